@@ -204,9 +204,13 @@ func logToAll(db *sql.DB, info *audit.Info) (err error) {
 		return err
 	}
 	defer tx.Rollback() //nolint:Errcheck
-	rowid, err := insertRow(db, info)
-	if err != nil {
-		return err
+	var rowid int64
+	// token lifecycle events don't belong in the signatures table
+	if info.EventType() == audit.EventSignature {
+		rowid, err = insertRow(db, info)
+		if err != nil {
+			return err
+		}
 	}
 	if err := logGraylog(info, rowid); err != nil {
 		return err
@@ -259,6 +263,19 @@ func logToFile(info *audit.Info, rowid int64) error {
 }
 
 func fmtRow(info *audit.Info, rowid int64) string {
+	if eventType := info.EventType(); eventType != audit.EventSignature {
+		reason := info.Attributes["token.reason"]
+		if reason == nil {
+			reason = ""
+		}
+		return fmt.Sprintf("[%s] event=%s server=%s token=%s reason=%q",
+			info.Attributes["event.timestamp"],
+			eventType,
+			info.Attributes["event.hostname"],
+			info.Attributes["token.name"],
+			reason,
+		)
+	}
 	client := info.Attributes["client.name"]
 	if client == nil {
 		client = ""
@@ -31,14 +31,20 @@ func logGraylog(info *audit.Info, rowid int64) error {
 	if auditConfig.GraylogURL == "" {
 		return nil
 	}
+	prefix := "sig."
+	if info.EventType() != audit.EventSignature {
+		prefix = "event."
+	}
 	msg := map[string]interface{}{
 		"version":       "1.1",
-		"host":          info.Attributes["sig.hostname"],
+		"host":          info.Attributes[prefix+"hostname"],
 		"short_message": fmtRow(info, rowid),
 		"level":         6, // INFO
 	}
-	if timestamp, err := time.Parse(time.RFC3339Nano, info.Attributes["sig.timestamp"].(string)); err == nil {
-		msg["timestamp"] = timestamp.Unix()
+	if ts, ok := info.Attributes[prefix+"timestamp"].(string); ok {
+		if timestamp, err := time.Parse(time.RFC3339Nano, ts); err == nil {
+			msg["timestamp"] = timestamp.Unix()
+		}
 	}
 	for k, v := range info.Attributes {
 		if v == nil {
@@ -18,6 +18,7 @@ package remotecmd
 
 import (
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -27,8 +28,10 @@ import (
 	"net/url"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/google/uuid"
 	"golang.org/x/net/http2"
 	"golang.org/x/oauth2"
 
@@ -36,6 +39,7 @@ import (
 	"github.com/mind-security/relic/v8/config"
 	"github.com/mind-security/relic/v8/internal/authmodel"
 	"github.com/mind-security/relic/v8/internal/httperror"
+	"github.com/mind-security/relic/v8/internal/zhttp"
 	"github.com/mind-security/relic/v8/lib/compresshttp"
 	"github.com/mind-security/relic/v8/lib/x509tools"
 )
@@ -48,6 +52,9 @@ type client struct {
 	config      *config.RemoteConfig
 	cli         *http.Client
 	tokenSource oauth2.TokenSource
+
+	bases     []string // servers to try, from the directory
+	encodings string   // compression accepted by the server
 }
 
 func newClient() (*client, error) {
@@ -68,10 +75,14 @@ func newClient() (*client, error) {
 	if err != nil {
 		return nil, err
 	}
+	proxy, err := config.ProxyFunc(cfg.Proxy)
+	if err != nil {
+		return nil, err
+	}
 	dialer := &net.Dialer{
 		Timeout: time.Duration(cfg.ConnectTimeout) * time.Second,
 	}
-	transport := &http.Transport{TLSClientConfig: tconf, DialContext: dialer.DialContext}
+	transport := &http.Transport{Proxy: proxy, TLSClientConfig: tconf, DialContext: dialer.DialContext}
 	if err := http2.ConfigureTransport(transport); err != nil {
 		return nil, err
 	}
@@ -91,24 +102,48 @@ func newClient() (*client, error) {
 }
 
 // Make a single API request to a named endpoint, handling directory lookup and failover automatically.
+// Error responses from the server are returned as a *RemoteError.
 func CallRemote(endpoint, method string, query *url.Values, body ReaderGetter) (*http.Response, error) {
+	cli, err := getClient()
+	if err != nil {
+		return nil, err
+	}
+	return cli.doRequest(cli.bases, endpoint, method, cli.encodings, query, body)
+}
+
+var (
+	sharedMu     sync.Mutex
+	sharedClient *client
+)
+
+// Get the client shared by all requests in this process, creating it and
+// consulting the directory on first use. Reusing one transport lets many sign
+// requests share a connection instead of each paying for a TLS handshake.
+func getClient() (*client, error) {
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+	if sharedClient != nil {
+		return sharedClient, nil
+	}
 	cli, err := newClient()
 	if err != nil {
 		return nil, err
 	}
 	cfg := shared.CurrentConfig.Remote
-	bases := []string{cfg.DirectoryURL}
+	cli.bases = []string{cfg.DirectoryURL}
 	metadata, serverEncodings, err := cli.getDirectory(cfg.DirectoryURL)
 	if err != nil {
 		return nil, err
 	} else if len(metadata.Hosts) > 0 {
 		// list of direct URLs provided
-		bases = metadata.Hosts
+		cli.bases = metadata.Hosts
 	}
+	cli.encodings = serverEncodings
 	if err := cli.interactiveAuth(metadata); err != nil {
 		return nil, fmt.Errorf("configuring interactive authentication: %w", err)
 	}
-	return cli.doRequest(bases, endpoint, method, serverEncodings, query, body)
+	sharedClient = cli
+	return cli, nil
 }
 
 func (cli *client) interactiveAuth(metadata *authmodel.Metadata) error {
@@ -195,6 +230,8 @@ func (cli *client) buildRequest(base, endpoint, method, encoding string, query *
 	}
 	if endpoint == "directory" {
 		request.Header.Set("Accept", "application/json, */*")
+	} else if endpoint == "sign" && argPriority != "" {
+		request.Header.Set("X-Relic-Priority", argPriority)
 	}
 	return request, nil
 }
@@ -206,31 +243,34 @@ func makeTLSConfig(cfg *config.RemoteConfig) (*tls.Config, error) {
 		return nil, err
 	}
 	x509tools.SetKeyLogFile(tconf)
+	if cfg.FIPS() {
+		x509tools.RestrictFIPS(tconf)
+	}
+	cfg.TLSOptions().Apply(tconf)
 	if cfg.CertFile == "" && cfg.KeyFile == "" {
 		return tconf, nil
 	}
-	var err error
-	var certBytes, keyBytes []byte
-	if strings.Contains(cfg.CertFile, "-----BEGIN") {
-		certBytes = []byte(cfg.CertFile)
-	} else {
-		certBytes, err = os.ReadFile(cfg.CertFile)
-		if err != nil {
-			return nil, fmt.Errorf("remote.certfile: %w", err)
-		}
+	certBytes, err := config.ReadFileOrSecret(cfg.CertFile)
+	if err != nil {
+		return nil, fmt.Errorf("remote.certfile: %w", err)
 	}
-	if strings.Contains(cfg.KeyFile, "-----BEGIN") {
-		keyBytes = []byte(cfg.KeyFile)
-	} else {
-		keyBytes, err = os.ReadFile(cfg.KeyFile)
-		if err != nil {
-			return nil, fmt.Errorf("remote.keyfile: %w", err)
-		}
+	keyBytes, err := config.ReadFileOrSecret(cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("remote.keyfile: %w", err)
 	}
 	tlscert, err := tls.X509KeyPair(certBytes, keyBytes)
 	if err != nil {
 		return nil, err
 	}
+	if cfg.FIPS() {
+		leaf, err := x509.ParseCertificate(tlscert.Certificate[0])
+		if err != nil {
+			return nil, err
+		}
+		if err := x509tools.CheckFIPSKey(leaf.PublicKey); err != nil {
+			return nil, fmt.Errorf("remote client certificate: %w", err)
+		}
+	}
 	// When the server is running behind nginx, nginx must be configured to send
 	// at least one CA-cert to the client to pick from. However, it's not
 	// feasible to list every cert the server would accept as most of them are
@@ -256,6 +296,9 @@ func (cli *client) doRequest(bases []string, endpoint, method, encodings string,
 		bases = repeated
 	}
 
+	// every attempt carries the same ID so the server logs can be correlated
+	// with this operation
+	reqID := uuid.NewString()
 loop:
 	for i, base := range bases {
 		var request *http.Request
@@ -263,6 +306,7 @@ loop:
 		if err != nil {
 			return nil, err
 		}
+		request.Header.Set(zhttp.RequestIDHeader, reqID)
 		response, err = cli.cli.Do(request)
 		if request.Body != nil {
 			request.Body.Close()
@@ -270,7 +314,7 @@ loop:
 		if err == nil {
 			if response.StatusCode < 300 {
 				if i != 0 {
-					fmt.Printf("successfully contacted %s\n", request.URL)
+					fmt.Fprintf(os.Stderr, "successfully contacted %s\n", request.URL)
 				}
 				break loop
 			}
@@ -282,9 +326,9 @@ loop:
 			encodings = ""
 			goto loop
 		} else if httperror.Temporary(err) && i+1 < len(bases) {
-			fmt.Printf("%s\nunable to connect to %s; trying next server\n", err, request.URL)
+			fmt.Fprintf(os.Stderr, "%s\nunable to connect to %s; trying next server\n", err, request.URL)
 		} else {
-			return nil, err
+			return nil, fmt.Errorf("%w (request ID %s)", classifyError(err), reqID)
 		}
 	}
 	if response != nil {
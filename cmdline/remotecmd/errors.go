@@ -0,0 +1,95 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package remotecmd
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/mind-security/relic/v8/internal/httperror"
+)
+
+// Errors returned by CallRemote and the commands built on it can be tested
+// against these with errors.Is to decide whether to retry or fall back.
+var (
+	ErrUnauthorized     = errors.New("client is not authenticated")
+	ErrForbidden        = errors.New("permission denied")
+	ErrKeyNotFound      = errors.New("key not found")
+	ErrTokenUnavailable = errors.New("token unavailable")
+	ErrTimestampFailed  = errors.New("timestamp failed")
+)
+
+// RemoteError is a failure reported by the server. It matches one of the
+// sentinel errors above when the cause is known, and also unwraps to the
+// httperror.Problem or httperror.ResponseError describing the response.
+type RemoteError struct {
+	StatusCode int
+	Kind       error // one of the sentinel errors, or nil
+	Err        error
+}
+
+func (e *RemoteError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RemoteError) Unwrap() []error {
+	if e.Kind == nil {
+		return []error{e.Err}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+// Temporary reports whether the same request might succeed later
+func (e *RemoteError) Temporary() bool {
+	return httperror.Temporary(e.Err)
+}
+
+// classifyError wraps an error response from the server in a RemoteError.
+// Other errors are returned unchanged.
+func classifyError(err error) error {
+	var problem httperror.Problem
+	var respErr httperror.ResponseError
+	switch {
+	case errors.As(err, &problem):
+		return &RemoteError{StatusCode: problem.Status, Kind: problemKind(problem), Err: err}
+	case errors.As(err, &respErr):
+		return &RemoteError{StatusCode: respErr.StatusCode, Kind: statusKind(respErr.StatusCode), Err: err}
+	}
+	return err
+}
+
+func problemKind(problem httperror.Problem) error {
+	switch problem.Type {
+	case httperror.ProblemKeyNotFound:
+		return ErrKeyNotFound
+	case httperror.ProblemTokenUnavailable, httperror.ProblemTokenBusy:
+		return ErrTokenUnavailable
+	case httperror.ProblemTimestampFailed:
+		return ErrTimestampFailed
+	}
+	return statusKind(problem.Status)
+}
+
+func statusKind(status int) error {
+	switch status {
+	case http.StatusUnauthorized:
+		return ErrUnauthorized
+	case http.StatusForbidden:
+		return ErrForbidden
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package remotecmd
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mind-security/relic/v8/internal/httperror"
+)
+
+func TestClassifyError(t *testing.T) {
+	respond := func(h http.Handler) error {
+		srv := httptest.NewServer(h)
+		defer srv.Close()
+		resp, err := http.Get(srv.URL)
+		require.NoError(t, err)
+		return fmt.Errorf("%w (request ID x)", classifyError(httperror.FromResponse(resp)))
+	}
+	cases := []struct {
+		h    http.Handler
+		kind error
+		temp bool
+	}{
+		{httperror.ErrKeyNotFound, ErrKeyNotFound, false},
+		{httperror.ErrForbidden, ErrForbidden, false},
+		{httperror.ErrFormatNotAllowed, ErrForbidden, false},
+		{httperror.ErrCertificateNotRecognized, ErrUnauthorized, false},
+		{httperror.ErrTokenBusy, ErrTokenUnavailable, true},
+		{httperror.ErrTokenUnavailable, ErrTokenUnavailable, true},
+		{httperror.Problem{Status: http.StatusBadGateway, Type: httperror.ProblemTimestampFailed}, ErrTimestampFailed, true},
+		{http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			http.Error(rw, "go away", http.StatusForbidden)
+		}), ErrForbidden, false},
+	}
+	for _, c := range cases {
+		err := respond(c.h)
+		assert.ErrorIs(t, err, c.kind, err.Error())
+		var remoteErr *RemoteError
+		require.ErrorAs(t, err, &remoteErr)
+		assert.Equal(t, c.temp, remoteErr.Temporary())
+	}
+	// the underlying problem is still available
+	err := respond(httperror.NoCertificateError("pgp"))
+	var problem httperror.Problem
+	require.ErrorAs(t, err, &problem)
+	assert.Equal(t, httperror.ProblemNoCert, problem.Type)
+	for _, kind := range []error{ErrUnauthorized, ErrForbidden, ErrKeyNotFound, ErrTokenUnavailable, ErrTimestampFailed} {
+		assert.False(t, errors.Is(err, kind))
+	}
+
+	// chunks are retried while an earlier attempt still holds the upload
+	assert.True(t, isUploadBusy(respond(httperror.ErrUploadBusy)))
+	assert.False(t, isUploadBusy(respond(httperror.ErrUploadNotFound)))
+}
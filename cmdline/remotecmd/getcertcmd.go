@@ -0,0 +1,85 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package remotecmd
+
+import (
+	"errors"
+	"io"
+	"net/url"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mind-security/relic/v8/cmdline/shared"
+	"github.com/mind-security/relic/v8/internal/httperror"
+	"github.com/mind-security/relic/v8/lib/atomicfile"
+)
+
+var GetCertCmd = &cobra.Command{
+	Use:   "get-cert <key>",
+	Short: "Get the X.509 certificate chain (PEM) or PGP public key (armored) of a remote key",
+	RunE:  getCertCmd,
+}
+
+var (
+	argGetCertType   string
+	argGetCertOutput string
+)
+
+func init() {
+	RemoteCmd.AddCommand(GetCertCmd)
+	GetCertCmd.Flags().StringVar(&argGetCertType, "type", "", "Certificate to fetch: x509 or pgp (default: x509 if the key has one, otherwise pgp)")
+	GetCertCmd.Flags().StringVarP(&argGetCertOutput, "output", "o", "", "Write the certificate to this file (default: standard output)")
+}
+
+func getCertCmd(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.New("specify a key name. See also 'list-keys'")
+	}
+	var blob []byte
+	var err error
+	switch argGetCertType {
+	case "x509", "pgp":
+		blob, err = getCert(args[0], argGetCertType)
+	case "":
+		blob, err = getCert(args[0], "x509")
+		var problem httperror.Problem
+		if errors.As(err, &problem) && problem.Type == httperror.ProblemNoCert {
+			blob, err = getCert(args[0], "pgp")
+		}
+	default:
+		return errors.New("--type must be x509 or pgp")
+	}
+	if err != nil {
+		return shared.Fail(err)
+	}
+	if argGetCertOutput == "" || argGetCertOutput == "-" {
+		_, err = os.Stdout.Write(blob)
+	} else {
+		err = atomicfile.WriteFile(argGetCertOutput, blob)
+	}
+	return shared.Fail(err)
+}
+
+func getCert(keyName, certType string) ([]byte, error) {
+	response, err := CallRemote("keys/"+url.PathEscape(keyName)+"/"+certType, "GET", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	return io.ReadAll(response.Body)
+}
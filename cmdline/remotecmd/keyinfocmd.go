@@ -0,0 +1,131 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package remotecmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mind-security/relic/v8/cmdline/shared"
+)
+
+var KeyInfoCmd = &cobra.Command{
+	Use:   "keyinfo <key>...",
+	Short: "Show the algorithm, certificate and timestamp settings of a remote key",
+	RunE:  keyInfoCmd,
+}
+
+var argKeyInfoJSON bool
+
+func init() {
+	RemoteCmd.AddCommand(KeyInfoCmd)
+	KeyInfoCmd.Flags().BoolVar(&argKeyInfoJSON, "json", false, "Print the raw JSON response")
+}
+
+type keyDetails struct {
+	Name      string
+	Algorithm string
+	KeySize   int
+	Digest    string
+	X509      *struct {
+		Subject           string
+		Issuer            string
+		SerialNumber      string
+		NotBefore         time.Time
+		NotAfter          time.Time
+		SHA256Fingerprint string
+	}
+	PGP *struct {
+		UserID      string
+		KeyID       string
+		Fingerprint string
+	}
+	Timestamp struct {
+		Enabled bool
+		Style   string
+		URLs    []string
+	}
+}
+
+func keyInfoCmd(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		return errors.New("specify one or more key names. See also 'list-keys'")
+	}
+	for i, keyName := range args {
+		details, err := getKeyDetails(keyName)
+		if err != nil {
+			return shared.Fail(err)
+		}
+		if argKeyInfoJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(details); err != nil {
+				return shared.Fail(err)
+			}
+			continue
+		}
+		if i > 0 {
+			fmt.Println()
+		}
+		printKeyDetails(details)
+	}
+	return nil
+}
+
+func getKeyDetails(keyName string) (*keyDetails, error) {
+	response, err := CallRemote("keys/"+url.PathEscape(keyName)+"/info", "GET", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	details := new(keyDetails)
+	if err := json.NewDecoder(response.Body).Decode(details); err != nil {
+		return nil, err
+	}
+	return details, nil
+}
+
+func printKeyDetails(d *keyDetails) {
+	fmt.Printf("Key:          %s\n", d.Name)
+	fmt.Printf("Algorithm:    %s %d\n", d.Algorithm, d.KeySize)
+	if d.Digest != "" {
+		fmt.Printf("Digest:       %s\n", d.Digest)
+	}
+	if c := d.X509; c != nil {
+		fmt.Printf("Subject:      %s\n", c.Subject)
+		fmt.Printf("Issuer:       %s\n", c.Issuer)
+		fmt.Printf("Serial:       %s\n", c.SerialNumber)
+		fmt.Printf("Valid:        %s to %s\n", c.NotBefore.UTC().Format(time.RFC3339), c.NotAfter.UTC().Format(time.RFC3339))
+		fmt.Printf("SHA-256:      %s\n", c.SHA256Fingerprint)
+	}
+	if p := d.PGP; p != nil {
+		fmt.Printf("PGP user:     %s\n", p.UserID)
+		fmt.Printf("PGP key:      %s (%s)\n", p.KeyID, p.Fingerprint)
+	}
+	if t := d.Timestamp; t.Enabled {
+		fmt.Printf("Timestamp:    %s %s\n", t.Style, strings.Join(t.URLs, " "))
+	} else {
+		fmt.Println("Timestamp:    none")
+	}
+}
@@ -20,6 +20,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net/url"
+	"os"
+	"strconv"
 
 	"github.com/mind-security/relic/v8/cmdline/shared"
 
@@ -32,13 +35,38 @@ var ListKeysCmd = &cobra.Command{
 	RunE:  listKeysCmd,
 }
 
+var (
+	argListName   string
+	argListRole   string
+	argListFormat string
+	argListLimit  int
+	argListOffset int
+)
+
 func init() {
 	RemoteCmd.AddCommand(ListKeysCmd)
+	ListKeysCmd.Flags().StringVar(&argListName, "name", "", "Only list keys whose name matches this glob")
+	ListKeysCmd.Flags().StringVar(&argListRole, "role", "", "Only list keys that this role may use")
+	ListKeysCmd.Flags().StringVar(&argListFormat, "format", "", "Only list keys that may sign this signature type")
+	ListKeysCmd.Flags().IntVar(&argListLimit, "limit", 0, "List at most this many keys")
+	ListKeysCmd.Flags().IntVar(&argListOffset, "offset", 0, "Skip this many matching keys before listing")
 }
 
 func listKeysCmd(cmd *cobra.Command, args []string) error {
+	query := url.Values{}
+	for param, value := range map[string]string{"name": argListName, "role": argListRole, "format": argListFormat} {
+		if value != "" {
+			query.Set(param, value)
+		}
+	}
+	if argListLimit != 0 {
+		query.Set("limit", strconv.Itoa(argListLimit))
+	}
+	if argListOffset != 0 {
+		query.Set("offset", strconv.Itoa(argListOffset))
+	}
 	var keyList []string
-	response, err := CallRemote("list_keys", "GET", nil, nil)
+	response, err := CallRemote("list_keys", "GET", &query, nil)
 	if err != nil {
 		return shared.Fail(err)
 	}
@@ -54,5 +82,9 @@ func listKeysCmd(cmd *cobra.Command, args []string) error {
 	for _, key := range keyList {
 		fmt.Println(key)
 	}
+	// tell the user there are more pages. Older servers don't send the total.
+	if total, err := strconv.Atoi(response.Header.Get("X-Total-Count")); err == nil && len(keyList) < total {
+		fmt.Fprintf(os.Stderr, "listed %d of %d matching keys\n", len(keyList), total)
+	}
 	return nil
 }
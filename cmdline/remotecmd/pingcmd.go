@@ -0,0 +1,131 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package remotecmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mind-security/relic/v8/cmdline/shared"
+	"github.com/mind-security/relic/v8/config"
+)
+
+var PingCmd = &cobra.Command{
+	Use:   "ping",
+	Short: "Show the remote server's version and the signature types it supports",
+	RunE:  pingCmd,
+}
+
+var argPingJSON bool
+
+func init() {
+	RemoteCmd.AddCommand(PingCmd)
+	PingCmd.Flags().BoolVar(&argPingJSON, "json", false, "Print the raw JSON response")
+}
+
+type serverVersion struct {
+	Version  string
+	Uptime   int64
+	Formats  []string
+	Features []string
+}
+
+func pingCmd(cmd *cobra.Command, args []string) error {
+	start := time.Now()
+	info, err := fetchServerVersion()
+	if err != nil {
+		if isNotFound(err) {
+			err = fmt.Errorf("the server is too old to report its version: %w", err)
+		}
+		return shared.Fail(err)
+	}
+	elapsed := time.Since(start)
+	if argPingJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return shared.Fail(enc.Encode(info))
+	}
+	fmt.Printf("Server:    relic/%s\n", info.Version)
+	fmt.Printf("Client:    %s\n", config.UserAgent)
+	fmt.Printf("Uptime:    %s\n", time.Duration(info.Uptime)*time.Second)
+	fmt.Printf("Latency:   %s\n", elapsed.Round(time.Millisecond))
+	fmt.Printf("Formats:   %s\n", strings.Join(info.Formats, " "))
+	fmt.Printf("Features:  %s\n", strings.Join(info.Features, " "))
+	return nil
+}
+
+func fetchServerVersion() (*serverVersion, error) {
+	response, err := CallRemote("version", "GET", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	info := new(serverVersion)
+	if err := json.NewDecoder(response.Body).Decode(info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+var (
+	versionOnce   sync.Once
+	cachedVersion *serverVersion
+	versionErr    error
+)
+
+// checkServerFormat asks the server, once per run, which signature types it
+// supports and warns or fails according to remote.versioncheck if sigType is
+// not one of them. Servers too old to answer are not checked.
+func checkServerFormat(sigType string) error {
+	// loads the client configuration
+	if _, err := getClient(); err != nil {
+		return err
+	}
+	mode := shared.CurrentConfig.Remote.VersionCheck
+	if mode == config.VersionCheckOff {
+		return nil
+	}
+	versionOnce.Do(func() {
+		cachedVersion, versionErr = fetchServerVersion()
+		if isNotFound(versionErr) {
+			versionErr = nil
+		}
+	})
+	if versionErr != nil {
+		if mode == config.VersionCheckError {
+			return fmt.Errorf("checking server version: %w", versionErr)
+		}
+		// let the request itself report the problem
+		return nil
+	}
+	if cachedVersion == nil || slices.Contains(cachedVersion.Formats, sigType) {
+		return nil
+	}
+	err := fmt.Errorf("the server (relic/%s) does not support signature type %q, this client is %s", cachedVersion.Version, sigType, config.UserAgent)
+	if mode == config.VersionCheckError {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, "warning:", err)
+	return nil
+}
@@ -17,15 +17,23 @@
 package remotecmd
 
 import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
 	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 
 	"github.com/spf13/cobra"
 
 	"github.com/mind-security/relic/v8/cmdline/shared"
+	"github.com/mind-security/relic/v8/lib/archivesign"
+	"github.com/mind-security/relic/v8/lib/atomicfile"
+	"github.com/mind-security/relic/v8/lib/audit"
 	"github.com/mind-security/relic/v8/signers"
 )
 
@@ -38,112 +46,189 @@ var SignCmd = &cobra.Command{
 var (
 	argIfUnsigned bool
 	argSigType    string
+	argChainOut   string
+	argMeta       []string
+	argPriority   string
 )
 
 func init() {
 	RemoteCmd.AddCommand(SignCmd)
-	SignCmd.Flags().StringVarP(&argKeyName, "key", "k", "", "Name of key on remote server to use")
+	SignCmd.Flags().StringVarP(&argKeyName, "key", "k", "", "Name of key on remote server to use (default: the client's defaultkey on the server)")
 	SignCmd.Flags().StringVarP(&argFile, "file", "f", "", "Input file to sign")
 	SignCmd.Flags().StringVarP(&argOutput, "output", "o", "", "Output file. Defaults to same as --file.")
 	SignCmd.Flags().StringVarP(&argSigType, "sig-type", "T", "", "Specify signature type (default: auto-detect)")
 	SignCmd.Flags().BoolVar(&argIfUnsigned, "if-unsigned", false, "Skip signing if the file already has a signature")
+	SignCmd.Flags().StringArrayVar(&argMeta, "meta", nil, "Attach key=value metadata identifying the artifact, such as product=foo or version=1.2.3, to the server's audit record. May be repeated.")
+	SignCmd.Flags().StringVar(&argPriority, "priority", "", "Queue behind other requests when the token is busy, if lower than the priority of the client's roles")
+	SignCmd.Flags().StringVar(&argChainOut, "chain-out", "", "Write the signing certificate chain to this file as PEM, for use with \"relic verify --intermediates\"")
 	shared.AddDigestFlag(SignCmd)
+	shared.AddMembersFlags(SignCmd)
+	shared.AddDetachFlag(SignCmd)
 	shared.AddLateHook(func() {
 		signers.MergeFlags(SignCmd)
 	})
 }
 
 func signCmd(cmd *cobra.Command, args []string) (err error) {
-	if argFile == "" || argKeyName == "" {
-		return errors.New("--file and --key are required")
+	if argFile == "" {
+		return errors.New("--file is required")
+	}
+	if shared.ArgDetachOutput != "" && (argOutput != "" || shared.ArgMembers) {
+		return errors.New("--detach-output can't be used with --output or --members")
+	}
+	if _, err := audit.ParseMetadata(argMeta); err != nil {
+		return fmt.Errorf("--meta: %w", err)
+	}
+	if argPriority != "" {
+		if _, err := strconv.Atoi(argPriority); err != nil {
+			return errors.New("--priority must be an integer")
+		}
 	}
 	if argOutput == "" {
 		argOutput = argFile
 	}
+	if shared.ArgMembers {
+		err := shared.SignMembers(cmd, argFile, argOutput, func(path string) error {
+			_, err := signFile(cmd, path, path, "", shared.ExistingSkip)
+			return err
+		})
+		return shared.Fail(err)
+	}
+	outpath, err := signFile(cmd, argFile, argOutput, argSigType, shared.IfUnsignedPolicy(argIfUnsigned))
+	if errors.Is(err, archivesign.ErrAlreadySigned) {
+		fmt.Fprintf(os.Stderr, "skipping already-signed file: %s\n", argFile)
+		return nil
+	} else if err != nil {
+		return shared.Fail(err)
+	}
+	shared.ReportSigned(argFile, outpath)
+	return nil
+}
+
+// signFile signs a single file and returns the path the result was written
+// to. existing says what to do if the file already has a signature; with
+// shared.ExistingSkip archivesign.ErrAlreadySigned is returned.
+func signFile(cmd *cobra.Command, inpath, outpath, sigType string, existing shared.ExistingPolicy) (string, error) {
 	// detect signature type
-	mod, err := signers.ByFile(argFile, argSigType)
+	mod, err := signers.ByFile(inpath, sigType)
 	if err != nil {
-		return shared.Fail(err)
+		return "", err
 	}
 	if mod.Sign == nil {
-		return shared.Fail(fmt.Errorf("can't sign files of type: %s", mod.Name))
+		return "", fmt.Errorf("can't sign files of type: %s", mod.Name)
+	}
+	if err := checkServerFormat(mod.Name); err != nil {
+		return "", err
 	}
 	// parse signer-specific flags
 	flags, err := mod.FlagsFromCmdline(cmd.Flags())
 	if err != nil {
-		return shared.Fail(err)
+		return "", err
+	}
+	if dest, err := shared.DetachedOutputPath(mod, flags, inpath); err != nil {
+		return "", err
+	} else if dest != "" {
+		outpath = dest
 	}
-	infile, err := shared.OpenForPatching(argFile, argOutput)
+	infile, err := shared.OpenForPatching(inpath, outpath)
 	if err != nil {
-		return shared.Fail(err)
+		return "", err
 	} else if infile == os.Stdin {
 		if !mod.AllowStdin {
-			return shared.Fail(errors.New("this signature type does not support reading from stdin"))
+			return "", errors.New("this signature type does not support reading from stdin")
 		}
 	} else {
 		defer infile.Close()
 	}
-	if argIfUnsigned {
-		if infile == os.Stdin {
-			return shared.Fail(errors.New("cannot use --if-unsigned with standard input"))
-		}
-		if signed, err := mod.IsSigned(infile); err != nil {
-			return shared.Fail(err)
-		} else if signed {
-			fmt.Fprintf(os.Stderr, "skipping already-signed file: %s\n", argFile)
-			return nil
-		}
-		if _, err := infile.Seek(0, 0); err != nil {
-			return shared.Fail(fmt.Errorf("rewinding input file: %w", err))
-		}
+	if err := shared.CheckExisting(mod, infile, flags, existing); err != nil {
+		return "", err
 	}
 	// transform input if needed
+	if err := shared.InitClientConfig(); err != nil {
+		return "", err
+	}
+	// zero lets the server choose a digest for the key
 	hash, err := shared.GetDigest()
 	if err != nil {
-		return err
+		return "", err
 	}
 	opts := signers.SignOpts{
-		Path:  argFile,
+		Path:  inpath,
 		Hash:  hash,
 		Flags: flags,
 	}
+	if rc := shared.CurrentConfig.Remote; rc != nil {
+		opts.SpoolThreshold = rc.SpoolThreshold
+	}
 	transform, err := mod.GetTransform(infile, opts)
 	if err != nil {
-		return shared.Fail(err)
+		return "", err
 	}
 	// build request
 	values := url.Values{}
-	values.Add("key", argKeyName)
-	values.Add("filename", filepath.Base(argFile))
+	if argKeyName != "" {
+		values.Add("key", argKeyName)
+	}
+	values.Add("filename", filepath.Base(inpath))
 	values.Add("sigtype", mod.Name)
 	if err := flags.ToQuery(values); err != nil {
-		return shared.Fail(err)
+		return "", err
 	}
 	if err := setDigestQueryParam(values); err != nil {
-		return err
+		return "", err
+	}
+	if argChainOut != "" {
+		values.Add("chain", "1")
+	}
+	for _, item := range argMeta {
+		values.Add("meta", item)
 	}
 	// do request
-	response, err := CallRemote("sign", "POST", &values, transform)
+	response, err := CallRemoteUpload("sign", "POST", &values, transform)
 	if err != nil {
-		return shared.Fail(err)
+		return "", err
 	}
 	defer response.Body.Close()
+	if argChainOut != "" {
+		if err := writeChain(response.Header, argChainOut); err != nil {
+			return "", err
+		}
+	}
 	// apply the result
-	if err := transform.Apply(argOutput, response.Header.Get("Content-Type"), response.Body); err != nil {
-		return shared.Fail(err)
+	if err := transform.Apply(outpath, response.Header.Get("Content-Type"), response.Body); err != nil {
+		return "", err
 	}
 	// if needed, do a final fixup step
 	if mod.Fixup != nil {
-		f, err := os.OpenFile(argOutput, os.O_RDWR, 0)
+		f, err := os.OpenFile(outpath, os.O_RDWR, 0)
 		if err != nil {
-			return shared.Fail(err)
+			return "", err
 		}
 		defer f.Close()
 		if err := mod.Fixup(f); err != nil {
-			return shared.Fail(err)
+			return "", err
 		}
 	}
+	return outpath, nil
+}
 
-	fmt.Fprintf(os.Stderr, "Signed %s\n", argFile)
-	return nil
+// writeChain saves the certificate chain returned by the server as PEM
+func writeChain(header http.Header, path string) error {
+	value := header.Get("X-Relic-Chain")
+	if value == "" {
+		return errors.New("server did not return a certificate chain; it may need sendchain enabled")
+	}
+	der, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return fmt.Errorf("parsing certificate chain from server: %w", err)
+	}
+	certs, err := x509.ParseCertificates(der)
+	if err != nil {
+		return fmt.Errorf("parsing certificate chain from server: %w", err)
+	}
+	var blob []byte
+	for _, cert := range certs {
+		blob = append(blob, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+	}
+	return atomicfile.WriteFile(path, blob)
 }
@@ -0,0 +1,90 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package remotecmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mind-security/relic/v8/cmdline/shared"
+	"github.com/mind-security/relic/v8/internal/httperror"
+	"github.com/mind-security/relic/v8/lib/atomicfile"
+)
+
+// TrustRootsFile returns the path to a file holding the certificates of the
+// given type, "x509" or "pgp", that the signing server says verifiers should
+// trust. The file is kept in the user's cache directory and fetched again once
+// it is older than ttl. An empty path means the server has none of that type.
+func TrustRootsFile(certType string, ttl time.Duration) (string, error) {
+	if err := shared.InitClientConfig(); err != nil {
+		return "", err
+	}
+	cfg := shared.CurrentConfig.Remote
+	if cfg == nil {
+		return "", errors.New("missing remote section in config file")
+	}
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	server := cfg.DirectoryURL
+	if server == "" {
+		server = cfg.URL
+	}
+	d := sha256.Sum256([]byte(server))
+	path := filepath.Join(cacheDir, "relic", "trust-"+hex.EncodeToString(d[:8])+"."+certType)
+	if st, err := os.Stat(path); err == nil && time.Since(st.ModTime()) < ttl {
+		return cachedTrustPath(path, st.Size()), nil
+	}
+	blob, err := fetchTrustRoots(certType)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", err
+	}
+	// an empty file remembers that the server has none
+	if err := atomicfile.WriteFile(path, blob); err != nil {
+		return "", err
+	}
+	return cachedTrustPath(path, int64(len(blob))), nil
+}
+
+func fetchTrustRoots(certType string) ([]byte, error) {
+	response, err := CallRemote("trust/"+certType, "GET", nil, nil)
+	if err != nil {
+		var problem httperror.Problem
+		if errors.As(err, &problem) && problem.Type == httperror.ProblemNoCert {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer response.Body.Close()
+	return io.ReadAll(response.Body)
+}
+
+func cachedTrustPath(path string, size int64) string {
+	if size == 0 {
+		return ""
+	}
+	return path
+}
@@ -0,0 +1,204 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package remotecmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/mind-security/relic/v8/internal/httperror"
+)
+
+// uploadStatus is the server's record of how much of an upload it has
+type uploadStatus struct {
+	ID     string
+	Offset int64
+}
+
+// prefixedBody replays a chunk that was read to decide how to send the body,
+// followed by the rest of the original stream. Retries start over from the
+// original.
+type prefixedBody struct {
+	prefix []byte
+	rest   io.Reader
+	orig   ReaderGetter
+}
+
+func (b *prefixedBody) GetReader() (io.Reader, error) {
+	if b.rest == nil {
+		return b.orig.GetReader()
+	}
+	r := io.MultiReader(bytes.NewReader(b.prefix), b.rest)
+	b.rest = nil
+	return r, nil
+}
+
+type bytesBody []byte
+
+func (b bytesBody) GetReader() (io.Reader, error) {
+	return bytes.NewReader(b), nil
+}
+
+// CallRemoteUpload is like CallRemote, but a body larger than the configured
+// chunk size is first sent as a resumable upload. The request is then made to
+// the server holding the upload, with its ID in the "upload" query parameter.
+func CallRemoteUpload(endpoint, method string, query *url.Values, body ReaderGetter) (*http.Response, error) {
+	cli, err := getClient()
+	if err != nil {
+		return nil, err
+	}
+	chunkSize := cli.config.UploadChunkSize
+	if chunkSize <= 0 {
+		return CallRemote(endpoint, method, query, body)
+	}
+	r, err := body.GetReader()
+	if err != nil {
+		return nil, err
+	}
+	var first bytes.Buffer
+	if _, err := first.ReadFrom(io.LimitReader(r, chunkSize)); err != nil {
+		return nil, err
+	} else if int64(first.Len()) < chunkSize {
+		// small enough to send in one go
+		return CallRemote(endpoint, method, query, bytesBody(first.Bytes()))
+	}
+	chunk := first.Bytes()
+	n := len(chunk)
+	base, id, err := cli.createUpload()
+	if isNotFound(err) {
+		// server predates resumable uploads
+		return CallRemote(endpoint, method, query, &prefixedBody{prefix: chunk, rest: r, orig: body})
+	} else if err != nil {
+		return nil, err
+	}
+	var offset int64
+	for n > 0 {
+		if err := cli.sendChunk(base, id, offset, chunk[:n]); err != nil {
+			cli.deleteUpload(base, id)
+			return nil, fmt.Errorf("uploading at offset %d: %w", offset, err)
+		}
+		offset += int64(n)
+		n, err = io.ReadFull(r, chunk)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			cli.deleteUpload(base, id)
+			return nil, err
+		}
+	}
+	q := url.Values{}
+	if query != nil {
+		for k, v := range *query {
+			q[k] = v
+		}
+	}
+	q.Set("upload", id)
+	response, err := cli.doRequest([]string{base}, endpoint, method, cli.encodings, &q, nil)
+	if err != nil {
+		cli.deleteUpload(base, id)
+	}
+	return response, err
+}
+
+// createUpload starts an upload, returning it and the server that holds it.
+// The rest of the upload must go to the same server.
+func (cli *client) createUpload() (base, id string, err error) {
+	response, err := cli.doRequest(cli.bases, "uploads", "POST", "", nil, nil)
+	if err != nil {
+		return "", "", err
+	}
+	defer response.Body.Close()
+	var status uploadStatus
+	if err := json.NewDecoder(response.Body).Decode(&status); err != nil {
+		return "", "", err
+	}
+	base = response.Request.URL.ResolveReference(&url.URL{Path: "./"}).String()
+	return base, status.ID, nil
+}
+
+// uploadBusyWait is how long to keep retrying a chunk while the server is
+// still reading an earlier attempt from a dropped connection
+const uploadBusyWait = 5 * time.Minute
+
+// sendChunk appends a chunk to an upload. If the response is lost the
+// server is asked whether the chunk arrived anyway.
+func (cli *client) sendChunk(base, id string, offset int64, chunk []byte) error {
+	query := url.Values{"offset": []string{strconv.FormatInt(offset, 10)}}
+	deadline := time.Now().Add(uploadBusyWait)
+	delay := time.Second
+	for {
+		response, err := cli.doRequest([]string{base}, "uploads/"+id, "PUT", cli.encodings, &query, bytesBody(chunk))
+		if err == nil {
+			response.Body.Close()
+			return nil
+		}
+		if status, serr := cli.uploadStatus(base, id); serr == nil && status.Offset == offset+int64(len(chunk)) {
+			return nil
+		}
+		if !isUploadBusy(err) || time.Now().Add(delay).After(deadline) {
+			return err
+		}
+		time.Sleep(delay)
+		if delay < 16*time.Second {
+			delay *= 2
+		}
+	}
+}
+
+func (cli *client) uploadStatus(base, id string) (*uploadStatus, error) {
+	response, err := cli.doRequest([]string{base}, "uploads/"+id, "GET", "", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	status := new(uploadStatus)
+	if err := json.NewDecoder(response.Body).Decode(status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// deleteUpload discards an upload that won't be used. It is best-effort since
+// the server also discards abandoned uploads eventually.
+func (cli *client) deleteUpload(base, id string) {
+	response, err := cli.doRequest([]string{base}, "uploads/"+id, "DELETE", "", nil, nil)
+	if err == nil {
+		response.Body.Close()
+	}
+}
+
+func isUploadBusy(err error) bool {
+	var problem httperror.Problem
+	return errors.As(err, &problem) && problem.Type == httperror.ProblemUploadBusy
+}
+
+func isNotFound(err error) bool {
+	var problem httperror.Problem
+	var respErr httperror.ResponseError
+	switch {
+	case errors.As(err, &problem):
+		return problem.Status == http.StatusNotFound || problem.Status == http.StatusMethodNotAllowed
+	case errors.As(err, &respErr):
+		return respErr.StatusCode == http.StatusNotFound || respErr.StatusCode == http.StatusMethodNotAllowed
+	}
+	return false
+}
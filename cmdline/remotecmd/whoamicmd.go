@@ -0,0 +1,101 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package remotecmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mind-security/relic/v8/cmdline/shared"
+	"github.com/mind-security/relic/v8/internal/authmodel"
+)
+
+var WhoamiCmd = &cobra.Command{
+	Use:   "whoami",
+	Short: "Show how the remote server identifies this client and which roles it has",
+	RunE:  whoamiCmd,
+}
+
+var argWhoamiJSON bool
+
+func init() {
+	RemoteCmd.AddCommand(WhoamiCmd)
+	WhoamiCmd.Flags().BoolVar(&argWhoamiJSON, "json", false, "Print the raw JSON response")
+}
+
+func whoamiCmd(cmd *cobra.Command, args []string) error {
+	response, err := CallRemote("whoami", "GET", nil, nil)
+	if err != nil {
+		return shared.Fail(err)
+	}
+	defer response.Body.Close()
+	ident := new(authmodel.Identity)
+	if err := json.NewDecoder(response.Body).Decode(ident); err != nil {
+		return shared.Fail(err)
+	}
+	if argWhoamiJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(ident); err != nil {
+			return shared.Fail(err)
+		}
+	} else {
+		printIdentity(ident)
+	}
+	if !ident.Authenticated {
+		// exit non-zero so scripts can check access
+		os.Exit(1)
+	}
+	return nil
+}
+
+func printIdentity(ident *authmodel.Identity) {
+	if ident.Authenticated {
+		fmt.Println("Authenticated: yes")
+	} else {
+		fmt.Printf("Authenticated: no (%s)\n", ident.Error)
+	}
+	if ident.Name != "" {
+		fmt.Printf("Name:          %s\n", ident.Name)
+	}
+	if ident.Fingerprint != "" {
+		fmt.Printf("Fingerprint:   %s\n", ident.Fingerprint)
+	}
+	if ident.Subject != "" {
+		fmt.Printf("Subject:       %s\n", ident.Subject)
+	}
+	if ident.Issuer != "" {
+		fmt.Printf("Issuer:        %s\n", ident.Issuer)
+	}
+	if ident.SpiffeID != "" {
+		fmt.Printf("SPIFFE ID:     %s\n", ident.SpiffeID)
+	}
+	if ident.Authenticated {
+		roles := "none"
+		if len(ident.Roles) != 0 {
+			roles = strings.Join(ident.Roles, ", ")
+		}
+		fmt.Printf("Roles:         %s\n", roles)
+	}
+	if ident.DefaultKey != "" {
+		fmt.Printf("Default key:   %s\n", ident.DefaultKey)
+	}
+}
@@ -19,16 +19,13 @@ package servecmd
 import (
 	"errors"
 	"fmt"
-	"net"
 	"net/http"
 
-	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 
 	"github.com/mind-security/relic/v8/cmdline/shared"
+	"github.com/mind-security/relic/v8/internal/signinit"
 	"github.com/mind-security/relic/v8/server/daemon"
-
-	_ "net/http/pprof"
 )
 
 var ServeCmd = &cobra.Command{
@@ -52,7 +49,7 @@ func MakeServer() (*daemon.Daemon, error) {
 	if shared.CurrentConfig.Server == nil {
 		return nil, errors.New("Missing server section in configuration file")
 	}
-	if shared.CurrentConfig.Clients == nil {
+	if shared.CurrentConfig.Clients == nil && shared.CurrentConfig.Server.ClientsDir == "" {
 		return nil, errors.New("Missing clients section in configuration file")
 	}
 	if shared.CurrentConfig.Server.Listen == "" && shared.CurrentConfig.Server.ListenHTTP == "" {
@@ -66,26 +63,10 @@ func MakeServer() (*daemon.Daemon, error) {
 			return nil, errors.New("missing certfile option in server configuration file")
 		}
 	}
+	signinit.InitTokenAudit()
 	return daemon.New(shared.CurrentConfig, argTest)
 }
 
-func listenDebug() error {
-	if !shared.CurrentConfig.Server.ListenDebug {
-		return nil
-	}
-	lis, err := net.Listen("tcp", ":0")
-	if err != nil {
-		return err
-	}
-	log.Info().Msgf("serving debug info on http://%s/debug/pprof/", lis.Addr())
-	go func() {
-		// pprof installs itself into the default handler on import
-		err := http.Serve(lis, nil)
-		log.Err(err).Msg("debug listener stopped")
-	}()
-	return nil
-}
-
 func serveCmd(cmd *cobra.Command, args []string) error {
 	// let journald add timestamps
 	srv, err := MakeServer()
@@ -96,9 +77,7 @@ func serveCmd(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 	go watchSignals(srv)
-	if err := listenDebug(); err != nil {
-		return err
-	}
+	defer signinit.CloseAudit()
 	if err := srv.Serve(); err != nil && err != http.ErrServerClosed {
 		return shared.Fail(err)
 	}
@@ -31,6 +31,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/mind-security/relic/v8/cmdline/shared"
+	"github.com/mind-security/relic/v8/config"
 	"github.com/mind-security/relic/v8/lib/certloader"
 	"github.com/mind-security/relic/v8/lib/x509tools"
 )
@@ -119,6 +120,9 @@ func setupCmd(cmd *cobra.Command, args []string) error {
 	if argSelfSign && shared.CurrentConfig.Server.KeyFile == "" {
 		return errors.New("Missing certfile option in server configuration file")
 	}
+	if config.IsInline(shared.CurrentConfig.Server.KeyFile) || (argSelfSign && config.IsInline(shared.CurrentConfig.Server.CertFile)) {
+		return errors.New("setup writes the server key and certificate to files, so keyfile and certfile must be paths")
+	}
 	if x509tools.ArgCommonName == "" {
 		return errors.New("--commonName is required")
 	}
@@ -43,7 +43,8 @@ func watchSignals(srv *daemon.Daemon) {
 		sig := <-ch
 		switch {
 		case sig == syscall.SIGUSR1:
-			// no longer used
+			n := srv.FlushSignatureCache()
+			log.Info().Int("signatures", n).Msg("flushed signature cache")
 		case !already:
 			log.Info().Stringer("signal", sig).Msg("initiating graceful shutdown")
 			go func() {
@@ -0,0 +1,87 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package shared
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mind-security/relic/v8/signers"
+)
+
+// DetachAuto writes detached signatures beside the input, using the usual
+// suffix for the signature type
+const DetachAuto = "auto"
+
+var ArgDetachOutput string
+
+func AddDetachFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&ArgDetachOutput, "detach-output", "", "Where to write detached signatures: \"auto\" (the default if no value is given) to write beside the input with the usual suffix for the signature type, a suffix starting with \".\" such as \".sig\", a directory, or \"-\" for standard output")
+	cmd.Flags().Lookup("detach-output").NoOptDefVal = DetachAuto
+}
+
+// DetachedOutputPath returns where --detach-output says to write the detached
+// signature of inpath, or "" if the option was not given
+func DetachedOutputPath(mod *signers.Signer, flags *signers.FlagValues, inpath string) (string, error) {
+	if ArgDetachOutput == "" {
+		return "", nil
+	}
+	var suffix string
+	if mod.DetachedSuffix != nil {
+		suffix = mod.DetachedSuffix(flags)
+	}
+	if suffix == "" {
+		return "", fmt.Errorf("--detach-output: %s signatures with these options are not detached", mod.Name)
+	}
+	dest := ArgDetachOutput
+	switch {
+	case dest == "-":
+		return dest, nil
+	case inpath == "-":
+		return "", errors.New("--detach-output: reading from standard input, so the signature can only be written to standard output")
+	case dest == DetachAuto:
+		return inpath + suffix, nil
+	case strings.HasPrefix(dest, ".") && !strings.ContainsAny(dest, `/\`):
+		return inpath + dest, nil
+	}
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return "", err
+	}
+	return filepath.Join(dest, filepath.Base(inpath)+suffix), nil
+}
+
+// ReportSigned tells the user which file was signed and, if the result went
+// somewhere else, where it was written
+func ReportSigned(inpath, outpath string) {
+	name := inpath
+	if inpath == "-" {
+		name = "standard input"
+	}
+	switch outpath {
+	case "-":
+		fmt.Fprintf(os.Stderr, "Signed %s, wrote signature to standard output\n", name)
+	case inpath:
+		fmt.Fprintf(os.Stderr, "Signed %s\n", name)
+	default:
+		fmt.Fprintf(os.Stderr, "Signed %s, wrote %s\n", name, outpath)
+	}
+}
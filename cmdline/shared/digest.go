@@ -30,13 +30,14 @@ var ArgDigest string
 const DefaultHash = "SHA-256"
 
 func AddDigestFlag(cmd *cobra.Command) {
-	cmd.Flags().StringVar(&ArgDigest, "digest", DefaultHash, "Specify a digest algorithm")
+	cmd.Flags().StringVar(&ArgDigest, "digest", "", "Specify a digest algorithm (default: chosen according to the key)")
 }
 
+// GetDigest returns the digest chosen with --digest, or zero if the key's
+// default should be used
 func GetDigest() (hash crypto.Hash, err error) {
 	if ArgDigest == "" {
-		// TODO: figure out why this randomly started coming back blank
-		ArgDigest = DefaultHash
+		return 0, nil
 	}
 	hash = x509tools.HashByName(ArgDigest)
 	if hash == 0 {
@@ -0,0 +1,97 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package shared
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/mind-security/relic/v8/lib/archivesign"
+	"github.com/mind-security/relic/v8/signers"
+)
+
+// ExistingPolicy says what to do when a file to be signed in place already
+// has a signature
+type ExistingPolicy int
+
+const (
+	// ExistingCheck fails unless --replace or --nest says what to do with the
+	// existing signatures
+	ExistingCheck ExistingPolicy = iota
+	// ExistingSkip returns archivesign.ErrAlreadySigned so the caller can skip
+	// the file, as for --if-unsigned
+	ExistingSkip
+	// ExistingAllow signs without checking, leaving it to the format
+	ExistingAllow
+)
+
+// IfUnsignedPolicy returns ExistingSkip if --if-unsigned was given, otherwise
+// ExistingCheck
+func IfUnsignedPolicy(ifUnsigned bool) ExistingPolicy {
+	if ifUnsigned {
+		return ExistingSkip
+	}
+	return ExistingCheck
+}
+
+// CheckExisting looks for signatures already present in infile before it is
+// signed by mod. Unless skipping signed files, detached signatures and
+// formats that never sign in place are not checked. If the file was read then
+// it is rewound afterwards.
+func CheckExisting(mod *signers.Signer, infile *os.File, flags *signers.FlagValues, policy ExistingPolicy) error {
+	replace, nest := flags.GetBool("replace"), flags.GetBool("nest")
+	if replace && nest {
+		return errors.New("--replace and --nest are mutually exclusive")
+	} else if nest && mod.Resign != signers.ResignAppend {
+		return fmt.Errorf("--nest: %s files can only hold one signature; use --replace instead", mod.Name)
+	}
+	switch {
+	case policy == ExistingAllow:
+		return nil
+	case policy == ExistingSkip:
+		// always check
+	case mod.Resign == signers.ResignNone || replace || nest:
+		return nil
+	case mod.DetachedSuffix != nil && mod.DetachedSuffix(flags) != "":
+		return nil
+	}
+	if infile == os.Stdin {
+		if policy == ExistingSkip {
+			return errors.New("cannot use --if-unsigned with standard input")
+		}
+		// can't rewind, so leave it to the format
+		return nil
+	}
+	signed, err := mod.IsSigned(infile)
+	if err != nil && policy == ExistingSkip {
+		return err
+	}
+	if _, err := infile.Seek(0, 0); err != nil {
+		return fmt.Errorf("rewinding input file: %w", err)
+	}
+	switch {
+	case !signed || err != nil:
+		return nil
+	case policy == ExistingSkip:
+		return archivesign.ErrAlreadySigned
+	case mod.Resign == signers.ResignAppend:
+		return errors.New("file is already signed; use --nest to add another signature or --replace to discard the existing ones")
+	default:
+		return errors.New("file is already signed; use --replace to discard the existing signature")
+	}
+}
@@ -0,0 +1,59 @@
+package shared
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mind-security/relic/v8/lib/archivesign"
+	"github.com/mind-security/relic/v8/signers"
+	"github.com/mind-security/relic/v8/signers/sigerrors"
+)
+
+func TestCheckExisting(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(path, []byte("signed"), 0644))
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	verify := func(f *os.File, opts signers.VerifyOpts) ([]*signers.Signature, error) {
+		var buf [16]byte
+		n, _ := f.Read(buf[:])
+		if string(buf[:n]) != "signed" {
+			return nil, sigerrors.NotSignedError{Type: "test"}
+		}
+		return []*signers.Signature{{}}, nil
+	}
+	single := &signers.Signer{Name: "single", Verify: verify, Resign: signers.ResignReplace}
+	multi := &signers.Signer{Name: "multi", Verify: verify, Resign: signers.ResignAppend}
+	detached := &signers.Signer{Name: "detached", Verify: verify}
+	check := func(mod *signers.Signer, policy ExistingPolicy, flags map[string]string) error {
+		err := CheckExisting(mod, f, &signers.FlagValues{Values: flags}, policy)
+		// always rewound
+		pos, serr := f.Seek(0, 1)
+		require.NoError(t, serr)
+		assert.Zero(t, pos)
+		return err
+	}
+	replace := map[string]string{"replace": "true"}
+	nest := map[string]string{"nest": "true"}
+
+	assert.ErrorContains(t, check(single, ExistingCheck, nil), "use --replace")
+	assert.NotErrorIs(t, check(single, ExistingCheck, nil), archivesign.ErrAlreadySigned)
+	assert.ErrorContains(t, check(multi, ExistingCheck, nil), "use --nest")
+	assert.ErrorIs(t, check(single, ExistingSkip, nil), archivesign.ErrAlreadySigned)
+	assert.NoError(t, check(single, ExistingAllow, nil))
+	assert.NoError(t, check(single, ExistingCheck, replace))
+	assert.NoError(t, check(multi, ExistingCheck, replace))
+	assert.NoError(t, check(multi, ExistingCheck, nest))
+	assert.NoError(t, check(detached, ExistingCheck, nil))
+	assert.ErrorContains(t, check(single, ExistingCheck, nest), "use --replace instead")
+	assert.ErrorContains(t, check(multi, ExistingCheck, map[string]string{"replace": "true", "nest": "true"}), "mutually exclusive")
+
+	require.NoError(t, os.WriteFile(path, []byte("plain"), 0644))
+	assert.NoError(t, check(single, ExistingCheck, nil))
+	assert.NoError(t, check(single, ExistingSkip, nil))
+}
@@ -34,7 +34,7 @@ var (
 	argVersion bool
 )
 
-var lateHooks []func()
+var lateHooks, exitHooks []func()
 
 var RootCmd = &cobra.Command{
 	Use:              "relic",
@@ -43,7 +43,7 @@ var RootCmd = &cobra.Command{
 }
 
 func init() {
-	RootCmd.PersistentFlags().StringVarP(&ArgConfig, "config", "c", "", "Configuration file")
+	RootCmd.PersistentFlags().StringVarP(&ArgConfig, "config", "c", "", "Configuration file or directory")
 	RootCmd.PersistentFlags().BoolVar(&argVersion, "version", false, "Show version and exit")
 	RootCmd.PersistentFlags().Uint32VarP(&ArgDebug, "debug", "d", 0, "Log additional diagnostic data. (0-9)")
 }
@@ -66,11 +66,27 @@ func AddLateHook(f func()) {
 	lateHooks = append(lateHooks, f)
 }
 
+// AddExitHook registers a function to run once when the command finishes,
+// including when it fails
+func AddExitHook(f func()) {
+	exitHooks = append(exitHooks, f)
+}
+
+func runExitHooks() {
+	hooks := exitHooks
+	exitHooks = nil
+	for _, f := range hooks {
+		f()
+	}
+}
+
 func Main() {
 	for _, f := range lateHooks {
 		f()
 	}
-	if err := RootCmd.Execute(); err != nil {
+	err := RootCmd.Execute()
+	runExitHooks()
+	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
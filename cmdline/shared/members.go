@@ -0,0 +1,166 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package shared
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mind-security/relic/v8/lib/archivesign"
+	"github.com/mind-security/relic/v8/lib/atomicfile"
+	"github.com/mind-security/relic/v8/lib/zipslicer"
+)
+
+var (
+	ArgMembers       bool
+	ArgOnMemberError string
+)
+
+func AddMembersFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&ArgMembers, "members", false, "Sign each member of a ZIP or tar archive instead of the archive itself")
+	cmd.Flags().StringVar(&ArgOnMemberError, "on-member-error", "skip-and-continue", "With --members, what to do when a member can't be signed: skip-and-continue, abort-all or fail-fast")
+}
+
+// SignMembers signs each file inside the ZIP or tar archive at inpath by
+// extracting it to a temporary directory and calling signFile on it in-place. A report
+// for each member is printed to stderr. With --reproducible or
+// --deterministic the archive is repacked so that its layout depends only on
+// the signed members.
+func SignMembers(cmd *cobra.Command, inpath, outpath string, signFile func(path string) error) error {
+	mode, err := archivesign.ParseMode(ArgOnMemberError)
+	if err != nil {
+		return err
+	}
+	repack, err := memberRepackOptions(cmd)
+	if err != nil {
+		return err
+	}
+	infile, err := os.Open(inpath)
+	if err != nil {
+		return err
+	}
+	defer infile.Close()
+	stat, err := infile.Stat()
+	if err != nil {
+		return err
+	}
+	isTar, err := isTarArchive(infile)
+	if err != nil {
+		return err
+	}
+	tempdir, err := os.MkdirTemp("", "relic-members-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempdir)
+	outfile, err := atomicfile.WriteAny(outpath)
+	if err != nil {
+		return err
+	}
+	defer outfile.Close()
+	var w io.Writer = outfile
+	signed := new(bytes.Buffer)
+	if repack.Reproducible {
+		w = signed
+	}
+	signMember := func(name string, r io.Reader) ([]byte, error) {
+		// keep the base name so the signature type can be detected by extension
+		memberPath := filepath.Join(tempdir, path.Base(name))
+		defer os.Remove(memberPath)
+		f, err := os.Create(memberPath)
+		if err != nil {
+			return nil, err
+		}
+		_, err = io.Copy(f, r)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		if err := signFile(memberPath); err != nil {
+			return nil, err
+		}
+		return os.ReadFile(memberPath)
+	}
+	var report *archivesign.Report
+	if isTar {
+		report, err = archivesign.SignTar(infile, w, mode, signMember)
+	} else {
+		report, err = archivesign.SignZip(infile, stat.Size(), w, mode, signMember)
+	}
+	if report != nil && report.Written && repack.Reproducible {
+		if rerr := repackMembers(signed.Bytes(), outfile, isTar, repack); rerr != nil {
+			return rerr
+		}
+	}
+	if report != nil {
+		report.Print(os.Stderr)
+		if report.Written {
+			if err := outfile.Commit(); err != nil {
+				return err
+			}
+		}
+	}
+	return err
+}
+
+// memberRepackOptions returns how to repack the archive after signing its
+// members, following the same options that make signing reproducible
+func memberRepackOptions(cmd *cobra.Command) (zipslicer.RepackOptions, error) {
+	reproducible, _ := cmd.Flags().GetBool("reproducible")
+	deterministic, _ := cmd.Flags().GetBool("deterministic")
+	if !reproducible && !deterministic {
+		return zipslicer.RepackOptions{}, nil
+	}
+	epoch, _ := cmd.Flags().GetString("source-date-epoch")
+	if epoch == "" {
+		epoch = os.Getenv("SOURCE_DATE_EPOCH")
+	}
+	modTime, err := zipslicer.ReproducibleTime(epoch)
+	if err != nil {
+		return zipslicer.RepackOptions{}, err
+	}
+	return zipslicer.RepackOptions{Reproducible: true, ModTime: modTime}, nil
+}
+
+// isTarArchive reports whether f holds a tar archive rather than a ZIP archive
+func isTarArchive(f *os.File) (bool, error) {
+	var magic [5]byte
+	if _, err := f.ReadAt(magic[:], 257); err == io.EOF {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return string(magic[:]) == "ustar", nil
+}
+
+// repackMembers rewrites the archive produced by SignZip or SignTar with
+// sorted entries and normalized timestamps and permissions
+func repackMembers(signed []byte, w io.Writer, isTar bool, opts zipslicer.RepackOptions) error {
+	if isTar {
+		return zipslicer.RepackTar(bytes.NewReader(signed), w, opts)
+	}
+	dir, err := zipslicer.Read(bytes.NewReader(signed), int64(len(signed)))
+	if err != nil {
+		return err
+	}
+	return dir.Repack(w, opts)
+}
@@ -0,0 +1,97 @@
+package shared
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignMembersReproducible(t *testing.T) {
+	dir := t.TempDir()
+	makeZip := func(name string, names []string, mtime time.Time) string {
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		for _, member := range names {
+			w, err := zw.CreateHeader(&zip.FileHeader{Name: member, Method: zip.Deflate, Modified: mtime})
+			require.NoError(t, err)
+			_, err = io.WriteString(w, "contents of "+member)
+			require.NoError(t, err)
+		}
+		require.NoError(t, zw.Close())
+		fp := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(fp, buf.Bytes(), 0644))
+		return fp
+	}
+	in1 := makeZip("in1.zip", []string{"a.ps1", "b.ps1"}, time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC))
+	in2 := makeZip("in2.zip", []string{"b.ps1", "a.ps1"}, time.Date(2023, 6, 7, 8, 9, 10, 0, time.UTC))
+	signFile := func(fp string) error {
+		f, err := os.OpenFile(fp, os.O_APPEND|os.O_WRONLY, 0)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.WriteString(f, "\nsigned")
+		return err
+	}
+	signMembers := func(inpath string, reproducible bool) []byte {
+		cmd := &cobra.Command{}
+		cmd.Flags().Bool("reproducible", reproducible, "")
+		cmd.Flags().Bool("deterministic", false, "")
+		cmd.Flags().String("source-date-epoch", "1700000000", "")
+		outpath := inpath + ".signed"
+		require.NoError(t, SignMembers(cmd, inpath, outpath, signFile))
+		blob, err := os.ReadFile(outpath)
+		require.NoError(t, err)
+		return blob
+	}
+
+	out1 := signMembers(in1, true)
+	assert.Equal(t, out1, signMembers(in2, true))
+	zr, err := zip.NewReader(bytes.NewReader(out1), int64(len(out1)))
+	require.NoError(t, err)
+	require.Len(t, zr.File, 2)
+	assert.Equal(t, "a.ps1", zr.File[0].Name)
+	assert.True(t, zr.File[0].Modified.Equal(time.Unix(1700000000, 0)))
+
+	// otherwise the original order and timestamps are kept
+	assert.NotEqual(t, signMembers(in1, false), signMembers(in2, false))
+
+	// tar archives are repacked the same way
+	makeTar := func(name string, names []string, mtime time.Time) string {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		for _, member := range names {
+			contents := "contents of " + member
+			require.NoError(t, tw.WriteHeader(&tar.Header{Name: member, Mode: 0600, Size: int64(len(contents)), ModTime: mtime, Uname: "builder"}))
+			_, err := io.WriteString(tw, contents)
+			require.NoError(t, err)
+		}
+		require.NoError(t, tw.Close())
+		fp := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(fp, buf.Bytes(), 0644))
+		return fp
+	}
+	tar1 := makeTar("in1.tar", []string{"a.ps1", "b.ps1"}, time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC))
+	tar2 := makeTar("in2.tar", []string{"b.ps1", "a.ps1"}, time.Date(2023, 6, 7, 8, 9, 10, 0, time.UTC))
+	out1 = signMembers(tar1, true)
+	assert.Equal(t, out1, signMembers(tar2, true))
+	tr := tar.NewReader(bytes.NewReader(out1))
+	hdr, err := tr.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "a.ps1", hdr.Name)
+	assert.True(t, hdr.ModTime.Equal(time.Unix(1700000000, 0)))
+	assert.Empty(t, hdr.Uname)
+	contents, err := io.ReadAll(tr)
+	require.NoError(t, err)
+	assert.Equal(t, "contents of a.ps1\nsigned", string(contents))
+	assert.NotEqual(t, signMembers(tar1, false), signMembers(tar2, false))
+}
@@ -55,7 +55,7 @@ func initConfig(client bool) error {
 	if ArgConfig == "" {
 		return errors.New("--config not specified")
 	}
-	cfg, err := config.ReadFile(ArgConfig)
+	cfg, err := config.ReadPath(ArgConfig)
 	if err != nil {
 		if os.IsNotExist(err) && usedDefault {
 			if client {
@@ -101,6 +101,7 @@ func OpenForPatching(inpath, outpath string) (*os.File, error) {
 func Fail(err error) error {
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "ERROR:", err)
+		runExitHooks()
 		os.Exit(70)
 	}
 	return err
@@ -0,0 +1,181 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package token
+
+import (
+	"bufio"
+	"crypto"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mind-security/relic/v8/cmdline/shared"
+	"github.com/mind-security/relic/v8/lib/archivesign"
+	"github.com/mind-security/relic/v8/token"
+)
+
+var (
+	argManifest      string
+	argOutputPattern string
+	argParallel      int
+)
+
+func addBatchFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&argManifest, "manifest", "", "Sign every file listed in this file, one path per line")
+	cmd.Flags().StringVar(&argOutputPattern, "output-pattern", "", "When signing several files, where to write each one. {dir}, {name} and {ext} are replaced with parts of the input path. (default: sign in place)")
+	cmd.Flags().IntVar(&argParallel, "parallel", 4, "When signing several files, how many to sign at once")
+}
+
+type batchResult struct {
+	path    string
+	output  string
+	skipped bool
+	err     error
+}
+
+// signBatch signs each file named on the command line or in --manifest using
+// a single token session. Failures are reported at the end and don't stop the
+// other files from being signed.
+func signBatch(cmd *cobra.Command, args []string) error {
+	if argFile != "" || argOutput != "" || shared.ArgMembers || argDigestOnly || len(argAssemble) != 0 {
+		return errors.New("--file, --output, --members and offline signing can't be used with --manifest or multiple files")
+	} else if argKeyName == "" {
+		return errors.New("--key is required")
+	} else if argOutputPattern != "" && shared.ArgDetachOutput != "" {
+		return errors.New("--output-pattern and --detach-output are mutually exclusive")
+	}
+	paths := args
+	if argManifest != "" {
+		listed, err := readManifest(argManifest)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, listed...)
+	}
+	if len(paths) == 0 {
+		return errors.New("no files to sign")
+	}
+	hash, err := shared.GetDigest()
+	if err != nil {
+		return err
+	}
+	tok, err := openTokenByKey(argKeyName)
+	if err != nil {
+		return err
+	}
+	results := signPaths(cmd, tok, hash, paths)
+	var failed, skipped int
+	for _, res := range results {
+		switch {
+		case res.err != nil:
+			failed++
+			fmt.Fprintf(os.Stderr, "%s: failed: %s\n", res.path, res.err)
+		case res.skipped:
+			skipped++
+			fmt.Fprintf(os.Stderr, "%s: already signed\n", res.path)
+		case res.output != res.path:
+			fmt.Fprintf(os.Stderr, "%s: signed, wrote %s\n", res.path, res.output)
+		default:
+			fmt.Fprintf(os.Stderr, "%s: signed\n", res.path)
+		}
+	}
+	summary := fmt.Sprintf("signed %d of %d files", len(results)-failed-skipped, len(results))
+	if skipped != 0 {
+		summary += fmt.Sprintf(", %d already signed", skipped)
+	}
+	if failed != 0 {
+		return fmt.Errorf("%s, %d failed", summary, failed)
+	}
+	fmt.Fprintln(os.Stderr, summary)
+	return nil
+}
+
+// signPaths signs up to --parallel files at a time and returns the result for
+// each in the same order as paths
+func signPaths(cmd *cobra.Command, tok token.Token, hash crypto.Hash, paths []string) []batchResult {
+	parallel := argParallel
+	if parallel < 1 {
+		parallel = 1
+	}
+	results := make([]batchResult, len(paths))
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i, inpath := range paths {
+		i, inpath := i, inpath // re-scope to loop
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			res := batchResult{path: inpath}
+			outpath := expandOutputPattern(argOutputPattern, inpath)
+			if outpath != inpath {
+				res.err = os.MkdirAll(filepath.Dir(outpath), 0755)
+			}
+			if res.err == nil {
+				res.output, res.err = signFile(cmd, tok, argKeyName, hash, inpath, outpath, argSigType, shared.IfUnsignedPolicy(argIfUnsigned))
+			}
+			if errors.Is(res.err, archivesign.ErrAlreadySigned) {
+				res.err = nil
+				res.skipped = true
+			}
+			results[i] = res
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// readManifest returns the paths listed in a manifest file. Blank lines and
+// lines starting with # are ignored.
+func readManifest(fp string) ([]string, error) {
+	f, err := os.Open(fp)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var paths []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		paths = append(paths, line)
+	}
+	return paths, scanner.Err()
+}
+
+// expandOutputPattern substitutes parts of inpath into pattern. An empty
+// pattern signs the file in place.
+func expandOutputPattern(pattern, inpath string) string {
+	if pattern == "" {
+		return inpath
+	}
+	base := filepath.Base(inpath)
+	ext := filepath.Ext(base)
+	return strings.NewReplacer(
+		"{dir}", filepath.Dir(inpath),
+		"{name}", strings.TrimSuffix(base, ext),
+		"{ext}", ext,
+	).Replace(pattern)
+}
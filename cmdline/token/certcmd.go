@@ -0,0 +1,195 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package token
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mind-security/relic/v8/cmdline/shared"
+	"github.com/mind-security/relic/v8/lib/atomicfile"
+	"github.com/mind-security/relic/v8/lib/certloader"
+	"github.com/mind-security/relic/v8/lib/x509tools"
+	"github.com/mind-security/relic/v8/signers/sigerrors"
+	"github.com/mind-security/relic/v8/token"
+)
+
+var RequestCertCmd = &cobra.Command{
+	Use:   "request-cert",
+	Short: "Generate a certificate signing request for an existing key",
+	Long: `Generate a PKCS#10 certificate signing request for a key that already
+exists in a token. The request is signed by the token.`,
+	RunE: requestCertCmd,
+}
+
+var ImportCertCmd = &cobra.Command{
+	Use:   "import-cert",
+	Short: "Import an issued certificate for an existing key",
+	Long: `Import a PEM certificate, and optionally its chain, for a key that
+already exists in a token. By default the certificate is written to the key's
+x509certificate file, or to --save in which case the configuration file is
+updated to point to it. With --into-token the certificates are stored as
+objects in the token instead.`,
+	RunE: importCertCmd,
+}
+
+var (
+	argCertOut   string
+	argCertSave  string
+	argIntoToken bool
+)
+
+func init() {
+	TokenCmd.AddCommand(RequestCertCmd)
+	addKeyFlags(RequestCertCmd)
+	x509tools.AddRequestFlags(RequestCertCmd)
+	RequestCertCmd.Flags().StringVarP(&argCertOut, "output", "o", "", "Write the request to this file (default: standard output)")
+
+	TokenCmd.AddCommand(ImportCertCmd)
+	addKeyFlags(ImportCertCmd)
+	ImportCertCmd.Flags().StringVarP(&argFile, "file", "f", "", "PEM file with the certificate and optional chain")
+	ImportCertCmd.Flags().StringVar(&argCertSave, "save", "", "Write the certificate here and point the key's x509certificate at it")
+	ImportCertCmd.Flags().BoolVar(&argIntoToken, "into-token", false, "Store the certificates in the token")
+}
+
+func requestCertCmd(cmd *cobra.Command, args []string) error {
+	if argKeyName == "" {
+		return errors.New("--key is required")
+	}
+	if x509tools.ArgCommonName == "" {
+		return errors.New("--commonName is required")
+	}
+	key, err := openKey(argKeyName)
+	if err != nil {
+		return shared.Fail(err)
+	}
+	csr, err := x509tools.MakeRequest(rand.Reader, key)
+	if err != nil {
+		return shared.Fail(err)
+	}
+	if argCertOut != "" {
+		err = atomicfile.WriteFile(argCertOut, []byte(csr))
+	} else {
+		_, err = os.Stdout.WriteString(csr)
+	}
+	if err != nil {
+		return shared.Fail(err)
+	}
+	return nil
+}
+
+func importCertCmd(cmd *cobra.Command, args []string) error {
+	if argKeyName == "" || argFile == "" {
+		return errors.New("--key and --file are required")
+	}
+	if argIntoToken && argCertSave != "" {
+		return errors.New("--into-token and --save are mutually exclusive")
+	}
+	blob, err := os.ReadFile(argFile)
+	if err != nil {
+		return shared.Fail(err)
+	}
+	certs, err := certloader.ParseX509Certificates(blob)
+	if err != nil {
+		return shared.Fail(err)
+	}
+	key, err := openKey(argKeyName)
+	if err != nil {
+		return shared.Fail(err)
+	}
+	// put the certificate that matches the key first, followed by the chain
+	var leaf *x509.Certificate
+	chain := make([]*x509.Certificate, 0, len(certs))
+	for _, cert := range certs {
+		if leaf == nil && x509tools.SameKey(key.Public(), cert.PublicKey) {
+			leaf = cert
+		} else {
+			chain = append(chain, cert)
+		}
+	}
+	if leaf == nil {
+		return shared.Fail(fmt.Errorf("no certificate in %s matches key %q", argFile, argKeyName))
+	}
+	if argIntoToken {
+		return importCertsToToken(key, leaf, chain)
+	}
+	keyConf := key.Config()
+	dest := argCertSave
+	if dest == "" {
+		dest = keyConf.X509Certificate
+	}
+	if dest == "" {
+		return errors.New("key has no x509certificate configured; use --save or --into-token")
+	}
+	var pemBlob []byte
+	for _, cert := range append([]*x509.Certificate{leaf}, chain...) {
+		pemBlob = append(pemBlob, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+	}
+	if err := atomicfile.WriteFile(dest, pemBlob); err != nil {
+		return shared.Fail(err)
+	}
+	fmt.Fprintln(os.Stderr, "Wrote", x509tools.FormatSubject(leaf), "to", dest)
+	if argCertSave != "" {
+		dest, err = filepath.Abs(dest)
+		if err != nil {
+			return shared.Fail(err)
+		}
+		if dest != keyConf.X509Certificate {
+			if err := shared.CurrentConfig.SetKeyCertificate(argKeyName, dest); err != nil {
+				return shared.Fail(fmt.Errorf("updating configuration: %w", err))
+			}
+			fmt.Fprintln(os.Stderr, "Updated x509certificate for key", argKeyName, "in", shared.CurrentConfig.Path())
+		}
+	}
+	return nil
+}
+
+func importCertsToToken(key token.Key, leaf *x509.Certificate, chain []*x509.Certificate) error {
+	tok, err := openTokenByKey(argKeyName)
+	if err != nil {
+		return shared.Fail(err)
+	}
+	label := key.Config().Label
+	var didSomething bool
+	for i, cert := range append([]*x509.Certificate{leaf}, chain...) {
+		name := x509tools.FormatSubject(cert)
+		if i == 0 {
+			err = key.ImportCertificate(cert)
+		} else {
+			err = tok.ImportCertificate(cert, label)
+		}
+		if err == sigerrors.ErrExist {
+			fmt.Fprintln(os.Stderr, "Certificate already exists:", name)
+		} else if err != nil {
+			return shared.Fail(fmt.Errorf("importing %s: %w", name, err))
+		} else {
+			fmt.Fprintln(os.Stderr, "Imported", name)
+			didSomething = true
+		}
+	}
+	if !didSomething {
+		return shared.Fail(errors.New("nothing imported"))
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package token
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"github.com/mind-security/relic/v8/cmdline/shared"
+	"github.com/mind-security/relic/v8/lib/atomicfile"
+	"github.com/mind-security/relic/v8/lib/x509tools"
+	"github.com/mind-security/relic/v8/signers/sigerrors"
+	"github.com/mind-security/relic/v8/token"
+)
+
+var GenerateCmd = &cobra.Command{
+	Use:   "generate",
+	Short: "Generate a new key pair in a token",
+	Long: `Generate a new key pair in a token, and write out its public key and
+optionally a certificate signing request or self-signed certificate.
+
+The key is named either by --key, referring to a key in the configuration
+file, or by --token and --label. The token's PIN and user settings from the
+configuration file are used to log in.`,
+	RunE: generateCmd,
+}
+
+var (
+	argGenID        string
+	argGenAlgorithm string
+	argGenBits      uint
+	argGenCurve     string
+	argPubKeyOut    string
+	argCsrOut       string
+	argSelfSignOut  string
+	argForce        bool
+)
+
+func init() {
+	TokenCmd.AddCommand(GenerateCmd)
+	addKeyFlags(GenerateCmd)
+	GenerateCmd.Flags().StringVarP(&argLabel, "label", "l", "", "Label to attach to generated key")
+	GenerateCmd.Flags().StringVar(&argGenID, "id", "", "ID (CKA_ID) to attach to generated key, in hex. Random if not specified.")
+	GenerateCmd.Flags().StringVarP(&argGenAlgorithm, "algorithm", "a", "rsa", "Key algorithm: rsa or ecdsa")
+	GenerateCmd.Flags().UintVar(&argGenBits, "bits", 3072, "RSA key size in bits")
+	GenerateCmd.Flags().StringVar(&argGenCurve, "curve", "P-256", "ECDSA curve: P-256, P-384, or P-521")
+	GenerateCmd.Flags().StringVar(&argPubKeyOut, "pubkey-out", "", "Write the public key to this file as PEM (default: standard output)")
+	GenerateCmd.Flags().StringVar(&argCsrOut, "csr-out", "", "Write a certificate signing request to this file")
+	GenerateCmd.Flags().StringVar(&argSelfSignOut, "self-sign-out", "", "Write a self-signed certificate to this file")
+	GenerateCmd.Flags().BoolVar(&argForce, "force", false, "Don't ask for confirmation before generating the key")
+	x509tools.AddCertFlags(GenerateCmd)
+}
+
+func generateCmd(cmd *cobra.Command, args []string) error {
+	keyType, bits, err := parseKeyType()
+	if err != nil {
+		return err
+	}
+	if (argCsrOut != "" || argSelfSignOut != "") && x509tools.ArgCommonName == "" {
+		return errors.New("--commonName is required with --csr-out or --self-sign-out")
+	}
+	keyConf, err := newKeyConfig()
+	if err != nil {
+		return err
+	}
+	if argGenID != "" {
+		keyConf.ID = argGenID
+	}
+	tok, err := openToken(keyConf.Token)
+	if err != nil {
+		return shared.Fail(err)
+	}
+	// refuse to make a second key that the config can't tell apart
+	if _, err := tok.GetKey(context.Background(), argKeyName); err == nil {
+		return shared.Fail(fmt.Errorf("a key with label %q already exists in token %q", keyConf.Label, keyConf.Token))
+	} else if _, ok := err.(sigerrors.KeyNotFoundError); !ok {
+		return shared.Fail(err)
+	}
+	if !argForce {
+		ok, err := confirm(fmt.Sprintf("Generate a new %s key with label %q in token %q? [y/N] ", describeKey(keyType, bits), keyConf.Label, keyConf.Token))
+		if err != nil {
+			return shared.Fail(err)
+		} else if !ok {
+			return shared.Fail(errors.New("aborted"))
+		}
+	}
+	key, err := tok.Generate(argKeyName, keyType, bits)
+	if err != nil {
+		return shared.Fail(err)
+	}
+	fmt.Fprintln(os.Stderr, "Generated key with CKA_ID", formatKeyID(key.GetID()))
+	// write outputs
+	der, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		return shared.Fail(err)
+	}
+	pubPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	if argPubKeyOut != "" {
+		err = atomicfile.WriteFile(argPubKeyOut, pubPEM)
+	} else {
+		_, err = os.Stdout.Write(pubPEM)
+	}
+	if err != nil {
+		return shared.Fail(err)
+	}
+	if argCsrOut != "" {
+		csr, err := x509tools.MakeRequest(rand.Reader, key)
+		if err == nil {
+			err = atomicfile.WriteFile(argCsrOut, []byte(csr))
+		}
+		if err != nil {
+			return shared.Fail(fmt.Errorf("writing CSR: %w", err))
+		}
+	}
+	if argSelfSignOut != "" {
+		cert, err := x509tools.MakeCertificate(rand.Reader, key)
+		if err == nil {
+			err = atomicfile.WriteFile(argSelfSignOut, []byte(cert))
+		}
+		if err != nil {
+			return shared.Fail(fmt.Errorf("writing certificate: %w", err))
+		}
+	}
+	return nil
+}
+
+func parseKeyType() (token.KeyType, uint, error) {
+	switch strings.ToLower(argGenAlgorithm) {
+	case "rsa":
+		if argGenBits < 2048 {
+			return 0, 0, errors.New("--bits must be at least 2048")
+		}
+		return token.KeyTypeRsa, argGenBits, nil
+	case "ecdsa", "ec":
+		name := strings.TrimPrefix(strings.ToUpper(argGenCurve), "P-")
+		name = strings.TrimPrefix(name, "P")
+		for _, def := range x509tools.DefinedCurves {
+			if name == fmt.Sprint(def.Bits) {
+				return token.KeyTypeEcdsa, def.Bits, nil
+			}
+		}
+		return 0, 0, fmt.Errorf("unsupported curve %q, expected one of: %s", argGenCurve, x509tools.SupportedCurves())
+	default:
+		return 0, 0, fmt.Errorf("unsupported algorithm %q, expected rsa or ecdsa", argGenAlgorithm)
+	}
+}
+
+func describeKey(keyType token.KeyType, bits uint) string {
+	if keyType == token.KeyTypeEcdsa {
+		return fmt.Sprintf("ECDSA P-%d", bits)
+	}
+	return fmt.Sprintf("RSA %d-bit", bits)
+}
+
+// confirm asks a yes/no question on the terminal. Unlike interactive
+// certificate signing, it doesn't assume yes when there is no terminal.
+func confirm(prompt string) (bool, error) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return false, errors.New("standard input is not a terminal; use --force to proceed without confirmation")
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false, err
+	}
+	answer := strings.ToLower(strings.TrimSpace(line))
+	return answer == "y" || answer == "yes", nil
+}
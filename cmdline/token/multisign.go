@@ -0,0 +1,167 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package token
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mind-security/relic/v8/cmdline/shared"
+	"github.com/mind-security/relic/v8/signers"
+	"github.com/mind-security/relic/v8/token"
+)
+
+var MultiSignCmd = &cobra.Command{
+	Use:   "multi-sign",
+	Short: "Sign one file with several keys",
+	Long: `Sign one file with each of several keys, writing a separate result for each key.
+
+Keys in different tokens are used at the same time. A failure with one key doesn't stop the others, and which keys succeeded is reported at the end.`,
+	RunE: multiSignCmd,
+}
+
+var argMultiKeys []string
+
+func init() {
+	shared.RootCmd.AddCommand(MultiSignCmd)
+	MultiSignCmd.Flags().StringArrayVarP(&argMultiKeys, "key", "k", nil, "Name of key section in config file to use. Repeat once per key.")
+	MultiSignCmd.Flags().StringVarP(&argFile, "file", "f", "", "Input file to sign")
+	MultiSignCmd.Flags().StringVarP(&argSigType, "sig-type", "T", "", "Specify signature type (default: auto-detect)")
+	MultiSignCmd.Flags().StringVar(&argOutputPattern, "output-pattern", "", "Where to write the result for each key. {key} is replaced with the key name, and {dir}, {name} and {ext} with parts of the input path. (default: beside the input, with the key name before the extension or, for detached signatures, before the signature suffix)")
+	shared.AddDigestFlag(MultiSignCmd)
+	shared.AddLateHook(func() {
+		signers.MergeFlags(MultiSignCmd)
+	})
+}
+
+type keyResult struct {
+	key    string
+	output string
+	err    error
+}
+
+func multiSignCmd(cmd *cobra.Command, args []string) error {
+	if argFile == "" || len(argMultiKeys) == 0 {
+		return errors.New("--file and --key are required")
+	} else if argFile == "-" {
+		return errors.New("multi-sign can't read from standard input")
+	}
+	hash, err := shared.GetDigest()
+	if err != nil {
+		return shared.Fail(err)
+	}
+	mod, err := signers.ByFile(argFile, argSigType)
+	if err != nil {
+		return shared.Fail(err)
+	}
+	flags, err := mod.FlagsFromCmdline(cmd.Flags())
+	if err != nil {
+		return shared.Fail(err)
+	}
+	var suffix string
+	if mod.DetachedSuffix != nil {
+		suffix = mod.DetachedSuffix(flags)
+	}
+	results := make([]keyResult, len(argMultiKeys))
+	outputs := make(map[string]string)
+	for i, keyName := range argMultiKeys {
+		outpath := multiOutputPath(argOutputPattern, argFile, keyName, suffix)
+		if prev, ok := outputs[outpath]; ok {
+			return shared.Fail(fmt.Errorf("keys %q and %q would both write to %s", prev, keyName, outpath))
+		}
+		outputs[outpath] = keyName
+		results[i] = keyResult{key: keyName, output: outpath}
+	}
+	// open each token up front so any PIN prompts happen one at a time, then
+	// group the keys so that each token only signs one thing at a time
+	var tokenNames []string
+	groups := make(map[string][]int)
+	tokens := make(map[string]token.Token)
+	for i, keyName := range argMultiKeys {
+		tok, err := openTokenByKey(keyName)
+		if err != nil {
+			results[i].err = err
+			continue
+		}
+		keyConf, err := shared.CurrentConfig.GetKey(keyName)
+		if err != nil {
+			results[i].err = err
+			continue
+		}
+		if groups[keyConf.Token] == nil {
+			tokenNames = append(tokenNames, keyConf.Token)
+		}
+		groups[keyConf.Token] = append(groups[keyConf.Token], i)
+		tokens[keyConf.Token] = tok
+	}
+	var wg sync.WaitGroup
+	for _, tokenName := range tokenNames {
+		tok, indexes := tokens[tokenName], groups[tokenName]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, i := range indexes {
+				results[i].err = signWithKey(cmd, tok, results[i].key, hash, results[i].output)
+			}
+		}()
+	}
+	wg.Wait()
+	var failed int
+	for _, res := range results {
+		if res.err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "%s: failed: %s\n", res.key, res.err)
+		} else {
+			fmt.Fprintf(os.Stderr, "%s: signed, wrote %s\n", res.key, res.output)
+		}
+	}
+	summary := fmt.Sprintf("signed %s with %d of %d keys", argFile, len(results)-failed, len(results))
+	if failed != 0 {
+		return shared.Fail(fmt.Errorf("%s, %d failed", summary, failed))
+	}
+	fmt.Fprintln(os.Stderr, summary)
+	return nil
+}
+
+func signWithKey(cmd *cobra.Command, tok token.Token, keyName string, hash crypto.Hash, outpath string) error {
+	if err := os.MkdirAll(filepath.Dir(outpath), 0755); err != nil {
+		return err
+	}
+	_, err := signFile(cmd, tok, keyName, hash, argFile, outpath, argSigType, shared.ExistingCheck)
+	return err
+}
+
+// multiOutputPath returns where to write the result of signing inpath with
+// keyName. Detached signatures are named like "file.tar.gz.vendor.sig", and
+// everything else like "file.vendor.exe".
+func multiOutputPath(pattern, inpath, keyName, suffix string) string {
+	if pattern == "" {
+		if suffix != "" {
+			return inpath + "." + keyName + suffix
+		}
+		pattern = "{dir}/{name}.{key}{ext}"
+	}
+	pattern = strings.ReplaceAll(pattern, "{key}", keyName)
+	return filepath.Clean(expandOutputPattern(pattern, inpath))
+}
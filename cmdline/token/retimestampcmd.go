@@ -0,0 +1,139 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package token
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mind-security/relic/v8/cmdline/shared"
+	"github.com/mind-security/relic/v8/internal/signinit"
+	"github.com/mind-security/relic/v8/lib/atomicfile"
+	"github.com/mind-security/relic/v8/lib/authenticode"
+	"github.com/mind-security/relic/v8/lib/comdoc"
+	"github.com/mind-security/relic/v8/lib/pkcs9"
+	"github.com/mind-security/relic/v8/lib/x509tools"
+	"github.com/mind-security/relic/v8/signers"
+)
+
+var RetimestampCmd = &cobra.Command{
+	Use:   "retimestamp",
+	Short: "Add a new timestamp to an already-signed file",
+	Long: `Add a new timestamp to an already-signed file without signing it again.
+
+The new timestamp covers the existing signature value and is added alongside
+any existing timestamps, so the original signing time is preserved. Use this to
+keep signatures verifiable after the original timestamp certificate expires.
+Supported for PE/COFF, MSI and PKCS#7 (CMS) signatures. The timestamp servers
+come from the "timestamp" section of the configuration file.`,
+	RunE: retimestampCmd,
+}
+
+func init() {
+	shared.RootCmd.AddCommand(RetimestampCmd)
+	RetimestampCmd.Flags().StringVarP(&argFile, "file", "f", "", "Signed file to add a timestamp to")
+	RetimestampCmd.Flags().StringVarP(&argOutput, "output", "o", "", "Output file")
+	RetimestampCmd.Flags().StringVarP(&argSigType, "sig-type", "T", "", "Specify signature type (default: auto-detect)")
+}
+
+func retimestampCmd(cmd *cobra.Command, args []string) error {
+	if argFile == "" {
+		return errors.New("--file is required")
+	}
+	if argOutput == "" {
+		argOutput = argFile
+	}
+	if err := shared.InitConfig(); err != nil {
+		return shared.Fail(err)
+	}
+	timestamper, err := signinit.GetTimestamper()
+	if err != nil {
+		return shared.Fail(err)
+	}
+	sigs, err := retimestampFile(context.Background(), timestamper, argFile, argOutput, argSigType)
+	if err != nil {
+		return shared.Fail(err)
+	}
+	for _, sig := range sigs {
+		fmt.Fprintf(os.Stderr, "%s: signature by `%s` now has %d timestamp(s)\n", argFile, x509tools.FormatSubject(sig.Certificate), len(sig.Timestamps))
+	}
+	return nil
+}
+
+func retimestampFile(ctx context.Context, timestamper pkcs9.Timestamper, inpath, outpath, sigType string) ([]*pkcs9.TimestampedSignature, error) {
+	mod, err := signers.ByFile(inpath, sigType)
+	if err != nil {
+		return nil, err
+	}
+	infile, err := shared.OpenForPatching(inpath, outpath)
+	if err != nil {
+		return nil, err
+	} else if infile == os.Stdin {
+		return nil, errors.New("retimestamp does not support reading from stdin")
+	}
+	defer infile.Close()
+	switch mod.Name {
+	case "pe-coff":
+		patch, sigs, err := authenticode.RetimestampPE(ctx, infile, timestamper)
+		if err != nil {
+			return nil, err
+		}
+		if err := patch.Apply(infile, outpath); err != nil {
+			return nil, err
+		}
+		f, err := os.OpenFile(outpath, os.O_RDWR, 0)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return sigs, authenticode.FixPEChecksum(f)
+	case "msi":
+		f, err := atomicfile.WriteInPlace(infile, outpath)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		cdf, err := comdoc.WriteFile(f.GetFile())
+		if err != nil {
+			return nil, err
+		}
+		sig, err := authenticode.RetimestampMSI(ctx, cdf, timestamper)
+		if err != nil {
+			return nil, err
+		}
+		if err := cdf.Close(); err != nil {
+			return nil, err
+		}
+		return []*pkcs9.TimestampedSignature{sig}, f.Commit()
+	case "pkcs7":
+		blob, err := os.ReadFile(inpath)
+		if err != nil {
+			return nil, err
+		}
+		sig, err := pkcs9.AddTimestamp(ctx, blob, timestamper, false)
+		if err != nil {
+			return nil, err
+		}
+		return []*pkcs9.TimestampedSignature{sig}, atomicfile.WriteFile(outpath, sig.Raw)
+	default:
+		return nil, fmt.Errorf("can't add timestamps to files of type: %s", mod.Name)
+	}
+}
@@ -0,0 +1,310 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package token
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mind-security/relic/v8/cmdline/shared"
+	"github.com/mind-security/relic/v8/internal/signinit"
+	"github.com/mind-security/relic/v8/lib/certloader"
+	"github.com/mind-security/relic/v8/lib/pgptools"
+	"github.com/mind-security/relic/v8/lib/x509tools"
+	"github.com/mind-security/relic/v8/signers"
+	"github.com/mind-security/relic/v8/signers/sigerrors"
+	"github.com/mind-security/relic/v8/token"
+)
+
+var RotateCmd = &cobra.Command{
+	Use:   "rotate dir...",
+	Short: "Re-sign a directory tree of signed files with a new key",
+	Long: `Re-sign every signed file found under the given directories with a new key.
+
+Each file's format is detected the same way as "relic sign". Files that aren't
+a supported format, aren't signed, or are already signed by the new key are
+skipped. With --old-key, the existing signature is verified and files signed by
+any other key are reported as failures and left alone. Detached PGP signatures
+are not rotated.
+
+Files are re-signed in place unless --output-dir is given, in which case each
+re-signed file is written to the same relative path under it.`,
+	RunE: rotateCmd,
+}
+
+var (
+	argOldKey    string
+	argOutputDir string
+)
+
+func init() {
+	shared.RootCmd.AddCommand(RotateCmd)
+	addKeyFlags(RotateCmd)
+	RotateCmd.Flags().StringVar(&argOldKey, "old-key", "", "Only re-sign files with a valid signature by this key")
+	RotateCmd.Flags().StringVar(&argOutputDir, "output-dir", "", "Write re-signed files under this directory instead of in place")
+	RotateCmd.Flags().IntVar(&argParallel, "parallel", 4, "How many files to sign at once")
+	shared.AddDigestFlag(RotateCmd)
+}
+
+type rotateResult struct {
+	path   string
+	output string
+	skip   string
+	err    error
+}
+
+// rotateKeys identifies the signatures made by the old and new keys
+type rotateKeys struct {
+	newName, oldName string
+	old, new         *certloader.Certificate
+	opts             signers.VerifyOpts
+}
+
+func rotateCmd(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		return errors.New("expected 1 or more directories")
+	} else if argKeyName == "" {
+		return errors.New("--key is required")
+	}
+	hash, err := shared.GetDigest()
+	if err != nil {
+		return shared.Fail(err)
+	}
+	tok, err := openTokenByKey(argKeyName)
+	if err != nil {
+		return shared.Fail(err)
+	}
+	var oldTok token.Token
+	if argOldKey != "" {
+		oldTok, err = openTokenByKey(argOldKey)
+		if err != nil {
+			return shared.Fail(err)
+		}
+	}
+	keys, err := loadRotateKeys(tok, argKeyName, oldTok, argOldKey)
+	if err != nil {
+		return shared.Fail(err)
+	}
+	paths, outpaths, err := rotateTargets(args, argOutputDir)
+	if err != nil {
+		return shared.Fail(err)
+	}
+	results := rotatePaths(cmd, tok, keys, hash, paths, outpaths)
+	var failed, skipped int
+	for _, res := range results {
+		switch {
+		case res.err != nil:
+			failed++
+			fmt.Fprintf(os.Stderr, "%s: failed: %s\n", res.path, res.err)
+		case res.skip != "":
+			skipped++
+			fmt.Fprintf(os.Stderr, "%s: skipped: %s\n", res.path, res.skip)
+		case res.output != res.path:
+			fmt.Fprintf(os.Stderr, "%s: re-signed, wrote %s\n", res.path, res.output)
+		default:
+			fmt.Fprintf(os.Stderr, "%s: re-signed\n", res.path)
+		}
+	}
+	summary := fmt.Sprintf("re-signed %d of %d files", len(results)-failed-skipped, len(results))
+	if skipped != 0 {
+		summary += fmt.Sprintf(", %d skipped", skipped)
+	}
+	if failed != 0 {
+		return shared.Fail(fmt.Errorf("%s, %d failed", summary, failed))
+	}
+	fmt.Fprintln(os.Stderr, summary)
+	return nil
+}
+
+// loadRotateKeys loads the certificates of the new key and, if oldName is
+// given, the old one. Their PGP certificates are trusted so that PGP
+// signatures by either can be identified.
+func loadRotateKeys(tok token.Token, newName string, oldTok token.Token, oldName string) (*rotateKeys, error) {
+	keys := &rotateKeys{
+		newName: newName,
+		oldName: oldName,
+		opts:    signers.VerifyOpts{NoChain: true, NoDigests: oldName == ""},
+	}
+	var err error
+	keys.new, _, err = signinit.InitKey(context.Background(), tok, newName)
+	if err != nil {
+		return nil, err
+	}
+	if oldName != "" {
+		keys.old, _, err = signinit.InitKey(context.Background(), oldTok, oldName)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, cert := range []*certloader.Certificate{keys.old, keys.new} {
+		if cert != nil && cert.PgpKey != nil {
+			keys.opts.TrustedPgp = append(keys.opts.TrustedPgp, cert.PgpKey)
+		}
+	}
+	return keys, nil
+}
+
+// rotateTargets lists the files under each root, along with where each
+// re-signed file is written: in place, or at the same path relative to its
+// root under outputDir
+func rotateTargets(roots []string, outputDir string) (paths, outpaths []string, err error) {
+	for _, root := range roots {
+		found, err := findFiles(root)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, path := range found {
+			outpath := path
+			if outputDir != "" {
+				rel, err := filepath.Rel(root, path)
+				if err != nil {
+					return nil, nil, err
+				}
+				outpath = filepath.Join(outputDir, rel)
+			}
+			paths = append(paths, path)
+			outpaths = append(outpaths, outpath)
+		}
+	}
+	return paths, outpaths, nil
+}
+
+// findFiles lists the regular files under root
+func findFiles(root string) ([]string, error) {
+	var paths []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		} else if d.Type().IsRegular() {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	return paths, err
+}
+
+// rotatePaths re-signs up to --parallel files at a time, writing each to the
+// corresponding entry of outpaths, and returns the result for each in the same
+// order as paths
+func rotatePaths(cmd *cobra.Command, tok token.Token, keys *rotateKeys, hash crypto.Hash, paths, outpaths []string) []rotateResult {
+	parallel := argParallel
+	if parallel < 1 {
+		parallel = 1
+	}
+	results := make([]rotateResult, len(paths))
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i, inpath := range paths {
+		i, inpath := i, inpath // re-scope to loop
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = rotateFile(cmd, tok, keys, hash, inpath, outpaths[i])
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// rotateFile re-signs one file with the new key unless it should be skipped
+func rotateFile(cmd *cobra.Command, tok token.Token, keys *rotateKeys, hash crypto.Hash, inpath, outpath string) rotateResult {
+	res := rotateResult{path: inpath}
+	mod, err := signers.ByFile(inpath, "")
+	if err != nil {
+		res.skip = "unsupported format"
+		return res
+	} else if mod.Sign == nil || (mod.Verify == nil && mod.VerifyStream == nil) {
+		res.skip = "can't re-sign " + mod.Name + " files"
+		return res
+	} else if mod.Name == "pgp" {
+		res.skip = "detached PGP signature"
+		return res
+	}
+	sigs, err := verifyExisting(mod, inpath, keys.opts)
+	var nokey pgptools.ErrNoKey
+	switch {
+	case errors.As(err, new(sigerrors.NotSignedError)):
+		res.skip = "not signed"
+		return res
+	case errors.As(err, &nokey):
+		// signed by a PGP key that is neither old nor new
+		sigs = nil
+	case err != nil:
+		res.err = err
+		return res
+	}
+	for _, sig := range sigs {
+		if signedBy(sig, keys.new) {
+			res.skip = "already signed by the new key"
+			return res
+		}
+	}
+	if keys.old != nil {
+		var found bool
+		for _, sig := range sigs {
+			found = found || signedBy(sig, keys.old)
+		}
+		if !found {
+			res.err = fmt.Errorf("not signed by key %q", keys.oldName)
+			return res
+		}
+	}
+	if outpath != inpath {
+		if err := os.MkdirAll(filepath.Dir(outpath), 0755); err != nil {
+			res.err = err
+			return res
+		}
+	}
+	res.output, res.err = signFile(cmd, tok, keys.newName, hash, inpath, outpath, mod.Name, shared.ExistingAllow)
+	return res
+}
+
+// verifyExisting returns the signatures already in a file
+func verifyExisting(mod *signers.Signer, inpath string, opts signers.VerifyOpts) ([]*signers.Signature, error) {
+	f, err := os.Open(inpath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	opts.FileName = inpath
+	if mod.VerifyStream != nil {
+		return mod.VerifyStream(f, opts)
+	}
+	return mod.Verify(f, opts)
+}
+
+// signedBy reports whether sig was made by the key behind cert
+func signedBy(sig *signers.Signature, cert *certloader.Certificate) bool {
+	switch {
+	case sig.X509Signature != nil:
+		return x509tools.SameKey(cert.PrivateKey, sig.X509Signature.Certificate.PublicKey)
+	case sig.SignerPgp != nil:
+		return cert.PgpKey != nil && bytes.Equal(cert.PgpKey.PrimaryKey.Fingerprint, sig.SignerPgp.PrimaryKey.Fingerprint)
+	}
+	return false
+}
@@ -0,0 +1,138 @@
+package token
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mind-security/relic/v8/cmdline/shared"
+	"github.com/mind-security/relic/v8/config"
+	"github.com/mind-security/relic/v8/lib/certloader"
+	"github.com/mind-security/relic/v8/signers"
+	_ "github.com/mind-security/relic/v8/signers/pecoff"
+	"github.com/mind-security/relic/v8/token/filetoken"
+)
+
+// writeTestKey creates a key with a self-signed certificate and returns its
+// configuration
+func writeTestKey(t *testing.T, dir, name string) *config.KeyConfig {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	kconf := &config.KeyConfig{
+		Token:           "file",
+		KeyFile:         filepath.Join(dir, name+".key"),
+		X509Certificate: filepath.Join(dir, name+".crt"),
+	}
+	require.NoError(t, os.WriteFile(kconf.KeyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600))
+	require.NoError(t, os.WriteFile(kconf.X509Certificate, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}), 0600))
+	return kconf
+}
+
+func TestRotateFile(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{
+		Tokens: map[string]*config.TokenConfig{"file": {Type: "file"}},
+		Keys:   map[string]*config.KeyConfig{},
+	}
+	for _, name := range []string{"old", "new", "other"} {
+		cfg.Keys[name] = writeTestKey(t, dir, name)
+	}
+	require.NoError(t, cfg.Normalize(""))
+	prev := shared.CurrentConfig
+	shared.CurrentConfig = cfg
+	t.Cleanup(func() { shared.CurrentConfig = prev })
+	tok, err := filetoken.Open(cfg, "file", nil)
+	require.NoError(t, err)
+	cmd := new(cobra.Command)
+
+	// lay out a tree of files signed by various keys
+	dll, err := os.ReadFile("../../functest/packages/ClassLibrary1.dll")
+	require.NoError(t, err)
+	src := filepath.Join(dir, "src")
+	require.NoError(t, os.MkdirAll(filepath.Join(src, "sub"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "data.bin"), []byte("not a package"), 0644))
+	for name, key := range map[string]string{
+		"unsigned.dll":    "",
+		"sub/old.dll":     "old",
+		"sub/new.dll":     "new",
+		"sub/another.dll": "other",
+	} {
+		path := filepath.Join(src, name)
+		require.NoError(t, os.WriteFile(path, dll, 0644))
+		if key != "" {
+			_, err := signFile(cmd, tok, key, crypto.SHA256, path, path, "", shared.ExistingAllow)
+			require.NoError(t, err)
+		}
+	}
+
+	out := filepath.Join(dir, "out")
+	paths, outpaths, err := rotateTargets([]string{src}, out)
+	require.NoError(t, err)
+	require.Len(t, paths, 5)
+	keys, err := loadRotateKeys(tok, "new", tok, "old")
+	require.NoError(t, err)
+	results := make(map[string]rotateResult)
+	for i, path := range paths {
+		rel, err := filepath.Rel(src, path)
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Join(out, rel), outpaths[i])
+		results[filepath.ToSlash(rel)] = rotateFile(cmd, tok, keys, crypto.SHA256, path, outpaths[i])
+	}
+	assert.Equal(t, "unsupported format", results["data.bin"].skip)
+	assert.Equal(t, "not signed", results["unsigned.dll"].skip)
+	assert.Equal(t, "already signed by the new key", results["sub/new.dll"].skip)
+	assert.EqualError(t, results["sub/another.dll"].err, `not signed by key "old"`)
+	old := results["sub/old.dll"]
+	require.NoError(t, old.err)
+	assert.Empty(t, old.skip)
+	assert.Equal(t, filepath.Join(out, "sub", "old.dll"), old.output)
+
+	// only the re-signed file was written, and the original is untouched
+	written, err := findFiles(out)
+	require.NoError(t, err)
+	assert.Equal(t, []string{old.output}, written)
+	assertSignedBy(t, keys, old.output, keys.new)
+	assertSignedBy(t, keys, old.path, keys.old)
+
+	// without --old-key, files signed by any other key are re-signed in place
+	keys, err = loadRotateKeys(tok, "new", nil, "")
+	require.NoError(t, err)
+	another := filepath.Join(src, "sub", "another.dll")
+	res := rotateFile(cmd, tok, keys, crypto.SHA256, another, another)
+	require.NoError(t, res.err)
+	assert.Equal(t, another, res.output)
+	assertSignedBy(t, keys, another, keys.new)
+}
+
+func assertSignedBy(t *testing.T, keys *rotateKeys, path string, cert *certloader.Certificate) {
+	t.Helper()
+	mod, err := signers.ByFile(path, "")
+	require.NoError(t, err)
+	sigs, err := verifyExisting(mod, path, keys.opts)
+	require.NoError(t, err)
+	require.Len(t, sigs, 1)
+	assert.True(t, signedBy(sigs[0], cert))
+}
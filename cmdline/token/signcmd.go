@@ -19,19 +19,24 @@ package token
 import (
 	"bytes"
 	"context"
+	"crypto"
 	"errors"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/mind-security/relic/v8/cmdline/shared"
 	"github.com/mind-security/relic/v8/internal/signinit"
+	"github.com/mind-security/relic/v8/lib/archivesign"
 	"github.com/mind-security/relic/v8/signers"
+	"github.com/mind-security/relic/v8/token"
+	"github.com/mind-security/relic/v8/token/offlinetoken"
 )
 
 var SignCmd = &cobra.Command{
-	Use:   "sign",
+	Use:   "sign [file...]",
 	Short: "Sign a package using a token",
 	RunE:  signCmd,
 }
@@ -40,6 +45,9 @@ var (
 	argIfUnsigned bool
 	argSigType    string
 	argOutput     string
+	argDigestOnly bool
+	argAssemble   []string
+	argSignTime   string
 )
 
 func init() {
@@ -49,98 +57,192 @@ func init() {
 	SignCmd.Flags().StringVarP(&argOutput, "output", "o", "", "Output file")
 	SignCmd.Flags().StringVarP(&argSigType, "sig-type", "T", "", "Specify signature type (default: auto-detect)")
 	SignCmd.Flags().BoolVar(&argIfUnsigned, "if-unsigned", false, "Skip signing if the file already has a signature")
+	SignCmd.Flags().BoolVar(&argDigestOnly, "digest-only", false, "Print the digest(s) that would be signed without using the key or writing any output")
+	SignCmd.Flags().StringArrayVar(&argAssemble, "assemble", nil, "Complete the signature using a raw signature made elsewhere over a digest from --digest-only. Repeat once per digest, in order.")
+	SignCmd.Flags().StringVar(&argSignTime, "signing-time", "", "Signing time to record: \"now\" (default), \"omit\" to rely on the timestamp instead, or a fixed time in RFC 3339 format. Required with --assemble, and must match the time printed by --digest-only.")
 	shared.AddDigestFlag(SignCmd)
+	shared.AddMembersFlags(SignCmd)
+	shared.AddDetachFlag(SignCmd)
+	addBatchFlags(SignCmd)
 	shared.AddLateHook(func() {
 		signers.MergeFlags(SignCmd)
 	})
+	// flush audit records once, after all files are signed
+	shared.AddExitHook(signinit.CloseAudit)
 }
 
 func signCmd(cmd *cobra.Command, args []string) error {
+	if argManifest != "" || len(args) != 0 {
+		return shared.Fail(signBatch(cmd, args))
+	}
 	if argFile == "" || argKeyName == "" {
 		return errors.New("--file and --key are required")
 	}
+	if shared.ArgDetachOutput != "" && (argOutput != "" || shared.ArgMembers) {
+		return errors.New("--detach-output can't be used with --output or --members")
+	}
 	if argOutput == "" {
 		argOutput = argFile
 	}
-	mod, err := signers.ByFile(argFile, argSigType)
+	hash, err := shared.GetDigest()
 	if err != nil {
 		return shared.Fail(err)
 	}
-	if mod.Sign == nil {
-		return shared.Fail(fmt.Errorf("can't sign files of type: %s", mod.Name))
+	if argDigestOnly || len(argAssemble) != 0 {
+		return shared.Fail(signOffline(cmd, hash))
 	}
-	flags, err := mod.FlagsFromCmdline(cmd.Flags())
+	tok, err := openTokenByKey(argKeyName)
 	if err != nil {
 		return shared.Fail(err)
 	}
-	hash, err := shared.GetDigest()
-	if err != nil {
+	if shared.ArgMembers {
+		err := shared.SignMembers(cmd, argFile, argOutput, func(path string) error {
+			_, err := signFile(cmd, tok, argKeyName, hash, path, path, "", shared.ExistingSkip)
+			return err
+		})
 		return shared.Fail(err)
 	}
-	token, err := openTokenByKey(argKeyName)
-	if err != nil {
+	outpath, err := signFile(cmd, tok, argKeyName, hash, argFile, argOutput, argSigType, shared.IfUnsignedPolicy(argIfUnsigned))
+	if errors.Is(err, archivesign.ErrAlreadySigned) {
+		fmt.Fprintf(os.Stderr, "skipping already-signed file: %s\n", argFile)
+		return nil
+	} else if err != nil {
 		return shared.Fail(err)
 	}
-	cert, opts, err := signinit.Init(context.Background(), mod, token, argKeyName, hash, flags)
+	shared.ReportSigned(argFile, outpath)
+	return nil
+}
+
+// signFile signs a single file with the named key and returns the path the
+// result was written to. existing says what to do if the file already has a
+// signature; with shared.ExistingSkip archivesign.ErrAlreadySigned is returned.
+func signFile(cmd *cobra.Command, tok token.Token, keyName string, hash crypto.Hash, inpath, outpath, sigType string, existing shared.ExistingPolicy) (string, error) {
+	mod, err := signers.ByFile(inpath, sigType)
+	if err != nil {
+		return "", err
+	}
+	if mod.Sign == nil {
+		return "", fmt.Errorf("can't sign files of type: %s", mod.Name)
+	}
+	flags, err := mod.FlagsFromCmdline(cmd.Flags())
 	if err != nil {
-		return shared.Fail(err)
+		return "", err
 	}
-	opts.Path = argFile
-	infile, err := shared.OpenForPatching(argFile, argOutput)
+	if dest, err := shared.DetachedOutputPath(mod, flags, inpath); err != nil {
+		return "", err
+	} else if dest != "" {
+		outpath = dest
+	}
+	now := time.Now()
+	if offline != nil {
+		now = offlineTime
+	}
+	cert, opts, err := signinit.InitAt(context.Background(), mod, tok, keyName, hash, flags, now)
 	if err != nil {
-		return shared.Fail(err)
+		return "", err
+	}
+
+	opts.Path = inpath
+	infile, err := shared.OpenForPatching(inpath, outpath)
+	if err != nil {
+		return "", err
 	} else if infile == os.Stdin {
 		if !mod.AllowStdin {
-			return shared.Fail(errors.New("this signature type does not support reading from stdin"))
+			return "", errors.New("this signature type does not support reading from stdin")
 		}
 	} else {
 		defer infile.Close()
 	}
-	if argIfUnsigned {
-		if infile == os.Stdin {
-			return shared.Fail(errors.New("cannot use --if-unsigned with standard input"))
-		}
-		if signed, err := mod.IsSigned(infile); err != nil {
-			return shared.Fail(err)
-		} else if signed {
-			fmt.Fprintf(os.Stderr, "skipping already-signed file: %s\n", argFile)
-			return nil
-		}
-		if _, err := infile.Seek(0, 0); err != nil {
-			return shared.Fail(fmt.Errorf("rewinding input file: %w", err))
-		}
+	if err := shared.CheckExisting(mod, infile, flags, existing); err != nil {
+		return "", err
 	}
 	// transform the input, sign the stream, and apply the result
 	transform, err := mod.GetTransform(infile, *opts)
 	if err != nil {
-		return shared.Fail(err)
+		return "", err
 	}
 	stream, err := transform.GetReader()
 	if err != nil {
-		return shared.Fail(err)
+		return "", err
 	}
 	blob, err := mod.Sign(stream, cert, *opts)
 	if err != nil {
-		return shared.Fail(err)
+		return "", err
+	}
+	if offline != nil && len(offline.Digests) != len(offline.Signatures) {
+		return "", fmt.Errorf("%d signature(s) were given but only %d were needed", len(offline.Signatures), len(offline.Digests))
+	}
+	if err := signinit.SubmitTransparency(context.Background(), cert, opts); err != nil {
+		return "", err
 	}
 	mimeType := opts.Audit.GetMimeType()
-	if err := transform.Apply(argOutput, mimeType, bytes.NewReader(blob)); err != nil {
-		return shared.Fail(err)
+	if err := transform.Apply(outpath, mimeType, bytes.NewReader(blob)); err != nil {
+		return "", err
 	}
 	// if needed, do a final fixup step
 	if mod.Fixup != nil {
-		f, err := os.OpenFile(argOutput, os.O_RDWR, 0)
+		f, err := os.OpenFile(outpath, os.O_RDWR, 0)
 		if err != nil {
-			return shared.Fail(err)
+			return "", err
 		}
 		defer f.Close()
 		if err := mod.Fixup(f); err != nil {
-			return shared.Fail(err)
+			return "", err
 		}
 	}
-	if err := signinit.PublishAudit(opts.Audit); err != nil {
+	return outpath, signinit.PublishAudit(opts.Audit)
+}
+
+var (
+	offline     *offlinetoken.Token
+	offlineTime time.Time
+)
+
+// signOffline runs the sign command without access to the private key. With
+// --digest-only it prints the digest that would be signed, and with
+// --assemble it completes the signature using ones made elsewhere. Formats
+// that make several signatures print the next digest each time until enough
+// signatures have been given.
+func signOffline(cmd *cobra.Command, hash crypto.Hash) error {
+	if argDigestOnly && len(argAssemble) != 0 {
+		return errors.New("--digest-only and --assemble are mutually exclusive")
+	} else if shared.ArgMembers || argIfUnsigned {
+		return errors.New("--members and --if-unsigned can't be used with offline signing")
+	}
+	if err := shared.InitConfig(); err != nil {
+		return err
+	}
+	switch {
+	case argSignTime != "":
+		t, err := time.Parse(time.RFC3339, argSignTime)
+		if err != nil {
+			return fmt.Errorf("--signing-time: %w", err)
+		}
+		offlineTime = t
+	case argDigestOnly:
+		offlineTime = time.Now().Truncate(time.Second)
+	default:
+		return errors.New("--assemble requires --signing-time")
+	}
+	var sigs [][]byte
+	for _, path := range argAssemble {
+		blob, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		sigs = append(sigs, blob)
+	}
+	offline = offlinetoken.New(shared.CurrentConfig, sigs)
+	outpath, err := signFile(cmd, offline, argKeyName, hash, argFile, argOutput, argSigType, shared.ExistingCheck)
+	if pending := offline.Pending(); pending != nil {
+		fmt.Println(pending)
+		fmt.Fprintf(os.Stderr, "To assemble, sign the digest and run again with --signing-time %s and --assemble SIGFILE for this and any previous signatures, in order\n", offlineTime.UTC().Format(time.RFC3339))
+		return nil
+	} else if err != nil {
 		return err
+	} else if argDigestOnly {
+		return errors.New("nothing was signed")
 	}
-	fmt.Fprintln(os.Stderr, "Signed", argFile)
+	shared.ReportSigned(argFile, outpath)
 	return nil
 }
@@ -26,8 +26,10 @@ import (
 var SignPgpCmd = &cobra.Command{
 	Use:   "sign-pgp",
 	Short: "Create PGP signatures",
-	Long:  "This command is vaguely compatible with the gpg command-line and accepts (and mostly, ignores) many of gpg's options. It can thus be used as a drop-in replacement for tools that use gpg to make signatures.",
-	RunE:  signPgpCmd,
+	Long: `This command is vaguely compatible with the gpg command-line and accepts (and mostly, ignores) many of gpg's options. It can thus be used as a drop-in replacement for tools that use gpg to make signatures.
+
+To sign git commits and tags, set gpg.program to a script that runs this command and user.signingkey to the key name. git still needs gpg itself to verify them, e.g. "git -c gpg.program=gpg verify-commit".`,
+	RunE: signPgpCmd,
 }
 
 func init() {
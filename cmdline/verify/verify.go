@@ -17,18 +17,24 @@
 package verify
 
 import (
+	"context"
 	"crypto/x509"
 	"encoding/pem"
 	"errors"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/spf13/cobra"
 
+	"github.com/mind-security/relic/v8/cmdline/remotecmd"
 	"github.com/mind-security/relic/v8/cmdline/shared"
+	"github.com/mind-security/relic/v8/config"
 	"github.com/mind-security/relic/v8/lib/certloader"
 	"github.com/mind-security/relic/v8/lib/magic"
 	"github.com/mind-security/relic/v8/lib/pgptools"
+	"github.com/mind-security/relic/v8/lib/pkcs7"
+	"github.com/mind-security/relic/v8/lib/revocation"
 	"github.com/mind-security/relic/v8/lib/x509tools"
 	"github.com/mind-security/relic/v8/signers"
 )
@@ -39,23 +45,65 @@ var VerifyCmd = &cobra.Command{
 	RunE:  verifyCmd,
 }
 
+var RemoteVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Verify a signed package or executable using the signing server's trust roots",
+	Long:  "Verify a signed package or executable using the trusted certificates that the signing server offers, as \"relic verify --trust-server\" does.",
+	RunE:  remoteVerifyCmd,
+}
+
 var (
 	argNoIntegrityCheck bool
 	argNoChain          bool
 	argAlsoSystem       bool
 	argShowCerts        bool
+	argShowChain        bool
 	argContent          string
+	argTufRoot          string
 	argTrustedCerts     []string
+	argUnknownSigned    string
+	argUnknownUnsigned  string
+	argCheckRevocation  bool
+	argRevocationCache  string
+	argOffline          bool
+	argRevocationSoft   bool
+	argIntermediates    []string
+	argDeprecatedAlgs   string
+	argTrustServer      bool
+	argTrustCacheTTL    time.Duration
 )
 
+var revocationChecker *revocation.Checker
+
 func init() {
 	shared.RootCmd.AddCommand(VerifyCmd)
-	VerifyCmd.Flags().BoolVar(&argNoIntegrityCheck, "no-integrity-check", false, "Bypass the integrity check of the file contents and only inspect the signature itself")
-	VerifyCmd.Flags().BoolVar(&argNoChain, "no-trust-chain", false, "Do not test whether the signing certificate is trusted")
-	VerifyCmd.Flags().BoolVar(&argAlsoSystem, "system-store", false, "When --cert is used, append rather than replace the system trust store")
-	VerifyCmd.Flags().BoolVar(&argShowCerts, "show-certs", false, "Dump certificate chain from signature")
-	VerifyCmd.Flags().StringVar(&argContent, "content", "", "Specify file containing contents for detached signatures")
-	VerifyCmd.Flags().StringArrayVar(&argTrustedCerts, "cert", nil, "Add a trusted root certificate (PEM, DER, PKCS#7, or PGP)")
+	remotecmd.RemoteCmd.AddCommand(RemoteVerifyCmd)
+	VerifyCmd.Flags().BoolVar(&argTrustServer, "trust-server", false, "Also trust the certificates offered by the signing server in the remote configuration")
+	for _, cmd := range []*cobra.Command{VerifyCmd, RemoteVerifyCmd} {
+		flags := cmd.Flags()
+		flags.BoolVar(&argNoIntegrityCheck, "no-integrity-check", false, "Bypass the integrity check of the file contents and only inspect the signature itself")
+		flags.BoolVar(&argNoChain, "no-trust-chain", false, "Do not test whether the signing certificate is trusted")
+		flags.BoolVar(&argAlsoSystem, "system-store", false, "When --cert is used, append rather than replace the system trust store")
+		flags.BoolVar(&argShowCerts, "show-certs", false, "Dump certificate chain from signature")
+		flags.BoolVar(&argShowChain, "show-chain", false, "Print the subject of each certificate in the verified chain")
+		flags.StringVar(&argContent, "content", "", "Specify file containing contents for detached signatures")
+		flags.StringVar(&argTufRoot, "tuf-root", "", "Verify TUF metadata against the keys in this trusted root metadata")
+		flags.StringArrayVar(&argTrustedCerts, "cert", nil, "Add a trusted root certificate (PEM, DER, PKCS#7, or PGP)")
+		flags.StringArrayVar(&argIntermediates, "intermediates", nil, "Add untrusted intermediate certificates for building the chain, e.g. from \"relic remote sign --chain-out\"")
+		flags.StringVar(&argUnknownSigned, "unknown-signed-attrs", "", "How to treat unknown signed (critical) PKCS#7 attributes: strict or lenient (default from verify.unknownsignedattrs in the config, otherwise strict)")
+		flags.StringVar(&argUnknownUnsigned, "unknown-unsigned-attrs", "", "How to treat unknown unsigned PKCS#7 attributes: strict or lenient (default from verify.unknownunsignedattrs in the config, otherwise lenient)")
+		flags.StringVar(&argDeprecatedAlgs, "deprecated-algorithms", "lenient", "How to treat signatures using deprecated digests such as SHA-1: strict or lenient (accept with a warning)")
+		flags.BoolVar(&argCheckRevocation, "check-revocation", false, "Check the signing certificate chain against OCSP and CRLs")
+		flags.StringVar(&argRevocationCache, "revocation-cache", "", "Directory to persist OCSP responses and CRLs in until their next update")
+		flags.BoolVar(&argOffline, "offline", false, "Only use cached OCSP responses and CRLs. Implies --check-revocation")
+		flags.BoolVar(&argRevocationSoft, "revocation-soft-fail", false, "Warn instead of failing when revocation status can't be determined, e.g. due to a network error. Revoked certificates always fail")
+		flags.DurationVar(&argTrustCacheTTL, "trust-cache-ttl", time.Hour, "How long to reuse the signing server's certificates before fetching them again")
+	}
+}
+
+func remoteVerifyCmd(cmd *cobra.Command, args []string) error {
+	argTrustServer = true
+	return verifyCmd(cmd, args)
 }
 
 func verifyCmd(cmd *cobra.Command, args []string) error {
@@ -66,6 +114,12 @@ func verifyCmd(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
+	if argCheckRevocation || argOffline || argRevocationSoft {
+		revocationChecker = &revocation.Checker{
+			CacheDir: argRevocationCache,
+			Offline:  argOffline,
+		}
+	}
 	rc := 0
 	for _, path := range args {
 		if err := verifyOne(path, opts); err != nil {
@@ -107,18 +161,22 @@ func verifyOne(path string, opts signers.VerifyOpts) error {
 		}
 		sigs, err = mod.VerifyStream(r, opts)
 	} else {
-		if opts.Compression != magic.CompressedNone {
+		// a type recognized inside the compression, like a Helm chart, is
+		// handled by its signer
+		if opts.Compression != magic.CompressedNone && fileType == magic.FileTypeUnknown {
 			return errors.New("cannot verify compressed file")
 		}
 		sigs, err = mod.Verify(f, opts)
 	}
 	if err != nil {
-		if _, ok := err.(pgptools.ErrNoKey); ok {
+		var nokey pgptools.ErrNoKey
+		if errors.As(err, &nokey) {
 			return fmt.Errorf("%w; use --cert to specify known keys", err)
 		}
 		return err
 	}
 	sawCerts := make(map[string]bool)
+	var rejected []error
 	for _, sig := range sigs {
 		var si, pkg, ts string
 		if sig.SigInfo != "" {
@@ -133,15 +191,70 @@ func verifyOne(path string, opts signers.VerifyOpts) error {
 				showCert(cert.Raw, sawCerts)
 			}
 		}
+		warnings, err := opts.AlgorithmPolicy.Check(sig)
+		if err != nil {
+			err = fmt.Errorf("%w; use --deprecated-algorithms=lenient to accept it", err)
+			if len(sigs) == 1 {
+				return err
+			}
+			// with several signatures, a verifier applying this policy uses
+			// the others
+			fmt.Printf("%s: REJECTED -%s %s%s (%s)\n", path, si, pkg, sig.SignerName(), err)
+			rejected = append(rejected, err)
+			continue
+		}
+		for _, warning := range warnings {
+			fmt.Fprintf(os.Stderr, "%s: WARNING: %s\n", path, warning)
+		}
+		if sig.X509Signature != nil && sig.X509Signature.SignerInfo != nil {
+			unknown, err := opts.AttributePolicy.Check(sig.X509Signature.SignerInfo)
+			if err != nil {
+				return err
+			}
+			for _, attr := range unknown {
+				fmt.Fprintf(os.Stderr, "%s: WARNING: ignoring unknown %s\n", path, attr)
+			}
+		}
+		var chain []*x509.Certificate
+		var revoked []revocation.Result
 		if sig.X509Signature != nil && !opts.NoChain {
-			if err := sig.X509Signature.VerifyChain(opts.TrustedPool, nil, x509.ExtKeyUsageAny); err != nil {
+			if chain, err = sig.X509Signature.BuildChain(opts.TrustedPool, opts.Intermediates, x509.ExtKeyUsageAny); err != nil {
 				if e := new(x509.UnknownAuthorityError); errors.As(err, e) {
 					fmt.Printf("While validating certificate:\n Subject: %s\n Issuer:  %s\n Serial:  %X\n", x509tools.FormatSubject(e.Cert), x509tools.FormatIssuer(e.Cert), e.Cert.SerialNumber)
 				}
 				return err
 			}
+			if revocationChecker != nil {
+				stapled := &revocation.Stapled{
+					OCSPResponses: sig.X509Signature.OCSPResponses,
+					CRLs:          sig.X509Signature.CRLs,
+				}
+				revoked = revocationChecker.ChainStatus(context.Background(), chain, stapled)
+				for _, result := range revoked {
+					if err := result.Error(); err != nil {
+						if result.Status == revocation.StatusUnknown && argRevocationSoft {
+							fmt.Fprintf(os.Stderr, "%s: WARNING: %s\n", path, err)
+							continue
+						}
+						printRevocation(path, revoked)
+						return err
+					}
+				}
+			}
 		}
-		if sig.X509Signature != nil && sig.X509Signature.CounterSignature != nil {
+		if sig.X509Signature != nil && len(sig.X509Signature.Timestamps) > 1 {
+			// re-timestamped, list each one and when its TSA certificate is valid
+			fmt.Printf("%s: OK -%s %s%s\n", path, si, pkg, sig.SignerName())
+			for i, cs := range sig.X509Signature.Timestamps {
+				status := "OK"
+				if !opts.NoChain {
+					if err := cs.VerifyChain(opts.TrustedPool, opts.Intermediates); err != nil {
+						status = "INVALID (" + err.Error() + ")"
+					}
+				}
+				fmt.Printf("%s(timestamp %d): %s - `%s` [%s] valid %s to %s\n", path, i, status, x509tools.FormatSubject(cs.Certificate), cs.SigningTime, cs.Certificate.NotBefore, cs.Certificate.NotAfter)
+			}
+		} else if sig.X509Signature != nil && sig.X509Signature.CounterSignature != nil {
 			fmt.Printf("%s: OK -%s %s%s\n", path, si, pkg, sig.SignerName())
 			fmt.Printf("%s(timestamp): OK - `%s` [%s]\n", path, x509tools.FormatSubject(sig.X509Signature.CounterSignature.Certificate), sig.X509Signature.CounterSignature.SigningTime)
 		} else {
@@ -150,22 +263,111 @@ func verifyOne(path string, opts signers.VerifyOpts) error {
 			}
 			fmt.Printf("%s: OK -%s %s%s%s\n", path, si, pkg, sig.SignerName(), ts)
 		}
+		if argShowChain {
+			for i, cert := range chain {
+				fmt.Printf("%s(chain %d): `%s`\n", path, i, x509tools.FormatSubject(cert))
+			}
+		}
+		printRevocation(path, revoked)
+	}
+	if len(rejected) != 0 && len(rejected) == len(sigs) {
+		return rejected[0]
 	}
 	return nil
 }
 
+func printRevocation(path string, results []revocation.Result) {
+	for i, result := range results {
+		var detail string
+		switch {
+		case result.Status == revocation.StatusRevoked:
+			detail = fmt.Sprintf(" at %s", result.RevokedAt)
+		case result.Err != nil:
+			detail = fmt.Sprintf(" (%s)", result.Err)
+		}
+		if result.Source != "" {
+			detail += " via " + result.Source
+			if result.Cached {
+				detail += " (cached)"
+			}
+		}
+		fmt.Printf("%s(revocation %d): %s - `%s`%s\n", path, i, result.Status, x509tools.FormatSubject(result.Cert), detail)
+	}
+}
+
+// verifyConfig returns the verify section of the configuration file.
+// Verifying doesn't need a configuration file, so it is only read if --config
+// was given or the default one exists.
+func verifyConfig() (*config.VerifyConfig, error) {
+	if shared.ArgConfig == "" {
+		if _, err := os.Stat(config.DefaultConfig()); err != nil {
+			return new(config.VerifyConfig), nil
+		}
+	}
+	if err := shared.InitClientConfig(); err != nil {
+		return nil, err
+	}
+	if vc := shared.CurrentConfig.Verify; vc != nil {
+		return vc, nil
+	}
+	return new(config.VerifyConfig), nil
+}
+
 func loadCerts() (signers.VerifyOpts, error) {
 	opts := signers.VerifyOpts{
 		NoChain:   argNoChain,
 		NoDigests: argNoIntegrityCheck,
 		Content:   argContent,
+		TufRoot:   argTufRoot,
 	}
-	trusted, err := certloader.LoadAnyCerts(argTrustedCerts)
+	vc, err := verifyConfig()
+	if err != nil {
+		return opts, err
+	}
+	unknownSigned, unknownUnsigned := argUnknownSigned, argUnknownUnsigned
+	if unknownSigned == "" {
+		unknownSigned = vc.UnknownSignedAttrs
+	}
+	if unknownUnsigned == "" {
+		unknownUnsigned = vc.UnknownUnsignedAttrs
+	}
+	opts.AttributePolicy.Signed, err = pkcs7.ParseAttributeAction(unknownSigned)
+	if err != nil {
+		return opts, err
+	}
+	opts.AttributePolicy.Unsigned, err = pkcs7.ParseAttributeAction(unknownUnsigned)
+	if err != nil {
+		return opts, err
+	}
+	opts.AlgorithmPolicy, err = signers.ParseAlgorithmPolicy(argDeprecatedAlgs)
+	if err != nil {
+		return opts, err
+	}
+	trustedPaths := argTrustedCerts
+	if argTrustServer {
+		for _, certType := range []string{"x509", "pgp"} {
+			path, err := remotecmd.TrustRootsFile(certType, argTrustCacheTTL)
+			if err != nil {
+				return opts, fmt.Errorf("fetching trusted certificates from server: %w", err)
+			} else if path != "" {
+				trustedPaths = append(trustedPaths, path)
+			}
+		}
+	}
+	trusted, err := certloader.LoadAnyCerts(trustedPaths)
 	if err != nil {
 		return opts, err
 	}
 	opts.TrustedX509 = trusted.X509Certs
+	if len(argIntermediates) != 0 {
+		extra, err := certloader.LoadAnyCerts(argIntermediates)
+		if err != nil {
+			return opts, err
+		}
+		opts.Intermediates = extra.X509Certs
+	}
 	opts.TrustedPgp = trusted.PGPCerts
+	opts.TrustedSSH = trusted.SSHKeys
 	if len(opts.TrustedX509) > 0 {
 		if argAlsoSystem {
 			var err error
@@ -35,6 +35,7 @@ import (
 
 	"github.com/mind-security/relic/v8/cmdline/shared"
 	"github.com/mind-security/relic/v8/internal/workerrpc"
+	"github.com/mind-security/relic/v8/signers/sigerrors"
 	"github.com/mind-security/relic/v8/token"
 	"github.com/mind-security/relic/v8/token/tokencache"
 )
@@ -83,6 +84,13 @@ func (h *handler) healthCheck() {
 			err = fmt.Errorf("timed out after %s", timeout)
 		}
 		cancel()
+		if e := new(token.Error); errors.As(err, e) && e.Transient {
+			// give the token one more chance before restarting the worker
+			log.Warn().Err(err).Msg("token health check failed; retrying")
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			err = h.token.Ping(ctx)
+			cancel()
+		}
 		if err != nil {
 			// stop the worker on error
 			log.Err(err).Msg("token health check failed")
@@ -112,9 +120,9 @@ func (h *handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 	if err != nil {
 		resp.Retryable = true
 		resp.Err = err.Error()
-		switch e := err.(type) {
-		case pkcs11Error:
-			if fatalErrors[e] {
+		var rv pkcs11Error
+		if errors.As(err, &rv) {
+			if fatalErrors[rv] {
 				log.Err(err).Msg("terminating worker due to token error")
 				go h.shutdown()
 				// errors that cause the worker to restart are also retryable
@@ -123,6 +131,12 @@ func (h *handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 				// pkcs11 errors not in fatalErrors are probably user error, so don't retry
 				resp.Retryable = false
 			}
+		}
+		if e := new(token.Error); errors.As(err, e) {
+			resp.Code = e.Code
+			resp.Fatal = e.Fatal
+		}
+		switch e := err.(type) {
 		case token.NotImplementedError:
 			resp.Retryable = false
 		case token.KeyUsageError:
@@ -130,6 +144,9 @@ func (h *handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 			resp.Usage = true
 			resp.Key = e.Key
 			resp.Err = e.Err.Error()
+		case sigerrors.KeyNotFoundError:
+			resp.Retryable = false
+			resp.NotFound = true
 		}
 	}
 	// marshal response
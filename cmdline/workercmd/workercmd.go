@@ -34,6 +34,7 @@ import (
 	"github.com/mind-security/relic/v8/cmdline/shared"
 	"github.com/mind-security/relic/v8/internal/activation"
 	"github.com/mind-security/relic/v8/internal/activation/activatecmd"
+	"github.com/mind-security/relic/v8/internal/signinit"
 	"github.com/mind-security/relic/v8/internal/zhttp"
 	"github.com/mind-security/relic/v8/token/open"
 	"github.com/mind-security/relic/v8/token/tokencache"
@@ -75,6 +76,7 @@ func runWorker(tokenName string) error {
 	if err != nil {
 		return err
 	}
+	signinit.InitTokenAudit()
 	cfg := shared.CurrentConfig
 	tok, err := open.Token(cfg, tokenName, nil)
 	if err != nil {
@@ -114,6 +116,10 @@ func runWorker(tokenName string) error {
 		err = nil
 	}
 	wg.Wait() // wait for shutdown to finish
+	// log out now that no requests are using the token
+	if err2 := tok.Close(); err == nil {
+		err = err2
+	}
 	return err
 }
 
@@ -16,13 +16,35 @@
 
 package config
 
-import "crypto/x509"
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/mind-security/relic/v8/lib/certloader"
+	"github.com/mind-security/relic/v8/lib/x509tools"
+)
+
+// SpiffeID returns the SPIFFE ID that identifies this client, if it is
+// configured by one instead of a fingerprint
+func (cl *ClientConfig) SpiffeID() string {
+	return cl.spiffeID
+}
 
 func (cl *ClientConfig) Match(incoming []*x509.Certificate) (bool, error) {
 	if cl.certs == nil || len(incoming) == 0 {
 		return false, nil
 	}
 	leaf := incoming[0]
+	if cl.spiffeID != "" && x509tools.SpiffeID(leaf) != cl.spiffeID {
+		return false, nil
+	}
 	intermediates := incoming[1:]
 	ipool := x509.NewCertPool()
 	for _, cert := range intermediates {
@@ -40,3 +62,95 @@ func (cl *ClientConfig) Match(incoming []*x509.Certificate) (bool, error) {
 	}
 	return false, err
 }
+
+func normalizeClients(clients map[string]*ClientConfig) (map[string]*ClientConfig, error) {
+	normalized := make(map[string]*ClientConfig, len(clients))
+	for fingerprint, client := range clients {
+		if client == nil {
+			return nil, fmt.Errorf("client %s has no settings", fingerprint)
+		}
+		isSpiffe := strings.HasPrefix(strings.ToLower(fingerprint), "spiffe://")
+		if client.Certificate != "" {
+			certs, err := certloader.ParseX509Certificates([]byte(client.Certificate))
+			if err != nil {
+				return nil, fmt.Errorf("invalid certificate for client %s: %w", fingerprint, err)
+			}
+			client.certs = x509.NewCertPool()
+			for _, cert := range certs {
+				client.certs.AddCert(cert)
+			}
+		} else if isSpiffe {
+			return nil, fmt.Errorf("client %s needs the SPIFFE trust bundle in certificate", fingerprint)
+		} else if len(client.Claims) == 0 && len(fingerprint) != 64 {
+			return nil, errors.New("Client keys must be hex-encoded SHA256 digests of the public key")
+		}
+		if isSpiffe {
+			// SVIDs are matched by ID so that rotating the key doesn't lock
+			// the client out
+			id, err := x509tools.ParseSpiffeID(fingerprint)
+			if err != nil {
+				return nil, fmt.Errorf("client %s: %w", fingerprint, err)
+			}
+			client.spiffeID = id
+			normalized[id] = client
+			continue
+		}
+		lower := strings.ToLower(fingerprint)
+		normalized[lower] = client
+	}
+	return normalized, nil
+}
+
+// LoadClientsDir reads every .yml or .yaml file in dir, each holding clients
+// in the same form as the clients section of the main configuration
+func LoadClientsDir(dir string) (map[string]*ClientConfig, error) {
+	names, err := listYAMLFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	clients := make(map[string]*ClientConfig)
+	seen := make(map[string]string)
+	for _, name := range names {
+		blob, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		var raw map[string]*ClientConfig
+		if err := yaml.Unmarshal(blob, &raw); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		loaded, err := normalizeClients(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		for fingerprint, client := range loaded {
+			if prev := seen[fingerprint]; prev != "" {
+				return nil, fmt.Errorf("%s: client %s is also defined in %s", name, fingerprint, prev)
+			}
+			seen[fingerprint] = name
+			clients[fingerprint] = client
+		}
+	}
+	return clients, nil
+}
+
+// listYAMLFiles returns the names of the .yml and .yaml files in dir, sorted
+// so that fragments are applied in a predictable order
+func listYAMLFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") {
+			continue
+		}
+		if ext := filepath.Ext(name); ext == ".yml" || ext == ".yaml" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -21,16 +21,41 @@ import (
 	"errors"
 	"fmt"
 	"os"
-	"strings"
 
 	"gopkg.in/yaml.v3"
 
-	"github.com/mind-security/relic/v8/lib/certloader"
+	"github.com/mind-security/relic/v8/lib/x509tools"
 )
 
 const (
 	defaultSigXchg = "relic.signatures"
 	sigKey         = "relic.signatures"
+
+	// BufferFullBlock fails signing requests while the audit buffer is full
+	BufferFullBlock = "block"
+	// BufferFullDrop discards new audit records while the audit buffer is full
+	BufferFullDrop = "drop"
+
+	// VersionCheckWarn prints a warning when the server doesn't support the
+	// requested signature type
+	VersionCheckWarn = "warn"
+	// VersionCheckError fails instead of sending the request
+	VersionCheckError = "error"
+	// VersionCheckOff doesn't ask the server what it supports
+	VersionCheckOff = "off"
+
+	// LoginSession logs in to the PKCS#11 token once per session
+	LoginSession = "session"
+	// LoginContext additionally authenticates each private key operation
+	// with CKU_CONTEXT_SPECIFIC
+	LoginContext = "context"
+	// LoginPublic never logs in, for keys usable from a public session
+	LoginPublic = "public"
+
+	// ReloginOnce logs in again and retries once after CKR_USER_NOT_LOGGED_IN
+	ReloginOnce = "once"
+	// ReloginNever fails the operation after CKR_USER_NOT_LOGGED_IN
+	ReloginNever = "never"
 )
 
 var (
@@ -42,19 +67,34 @@ var (
 )
 
 type TokenConfig struct {
-	Type       string  // Provider type: file or pkcs11 (default)
-	Provider   string  // Path to PKCS#11 provider module (required)
-	Label      string  // Select a token by label
-	Serial     string  // Select a token by serial number
-	Pin        *string // PIN to use, otherwise will be prompted. Can be empty. (optional)
-	Timeout    int     // (server) Terminate command after N seconds (default 60)
-	Retries    int     // (server) Retry failed commands N times (default 5)
-	RateLimit  float64 // (server) limit token operations per second
-	RateBurst  int     // (server) allow burst of operations before limit kicks in
-	User       *uint   // User argument for PKCS#11 login (optional)
-	UseKeyring bool    // Read PIN from system keyring
+	Type             string  // Provider type: file or pkcs11 (default)
+	Provider         string  // Path to PKCS#11 provider module (required)
+	Label            string  // Select a token by label
+	Serial           string  // Select a token by serial number
+	Slot             *uint   // Select a token by slot ID
+	URI              string  // Select a token, and optionally a key, with a RFC 7512 "pkcs11:" URI
+	Pin              *string // PIN to use, otherwise will be prompted. Can be empty. (optional)
+	Timeout          int     // (server) Terminate command after N seconds (default 60)
+	Retries          int     // (server) Retry failed commands N times (default 5)
+	RateLimit        float64 // (server) limit token operations per second
+	RateBurst        int     // (server) allow burst of operations before limit kicks in
+	User             *uint   // User argument for PKCS#11 login (optional)
+	UseKeyring       bool    // Read PIN from system keyring
+	PinCommand       string  // Run this shell command and read the PIN from its stdout
+	PinSource        string  // Fetch the PIN when needed: file:, env:, command: or secret:// (see ParsePinSource)
+	PinRefresh       int     // Fetch the PIN again after N seconds (default: keep the first one)
+	MaxConcurrent    int     // (server) limit signing requests using the token at once
+	RejectConcurrent bool    // (server) reject requests over MaxConcurrent with 429 instead of queuing
+	PriorityAging    int     // (server) raise the priority of a request queued by MaxConcurrent by one every N seconds (default 10)
+	MaxSessions      int     // (pkcs11) open at most N sessions for signing (default 8)
+	LoginMode        string  // (pkcs11) when to log in: session (default), context, or public
+	Relogin          string  // (pkcs11) after CKR_USER_NOT_LOGGED_IN: once (default) to log in again and retry, or never
+	Proxy            string  // (aws, azure) URL of a HTTP proxy for the key service, or "direct" to ignore HTTPS_PROXY
+	KeyFile          string  // (pkcs12) Path to the PKCS#12 file holding the key and its certificate chain
 
 	name string
+	uri  *PKCS11URI
+	pin  *pinCache
 }
 
 type KeyConfig struct {
@@ -62,16 +102,29 @@ type KeyConfig struct {
 	Alias           string   // This is an alias for another key
 	Label           string   // Select a key by label
 	ID              string   // Select a key by ID (hex notation)
+	LabelPattern    string   // Serve every key whose label matches this glob, named by its label
 	PgpCertificate  string   // Path to PGP certificate associated with this key
+	PgpSubkey       string   // Key ID or fingerprint of the PGP signing subkey held by the token
 	X509Certificate string   // Path to X.509 certificate associated with this key
+	X509Chain       string   // Path to intermediate certificates to include in signatures
+	BuildChain      bool     // If true, complete the X.509 chain up to a root when signing (see "chain")
 	KeyFile         string   // For "file" tokens, path to the private key
 	IsPkcs12        bool     // If true, key file contains PKCS#12 key and certificate chain
 	Roles           []string // List of user roles that can use this key
+	AllowedFormats  []string // Signature types the server will produce with this key, or any if empty
 	Timestamp       bool     // If true, attach a timestamped countersignature when possible
+	TimestampStyle  string   // For Authenticode: rfc3161 (default), microsoft, or fallback
+	HashAlgorithm   string   // Digest to sign with when the request doesn't choose one
+	RpmStyle        string   // For RPM: classic (default), v4, or v6
+	RpmIMA          bool     // For RPM: also add an IMA signature for each file
+	SigningTime     string   // Signing time to record: now (default), omit, or a fixed time
 	Hide            bool     // If true, then omit this key from 'remote list-keys'
+	StandbyIDs      []string // Cloud KMS: replicas of this key in other regions to fail over to
+	CacheSignatures bool     // If true, reuse a recent signature when the same digest is signed again
 
-	name  string
-	token *TokenConfig
+	name     string
+	token    *TokenConfig
+	template string
 }
 
 type ServerConfig struct {
@@ -88,14 +141,28 @@ type ServerConfig struct {
 	ListenMetrics string // Port to listen for plaintext metrics
 	NumWorkers    int    // Number of worker subprocesses per configured token
 
+	ClientsDir            string // Directory of YAML files with more clients, re-read periodically
+	ClientsReloadInterval int    // Seconds between re-reading ClientsDir
+
 	TokenCheckInterval int
 	TokenCheckFailures int
 	TokenCheckTimeout  int
 	TokenCacheSeconds  int
 
+	SignatureCacheSeconds int // Seconds to remember signatures of keys with cachesignatures set
+	SignatureCacheSize    int // Maximum number of signatures to remember
+
 	ReadHeaderTimeout int
 	ReadTimeout       int
 	WriteTimeout      int
+	ShutdownTimeout   int // Seconds to let in-flight requests finish when stopping
+
+	IdleTimeout          int    // Seconds to keep an idle client connection open
+	KeepAliveInterval    int    // Seconds between TCP keep-alive probes, or -1 to disable
+	MaxConcurrentStreams uint32 // Simultaneous requests allowed on one HTTP/2 connection
+
+	MinTLSVersion string   // Oldest TLS version to accept, "1.2" (default) or "1.3"
+	CipherSuites  []string // Optional allowlist of TLS 1.2 cipher suites
 
 	// URLs to all servers in the cluster. If a client uses DirectoryURL to
 	// point to this server (or a load balancer), then we will give them these
@@ -105,7 +172,40 @@ type ServerConfig struct {
 	// IP networks of trusted reverse proxies that can front this service
 	TrustedProxies []string
 
+	// Return the signing certificate chain to clients that ask for it
+	SendChain bool
+
+	// PEM bundle of CA roots that verifiers should trust for signatures made
+	// by this server, offered to clients along with the roots of the served
+	// keys' own chains
+	TrustRoots string
+
+	UploadDir     string // Directory for resumable uploads in progress (default: system temp dir)
+	UploadTimeout int    // Seconds before an idle, unfinished upload is discarded
+	MaxUploads    int    // Most unfinished uploads each client may have at once (default 10)
+
+	// Largest file in bytes that may be sent to be signed, or 0 for no limit.
+	// Formats that produce a detached signature read the file as a stream
+	// and can use a separate, larger limit.
+	MaxRequestSize         int64
+	MaxDetachedRequestSize int64
+	// Formats that read their input more than once keep up to this many
+	// bytes of it in memory, then use a temp file
+	SpoolThreshold int64
+
 	AzureAD *ServerAzureConfig
+	OIDC    *OIDCConfig
+
+	tls x509tools.TLSOptions
+}
+
+// OIDCConfig enables clients to authenticate with a bearer JWT issued by an
+// OpenID Connect provider, in addition to client certificates
+type OIDCConfig struct {
+	Issuer   string // Expected iss claim, and where to discover the signing keys
+	Audience string // Value that must be present in the aud claim
+	JWKSURL  string // Optional URL of the signing keys, instead of discovering it
+	Leeway   int    // Seconds of clock skew to allow when checking exp and nbf
 }
 
 type ServerAzureConfig struct {
@@ -117,9 +217,23 @@ type ServerAzureConfig struct {
 type ClientConfig struct {
 	Nickname    string   // Name that appears in audit log entries
 	Roles       []string // List of roles that this client possesses
-	Certificate string   // Optional CA certificate(s) that sign client certs instead of using fingerprint-based auth
+	Certificate string   // Optional CA certificate(s) that sign client certs instead of using fingerprint-based auth, or the SPIFFE trust bundle
+	DefaultKey  string   // Key to sign with when a request doesn't name one. The client's roles must still grant access to it.
+
+	// For OIDC bearer tokens, the claims a token must carry to act as this
+	// client. Every listed claim must match.
+	Claims map[string]string
+
+	certs    *x509.CertPool
+	spiffeID string
+}
 
-	certs *x509.CertPool
+// RoleConfig limits what clients holding a role may sign, whichever key they
+// use
+type RoleConfig struct {
+	AllowedFormats    []string // Signature types that may be requested, or any if empty
+	AllowedExtensions []string // Filename extensions that may be signed, or any if empty
+	Priority          int      // Requests waiting on a token's MaxConcurrent go first if higher (default 0)
 }
 
 type RemoteConfig struct {
@@ -130,19 +244,74 @@ type RemoteConfig struct {
 	CaCert         string `yaml:",omitempty"` // Path to CA certificate or embedded certificate
 	ConnectTimeout int    `yaml:",omitempty"` // Connection timeout in seconds
 	Retries        int    `yaml:",omitempty"` // Attempt an operation (at least) N times
+	SpoolThreshold int64  `yaml:",omitempty"` // Buffer non-seekable input in memory up to this many bytes, then use a temp file
+
+	// Send inputs larger than this many bytes to the server as a resumable
+	// upload in chunks of this size, or -1 to always send them in one request
+	UploadChunkSize int64 `yaml:",omitempty"`
+
+	// URL of a HTTP proxy to reach the server through, or "direct" to ignore
+	// HTTPS_PROXY from the environment
+	Proxy string `yaml:",omitempty"`
+
+	MinTLSVersion string   `yaml:",omitempty"` // Oldest TLS version to use, "1.2" (default) or "1.3"
+	CipherSuites  []string `yaml:",omitempty"` // Optional allowlist of TLS 1.2 cipher suites
+
+	// What to do when the server doesn't support the signature type being
+	// requested: "warn" (default), "error" or "off"
+	VersionCheck string `yaml:",omitempty"`
 
 	AccessToken string `yaml:"-"`
 	Interactive bool
+
+	fips bool
+	tls  x509tools.TLSOptions
 }
 
 type TimestampConfig struct {
 	URLs      []string // List of timestamp server URLs
 	MsURLs    []string // List of microsoft-style URLs
-	Timeout   int      // Connect timeout in seconds
+	Timeout   int      // Timeout in seconds for each attempt
 	CaCert    string   // Path to CA certificate
 	Memcache  []string // host:port of memcached to use for caching timestamps
 	RateLimit float64  // limit timestamp requests per second
 	RateBurst int      // allow burst of requests before limit kicks in
+
+	HashAlgorithm  string   // Digest to request, instead of the one used by the signature
+	RequestCertReq *bool    // Ask the TSA to include its certificate (default true)
+	MaxTimestamps  int      // Refuse to add more than this many timestamps to one signature
+	ReuseSeconds   int      // Reuse the timestamp for a repeated request within this window (default 60, -1 disables)
+	AllowedURLs    []string // Servers that a signing request may choose instead of URLs or MsURLs
+	Proxy          string   // URL of a HTTP proxy for timestamp requests, or "direct" to ignore HTTP(S)_PROXY
+}
+
+type TransparencyConfig struct {
+	URL       string // Base URL of a Rekor-compatible transparency log
+	AuthToken string // Optional bearer token to send with submissions
+	CaCert    string // Path to CA certificate
+	Timeout   int    // Timeout in seconds
+	Required  bool   // Fail the signing operation if the submission fails
+	Proxy     string // URL of a HTTP proxy for the log, or "direct" to ignore HTTP(S)_PROXY
+}
+
+// ChainConfig controls how keys with buildchain set complete their X.509
+// certificate chain when signing
+type ChainConfig struct {
+	Intermediates string // Path to a bundle of intermediate certificates to search for issuers
+	FetchAIA      bool   // Download missing issuers named in the Authority Information Access extension
+	CacheDir      string // Directory to keep downloaded issuers in across restarts
+	Timeout       int    // Timeout in seconds for each download (default 10)
+	Proxy         string // URL of a HTTP proxy for downloads, or "direct" to ignore HTTP(S)_PROXY
+}
+
+type VerifyConfig struct {
+	UnknownSignedAttrs   string // How "relic verify" treats unknown signed PKCS#7 attributes: strict (default) or lenient
+	UnknownUnsignedAttrs string // How "relic verify" treats unknown unsigned PKCS#7 attributes: lenient (default) or strict
+}
+
+type DigestConfig struct {
+	Workers    int   // Most files digested in parallel. Defaults to GOMAXPROCS.
+	BufferSize int64 // Largest file buffered in memory per worker
 }
 
 type AmqpConfig struct {
@@ -151,6 +320,12 @@ type AmqpConfig struct {
 	KeyFile  string
 	CertFile string
 	SigsXchg string // Name of exchange to send to (default relic.signatures)
+
+	BufferDir  string // Directory to hold records until the broker confirms them
+	BufferMax  int    // Most records held in BufferDir (default 10000)
+	BufferFull string // What to do when BufferDir is full: "block" (default) or "drop"
+
+	fips bool
 }
 
 type Config struct {
@@ -158,14 +333,26 @@ type Config struct {
 	Keys      map[string]*KeyConfig    `yaml:",omitempty"`
 	Server    *ServerConfig            `yaml:",omitempty"`
 	Clients   map[string]*ClientConfig `yaml:",omitempty"`
+	Roles     map[string]*RoleConfig   `yaml:",omitempty"`
 	Remote    *RemoteConfig            `yaml:",omitempty"`
 	Timestamp *TimestampConfig         `yaml:",omitempty"`
 	Amqp      *AmqpConfig              `yaml:",omitempty"`
+	Digest    *DigestConfig            `yaml:",omitempty"`
+
+	Transparency *TransparencyConfig `yaml:",omitempty"`
+	Chain        *ChainConfig        `yaml:",omitempty"`
+	Verify       *VerifyConfig       `yaml:",omitempty"`
+
+	AuditFile        string `yaml:",omitempty"` // Optional log file for signatures
+	AuditTokenEvents bool   `yaml:",omitempty"` // Also audit token logins and health changes
+	PinFile          string `yaml:",omitempty"` // Optional YAML file with additional token PINs
 
-	AuditFile string `yaml:",omitempty"` // Optional log file for signatures
-	PinFile   string `yaml:",omitempty"` // Optional YAML file with additional token PINs
+	// Restrict TLS connections to FIPS-approved versions, cipher suites and
+	// key types
+	FIPS bool `yaml:",omitempty"`
 
-	path string
+	path     string
+	keyPaths map[string]string // file defining each key, when read from a directory
 }
 
 func ReadFile(path string) (*Config, error) {
@@ -183,54 +370,59 @@ func ReadFile(path string) (*Config, error) {
 
 func (config *Config) Normalize(path string) error {
 	config.path = path
-	normalized := make(map[string]*ClientConfig)
-	for fingerprint, client := range config.Clients {
-		if client.Certificate != "" {
-			certs, err := certloader.ParseX509Certificates([]byte(client.Certificate))
-			if err != nil {
-				return fmt.Errorf("invalid certificate for client %s: %w", fingerprint, err)
-			}
-			client.certs = x509.NewCertPool()
-			for _, cert := range certs {
-				client.certs.AddCert(cert)
-			}
-		} else if len(fingerprint) != 64 {
-			return errors.New("Client keys must be hex-encoded SHA256 digests of the public key")
-		}
-		lower := strings.ToLower(fingerprint)
-		normalized[lower] = client
+	normalized, err := normalizeClients(config.Clients)
+	if err != nil {
+		return err
 	}
 	config.Clients = normalized
-	if config.PinFile != "" {
-		contents, err := os.ReadFile(config.PinFile)
-		if err != nil {
-			return fmt.Errorf("reading PinFile: %w", err)
-		}
-		pinMap := make(map[string]string)
-		if err := yaml.Unmarshal(contents, pinMap); err != nil {
-			return fmt.Errorf("reading PinFile: %w", err)
-		}
-		for token, pin := range pinMap {
-			tokenConf := config.Tokens[token]
-			if tokenConf != nil {
-				ppin := pin
-				tokenConf.Pin = &ppin
-			}
-		}
-	}
 	for tokenName, tokenConf := range config.Tokens {
 		tokenConf.name = tokenName
 		if tokenConf.Type == "" {
 			tokenConf.Type = "pkcs11"
 		}
+		if err := tokenConf.normalizeSelectors(); err != nil {
+			return fmt.Errorf("token %q: %w", tokenName, err)
+		}
+		switch tokenConf.LoginMode {
+		case "":
+			tokenConf.LoginMode = LoginSession
+		case LoginSession, LoginContext, LoginPublic:
+		default:
+			return fmt.Errorf("token %q: loginmode must be %q, %q or %q", tokenName, LoginSession, LoginContext, LoginPublic)
+		}
+		switch tokenConf.Relogin {
+		case "":
+			tokenConf.Relogin = ReloginOnce
+		case ReloginOnce, ReloginNever:
+		default:
+			return fmt.Errorf("token %q: relogin must be %q or %q", tokenName, ReloginOnce, ReloginNever)
+		}
+		if _, err := ProxyFunc(tokenConf.Proxy); err != nil {
+			return fmt.Errorf("token %q: %w", tokenName, err)
+		}
+		if err := tokenConf.normalizePin(config.PinFile); err != nil {
+			return fmt.Errorf("token %q: %w", tokenName, err)
+		}
 	}
 	for keyName, keyConf := range config.Keys {
 		keyConf.name = keyName
 		if keyConf.Token != "" {
 			keyConf.token = config.Tokens[keyConf.Token]
 		}
+		if keyConf.LabelPattern != "" {
+			if keyConf.Label != "" || keyConf.ID != "" || keyConf.Alias != "" {
+				return fmt.Errorf("key %q: labelpattern can't be combined with label, id or alias", keyName)
+			} else if _, err := matchLabel(keyConf.LabelPattern, ""); err != nil {
+				return fmt.Errorf("key %q: labelpattern: %w", keyName, err)
+			}
+		}
 	}
 	if s := config.Server; s != nil {
+		tlsOpts, err := x509tools.ParseTLSOptions(s.MinTLSVersion, s.CipherSuites, config.FIPS)
+		if err != nil {
+			return fmt.Errorf("server: %w", err)
+		}
+		s.tls = tlsOpts
 		if s.TokenCheckInterval == 0 {
 			s.TokenCheckInterval = 60
 		}
@@ -243,6 +435,12 @@ func (config *Config) Normalize(path string) error {
 		if s.TokenCacheSeconds == 0 {
 			s.TokenCacheSeconds = 600
 		}
+		if s.SignatureCacheSeconds == 0 {
+			s.SignatureCacheSeconds = 60
+		}
+		if s.SignatureCacheSize == 0 {
+			s.SignatureCacheSize = 1000
+		}
 		if s.ReadHeaderTimeout == 0 {
 			s.ReadHeaderTimeout = 10
 		}
@@ -252,14 +450,85 @@ func (config *Config) Normalize(path string) error {
 		if s.WriteTimeout == 0 {
 			s.WriteTimeout = 600
 		}
+		if s.ShutdownTimeout == 0 {
+			s.ShutdownTimeout = 300
+		}
+		if s.IdleTimeout == 0 {
+			s.IdleTimeout = 120
+		}
+		if s.ClientsReloadInterval == 0 {
+			s.ClientsReloadInterval = 60
+		}
+		if s.UploadTimeout == 0 {
+			s.UploadTimeout = 3600
+		}
+		if s.MaxUploads == 0 {
+			s.MaxUploads = 10
+		}
+		if s.MaxRequestSize < 0 || s.MaxDetachedRequestSize < 0 {
+			return errors.New("server: request size limits can't be negative")
+		}
+		if s.OIDC != nil && s.OIDC.Leeway == 0 {
+			s.OIDC.Leeway = 60
+		}
+	}
+	if a := config.Amqp; a != nil {
+		a.fips = config.FIPS
+		if a.BufferMax == 0 {
+			a.BufferMax = 10000
+		}
+		switch a.BufferFull {
+		case "":
+			a.BufferFull = BufferFullBlock
+		case BufferFullBlock, BufferFullDrop:
+		default:
+			return fmt.Errorf("amqp: bufferfull must be %q or %q", BufferFullBlock, BufferFullDrop)
+		}
+	}
+	if t := config.Timestamp; t != nil {
+		if _, err := ProxyFunc(t.Proxy); err != nil {
+			return fmt.Errorf("timestamp: %w", err)
+		}
+	}
+	if t := config.Transparency; t != nil {
+		if _, err := ProxyFunc(t.Proxy); err != nil {
+			return fmt.Errorf("transparency: %w", err)
+		}
+	}
+	if c := config.Chain; c != nil {
+		if _, err := ProxyFunc(c.Proxy); err != nil {
+			return fmt.Errorf("chain: %w", err)
+		}
+		if c.Timeout == 0 {
+			c.Timeout = 10
+		}
 	}
 	if r := config.Remote; r != nil {
+		if _, err := ProxyFunc(r.Proxy); err != nil {
+			return fmt.Errorf("remote: %w", err)
+		}
+		r.fips = config.FIPS
+		tlsOpts, err := x509tools.ParseTLSOptions(r.MinTLSVersion, r.CipherSuites, config.FIPS)
+		if err != nil {
+			return fmt.Errorf("remote: %w", err)
+		}
+		r.tls = tlsOpts
 		if r.ConnectTimeout == 0 {
 			r.ConnectTimeout = 15
 		}
 		if r.Retries == 0 {
 			r.Retries = 3
 		}
+		if r.UploadChunkSize == 0 {
+			r.UploadChunkSize = 64 * 1024 * 1024
+		}
+		switch r.VersionCheck {
+		case "":
+			r.VersionCheck = VersionCheckWarn
+		case VersionCheckWarn, VersionCheckError, VersionCheckOff:
+		default:
+			return fmt.Errorf("remote: versioncheck must be %q, %q or %q", VersionCheckWarn, VersionCheckError, VersionCheckOff)
+		}
 	}
 	return nil
 }
@@ -287,7 +556,12 @@ func (config *Config) NewToken(name string) *TokenConfig {
 func (config *Config) GetKey(keyName string) (*KeyConfig, error) {
 	keyConf, ok := config.Keys[keyName]
 	if !ok {
-		return nil, fmt.Errorf("Key \"%s\" not found in configuration", keyName)
+		keyConf = config.keyFromPattern(keyName)
+		if keyConf == nil {
+			return nil, fmt.Errorf("Key \"%s\" not found in configuration", keyName)
+		}
+	} else if keyConf.LabelPattern != "" {
+		return nil, fmt.Errorf("Key \"%s\" is a label pattern and can't be used directly", keyName)
 	} else if keyConf.Alias != "" {
 		keyConf, ok = config.Keys[keyConf.Alias]
 		if !ok {
@@ -350,3 +624,24 @@ func (aconf *AmqpConfig) ExchangeName() string {
 func (aconf *AmqpConfig) RoutingKey() string {
 	return sigKey
 }
+
+// FIPS returns true if connections to the broker must use FIPS-approved TLS
+func (aconf *AmqpConfig) FIPS() bool {
+	return aconf.fips
+}
+
+// FIPS returns true if connections to the server must use FIPS-approved TLS
+func (r *RemoteConfig) FIPS() bool {
+	return r.fips
+}
+
+// TLSOptions returns the TLS version and cipher suites to use when connecting
+// to the server
+func (r *RemoteConfig) TLSOptions() x509tools.TLSOptions {
+	return r.tls
+}
+
+// TLSOptions returns the TLS version and cipher suites to accept from clients
+func (s *ServerConfig) TLSOptions() x509tools.TLSOptions {
+	return s.tls
+}
@@ -0,0 +1,131 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ReadDir reads every .yml or .yaml file in dir in lexical order and merges
+// them into a single configuration. Tokens, keys, clients and roles are merged
+// by name, with later files overriding earlier ones. Any other section may only
+// be defined by one file.
+func ReadDir(dir string) (*Config, error) {
+	names, err := listYAMLFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("%s: no configuration files found", dir)
+	}
+	config := new(Config)
+	sections := make(map[string]string)
+	for _, name := range names {
+		fpath := filepath.Join(dir, name)
+		data, err := os.ReadFile(fpath)
+		if err != nil {
+			return nil, err
+		}
+		frag := new(Config)
+		if err := yaml.Unmarshal(data, frag); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		// normalize client fingerprints before merging so that overrides match
+		frag.Clients, err = normalizeClients(frag.Clients)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if err := config.merge(frag, fpath, sections); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return config, config.Normalize(dir)
+}
+
+// ReadPath reads a configuration file, or a directory of configuration files
+func ReadPath(path string) (*Config, error) {
+	st, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	} else if st.IsDir() {
+		return ReadDir(path)
+	}
+	return ReadFile(path)
+}
+
+// merge a fragment read from fpath into config. sections tracks which file
+// defined each singleton section.
+func (config *Config) merge(frag *Config, fpath string, sections map[string]string) error {
+	for name, tokenConf := range frag.Tokens {
+		if config.Tokens == nil {
+			config.Tokens = make(map[string]*TokenConfig)
+		}
+		config.Tokens[name] = tokenConf
+	}
+	for name, keyConf := range frag.Keys {
+		if config.Keys == nil {
+			config.Keys = make(map[string]*KeyConfig)
+		}
+		if config.keyPaths == nil {
+			config.keyPaths = make(map[string]string)
+		}
+		config.Keys[name] = keyConf
+		config.keyPaths[name] = fpath
+	}
+	for fingerprint, client := range frag.Clients {
+		if config.Clients == nil {
+			config.Clients = make(map[string]*ClientConfig)
+		}
+		config.Clients[fingerprint] = client
+	}
+	for name, role := range frag.Roles {
+		if config.Roles == nil {
+			config.Roles = make(map[string]*RoleConfig)
+		}
+		config.Roles[name] = role
+	}
+	singletons := []struct {
+		name string
+		set  bool
+		copy func()
+	}{
+		{"server", frag.Server != nil, func() { config.Server = frag.Server }},
+		{"remote", frag.Remote != nil, func() { config.Remote = frag.Remote }},
+		{"timestamp", frag.Timestamp != nil, func() { config.Timestamp = frag.Timestamp }},
+		{"amqp", frag.Amqp != nil, func() { config.Amqp = frag.Amqp }},
+		{"digest", frag.Digest != nil, func() { config.Digest = frag.Digest }},
+		{"transparency", frag.Transparency != nil, func() { config.Transparency = frag.Transparency }},
+		{"auditfile", frag.AuditFile != "", func() { config.AuditFile = frag.AuditFile }},
+		{"audittokenevents", frag.AuditTokenEvents, func() { config.AuditTokenEvents = true }},
+		{"pinfile", frag.PinFile != "", func() { config.PinFile = frag.PinFile }},
+	}
+	for _, section := range singletons {
+		if !section.set {
+			continue
+		}
+		if prev := sections[section.name]; prev != "" {
+			return fmt.Errorf("%s is also defined in %s", section.name, prev)
+		}
+		sections[section.name] = fpath
+		section.copy()
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFragments(t *testing.T, frags map[string]string) string {
+	dir := t.TempDir()
+	for name, contents := range frags {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(contents), 0600))
+	}
+	return dir
+}
+
+func TestReadDir(t *testing.T) {
+	dir := writeFragments(t, map[string]string{
+		"10-keys.yaml": `
+tokens:
+  file:
+    type: file
+keys:
+  rsa1:
+    token: file
+    keyfile: rsa1.key
+  ecdsa1:
+    token: file
+    keyfile: ecdsa1.key
+`,
+		"20-override.yml": `
+keys:
+  ecdsa1:
+    token: file
+    keyfile: ecdsa2.key
+clients:
+  ` + "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA" + `:
+    roles: [signer]
+`,
+		"30-server.yaml": `
+server:
+  listen: ":6300"
+`,
+		"notes.txt":    "not: [yaml",
+		".hidden.yaml": "not: [yaml",
+	})
+	cfg, err := ReadDir(dir)
+	require.NoError(t, err)
+	assert.Equal(t, dir, cfg.Path())
+	require.Len(t, cfg.Keys, 2)
+	assert.Equal(t, "rsa1.key", cfg.Keys["rsa1"].KeyFile)
+	assert.Equal(t, "ecdsa2.key", cfg.Keys["ecdsa1"].KeyFile)
+	assert.Equal(t, "file", cfg.Keys["ecdsa1"].Token)
+	assert.Contains(t, cfg.Clients, "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa")
+	require.NotNil(t, cfg.Server)
+	assert.Equal(t, ":6300", cfg.Server.Listen)
+	assert.Equal(t, 60, cfg.Server.TokenCheckInterval)
+
+	// certificate updates go to the fragment that defined the key
+	require.NoError(t, cfg.SetKeyCertificate("ecdsa1", "new.crt"))
+	data, err := os.ReadFile(filepath.Join(dir, "20-override.yml"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "x509certificate: new.crt")
+
+	cfg, err = ReadPath(dir)
+	require.NoError(t, err)
+	assert.Equal(t, "new.crt", cfg.Keys["ecdsa1"].X509Certificate)
+}
+
+func TestReadDirConflict(t *testing.T) {
+	dir := writeFragments(t, map[string]string{
+		"a.yaml": "server:\n  listen: \":6300\"\n",
+		"b.yaml": "server:\n  listen: \":6301\"\n",
+	})
+	_, err := ReadDir(dir)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "server is also defined in")
+
+	_, err = ReadDir(t.TempDir())
+	assert.Error(t, err)
+}
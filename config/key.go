@@ -16,7 +16,12 @@
 
 package config
 
-import "time"
+import (
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
 
 const defaultTimeout = 60 * time.Second
 
@@ -24,6 +29,45 @@ func (keyConf *KeyConfig) Name() string {
 	return keyConf.name
 }
 
+// Template returns the name of the labelpattern key that this key was resolved
+// from, or "" if it is configured directly
+func (keyConf *KeyConfig) Template() string {
+	return keyConf.template
+}
+
+// keyFromPattern resolves a key by label if it matches the labelpattern of a
+// configured key. "{label}" in certificate paths is replaced with the label.
+func (config *Config) keyFromPattern(label string) *KeyConfig {
+	names := make([]string, 0, len(config.Keys))
+	for name, keyConf := range config.Keys {
+		if keyConf.LabelPattern != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		template := config.Keys[name]
+		if ok, _ := matchLabel(template.LabelPattern, label); !ok {
+			continue
+		}
+		keyConf := *template
+		keyConf.name = label
+		keyConf.template = name
+		keyConf.Label = label
+		keyConf.LabelPattern = ""
+		expand := func(p string) string { return strings.ReplaceAll(p, "{label}", label) }
+		keyConf.PgpCertificate = expand(keyConf.PgpCertificate)
+		keyConf.X509Certificate = expand(keyConf.X509Certificate)
+		keyConf.X509Chain = expand(keyConf.X509Chain)
+		return &keyConf
+	}
+	return nil
+}
+
+func matchLabel(pattern, label string) (bool, error) {
+	return path.Match(pattern, label)
+}
+
 func (keyConf *KeyConfig) GetTimeout() time.Duration {
 	if keyConf.token != nil && keyConf.token.Timeout != 0 {
 		return time.Second * time.Duration(keyConf.token.Timeout)
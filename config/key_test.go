@@ -0,0 +1,57 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestLabelPattern(t *testing.T) {
+	normalize := func(doc string) (*Config, error) {
+		cfg := new(Config)
+		require.NoError(t, yaml.Unmarshal([]byte(doc), cfg))
+		return cfg, cfg.Normalize("")
+	}
+	cfg, err := normalize(`
+tokens:
+  hsm: {}
+keys:
+  customers:
+    token: hsm
+    labelpattern: customer-*
+    pgpcertificate: /etc/relic/{label}.asc
+    roles: [customer-signers]
+  fixed:
+    token: hsm
+    label: customer-fixed
+`)
+	require.NoError(t, err)
+	keyConf, err := cfg.GetKey("customer-acme")
+	require.NoError(t, err)
+	assert.Equal(t, "customer-acme", keyConf.Name())
+	assert.Equal(t, "customer-acme", keyConf.Label)
+	assert.Equal(t, "customers", keyConf.Template())
+	assert.Equal(t, "/etc/relic/customer-acme.asc", keyConf.PgpCertificate)
+	assert.Equal(t, []string{"customer-signers"}, keyConf.Roles)
+	assert.Equal(t, "hsm", keyConf.token.Name())
+	// the template itself is unchanged and can't be used directly
+	assert.Equal(t, "/etc/relic/{label}.asc", cfg.Keys["customers"].PgpCertificate)
+	_, err = cfg.GetKey("customers")
+	assert.Error(t, err)
+	// configured keys take precedence
+	keyConf, err = cfg.GetKey("fixed")
+	require.NoError(t, err)
+	assert.Empty(t, keyConf.Template())
+	_, err = cfg.GetKey("vendor-acme")
+	assert.Error(t, err)
+
+	for _, bad := range []string{
+		"keys:\n  k:\n    token: hsm\n    labelpattern: a-*\n    label: a\n",
+		"keys:\n  k:\n    token: hsm\n    labelpattern: a-[\n",
+	} {
+		_, err := normalize(bad)
+		assert.Error(t, err, bad)
+	}
+}
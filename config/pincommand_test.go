@@ -0,0 +1,32 @@
+package config
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunPinCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test commands use the POSIX shell")
+	}
+	pin, err := runPinCommand("printf '1234\\r\\n\\n'")
+	require.NoError(t, err)
+	assert.Equal(t, "1234", pin)
+	// only trailing line endings are removed
+	pin, err = runPinCommand("printf ' 12\\n34 '")
+	require.NoError(t, err)
+	assert.Equal(t, " 12\n34 ", pin)
+
+	// the PIN printed before failing must not leak into the error
+	_, err = runPinCommand("echo secret-pin; exit 3")
+	assert.EqualError(t, err, "pincommand exited with status 3")
+	assert.NotContains(t, err.Error(), "secret-pin")
+
+	_, err = runPinCommand("printf '\\r\\n'")
+	assert.EqualError(t, err, "pincommand produced no output")
+	_, err = runPinCommand("true")
+	assert.EqualError(t, err, "pincommand produced no output")
+}
@@ -0,0 +1,235 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PinSource fetches a token's PIN when it is first needed, instead of it being
+// written into the configuration
+type PinSource interface {
+	// FetchPin returns the PIN for the named token, or ErrNoPin if the source
+	// doesn't have one for it. The caller may clear the returned slice.
+	FetchPin(tokenName string) ([]byte, error)
+}
+
+// ErrNoPin is returned by a PinSource that has no PIN for a token
+var ErrNoPin = errors.New("no PIN available")
+
+// ParsePinSource parses a pinsource setting, which is one of:
+//
+//	file:<path>              a YAML file mapping token names to PINs
+//	env:<variable>           an environment variable
+//	command:<command>        the standard output of a shell command
+//	secret://<store>/<name>  a secret store, such as secret://aws/<secret id>
+func ParsePinSource(spec string) (PinSource, error) {
+	if strings.HasPrefix(spec, SecretPrefix) {
+		return secretPinSource(spec), nil
+	}
+	scheme, value, _ := strings.Cut(spec, ":")
+	if value == "" {
+		return nil, fmt.Errorf("invalid pinsource %q: expected file:, env:, command: or %s", spec, SecretPrefix)
+	}
+	switch scheme {
+	case "file":
+		return filePinSource(value), nil
+	case "env":
+		return envPinSource(value), nil
+	case "command":
+		return commandPinSource(value), nil
+	default:
+		return nil, fmt.Errorf("invalid pinsource %q: unknown type %q", spec, scheme)
+	}
+}
+
+type filePinSource string
+
+func (s filePinSource) FetchPin(tokenName string) ([]byte, error) {
+	contents, err := os.ReadFile(string(s))
+	if err != nil {
+		return nil, fmt.Errorf("reading PinFile: %w", err)
+	}
+	pinMap := make(map[string]string)
+	if err := yaml.Unmarshal(contents, pinMap); err != nil {
+		// the YAML error can quote the file
+		return nil, fmt.Errorf("reading PinFile %s: invalid YAML", s)
+	}
+	pin, ok := pinMap[tokenName]
+	if !ok {
+		return nil, ErrNoPin
+	}
+	return []byte(pin), nil
+}
+
+type envPinSource string
+
+func (s envPinSource) FetchPin(string) ([]byte, error) {
+	pin, ok := os.LookupEnv(string(s))
+	if !ok {
+		return nil, fmt.Errorf("environment variable %s is not set", s)
+	}
+	return []byte(pin), nil
+}
+
+type commandPinSource string
+
+func (s commandPinSource) FetchPin(string) ([]byte, error) {
+	pin, err := runPinCommand(string(s))
+	if err != nil {
+		return nil, err
+	}
+	return []byte(pin), nil
+}
+
+type secretPinSource string
+
+func (s secretPinSource) FetchPin(string) ([]byte, error) {
+	blob, err := loadSecret(string(s))
+	if err != nil {
+		return nil, err
+	}
+	n := len(blob)
+	for n > 0 && (blob[n-1] == '\n' || blob[n-1] == '\r') {
+		n--
+	}
+	return blob[:n], nil
+}
+
+type staticPinSource string
+
+func (s staticPinSource) FetchPin(string) ([]byte, error) {
+	return []byte(s), nil
+}
+
+// pinCache holds the PIN fetched from a token's sources until it is cleared or
+// expires
+type pinCache struct {
+	sources []PinSource
+	refresh time.Duration
+
+	mu      sync.Mutex
+	pin     []byte
+	found   bool
+	fetched time.Time
+}
+
+func (c *pinCache) get(tokenName string) (string, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.fetched.IsZero() && (c.refresh == 0 || time.Since(c.fetched) < c.refresh) {
+		return string(c.pin), c.found, nil
+	}
+	c.clearLocked()
+	for _, source := range c.sources {
+		pin, err := source.FetchPin(tokenName)
+		if errors.Is(err, ErrNoPin) {
+			continue
+		} else if err != nil {
+			return "", false, fmt.Errorf("token %q: fetching PIN: %w", tokenName, err)
+		}
+		c.pin = pin
+		c.found = true
+		break
+	}
+	c.fetched = time.Now()
+	return string(c.pin), c.found, nil
+}
+
+func (c *pinCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.clearLocked()
+}
+
+func (c *pinCache) clearLocked() {
+	for i := range c.pin {
+		c.pin[i] = 0
+	}
+	c.pin = nil
+	c.found = false
+	c.fetched = time.Time{}
+}
+
+// normalizePin sets up where the token's PIN comes from. Nothing is fetched
+// until the token is used.
+func (tconf *TokenConfig) normalizePin(pinFile string) error {
+	if tconf.PinRefresh < 0 {
+		return errors.New("pinrefresh must not be negative")
+	}
+	var sources []PinSource
+	if tconf.PinSource != "" {
+		if tconf.Pin != nil || tconf.PinCommand != "" {
+			return errors.New("pinsource can't be combined with pin or pincommand")
+		}
+		source, err := ParsePinSource(tconf.PinSource)
+		if err != nil {
+			return err
+		}
+		sources = append(sources, source)
+	} else {
+		// the global pin file takes precedence, then the PIN in the token
+		// section, then the command
+		if pinFile != "" {
+			sources = append(sources, filePinSource(pinFile))
+		}
+		if tconf.Pin != nil {
+			sources = append(sources, staticPinSource(*tconf.Pin))
+		}
+		if tconf.PinCommand != "" {
+			sources = append(sources, commandPinSource(tconf.PinCommand))
+		}
+	}
+	if len(sources) == 0 {
+		tconf.pin = nil
+		return nil
+	}
+	tconf.pin = &pinCache{
+		sources: sources,
+		refresh: time.Duration(tconf.PinRefresh) * time.Second,
+	}
+	return nil
+}
+
+// GetPin returns the token's PIN, fetching it from the configured source the
+// first time it is needed. If ok is false then no PIN is configured and the
+// user may be prompted for one.
+func (tconf *TokenConfig) GetPin() (pin string, ok bool, err error) {
+	if tconf.pin == nil {
+		// not normalized, or nothing but the pin setting
+		if tconf.Pin != nil {
+			return *tconf.Pin, true, nil
+		}
+		return "", false, nil
+	}
+	return tconf.pin.get(tconf.name)
+}
+
+// ClearPin forgets a fetched PIN, overwriting the copy held by the
+// configuration, so that it is fetched again the next time it is needed
+func (tconf *TokenConfig) ClearPin() {
+	if tconf.pin != nil {
+		tconf.pin.clear()
+	}
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPinSources(t *testing.T) {
+	dir := t.TempDir()
+	pinFile := filepath.Join(dir, "pins.yaml")
+	require.NoError(t, os.WriteFile(pinFile, []byte("filed: \"1111\"\n"), 0600))
+	t.Setenv("RELIC_TEST_PIN", "2222")
+	RegisterSecretLoader("pintest", func(name string) ([]byte, error) {
+		return []byte(name + "\n"), nil
+	})
+	static := "4444"
+	cfg := &Config{
+		PinFile: pinFile,
+		Tokens: map[string]*TokenConfig{
+			"filed":   {Pin: &static},
+			"env":     {PinSource: "env:RELIC_TEST_PIN"},
+			"command": {PinCommand: "echo 3333"},
+			"static":  {Pin: &static},
+			"secret":  {PinSource: "secret://pintest/5555"},
+			"prompt":  {},
+			"unset":   {PinSource: "env:RELIC_TEST_UNSET"},
+		},
+	}
+	require.NoError(t, cfg.Normalize(""))
+	for name, expected := range map[string]string{
+		"filed":   "1111",
+		"env":     "2222",
+		"command": "3333",
+		"static":  "4444",
+		"secret":  "5555",
+	} {
+		pin, ok, err := cfg.Tokens[name].GetPin()
+		require.NoError(t, err, name)
+		assert.True(t, ok, name)
+		assert.Equal(t, expected, pin, name)
+	}
+	_, ok, err := cfg.Tokens["prompt"].GetPin()
+	require.NoError(t, err)
+	assert.False(t, ok)
+	_, _, err = cfg.Tokens["unset"].GetPin()
+	assert.ErrorContains(t, err, "RELIC_TEST_UNSET is not set")
+
+	for _, tconf := range []*TokenConfig{
+		{PinSource: "env:X", Pin: &static},
+		{PinSource: "vault:x"},
+		{PinSource: "file:"},
+		{PinRefresh: -1},
+	} {
+		bad := &Config{Tokens: map[string]*TokenConfig{"bad": tconf}}
+		assert.Error(t, bad.Normalize(""))
+	}
+}
+
+func TestPinFetchedLazily(t *testing.T) {
+	t.Setenv("RELIC_TEST_PIN", "1111")
+	cfg := &Config{Tokens: map[string]*TokenConfig{
+		"cached":  {PinSource: "env:RELIC_TEST_PIN"},
+		"refresh": {PinSource: "env:RELIC_TEST_PIN", PinRefresh: 1},
+	}}
+	require.NoError(t, cfg.Normalize(""))
+	// nothing is read until the first use
+	t.Setenv("RELIC_TEST_PIN", "2222")
+	for _, name := range []string{"cached", "refresh"} {
+		pin, _, err := cfg.Tokens[name].GetPin()
+		require.NoError(t, err)
+		assert.Equal(t, "2222", pin)
+	}
+	t.Setenv("RELIC_TEST_PIN", "3333")
+	pin, _, _ := cfg.Tokens["cached"].GetPin()
+	assert.Equal(t, "2222", pin)
+	cfg.Tokens["cached"].ClearPin()
+	pin, _, _ = cfg.Tokens["cached"].GetPin()
+	assert.Equal(t, "3333", pin)
+	// the refreshing token picks up the change once the interval passes
+	cfg.Tokens["refresh"].pin.fetched = time.Now().Add(-2 * time.Second)
+	pin, _, _ = cfg.Tokens["refresh"].GetPin()
+	assert.Equal(t, "3333", pin)
+}
@@ -0,0 +1,146 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// PKCS11URI holds the token and object selectors from a RFC 7512 PKCS#11 URI
+type PKCS11URI struct {
+	Token        string
+	Manufacturer string
+	Model        string
+	Serial       string
+	SlotID       *uint
+	Object       string // Label of the key object
+	ID           []byte // CKA_ID of the key object
+	ModulePath   string // Path to the provider module
+}
+
+// ParsePKCS11URI parses a "pkcs11:" URI. Attributes that don't select a token
+// or object are rejected, as are PINs, which belong in the pin options
+// instead.
+func ParsePKCS11URI(s string) (*PKCS11URI, error) {
+	rest, ok := strings.CutPrefix(s, "pkcs11:")
+	if !ok {
+		return nil, errors.New("PKCS#11 URI must start with \"pkcs11:\"")
+	}
+	path, query, _ := strings.Cut(rest, "?")
+	u := new(PKCS11URI)
+	seen := make(map[string]bool)
+	for _, attr := range splitNonEmpty(path, ";") {
+		name, value, err := uriAttribute(attr)
+		if err != nil {
+			return nil, err
+		} else if seen[name] {
+			return nil, fmt.Errorf("PKCS#11 URI: duplicate attribute %q", name)
+		}
+		seen[name] = true
+		switch name {
+		case "token":
+			u.Token = value
+		case "manufacturer":
+			u.Manufacturer = value
+		case "model":
+			u.Model = value
+		case "serial":
+			u.Serial = value
+		case "slot-id":
+			slot, err := strconv.ParseUint(value, 10, 0)
+			if err != nil {
+				return nil, fmt.Errorf("PKCS#11 URI: invalid slot-id %q", value)
+			}
+			slotID := uint(slot)
+			u.SlotID = &slotID
+		case "object":
+			u.Object = value
+		case "id":
+			u.ID = []byte(value)
+		case "type":
+			// relic always looks up both halves of the key pair
+		default:
+			return nil, fmt.Errorf("PKCS#11 URI: unsupported attribute %q", name)
+		}
+	}
+	for _, attr := range splitNonEmpty(query, "&") {
+		name, value, err := uriAttribute(attr)
+		if err != nil {
+			return nil, err
+		}
+		switch name {
+		case "module-path":
+			u.ModulePath = value
+		case "pin-value", "pin-source":
+			return nil, errors.New("PKCS#11 URI: PINs must be configured with pin, pinfile or pincommand instead")
+		default:
+			return nil, fmt.Errorf("PKCS#11 URI: unsupported query attribute %q", name)
+		}
+	}
+	return u, nil
+}
+
+func splitNonEmpty(s, sep string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, sep)
+}
+
+func uriAttribute(attr string) (name, value string, err error) {
+	name, value, ok := strings.Cut(attr, "=")
+	if !ok {
+		return "", "", fmt.Errorf("PKCS#11 URI: malformed attribute %q", attr)
+	}
+	value, err = url.PathUnescape(value)
+	if err != nil {
+		return "", "", fmt.Errorf("PKCS#11 URI: attribute %q: %w", name, err)
+	}
+	return strings.ToLower(name), value, nil
+}
+
+// PKCS11URI returns the parsed URI option, or nil if it is not set
+func (tokenConf *TokenConfig) PKCS11URI() *PKCS11URI {
+	return tokenConf.uri
+}
+
+// normalizeSelectors parses the URI option and checks that it isn't combined
+// with other options selecting the same thing
+func (tokenConf *TokenConfig) normalizeSelectors() error {
+	if tokenConf.URI == "" {
+		return nil
+	}
+	if tokenConf.Label != "" || tokenConf.Serial != "" || tokenConf.Slot != nil {
+		return errors.New("uri can't be combined with label, serial or slot")
+	}
+	u, err := ParsePKCS11URI(tokenConf.URI)
+	if err != nil {
+		return err
+	}
+	if u.ModulePath != "" {
+		if tokenConf.Provider != "" && tokenConf.Provider != u.ModulePath {
+			return errors.New("uri module-path conflicts with provider")
+		}
+		tokenConf.Provider = u.ModulePath
+	}
+	tokenConf.uri = u
+	return nil
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestParsePKCS11URI(t *testing.T) {
+	u, err := ParsePKCS11URI("pkcs11:token=My%20Token;serial=0123;slot-id=3;object=signing;id=%01%ff;type=private?module-path=/usr/lib/softhsm/libsofthsm2.so")
+	require.NoError(t, err)
+	slot := uint(3)
+	assert.Equal(t, &PKCS11URI{
+		Token:      "My Token",
+		Serial:     "0123",
+		SlotID:     &slot,
+		Object:     "signing",
+		ID:         []byte{0x01, 0xff},
+		ModulePath: "/usr/lib/softhsm/libsofthsm2.so",
+	}, u)
+
+	u, err = ParsePKCS11URI("pkcs11:")
+	require.NoError(t, err)
+	assert.Equal(t, &PKCS11URI{}, u)
+
+	for _, bad := range []string{
+		"token=foo",
+		"pkcs11:token",
+		"pkcs11:token=a;token=b",
+		"pkcs11:slot-id=x",
+		"pkcs11:bogus=1",
+		"pkcs11:token=%zz",
+		"pkcs11:token=a?pin-value=1234",
+	} {
+		_, err := ParsePKCS11URI(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestTokenSelectors(t *testing.T) {
+	normalize := func(tokens string) (*Config, error) {
+		cfg := new(Config)
+		require.NoError(t, yaml.Unmarshal([]byte(tokens), cfg))
+		return cfg, cfg.Normalize("")
+	}
+	cfg, err := normalize("tokens:\n  hsm:\n    uri: pkcs11:token=a;object=k?module-path=/lib/p11.so\n")
+	require.NoError(t, err)
+	tokenConf := cfg.Tokens["hsm"]
+	assert.Equal(t, "/lib/p11.so", tokenConf.Provider)
+	assert.Equal(t, "k", tokenConf.PKCS11URI().Object)
+
+	cfg, err = normalize("tokens:\n  hsm:\n    provider: /lib/p11.so\n    slot: 0\n")
+	require.NoError(t, err)
+	require.NotNil(t, cfg.Tokens["hsm"].Slot)
+	assert.Equal(t, uint(0), *cfg.Tokens["hsm"].Slot)
+
+	for _, bad := range []string{
+		"tokens:\n  hsm:\n    uri: pkcs11:token=a\n    label: a\n",
+		"tokens:\n  hsm:\n    uri: pkcs11:token=a\n    slot: 1\n",
+		"tokens:\n  hsm:\n    uri: pkcs11:token=a?module-path=/lib/a.so\n    provider: /lib/b.so\n",
+	} {
+		_, err := normalize(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestTokenLoginMode(t *testing.T) {
+	normalize := func(tokens string) (*Config, error) {
+		cfg := new(Config)
+		require.NoError(t, yaml.Unmarshal([]byte(tokens), cfg))
+		return cfg, cfg.Normalize("")
+	}
+	cfg, err := normalize("tokens:\n  hsm:\n    provider: /lib/p11.so\n")
+	require.NoError(t, err)
+	assert.Equal(t, LoginSession, cfg.Tokens["hsm"].LoginMode)
+	assert.Equal(t, ReloginOnce, cfg.Tokens["hsm"].Relogin)
+
+	cfg, err = normalize("tokens:\n  hsm:\n    provider: /lib/p11.so\n    loginmode: context\n    relogin: never\n")
+	require.NoError(t, err)
+	assert.Equal(t, LoginContext, cfg.Tokens["hsm"].LoginMode)
+	assert.Equal(t, ReloginNever, cfg.Tokens["hsm"].Relogin)
+
+	_, err = normalize("tokens:\n  hsm:\n    provider: /lib/p11.so\n    loginmode: always\n")
+	assert.ErrorContains(t, err, "loginmode")
+	_, err = normalize("tokens:\n  hsm:\n    provider: /lib/p11.so\n    relogin: twice\n")
+	assert.ErrorContains(t, err, "relogin")
+}
@@ -0,0 +1,55 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// ProxyDirect is a proxy setting that connects straight to the server even if
+// a proxy is set in the environment
+const ProxyDirect = "direct"
+
+// ProxyFunc returns the proxy selector for a http.Transport that makes requests
+// on behalf of a config section. An empty setting uses HTTP_PROXY, HTTPS_PROXY
+// and NO_PROXY from the environment, "direct" uses no proxy, and anything else
+// is the URL of the proxy to send every request through.
+func ProxyFunc(setting string) (func(*http.Request) (*url.URL, error), error) {
+	switch setting {
+	case "":
+		return http.ProxyFromEnvironment, nil
+	case ProxyDirect:
+		return nil, nil
+	}
+	// don't put the URL in errors, it may have credentials in it
+	u, err := url.Parse(setting)
+	if err != nil {
+		return nil, errors.New("proxy is not a valid URL")
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("proxy scheme must be http, https or socks5, not %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, errors.New("proxy URL has no host")
+	}
+	return http.ProxyURL(u), nil
+}
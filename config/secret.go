@@ -0,0 +1,92 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// SecretPrefix marks a setting that refers to a secret store instead of a
+// file, in the form secret://<store>/<name>
+const SecretPrefix = "secret://"
+
+// SecretLoader fetches the named secret from a store
+type SecretLoader func(name string) ([]byte, error)
+
+var (
+	secretMu      sync.Mutex
+	secretLoaders = map[string]SecretLoader{"env": envSecret}
+)
+
+// RegisterSecretLoader adds a store that secret:// references can name. The
+// "env" store, which reads environment variables, is always available.
+func RegisterSecretLoader(store string, loader SecretLoader) {
+	secretMu.Lock()
+	defer secretMu.Unlock()
+	secretLoaders[store] = loader
+}
+
+// IsInline reports whether a key or certificate setting holds its value
+// directly or refers to a secret store, rather than naming a file
+func IsInline(value string) bool {
+	return strings.HasPrefix(strings.TrimSpace(value), "-----BEGIN") || strings.HasPrefix(value, SecretPrefix)
+}
+
+// ReadFileOrSecret returns the contents of a key or certificate setting. PEM
+// data is returned as-is, secret:// references are resolved using the
+// registered loaders, and anything else is read as a file.
+func ReadFileOrSecret(value string) ([]byte, error) {
+	switch {
+	case strings.HasPrefix(strings.TrimSpace(value), "-----BEGIN"):
+		return []byte(value), nil
+	case strings.HasPrefix(value, SecretPrefix):
+		return loadSecret(value)
+	default:
+		return os.ReadFile(value)
+	}
+}
+
+// loadSecret resolves a secret:// reference using the registered loaders
+func loadSecret(value string) ([]byte, error) {
+	store, name, _ := strings.Cut(strings.TrimPrefix(value, SecretPrefix), "/")
+	if store == "" || name == "" {
+		return nil, fmt.Errorf("invalid secret reference %q: expected %s<store>/<name>", value, SecretPrefix)
+	}
+	secretMu.Lock()
+	loader := secretLoaders[store]
+	secretMu.Unlock()
+	if loader == nil {
+		return nil, fmt.Errorf("invalid secret reference %q: unknown store %q", value, store)
+	}
+	blob, err := loader(name)
+	if err != nil {
+		return nil, fmt.Errorf("loading secret %q: %w", value, err)
+	}
+	return blob, nil
+}
+
+func envSecret(name string) ([]byte, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return nil, errors.New("environment variable is not set")
+	}
+	return []byte(value), nil
+}
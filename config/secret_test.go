@@ -0,0 +1,42 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadFileOrSecret(t *testing.T) {
+	const pem = "-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n"
+	path := filepath.Join(t.TempDir(), "cert.pem")
+	require.NoError(t, os.WriteFile(path, []byte(pem), 0600))
+	t.Setenv("RELIC_TEST_SECRET", pem)
+	RegisterSecretLoader("test", func(name string) ([]byte, error) {
+		if name == "tls/cert" {
+			return []byte(pem), nil
+		}
+		return nil, errors.New("not found")
+	})
+
+	for _, value := range []string{pem, path, "secret://env/RELIC_TEST_SECRET", "secret://test/tls/cert"} {
+		blob, err := ReadFileOrSecret(value)
+		require.NoError(t, err, value)
+		assert.Equal(t, pem, string(blob), value)
+	}
+	assert.True(t, IsInline(pem))
+	assert.True(t, IsInline("secret://env/X"))
+	assert.False(t, IsInline(path))
+
+	_, err := ReadFileOrSecret("secret://env/RELIC_TEST_UNSET")
+	assert.ErrorContains(t, err, "not set")
+	_, err = ReadFileOrSecret("secret://vault/x")
+	assert.ErrorContains(t, err, `unknown store "vault"`)
+	_, err = ReadFileOrSecret("secret://env")
+	assert.ErrorContains(t, err, "expected secret://<store>/<name>")
+	_, err = ReadFileOrSecret("secret://test/missing")
+	assert.ErrorContains(t, err, "not found")
+}
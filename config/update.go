@@ -0,0 +1,132 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SetKeyCertificate points the named key's x509certificate option at
+// certPath, both in memory and in the configuration file it was loaded from.
+// Other settings and comments in the file are kept, although blank lines and
+// indentation are normalized.
+func (config *Config) SetKeyCertificate(keyName, certPath string) error {
+	keyConf, err := config.GetKey(keyName)
+	if err != nil {
+		return err
+	}
+	fpath := config.path
+	if p := config.keyPaths[keyName]; p != "" {
+		fpath = p
+	}
+	if fpath == "" {
+		return errors.New("configuration was not loaded from a file")
+	}
+	data, err := os.ReadFile(fpath)
+	if err != nil {
+		return err
+	}
+	updated, err := setYAMLValue(data, certPath, "keys", keyName, "x509certificate")
+	if err != nil {
+		return fmt.Errorf("%s: %w", fpath, err)
+	}
+	if err := replaceFile(fpath, updated); err != nil {
+		return err
+	}
+	keyConf.X509Certificate = certPath
+	return nil
+}
+
+// setYAMLValue sets a scalar at the given mapping path in a YAML document,
+// creating the last element if it isn't already there.
+func setYAMLValue(data []byte, value string, path ...string) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return nil, errors.New("empty document")
+	}
+	node := doc.Content[0]
+	for i, name := range path {
+		if node.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("%s is not a mapping", name)
+		}
+		var next *yaml.Node
+		for j := 0; j+1 < len(node.Content); j += 2 {
+			if node.Content[j].Value == name {
+				next = node.Content[j+1]
+				break
+			}
+		}
+		if next == nil {
+			if i != len(path)-1 {
+				return nil, fmt.Errorf("%s is not defined", strings.Join(path[:i+1], "."))
+			}
+			next = &yaml.Node{Kind: yaml.ScalarNode}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: name}, next)
+		}
+		node = next
+	}
+	if node.Kind != yaml.ScalarNode {
+		return nil, fmt.Errorf("%s is not a scalar", path[len(path)-1])
+	}
+	node.SetString(value)
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// replaceFile writes data to path via a temporary file, keeping the original
+// file's permissions since the configuration may hold PINs.
+func replaceFile(path string, data []byte) error {
+	st, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err := f.Chmod(st.Mode().Perm()); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
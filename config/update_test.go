@@ -0,0 +1,48 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const updateConfig = `# signing keys
+tokens:
+  file:
+    type: file
+keys:
+  # the main key
+  rsa1:
+    token: file
+    keyfile: rsa1.key
+  ecdsa1:
+    token: file
+    x509certificate: old.crt
+`
+
+func TestSetKeyCertificate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "relic.yml")
+	require.NoError(t, os.WriteFile(path, []byte(updateConfig), 0600))
+	cfg, err := ReadFile(path)
+	require.NoError(t, err)
+
+	require.NoError(t, cfg.SetKeyCertificate("rsa1", "/etc/relic/rsa1.crt"))
+	require.NoError(t, cfg.SetKeyCertificate("ecdsa1", "new.crt"))
+	assert.Equal(t, "/etc/relic/rsa1.crt", cfg.Keys["rsa1"].X509Certificate)
+	assert.Error(t, cfg.SetKeyCertificate("missing", "x.crt"))
+
+	st, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), st.Mode().Perm())
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "# the main key")
+	reread, err := ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "/etc/relic/rsa1.crt", reread.Keys["rsa1"].X509Certificate)
+	assert.Equal(t, "rsa1.key", reread.Keys["rsa1"].KeyFile)
+	assert.Equal(t, "new.crt", reread.Keys["ecdsa1"].X509Certificate)
+}
@@ -85,6 +85,14 @@ $relic remote sign-pgp -u rsa2048 "packages/Release" -o "$signed/Release.inline"
 $verify_2048p "$signed/Release.inline"
 echo
 
+### APT release
+mkdir -p "$signed/dists"
+$client verify --cert "testkeys/ubuntu2012.pgp" "packages/Release"
+$relic remote sign -k rsa2048 -f "packages/Release" -o "$signed/dists/Release"
+$verify_2048p "$signed/dists/Release"
+$verify_2048p "$signed/dists/InRelease"
+echo
+
 ### JAR
 pkg="hello.jar"
 $relic remote sign -k rsa2048 -f "packages/$pkg" -o "$signed/$pkg"
@@ -177,7 +185,7 @@ echo
 ### PKG
 pkg="dummy.pkg"
 $relic remote sign -k rsa2048 -f "packages/$pkg" -o "$signed/$pkg"
-$relic remote sign -k rsa2048 -f "$signed/$pkg"
+$relic remote sign -k rsa2048 -f "$signed/$pkg" --replace
 $verify_2048x "$signed/$pkg"
 echo
 
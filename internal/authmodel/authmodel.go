@@ -2,11 +2,15 @@ package authmodel
 
 import (
 	"context"
+	"errors"
 	"net/http"
 
 	"github.com/mind-security/relic/v8/config"
+	"github.com/mind-security/relic/v8/internal/httperror"
+	"github.com/mind-security/relic/v8/internal/realip"
 	"github.com/mind-security/relic/v8/internal/zhttp"
 	"github.com/mind-security/relic/v8/lib/audit"
+	"github.com/mind-security/relic/v8/lib/x509tools"
 )
 
 type ctxKey int
@@ -23,6 +27,8 @@ type UserInfo interface {
 	// AuditContext amends an audit record with the authenticated user's name
 	// and other relevant details
 	AuditContext(info *audit.Info)
+	// Identity describes the user as reported by /whoami
+	Identity() *Identity
 }
 
 // New creates an authenticator based on the provided server configuration
@@ -30,8 +36,10 @@ func New(conf *config.Config) (Authenticator, error) {
 	switch {
 	case conf.Server.PolicyURL != "":
 		return newPolicyAuthenticator(conf)
+	case conf.Server.OIDC != nil:
+		return newOIDCAuthenticator(conf)
 	default:
-		return &CertificateAuth{Config: conf}, nil
+		return newCertificateAuth(conf)
 	}
 }
 
@@ -60,6 +68,41 @@ func Middleware(a Authenticator) func(http.Handler) http.Handler {
 	}
 }
 
+// Whoami authenticates the request and describes the caller. Credentials that
+// are missing or not recognized produce an unauthenticated Identity explaining
+// why, along with the certificate fingerprint that was looked up, instead of
+// an error.
+func Whoami(a Authenticator, req *http.Request) (*Identity, error) {
+	info, err := a.Authenticate(req)
+	if err == nil {
+		ident := info.Identity()
+		ident.Authenticated = true
+		return ident, nil
+	}
+	var ptr *httperror.Problem
+	var problem httperror.Problem
+	switch {
+	case errors.As(err, &ptr):
+		problem = *ptr
+	case errors.As(err, &problem):
+	default:
+		return nil, err
+	}
+	if problem.Status >= 500 {
+		return nil, err
+	}
+	ident := &Identity{Error: problem.Detail}
+	if ident.Error == "" {
+		ident.Error = problem.Error()
+	}
+	if peerCerts, err := realip.PeerCertificates(req); err == nil && len(peerCerts) != 0 {
+		ident.Fingerprint = fingerprint(peerCerts[0])
+		ident.Subject = formatSubject(peerCerts[0])
+		ident.SpiffeID = x509tools.SpiffeID(peerCerts[0])
+	}
+	return ident, nil
+}
+
 // RequestInfo returns information about the calling user
 func RequestInfo(req *http.Request) UserInfo {
 	info, ok := req.Context().Value(ctxKeyUserInfo).(UserInfo)
@@ -0,0 +1,55 @@
+package authmodel
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mind-security/relic/v8/config"
+)
+
+func TestWhoami(t *testing.T) {
+	known, unknown := clientCert(t), clientCert(t)
+	conf := &config.Config{
+		Server: &config.ServerConfig{},
+		Clients: map[string]*config.ClientConfig{
+			fingerprint(known): {Nickname: "builder", Roles: []string{"release"}},
+		},
+	}
+	auth, err := New(conf)
+	require.NoError(t, err)
+	whoami := func(cert *x509.Certificate) *Identity {
+		req := httptest.NewRequest("GET", "/whoami", nil)
+		if cert != nil {
+			req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+		}
+		ident, err := Whoami(auth, req)
+		require.NoError(t, err)
+		return ident
+	}
+
+	assert.Equal(t, &Identity{
+		Authenticated: true,
+		Name:          "builder",
+		Fingerprint:   fingerprint(known),
+		Roles:         []string{"release"},
+	}, whoami(known))
+
+	// the fingerprint that was looked up is reported so it can be compared
+	// with the configuration
+	ident := whoami(unknown)
+	assert.False(t, ident.Authenticated)
+	assert.Equal(t, fingerprint(unknown), ident.Fingerprint)
+	assert.Equal(t, "/CN=client", ident.Subject)
+	assert.Contains(t, ident.Error, "not recognized")
+	assert.Empty(t, ident.Roles)
+
+	ident = whoami(nil)
+	assert.False(t, ident.Authenticated)
+	assert.Empty(t, ident.Fingerprint)
+	assert.Contains(t, ident.Error, "must be provided")
+}
@@ -4,6 +4,7 @@ import (
 	"crypto/sha256"
 	"crypto/x509"
 	"encoding/hex"
+	"fmt"
 	"net/http"
 
 	"github.com/mind-security/relic/v8/config"
@@ -20,6 +21,18 @@ import (
 // configured CA.
 type CertificateAuth struct {
 	Config *config.Config
+
+	dynamic dynamicClients
+}
+
+func newCertificateAuth(conf *config.Config) (*CertificateAuth, error) {
+	auth := &CertificateAuth{Config: conf}
+	if dir := conf.Server.ClientsDir; dir != "" {
+		if err := auth.dynamic.load(dir); err != nil {
+			return nil, fmt.Errorf("loading clients: %w", err)
+		}
+	}
+	return auth, nil
 }
 
 func (a *CertificateAuth) Authenticate(req *http.Request) (UserInfo, error) {
@@ -32,21 +45,52 @@ func (a *CertificateAuth) Authenticate(req *http.Request) (UserInfo, error) {
 	cert := peerCerts[0]
 	encoded := fingerprint(cert)
 	var useDN bool
+	var spiffeID string
 	var saved error
-	client := a.Config.Clients[encoded]
-	if client == nil {
-		for _, c2 := range a.Config.Clients {
+	clientSets := []map[string]*config.ClientConfig{a.Config.Clients, a.dynamicClients()}
+	var client *config.ClientConfig
+	for _, clients := range clientSets {
+		if client = clients[encoded]; client != nil {
+			break
+		}
+	}
+	if id := x509tools.SpiffeID(cert); client == nil && id != "" {
+		// SVIDs are looked up by their SPIFFE ID, then checked against that
+		// client's trust bundle
+		for _, clients := range clientSets {
+			c2 := clients[id]
+			if c2 == nil {
+				continue
+			}
 			match, err := c2.Match(peerCerts)
 			if match {
 				client = c2
-				useDN = true
+				spiffeID = id
 				break
 			} else if err != nil {
-				// preserve any potentially interesting validation errors
 				saved = err
 			}
 		}
 	}
+	if client == nil {
+	search:
+		for _, clients := range clientSets {
+			for _, c2 := range clients {
+				if c2.SpiffeID() != "" {
+					continue
+				}
+				match, err := c2.Match(peerCerts)
+				if match {
+					client = c2
+					useDN = true
+					break search
+				} else if err != nil {
+					// preserve any potentially interesting validation errors
+					saved = err
+				}
+			}
+		}
+	}
 	if client == nil {
 		zhttp.AppendAccessLog(req, func(e *zerolog.Event) {
 			e.Str("fingerprint", encoded)
@@ -59,10 +103,15 @@ func (a *CertificateAuth) Authenticate(req *http.Request) (UserInfo, error) {
 	}
 
 	user := &CertificateInfo{
-		Name:  client.Nickname,
-		Roles: client.Roles,
+		Name:        client.Nickname,
+		Fingerprint: encoded,
+		SpiffeID:    spiffeID,
+		Roles:       client.Roles,
+		DefaultKey:  client.DefaultKey,
 	}
-	if user.Name == "" {
+	if user.Name == "" && spiffeID != "" {
+		user.Name = spiffeID
+	} else if user.Name == "" {
 		user.Name = encoded[:12]
 	}
 	if useDN {
@@ -74,14 +123,20 @@ func (a *CertificateAuth) Authenticate(req *http.Request) (UserInfo, error) {
 		if user.Subject != "" {
 			e.Str("subject", user.Subject)
 		}
+		if user.SpiffeID != "" {
+			e.Str("spiffe_id", user.SpiffeID)
+		}
 	})
 	return user, nil
 }
 
 type CertificateInfo struct {
-	Name    string
-	Subject string
-	Roles   []string
+	Name        string
+	Fingerprint string
+	Subject     string
+	SpiffeID    string
+	Roles       []string
+	DefaultKey  string
 }
 
 func (c *CertificateInfo) AuditContext(info *audit.Info) {
@@ -89,11 +144,30 @@ func (c *CertificateInfo) AuditContext(info *audit.Info) {
 	if c.Subject != "" {
 		info.Attributes["client.dn"] = c.Subject
 	}
+	if c.SpiffeID != "" {
+		info.Attributes["client.spiffe_id"] = c.SpiffeID
+	}
+}
+
+func (c *CertificateInfo) Identity() *Identity {
+	return &Identity{
+		Name:        c.Name,
+		Fingerprint: c.Fingerprint,
+		Subject:     c.Subject,
+		SpiffeID:    c.SpiffeID,
+		Roles:       c.Roles,
+		DefaultKey:  c.DefaultKey,
+	}
 }
 
 func (c *CertificateInfo) Allowed(keyConf *config.KeyConfig) bool {
+	return hasRole(keyConf, c.Roles)
+}
+
+// hasRole checks whether any of the client's roles grants access to the key
+func hasRole(keyConf *config.KeyConfig, roles []string) bool {
 	for _, keyRole := range keyConf.Roles {
-		for _, clientRole := range c.Roles {
+		for _, clientRole := range roles {
 			if keyRole == clientRole {
 				return true
 			}
@@ -0,0 +1,122 @@
+package authmodel
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mind-security/relic/v8/config"
+	"github.com/mind-security/relic/v8/internal/httperror"
+)
+
+func newSpiffeCA(t *testing.T) (*x509.Certificate, crypto.Signer) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "trust domain"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert, key
+}
+
+// issueSVID creates an SVID with a fresh key, as on each rotation
+func issueSVID(t *testing.T, ca *x509.Certificate, caKey crypto.Signer, id string) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	uri, err := url.Parse(id)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		URIs:         []*url.URL{uri},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, key.Public(), caKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert
+}
+
+func TestSpiffeClient(t *testing.T) {
+	ca, caKey := newSpiffeCA(t)
+	otherCA, otherKey := newSpiffeCA(t)
+	bundle := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw}))
+	conf := &config.Config{
+		Server: &config.ServerConfig{},
+		Clients: map[string]*config.ClientConfig{
+			"spiffe://Example.org/ci/release": {Nickname: "release", Roles: []string{"release"}, Certificate: bundle},
+			"spiffe://example.org/ci/test":    {Roles: []string{"test"}, Certificate: bundle},
+		},
+	}
+	require.NoError(t, conf.Normalize(""))
+	auth, err := New(conf)
+	require.NoError(t, err)
+	authenticate := func(cert *x509.Certificate) (*CertificateInfo, error) {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+		info, err := auth.Authenticate(req)
+		if err != nil {
+			return nil, err
+		}
+		return info.(*CertificateInfo), nil
+	}
+
+	// matched by ID across rotations
+	for i := 0; i < 2; i++ {
+		info, err := authenticate(issueSVID(t, ca, caKey, "spiffe://example.org/ci/release"))
+		require.NoError(t, err)
+		assert.Equal(t, "release", info.Name)
+		assert.Equal(t, "spiffe://example.org/ci/release", info.SpiffeID)
+		assert.Equal(t, []string{"release"}, info.Roles)
+	}
+	info, err := authenticate(issueSVID(t, ca, caKey, "spiffe://example.org/ci/test"))
+	require.NoError(t, err)
+	assert.Equal(t, "spiffe://example.org/ci/test", info.Name)
+
+	// an unknown ID, or a known ID from the wrong trust bundle, is refused
+	for _, cert := range []*x509.Certificate{
+		issueSVID(t, ca, caKey, "spiffe://example.org/ci/other"),
+		issueSVID(t, otherCA, otherKey, "spiffe://example.org/ci/release"),
+	} {
+		_, err = authenticate(cert)
+		assert.True(t, errors.Is(err, httperror.ErrCertificateNotRecognized), "got %v", err)
+	}
+}
+
+func TestSpiffeClientConfig(t *testing.T) {
+	ca, _ := newSpiffeCA(t)
+	bundle := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw}))
+	normalize := func(id string, client *config.ClientConfig) error {
+		conf := &config.Config{Clients: map[string]*config.ClientConfig{id: client}}
+		return conf.Normalize("")
+	}
+	assert.NoError(t, normalize("spiffe://example.org/svc", &config.ClientConfig{Certificate: bundle}))
+	assert.ErrorContains(t, normalize("spiffe://example.org/svc", &config.ClientConfig{}), "trust bundle")
+	assert.Error(t, normalize("spiffe://example.org/svc/", &config.ClientConfig{Certificate: bundle}))
+	assert.Error(t, normalize("spiffe://example.org:443/svc", &config.ClientConfig{Certificate: bundle}))
+}
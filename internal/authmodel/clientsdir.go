@@ -0,0 +1,51 @@
+package authmodel
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/mind-security/relic/v8/config"
+)
+
+// dynamicClients holds the clients loaded from the server's clients
+// directory. The directory is re-read by whichever request first notices that
+// the reload interval has passed, while other requests keep using the previous
+// set.
+type dynamicClients struct {
+	mu      sync.Mutex
+	loaded  atomic.Int64 // time of the last load attempt, in unix nanoseconds
+	clients atomic.Pointer[map[string]*config.ClientConfig]
+}
+
+// load reads the clients directory, keeping the previous set if it fails
+func (d *dynamicClients) load(dir string) error {
+	d.loaded.Store(time.Now().UnixNano())
+	clients, err := config.LoadClientsDir(dir)
+	if err != nil {
+		return err
+	}
+	d.clients.Store(&clients)
+	return nil
+}
+
+func (a *CertificateAuth) dynamicClients() map[string]*config.ClientConfig {
+	sconf := a.Config.Server
+	if sconf == nil || sconf.ClientsDir == "" {
+		return nil
+	}
+	d := &a.dynamic
+	interval := time.Duration(sconf.ClientsReloadInterval) * time.Second
+	if time.Since(time.Unix(0, d.loaded.Load())) >= interval && d.mu.TryLock() {
+		if err := d.load(sconf.ClientsDir); err != nil {
+			log.Error().Err(err).Str("dir", sconf.ClientsDir).Msg("failed to reload clients, keeping the previous set")
+		}
+		d.mu.Unlock()
+	}
+	if clients := d.clients.Load(); clients != nil {
+		return *clients
+	}
+	return nil
+}
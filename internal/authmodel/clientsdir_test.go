@@ -0,0 +1,94 @@
+package authmodel
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mind-security/relic/v8/config"
+	"github.com/mind-security/relic/v8/internal/httperror"
+)
+
+func clientCert(t *testing.T) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert
+}
+
+func TestClientsDir(t *testing.T) {
+	static, rotated := clientCert(t), clientCert(t)
+	dir := t.TempDir()
+	conf := &config.Config{
+		Server: &config.ServerConfig{ClientsDir: dir, ClientsReloadInterval: 3600},
+		Clients: map[string]*config.ClientConfig{
+			fingerprint(static): {Nickname: "static", Roles: []string{"a"}},
+		},
+	}
+	auth, err := New(conf)
+	require.NoError(t, err)
+	certAuth := auth.(*CertificateAuth)
+	authenticate := func(cert *x509.Certificate) (UserInfo, error) {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+		return auth.Authenticate(req)
+	}
+	expire := func() { certAuth.dynamic.loaded.Store(0) }
+
+	info, err := authenticate(static)
+	require.NoError(t, err)
+	assert.Equal(t, "static", info.(*CertificateInfo).Name)
+	_, err = authenticate(rotated)
+	assert.Equal(t, httperror.ErrCertificateNotRecognized, err)
+
+	// new fingerprints show up after the reload interval
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ci.yml"), []byte(fingerprint(rotated)+":\n  nickname: ci\n  roles: [b]\n"), 0600))
+	_, err = authenticate(rotated)
+	assert.Equal(t, httperror.ErrCertificateNotRecognized, err, "not reloaded before the interval")
+	expire()
+	info, err = authenticate(rotated)
+	require.NoError(t, err)
+	assert.Equal(t, &CertificateInfo{Name: "ci", Fingerprint: fingerprint(rotated), Roles: []string{"b"}}, info)
+	assert.True(t, info.Allowed(&config.KeyConfig{Roles: []string{"b"}}))
+
+	// a broken file keeps the previous set
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "bad.yml"), []byte("nope: ["), 0600))
+	expire()
+	_, err = authenticate(rotated)
+	require.NoError(t, err)
+
+	// removed fingerprints stop working
+	require.NoError(t, os.Remove(filepath.Join(dir, "bad.yml")))
+	require.NoError(t, os.Remove(filepath.Join(dir, "ci.yml")))
+	expire()
+	_, err = authenticate(rotated)
+	assert.Equal(t, httperror.ErrCertificateNotRecognized, err)
+	_, err = authenticate(static)
+	require.NoError(t, err)
+
+	// startup fails if the directory can't be read
+	conf.Server.ClientsDir = filepath.Join(dir, "missing")
+	_, err = New(conf)
+	assert.Error(t, err)
+}
@@ -13,6 +13,20 @@ type Metadata struct {
 	Auth  []AuthMetadata `json:"auth"`
 }
 
+// Identity is the server's view of a caller, as returned by /whoami
+type Identity struct {
+	Authenticated bool     `json:"authenticated"`
+	Name          string   `json:"name,omitempty"`
+	Fingerprint   string   `json:"fingerprint,omitempty"`
+	Subject       string   `json:"subject,omitempty"`
+	Issuer        string   `json:"issuer,omitempty"`
+	SpiffeID      string   `json:"spiffe_id,omitempty"`
+	Roles         []string `json:"roles,omitempty"`
+	DefaultKey    string   `json:"default_key,omitempty"`
+	// why the caller wasn't authenticated
+	Error string `json:"error,omitempty"`
+}
+
 type AuthMetadata struct {
 	Type AuthType `json:"type"`
 	// azure AD
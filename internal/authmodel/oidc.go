@@ -0,0 +1,347 @@
+package authmodel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/jwt"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/singleflight"
+
+	"github.com/mind-security/relic/v8/config"
+	"github.com/mind-security/relic/v8/internal/httperror"
+	"github.com/mind-security/relic/v8/internal/zhttp"
+	"github.com/mind-security/relic/v8/lib/audit"
+)
+
+const (
+	// refetch keys at least this often so rotated keys are picked up
+	jwksMaxAge = time.Hour
+	// but don't refetch more often than this when an unknown key is seen
+	jwksMinAge = time.Minute
+)
+
+// only asymmetric algorithms, since the keys come from the issuer
+var oidcAlgorithms = map[string]bool{
+	string(jose.RS256): true, string(jose.RS384): true, string(jose.RS512): true,
+	string(jose.PS256): true, string(jose.PS384): true, string(jose.PS512): true,
+	string(jose.ES256): true, string(jose.ES384): true, string(jose.ES512): true,
+	string(jose.EdDSA): true,
+}
+
+// OIDCAuth accepts bearer JWTs from an OpenID Connect issuer, mapping their
+// claims to a configured client. Requests without a token are passed to
+// certificate authentication.
+type OIDCAuth struct {
+	certs *CertificateAuth
+	conf  *config.OIDCConfig
+	cli   *http.Client
+
+	mu      sync.Mutex
+	keys    *jose.JSONWebKeySet
+	fetched time.Time
+
+	// refresh ensures one fetch at a time; jwksURL is only used inside it
+	refresh singleflight.Group
+	jwksURL string
+}
+
+func newOIDCAuthenticator(conf *config.Config) (*OIDCAuth, error) {
+	oconf := conf.Server.OIDC
+	if oconf.Issuer == "" || oconf.Audience == "" {
+		return nil, errors.New("oidc: issuer and audience are required")
+	}
+	certs, err := newCertificateAuth(conf)
+	if err != nil {
+		return nil, err
+	}
+	return &OIDCAuth{
+		certs:   certs,
+		conf:    oconf,
+		cli:     &http.Client{Timeout: 30 * time.Second},
+		jwksURL: oconf.JWKSURL,
+	}, nil
+}
+
+func (a *OIDCAuth) Authenticate(req *http.Request) (UserInfo, error) {
+	token := bearerToken(req)
+	if token == "" {
+		return a.certs.Authenticate(req)
+	}
+	claims, err := a.verify(req.Context(), token)
+	if err != nil {
+		zhttp.AppendAccessLog(req, func(e *zerolog.Event) {
+			e.AnErr("token_error", err)
+		})
+		return nil, httperror.TokenAuthorizationError(http.StatusUnauthorized, []string{err.Error()})
+	}
+	name, client := a.findClient(claims)
+	if client == nil {
+		zhttp.AppendAccessLog(req, func(e *zerolog.Event) {
+			e.Interface("sub", claims["sub"])
+		})
+		return nil, httperror.TokenAuthorizationError(http.StatusForbidden, []string{"token does not match any client"})
+	}
+	user := &TokenInfo{
+		Name:       client.Nickname,
+		Roles:      client.Roles,
+		DefaultKey: client.DefaultKey,
+	}
+	user.Subject, _ = claims["sub"].(string)
+	user.Issuer, _ = claims["iss"].(string)
+	if user.Name == "" {
+		user.Name = name
+	}
+	zhttp.AppendAccessLog(req, func(e *zerolog.Event) {
+		e.Str("user", user.Name)
+		e.Str("sub", user.Subject)
+	})
+	return user, nil
+}
+
+// verify checks the token's signature, issuer, audience and lifetime, and
+// returns all of its claims
+func (a *OIDCAuth) verify(ctx context.Context, token string) (map[string]interface{}, error) {
+	parsed, err := jwt.ParseSigned(token)
+	if err != nil {
+		return nil, errors.New("token is missing or not well-formed")
+	} else if len(parsed.Headers) != 1 {
+		return nil, errors.New("token must have exactly one signature")
+	}
+	header := parsed.Headers[0]
+	if !oidcAlgorithms[header.Algorithm] {
+		return nil, fmt.Errorf("token algorithm %q is not allowed", header.Algorithm)
+	}
+	key, err := a.key(ctx, header.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	var std jwt.Claims
+	var claims map[string]interface{}
+	if err := parsed.Claims(key, &std, &claims); err != nil {
+		return nil, errors.New("token signature is invalid")
+	}
+	if std.Expiry == nil {
+		return nil, errors.New("token has no expiry")
+	}
+	expected := jwt.Expected{
+		Issuer:   a.conf.Issuer,
+		Audience: jwt.Audience{a.conf.Audience},
+		Time:     time.Now(),
+	}
+	if err := std.ValidateWithLeeway(expected, time.Duration(a.conf.Leeway)*time.Second); err != nil {
+		switch err {
+		case jwt.ErrExpired:
+			return nil, errors.New("token is expired")
+		case jwt.ErrNotValidYet:
+			return nil, errors.New("token is not yet valid")
+		case jwt.ErrInvalidIssuer:
+			return nil, errors.New("token issuer is not accepted")
+		case jwt.ErrInvalidAudience:
+			return nil, errors.New("token audience is not accepted")
+		}
+		return nil, err
+	}
+	return claims, nil
+}
+
+// key returns the issuer's key with the given ID, fetching the key set if it
+// is stale or doesn't have that key yet
+func (a *OIDCAuth) key(ctx context.Context, kid string) (*jose.JSONWebKey, error) {
+	key, stale := a.cachedKey(kid)
+	if stale {
+		// fetch without holding the lock so a slow issuer doesn't block
+		// requests, and share the fetch between concurrent callers
+		_, err, _ := a.refresh.Do("", func() (interface{}, error) {
+			if _, stale := a.cachedKey(kid); !stale {
+				// refreshed while waiting
+				return nil, nil
+			}
+			return nil, a.refreshKeys(ctx)
+		})
+		if err != nil {
+			return nil, err
+		}
+		key, _ = a.cachedKey(kid)
+	}
+	if key == nil {
+		return nil, fmt.Errorf("token signing key %q is not known", kid)
+	}
+	return key, nil
+}
+
+// cachedKey returns the key with the given ID from the current key set, and
+// whether the set should be fetched again
+func (a *OIDCAuth) cachedKey(kid string) (*jose.JSONWebKey, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	age := time.Since(a.fetched)
+	key := findKey(a.keys, kid)
+	return key, a.keys == nil || age > jwksMaxAge || (key == nil && age > jwksMinAge)
+}
+
+// refreshKeys fetches the key set and replaces the current one. If there
+// already is one then failures are only logged.
+func (a *OIDCAuth) refreshKeys(ctx context.Context) error {
+	keys, err := a.fetchKeys(ctx)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err != nil {
+		if a.keys == nil {
+			return err
+		}
+		// keep using the old keys
+		log.Warn().Err(err).Str("issuer", a.conf.Issuer).Msg("failed to refresh OIDC signing keys")
+	} else {
+		a.keys = keys
+	}
+	a.fetched = time.Now()
+	return nil
+}
+
+func findKey(keys *jose.JSONWebKeySet, kid string) *jose.JSONWebKey {
+	if keys == nil {
+		return nil
+	}
+	if kid == "" && len(keys.Keys) == 1 {
+		return &keys.Keys[0]
+	}
+	for _, key := range keys.Key(kid) {
+		if key.Use == "" || key.Use == "sig" {
+			key := key
+			return &key
+		}
+	}
+	return nil
+}
+
+func (a *OIDCAuth) fetchKeys(ctx context.Context) (*jose.JSONWebKeySet, error) {
+	if a.jwksURL == "" {
+		var discovery struct {
+			Issuer  string `json:"issuer"`
+			JWKSURI string `json:"jwks_uri"`
+		}
+		discoveryURL := strings.TrimSuffix(a.conf.Issuer, "/") + "/.well-known/openid-configuration"
+		if err := a.getJSON(ctx, discoveryURL, &discovery); err != nil {
+			return nil, err
+		}
+		if discovery.JWKSURI == "" {
+			return nil, errors.New("oidc: discovery document has no jwks_uri")
+		}
+		a.jwksURL = discovery.JWKSURI
+	}
+	keys := new(jose.JSONWebKeySet)
+	if err := a.getJSON(ctx, a.jwksURL, keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (a *OIDCAuth) getJSON(ctx context.Context, url string, dest interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := a.cli.Do(req)
+	if err != nil {
+		return fmt.Errorf("oidc: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("oidc: %s: %w", url, httperror.FromResponse(resp))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(dest); err != nil {
+		return fmt.Errorf("oidc: %s: %w", url, err)
+	}
+	return nil
+}
+
+// findClient returns the first client, by name, whose claims all match the
+// token's
+func (a *OIDCAuth) findClient(claims map[string]interface{}) (string, *config.ClientConfig) {
+	for _, clients := range []map[string]*config.ClientConfig{a.certs.Config.Clients, a.certs.dynamicClients()} {
+		names := make([]string, 0, len(clients))
+		for name, client := range clients {
+			if len(client.Claims) != 0 {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if claimsMatch(clients[name].Claims, claims) {
+				return name, clients[name]
+			}
+		}
+	}
+	return "", nil
+}
+
+func claimsMatch(want map[string]string, claims map[string]interface{}) bool {
+	for name, value := range want {
+		if !claimMatches(claims[name], value) {
+			return false
+		}
+	}
+	return true
+}
+
+// claimMatches compares a claim to a configured value. For list claims such
+// as groups, any member may match.
+func claimMatches(claim interface{}, want string) bool {
+	switch v := claim.(type) {
+	case nil:
+		return false
+	case string:
+		return v == want
+	case []interface{}:
+		for _, item := range v {
+			if claimMatches(item, want) {
+				return true
+			}
+		}
+		return false
+	default:
+		return fmt.Sprint(v) == want
+	}
+}
+
+type TokenInfo struct {
+	Name       string
+	Subject    string
+	Issuer     string
+	Roles      []string
+	DefaultKey string
+}
+
+// Allowed checks whether the named key is visible to the current user
+func (i *TokenInfo) Allowed(keyConf *config.KeyConfig) bool {
+	return hasRole(keyConf, i.Roles)
+}
+
+// Identity describes the user as reported by /whoami
+func (i *TokenInfo) Identity() *Identity {
+	return &Identity{
+		Name:       i.Name,
+		Subject:    i.Subject,
+		Issuer:     i.Issuer,
+		Roles:      i.Roles,
+		DefaultKey: i.DefaultKey,
+	}
+}
+
+// AuditContext amends an audit record with the authenticated user's name
+// and other relevant details
+func (i *TokenInfo) AuditContext(info *audit.Info) {
+	info.Attributes["client.name"] = i.Name
+	info.Attributes["client.sub"] = i.Subject
+	info.Attributes["client.iss"] = i.Issuer
+}
@@ -0,0 +1,173 @@
+package authmodel
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mind-security/relic/v8/config"
+	"github.com/mind-security/relic/v8/internal/httperror"
+)
+
+type testIssuer struct {
+	*httptest.Server
+	key     *ecdsa.PrivateKey
+	fetches atomic.Int32
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	iss := &testIssuer{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{"issuer": iss.URL, "jwks_uri": iss.URL + "/keys"})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		iss.fetches.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		_ = json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+			{Key: key.Public(), KeyID: "k1", Algorithm: string(jose.ES256), Use: "sig"},
+		}})
+	})
+	iss.Server = httptest.NewServer(mux)
+	t.Cleanup(iss.Close)
+	return iss
+}
+
+func (iss *testIssuer) token(t *testing.T, key *ecdsa.PrivateKey, claims jwt.Claims, extra map[string]interface{}) string {
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: key},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", "k1"))
+	require.NoError(t, err)
+	token, err := jwt.Signed(signer).Claims(claims).Claims(extra).CompactSerialize()
+	require.NoError(t, err)
+	return token
+}
+
+func TestOIDC(t *testing.T) {
+	iss := newTestIssuer(t)
+	cert := clientCert(t)
+	conf := &config.Config{
+		Server: &config.ServerConfig{OIDC: &config.OIDCConfig{Issuer: iss.URL, Audience: "relic", Leeway: 60}},
+		Clients: map[string]*config.ClientConfig{
+			fingerprint(cert): {Nickname: "cert", Roles: []string{"a"}},
+			"ci": {
+				Nickname: "ci-bot",
+				Roles:    []string{"b"},
+				Claims:   map[string]string{"sub": "repo:relic", "groups": "release"},
+			},
+		},
+	}
+	auth, err := New(conf)
+	require.NoError(t, err)
+	authenticate := func(token string) (UserInfo, error) {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		return auth.Authenticate(req)
+	}
+	now := time.Now()
+	valid := jwt.Claims{
+		Issuer:   iss.URL,
+		Subject:  "repo:relic",
+		Audience: jwt.Audience{"relic"},
+		Expiry:   jwt.NewNumericDate(now.Add(time.Hour)),
+	}
+	groups := map[string]interface{}{"groups": []string{"dev", "release"}}
+
+	info, err := authenticate(iss.token(t, iss.key, valid, groups))
+	require.NoError(t, err)
+	assert.Equal(t, &TokenInfo{Name: "ci-bot", Subject: "repo:relic", Issuer: iss.URL, Roles: []string{"b"}}, info)
+	assert.True(t, info.Allowed(&config.KeyConfig{Roles: []string{"b"}}))
+	assert.False(t, info.Allowed(&config.KeyConfig{Roles: []string{"a"}}))
+
+	// no token falls back to the client certificate
+	info, err = authenticate("")
+	require.NoError(t, err)
+	assert.Equal(t, "cert", info.(*CertificateInfo).Name)
+
+	assertStatus := func(code int, token string) {
+		t.Helper()
+		_, err := authenticate(token)
+		var problem httperror.Problem
+		require.ErrorAs(t, err, &problem)
+		assert.Equal(t, code, problem.Status, problem.Detail)
+	}
+	expired := valid
+	expired.Expiry = jwt.NewNumericDate(now.Add(-time.Hour))
+	assertStatus(http.StatusUnauthorized, iss.token(t, iss.key, expired, groups))
+	noExpiry := valid
+	noExpiry.Expiry = nil
+	assertStatus(http.StatusUnauthorized, iss.token(t, iss.key, noExpiry, groups))
+	wrongIssuer := valid
+	wrongIssuer.Issuer = "https://elsewhere.example"
+	assertStatus(http.StatusUnauthorized, iss.token(t, iss.key, wrongIssuer, groups))
+	wrongAudience := valid
+	wrongAudience.Audience = jwt.Audience{"other"}
+	assertStatus(http.StatusUnauthorized, iss.token(t, iss.key, wrongAudience, groups))
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	assertStatus(http.StatusUnauthorized, iss.token(t, otherKey, valid, groups))
+	assertStatus(http.StatusUnauthorized, "garbage")
+	// valid token, but the claims don't match any client
+	assertStatus(http.StatusForbidden, iss.token(t, iss.key, valid, map[string]interface{}{"groups": "dev"}))
+}
+
+func TestClaimMatches(t *testing.T) {
+	assert.True(t, claimMatches("x", "x"))
+	assert.False(t, claimMatches("x", "y"))
+	assert.True(t, claimMatches([]interface{}{"y", "x"}, "x"))
+	assert.False(t, claimMatches([]interface{}{"y"}, "x"))
+	assert.True(t, claimMatches(true, "true"))
+	assert.True(t, claimMatches(float64(42), "42"))
+	assert.False(t, claimMatches(nil, ""))
+}
+
+func TestOIDCKeyFetch(t *testing.T) {
+	iss := newTestIssuer(t)
+	auth := &OIDCAuth{conf: &config.OIDCConfig{Issuer: iss.URL}, cli: iss.Client()}
+
+	// concurrent callers share one fetch
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			key, err := auth.key(context.Background(), "k1")
+			assert.NoError(t, err)
+			assert.NotNil(t, key)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), iss.fetches.Load())
+
+	// unknown keys don't refetch until the minimum age has passed
+	_, err := auth.key(context.Background(), "k2")
+	assert.ErrorContains(t, err, "not known")
+	assert.Equal(t, int32(1), iss.fetches.Load())
+
+	// a stale set is fetched again
+	auth.mu.Lock()
+	auth.fetched = time.Now().Add(-2 * jwksMaxAge)
+	auth.mu.Unlock()
+	_, err = auth.key(context.Background(), "k2")
+	assert.ErrorContains(t, err, "not known")
+	assert.Equal(t, int32(2), iss.fetches.Load())
+}
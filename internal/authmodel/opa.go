@@ -146,6 +146,15 @@ func (i *PolicyInfo) Allowed(keyConf *config.KeyConfig) bool {
 	return false
 }
 
+// Identity describes the user as reported by /whoami
+func (i *PolicyInfo) Identity() *Identity {
+	ident := &Identity{Subject: i.Subject, Roles: i.Roles}
+	if v, ok := i.Claims["iss"].(string); ok {
+		ident.Issuer = v
+	}
+	return ident
+}
+
 // AuditContext amends an audit record with the authenticated user's name
 // and other relevant details
 func (i *PolicyInfo) AuditContext(info *audit.Info) {
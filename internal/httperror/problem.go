@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/mind-security/relic/v8/internal/zhttp"
@@ -22,6 +23,7 @@ type Problem struct {
 	// error-specific
 	Param  string   `json:"param,omitempty"`
 	Errors []string `json:"errors,omitempty"`
+	Code   string   `json:"code,omitempty"`
 }
 
 func (e Problem) Error() string {
@@ -55,6 +57,14 @@ func (e Problem) Temporary() bool {
 const (
 	ProblemBase     = "https://relic.sas.com/"
 	ProblemKeyUsage = ProblemBase + "key-usage"
+	ProblemNoCert   = ProblemBase + "certificate-not-defined"
+
+	ProblemKeyNotFound      = ProblemBase + "key-not-found"
+	ProblemTokenBusy        = ProblemBase + "token-busy"
+	ProblemTokenUnavailable = ProblemBase + "token-unavailable"
+	ProblemTimestampFailed  = ProblemBase + "timestamp-failed"
+	ProblemTokenError       = ProblemBase + "token-error"
+	ProblemUploadBusy       = ProblemBase + "upload-busy"
 )
 
 var (
@@ -77,6 +87,31 @@ var (
 		Type:   ProblemBase + "token-required",
 		Detail: "A bearer token or client certificate must be provided to use this service",
 	}
+	ErrFormatNotAllowed = &Problem{
+		Status: http.StatusForbidden,
+		Type:   ProblemBase + "format-not-allowed",
+		Detail: "This key may not be used to sign this type of file",
+	}
+	ErrRoleNotAllowed = &Problem{
+		Status: http.StatusForbidden,
+		Type:   ProblemBase + "role-not-allowed",
+		Detail: "Your roles do not allow signing this type of file",
+	}
+	ErrTokenBusy = &Problem{
+		Status: http.StatusTooManyRequests,
+		Type:   ProblemTokenBusy,
+		Detail: "Too many requests are using this key's token, try again later",
+	}
+	ErrTokenUnavailable = &Problem{
+		Status: http.StatusServiceUnavailable,
+		Type:   ProblemTokenUnavailable,
+		Detail: "The token holding this key is not responding, try again later",
+	}
+	ErrKeyNotFound = &Problem{
+		Status: http.StatusNotFound,
+		Type:   ProblemKeyNotFound,
+		Detail: "The key is configured but was not found in its token",
+	}
 	ErrUnknownSignatureType = &Problem{
 		Status: http.StatusBadRequest,
 		Type:   ProblemBase + "unknown-signature-type",
@@ -87,6 +122,26 @@ var (
 		Type:   ProblemBase + "unknown-digest-algorithm",
 		Detail: "Unknown digest algorithm specified",
 	}
+	ErrRequestTooLarge = &Problem{
+		Status: http.StatusRequestEntityTooLarge,
+		Type:   ProblemBase + "request-too-large",
+		Detail: "The file is larger than the server allows for this signature type",
+	}
+	ErrUploadNotFound = &Problem{
+		Status: http.StatusNotFound,
+		Type:   ProblemBase + "upload-not-found",
+		Detail: "The upload does not exist or has expired",
+	}
+	ErrUploadBusy = &Problem{
+		Status: http.StatusConflict,
+		Type:   ProblemUploadBusy,
+		Detail: "A chunk is already in progress for this upload, try again later",
+	}
+	ErrTooManyUploads = &Problem{
+		Status: http.StatusTooManyRequests,
+		Type:   ProblemBase + "too-many-uploads",
+		Detail: "Too many unfinished uploads, finish or delete one first",
+	}
 )
 
 func MissingParameterError(param string) Problem {
@@ -98,6 +153,15 @@ func MissingParameterError(param string) Problem {
 	}
 }
 
+func InvalidParameterError(param string, err error) Problem {
+	return Problem{
+		Status: http.StatusBadRequest,
+		Type:   ProblemBase + "invalid-parameter",
+		Detail: "Parameter " + param + " is invalid: " + err.Error(),
+		Param:  param,
+	}
+}
+
 func BadParameterError(err error) Problem {
 	return Problem{
 		Status: http.StatusBadRequest,
@@ -106,6 +170,14 @@ func BadParameterError(err error) Problem {
 	}
 }
 
+func InvalidInputError(err error) Problem {
+	return Problem{
+		Status: http.StatusBadRequest,
+		Type:   ProblemBase + "invalid-input",
+		Detail: "The file is not valid for this signature type: " + err.Error(),
+	}
+}
+
 func TokenAuthorizationError(code int, errors []string) Problem {
 	p := Problem{
 		Status: code,
@@ -123,7 +195,25 @@ func TokenAuthorizationError(code int, errors []string) Problem {
 func NoCertificateError(certType string) Problem {
 	return Problem{
 		Status: http.StatusBadRequest,
-		Type:   ProblemBase + "certificate-not-defined",
+		Type:   ProblemNoCert,
 		Detail: "No certificate of type \"" + certType + "\" is defined for this key",
 	}
 }
+
+// NoTrustRootsError means the server has no roots of the requested type to
+// offer verifiers
+func NoTrustRootsError(certType string) Problem {
+	return Problem{
+		Status: http.StatusNotFound,
+		Type:   ProblemNoCert,
+		Detail: "No trusted certificates of type \"" + certType + "\" are available from this server",
+	}
+}
+
+func UploadOffsetError(offset int64) Problem {
+	return Problem{
+		Status: http.StatusConflict,
+		Type:   ProblemBase + "upload-offset-mismatch",
+		Detail: "Upload is at offset " + strconv.FormatInt(offset, 10),
+	}
+}
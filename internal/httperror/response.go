@@ -51,6 +51,7 @@ func FromResponse(resp *http.Response) error {
 func statusIsTemporary(code int) bool {
 	switch code {
 	case http.StatusGatewayTimeout,
+		http.StatusTooManyRequests,
 		http.StatusBadGateway,
 		http.StatusServiceUnavailable,
 		http.StatusInsufficientStorage,
@@ -0,0 +1,183 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package otlpexport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	maxQueuedLogs = 4096
+	maxLogBatch   = 512
+	logInterval   = 5 * time.Second
+)
+
+type logsRequest struct {
+	ResourceLogs []resourceLogs `json:"resourceLogs"`
+}
+
+type resourceLogs struct {
+	Resource  resource    `json:"resource"`
+	ScopeLogs []scopeLogs `json:"scopeLogs"`
+}
+
+type scopeLogs struct {
+	Scope      scope       `json:"scope"`
+	LogRecords []logRecord `json:"logRecords"`
+}
+
+type logRecord struct {
+	TimeUnixNano         string     `json:"timeUnixNano"`
+	ObservedTimeUnixNano string     `json:"observedTimeUnixNano"`
+	SeverityNumber       int        `json:"severityNumber,omitempty"`
+	SeverityText         string     `json:"severityText,omitempty"`
+	Body                 anyValue   `json:"body"`
+	Attributes           []keyValue `json:"attributes,omitempty"`
+}
+
+var severities = map[string]int{
+	zerolog.LevelTraceValue: 1,
+	zerolog.LevelDebugValue: 5,
+	zerolog.LevelInfoValue:  9,
+	zerolog.LevelWarnValue:  13,
+	zerolog.LevelErrorValue: 17,
+	zerolog.LevelFatalValue: 21,
+	zerolog.LevelPanicValue: 24,
+}
+
+// Write implements io.Writer so the exporter can be attached to a zerolog
+// logger. Each call is expected to hold exactly one JSON-encoded log event.
+// Events are queued and sent in batches; if the queue is full they are
+// dropped rather than blocking the caller.
+func (e *Exporter) Write(p []byte) (int, error) {
+	if e == nil || e.logsURL == "" {
+		return len(p), nil
+	}
+	rec, err := parseLogEvent(p, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	select {
+	case e.logs <- rec:
+	default:
+	}
+	return len(p), nil
+}
+
+func parseLogEvent(p []byte, now time.Time) (logRecord, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(p, &fields); err != nil {
+		return logRecord{}, fmt.Errorf("parsing log event: %w", err)
+	}
+	rec := logRecord{ObservedTimeUnixNano: u64(uint64(now.UnixNano()))}
+	rec.TimeUnixNano = rec.ObservedTimeUnixNano
+	if s, ok := fields[zerolog.TimestampFieldName].(string); ok {
+		if t, err := time.Parse(zerolog.TimeFieldFormat, s); err == nil {
+			rec.TimeUnixNano = u64(uint64(t.UnixNano()))
+		}
+		delete(fields, zerolog.TimestampFieldName)
+	}
+	if s, ok := fields[zerolog.LevelFieldName].(string); ok {
+		rec.SeverityText = s
+		rec.SeverityNumber = severities[s]
+		delete(fields, zerolog.LevelFieldName)
+	}
+	msg, _ := fields[zerolog.MessageFieldName].(string)
+	delete(fields, zerolog.MessageFieldName)
+	rec.Body = anyValue{StringValue: &msg}
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		rec.Attributes = append(rec.Attributes, keyValue{Key: k, Value: toAnyValue(fields[k])})
+	}
+	return rec, nil
+}
+
+func toAnyValue(v interface{}) anyValue {
+	switch v := v.(type) {
+	case string:
+		return anyValue{StringValue: &v}
+	case bool:
+		return anyValue{BoolValue: &v}
+	case float64:
+		if v == float64(int64(v)) {
+			s := strconv.FormatInt(int64(v), 10)
+			return anyValue{IntValue: &s}
+		}
+		return anyValue{DoubleValue: &v}
+	default:
+		// flatten nested objects and arrays to their JSON text
+		blob, _ := json.Marshal(v)
+		s := string(blob)
+		return anyValue{StringValue: &s}
+	}
+}
+
+func (e *Exporter) logsLoop() {
+	defer e.wg.Done()
+	t := time.NewTicker(logInterval)
+	defer t.Stop()
+	var batch []logRecord
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := e.post(context.Background(), e.logsURL, e.logsRequest(batch)); err != nil {
+			fmt.Fprintln(os.Stderr, "error: exporting OTLP logs:", err)
+		}
+		batch = nil
+	}
+	for {
+		select {
+		case rec := <-e.logs:
+			batch = append(batch, rec)
+			if len(batch) >= maxLogBatch {
+				flush()
+			}
+		case <-t.C:
+			flush()
+		case <-e.stop:
+			// drain whatever is still queued
+			for len(e.logs) > 0 {
+				batch = append(batch, <-e.logs)
+			}
+			flush()
+			return
+		}
+	}
+}
+
+func (e *Exporter) logsRequest(records []logRecord) *logsRequest {
+	return &logsRequest{ResourceLogs: []resourceLogs{{
+		Resource: e.resource,
+		ScopeLogs: []scopeLogs{{
+			Scope:      relicScope(),
+			LogRecords: records,
+		}},
+	}}}
+}
@@ -0,0 +1,203 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package otlpexport
+
+import (
+	"math"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+const temporalityCumulative = 2
+
+type metricsRequest struct {
+	ResourceMetrics []resourceMetrics `json:"resourceMetrics"`
+}
+
+type resourceMetrics struct {
+	Resource     resource       `json:"resource"`
+	ScopeMetrics []scopeMetrics `json:"scopeMetrics"`
+}
+
+type scopeMetrics struct {
+	Scope   scope    `json:"scope"`
+	Metrics []metric `json:"metrics"`
+}
+
+type metric struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description,omitempty"`
+	Gauge       *gauge     `json:"gauge,omitempty"`
+	Sum         *sum       `json:"sum,omitempty"`
+	Histogram   *histogram `json:"histogram,omitempty"`
+	Summary     *summary   `json:"summary,omitempty"`
+}
+
+type gauge struct {
+	DataPoints []numberPoint `json:"dataPoints"`
+}
+
+type sum struct {
+	DataPoints             []numberPoint `json:"dataPoints"`
+	AggregationTemporality int           `json:"aggregationTemporality"`
+	IsMonotonic            bool          `json:"isMonotonic"`
+}
+
+type histogram struct {
+	DataPoints             []histogramPoint `json:"dataPoints"`
+	AggregationTemporality int              `json:"aggregationTemporality"`
+}
+
+type summary struct {
+	DataPoints []summaryPoint `json:"dataPoints"`
+}
+
+type numberPoint struct {
+	Attributes        []keyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      string     `json:"timeUnixNano"`
+	AsDouble          float64    `json:"asDouble"`
+}
+
+type histogramPoint struct {
+	Attributes        []keyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano"`
+	TimeUnixNano      string     `json:"timeUnixNano"`
+	Count             string     `json:"count"`
+	Sum               float64    `json:"sum"`
+	BucketCounts      []string   `json:"bucketCounts"`
+	ExplicitBounds    []float64  `json:"explicitBounds"`
+}
+
+type summaryPoint struct {
+	Attributes        []keyValue      `json:"attributes,omitempty"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	TimeUnixNano      string          `json:"timeUnixNano"`
+	Count             string          `json:"count"`
+	Sum               float64         `json:"sum"`
+	QuantileValues    []quantileValue `json:"quantileValues"`
+}
+
+type quantileValue struct {
+	Quantile float64 `json:"quantile"`
+	Value    float64 `json:"value"`
+}
+
+func (e *Exporter) metricsRequest(families []*dto.MetricFamily, now time.Time) *metricsRequest {
+	start := u64(uint64(e.start.UnixNano()))
+	ts := u64(uint64(now.UnixNano()))
+	var metrics []metric
+	for _, fam := range families {
+		m := metric{Name: fam.GetName(), Description: fam.GetHelp()}
+		for _, pm := range fam.Metric {
+			attrs := labelsToAttributes(pm.Label)
+			switch fam.GetType() {
+			case dto.MetricType_COUNTER:
+				if m.Sum == nil {
+					m.Sum = &sum{AggregationTemporality: temporalityCumulative, IsMonotonic: true}
+				}
+				m.Sum.DataPoints = append(m.Sum.DataPoints, numberPoint{
+					Attributes:        attrs,
+					StartTimeUnixNano: start,
+					TimeUnixNano:      ts,
+					AsDouble:          pm.GetCounter().GetValue(),
+				})
+			case dto.MetricType_GAUGE, dto.MetricType_UNTYPED:
+				if m.Gauge == nil {
+					m.Gauge = new(gauge)
+				}
+				v := pm.GetGauge().GetValue()
+				if fam.GetType() == dto.MetricType_UNTYPED {
+					v = pm.GetUntyped().GetValue()
+				}
+				m.Gauge.DataPoints = append(m.Gauge.DataPoints, numberPoint{
+					Attributes:   attrs,
+					TimeUnixNano: ts,
+					AsDouble:     v,
+				})
+			case dto.MetricType_HISTOGRAM, dto.MetricType_GAUGE_HISTOGRAM:
+				if m.Histogram == nil {
+					m.Histogram = &histogram{AggregationTemporality: temporalityCumulative}
+				}
+				m.Histogram.DataPoints = append(m.Histogram.DataPoints, convertHistogram(pm.GetHistogram(), attrs, start, ts))
+			case dto.MetricType_SUMMARY:
+				if m.Summary == nil {
+					m.Summary = new(summary)
+				}
+				s := pm.GetSummary()
+				p := summaryPoint{
+					Attributes:        attrs,
+					StartTimeUnixNano: start,
+					TimeUnixNano:      ts,
+					Count:             u64(s.GetSampleCount()),
+					Sum:               s.GetSampleSum(),
+				}
+				for _, q := range s.Quantile {
+					p.QuantileValues = append(p.QuantileValues, quantileValue{Quantile: q.GetQuantile(), Value: q.GetValue()})
+				}
+				m.Summary.DataPoints = append(m.Summary.DataPoints, p)
+			}
+		}
+		if m.Sum != nil || m.Gauge != nil || m.Histogram != nil || m.Summary != nil {
+			metrics = append(metrics, m)
+		}
+	}
+	return &metricsRequest{ResourceMetrics: []resourceMetrics{{
+		Resource: e.resource,
+		ScopeMetrics: []scopeMetrics{{
+			Scope:   relicScope(),
+			Metrics: metrics,
+		}},
+	}}}
+}
+
+// convertHistogram turns cumulative Prometheus buckets into OTLP's per-bucket
+// counts, with a final overflow bucket for everything above the last bound
+func convertHistogram(h *dto.Histogram, attrs []keyValue, start, ts string) histogramPoint {
+	p := histogramPoint{
+		Attributes:        attrs,
+		StartTimeUnixNano: start,
+		TimeUnixNano:      ts,
+		Count:             u64(h.GetSampleCount()),
+		Sum:               h.GetSampleSum(),
+		BucketCounts:      []string{},
+		ExplicitBounds:    []float64{},
+	}
+	var prev uint64
+	for _, b := range h.Bucket {
+		if math.IsInf(b.GetUpperBound(), 1) {
+			continue
+		}
+		p.ExplicitBounds = append(p.ExplicitBounds, b.GetUpperBound())
+		p.BucketCounts = append(p.BucketCounts, u64(b.GetCumulativeCount()-prev))
+		prev = b.GetCumulativeCount()
+	}
+	p.BucketCounts = append(p.BucketCounts, u64(h.GetSampleCount()-prev))
+	return p
+}
+
+func labelsToAttributes(labels []*dto.LabelPair) []keyValue {
+	if len(labels) == 0 {
+		return nil
+	}
+	attrs := make([]keyValue, len(labels))
+	for i, l := range labels {
+		attrs[i] = stringKV(l.GetName(), l.GetValue())
+	}
+	return attrs
+}
@@ -0,0 +1,99 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package otlpexport
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mind-security/relic/v8/config"
+)
+
+// Subset of the OTLP protobuf schema in its canonical JSON mapping. 64-bit
+// integers are encoded as strings, per the protobuf JSON rules.
+
+type anyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+type keyValue struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+type resource struct {
+	Attributes []keyValue `json:"attributes"`
+}
+
+type scope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+func stringKV(k, v string) keyValue {
+	return keyValue{Key: k, Value: anyValue{StringValue: &v}}
+}
+
+func u64(v uint64) string {
+	return strconv.FormatUint(v, 10)
+}
+
+func relicScope() scope {
+	return scope{Name: scopeName, Version: config.Version}
+}
+
+// newResource describes this process using OTEL_SERVICE_NAME and
+// OTEL_RESOURCE_ATTRIBUTES
+func newResource(getenv func(string) string) (resource, error) {
+	attrs := map[string]string{
+		"service.name":    "relic",
+		"service.version": config.Version,
+	}
+	for _, pair := range strings.Split(getenv("OTEL_RESOURCE_ATTRIBUTES"), ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok {
+			return resource{}, fmt.Errorf("OTEL_RESOURCE_ATTRIBUTES: invalid attribute %q", pair)
+		}
+		v, err := url.QueryUnescape(strings.TrimSpace(v))
+		if err != nil {
+			return resource{}, fmt.Errorf("OTEL_RESOURCE_ATTRIBUTES: %w", err)
+		}
+		attrs[strings.TrimSpace(k)] = v
+	}
+	if name := getenv("OTEL_SERVICE_NAME"); name != "" {
+		attrs["service.name"] = name
+	}
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var res resource
+	for _, k := range keys {
+		res.Attributes = append(res.Attributes, stringKV(k, attrs[k]))
+	}
+	return res, nil
+}
@@ -0,0 +1,271 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package otlpexport pushes the server's Prometheus metrics and structured
+// logs to an OpenTelemetry collector using OTLP/HTTP with JSON encoding. It is
+// configured entirely through the standard OTEL_* environment variables and
+// does nothing if no OTLP endpoint is set.
+package otlpexport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/mind-security/relic/v8/config"
+)
+
+const (
+	defaultInterval = 60 * time.Second
+	defaultTimeout  = 10 * time.Second
+	scopeName       = "github.com/mind-security/relic"
+)
+
+// Exporter periodically sends metrics and batches of log records to an OTLP
+// collector.
+type Exporter struct {
+	metricsURL string
+	logsURL    string
+	headers    http.Header
+	interval   time.Duration
+	resource   resource
+	gatherer   prometheus.Gatherer
+	cli        *http.Client
+	start      time.Time
+
+	logs    chan logRecord
+	stop    chan struct{}
+	wg      sync.WaitGroup
+	closeMu sync.Once
+}
+
+// Options override parts of the environment-derived configuration.
+type Options struct {
+	// Gatherer to read metrics from. Defaults to prometheus.DefaultGatherer
+	Gatherer prometheus.Gatherer
+	// Getenv is used to read configuration. Defaults to os.Getenv
+	Getenv func(string) string
+}
+
+// New configures an exporter from the standard OTEL_* environment variables.
+// If no OTLP endpoint is configured then nil is returned, and all methods of a
+// nil Exporter are no-ops.
+func New(opts Options) (*Exporter, error) {
+	getenv := opts.Getenv
+	if getenv == nil {
+		getenv = os.Getenv
+	}
+	if p := getenv("OTEL_EXPORTER_OTLP_PROTOCOL"); p != "" && p != "http/json" {
+		return nil, fmt.Errorf("OTEL_EXPORTER_OTLP_PROTOCOL: unsupported protocol %q, only http/json is supported", p)
+	}
+	base := getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	metricsURL, err := signalURL(base, getenv("OTEL_EXPORTER_OTLP_METRICS_ENDPOINT"), "v1/metrics")
+	if err != nil {
+		return nil, err
+	}
+	logsURL, err := signalURL(base, getenv("OTEL_EXPORTER_OTLP_LOGS_ENDPOINT"), "v1/logs")
+	if err != nil {
+		return nil, err
+	}
+	if getenv("OTEL_METRICS_EXPORTER") == "none" {
+		metricsURL = ""
+	}
+	if getenv("OTEL_LOGS_EXPORTER") == "none" {
+		logsURL = ""
+	}
+	if metricsURL == "" && logsURL == "" {
+		return nil, nil
+	}
+	headers, err := parseHeaders(getenv("OTEL_EXPORTER_OTLP_HEADERS"))
+	if err != nil {
+		return nil, fmt.Errorf("OTEL_EXPORTER_OTLP_HEADERS: %w", err)
+	}
+	interval, err := envMillis(getenv, "OTEL_METRIC_EXPORT_INTERVAL", defaultInterval)
+	if err != nil {
+		return nil, err
+	}
+	timeout, err := envMillis(getenv, "OTEL_EXPORTER_OTLP_TIMEOUT", defaultTimeout)
+	if err != nil {
+		return nil, err
+	}
+	res, err := newResource(getenv)
+	if err != nil {
+		return nil, err
+	}
+	gatherer := opts.Gatherer
+	if gatherer == nil {
+		gatherer = prometheus.DefaultGatherer
+	}
+	return &Exporter{
+		metricsURL: metricsURL,
+		logsURL:    logsURL,
+		headers:    headers,
+		interval:   interval,
+		resource:   res,
+		gatherer:   gatherer,
+		cli:        &http.Client{Timeout: timeout},
+		start:      time.Now(),
+		logs:       make(chan logRecord, maxQueuedLogs),
+		stop:       make(chan struct{}),
+	}, nil
+}
+
+// Start begins periodic export in the background
+func (e *Exporter) Start() {
+	if e == nil {
+		return
+	}
+	if e.metricsURL != "" {
+		e.wg.Add(1)
+		go e.metricsLoop()
+	}
+	if e.logsURL != "" {
+		e.wg.Add(1)
+		go e.logsLoop()
+	}
+}
+
+// Close stops the background exporters after flushing a final round of
+// metrics and any queued log records
+func (e *Exporter) Close() error {
+	if e == nil {
+		return nil
+	}
+	e.closeMu.Do(func() { close(e.stop) })
+	e.wg.Wait()
+	return nil
+}
+
+// ExportMetrics gathers and sends one round of metrics
+func (e *Exporter) ExportMetrics(ctx context.Context) error {
+	if e == nil || e.metricsURL == "" {
+		return nil
+	}
+	families, err := e.gatherer.Gather()
+	if err != nil {
+		return err
+	}
+	return e.post(ctx, e.metricsURL, e.metricsRequest(families, time.Now()))
+}
+
+func (e *Exporter) metricsLoop() {
+	defer e.wg.Done()
+	t := time.NewTicker(e.interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+		case <-e.stop:
+			e.exportMetricsLogged()
+			return
+		}
+		e.exportMetricsLogged()
+	}
+}
+
+func (e *Exporter) exportMetricsLogged() {
+	if err := e.ExportMetrics(context.Background()); err != nil {
+		// don't route this through the logger, it may be what is failing
+		fmt.Fprintln(os.Stderr, "error: exporting OTLP metrics:", err)
+	}
+}
+
+func (e *Exporter) post(ctx context.Context, dest string, body interface{}) error {
+	blob, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, dest, bytes.NewReader(blob))
+	if err != nil {
+		return err
+	}
+	for k, v := range e.headers {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", config.UserAgent)
+	resp, err := e.cli.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("POST %s: %s", dest, resp.Status)
+	}
+	return nil
+}
+
+func signalURL(base, specific, suffix string) (string, error) {
+	if specific != "" {
+		// signal-specific endpoints are used as-is
+		if _, err := url.Parse(specific); err != nil {
+			return "", err
+		}
+		return specific, nil
+	}
+	if base == "" {
+		return "", nil
+	}
+	if _, err := url.Parse(base); err != nil {
+		return "", fmt.Errorf("OTEL_EXPORTER_OTLP_ENDPOINT: %w", err)
+	}
+	return strings.TrimSuffix(base, "/") + "/" + suffix, nil
+}
+
+func parseHeaders(v string) (http.Header, error) {
+	h := make(http.Header)
+	for _, pair := range strings.Split(v, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		k, val, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid header %q", pair)
+		}
+		k, err := url.QueryUnescape(strings.TrimSpace(k))
+		if err != nil {
+			return nil, err
+		}
+		val, err = url.QueryUnescape(strings.TrimSpace(val))
+		if err != nil {
+			return nil, err
+		}
+		h.Set(k, val)
+	}
+	return h, nil
+}
+
+func envMillis(getenv func(string) string, name string, def time.Duration) (time.Duration, error) {
+	v := getenv(name)
+	if v == "" {
+		return def, nil
+	}
+	ms, err := strconv.ParseUint(v, 10, 32)
+	if err != nil || ms == 0 {
+		return 0, fmt.Errorf("%s: invalid value %q", name, v)
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
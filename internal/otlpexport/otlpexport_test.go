@@ -0,0 +1,191 @@
+package otlpexport
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// receiver is an in-memory OTLP/HTTP collector
+type receiver struct {
+	mu      sync.Mutex
+	metrics []metricsRequest
+	logs    []logsRequest
+	headers http.Header
+}
+
+func (r *receiver) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	blob, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.headers = req.Header.Clone()
+	var err error
+	switch req.URL.Path {
+	case "/v1/metrics":
+		var m metricsRequest
+		err = json.Unmarshal(blob, &m)
+		r.metrics = append(r.metrics, m)
+	case "/v1/logs":
+		var l logsRequest
+		err = json.Unmarshal(blob, &l)
+		r.logs = append(r.logs, l)
+	default:
+		http.NotFound(rw, req)
+		return
+	}
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+	}
+}
+
+func newTestExporter(t *testing.T, env map[string]string, reg prometheus.Gatherer) (*Exporter, *receiver) {
+	recv := new(receiver)
+	srv := httptest.NewServer(recv)
+	t.Cleanup(srv.Close)
+	if _, ok := env["OTEL_EXPORTER_OTLP_ENDPOINT"]; !ok {
+		env["OTEL_EXPORTER_OTLP_ENDPOINT"] = srv.URL
+	}
+	e, err := New(Options{
+		Gatherer: reg,
+		Getenv:   func(k string) string { return env[k] },
+	})
+	require.NoError(t, err)
+	return e, recv
+}
+
+func findMetric(t *testing.T, req metricsRequest, name string) metric {
+	require.Len(t, req.ResourceMetrics, 1)
+	require.Len(t, req.ResourceMetrics[0].ScopeMetrics, 1)
+	for _, m := range req.ResourceMetrics[0].ScopeMetrics[0].Metrics {
+		if m.Name == name {
+			return m
+		}
+	}
+	t.Fatalf("metric %s not exported", name)
+	return metric{}
+}
+
+func attrMap(kvs []keyValue) map[string]string {
+	m := make(map[string]string)
+	for _, kv := range kvs {
+		if kv.Value.StringValue != nil {
+			m[kv.Key] = *kv.Value.StringValue
+		} else if kv.Value.IntValue != nil {
+			m[kv.Key] = *kv.Value.IntValue
+		}
+	}
+	return m
+}
+
+func TestDisabled(t *testing.T) {
+	e, err := New(Options{Getenv: func(string) string { return "" }})
+	require.NoError(t, err)
+	assert.Nil(t, e)
+	// methods on a nil exporter are no-ops
+	e.Start()
+	assert.NoError(t, e.ExportMetrics(context.Background()))
+	n, err := e.Write([]byte(`{}`))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.NoError(t, e.Close())
+}
+
+func TestMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	ops := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "token_operation_seconds",
+		Help:    "A histogram of latencies for token operations",
+		Buckets: []float64{.1, 1},
+	}, []string{"token", "op"})
+	responses := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "token_responses",
+	}, []string{"token", "op", "code"})
+	health := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "token_check_sequential_errors",
+	}, []string{"token"})
+	reg.MustRegister(ops, responses, health)
+	ops.WithLabelValues("hsm", "sign").Observe(0.05)
+	ops.WithLabelValues("hsm", "sign").Observe(0.5)
+	ops.WithLabelValues("hsm", "sign").Observe(5)
+	responses.WithLabelValues("hsm", "sign", "200").Add(3)
+	health.WithLabelValues("hsm").Set(2)
+
+	e, recv := newTestExporter(t, map[string]string{
+		"OTEL_SERVICE_NAME":           "relic-test",
+		"OTEL_EXPORTER_OTLP_HEADERS":  "x-api-key=s%3Dcret",
+		"OTEL_RESOURCE_ATTRIBUTES":    "deployment.environment=prod",
+		"OTEL_METRIC_EXPORT_INTERVAL": "3600000",
+	}, reg)
+	e.Start()
+	require.NoError(t, e.Close())
+
+	recv.mu.Lock()
+	defer recv.mu.Unlock()
+	require.Len(t, recv.metrics, 1)
+	assert.Equal(t, "s=cret", recv.headers.Get("X-Api-Key"))
+	req := recv.metrics[0]
+	res := attrMap(req.ResourceMetrics[0].Resource.Attributes)
+	assert.Equal(t, "relic-test", res["service.name"])
+	assert.Equal(t, "prod", res["deployment.environment"])
+
+	m := findMetric(t, req, "token_operation_seconds")
+	require.NotNil(t, m.Histogram)
+	require.Len(t, m.Histogram.DataPoints, 1)
+	hp := m.Histogram.DataPoints[0]
+	assert.Equal(t, map[string]string{"token": "hsm", "op": "sign"}, attrMap(hp.Attributes))
+	assert.Equal(t, "3", hp.Count)
+	assert.Equal(t, []float64{.1, 1}, hp.ExplicitBounds)
+	assert.Equal(t, []string{"1", "1", "1"}, hp.BucketCounts)
+
+	m = findMetric(t, req, "token_responses")
+	require.NotNil(t, m.Sum)
+	assert.True(t, m.Sum.IsMonotonic)
+	require.Len(t, m.Sum.DataPoints, 1)
+	assert.Equal(t, map[string]string{"token": "hsm", "op": "sign", "code": "200"}, attrMap(m.Sum.DataPoints[0].Attributes))
+	assert.Equal(t, 3.0, m.Sum.DataPoints[0].AsDouble)
+
+	m = findMetric(t, req, "token_check_sequential_errors")
+	require.NotNil(t, m.Gauge)
+	assert.Equal(t, 2.0, m.Gauge.DataPoints[0].AsDouble)
+}
+
+func TestLogs(t *testing.T) {
+	e, recv := newTestExporter(t, map[string]string{
+		"OTEL_METRICS_EXPORTER": "none",
+	}, prometheus.NewRegistry())
+	e.Start()
+	logger := zerolog.New(e).With().Timestamp().Logger()
+	logger.Info().Str("key", "mykey").Int("status", 200).Msg("signed package")
+	logger.Error().Msg("token health check failed")
+	require.NoError(t, e.Close())
+
+	recv.mu.Lock()
+	defer recv.mu.Unlock()
+	assert.Empty(t, recv.metrics)
+	require.Len(t, recv.logs, 1)
+	records := recv.logs[0].ResourceLogs[0].ScopeLogs[0].LogRecords
+	require.Len(t, records, 2)
+	assert.Equal(t, "signed package", *records[0].Body.StringValue)
+	assert.Equal(t, 9, records[0].SeverityNumber)
+	assert.Equal(t, map[string]string{"key": "mykey", "status": "200"}, attrMap(records[0].Attributes))
+	assert.Equal(t, 17, records[1].SeverityNumber)
+}
+
+func TestBadProtocol(t *testing.T) {
+	_, err := New(Options{Getenv: func(k string) string {
+		return map[string]string{
+			"OTEL_EXPORTER_OTLP_ENDPOINT": "http://127.0.0.1:4318",
+			"OTEL_EXPORTER_OTLP_PROTOCOL": "grpc",
+		}[k]
+	}})
+	assert.Error(t, err)
+}
@@ -1,10 +1,21 @@
 package realip
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"io"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -61,3 +72,57 @@ func TestTrusted(t *testing.T) {
 		})
 	}
 }
+
+// Requests multiplexed over HTTP/2 connections must each see the certificate
+// of the client that opened their own connection.
+func TestPeerCertificatesHTTP2(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		certs, err := PeerCertificates(req)
+		if err != nil || len(certs) == 0 {
+			http.Error(rw, "no certificate", http.StatusForbidden)
+			return
+		}
+		fmt.Fprintf(rw, "%d %s", req.ProtoMajor, certs[0].Subject.CommonName)
+	}))
+	srv.EnableHTTP2 = true
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	srv.StartTLS()
+	defer srv.Close()
+
+	names := []string{"alice", "bob"}
+	var wg sync.WaitGroup
+	for _, name := range names {
+		cert := selfSigned(t, name)
+		transport := srv.Client().Transport.(*http.Transport).Clone()
+		transport.TLSClientConfig.Certificates = []tls.Certificate{cert}
+		cli := &http.Client{Transport: transport}
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(name string) {
+				defer wg.Done()
+				resp, err := cli.Get(srv.URL)
+				if !assert.NoError(t, err) {
+					return
+				}
+				defer resp.Body.Close()
+				body, _ := io.ReadAll(resp.Body)
+				assert.Equal(t, "2 "+name, string(body))
+			}(name)
+		}
+	}
+	wg.Wait()
+}
+
+func selfSigned(t *testing.T, name string) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	require.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
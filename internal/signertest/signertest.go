@@ -0,0 +1,142 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package signertest holds the setup shared by the signer module tests
+package signertest
+
+import (
+	"crypto"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mind-security/relic/v8/lib/audit"
+	"github.com/mind-security/relic/v8/lib/binpatch"
+	"github.com/mind-security/relic/v8/lib/certloader"
+	"github.com/mind-security/relic/v8/signers"
+)
+
+// KeyName is recorded in the audit info of test signatures
+const KeyName = "rsa2048"
+
+// functestDir returns the functest directory, wherever the test runs from
+func functestDir() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "..", "functest")
+}
+
+// LoadCert loads the RSA test key with its X.509 certificate, and its PGP
+// certificate too if withPGP is set
+func LoadCert(t testing.TB, withPGP bool) *certloader.Certificate {
+	dir := filepath.Join(functestDir(), "testkeys")
+	keyBlob, err := os.ReadFile(filepath.Join(dir, "rsa2048.key"))
+	require.NoError(t, err)
+	key, err := certloader.ParseAnyPrivateKey(keyBlob, nil)
+	require.NoError(t, err)
+	var pgpCert string
+	if withPGP {
+		pgpCert = filepath.Join(dir, "rsa2048.pgp")
+	}
+	cert, err := certloader.LoadTokenCertificates(key, filepath.Join(dir, "rsa2048.crt"), pgpCert, nil)
+	require.NoError(t, err)
+	return cert
+}
+
+// Opts returns options for signing with mod using SHA-256 at signTime. flags
+// may be nil.
+func Opts(mod *signers.Signer, signTime time.Time, flags map[string]string) signers.SignOpts {
+	if flags == nil {
+		flags = map[string]string{}
+	}
+	return signers.SignOpts{
+		Hash:  crypto.SHA256,
+		Time:  signTime,
+		Audit: audit.New(KeyName, mod.Name, crypto.SHA256),
+		Flags: &signers.FlagValues{Defs: mod.Flags(), Values: flags},
+	}
+}
+
+// SignPatch signs the file at path with a module that returns a binary patch,
+// and applies the patch to a copy in a new temporary directory. It returns
+// the path of the copy.
+func SignPatch(t testing.TB, mod *signers.Signer, path string, cert *certloader.Certificate, opts signers.SignOpts) string {
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	opts.Path = path
+	blob, err := mod.Sign(f, cert, opts)
+	require.NoError(t, err)
+	patch, err := binpatch.Load(blob)
+	require.NoError(t, err)
+	out := filepath.Join(t.TempDir(), filepath.Base(path))
+	require.NoError(t, patch.Apply(f, out))
+	return out
+}
+
+// Verify checks the signatures of the file at path with mod
+func Verify(t testing.TB, mod *signers.Signer, path string, opts signers.VerifyOpts) ([]*signers.Signature, error) {
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	return mod.Verify(f, opts)
+}
+
+// CheckSignature asserts that sigs holds a single SHA-256 signature by cert
+func CheckSignature(t testing.TB, sigs []*signers.Signature, cert *certloader.Certificate) {
+	require.Len(t, sigs, 1)
+	assert.Equal(t, crypto.SHA256, sigs[0].Hash)
+	assert.Equal(t, cert.Leaf.Raw, sigs[0].X509Signature.Certificate.Raw)
+	roots := x509.NewCertPool()
+	roots.AddCert(cert.Leaf)
+	require.NoError(t, sigs[0].X509Signature.VerifyChain(roots, nil, x509.ExtKeyUsageAny))
+}
+
+// RoundTrip signs the file at path with mod and checks the signature. It then
+// checks that signing the result again replaces the signature instead of
+// adding another, and that changing the byte at offset is detected. A
+// negative offset counts from the end of the file. It returns the path of the
+// first signed copy.
+func RoundTrip(t testing.TB, mod *signers.Signer, path string, cert *certloader.Certificate, offset int) string {
+	signed := SignPatch(t, mod, path, cert, Opts(mod, time.Now(), nil))
+	sigs, err := Verify(t, mod, signed, signers.VerifyOpts{})
+	require.NoError(t, err)
+	CheckSignature(t, sigs, cert)
+
+	resigned := SignPatch(t, mod, signed, cert, Opts(mod, time.Now(), nil))
+	sigs, err = Verify(t, mod, resigned, signers.VerifyOpts{})
+	require.NoError(t, err)
+	CheckSignature(t, sigs, cert)
+	before, err := os.ReadFile(signed)
+	require.NoError(t, err)
+	after, err := os.ReadFile(resigned)
+	require.NoError(t, err)
+	assert.Equal(t, len(before), len(after))
+
+	if offset < 0 {
+		offset += len(after)
+	}
+	after[offset] ^= 0xff
+	require.NoError(t, os.WriteFile(resigned, after, 0644))
+	_, err = Verify(t, mod, resigned, signers.VerifyOpts{})
+	assert.Error(t, err)
+	return signed
+}
@@ -0,0 +1,68 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package signinit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/mind-security/relic/v8/config"
+	"github.com/mind-security/relic/v8/lib/audit"
+)
+
+// how long to wait for buffered audit records to be sent when exiting
+const auditFlushTimeout = 10 * time.Second
+
+var (
+	publisherMu sync.Mutex
+	publisher   *audit.Publisher
+)
+
+// getPublisher returns the buffered AMQP publisher, starting it on first use
+func getPublisher(aconf *config.AmqpConfig) (*audit.Publisher, error) {
+	publisherMu.Lock()
+	defer publisherMu.Unlock()
+	if publisher == nil {
+		p, err := audit.NewPublisher(aconf)
+		if err != nil {
+			return nil, err
+		}
+		publisher = p
+	}
+	return publisher, nil
+}
+
+// CloseAudit makes a final attempt to send buffered audit records before the
+// process exits. Records that can't be sent in time stay in the buffer
+// directory and are sent by the next process to use it.
+func CloseAudit() {
+	publisherMu.Lock()
+	p := publisher
+	publisher = nil
+	publisherMu.Unlock()
+	if p == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), auditFlushTimeout)
+	defer cancel()
+	if err := p.Close(ctx); err != nil {
+		log.Warn().Err(err).Msg("audit records were left in the buffer and will be sent later")
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright © SAS Institute Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signinit
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/mind-security/relic/v8/cmdline/shared"
+	"github.com/mind-security/relic/v8/config"
+	"github.com/mind-security/relic/v8/lib/certloader"
+	"github.com/mind-security/relic/v8/lib/chainbuild"
+)
+
+var (
+	chainMu      sync.Mutex
+	chainConf    *config.ChainConfig
+	chainBuilder *chainbuild.Builder
+)
+
+// getChainBuilder returns a builder for the current configuration, reusing
+// the downloads cached by the previous one unless the configuration changed
+func getChainBuilder() (*chainbuild.Builder, error) {
+	var conf *config.ChainConfig
+	if shared.CurrentConfig != nil {
+		conf = shared.CurrentConfig.Chain
+	}
+	if conf == nil {
+		return nil, errors.New("buildchain requires a chain section in the configuration")
+	}
+	chainMu.Lock()
+	defer chainMu.Unlock()
+	if chainBuilder != nil && chainConf == conf {
+		return chainBuilder, nil
+	}
+	b := &chainbuild.Builder{Fetch: conf.FetchAIA, CacheDir: conf.CacheDir}
+	if conf.Intermediates != "" {
+		blob, err := os.ReadFile(conf.Intermediates)
+		if err != nil {
+			return nil, fmt.Errorf("chain.intermediates: %w", err)
+		}
+		b.Intermediates, err = certloader.ParseX509Certificates(blob)
+		if err != nil {
+			return nil, fmt.Errorf("chain.intermediates: %w", err)
+		}
+	}
+	proxy, err := config.ProxyFunc(conf.Proxy)
+	if err != nil {
+		return nil, fmt.Errorf("chain: %w", err)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxy
+	b.Client = &http.Client{Transport: transport, Timeout: time.Duration(conf.Timeout) * time.Second}
+	chainConf, chainBuilder = conf, b
+	return b, nil
+}
+
+// buildChain completes the certificate chain up to a root. If an issuer can't
+// be found, the chain found so far is used and a warning is logged.
+func buildChain(ctx context.Context, cert *certloader.Certificate) error {
+	b, err := getChainBuilder()
+	if err != nil {
+		return err
+	}
+	path, err := b.Complete(ctx, append([]*x509.Certificate{cert.Leaf}, cert.Certificates...))
+	if err != nil {
+		log.Warn().Err(err).Str("key", cert.KeyName).Msg("certificate chain is incomplete")
+	}
+	// keep configured certificates that aren't on the path, in case a
+	// verifier builds a different one
+	for _, c := range cert.Certificates {
+		var found bool
+		for _, p := range path {
+			if p.Equal(c) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			path = append(path, c)
+		}
+	}
+	cert.Certificates = path
+	return nil
+}
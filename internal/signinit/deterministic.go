@@ -0,0 +1,51 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package signinit
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"errors"
+	"io"
+
+	"github.com/mind-security/relic/v8/token"
+)
+
+// deterministicSigner refuses to produce randomized signatures. Since Go 1.24,
+// software ECDSA keys derive their nonce from the key and digest (RFC 6979)
+// when no random source is given, which is why go.mod requires it. PKCS#1 v1.5
+// and Ed25519 are deterministic already.
+type deterministicSigner struct {
+	crypto.Signer
+}
+
+func (s deterministicSigner) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if _, ok := opts.(*rsa.PSSOptions); ok {
+		return nil, errors.New("RSA-PSS signatures are randomized and can't be made deterministic")
+	}
+	return s.Signer.Sign(nil, digest, opts)
+}
+
+// checkDeterministic returns an error if the key can't produce repeatable
+// signatures. Only keys held in memory let us choose the ECDSA nonce.
+func checkDeterministic(tok token.Token, pub crypto.PublicKey) error {
+	if _, ok := pub.(*ecdsa.PublicKey); ok && tok.Config().Type != "file" {
+		return errors.New("ECDSA signatures from a hardware or cloud token are randomized and can't be made deterministic")
+	}
+	return nil
+}
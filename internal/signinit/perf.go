@@ -0,0 +1,58 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package signinit
+
+import (
+	"context"
+	"crypto"
+	"io"
+	"time"
+
+	"github.com/mind-security/relic/v8/lib/audit"
+	"github.com/mind-security/relic/v8/lib/pkcs7"
+	"github.com/mind-security/relic/v8/lib/pkcs9"
+)
+
+// timedSigner measures how long the token takes to sign
+type timedSigner struct {
+	crypto.Signer
+	timer *audit.Timer
+}
+
+func (s timedSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	defer s.timer.Since(time.Now())
+	return s.Signer.Sign(rand, digest, opts)
+}
+
+// timedTimestamper measures how long the timestamp server takes to respond
+type timedTimestamper struct {
+	pkcs9.Timestamper
+	timer *audit.Timer
+}
+
+func (t timedTimestamper) Timestamp(ctx context.Context, req *pkcs9.Request) (*pkcs7.ContentInfoSignedData, error) {
+	defer t.timer.Since(time.Now())
+	token, err := t.Timestamper.Timestamp(ctx, req)
+	if err != nil {
+		return nil, ErrTimestampFailed{Err: err}
+	}
+	return token, nil
+}
+
+func (t timedTimestamper) Unwrap() pkcs9.Timestamper {
+	return t.Timestamper
+}
@@ -0,0 +1,84 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package signinit
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/mind-security/relic/v8/config"
+	"github.com/mind-security/relic/v8/signers"
+)
+
+// Where the signing time recorded in a signature comes from, as noted in the
+// audit log
+const (
+	SigningTimeNow           = "now"
+	SigningTimeOmit          = "omit"
+	SigningTimeFixed         = "fixed"
+	SigningTimeDeterministic = "deterministic"
+)
+
+// signingTime decides the signing time from the request and the key's
+// configuration. now is the current time, or the reproducible time in
+// deterministic mode. It returns the source of the time, and the time to
+// sign with.
+func signingTime(mod *signers.Signer, flags *signers.FlagValues, kconf *config.KeyConfig, deterministic bool, now time.Time) (string, time.Time, error) {
+	value := flags.GetString("signing-time")
+	if kconf.SigningTime != "" {
+		if value != "" && value != kconf.SigningTime {
+			return "", now, fmt.Errorf("key %q requires signing time %q", kconf.Name(), kconf.SigningTime)
+		}
+		value = kconf.SigningTime
+	}
+	switch value {
+	case "":
+		if deterministic {
+			return SigningTimeDeterministic, now, nil
+		}
+		return SigningTimeNow, now, nil
+	case SigningTimeNow:
+		if deterministic {
+			return "", now, fmt.Errorf("signing time %q can't be used with --deterministic", value)
+		}
+		return SigningTimeNow, now, nil
+	case SigningTimeOmit:
+		if mod.NeedsSigningTime {
+			return "", now, fmt.Errorf("signature type %q always records a signing time; use a fixed time instead of %q", mod.Name, value)
+		}
+		return SigningTimeOmit, now, nil
+	}
+	fixed, err := parseSigningTime(value)
+	if err != nil {
+		return "", now, err
+	}
+	return SigningTimeFixed, fixed, nil
+}
+
+// parseSigningTime parses a fixed signing time given as RFC 3339 or UNIX
+// seconds
+func parseSigningTime(value string) (time.Time, error) {
+	if secs, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(secs, 0).UTC(), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid signing time %q: expected \"now\", \"omit\", an RFC 3339 time, or UNIX seconds", value)
+	}
+	return t.UTC(), nil
+}
@@ -19,47 +19,225 @@ package signinit
 import (
 	"context"
 	"crypto"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"os"
+	"strings"
 	"time"
 
+	"github.com/rs/zerolog/log"
+
 	"github.com/mind-security/relic/v8/cmdline/shared"
 	"github.com/mind-security/relic/v8/config"
 	"github.com/mind-security/relic/v8/lib/audit"
 	"github.com/mind-security/relic/v8/lib/certloader"
+	"github.com/mind-security/relic/v8/lib/digestpool"
+	"github.com/mind-security/relic/v8/lib/pgptools"
+	"github.com/mind-security/relic/v8/lib/pkcs9"
+	"github.com/mind-security/relic/v8/lib/transparency"
+	"github.com/mind-security/relic/v8/lib/x509tools"
+	"github.com/mind-security/relic/v8/lib/zipslicer"
 	"github.com/mind-security/relic/v8/signers"
 	"github.com/mind-security/relic/v8/signers/sigerrors"
 	"github.com/mind-security/relic/v8/token"
 )
 
+// ErrDeprecatedHash is returned when asked to sign with a digest that is only
+// acceptable for verifying old signatures
+type ErrDeprecatedHash struct {
+	Hash crypto.Hash
+}
+
+func (e ErrDeprecatedHash) Error() string {
+	return fmt.Sprintf("refusing to sign using deprecated digest %s", e.Hash)
+}
+
+// checkValidity returns an error if the certificate is not valid at the
+// signing time, since the signature would fail strict verification
+func checkValidity(cert *x509.Certificate, signingTime time.Time) error {
+	if signingTime.Before(cert.NotBefore) {
+		return fmt.Errorf("certificate is not valid until %s, but signing time is %s", cert.NotBefore.UTC(), signingTime.UTC())
+	} else if signingTime.After(cert.NotAfter) {
+		return fmt.Errorf("certificate expired at %s, but signing time is %s", cert.NotAfter.UTC(), signingTime.UTC())
+	}
+	return nil
+}
+
+// checkKeyUsage returns an error if the certificate has an extended key usage
+// that doesn't allow signing in the given format. A certificate without the
+// extension may be used for anything.
+func checkKeyUsage(cert *x509.Certificate, mod *signers.Signer) error {
+	if len(cert.ExtKeyUsage) == 0 && len(cert.UnknownExtKeyUsage) == 0 {
+		return nil
+	}
+	wanted := mod.KeyUsages
+	if len(wanted) == 0 {
+		wanted = signers.DefaultKeyUsages
+	}
+	var have []string
+	for _, u := range cert.ExtKeyUsage {
+		if u == x509.ExtKeyUsageAny {
+			return nil
+		}
+		for _, w := range wanted {
+			if u == w {
+				return nil
+			}
+		}
+		have = append(have, x509tools.ExtKeyUsageName(u))
+	}
+	for _, oid := range cert.UnknownExtKeyUsage {
+		have = append(have, oid.String())
+	}
+	var want []string
+	for _, w := range wanted {
+		want = append(want, x509tools.ExtKeyUsageName(w))
+	}
+	return fmt.Errorf("certificate extended key usage %s does not allow %s signatures, which need %s",
+		strings.Join(have, ","), mod.Name, strings.Join(want, " or "))
+}
+
 // InitKey loads the cert chain for a key
 func InitKey(ctx context.Context, tok token.Token, keyName string) (*certloader.Certificate, *config.KeyConfig, error) {
+	return initKey(ctx, tok, keyName, nil)
+}
+
+// initKey is like InitKey, but if wrap is not nil the certificate uses the
+// signer it returns instead of the token key
+func initKey(ctx context.Context, tok token.Token, keyName string, wrap func(crypto.Signer) crypto.Signer) (*certloader.Certificate, *config.KeyConfig, error) {
 	key, err := tok.GetKey(ctx, keyName)
 	if err != nil {
 		return nil, nil, err
 	}
 	kconf := key.Config()
+	var signer crypto.Signer = key
+	if wrap != nil {
+		signer = wrap(key)
+	}
 	// parse certificates
-	cert, err := certloader.LoadTokenCertificates(key, kconf.X509Certificate, kconf.PgpCertificate, key.Certificate())
+	cert, err := certloader.LoadTokenCertificates(signer, kconf.X509Certificate, kconf.PgpCertificate, key.Certificate())
 	if err != nil {
 		return nil, nil, err
 	}
+	if kconf.PgpSubkey != "" {
+		if err := checkPgpSubkey(cert, kconf.PgpSubkey); err != nil {
+			return nil, nil, fmt.Errorf("key %q: %w", keyName, err)
+		}
+	}
+	if kconf.X509Chain != "" {
+		if cert.Leaf == nil {
+			return nil, nil, fmt.Errorf("key %q: x509chain requires x509certificate", keyName)
+		}
+		blob, err := os.ReadFile(kconf.X509Chain)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := cert.AddChain(blob); err != nil {
+			return nil, nil, fmt.Errorf("key %q: x509chain: %w", keyName, err)
+		}
+	}
 	cert.KeyName = keyName
+	if kconf.BuildChain {
+		if cert.Leaf == nil {
+			return nil, nil, fmt.Errorf("key %q: buildchain requires x509certificate", keyName)
+		}
+		if err := buildChain(ctx, cert); err != nil {
+			return nil, nil, fmt.Errorf("key %q: %w", keyName, err)
+		}
+	}
 	return cert, kconf, nil
 }
 
 // InitKey prepares to sign using the named key, preparing a cert chain and
 // signing options according to the server configuration
 func Init(ctx context.Context, mod *signers.Signer, tok token.Token, keyName string, hash crypto.Hash, flags *signers.FlagValues) (*certloader.Certificate, *signers.SignOpts, error) {
-	cert, kconf, err := InitKey(ctx, tok, keyName)
+	return InitWith(ctx, mod, tok, keyName, hash, flags, GetTimestamper)
+}
+
+// InitWith is like Init, but calls getTimestamper if the key needs a
+// timestamp instead of using the global configuration
+func InitWith(ctx context.Context, mod *signers.Signer, tok token.Token, keyName string, hash crypto.Hash, flags *signers.FlagValues, getTimestamper func() (pkcs9.Timestamper, error)) (*certloader.Certificate, *signers.SignOpts, error) {
+	return initAt(ctx, mod, tok, keyName, hash, flags, getTimestamper, time.Now())
+}
+
+// InitAt is like Init, but signs as of the given time instead of the current
+// time. This lets an offline signing operation be repeated exactly.
+func InitAt(ctx context.Context, mod *signers.Signer, tok token.Token, keyName string, hash crypto.Hash, flags *signers.FlagValues, now time.Time) (*certloader.Certificate, *signers.SignOpts, error) {
+	return initAt(ctx, mod, tok, keyName, hash, flags, GetTimestamper, now)
+}
+
+func initAt(ctx context.Context, mod *signers.Signer, tok token.Token, keyName string, hash crypto.Hash, flags *signers.FlagValues, getTimestamper func() (pkcs9.Timestamper, error), now time.Time) (*certloader.Certificate, *signers.SignOpts, error) {
+	if x509tools.IsDeprecatedHash(hash) {
+		return nil, nil, ErrDeprecatedHash{Hash: hash}
+	}
+	deterministic := flags.GetBool("deterministic")
+	tokenTime := new(audit.Timer)
+	wrap := func(key crypto.Signer) crypto.Signer { return timedSigner{Signer: key, timer: tokenTime} }
+	if deterministic {
+		if flags.GetString("timestamp-url") != "" {
+			return nil, nil, errors.New("--timestamp-url can't be used with --deterministic")
+		}
+		inner := wrap
+		wrap = func(key crypto.Signer) crypto.Signer { return deterministicSigner{Signer: inner(key)} }
+	}
+	if shared.CurrentConfig != nil && shared.CurrentConfig.Transparency != nil {
+		// remember signatures so SubmitTransparency can log them
+		inner := wrap
+		wrap = func(key crypto.Signer) crypto.Signer {
+			return transparency.NewRecorder(inner(key))
+		}
+	}
+	cert, kconf, err := initKey(ctx, tok, keyName, wrap)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cert.Leaf != nil && mod.CertTypes&signers.CertTypeX509 != 0 && !flags.GetBool("allow-eku-mismatch") {
+		if err := checkKeyUsage(cert.Leaf, mod); err != nil {
+			return nil, nil, fmt.Errorf("key %q: %w (use --allow-eku-mismatch to override)", kconf.Name(), err)
+		}
+	}
+	auditTime := now.UTC()
+	if deterministic {
+		if err := checkDeterministic(tok, cert.Signer().Public()); err != nil {
+			return nil, nil, fmt.Errorf("key %q: %w", kconf.Name(), err)
+		}
+		now, err = zipslicer.ReproducibleTime(flags.GetString("source-date-epoch"))
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	timeSource, now, err := signingTime(mod, flags, kconf, deterministic, now)
 	if err != nil {
 		return nil, nil, err
 	}
+	if hash == 0 {
+		hash, err = KeyHash(kconf, cert.Signer().Public())
+		if err != nil {
+			return nil, nil, err
+		}
+		hash = mod.SupportedHash(hash)
+	}
 	// create audit info
 	auditInfo := audit.New(kconf.Name(), mod.Name, hash)
-	now := time.Now().UTC()
-	auditInfo.SetTimestamp(now)
+	auditInfo.TokenTime = tokenTime
+	if template := kconf.Template(); template != "" {
+		auditInfo.Attributes["sig.keylabel"] = kconf.Label
+		auditInfo.Attributes["sig.keypattern"] = template
+	}
+	now = now.UTC()
+	auditInfo.SetTimestamp(auditTime)
+	auditInfo.Attributes["sig.signingtime.source"] = timeSource
+	if timeSource == SigningTimeFixed || timeSource == SigningTimeDeterministic {
+		auditInfo.Attributes["sig.signingtime"] = now
+	}
 	if cert.Leaf != nil {
 		auditInfo.SetX509Cert(cert.Leaf)
+		if mod.CertTypes&signers.CertTypeX509 != 0 && !flags.GetBool("ignore-cert-validity") {
+			if err := checkValidity(cert.Leaf, now); err != nil {
+				return nil, nil, fmt.Errorf("key %q: %w", kconf.Name(), err)
+			}
+		}
 	} else if mod.CertTypes&signers.CertTypeX509 != 0 {
 		return nil, nil, sigerrors.ErrNoCertificate{Type: "x509"}
 	}
@@ -68,26 +246,94 @@ func Init(ctx context.Context, mod *signers.Signer, tok token.Token, keyName str
 	} else if mod.CertTypes&signers.CertTypePgp != 0 {
 		return nil, nil, sigerrors.ErrNoCertificate{Type: "pgp"}
 	}
-	if kconf.Timestamp && !flags.GetBool("no-timestamp") {
-		cert.Timestamper, err = GetTimestamper()
+	if kconf.Timestamp && !flags.GetBool("no-timestamp") && !deterministic {
+		if tsURL := flags.GetString("timestamp-url"); tsURL != "" {
+			cert.Timestamper, err = GetTimestamperForURL(shared.CurrentConfig, tsURL)
+			auditInfo.Attributes["sig.ts.url"] = tsURL
+		} else {
+			cert.Timestamper, err = getTimestamper()
+		}
 		if err != nil {
 			return nil, nil, err
 		}
+		if kconf.TimestampStyle != "" {
+			style, err := pkcs9.ParseTimestampStyle(kconf.TimestampStyle)
+			if err != nil {
+				return nil, nil, fmt.Errorf("key %q: %w", kconf.Name(), err)
+			}
+			cert.Timestamper = pkcs9.StyledTimestamper{Timestamper: cert.Timestamper, Style: style}
+		}
+		auditInfo.TimestampTime = new(audit.Timer)
+		cert.Timestamper = timedTimestamper{Timestamper: cert.Timestamper, timer: auditInfo.TimestampTime}
 	}
+	applyKeyDefaults(flags, kconf)
 	opts := signers.SignOpts{
 		Hash:  hash,
 		Time:  now,
 		Audit: auditInfo,
 		Flags: flags,
+
+		OmitSigningTime: timeSource == SigningTimeOmit,
+	}
+	if shared.CurrentConfig != nil && shared.CurrentConfig.Digest != nil {
+		opts.DigestLimits = digestpool.Limits{
+			Workers:    shared.CurrentConfig.Digest.Workers,
+			BufferSize: shared.CurrentConfig.Digest.BufferSize,
+		}
 	}
 	opts = opts.WithContext(ctx)
 	return cert, &opts, nil
 }
 
+// KeyHash returns the digest to sign with when the request doesn't choose one:
+// the key's hashalgorithm option if set, otherwise one matching the strength
+// of the key.
+func KeyHash(kconf *config.KeyConfig, pub crypto.PublicKey) (crypto.Hash, error) {
+	if kconf.HashAlgorithm == "" {
+		return x509tools.HashForKey(pub), nil
+	}
+	hash := x509tools.HashByName(kconf.HashAlgorithm)
+	if hash == 0 {
+		return 0, fmt.Errorf("key %q: unsupported hashalgorithm %q", kconf.Name(), kconf.HashAlgorithm)
+	} else if x509tools.IsDeprecatedHash(hash) {
+		return 0, fmt.Errorf("key %q: %w", kconf.Name(), ErrDeprecatedHash{Hash: hash})
+	}
+	return hash, nil
+}
+
+// applyKeyDefaults fills in signer options that the request didn't set from
+// the key's configuration
+func applyKeyDefaults(flags *signers.FlagValues, kconf *config.KeyConfig) {
+	set := func(name, value string) {
+		if value == "" || flags.Defs == nil || flags.Defs.Lookup(name) == nil {
+			return
+		}
+		if _, ok := flags.Values[name]; ok {
+			return
+		}
+		if flags.Values == nil {
+			flags.Values = make(map[string]string)
+		}
+		flags.Values[name] = value
+	}
+	set("rpm-style", kconf.RpmStyle)
+	if kconf.RpmIMA {
+		set("rpm-ima", "true")
+	}
+}
+
 func PublishAudit(info *audit.Info) error {
 	aconf := shared.CurrentConfig.Amqp
 	if aconf != nil && aconf.URL != "" {
-		if err := info.Publish(aconf); err != nil {
+		if aconf.BufferDir != "" {
+			p, err := getPublisher(aconf)
+			if err == nil {
+				err = p.Publish(info)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to publish audit log: %w", err)
+			}
+		} else if err := info.Publish(aconf); err != nil {
 			return fmt.Errorf("failed to publish audit log: %w", err)
 		}
 	}
@@ -99,3 +345,78 @@ func PublishAudit(info *audit.Info) error {
 
 	return nil
 }
+
+// SubmitTransparency adds the signatures made while signing to the configured
+// transparency log, and records the resulting entries in the audit record.
+// Failures are only logged unless the log is configured as required.
+func SubmitTransparency(ctx context.Context, cert *certloader.Certificate, opts *signers.SignOpts) error {
+	rec, ok := cert.PrivateKey.(*transparency.Recorder)
+	if !ok {
+		return nil
+	}
+	tconf := shared.CurrentConfig.Transparency
+	entries, err := submitTransparency(ctx, tconf, cert, rec)
+	if err != nil {
+		opts.Audit.SetTransparencyError(tconf.URL, err)
+		if tconf.Required {
+			return fmt.Errorf("transparency log: %w", err)
+		}
+		log.Warn().Err(err).Str("key", cert.KeyName).Msg("failed to submit signature to transparency log")
+		return nil
+	}
+	opts.Audit.SetTransparencyEntries(tconf.URL, entries)
+	return nil
+}
+
+func submitTransparency(ctx context.Context, tconf *config.TransparencyConfig, cert *certloader.Certificate, rec *transparency.Recorder) ([]*transparency.Entry, error) {
+	client, err := transparency.NewClient(tconf)
+	if err != nil {
+		return nil, err
+	}
+	var entries []*transparency.Entry
+	for _, sig := range rec.Signatures() {
+		if !transparency.Supported(sig.Hash) {
+			continue
+		}
+		entry, err := client.Submit(ctx, cert.Leaf, rec.Public(), sig)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) == 0 {
+		return nil, errors.New("no signatures that the log can accept were made")
+	}
+	return entries, nil
+}
+
+// InitTokenAudit sends token lifecycle events to the same places as signature
+// audit records, if enabled in the configuration
+func InitTokenAudit() {
+	if !shared.CurrentConfig.AuditTokenEvents {
+		return
+	}
+	token.AuditHook = func(info *audit.Info) {
+		if err := PublishAudit(info); err != nil {
+			log.Err(err).Str("event", info.EventType()).Msg("failed to publish token audit event")
+		}
+	}
+}
+
+// checkPgpSubkey ensures that the key in the token is the configured subkey
+func checkPgpSubkey(cert *certloader.Certificate, spec string) error {
+	if cert.PgpKey == nil {
+		return errors.New("pgpsubkey requires pgpcertificate")
+	}
+	priv := pgptools.SigningKey(cert.PgpKey, 0)
+	if priv == nil || priv.PublicKey.KeyId == cert.PgpKey.PrimaryKey.KeyId {
+		return fmt.Errorf("token key is not PGP subkey %s", spec)
+	}
+	ok, err := pgptools.MatchKey(&priv.PublicKey, spec)
+	if err != nil {
+		return err
+	} else if !ok {
+		return fmt.Errorf("token key is PGP subkey %016X, not %s", priv.PublicKey.KeyId, spec)
+	}
+	return nil
+}
@@ -0,0 +1,339 @@
+package signinit
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mind-security/relic/v8/config"
+	"github.com/mind-security/relic/v8/lib/pkcs7"
+	"github.com/mind-security/relic/v8/lib/pkcs9"
+	"github.com/mind-security/relic/v8/signers"
+	"github.com/mind-security/relic/v8/token/filetoken"
+)
+
+func TestCheckValidity(t *testing.T) {
+	notBefore := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cert := &x509.Certificate{
+		NotBefore: notBefore,
+		NotAfter:  notBefore.AddDate(1, 0, 0),
+	}
+	assert.ErrorContains(t, checkValidity(cert, notBefore.Add(-time.Second)), "not valid until")
+	assert.ErrorContains(t, checkValidity(cert, cert.NotAfter.Add(time.Second)), "expired")
+	assert.NoError(t, checkValidity(cert, notBefore))
+	assert.NoError(t, checkValidity(cert, notBefore.AddDate(0, 6, 0)))
+	assert.NoError(t, checkValidity(cert, cert.NotAfter))
+}
+
+func TestTimestamperForURL(t *testing.T) {
+	cfg := &config.Config{Timestamp: &config.TimestampConfig{
+		URLs:        []string{"http://default.example.com"},
+		AllowedURLs: []string{"http://alt.example.com"},
+	}}
+	_, err := GetTimestamperForURL(cfg, "http://evil.example.com")
+	var notAllowed ErrTimestampURLNotAllowed
+	require.ErrorAs(t, err, &notAllowed)
+	assert.Equal(t, "http://evil.example.com", notAllowed.URL)
+
+	t1, err := GetTimestamperForURL(cfg, "http://alt.example.com")
+	require.NoError(t, err)
+	t2, err := GetTimestamperForURL(cfg, "http://alt.example.com")
+	require.NoError(t, err)
+	assert.Same(t, t1, t2)
+	// the configured URLs must not be modified
+	assert.Equal(t, []string{"http://default.example.com"}, cfg.Timestamp.URLs)
+
+	// the override doesn't share cached tokens with the default server
+	cfg.Timestamp.Memcache = []string{"127.0.0.1:11211"}
+	conf := overrideConfig(cfg.Timestamp, "http://alt.example.com")
+	assert.Equal(t, []string{"http://alt.example.com"}, conf.URLs)
+	assert.Equal(t, []string{"http://alt.example.com"}, conf.MsURLs)
+	assert.Empty(t, conf.Memcache)
+	assert.Equal(t, []string{"127.0.0.1:11211"}, cfg.Timestamp.Memcache)
+
+	_, err = GetTimestamperForURL(nil, "http://alt.example.com")
+	assert.ErrorAs(t, err, &notAllowed)
+}
+
+func TestKeyHash(t *testing.T) {
+	pub := &ecdsa.PublicKey{Curve: elliptic.P521()}
+	hash, err := KeyHash(&config.KeyConfig{}, pub)
+	require.NoError(t, err)
+	assert.Equal(t, crypto.SHA512, hash)
+
+	hash, err = KeyHash(&config.KeyConfig{HashAlgorithm: "SHA-384"}, pub)
+	require.NoError(t, err)
+	assert.Equal(t, crypto.SHA384, hash)
+
+	_, err = KeyHash(&config.KeyConfig{HashAlgorithm: "SHA1"}, pub)
+	var deprecated ErrDeprecatedHash
+	assert.ErrorAs(t, err, &deprecated)
+	_, err = KeyHash(&config.KeyConfig{HashAlgorithm: "bogus"}, pub)
+	assert.ErrorContains(t, err, "unsupported hashalgorithm")
+
+	// formats with a fixed set of digests get the nearest one
+	mod := &signers.Signer{Hashes: []crypto.Hash{crypto.SHA256, crypto.SHA512}}
+	assert.Equal(t, crypto.SHA512, mod.SupportedHash(crypto.SHA384))
+	assert.Equal(t, crypto.SHA256, mod.SupportedHash(crypto.SHA256))
+	mod.Hashes = []crypto.Hash{crypto.SHA256, crypto.SHA384}
+	assert.Equal(t, crypto.SHA384, mod.SupportedHash(crypto.SHA512))
+	assert.Equal(t, crypto.SHA512, (&signers.Signer{}).SupportedHash(crypto.SHA512))
+}
+
+func TestDeterministicSigner(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	signer := deterministicSigner{Signer: key}
+	digest := sha256.Sum256([]byte("hello"))
+	sig1, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	require.NoError(t, err)
+	sig2, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	require.NoError(t, err)
+	assert.Equal(t, sig1, sig2)
+	assert.True(t, ecdsa.VerifyASN1(&key.PublicKey, digest[:], sig1))
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	_, err = deterministicSigner{Signer: rsaKey}.Sign(rand.Reader, digest[:], &rsa.PSSOptions{Hash: crypto.SHA256})
+	assert.ErrorContains(t, err, "RSA-PSS")
+}
+
+func TestSigningTime(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	mod := &signers.Signer{Name: "pkcs7"}
+	pgp := &signers.Signer{Name: "pgp", NeedsSigningTime: true}
+	kconf := &config.KeyConfig{}
+	flags := func(value string) *signers.FlagValues {
+		v := &signers.FlagValues{Values: map[string]string{}}
+		if value != "" {
+			v.Values["signing-time"] = value
+		}
+		return v
+	}
+
+	source, got, err := signingTime(mod, flags(""), kconf, false, now)
+	require.NoError(t, err)
+	assert.Equal(t, SigningTimeNow, source)
+	assert.Equal(t, now, got)
+	source, _, err = signingTime(mod, flags(""), kconf, true, now)
+	require.NoError(t, err)
+	assert.Equal(t, SigningTimeDeterministic, source)
+	_, _, err = signingTime(mod, flags("now"), kconf, true, now)
+	assert.Error(t, err)
+
+	source, _, err = signingTime(mod, flags("omit"), kconf, false, now)
+	require.NoError(t, err)
+	assert.Equal(t, SigningTimeOmit, source)
+	_, _, err = signingTime(pgp, flags("omit"), kconf, false, now)
+	assert.ErrorContains(t, err, "always records a signing time")
+
+	fixed := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, value := range []string{"2020-01-02T03:04:05Z", "2020-01-02T04:04:05+01:00", "1577934245"} {
+		source, got, err = signingTime(pgp, flags(value), kconf, true, now)
+		require.NoError(t, err, value)
+		assert.Equal(t, SigningTimeFixed, source)
+		assert.Equal(t, fixed, got)
+	}
+	_, _, err = signingTime(mod, flags("yesterday"), kconf, false, now)
+	assert.ErrorContains(t, err, "invalid signing time")
+
+	// the key's setting applies, and can't be contradicted
+	kconf.SigningTime = "omit"
+	source, _, err = signingTime(mod, flags(""), kconf, false, now)
+	require.NoError(t, err)
+	assert.Equal(t, SigningTimeOmit, source)
+	_, _, err = signingTime(mod, flags("now"), kconf, false, now)
+	assert.ErrorContains(t, err, "requires signing time")
+}
+
+func TestCheckKeyUsage(t *testing.T) {
+	pe := &signers.Signer{Name: "pe-coff", KeyUsages: signers.CodeSigningKeyUsages}
+	pkcs := &signers.Signer{Name: "pkcs7"}
+	cert := &x509.Certificate{}
+	assert.NoError(t, checkKeyUsage(cert, pe), "no EKU")
+	cert.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	assert.ErrorContains(t, checkKeyUsage(cert, pe), "serverAuth does not allow pe-coff signatures, which need codeSigning")
+	assert.Error(t, checkKeyUsage(cert, pkcs))
+	cert.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection}
+	assert.Error(t, checkKeyUsage(cert, pe))
+	assert.NoError(t, checkKeyUsage(cert, pkcs))
+	cert.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping}
+	assert.Error(t, checkKeyUsage(cert, pkcs))
+	cert.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageCodeSigning}
+	assert.NoError(t, checkKeyUsage(cert, pe))
+	cert.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageAny}
+	assert.NoError(t, checkKeyUsage(cert, pe))
+}
+
+func issueCert(t *testing.T, name string, parent *x509.Certificate, parentKey crypto.Signer) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		BasicConstraintsValid: true,
+		IsCA:                  name != "leaf",
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert, key
+}
+
+func writePEM(t *testing.T, path, blockType string, blobs ...[]byte) {
+	var out []byte
+	for _, blob := range blobs {
+		out = append(out, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: blob})...)
+	}
+	require.NoError(t, os.WriteFile(path, out, 0600))
+}
+
+func TestInitKeyX509Chain(t *testing.T) {
+	root, rootKey := issueCert(t, "root", nil, nil)
+	inter, interKey := issueCert(t, "intermediate", root, rootKey)
+	leaf, leafKey := issueCert(t, "leaf", inter, interKey)
+	dir := t.TempDir()
+	keyDER, err := x509.MarshalPKCS8PrivateKey(leafKey)
+	require.NoError(t, err)
+	writePEM(t, filepath.Join(dir, "leaf.key"), "PRIVATE KEY", keyDER)
+	writePEM(t, filepath.Join(dir, "leaf.crt"), "CERTIFICATE", leaf.Raw)
+	writePEM(t, filepath.Join(dir, "chain.crt"), "CERTIFICATE", inter.Raw, root.Raw)
+
+	cfg := &config.Config{
+		Tokens: map[string]*config.TokenConfig{"file": {Type: "file"}},
+		Keys: map[string]*config.KeyConfig{
+			"withchain": {
+				Token:           "file",
+				KeyFile:         filepath.Join(dir, "leaf.key"),
+				X509Certificate: filepath.Join(dir, "leaf.crt"),
+				X509Chain:       filepath.Join(dir, "chain.crt"),
+			},
+			"nocert": {
+				Token:     "file",
+				KeyFile:   filepath.Join(dir, "leaf.key"),
+				X509Chain: filepath.Join(dir, "chain.crt"),
+			},
+		},
+	}
+	require.NoError(t, cfg.Normalize(""))
+	tok, err := filetoken.Open(cfg, "file", nil)
+	require.NoError(t, err)
+
+	// the intermediate is appended after the leaf and the root is left out
+	cert, _, err := InitKey(context.Background(), tok, "withchain")
+	require.NoError(t, err)
+	assert.Equal(t, leaf, cert.Leaf)
+	assert.Equal(t, []*x509.Certificate{leaf, inter}, cert.Certificates)
+
+	_, _, err = InitKey(context.Background(), tok, "nocert")
+	assert.ErrorContains(t, err, "x509chain requires x509certificate")
+}
+
+type recordingTimestamper struct {
+	legacy []bool
+}
+
+func (r *recordingTimestamper) Timestamp(ctx context.Context, req *pkcs9.Request) (*pkcs7.ContentInfoSignedData, error) {
+	r.legacy = append(r.legacy, req.Legacy)
+	return nil, errors.New("timestamp server is down")
+}
+
+func TestInitTimestampOptions(t *testing.T) {
+	leaf, leafKey := issueCert(t, "leaf", nil, nil)
+	dir := t.TempDir()
+	keyDER, err := x509.MarshalPKCS8PrivateKey(leafKey)
+	require.NoError(t, err)
+	writePEM(t, filepath.Join(dir, "leaf.key"), "PRIVATE KEY", keyDER)
+	writePEM(t, filepath.Join(dir, "leaf.crt"), "CERTIFICATE", leaf.Raw)
+	cfg := &config.Config{
+		Tokens: map[string]*config.TokenConfig{"file": {Type: "file"}},
+		Keys: map[string]*config.KeyConfig{
+			"legacy": {
+				Token:           "file",
+				KeyFile:         filepath.Join(dir, "leaf.key"),
+				X509Certificate: filepath.Join(dir, "leaf.crt"),
+				Timestamp:       true,
+				TimestampStyle:  "microsoft",
+			},
+			"fallback": {
+				Token:           "file",
+				KeyFile:         filepath.Join(dir, "leaf.key"),
+				X509Certificate: filepath.Join(dir, "leaf.crt"),
+				Timestamp:       true,
+				TimestampStyle:  "fallback",
+			},
+		},
+	}
+	require.NoError(t, cfg.Normalize(""))
+	tok, err := filetoken.Open(cfg, "file", nil)
+	require.NoError(t, err)
+
+	inner := new(recordingTimestamper)
+	getTimestamper := func() (pkcs9.Timestamper, error) {
+		return pkcs9.LimitedTimestamper{Timestamper: inner, Max: 2}, nil
+	}
+	mod := &signers.Signer{Name: "pkcs7", CertTypes: signers.CertTypeX509}
+	flags := &signers.FlagValues{Values: map[string]string{}}
+	cert, _, err := InitWith(context.Background(), mod, tok, "legacy", crypto.SHA256, flags, getTimestamper)
+	require.NoError(t, err)
+	// the audit timer wraps the style and cap, which must still be found
+	assert.Equal(t, pkcs9.StyleMicrosoft, pkcs9.StyleOf(cert.Timestamper))
+	assert.Equal(t, 2, pkcs9.MaxTimestampsOf(cert.Timestamper))
+
+	builder := pkcs7.NewBuilder(cert.Signer(), cert.Chain(), crypto.SHA256)
+	require.NoError(t, builder.SetContentData([]byte("hello")))
+	psd, err := builder.Sign()
+	require.NoError(t, err)
+	_, err = pkcs9.TimestampAndMarshal(context.Background(), psd, cert.Timestamper, true)
+	var failed ErrTimestampFailed
+	require.ErrorAs(t, err, &failed)
+	assert.Equal(t, []bool{true}, inner.legacy)
+
+	// a signature that already has as many timestamps as allowed is refused
+	// without contacting the server
+	token := pkcs7.ContentInfoSignedData{
+		ContentType: pkcs7.OidSignedData,
+		Content:     pkcs7.SignedData{Version: 1, ContentInfo: pkcs7.ContentInfo{ContentType: pkcs9.OidTSTInfo}},
+	}
+	for i := 0; i < 2; i++ {
+		require.NoError(t, pkcs9.AddStampToSignedAuthenticode(&psd.Content.SignerInfos[0], token))
+	}
+	_, err = pkcs9.TimestampAndMarshal(context.Background(), psd, cert.Timestamper, true)
+	assert.ErrorAs(t, err, &pkcs9.TooManyTimestampsError{})
+	assert.Len(t, inner.legacy, 1)
+
+	// fallback tries RFC 3161 first, then a legacy timestamp
+	inner.legacy = nil
+	cert, _, err = InitWith(context.Background(), mod, tok, "fallback", crypto.SHA256, flags, getTimestamper)
+	require.NoError(t, err)
+	assert.Equal(t, pkcs9.StyleFallback, pkcs9.StyleOf(cert.Timestamper))
+	builder = pkcs7.NewBuilder(cert.Signer(), cert.Chain(), crypto.SHA256)
+	require.NoError(t, builder.SetContentData([]byte("hello")))
+	psd, err = builder.Sign()
+	require.NoError(t, err)
+	_, err = pkcs9.TimestampAndMarshal(context.Background(), psd, cert.Timestamper, true)
+	require.ErrorAs(t, err, &failed)
+	assert.Equal(t, []bool{false, true}, inner.legacy)
+}
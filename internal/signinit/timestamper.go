@@ -14,36 +14,120 @@
 package signinit
 
 import (
+	"fmt"
 	"sync"
 
 	"github.com/mind-security/relic/v8/cmdline/shared"
+	"github.com/mind-security/relic/v8/config"
 	"github.com/mind-security/relic/v8/lib/pkcs9"
 	"github.com/mind-security/relic/v8/lib/pkcs9/tsclient"
 )
 
 var (
-	mu sync.Mutex
-	ts pkcs9.Timestamper
+	mu        sync.Mutex
+	ts        pkcs9.Timestamper
+	overrides map[string]pkcs9.Timestamper
 )
 
+// ErrTimestampURLNotAllowed is returned when a request asks for a timestamp
+// server that isn't listed in timestamp.allowedurls
+type ErrTimestampURLNotAllowed struct {
+	URL string
+}
+
+func (e ErrTimestampURLNotAllowed) Error() string {
+	return fmt.Sprintf("timestamp server %q is not in the list of allowed URLs", e.URL)
+}
+
+// ErrTimestampFailed is returned when the timestamp server could not provide a
+// timestamp for a signature
+type ErrTimestampFailed struct {
+	Err error
+}
+
+func (e ErrTimestampFailed) Error() string {
+	return e.Err.Error()
+}
+
+func (e ErrTimestampFailed) Unwrap() error {
+	return e.Err
+}
+
 func GetTimestamper() (pkcs9.Timestamper, error) {
 	mu.Lock()
 	defer mu.Unlock()
 	var err error
 	if ts == nil {
-		ts, err = newTimestamper()
+		ts, err = NewTimestamper(shared.CurrentConfig)
 	}
 	return ts, err
 }
 
-func newTimestamper() (timestamper pkcs9.Timestamper, err error) {
-	tsconf, err := shared.CurrentConfig.GetTimestampConfig()
+// NewTimestamper creates a timestamper from the given configuration
+func NewTimestamper(cfg *config.Config) (timestamper pkcs9.Timestamper, err error) {
+	tsconf, err := cfg.GetTimestampConfig()
+	if err != nil {
+		return nil, err
+	}
+	return newTimestamper(tsconf)
+}
+
+// GetTimestamperForURL returns a timestamper that uses only the given server
+// instead of the configured ones. The URL must be listed in
+// timestamp.allowedurls.
+func GetTimestamperForURL(cfg *config.Config, url string) (pkcs9.Timestamper, error) {
+	if cfg == nil {
+		return nil, ErrTimestampURLNotAllowed{URL: url}
+	}
+	tsconf, err := cfg.GetTimestampConfig()
 	if err != nil {
 		return nil, err
 	}
+	allowed := false
+	for _, allowedURL := range tsconf.AllowedURLs {
+		if url == allowedURL {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return nil, ErrTimestampURLNotAllowed{URL: url}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if t := overrides[url]; t != nil {
+		return t, nil
+	}
+	t, err := newTimestamper(overrideConfig(tsconf, url))
+	if err != nil {
+		return nil, err
+	}
+	if overrides == nil {
+		overrides = make(map[string]pkcs9.Timestamper)
+	}
+	overrides[url] = t
+	return t, nil
+}
+
+// overrideConfig returns a copy of the timestamp configuration that uses only
+// the given server. The rest of the configuration, such as the CA and rate
+// limits, is kept, except for the shared memcache: its entries aren't keyed by
+// server, so a token from the default server could be returned instead.
+func overrideConfig(tsconf *config.TimestampConfig, url string) *config.TimestampConfig {
+	conf := *tsconf
+	conf.URLs = []string{url}
+	conf.MsURLs = []string{url}
+	conf.Memcache = nil
+	return &conf
+}
+
+func newTimestamper(tsconf *config.TimestampConfig) (timestamper pkcs9.Timestamper, err error) {
 	timestamper, err = tsclient.New(tsconf)
 	if err != nil {
 		return
 	}
+	if tsconf.MaxTimestamps > 0 {
+		timestamper = pkcs9.LimitedTimestamper{Timestamper: timestamper, Max: tsconf.MaxTimestamps}
+	}
 	return timestamper, nil
 }
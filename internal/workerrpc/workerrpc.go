@@ -38,4 +38,8 @@ type Response struct {
 	Err       string
 	Retryable bool
 	Usage     bool
+	NotFound  bool
+	// Code and Fatal describe a token.Error
+	Code  string
+	Fatal bool
 }
@@ -3,12 +3,14 @@ package zhttp
 import (
 	"context"
 	"fmt"
+	"io"
 	stdlog "log"
 	"net/http"
 	"os"
 	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/mind-security/relic/v8/internal/logrotate"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
@@ -19,31 +21,43 @@ type ctxKey int
 var (
 	ctxAccessCallbacks ctxKey = 1
 	ctxDontLog         ctxKey = 2
+	ctxRequestID       ctxKey = 3
 )
 
+// RequestIDHeader carries an identifier chosen by the client that correlates
+// its own logs with the server's logs and audit records for the request
+const RequestIDHeader = "X-Request-Id"
+
 const rfc3339Milli = "2006-01-02T15:04:05.000Z07:00" // RFC3339 with 3 decimal places, padded
 
-// SetupLogging initializes zerolog with reasonable defaults
-func SetupLogging(levelName, logFile string) error {
+// SetupLogging initializes zerolog with reasonable defaults. Any extra
+// writers receive a copy of each JSON-encoded log event.
+func SetupLogging(levelName, logFile string, extra ...io.Writer) error {
 	zerolog.TimeFieldFormat = rfc3339Milli
 	zerolog.DurationFieldInteger = true
+	var w io.Writer
 	switch logFile {
 	case "-":
 		// write JSON to stderr
+		w = os.Stderr
 	case "":
 		// write pretty text to stderr
-		log.Logger = log.Logger.Output(zerolog.ConsoleWriter{
+		w = zerolog.ConsoleWriter{
 			Out:        os.Stderr,
 			TimeFormat: "15:04:05",
-		})
+		}
 	default:
 		// write JSON to file
-		w, err := logrotate.NewWriter(logFile)
+		var err error
+		w, err = logrotate.NewWriter(logFile)
 		if err != nil {
 			return fmt.Errorf("log_file: %w", err)
 		}
-		log.Logger = log.Logger.Output(w)
 	}
+	if len(extra) != 0 {
+		w = zerolog.MultiLevelWriter(append([]io.Writer{w}, extra...)...)
+	}
+	log.Logger = log.Logger.Output(w)
 	// set default log level
 	if levelName == "" {
 		levelName = zerolog.InfoLevel.String()
@@ -71,15 +85,23 @@ func LoggingMiddleware(opts ...LoggingOption) func(http.Handler) http.Handler {
 	}
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			// Use the client's request ID if it sent a usable one, and let it
+			// know which one was used
+			reqID := req.Header.Get(RequestIDHeader)
+			if !validRequestID(reqID) {
+				reqID = uuid.NewString()
+			}
+			rw.Header().Set(RequestIDHeader, reqID)
 			// Make a new log context for the scope of the request with basic
 			// request metadata suitable for every log entry
 			lc := cfg.logger.With().
 				Str("ip", StripPort(req.RemoteAddr)).
-				Str("req_id", req.Header.Get("X-Request-Id"))
+				Str("req_id", reqID)
 			// build request context and execute the next handler
 			baseLogger := lc.Logger()
 			ctx := req.Context()
 			ctx = baseLogger.WithContext(ctx)
+			ctx = context.WithValue(ctx, ctxRequestID, reqID)
 			var callbacks []AccessLogCallback
 			var dontLog bool
 			ctx = context.WithValue(ctx, ctxAccessCallbacks, &callbacks)
@@ -140,6 +162,29 @@ func DontLog(req *http.Request) {
 	}
 }
 
+// RequestID returns the correlation ID of the current request
+func RequestID(ctx context.Context) string {
+	reqID, _ := ctx.Value(ctxRequestID).(string)
+	return reqID
+}
+
+// validRequestID checks that a client-provided request ID is short and free
+// of characters that could garble a log line
+func validRequestID(reqID string) bool {
+	if reqID == "" || len(reqID) > 64 {
+		return false
+	}
+	for _, c := range reqID {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
 type loggingConfig struct {
 	logger zerolog.Logger
 	now    func() time.Time
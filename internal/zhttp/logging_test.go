@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -70,6 +71,29 @@ func TestLoggingMiddleware(t *testing.T) {
 	})
 }
 
+func TestRequestID(t *testing.T) {
+	var seen string
+	h := LoggingMiddleware(WithLogger(zerolog.Nop()))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestID(r.Context())
+	}))
+	serve := func(reqID string) string {
+		r, w := httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder()
+		if reqID != "" {
+			r.Header.Set(RequestIDHeader, reqID)
+		}
+		h.ServeHTTP(w, r)
+		assert.Equal(t, seen, w.Header().Get(RequestIDHeader))
+		return seen
+	}
+	assert.Equal(t, "3f9c2a1e-client", serve("3f9c2a1e-client"))
+	// missing or unsafe IDs are replaced
+	for _, reqID := range []string{"", "bad id\n", strings.Repeat("x", 65)} {
+		replaced := serve(reqID)
+		assert.NotEqual(t, reqID, replaced)
+		assert.Len(t, replaced, 36)
+	}
+}
+
 func fakeTime() func() time.Time {
 	ts := time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
 	return func() time.Time {
@@ -0,0 +1,306 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package archivesign signs each member of a ZIP or tar archive individually
+// and reports the outcome for every member.
+package archivesign
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrAlreadySigned is returned by a SignFunc for members that already carry a
+// signature
+var ErrAlreadySigned = errors.New("already signed")
+
+// Mode selects what happens when a member can't be signed
+type Mode int
+
+const (
+	// SkipAndContinue leaves failed members unchanged, signs everything else,
+	// and writes the archive. The result is still reported as an error.
+	SkipAndContinue Mode = iota
+	// AbortAll attempts every member so that all failures are reported, but
+	// writes nothing if any of them failed
+	AbortAll
+	// FailFast stops at the first failure and writes nothing
+	FailFast
+)
+
+var modeNames = map[Mode]string{
+	SkipAndContinue: "skip-and-continue",
+	AbortAll:        "abort-all",
+	FailFast:        "fail-fast",
+}
+
+// ParseMode parses a failure mode name. The empty string selects
+// SkipAndContinue.
+func ParseMode(s string) (Mode, error) {
+	switch strings.ToLower(s) {
+	case "", "skip", "skip-and-continue":
+		return SkipAndContinue, nil
+	case "abort", "abort-all":
+		return AbortAll, nil
+	case "fail-fast":
+		return FailFast, nil
+	}
+	return 0, fmt.Errorf("unknown failure mode %q: expected skip-and-continue, abort-all or fail-fast", s)
+}
+
+func (m Mode) String() string {
+	if s := modeNames[m]; s != "" {
+		return s
+	}
+	return fmt.Sprintf("Mode(%d)", int(m))
+}
+
+// Status is the outcome of signing a single member
+type Status int
+
+const (
+	StatusSigned Status = iota
+	StatusFailed
+	// StatusNotAttempted is used for members after a failure in FailFast mode
+	StatusNotAttempted
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusSigned:
+		return "signed"
+	case StatusFailed:
+		return "failed"
+	default:
+		return "not attempted"
+	}
+}
+
+// Result records the outcome for one member of the archive
+type Result struct {
+	Name   string
+	Status Status
+	Err    error
+}
+
+// Report holds the per-member results of SignZip or SignTar
+type Report struct {
+	Mode    Mode
+	Results []Result
+	// Written is true if the output archive was written and should be kept
+	Written bool
+}
+
+// Count returns the number of members with the given status
+func (r *Report) Count(status Status) int {
+	var n int
+	for _, res := range r.Results {
+		if res.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// Print writes one line per member followed by a summary
+func (r *Report) Print(w io.Writer) {
+	for _, res := range r.Results {
+		if res.Err != nil {
+			fmt.Fprintf(w, "%s: %s: %s\n", res.Name, res.Status, res.Err)
+		} else {
+			fmt.Fprintf(w, "%s: %s\n", res.Name, res.Status)
+		}
+	}
+	fmt.Fprintln(w, r.Summary())
+}
+
+// Summary returns a one-line description of the results
+func (r *Report) Summary() string {
+	s := fmt.Sprintf("signed %d of %d members", r.Count(StatusSigned), len(r.Results))
+	if n := r.Count(StatusFailed); n != 0 {
+		s += fmt.Sprintf(", %d failed", n)
+	}
+	if n := r.Count(StatusNotAttempted); n != 0 {
+		s += fmt.Sprintf(", %d not attempted", n)
+	}
+	if !r.Written {
+		s += ", archive not written"
+	}
+	return s
+}
+
+// Err returns a *PartialError if any member was not signed
+func (r *Report) Err() error {
+	if r.Count(StatusSigned) == len(r.Results) {
+		return nil
+	}
+	return &PartialError{Report: r}
+}
+
+// PartialError indicates that at least one member of the archive was not
+// signed. Check Report.Written to tell whether the other members were.
+type PartialError struct {
+	Report *Report
+}
+
+func (e *PartialError) Error() string {
+	return e.Report.Summary()
+}
+
+// SignFunc signs the contents of a single archive member and returns the
+// signed contents
+type SignFunc func(name string, r io.Reader) ([]byte, error)
+
+// SignZip calls sign for each file in the ZIP archive r and writes an archive
+// with the signed members to w. Directories are copied as-is and are not
+// included in the report. Signed members are held in memory until all
+// members have been attempted, so that nothing is written to w unless mode
+// allows it.
+//
+// The returned error is either a fatal error reading or writing the archive,
+// or the result of Report.Err().
+func SignZip(r io.ReaderAt, size int64, w io.Writer, mode Mode, sign SignFunc) (*Report, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{Mode: mode}
+	signed := make(map[*zip.File][]byte)
+	var failed bool
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		res := Result{Name: f.Name}
+		if failed && mode == FailFast {
+			res.Status = StatusNotAttempted
+		} else if blob, err := signMember(f, sign); err != nil {
+			res.Status = StatusFailed
+			res.Err = err
+			failed = true
+		} else {
+			signed[f] = blob
+		}
+		report.Results = append(report.Results, res)
+	}
+	if failed && mode != SkipAndContinue {
+		return report, report.Err()
+	}
+	zw := zip.NewWriter(w)
+	for _, f := range zr.File {
+		blob, ok := signed[f]
+		if !ok {
+			if err := zw.Copy(f); err != nil {
+				return report, fmt.Errorf("%s: %w", f.Name, err)
+			}
+			continue
+		}
+		hdr := f.FileHeader
+		hdr.CRC32 = 0
+		hdr.CompressedSize64 = 0
+		hdr.UncompressedSize64 = 0
+		hdr.Flags &^= 0x8 // data descriptor
+		mw, err := zw.CreateHeader(&hdr)
+		if err != nil {
+			return report, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		if _, err := mw.Write(blob); err != nil {
+			return report, fmt.Errorf("%s: %w", f.Name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return report, err
+	}
+	report.Written = true
+	return report, report.Err()
+}
+
+func signMember(f *zip.File, sign SignFunc) ([]byte, error) {
+	r, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return sign(f.Name, r)
+}
+
+// SignTar is like SignZip for a tar archive read from r. Entries other than
+// regular files are copied as-is and are not included in the report. The
+// whole archive is held in memory.
+func SignTar(r io.Reader, w io.Writer, mode Mode, sign SignFunc) (*Report, error) {
+	type entry struct {
+		hdr  *tar.Header
+		data []byte
+	}
+	var entries []entry
+	report := &Report{Mode: mode}
+	var failed bool
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", hdr.Name, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			entries = append(entries, entry{hdr, data})
+			continue
+		}
+		res := Result{Name: hdr.Name}
+		if failed && mode == FailFast {
+			res.Status = StatusNotAttempted
+		} else if blob, err := sign(hdr.Name, bytes.NewReader(data)); err != nil {
+			res.Status = StatusFailed
+			res.Err = err
+			failed = true
+		} else {
+			data = blob
+		}
+		report.Results = append(report.Results, res)
+		entries = append(entries, entry{hdr, data})
+	}
+	if failed && mode != SkipAndContinue {
+		return report, report.Err()
+	}
+	tw := tar.NewWriter(w)
+	for _, e := range entries {
+		hdr := *e.hdr
+		if hdr.Typeflag == tar.TypeReg {
+			hdr.Size = int64(len(e.data))
+		}
+		if err := tw.WriteHeader(&hdr); err != nil {
+			return report, fmt.Errorf("%s: %w", hdr.Name, err)
+		}
+		if _, err := tw.Write(e.data); err != nil {
+			return report, fmt.Errorf("%s: %w", hdr.Name, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return report, err
+	}
+	report.Written = true
+	return report, report.Err()
+}
@@ -0,0 +1,229 @@
+package archivesign
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var members = []struct{ name, contents string }{
+	{"bin/", ""},
+	{"bin/a.exe", "MZ unsigned"},
+	{"README.txt", "hello"},
+	{"bin/b.exe", "MZ signed"},
+	{"bin/c.exe", "MZ unsigned"},
+}
+
+func makeZip(t *testing.T) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, m := range members {
+		w, err := zw.Create(m.name)
+		require.NoError(t, err)
+		_, err = io.WriteString(w, m.contents)
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+// fakeSign "signs" executables and rejects everything else
+func fakeSign(name string, r io.Reader) ([]byte, error) {
+	blob, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case !strings.HasPrefix(string(blob), "MZ"):
+		return nil, errors.New("unknown filetype")
+	case strings.Contains(string(blob), "signed") && !strings.Contains(string(blob), "unsigned"):
+		return nil, ErrAlreadySigned
+	}
+	return append(blob, " +sig"...), nil
+}
+
+func signZip(t *testing.T, mode Mode) (*Report, []byte, error) {
+	blob := makeZip(t)
+	var out bytes.Buffer
+	report, err := SignZip(bytes.NewReader(blob), int64(len(blob)), &out, mode, fakeSign)
+	require.NotNil(t, report)
+	return report, out.Bytes(), err
+}
+
+func readZip(t *testing.T, blob []byte) map[string]string {
+	zr, err := zip.NewReader(bytes.NewReader(blob), int64(len(blob)))
+	require.NoError(t, err)
+	contents := make(map[string]string)
+	for _, f := range zr.File {
+		r, err := f.Open()
+		require.NoError(t, err)
+		data, err := io.ReadAll(r)
+		require.NoError(t, err)
+		contents[f.Name] = string(data)
+	}
+	return contents
+}
+
+func statuses(r *Report) map[string]Status {
+	m := make(map[string]Status)
+	for _, res := range r.Results {
+		m[res.Name] = res.Status
+	}
+	return m
+}
+
+func TestSkipAndContinue(t *testing.T) {
+	report, out, err := signZip(t, SkipAndContinue)
+	var perr *PartialError
+	require.ErrorAs(t, err, &perr)
+	assert.True(t, report.Written)
+	assert.Equal(t, map[string]Status{
+		"bin/a.exe":  StatusSigned,
+		"README.txt": StatusFailed,
+		"bin/b.exe":  StatusFailed,
+		"bin/c.exe":  StatusSigned,
+	}, statuses(report))
+	assert.ErrorIs(t, report.Results[2].Err, ErrAlreadySigned)
+	assert.Equal(t, "signed 2 of 4 members, 2 failed", report.Summary())
+	// failed members are copied unchanged
+	assert.Equal(t, map[string]string{
+		"bin/":       "",
+		"bin/a.exe":  "MZ unsigned +sig",
+		"README.txt": "hello",
+		"bin/b.exe":  "MZ signed",
+		"bin/c.exe":  "MZ unsigned +sig",
+	}, readZip(t, out))
+}
+
+func TestAbortAll(t *testing.T) {
+	report, out, err := signZip(t, AbortAll)
+	var perr *PartialError
+	require.ErrorAs(t, err, &perr)
+	assert.False(t, report.Written)
+	assert.Empty(t, out)
+	// every member was still attempted
+	assert.Equal(t, map[string]Status{
+		"bin/a.exe":  StatusSigned,
+		"README.txt": StatusFailed,
+		"bin/b.exe":  StatusFailed,
+		"bin/c.exe":  StatusSigned,
+	}, statuses(report))
+	assert.Equal(t, "signed 2 of 4 members, 2 failed, archive not written", report.Summary())
+}
+
+func TestFailFast(t *testing.T) {
+	report, out, err := signZip(t, FailFast)
+	var perr *PartialError
+	require.ErrorAs(t, err, &perr)
+	assert.False(t, report.Written)
+	assert.Empty(t, out)
+	assert.Equal(t, map[string]Status{
+		"bin/a.exe":  StatusSigned,
+		"README.txt": StatusFailed,
+		"bin/b.exe":  StatusNotAttempted,
+		"bin/c.exe":  StatusNotAttempted,
+	}, statuses(report))
+	assert.Equal(t, "signed 1 of 4 members, 1 failed, 2 not attempted, archive not written", report.Summary())
+}
+
+func TestAllSigned(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("a.exe")
+	require.NoError(t, err)
+	_, _ = io.WriteString(w, "MZ")
+	require.NoError(t, zw.Close())
+	for _, mode := range []Mode{SkipAndContinue, AbortAll, FailFast} {
+		var out bytes.Buffer
+		report, err := SignZip(bytes.NewReader(buf.Bytes()), int64(buf.Len()), &out, mode, fakeSign)
+		require.NoError(t, err, mode.String())
+		assert.True(t, report.Written)
+		assert.Equal(t, map[string]string{"a.exe": "MZ +sig"}, readZip(t, out.Bytes()))
+	}
+}
+
+func makeTar(t *testing.T) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, m := range members {
+		hdr := &tar.Header{Name: m.name, Mode: 0644, Size: int64(len(m.contents)), Typeflag: tar.TypeReg}
+		if strings.HasSuffix(m.name, "/") {
+			hdr.Typeflag = tar.TypeDir
+			hdr.Mode = 0755
+		}
+		require.NoError(t, tw.WriteHeader(hdr))
+		_, err := io.WriteString(tw, m.contents)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "bin/latest.exe", Linkname: "c.exe", Typeflag: tar.TypeSymlink}))
+	require.NoError(t, tw.Close())
+	return buf.Bytes()
+}
+
+func readTar(t *testing.T, blob []byte) map[string]string {
+	tr := tar.NewReader(bytes.NewReader(blob))
+	contents := make(map[string]string)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		data, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		contents[hdr.Name] = string(data) + hdr.Linkname
+	}
+	return contents
+}
+
+func TestSignTar(t *testing.T) {
+	var out bytes.Buffer
+	report, err := SignTar(bytes.NewReader(makeTar(t)), &out, SkipAndContinue, fakeSign)
+	var perr *PartialError
+	require.ErrorAs(t, err, &perr)
+	assert.True(t, report.Written)
+	// directories and links aren't signed or reported
+	assert.Equal(t, map[string]Status{
+		"bin/a.exe":  StatusSigned,
+		"README.txt": StatusFailed,
+		"bin/b.exe":  StatusFailed,
+		"bin/c.exe":  StatusSigned,
+	}, statuses(report))
+	assert.Equal(t, map[string]string{
+		"bin/":           "",
+		"bin/a.exe":      "MZ unsigned +sig",
+		"README.txt":     "hello",
+		"bin/b.exe":      "MZ signed",
+		"bin/c.exe":      "MZ unsigned +sig",
+		"bin/latest.exe": "c.exe",
+	}, readTar(t, out.Bytes()))
+
+	out.Reset()
+	report, err = SignTar(bytes.NewReader(makeTar(t)), &out, FailFast, fakeSign)
+	require.ErrorAs(t, err, &perr)
+	assert.False(t, report.Written)
+	assert.Empty(t, out.Bytes())
+	assert.Equal(t, StatusNotAttempted, statuses(report)["bin/c.exe"])
+}
+
+func TestParseMode(t *testing.T) {
+	for s, want := range map[string]Mode{
+		"":                  SkipAndContinue,
+		"skip-and-continue": SkipAndContinue,
+		"abort-all":         AbortAll,
+		"fail-fast":         FailFast,
+	} {
+		m, err := ParseMode(s)
+		require.NoError(t, err)
+		assert.Equal(t, want, m)
+	}
+	_, err := ParseMode("bogus")
+	assert.Error(t, err)
+}
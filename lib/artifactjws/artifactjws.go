@@ -0,0 +1,256 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package artifactjws signs arbitrary files, and the members of ZIP archives,
+// using a JSON Web Signature (RFC 7515) over a small JSON document holding the
+// SHA-256 of the signed content. The JWS uses compact serialization and
+// carries the signer's certificate chain in the x5c header.
+package artifactjws
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/go-jose/go-jose/v3"
+
+	"github.com/mind-security/relic/v8/lib/x509tools"
+)
+
+const (
+	// MediaType of a compact-serialized JWS
+	MediaType = "application/jose"
+	// ContentType identifies the payload format in the JWS header
+	ContentType = "relic-artifact+json"
+	// EntryName is where the signature is stored when embedded in a ZIP archive
+	EntryName = "META-INF/signature.jws"
+)
+
+// Payload is the signed JSON document. Detached signatures over a single file
+// set Name, Size and SHA256. Signatures embedded in a ZIP archive set Entries
+// to the SHA-256 of each member instead.
+type Payload struct {
+	Name     string            `json:"name,omitempty"`
+	Size     int64             `json:"size,omitempty"`
+	SHA256   string            `json:"sha256,omitempty"`
+	Entries  map[string]string `json:"entries,omitempty"`
+	IssuedAt int64             `json:"iat"`
+	Creator  string            `json:"creator,omitempty"`
+}
+
+type header struct {
+	Algorithm   string   `json:"alg"`
+	ContentType string   `json:"cty"`
+	X5C         [][]byte `json:"x5c"`
+}
+
+// Algorithm returns the JWS algorithm name and digest to use with the given
+// public key. For ECDSA the digest is determined by the curve, and for ed25519
+// the message is signed directly.
+func Algorithm(pub crypto.PublicKey, hash crypto.Hash) (string, crypto.Hash, error) {
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		switch hash {
+		case crypto.SHA256:
+			return "RS256", hash, nil
+		case crypto.SHA384:
+			return "RS384", hash, nil
+		case crypto.SHA512:
+			return "RS512", hash, nil
+		}
+		return "", 0, fmt.Errorf("unsupported digest %s for JWS", hash)
+	case *ecdsa.PublicKey:
+		switch pub.Curve {
+		case elliptic.P256():
+			return "ES256", crypto.SHA256, nil
+		case elliptic.P384():
+			return "ES384", crypto.SHA384, nil
+		case elliptic.P521():
+			return "ES512", crypto.SHA512, nil
+		}
+		return "", 0, fmt.Errorf("unsupported curve %s for JWS", pub.Curve.Params().Name)
+	case ed25519.PublicKey:
+		return "EdDSA", 0, nil
+	default:
+		return "", 0, fmt.Errorf("unsupported public key type %T for JWS", pub)
+	}
+}
+
+// Sign a payload and return the compact JWS. The first certificate in certs
+// must be the signer's.
+func Sign(signer crypto.Signer, certs []*x509.Certificate, hash crypto.Hash, payload *Payload) ([]byte, error) {
+	if len(certs) == 0 {
+		return nil, errors.New("signing certificate is required")
+	}
+	alg, hash, err := Algorithm(signer.Public(), hash)
+	if err != nil {
+		return nil, err
+	}
+	hdr := header{Algorithm: alg, ContentType: ContentType}
+	for _, cert := range certs {
+		hdr.X5C = append(hdr.X5C, cert.Raw)
+	}
+	hdrBlob, err := json.Marshal(hdr)
+	if err != nil {
+		return nil, err
+	}
+	payloadBlob, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	signingInput := b64(hdrBlob) + "." + b64(payloadBlob)
+	message := []byte(signingInput)
+	if hash != 0 {
+		d := hash.New()
+		d.Write(message)
+		message = d.Sum(nil)
+	}
+	sig, err := signer.Sign(rand.Reader, message, hash)
+	if err != nil {
+		return nil, err
+	}
+	if pub, ok := signer.Public().(*ecdsa.PublicKey); ok {
+		// JWS uses fixed-size R || S instead of ASN.1
+		esig, err := x509tools.UnmarshalEcdsaSignature(sig)
+		if err != nil {
+			return nil, err
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		sig = make([]byte, 2*size)
+		esig.R.FillBytes(sig[:size])
+		esig.S.FillBytes(sig[size:])
+	}
+	return []byte(signingInput + "." + b64(sig)), nil
+}
+
+// Signature is a verified JWS
+type Signature struct {
+	Payload       Payload
+	Certificate   *x509.Certificate
+	Intermediates []*x509.Certificate
+	Hash          crypto.Hash
+}
+
+// Verify the integrity of a compact JWS and return its contents. The
+// certificate chain is not validated.
+func Verify(blob []byte) (*Signature, error) {
+	compact := strings.TrimSpace(string(blob))
+	hdrText, _, ok := strings.Cut(compact, ".")
+	if !ok {
+		return nil, errors.New("malformed JWS")
+	}
+	hdrBlob, err := base64.RawURLEncoding.DecodeString(hdrText)
+	if err != nil {
+		return nil, fmt.Errorf("malformed JWS header: %w", err)
+	}
+	var hdr header
+	if err := json.Unmarshal(hdrBlob, &hdr); err != nil {
+		return nil, fmt.Errorf("malformed JWS header: %w", err)
+	}
+	if hdr.ContentType != ContentType {
+		return nil, fmt.Errorf("unexpected JWS content type %q", hdr.ContentType)
+	}
+	if len(hdr.X5C) == 0 {
+		return nil, errors.New("JWS has no certificates")
+	}
+	var certs []*x509.Certificate
+	for _, der := range hdr.X5C {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, fmt.Errorf("parsing JWS certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	alg, hash, err := Algorithm(certs[0].PublicKey, hashForAlgorithm(hdr.Algorithm))
+	if err != nil {
+		return nil, err
+	} else if alg != hdr.Algorithm {
+		return nil, fmt.Errorf("JWS algorithm %s does not match certificate", hdr.Algorithm)
+	}
+	jws, err := jose.ParseSigned(compact)
+	if err != nil {
+		return nil, err
+	}
+	payloadBlob, err := jws.Verify(certs[0].PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	sig := &Signature{
+		Certificate:   certs[0],
+		Intermediates: certs[1:],
+		Hash:          hash,
+	}
+	if err := json.Unmarshal(payloadBlob, &sig.Payload); err != nil {
+		return nil, fmt.Errorf("malformed JWS payload: %w", err)
+	}
+	return sig, nil
+}
+
+func hashForAlgorithm(alg string) crypto.Hash {
+	if len(alg) < 3 {
+		return 0
+	}
+	switch alg[len(alg)-3:] {
+	case "256":
+		return crypto.SHA256
+	case "384":
+		return crypto.SHA384
+	case "512":
+		return crypto.SHA512
+	}
+	return 0
+}
+
+// DigestFile computes the SHA-256 and size of r
+func DigestFile(r io.Reader) (string, int64, error) {
+	d := sha256.New()
+	n, err := io.Copy(d, r)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(d.Sum(nil)), n, nil
+}
+
+// CheckFile verifies that r has the size and digest recorded in the payload
+func (p *Payload) CheckFile(r io.Reader) error {
+	if p.SHA256 == "" {
+		return errors.New("signature does not cover a detached file")
+	}
+	sum, size, err := DigestFile(r)
+	if err != nil {
+		return err
+	}
+	if size != p.Size || sum != p.SHA256 {
+		return errors.New("digest mismatch")
+	}
+	return nil
+}
+
+func b64(d []byte) string {
+	return base64.RawURLEncoding.EncodeToString(d)
+}
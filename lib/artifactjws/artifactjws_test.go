@@ -0,0 +1,119 @@
+package artifactjws
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mind-security/relic/v8/lib/zipslicer"
+)
+
+func selfSign(t *testing.T, key crypto.Signer) *x509.Certificate {
+	t.Helper()
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "artifact signer"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert
+}
+
+func TestSignVerify(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	keys := []struct {
+		name string
+		key  crypto.Signer
+		alg  string
+	}{
+		{"rsa", rsaKey, "RS256"},
+		{"p256", p256, "ES256"},
+		{"p384", p384, "ES384"},
+		{"ed25519", edKey, "EdDSA"},
+	}
+	content := []byte("artifact contents")
+	sum, size, err := DigestFile(bytes.NewReader(content))
+	require.NoError(t, err)
+	for _, k := range keys {
+		t.Run(k.name, func(t *testing.T) {
+			alg, _, err := Algorithm(k.key.Public(), crypto.SHA256)
+			require.NoError(t, err)
+			assert.Equal(t, k.alg, alg)
+			cert := selfSign(t, k.key)
+			payload := &Payload{Name: "artifact.bin", Size: size, SHA256: sum, IssuedAt: 1700000000}
+			blob, err := Sign(k.key, []*x509.Certificate{cert}, crypto.SHA256, payload)
+			require.NoError(t, err)
+
+			sig, err := Verify(blob)
+			require.NoError(t, err)
+			assert.Equal(t, *payload, sig.Payload)
+			assert.Equal(t, cert.Raw, sig.Certificate.Raw)
+			assert.NoError(t, sig.Payload.CheckFile(bytes.NewReader(content)))
+			assert.Error(t, sig.Payload.CheckFile(strings.NewReader("tampered")))
+
+			// altering the payload invalidates the signature
+			parts := strings.Split(string(blob), ".")
+			parts[1] = b64([]byte(`{"sha256":"00","iat":1}`))
+			_, err = Verify([]byte(strings.Join(parts, ".")))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestCheckEntries(t *testing.T) {
+	makeZip := func(files map[string]string) *zipslicer.Directory {
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		for name, contents := range files {
+			w, err := zw.Create(name)
+			require.NoError(t, err)
+			_, err = w.Write([]byte(contents))
+			require.NoError(t, err)
+		}
+		require.NoError(t, zw.Close())
+		d, err := zipslicer.Read(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		require.NoError(t, err)
+		return d
+	}
+	orig := makeZip(map[string]string{"a.txt": "a", "dir/": "", "dir/b.txt": "b"})
+	p := &Payload{Entries: make(map[string]string)}
+	for _, f := range orig.File {
+		if IsSigned(f.Name) {
+			sum, err := DigestEntry(f)
+			require.NoError(t, err)
+			p.Entries[f.Name] = sum
+		}
+	}
+	assert.Len(t, p.Entries, 2)
+	assert.NoError(t, p.CheckEntries(orig))
+	// signature entry itself is ignored
+	assert.NoError(t, p.CheckEntries(makeZip(map[string]string{"a.txt": "a", "dir/b.txt": "b", EntryName: "x"})))
+	assert.Error(t, p.CheckEntries(makeZip(map[string]string{"a.txt": "a", "dir/b.txt": "tampered"})))
+	assert.Error(t, p.CheckEntries(makeZip(map[string]string{"a.txt": "a"})))
+	assert.Error(t, p.CheckEntries(makeZip(map[string]string{"a.txt": "a", "dir/b.txt": "b", "c.txt": "c"})))
+}
@@ -0,0 +1,81 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package artifactjws
+
+import (
+	"crypto"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mind-security/relic/v8/lib/zipslicer"
+)
+
+// IsSigned returns true if a ZIP member is covered by an embedded signature.
+// Directories and the signature itself are not.
+func IsSigned(name string) bool {
+	return name != EntryName && !strings.HasSuffix(name, "/")
+}
+
+// DigestEntry computes the SHA-256 of a ZIP member
+func DigestEntry(f *zipslicer.File) (string, error) {
+	sum, err := f.Digest(crypto.SHA256)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", f.Name, err)
+	}
+	return hex.EncodeToString(sum), nil
+}
+
+// CheckEntries verifies that the members of a ZIP archive match the digests
+// recorded in the payload, and that no members were added or removed
+func (p *Payload) CheckEntries(d *zipslicer.Directory) error {
+	if len(p.Entries) == 0 {
+		return fmt.Errorf("signature does not cover any archive entries")
+	}
+	seen := make(map[string]bool, len(p.Entries))
+	for _, f := range d.File {
+		if !IsSigned(f.Name) {
+			continue
+		}
+		want, ok := p.Entries[f.Name]
+		if !ok {
+			return fmt.Errorf("%s: file is not covered by the signature", f.Name)
+		} else if seen[f.Name] {
+			return fmt.Errorf("%s: duplicate file in archive", f.Name)
+		}
+		seen[f.Name] = true
+		got, err := DigestEntry(f)
+		if err != nil {
+			return err
+		}
+		if got != want {
+			return fmt.Errorf("%s: digest mismatch", f.Name)
+		}
+	}
+	var missing []string
+	for name := range p.Entries {
+		if !seen[name] {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) != 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("signed files are missing from the archive: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
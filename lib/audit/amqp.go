@@ -17,8 +17,10 @@
 package audit
 
 import (
+	"context"
 	"crypto/tls"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/streadway/amqp"
@@ -34,37 +36,72 @@ func (info *Info) Publish(aconf *config.AmqpConfig) error {
 	if err != nil {
 		return err
 	}
-	msg := amqp.Publishing{
-		DeliveryMode: amqp.Persistent,
-		Timestamp:    time.Now(),
-		ContentType:  "application/json",
-		Body:         blob,
+	s, err := dialSender(aconf)
+	if err != nil {
+		return err
 	}
+	defer s.Close()
+	return s.Send(context.Background(), blob)
+}
+
+// amqpSender publishes to the configured exchange with publisher confirms
+type amqpSender struct {
+	aconf    *config.AmqpConfig
+	conn     *amqp.Connection
+	ch       *amqp.Channel
+	confirms chan amqp.Confirmation
+}
+
+func dialSender(aconf *config.AmqpConfig) (*amqpSender, error) {
 	conn, err := Connect(aconf)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer conn.Close()
 	ch, err := conn.Channel()
 	if err != nil {
-		return err
+		conn.Close()
+		return nil, err
 	}
-	defer ch.Close()
+	s := &amqpSender{aconf: aconf, conn: conn, ch: ch}
 	if err := ch.ExchangeDeclare(aconf.ExchangeName(), amqp.ExchangeFanout, true, false, false, false, nil); err != nil {
-		return err
+		s.Close()
+		return nil, err
 	}
 	if err := ch.Confirm(false); err != nil {
-		return err
+		s.Close()
+		return nil, err
+	}
+	s.confirms = ch.NotifyPublish(make(chan amqp.Confirmation, 1))
+	return s, nil
+}
+
+// Send one record and wait for the broker to confirm it
+func (s *amqpSender) Send(ctx context.Context, blob []byte) error {
+	msg := amqp.Publishing{
+		DeliveryMode: amqp.Persistent,
+		Timestamp:    time.Now(),
+		ContentType:  "application/json",
+		Body:         blob,
 	}
-	notify := ch.NotifyPublish(make(chan amqp.Confirmation, 1))
-	if err := ch.Publish(aconf.ExchangeName(), aconf.RoutingKey(), false, false, msg); err != nil {
+	if err := s.ch.Publish(s.aconf.ExchangeName(), s.aconf.RoutingKey(), false, false, msg); err != nil {
 		return err
 	}
-	confirm := <-notify
-	if !confirm.Ack {
-		return errors.New("message was NACKed")
+	select {
+	case confirm, ok := <-s.confirms:
+		if !ok {
+			return errors.New("channel closed before message was confirmed")
+		} else if !confirm.Ack {
+			return errors.New("message was NACKed")
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
-	return nil
+}
+
+func (s *amqpSender) Close() error {
+	s.ch.Close()
+	return s.conn.Close()
 }
 
 // Connect to the configured AMQP broker
@@ -87,8 +124,16 @@ func Connect(aconf *config.AmqpConfig) (*amqp.Connection, error) {
 			if err != nil {
 				return nil, err
 			}
+			if aconf.FIPS() {
+				if err := x509tools.CheckFIPSKey(cert.Leaf.PublicKey); err != nil {
+					return nil, fmt.Errorf("amqp client certificate: %w", err)
+				}
+			}
 			tconf.Certificates = []tls.Certificate{cert.TLS()}
 		}
+		if aconf.FIPS() {
+			x509tools.RestrictFIPS(tconf)
+		}
 		x509tools.SetKeyLogFile(tconf)
 		if len(tconf.Certificates) != 0 {
 			auth = append(auth, externalAuth{})
@@ -31,6 +31,7 @@ import (
 
 	"github.com/mind-security/relic/v8/lib/pgptools"
 	"github.com/mind-security/relic/v8/lib/pkcs9"
+	"github.com/mind-security/relic/v8/lib/transparency"
 	"github.com/mind-security/relic/v8/lib/x509tools"
 	"github.com/rs/zerolog"
 )
@@ -38,6 +39,9 @@ import (
 type Info struct {
 	Attributes map[string]interface{}
 	StartTime  time.Time
+	// Time spent in the token and timestamp server, if measured
+	TokenTime     *Timer
+	TimestampTime *Timer
 }
 
 // Create a new audit record, starting with the given key name, signature type,
@@ -83,6 +87,23 @@ func (info *Info) SetCounterSignature(cs *pkcs9.CounterSignature) {
 	info.Attributes["sig.ts.timestamper"] = x509tools.FormatSubject(cs.Certificate)
 	info.Attributes["sig.ts.timestamp"] = cs.SigningTime
 	info.Attributes["sig.ts.hash"] = x509tools.HashNames[cs.Hash]
+	if cs.Legacy {
+		info.Attributes["sig.ts.style"] = "microsoft"
+	} else {
+		info.Attributes["sig.ts.style"] = "rfc3161"
+	}
+}
+
+// Add the transparency log entries recording this signature
+func (info *Info) SetTransparencyEntries(logURL string, entries []*transparency.Entry) {
+	info.Attributes["tlog.url"] = logURL
+	info.Attributes["tlog.entries"] = entries
+}
+
+// Record why this signature could not be added to the transparency log
+func (info *Info) SetTransparencyError(logURL string, err error) {
+	info.Attributes["tlog.url"] = logURL
+	info.Attributes["tlog.error"] = err.Error()
 }
 
 // Set the MIME type (Content-Type) that the server will use when returning a
@@ -0,0 +1,167 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package audit
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ErrBufferFull is returned when an audit record can't be buffered because the
+// buffer already holds the configured maximum number of records
+var ErrBufferFull = errors.New("audit buffer is full")
+
+const (
+	bufferSuffix  = ".json"
+	sendingSuffix = ".sending"
+)
+
+// diskBuffer is a directory of audit records waiting to be sent, one file per
+// record. Several processes can share a directory: a record is claimed for
+// sending by renaming it, so that only one of them sends it.
+type diskBuffer struct {
+	dir string
+	max int
+	seq uint64
+}
+
+func newDiskBuffer(dir string, max int) (*diskBuffer, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &diskBuffer{dir: dir, max: max}, nil
+}
+
+// Add a record to the end of the buffer
+func (b *diskBuffer) Add(blob []byte) error {
+	names, err := b.list(false)
+	if err != nil {
+		return err
+	} else if b.max > 0 && len(names) >= b.max {
+		return ErrBufferFull
+	}
+	// names sort by creation time. the pid and sequence number keep names
+	// unique across processes and within one.
+	name := fmt.Sprintf("%020d-%d-%d%s", time.Now().UnixNano(), os.Getpid(), atomic.AddUint64(&b.seq, 1), bufferSuffix)
+	f, err := os.CreateTemp(b.dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(blob); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), filepath.Join(b.dir, name))
+}
+
+// Pending returns the names of the records waiting to be sent, oldest first
+func (b *diskBuffer) Pending() ([]string, error) {
+	return b.list(true)
+}
+
+// Len returns the number of records in the buffer, including any that are
+// being sent
+func (b *diskBuffer) Len() (int, error) {
+	names, err := b.list(false)
+	return len(names), err
+}
+
+func (b *diskBuffer) list(pendingOnly bool) ([]string, error) {
+	entries, err := os.ReadDir(b.dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") {
+			continue
+		}
+		if strings.HasSuffix(name, bufferSuffix) || (!pendingOnly && strings.HasSuffix(name, sendingSuffix)) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Claim a pending record for sending. ok is false if another process claimed
+// it first.
+func (b *diskBuffer) Claim(name string) (blob []byte, ok bool, err error) {
+	pending := filepath.Join(b.dir, name)
+	sending := pending + sendingSuffix
+	if err := os.Rename(pending, sending); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	now := time.Now()
+	_ = os.Chtimes(sending, now, now)
+	blob, err = os.ReadFile(sending)
+	if err != nil {
+		b.Release(name)
+		return nil, false, err
+	}
+	return blob, true, nil
+}
+
+// Release a claimed record so it will be sent again later
+func (b *diskBuffer) Release(name string) {
+	pending := filepath.Join(b.dir, name)
+	_ = os.Rename(pending+sendingSuffix, pending)
+}
+
+// Remove a claimed record after it was sent
+func (b *diskBuffer) Remove(name string) error {
+	return os.Remove(filepath.Join(b.dir, name+sendingSuffix))
+}
+
+// RecoverStale releases records claimed longer than maxAge ago, presumably by
+// a process that exited before it could finish sending them
+func (b *diskBuffer) RecoverStale(maxAge time.Duration) error {
+	entries, err := os.ReadDir(b.dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasSuffix(name, sendingSuffix) {
+			continue
+		}
+		st, err := entry.Info()
+		if err != nil || time.Since(st.ModTime()) < maxAge {
+			continue
+		}
+		b.Release(strings.TrimSuffix(name, sendingSuffix))
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package audit
+
+import (
+	"os"
+	"time"
+)
+
+// Event types. Signature records predate the event.type attribute and don't
+// carry it.
+const (
+	EventSignature      = "signature"
+	EventLogin          = "token.login"
+	EventLoginFailed    = "token.login.failed"
+	EventLogout         = "token.logout"
+	EventHealthy        = "token.healthy"
+	EventUnhealthy      = "token.unhealthy"
+	EventSessionRestart = "token.session.restart"
+	EventSignDenied     = "sign.denied"
+)
+
+// Create a new audit record for a token lifecycle event
+func NewTokenEvent(tokenName, eventType, reason string) *Info {
+	now := time.Now().UTC()
+	a := make(map[string]interface{})
+	a["event.type"] = eventType
+	a["event.timestamp"] = now
+	a["token.name"] = tokenName
+	if reason != "" {
+		a["token.reason"] = reason
+	}
+	if hostname, _ := os.Hostname(); hostname != "" {
+		a["event.hostname"] = hostname
+	}
+	return &Info{Attributes: a}
+}
+
+// Create a new audit record for a signing request that was refused by policy
+func NewDeniedEvent(keyName, sigType, reason string) *Info {
+	now := time.Now().UTC()
+	a := make(map[string]interface{})
+	a["event.type"] = EventSignDenied
+	a["event.timestamp"] = now
+	a["sig.keyname"] = keyName
+	a["sig.type"] = sigType
+	a["deny.reason"] = reason
+	if hostname, _ := os.Hostname(); hostname != "" {
+		a["event.hostname"] = hostname
+	}
+	return &Info{Attributes: a}
+}
+
+// Get the type of event this audit record describes
+func (info *Info) EventType() string {
+	if v, ok := info.Attributes["event.type"].(string); ok {
+		return v
+	}
+	return EventSignature
+}
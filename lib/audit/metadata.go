@@ -0,0 +1,99 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package audit
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Limits on the metadata a client can attach to a signing request
+const (
+	MaxMetadataItems       = 16
+	MaxMetadataKeyLength   = 64
+	MaxMetadataValueLength = 256
+)
+
+// MetadataPrefix is prepended to the name of each metadata item when it is
+// recorded as an attribute
+const MetadataPrefix = "client.meta."
+
+// ParseMetadata parses labeled metadata in the form key=value, as supplied by
+// a client to identify the artifact being signed. Keys are limited to
+// letters, digits, dots, dashes and underscores. Control characters and
+// invalid UTF-8 in values are replaced, and anything over the length limits
+// is rejected.
+func ParseMetadata(items []string) (map[string]string, error) {
+	if len(items) > MaxMetadataItems {
+		return nil, fmt.Errorf("too many metadata items, at most %d are allowed", MaxMetadataItems)
+	}
+	meta := make(map[string]string, len(items))
+	for _, item := range items {
+		key, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, errors.New("metadata must be in the form key=value")
+		}
+		if err := checkMetadataKey(key); err != nil {
+			return nil, err
+		}
+		if _, dup := meta[key]; dup {
+			return nil, fmt.Errorf("metadata key %q given more than once", key)
+		}
+		value = sanitizeMetadataValue(value)
+		if len(value) > MaxMetadataValueLength {
+			return nil, fmt.Errorf("metadata value for %q is longer than %d bytes", key, MaxMetadataValueLength)
+		}
+		meta[key] = value
+	}
+	return meta, nil
+}
+
+func checkMetadataKey(key string) error {
+	if key == "" {
+		return errors.New("metadata key must not be empty")
+	} else if len(key) > MaxMetadataKeyLength {
+		return fmt.Errorf("metadata key is longer than %d characters", MaxMetadataKeyLength)
+	}
+	for _, c := range key {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '.', c == '-', c == '_':
+		default:
+			return fmt.Errorf("metadata key %q may only contain letters, digits, '.', '-' and '_'", key)
+		}
+	}
+	return nil
+}
+
+func sanitizeMetadataValue(value string) string {
+	value = strings.ToValidUTF8(value, "\uFFFD")
+	return strings.Map(func(c rune) rune {
+		if unicode.IsControl(c) {
+			return unicode.ReplacementChar
+		}
+		return c
+	}, value)
+}
+
+// SetMetadata records client-supplied metadata, as returned by ParseMetadata
+func (info *Info) SetMetadata(meta map[string]string) {
+	for key, value := range meta {
+		info.Attributes[MetadataPrefix+key] = value
+	}
+}
@@ -0,0 +1,43 @@
+package audit
+
+import (
+	"crypto"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMetadata(t *testing.T) {
+	meta, err := ParseMetadata([]string{"product=foo", "version=1.2.3", "note=a=b", "build.id=", "bad=x\ny\xff"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"product":  "foo",
+		"version":  "1.2.3",
+		"note":     "a=b",
+		"build.id": "",
+		"bad":      "x�y�",
+	}, meta)
+
+	info := New("key", "pkcs7", crypto.SHA256)
+	info.SetMetadata(meta)
+	assert.Equal(t, "1.2.3", info.Attributes["client.meta.version"])
+
+	many := make([]string, MaxMetadataItems+1)
+	for i := range many {
+		many[i] = strings.Repeat("k", i+1) + "=v"
+	}
+	for _, items := range [][]string{
+		{"product"},
+		{"=foo"},
+		{"pro duct=foo"},
+		{"product=foo", "product=bar"},
+		{strings.Repeat("k", MaxMetadataKeyLength+1) + "=v"},
+		{"product=" + strings.Repeat("v", MaxMetadataValueLength+1)},
+		many,
+	} {
+		_, err := ParseMetadata(items)
+		assert.Error(t, err, items)
+	}
+}
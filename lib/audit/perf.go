@@ -0,0 +1,52 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package audit
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Timer accumulates the time spent in one part of a signing operation, such as
+// waiting on the token. It is safe for concurrent use.
+type Timer struct {
+	ns atomic.Int64
+}
+
+// Since adds the time elapsed since start
+func (t *Timer) Since(start time.Time) {
+	t.ns.Add(int64(time.Since(start)))
+}
+
+// Duration returns the accumulated time
+func (t *Timer) Duration() time.Duration {
+	if t == nil {
+		return 0
+	}
+	return time.Duration(t.ns.Load())
+}
+
+// SetPerf records how long signing took, split into time spent waiting on the
+// token, on the timestamp server, and the remainder which is mostly spent
+// transferring and digesting the input
+func (info *Info) SetPerf(elapsed time.Duration) {
+	tokenTime := info.TokenTime.Duration()
+	tsTime := info.TimestampTime.Duration()
+	info.Attributes["perf.token.ms"] = tokenTime.Milliseconds()
+	info.Attributes["perf.timestamp.ms"] = tsTime.Milliseconds()
+	info.Attributes["perf.digest.ms"] = (elapsed - tokenTime - tsTime).Milliseconds()
+}
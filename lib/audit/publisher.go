@@ -0,0 +1,238 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package audit
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog/log"
+
+	"github.com/mind-security/relic/v8/config"
+)
+
+var (
+	metricBuffered = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "audit_buffered_events",
+		Help: "Number of audit records waiting for the AMQP broker to confirm them",
+	})
+	metricDropped = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "audit_dropped_events_total",
+		Help: "Number of audit records discarded because the audit buffer was full",
+	})
+)
+
+const (
+	sendTimeout  = 30 * time.Second
+	staleAge     = 5 * time.Minute
+	pollInterval = time.Minute
+	retryMin     = time.Second
+	retryMax     = time.Minute
+)
+
+// sender publishes records to the broker and waits for each to be confirmed
+type sender interface {
+	Send(ctx context.Context, blob []byte) error
+	Close() error
+}
+
+// Publisher sends audit records to an AMQP exchange over a long-lived
+// connection. Records are written to an on-disk buffer first and only removed
+// once the broker confirms them, so they survive broker outages and restarts.
+// A background goroutine reconnects with backoff and replays the buffer in
+// order.
+type Publisher struct {
+	aconf *config.AmqpConfig
+	buf   *diskBuffer
+	dial  func() (sender, error)
+	conn  sender
+
+	wake    chan struct{}
+	ctx     context.Context
+	cancel  context.CancelFunc
+	stopped chan struct{}
+}
+
+// NewPublisher starts a publisher buffering records in aconf.BufferDir. Any
+// records left in the buffer by an earlier process are sent as well.
+func NewPublisher(aconf *config.AmqpConfig) (*Publisher, error) {
+	if aconf.BufferDir == "" {
+		return nil, errors.New("amqp: bufferdir is not set")
+	}
+	return newPublisher(aconf, func() (sender, error) { return dialSender(aconf) })
+}
+
+func newPublisher(aconf *config.AmqpConfig, dial func() (sender, error)) (*Publisher, error) {
+	buf, err := newDiskBuffer(aconf.BufferDir, aconf.BufferMax)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &Publisher{
+		aconf:   aconf,
+		buf:     buf,
+		dial:    dial,
+		wake:    make(chan struct{}, 1),
+		ctx:     ctx,
+		cancel:  cancel,
+		stopped: make(chan struct{}),
+	}
+	go p.loop()
+	p.kick()
+	return p, nil
+}
+
+// Publish adds a record to the buffer and returns without waiting for it to be
+// sent. If the buffer is full then, depending on the bufferfull option, either
+// ErrBufferFull is returned or the record is discarded and an error logged.
+func (p *Publisher) Publish(info *Info) error {
+	blob, err := info.Marshal()
+	if err != nil {
+		return err
+	}
+	if err := p.buf.Add(blob); err != nil {
+		if errors.Is(err, ErrBufferFull) && p.aconf.BufferFull == config.BufferFullDrop {
+			metricDropped.Inc()
+			log.Error().Str("dir", p.aconf.BufferDir).Str("event", info.EventType()).Msg("audit buffer is full, discarding audit record")
+			return nil
+		}
+		return err
+	}
+	metricBuffered.Inc()
+	p.kick()
+	return nil
+}
+
+// Close stops the background sender and makes a final attempt to send any
+// buffered records until ctx is done. Records that could not be sent stay in
+// the buffer for the next process to use it.
+func (p *Publisher) Close(ctx context.Context) error {
+	p.cancel()
+	<-p.stopped
+	err := p.drain(ctx)
+	p.disconnect()
+	return err
+}
+
+func (p *Publisher) kick() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (p *Publisher) loop() {
+	defer close(p.stopped)
+	var delay time.Duration
+	for {
+		// while backing off, new records wait for the retry
+		wake := p.wake
+		wait := pollInterval
+		if delay > 0 {
+			wake = nil
+			wait = delay
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-p.ctx.Done():
+			timer.Stop()
+			return
+		case <-wake:
+		case <-timer.C:
+		}
+		timer.Stop()
+		if err := p.drain(p.ctx); err != nil {
+			if p.ctx.Err() != nil {
+				return
+			}
+			delay = nextDelay(delay)
+			log.Warn().Err(err).Str("dir", p.aconf.BufferDir).Dur("retry", delay).Msg("failed to publish audit records")
+		} else {
+			delay = 0
+		}
+	}
+}
+
+func nextDelay(delay time.Duration) time.Duration {
+	delay *= 2
+	if delay < retryMin {
+		delay = retryMin
+	} else if delay > retryMax {
+		delay = retryMax
+	}
+	return delay
+}
+
+// send buffered records in order until the buffer is empty or an error occurs
+func (p *Publisher) drain(ctx context.Context) error {
+	if err := p.buf.RecoverStale(staleAge); err != nil {
+		return err
+	}
+	names, err := p.buf.Pending()
+	if err != nil {
+		return err
+	}
+	defer p.updateMetric()
+	for _, name := range names {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if p.conn == nil {
+			conn, err := p.dial()
+			if err != nil {
+				return err
+			}
+			p.conn = conn
+		}
+		blob, ok, err := p.buf.Claim(name)
+		if err != nil {
+			return err
+		} else if !ok {
+			// another process is sending it
+			continue
+		}
+		sctx, cancel := context.WithTimeout(ctx, sendTimeout)
+		err = p.conn.Send(sctx, blob)
+		cancel()
+		if err != nil {
+			p.buf.Release(name)
+			// confirmations may be out of step now, so start over
+			p.disconnect()
+			return err
+		}
+		if err := p.buf.Remove(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Publisher) updateMetric() {
+	if n, err := p.buf.Len(); err == nil {
+		metricBuffered.Set(float64(n))
+	}
+}
+
+func (p *Publisher) disconnect() {
+	if p.conn != nil {
+		p.conn.Close()
+		p.conn = nil
+	}
+}
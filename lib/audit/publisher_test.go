@@ -0,0 +1,163 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mind-security/relic/v8/config"
+)
+
+// fakeBroker confirms records while up and refuses connections while down
+type fakeBroker struct {
+	mu   sync.Mutex
+	up   bool
+	got  []string
+	fail bool
+}
+
+func (b *fakeBroker) dial() (sender, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.up {
+		return nil, errors.New("connection refused")
+	}
+	return b, nil
+}
+
+func (b *fakeBroker) Send(ctx context.Context, blob []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.up || b.fail {
+		return errors.New("message was NACKed")
+	}
+	b.got = append(b.got, string(blob))
+	return nil
+}
+
+func (b *fakeBroker) Close() error { return nil }
+
+func (b *fakeBroker) received() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string(nil), b.got...)
+}
+
+func testRecord(name string) *Info {
+	return &Info{Attributes: map[string]interface{}{"sig.keyname": name}}
+}
+
+func TestPublisherReplay(t *testing.T) {
+	broker := new(fakeBroker)
+	aconf := &config.AmqpConfig{BufferDir: t.TempDir(), BufferMax: 10, BufferFull: config.BufferFullBlock}
+	p, err := newPublisher(aconf, broker.dial)
+	require.NoError(t, err)
+	// broker is down, records are kept
+	for _, name := range []string{"a", "b", "c"} {
+		require.NoError(t, p.Publish(testRecord(name)))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.Error(t, p.Close(ctx))
+	n, err := p.buf.Len()
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+	assert.Empty(t, broker.received())
+
+	// a new publisher sends what was left behind, in order
+	broker.up = true
+	p, err = newPublisher(aconf, broker.dial)
+	require.NoError(t, err)
+	require.NoError(t, p.Publish(testRecord("d")))
+	require.NoError(t, p.Close(ctx))
+	assert.Equal(t, []string{
+		`{"sig.keyname":"a"}`,
+		`{"sig.keyname":"b"}`,
+		`{"sig.keyname":"c"}`,
+		`{"sig.keyname":"d"}`,
+	}, broker.received())
+	n, err = p.buf.Len()
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+}
+
+func TestPublisherNack(t *testing.T) {
+	broker := &fakeBroker{up: true, fail: true}
+	aconf := &config.AmqpConfig{BufferDir: t.TempDir(), BufferMax: 10}
+	p, err := newPublisher(aconf, broker.dial)
+	require.NoError(t, err)
+	require.NoError(t, p.Publish(testRecord("a")))
+	require.Error(t, p.Close(context.Background()))
+	// the record is back in the queue after a failed send
+	names, err := p.buf.Pending()
+	require.NoError(t, err)
+	assert.Len(t, names, 1)
+
+	broker.fail = false
+	p, err = newPublisher(aconf, broker.dial)
+	require.NoError(t, err)
+	require.NoError(t, p.Close(context.Background()))
+	assert.Len(t, broker.received(), 1)
+}
+
+func TestPublisherFull(t *testing.T) {
+	for _, policy := range []string{config.BufferFullBlock, config.BufferFullDrop} {
+		t.Run(policy, func(t *testing.T) {
+			broker := new(fakeBroker)
+			aconf := &config.AmqpConfig{BufferDir: t.TempDir(), BufferMax: 2, BufferFull: policy}
+			p, err := newPublisher(aconf, broker.dial)
+			require.NoError(t, err)
+			defer p.Close(context.Background())
+			require.NoError(t, p.Publish(testRecord("a")))
+			require.NoError(t, p.Publish(testRecord("b")))
+			err = p.Publish(testRecord("c"))
+			if policy == config.BufferFullBlock {
+				assert.ErrorIs(t, err, ErrBufferFull)
+			} else {
+				assert.NoError(t, err)
+			}
+			n, err := p.buf.Len()
+			require.NoError(t, err)
+			assert.Equal(t, 2, n)
+		})
+	}
+}
+
+func TestBufferClaim(t *testing.T) {
+	dir := t.TempDir()
+	b1, err := newDiskBuffer(dir, 0)
+	require.NoError(t, err)
+	b2, err := newDiskBuffer(dir, 0)
+	require.NoError(t, err)
+	require.NoError(t, b1.Add([]byte("x")))
+	names, err := b1.Pending()
+	require.NoError(t, err)
+	require.Len(t, names, 1)
+
+	blob, ok, err := b1.Claim(names[0])
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "x", string(blob))
+	// only one process can claim a record
+	_, ok, err = b2.Claim(names[0])
+	require.NoError(t, err)
+	assert.False(t, ok)
+	pending, err := b2.Pending()
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+
+	// claims abandoned by a process that went away are recovered
+	old := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(filepath.Join(dir, names[0]+sendingSuffix), old, old))
+	require.NoError(t, b2.RecoverStale(staleAge))
+	pending, err = b2.Pending()
+	require.NoError(t, err)
+	assert.Equal(t, names, pending)
+}
@@ -46,31 +46,10 @@ func VerifyMSI(f io.ReaderAt, skipDigests bool) (*MSISignature, error) {
 	if err != nil {
 		return nil, err
 	}
-	var sig, exsig []byte
-	files, err := cdf.ListDir(nil)
+	sig, exsig, err := readMSISignature(cdf)
 	if err != nil {
 		return nil, err
 	}
-	for _, item := range files {
-		name := item.Name()
-		if name == msiDigitalSignature {
-			r, err := cdf.ReadStream(item)
-			if err == nil {
-				sig, err = io.ReadAll(r)
-			}
-			if err != nil {
-				return nil, err
-			}
-		} else if name == msiDigitalSignatureEx {
-			r, err := cdf.ReadStream(item)
-			if err == nil {
-				exsig, err = io.ReadAll(r)
-			}
-			if err != nil {
-				return nil, err
-			}
-		}
-	}
 	if len(sig) == 0 {
 		return nil, sigerrors.NotSignedError{Type: "MSI"}
 	}
@@ -122,6 +101,35 @@ func VerifyMSI(f io.ReaderAt, skipDigests bool) (*MSISignature, error) {
 	return msisig, nil
 }
 
+// read the signature and extended signature streams, if present
+func readMSISignature(cdf *comdoc.ComDoc) (sig, exsig []byte, err error) {
+	files, err := cdf.ListDir(nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, item := range files {
+		name := item.Name()
+		if name == msiDigitalSignature {
+			r, err := cdf.ReadStream(item)
+			if err == nil {
+				sig, err = io.ReadAll(r)
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+		} else if name == msiDigitalSignatureEx {
+			r, err := cdf.ReadStream(item)
+			if err == nil {
+				exsig, err = io.ReadAll(r)
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	return sig, exsig, nil
+}
+
 // Calculate the digest (imprint) of a MSI file. If extended is true then the
 // MsiDigitalSignatureEx value is also hashed and returned.
 func DigestMSI(cdf *comdoc.ComDoc, hash crypto.Hash, extended bool) (imprint, prehash []byte, err error) {
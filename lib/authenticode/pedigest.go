@@ -37,6 +37,7 @@ type PEDigest struct {
 	Imprint    []byte
 	PageHashes []byte
 	Hash       crypto.Hash
+	PageHash   crypto.Hash
 	markers    *peHeaderValues
 }
 
@@ -45,6 +46,10 @@ const dosHeaderSize = 64
 // Calculate a digest (message imprint) over a PE image. Returns a structure
 // that can be used to sign the imprint and produce a binary patch to apply the
 // signature.
+//
+// If doPageHash is set then a table of page hashes is also calculated. Only
+// SHA-1 (V1) and SHA-256 (V2) page hashes are defined, so SHA-1 images get
+// SHA-1 page hashes and all others get SHA-256 page hashes.
 func DigestPE(r io.Reader, hash crypto.Hash, doPageHash bool) (*PEDigest, error) {
 	// Read and buffer all the headers
 	buf := bytes.NewBuffer(make([]byte, 0, 4096))
@@ -98,24 +103,53 @@ func DigestPE(r io.Reader, hash crypto.Hash, doPageHash bool) (*PEDigest, error)
 	if err != nil {
 		return nil, err
 	}
-	return &PEDigest{origSize, certStart, imprint, pagehashes, hash, hvals}, nil
+	return &PEDigest{origSize, certStart, imprint, pagehashes, hash, digester.pageHashFunc, hvals}, nil
+}
+
+// Subsystem returns the environment that the digested image runs in, one of
+// the pe.IMAGE_SUBSYSTEM_* values
+func (pd *PEDigest) Subsystem() uint16 {
+	return pd.markers.subsystem
+}
+
+// IsEFISubsystem returns true if the subsystem is a UEFI application, driver
+// or option ROM
+func IsEFISubsystem(subsystem uint16) bool {
+	switch subsystem {
+	case pe.IMAGE_SUBSYSTEM_EFI_APPLICATION,
+		pe.IMAGE_SUBSYSTEM_EFI_BOOT_SERVICE_DRIVER,
+		pe.IMAGE_SUBSYSTEM_EFI_RUNTIME_DRIVER,
+		pe.IMAGE_SUBSYSTEM_EFI_ROM:
+		return true
+	}
+	return false
 }
 
 type imageHasher struct {
-	hashFunc    crypto.Hash
-	imageDigest hash.Hash
-	pageHashes  []byte
-	zeroPage    []byte
-	pageBuf     []byte
-	doPageHash  bool
-	lastPage    uint32
+	pageHashFunc crypto.Hash
+	imageDigest  hash.Hash
+	pageHashes   []byte
+	zeroPage     []byte
+	pageBuf      []byte
+	doPageHash   bool
+	lastPage     uint32
+}
+
+// PageHashFunc returns the hash used for page hashes alongside an image digest
+// of the given type
+func PageHashFunc(hash crypto.Hash) crypto.Hash {
+	if hash == crypto.SHA1 {
+		return crypto.SHA1
+	}
+	return crypto.SHA256
 }
 
 func setupDigester(hash crypto.Hash, header []byte, hvals *peHeaderValues, sections []pe.SectionHeader32, doPageHash bool) *imageHasher {
 	imageDigest := hash.New()
 	imageDigest.Write(header)
-	h := &imageHasher{hashFunc: hash, imageDigest: imageDigest, doPageHash: doPageHash}
+	h := &imageHasher{imageDigest: imageDigest, doPageHash: doPageHash}
 	if doPageHash {
+		h.pageHashFunc = PageHashFunc(hash)
 		h.zeroPage = make([]byte, hvals.pageSize) // full page of zeroes, for padding
 		h.pageBuf = make([]byte, hvals.pageSize)  // scratch space
 		// make space for all the page hashes
@@ -124,7 +158,7 @@ func setupDigester(hash crypto.Hash, header []byte, hvals *peHeaderValues, secti
 			spage := (sh.SizeOfRawData + hvals.pageSize - 1) / hvals.pageSize
 			pages += int(spage)
 		}
-		h.pageHashes = make([]byte, 0, pages*(4+hash.Size()))
+		h.pageHashes = make([]byte, 0, pages*(4+h.pageHashFunc.Size()))
 		// the first page is the headers padded out to a full page with the
 		// signature bits snipped out in the same way as for the regular
 		// imprint. the padding is done based on the full size of the
@@ -175,10 +209,10 @@ func (h *imageHasher) addPageHash(offset uint32, blob []byte, removed int) {
 	h.pageHashes = append(h.pageHashes, obytes[:]...)
 	if len(blob) == 0 {
 		// last "page" has a null digest
-		h.pageHashes = append(h.pageHashes, make([]byte, h.hashFunc.Size())...)
+		h.pageHashes = append(h.pageHashes, make([]byte, h.pageHashFunc.Size())...)
 		return
 	}
-	d := h.hashFunc.New()
+	d := h.pageHashFunc.New()
 	d.Write(blob)
 	needzero := len(h.zeroPage) - len(blob) - removed
 	d.Write(h.zeroPage[:needzero])
@@ -257,6 +291,7 @@ func readOptHeader(r io.Reader, d io.Writer, peStart int64, fh *pe.FileHeader) (
 		dd4Start = 128
 		hvals.sizeOfHdr = int64(opt.SizeOfHeaders)
 		hvals.fileAlign = opt.FileAlignment
+		hvals.subsystem = opt.Subsystem
 	case optHeaderMagicPE32Plus:
 		// PE32+
 		var opt pe.OptionalHeader64
@@ -270,6 +305,7 @@ func readOptHeader(r io.Reader, d io.Writer, peStart int64, fh *pe.FileHeader) (
 		dd4Start = 144
 		hvals.sizeOfHdr = int64(opt.SizeOfHeaders)
 		hvals.fileAlign = opt.FileAlignment
+		hvals.subsystem = opt.Subsystem
 	default:
 		return nil, errors.New("unrecognized optional header magic")
 	}
@@ -353,4 +389,6 @@ type peHeaderValues struct {
 	fileAlign uint32
 	// file offset and size of the certificate table
 	certStart, certSize int64
+	// environment the image runs in
+	subsystem uint16
 }
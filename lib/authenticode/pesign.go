@@ -63,7 +63,7 @@ func (pd *PEDigest) GetIndirect() (indirect SpcIndirectDataContentPe, err error)
 
 func (pd *PEDigest) imprintPageHashes(indirect *SpcIndirectDataContentPe) error {
 	var attr SpcAttributePageHashes
-	switch pd.Hash {
+	switch pd.PageHash {
 	case crypto.SHA1:
 		attr.Type = OidSpcPageHashV1
 	case crypto.SHA256:
@@ -91,9 +91,7 @@ func (pd *PEDigest) imprintPageHashes(indirect *SpcIndirectDataContentPe) error
 // digested image with a new one
 func (pd *PEDigest) MakePatch(sig []byte) (*binpatch.PatchSet, error) {
 	// pack new cert table
-	padded := (len(sig) + 7) / 8 * 8
 	info := certInfo{
-		Length:          uint32(8 + padded),
 		Revision:        0x0200,
 		CertificateType: 0x0002,
 	}
@@ -102,9 +100,7 @@ func (pd *PEDigest) MakePatch(sig []byte) (*binpatch.PatchSet, error) {
 	if pad2 != 0 {
 		buf.Write(make([]byte, pad2))
 	}
-	_ = binary.Write(&buf, binary.LittleEndian, info)
-	_, _ = buf.Write(sig)
-	_, _ = buf.Write(make([]byte, padded-len(sig)))
+	writeCertEntry(&buf, info, sig)
 	// pack data directory
 	certTbl := buf.Bytes()
 	var dd pe.DataDirectory
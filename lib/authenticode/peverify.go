@@ -25,6 +25,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"strings"
 
 	"github.com/mind-security/relic/v8/lib/pkcs7"
 	"github.com/mind-security/relic/v8/lib/pkcs9"
@@ -122,6 +123,9 @@ func checkSignatures(blob []byte, image io.ReadSeeker) ([]PESignature, error) {
 	if image == nil {
 		return sigs, nil
 	}
+	// a page hash mismatch is more specific than an image digest mismatch, so
+	// check all the page hashes before reporting the latter
+	var digestErr error
 	for hash := range allhashes {
 		imagehash := values[hash]
 		pagehashes := phvalues[hash]
@@ -133,14 +137,30 @@ func checkSignatures(blob []byte, image io.ReadSeeker) ([]PESignature, error) {
 		if err != nil {
 			return sigs, err
 		}
-		if imagehash != nil && !hmac.Equal(digest.Imprint, imagehash) {
-			return sigs, fmt.Errorf("digest mismatch: %x != %x", digest.Imprint, imagehash)
-		}
 		if pagehashes != nil && !hmac.Equal(digest.PageHashes, pagehashes) {
-			return sigs, fmt.Errorf("page hash mismatch")
+			return sigs, comparePageHashes(hash, digest.PageHashes, pagehashes)
+		}
+		if imagehash != nil && !hmac.Equal(digest.Imprint, imagehash) && digestErr == nil {
+			digestErr = fmt.Errorf("digest mismatch: %x != %x", digest.Imprint, imagehash)
+		}
+	}
+	return sigs, digestErr
+}
+
+// Compare two page hash tables entry by entry and report which pages differ
+func comparePageHashes(hash crypto.Hash, calculated, expected []byte) error {
+	entrySize := 4 + hash.Size()
+	if len(calculated) != len(expected) {
+		return fmt.Errorf("page hash mismatch: image has %d pages but signature has %d", len(calculated)/entrySize, len(expected)/entrySize)
+	}
+	var bad []string
+	for i := 0; i < len(calculated); i += entrySize {
+		offset := binary.LittleEndian.Uint32(calculated[i:])
+		if offset != binary.LittleEndian.Uint32(expected[i:]) || !hmac.Equal(calculated[i+4:i+entrySize], expected[i+4:i+entrySize]) {
+			bad = append(bad, fmt.Sprintf("0x%x", offset))
 		}
 	}
-	return sigs, nil
+	return fmt.Errorf("page hash mismatch for page(s) at %s", strings.Join(bad, ", "))
 }
 
 func checkSignature(der []byte) (*PESignature, error) {
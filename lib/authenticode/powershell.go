@@ -39,7 +39,8 @@ import (
 	"github.com/mind-security/relic/v8/signers/sigerrors"
 )
 
-// Type of signature formatting used for different PowerShell file formats
+// Type of signature formatting used for different PowerShell and Windows
+// Script Host file formats
 type PsSigStyle int
 
 const (
@@ -49,6 +50,10 @@ const (
 	SigStyleXML
 	// C# style used by .mof files
 	SigStyleC
+	// JScript style used by .js files
+	SigStyleJS
+	// VBScript style used by .vbs files
+	SigStyleVBS
 )
 
 var psExtMap = map[string]PsSigStyle{
@@ -59,26 +64,40 @@ var psExtMap = map[string]PsSigStyle{
 	".psm1":   SigStyleHash,
 	".cdxml":  SigStyleXML,
 	".mof":    SigStyleC,
+	".js":     SigStyleJS,
+	".vbs":    SigStyleVBS,
 }
 
 const psBegin = "SIG # Begin signature block"
 const psEnd = "SIG # End signature block"
 
-type sigStyle struct{ start, end string }
+// The Windows Script Host puts the SIG marker on every line, not just the
+// first and last, and wraps the base64 at 44 columns instead of 64
+const wshBegin = "Begin signature block"
+const wshEnd = "End signature block"
+
+type sigStyle struct {
+	start, end   string
+	begin, final string
+	width        int
+	sip          SpcSipInfo
+}
 
 var psStyles = map[PsSigStyle]sigStyle{
-	SigStyleHash: {"# ", ""},
-	SigStyleXML:  {"<!-- ", " -->"},
-	SigStyleC:    {"/* ", " */"},
+	SigStyleHash: {"# ", "", psBegin, psEnd, 64, psSipInfo},
+	SigStyleXML:  {"<!-- ", " -->", psBegin, psEnd, 64, psSipInfo},
+	SigStyleC:    {"/* ", " */", psBegin, psEnd, 64, psSipInfo},
+	SigStyleJS:   {"// SIG // ", "", wshBegin, wshEnd, 44, jsSipInfo},
+	SigStyleVBS:  {"'' SIG '' ", "", wshBegin, wshEnd, 44, vbsSipInfo},
 }
 
-// Get the PowerShell signature style for a filename or extension
+// Get the script signature style for a filename or extension
 func GetSigStyle(filename string) (PsSigStyle, bool) {
 	style, ok := psExtMap[filepath.Ext(filename)]
 	return style, ok
 }
 
-// Return all supported PowerShell signature styles
+// Return all supported script signature styles
 func AllSigStyles() []string {
 	var ret []string
 	for k := range psExtMap {
@@ -95,17 +114,17 @@ type PsDigest struct {
 	IsUtf16           bool
 }
 
-// Digest a PowerShell script from a stream, returning the sum and the length of the digested bytes.
+// Digest a PowerShell or Windows Script Host script from a stream, returning the sum and the length of the digested bytes.
 //
-// PowerShell scripts are digested in UTF-16-LE format so, unless already in
-// that format, the text is converted first. Existing signatures are discarded.
+// Scripts are digested in UTF-16-LE format so, unless already in that
+// format, the text is converted first. Existing signatures are discarded.
 func DigestPowershell(r io.Reader, style PsSigStyle, hash crypto.Hash) (*PsDigest, error) {
 	si, ok := psStyles[style]
 	if !ok {
 		return nil, errors.New("invalid powershell signature style")
 	}
 	br := bufio.NewReader(r)
-	isUtf16, first, _ := detectUtf16(br, si.start, si.end)
+	isUtf16, first, _ := detectUtf16(br, si)
 	d := hash.New()
 	var textSize, sigSize int64
 	var saved string
@@ -145,9 +164,9 @@ func DigestPowershell(r io.Reader, style PsSigStyle, hash crypto.Hash) (*PsDiges
 	return &PsDigest{d.Sum(nil), hash, textSize, sigSize, style, isUtf16}, nil
 }
 
-func detectUtf16(br *bufio.Reader, start, end string) (bool, string, string) {
-	first := start + psBegin + end + "\r\n"
-	last := start + psEnd + end + "\r\n"
+func detectUtf16(br *bufio.Reader, si sigStyle) (bool, string, string) {
+	first := si.start + si.begin + si.end + "\r\n"
+	last := si.start + si.final + si.end + "\r\n"
 	if bom, err := br.Peek(2); err == nil && bom[0] == 0xff && bom[1] == 0xfe {
 		// UTF-16-LE
 		return true, toUtf16(first), toUtf16(last)
@@ -161,7 +180,7 @@ type PowershellSignature struct {
 	HashFunc crypto.Hash
 }
 
-// Verify a PowerShell script. The signature "style" must already have been
+// Verify a PowerShell or Windows Script Host script. The signature "style" must already have been
 // determined by calling GetSigStyle
 func VerifyPowershell(r io.ReadSeeker, style PsSigStyle, skipDigests bool) (*PowershellSignature, error) {
 	si, ok := psStyles[style]
@@ -169,14 +188,14 @@ func VerifyPowershell(r io.ReadSeeker, style PsSigStyle, skipDigests bool) (*Pow
 		return nil, errors.New("invalid powershell signature style")
 	}
 	br := bufio.NewReader(r)
-	isUtf16, first, last := detectUtf16(br, si.start, si.end)
+	isUtf16, first, last := detectUtf16(br, si)
 	found := false
 	var textSize int64
 	var pkcsb bytes.Buffer
 	for {
 		line, err := readLine(br, isUtf16)
 		if err == io.EOF && !found {
-			return nil, sigerrors.NotSignedError{Type: "powershell document"}
+			return nil, sigerrors.NotSignedError{Type: "script"}
 		} else if err != nil {
 			return nil, err
 		}
@@ -188,7 +207,7 @@ func VerifyPowershell(r io.ReadSeeker, style PsSigStyle, skipDigests bool) (*Pow
 				lstr = fromUtf16(line)
 			}
 			if !strings.HasPrefix(lstr, si.start) || !strings.HasSuffix(lstr, si.end+"\r\n") {
-				return nil, errors.New("malformed powershell signature")
+				return nil, errors.New("malformed script signature")
 			}
 			i := len(si.start)
 			j := len(lstr) - len(si.end) - 2
@@ -228,6 +247,9 @@ func VerifyPowershell(r io.ReadSeeker, style PsSigStyle, skipDigests bool) (*Pow
 	if err := psd.Content.ContentInfo.Unmarshal(indirect); err != nil {
 		return nil, err
 	}
+	if !bytes.Equal(indirect.Data.Value.UUID, si.sip.UUID) {
+		return nil, errors.New("signature is for a different script type")
+	}
 	hash, err := x509tools.PkixDigestToHashE(indirect.MessageDigest.DigestAlgorithm)
 	if err != nil {
 		return nil, err
@@ -258,7 +280,11 @@ func VerifyPowershell(r io.ReadSeeker, style PsSigStyle, skipDigests bool) (*Pow
 
 // Sign a previously digested PowerShell script and return the Authenticode structure
 func (pd *PsDigest) Sign(ctx context.Context, cert *certloader.Certificate, params *OpusParams) (*binpatch.PatchSet, *pkcs9.TimestampedSignature, error) {
-	ts, err := SignSip(ctx, pd.Imprint, pd.HashFunc, psSipInfo, cert, params)
+	si, ok := psStyles[pd.SigStyle]
+	if !ok {
+		return nil, nil, errors.New("invalid powershell signature style")
+	}
+	ts, err := SignSip(ctx, pd.Imprint, pd.HashFunc, si.sip, cert, params)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -276,16 +302,16 @@ func (pd *PsDigest) MakePatch(sig []byte) (*binpatch.PatchSet, error) {
 		return nil, errors.New("invalid powershell signature style")
 	}
 	var buf bytes.Buffer
-	buf.WriteString("\r\n" + si.start + psBegin + si.end + "\r\n")
+	buf.WriteString("\r\n" + si.start + si.begin + si.end + "\r\n")
 	b64 := base64.StdEncoding.EncodeToString(sig)
-	for i := 0; i < len(b64); i += 64 {
-		j := i + 64
+	for i := 0; i < len(b64); i += si.width {
+		j := i + si.width
 		if j > len(b64) {
 			j = len(b64)
 		}
 		buf.WriteString(si.start + b64[i:j] + si.end + "\r\n")
 	}
-	buf.WriteString(si.start + psEnd + si.end + "\r\n")
+	buf.WriteString(si.start + si.final + si.end + "\r\n")
 	patch := binpatch.New()
 	var encoded []byte
 	if pd.IsUtf16 {
@@ -0,0 +1,118 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package authenticode
+
+import (
+	"bytes"
+	"context"
+	"debug/pe"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/mind-security/relic/v8/lib/binpatch"
+	"github.com/mind-security/relic/v8/lib/comdoc"
+	"github.com/mind-security/relic/v8/lib/pkcs9"
+	"github.com/mind-security/relic/v8/signers/sigerrors"
+)
+
+// RetimestampPE adds a new timestamp to each signature in a PE image, keeping
+// the existing ones. The returned patch replaces the certificate table. The
+// image checksum must be fixed after applying it.
+func RetimestampPE(ctx context.Context, r io.ReadSeeker, timestamper pkcs9.Timestamper) (*binpatch.PatchSet, []*pkcs9.TimestampedSignature, error) {
+	hvals, err := findSignatures(r)
+	if err != nil {
+		return nil, nil, err
+	} else if hvals.certSize == 0 {
+		return nil, nil, sigerrors.NotSignedError{Type: "PECOFF"}
+	}
+	blob := make([]byte, hvals.certSize)
+	if _, err := r.Seek(hvals.certStart, 0); err != nil {
+		return nil, nil, err
+	}
+	if _, err := io.ReadFull(r, blob); err != nil {
+		return nil, nil, err
+	}
+	var certTbl bytes.Buffer
+	var sigs []*pkcs9.TimestampedSignature
+	for len(blob) != 0 {
+		if len(blob) < 8 {
+			return nil, nil, errors.New("invalid certificate table")
+		}
+		var info certInfo
+		_ = binaryReadBytes(blob[:8], &info)
+		end := (int(info.Length) + 7) / 8 * 8
+		size := int(info.Length) - 8
+		if end > len(blob) || size < 0 {
+			return nil, nil, errors.New("invalid certificate table")
+		}
+		der := blob[8 : 8+size]
+		blob = blob[end:]
+		if info.CertificateType != 0x0002 {
+			// not a PKCS#7 signature, keep as-is
+			writeCertEntry(&certTbl, info, der)
+			continue
+		}
+		ts, err := pkcs9.AddTimestamp(ctx, der, timestamper, true)
+		if err != nil {
+			return nil, nil, err
+		}
+		writeCertEntry(&certTbl, info, ts.Raw)
+		sigs = append(sigs, ts)
+	}
+	if hvals.certStart >= (1 << 32) {
+		return nil, nil, errors.New("PE file is too big")
+	}
+	dd := pe.DataDirectory{
+		VirtualAddress: uint32(hvals.certStart),
+		Size:           uint32(certTbl.Len()),
+	}
+	var ddBuf bytes.Buffer
+	_ = binary.Write(&ddBuf, binary.LittleEndian, dd)
+	patch := binpatch.New()
+	patch.Add(hvals.posDDCert, 8, ddBuf.Bytes())
+	patch.Add(hvals.certStart, hvals.certSize, certTbl.Bytes())
+	return patch, sigs, nil
+}
+
+// write one certificate table entry, padded to 8 bytes
+func writeCertEntry(w *bytes.Buffer, info certInfo, sig []byte) {
+	padded := (len(sig) + 7) / 8 * 8
+	info.Length = uint32(8 + padded)
+	_ = binary.Write(w, binary.LittleEndian, info)
+	w.Write(sig)
+	w.Write(make([]byte, padded-len(sig)))
+}
+
+// RetimestampMSI adds a new timestamp to the signature of a MSI file, keeping
+// the existing ones. cdf must be open for writing.
+func RetimestampMSI(ctx context.Context, cdf *comdoc.ComDoc, timestamper pkcs9.Timestamper) (*pkcs9.TimestampedSignature, error) {
+	sig, exsig, err := readMSISignature(cdf)
+	if err != nil {
+		return nil, err
+	} else if len(sig) == 0 {
+		return nil, sigerrors.NotSignedError{Type: "MSI"}
+	}
+	ts, err := pkcs9.AddTimestamp(ctx, sig, timestamper, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := InsertMSISignature(cdf, ts.Raw, exsig); err != nil {
+		return nil, err
+	}
+	return ts, nil
+}
@@ -22,6 +22,8 @@ import (
 	"encoding/binary"
 	"time"
 	"unicode/utf16"
+
+	"github.com/mind-security/relic/v8/lib/pkcs7"
 )
 
 var (
@@ -42,6 +44,8 @@ var (
 	OidCatalogNameValue       = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 12, 2, 1}
 	OidCatalogMemberInfo      = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 12, 2, 2}
 	OidCatalogMemberInfoV2    = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 12, 2, 3}
+	// Unsigned attribute holding additional signatures, e.g. from signtool /as
+	OidSpcNestedSignature = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 2, 4, 1}
 
 	SpcUUIDPageHashes = []byte{0xa6, 0xb5, 0x86, 0xd5, 0xb4, 0xa1, 0x24, 0x66, 0xae, 0x05, 0xa2, 0x17, 0xda, 0x8e, 0x60, 0xd6}
 
@@ -51,9 +55,12 @@ var (
 	// SIP related DLLs are registered at
 	// HKEY_LOCAL_MACHINE\SOFTWARE\Microsoft\Cryptography\OID\EncodingType 0\CryptSIPDllCreateIndirectData
 	// although these particular ClassIDs do not seem to appear there.
-	// Relevant DLLs include: WINTRUST.DLL, MSISIP.DLL, pwrshsip.dll
+	// Relevant DLLs include: WINTRUST.DLL, MSISIP.DLL, pwrshsip.dll, wshext.dll
 	SpcUUIDSipInfoMsi = []byte{0xf1, 0x10, 0x0c, 0x00, 0x00, 0x00, 0x00, 0x00, 0xc0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x46}
 	SpcUUIDSipInfoPs  = []byte{0x1f, 0xcc, 0x3b, 0x60, 0x59, 0x4b, 0x08, 0x4e, 0xb7, 0x24, 0xd2, 0xc6, 0x29, 0x7e, 0xf3, 0x51}
+	// Windows Script Host JScript and VBScript
+	SpcUUIDSipInfoJs  = []byte{0x10, 0xe0, 0xc9, 0x06, 0xce, 0x38, 0xd4, 0x11, 0xa2, 0xa3, 0x00, 0x10, 0x4b, 0xd3, 0x50, 0x90}
+	SpcUUIDSipInfoVbs = []byte{0x4e, 0xf0, 0x29, 0x16, 0x99, 0x27, 0xb5, 0x4d, 0x8f, 0xe5, 0xac, 0xe1, 0x0f, 0x17, 0xeb, 0xab}
 
 	// This one is used in V1 security catalogs
 	CryptSipCreateIndirectData = "{C689AAB8-8E78-11D0-8C47-00C04FC295EE}"
@@ -63,6 +70,10 @@ var (
 	msiDigitalSignatureEx = "\x05MsiDigitalSignatureEx"
 )
 
+func init() {
+	pkcs7.RegisterAttribute(OidSpcStatementType, OidSpcSpOpusInfo, OidSpcNestedSignature)
+}
+
 type SpcIndirectDataContentPe struct {
 	Data          SpcAttributePeImageData
 	MessageDigest DigestInfo
@@ -152,6 +163,8 @@ type SpcSipInfo struct {
 
 var msiSipInfo = SpcSipInfo{1, SpcUUIDSipInfoMsi, 0, 0, 0, 0, 0}
 var psSipInfo = SpcSipInfo{65536, SpcUUIDSipInfoPs, 0, 0, 0, 0, 0}
+var jsSipInfo = SpcSipInfo{65536, SpcUUIDSipInfoJs, 0, 0, 0, 0, 0}
+var vbsSipInfo = SpcSipInfo{65536, SpcUUIDSipInfoVbs, 0, 0, 0, 0, 0}
 
 type CertTrustList struct {
 	SubjectUsage     []asn1.ObjectIdentifier
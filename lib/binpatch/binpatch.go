@@ -189,6 +189,17 @@ func (p *PatchSet) applyRewrite(infile *os.File, outpath string) error {
 		return err
 	}
 	defer outfile.Close()
+	if err := p.ApplyStream(infile, outfile); err != nil {
+		return err
+	}
+	infile.Close()
+	return outfile.Commit()
+}
+
+// ApplyStream reads the original contents from r and writes the patched
+// result to w
+func (p *PatchSet) ApplyStream(r io.Reader, w io.Writer) error {
+	seeker, _ := r.(io.Seeker)
 	var pos int64
 	for i, patch := range p.Patches {
 		blob := p.Blobs[i]
@@ -198,28 +209,29 @@ func (p *PatchSet) applyRewrite(infile *os.File, outpath string) error {
 		}
 		// Copy data before the patch
 		if delta > 0 {
-			if _, err := io.CopyN(outfile, infile, delta); err != nil {
+			if _, err := io.CopyN(w, r, delta); err != nil {
 				return err
 			}
 			pos += delta
 		}
-		// Skip the old data on the input file
+		// Skip the old data on the input
 		delta = int64(patch.OldSize)
-		if _, err := infile.Seek(delta, io.SeekCurrent); err != nil {
+		if seeker != nil {
+			if _, err := seeker.Seek(delta, io.SeekCurrent); err != nil {
+				return err
+			}
+		} else if _, err := io.CopyN(io.Discard, r, delta); err != nil {
 			return err
 		}
 		pos += delta
-		// Write the new data to the output file
-		if _, err := outfile.Write(blob); err != nil {
+		// Write the new data to the output
+		if _, err := w.Write(blob); err != nil {
 			return err
 		}
 	}
 	// Copy everything after the last patch
-	if _, err := io.Copy(outfile, infile); err != nil {
-		return err
-	}
-	infile.Close()
-	return outfile.Commit()
+	_, err := io.Copy(w, r)
+	return err
 }
 
 func canOverwrite(ininfo, outinfo os.FileInfo) bool {
@@ -19,6 +19,8 @@ package certloader
 import (
 	"bytes"
 	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/x509"
 	"encoding/pem"
 	"errors"
@@ -29,9 +31,12 @@ import (
 
 	"github.com/ProtonMail/go-crypto/openpgp"
 	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	pgpecdsa "github.com/ProtonMail/go-crypto/openpgp/ecdsa"
+	"github.com/ProtonMail/go-crypto/openpgp/eddsa"
 	"github.com/ProtonMail/go-crypto/openpgp/packet"
 
 	"github.com/mind-security/relic/v8/lib/passprompt"
+	"github.com/mind-security/relic/v8/lib/pgptools"
 )
 
 // Parse and decrypt a private key. It can be a RSA or ECDA key in PKCS#1 or
@@ -121,5 +126,19 @@ func parsePgpPrivateKey(blob []byte, prompt passprompt.PasswordGetter) (crypto.P
 			}
 		}
 	}
+	// use the standard types so the key works as a crypto.Signer
+	switch key := entity.PrivateKey.PrivateKey.(type) {
+	case *eddsa.PrivateKey:
+		if _, ok := pgptools.PublicKey(&entity.PrivateKey.PublicKey).(ed25519.PublicKey); !ok {
+			return nil, errors.New("unsupported EdDSA curve")
+		}
+		return ed25519.NewKeyFromSeed(key.D), nil
+	case *pgpecdsa.PrivateKey:
+		pub, ok := pgptools.PublicKey(&entity.PrivateKey.PublicKey).(*ecdsa.PublicKey)
+		if !ok {
+			return nil, errors.New("unsupported ECDSA curve")
+		}
+		return &ecdsa.PrivateKey{PublicKey: *pub, D: key.D}, nil
+	}
 	return entity.PrivateKey.PrivateKey, nil
 }
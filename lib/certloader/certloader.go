@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rsa"
 	"crypto/tls"
 	"crypto/x509"
@@ -31,6 +32,7 @@ import (
 	"github.com/ProtonMail/go-crypto/openpgp"
 	"github.com/ProtonMail/go-crypto/openpgp/packet"
 
+	"github.com/mind-security/relic/v8/lib/pgptools"
 	"github.com/mind-security/relic/v8/lib/pkcs7"
 	"github.com/mind-security/relic/v8/lib/pkcs9"
 	"github.com/mind-security/relic/v8/lib/x509tools"
@@ -100,6 +102,45 @@ func (s *Certificate) TLS() tls.Certificate {
 	return tls.Certificate{Leaf: s.Leaf, Certificate: raw, PrivateKey: s.PrivateKey}
 }
 
+// selectLeaf makes the certificate matching the given key the leaf, so that a
+// bundle can list the leaf and intermediates in any order
+func (s *Certificate) selectLeaf(key crypto.PrivateKey) bool {
+	for i, cert := range s.Certificates {
+		if x509tools.SameKey(key, cert.PublicKey) {
+			s.Leaf = cert
+			s.Certificates = append([]*x509.Certificate{cert}, append(s.Certificates[:i:i], s.Certificates[i+1:]...)...)
+			return true
+		}
+	}
+	return false
+}
+
+// AddChain appends intermediate certificates from a PEM or DER blob.
+// Certificates already in the chain and self-signed roots are skipped.
+func (s *Certificate) AddChain(blob []byte) error {
+	certs, err := ParseX509Certificates(blob)
+	if err != nil {
+		return err
+	}
+	for _, cert := range certs {
+		if bytes.Equal(cert.RawIssuer, cert.RawSubject) {
+			// omit root CA
+			continue
+		}
+		var dupe bool
+		for _, existing := range s.Certificates {
+			if existing.Equal(cert) {
+				dupe = true
+				break
+			}
+		}
+		if !dupe {
+			s.Certificates = append(s.Certificates, cert)
+		}
+	}
+	return nil
+}
+
 // Parse a private key from a DER block
 // See crypto/tls.parsePrivateKey
 func parsePrivateKey(der []byte) (crypto.PrivateKey, error) {
@@ -110,6 +151,8 @@ func parsePrivateKey(der []byte) (crypto.PrivateKey, error) {
 		switch key := key.(type) {
 		case *rsa.PrivateKey, *ecdsa.PrivateKey:
 			return key, nil
+		case ed25519.PrivateKey:
+			return key, nil
 		default:
 			return nil, errors.New("tls: found unknown private key type in PKCS#8 wrapping")
 		}
@@ -191,6 +234,11 @@ func LoadX509KeyPair(certFile, keyFile string) (*Certificate, error) {
 	if err != nil {
 		return nil, err
 	}
+	return ParseX509KeyPair(certblob, keyblob)
+}
+
+// Parse a X509 certificate chain and the matching private key
+func ParseX509KeyPair(certblob, keyblob []byte) (*Certificate, error) {
 	key, err := ParseAnyPrivateKey(keyblob, nil)
 	if err != nil {
 		return nil, err
@@ -225,7 +273,7 @@ func LoadTokenCertificates(key crypto.PrivateKey, x509cert, pgpcert string, x509
 		if err != nil {
 			return nil, err
 		}
-		if !x509tools.SameKey(key, cert.Leaf.PublicKey) {
+		if !cert.selectLeaf(key) {
 			return nil, errors.New("certificate does not match key in token")
 		}
 		cert.PrivateKey = key
@@ -245,20 +293,35 @@ func LoadTokenCertificates(key crypto.PrivateKey, x509cert, pgpcert string, x509
 			return nil, fmt.Errorf("expected exactly 1 entity in pgp certificate %s", pgpcert)
 		}
 		entity := keyring[0]
-		priv := &packet.PrivateKey{
-			PublicKey:  *entity.PrimaryKey,
-			Encrypted:  false,
-			PrivateKey: key,
-		}
-		if !x509tools.SameKey(key, priv.PublicKey.PublicKey) {
-			return nil, errors.New("certificate does not match key in token")
+		if err := attachPgpKey(entity, key); err != nil {
+			return nil, err
 		}
-		entity.PrivateKey = priv
 		cert.PgpKey = entity
 	}
 	return cert, nil
 }
 
+// attachPgpKey finds the primary key or subkey of entity that matches the
+// token key and attaches the private key to it. When the token holds a signing
+// subkey, the primary key may be kept offline.
+func attachPgpKey(entity *openpgp.Entity, key crypto.PrivateKey) error {
+	if x509tools.SameKey(key, pgptools.PublicKey(entity.PrimaryKey)) {
+		entity.PrivateKey = &packet.PrivateKey{PublicKey: *entity.PrimaryKey, PrivateKey: key}
+		return nil
+	}
+	for i, sub := range entity.Subkeys {
+		if !x509tools.SameKey(key, pgptools.PublicKey(sub.PublicKey)) {
+			continue
+		}
+		if sub.Sig == nil || !sub.Sig.FlagsValid || !sub.Sig.FlagSign {
+			return fmt.Errorf("PGP subkey %016X is not a signing key", sub.PublicKey.KeyId)
+		}
+		entity.Subkeys[i].PrivateKey = &packet.PrivateKey{PublicKey: *sub.PublicKey, PrivateKey: key}
+		return nil
+	}
+	return errors.New("certificate does not match key in token")
+}
+
 type errNoCerts struct{}
 
 func (errNoCerts) Error() string {
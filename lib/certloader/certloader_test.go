@@ -0,0 +1,67 @@
+package certloader
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func issue(t *testing.T, name string, serial int64, parent *x509.Certificate, parentKey crypto.Signer) (*x509.Certificate, crypto.Signer) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		BasicConstraintsValid: true,
+		IsCA:                  name != "leaf",
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert, key
+}
+
+func toPEM(certs ...*x509.Certificate) []byte {
+	var blob []byte
+	for _, cert := range certs {
+		blob = append(blob, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+	}
+	return blob
+}
+
+func TestLoadTokenCertificatesChain(t *testing.T) {
+	root, rootKey := issue(t, "root", 1, nil, nil)
+	inter, interKey := issue(t, "intermediate", 2, root, rootKey)
+	leaf, leafKey := issue(t, "leaf", 3, inter, interKey)
+
+	// leaf doesn't have to come first in the bundle
+	cert, err := LoadTokenCertificates(leafKey, "", "", toPEM(inter, leaf))
+	require.NoError(t, err)
+	assert.Equal(t, leaf, cert.Leaf)
+	assert.Equal(t, []*x509.Certificate{leaf, inter}, cert.Chain())
+
+	// additional chain certificates are appended without duplicates, and the
+	// root is left out
+	require.NoError(t, cert.AddChain(toPEM(inter, root)))
+	assert.Equal(t, []*x509.Certificate{leaf, inter}, cert.Certificates)
+	assert.Equal(t, []*x509.Certificate{leaf, inter}, cert.Chain())
+
+	_, err = LoadTokenCertificates(interKey, "", "", toPEM(leaf))
+	assert.Error(t, err)
+}
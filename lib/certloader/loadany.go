@@ -25,14 +25,16 @@ import (
 
 	"github.com/ProtonMail/go-crypto/openpgp"
 	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"golang.org/x/crypto/ssh"
 )
 
 type AnyCerts struct {
 	X509Certs []*x509.Certificate
 	PGPCerts  openpgp.EntityList
+	SSHKeys   []ssh.PublicKey
 }
 
-// Load X509 and/or PGP certificates from the named file paths
+// Load X509 and/or PGP certificates, or SSH public keys, from the named file paths
 func LoadAnyCerts(paths []string) (any AnyCerts, err error) {
 	for _, path := range paths {
 		blob, err := ioutil.ReadFile(path)
@@ -46,6 +48,10 @@ func LoadAnyCerts(paths []string) (any AnyCerts, err error) {
 		} else if err != ErrNoCerts {
 			return any, fmt.Errorf("%s: %w", path, err)
 		}
+		if sshKeys := parseSSHKeys(blob); len(sshKeys) != 0 {
+			any.SSHKeys = append(any.SSHKeys, sshKeys...)
+			continue
+		}
 		pgpcerts, err := parsePGP(blob)
 		if err == nil {
 			any.PGPCerts = append(any.PGPCerts, pgpcerts...)
@@ -56,6 +62,20 @@ func LoadAnyCerts(paths []string) (any AnyCerts, err error) {
 	return any, nil
 }
 
+// Parse SSH public keys in authorized_keys format, one per line
+func parseSSHKeys(blob []byte) []ssh.PublicKey {
+	var keys []ssh.PublicKey
+	for len(blob) != 0 {
+		pub, _, _, rest, err := ssh.ParseAuthorizedKey(blob)
+		if err != nil {
+			break
+		}
+		keys = append(keys, pub)
+		blob = rest
+	}
+	return keys
+}
+
 // Parse one or more PGP certificates from the given possibly-armored blob
 func parsePGP(blob []byte) (openpgp.EntityList, error) {
 	reader := io.Reader(bytes.NewReader(blob))
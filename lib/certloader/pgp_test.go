@@ -0,0 +1,122 @@
+package certloader
+
+import (
+	"bytes"
+	"crypto"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/clearsign"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mind-security/relic/v8/lib/pgptools"
+)
+
+func TestPgpSigningSubkey(t *testing.T) {
+	config := &packet.Config{Algorithm: packet.PubKeyAlgoRSA, RSABits: 2048}
+	master, err := openpgp.NewEntity("test", "", "test@example.com", config)
+	require.NoError(t, err)
+	require.NoError(t, master.AddSigningSubkey(config))
+	subkey := master.Subkeys[len(master.Subkeys)-1]
+	subID := subkey.PublicKey.KeyId
+
+	// the public certificate includes the subkey binding
+	var pub bytes.Buffer
+	require.NoError(t, master.Serialize(&pub))
+	certPath := filepath.Join(t.TempDir(), "cert.pgp")
+	require.NoError(t, os.WriteFile(certPath, pub.Bytes(), 0644))
+	keyring, err := openpgp.ReadKeyRing(bytes.NewReader(pub.Bytes()))
+	require.NoError(t, err)
+
+	// only the subkey's secret is in the token
+	cert, err := LoadTokenCertificates(subkey.PrivateKey.PrivateKey, "", certPath, nil)
+	require.NoError(t, err)
+	assert.Nil(t, cert.PgpKey.PrivateKey)
+	priv := pgptools.SigningKey(cert.PgpKey, 0)
+	require.NotNil(t, priv)
+	assert.Equal(t, subID, priv.KeyId)
+	ok, err := pgptools.MatchKey(&priv.PublicKey, fmt.Sprintf("0x%016X", subID))
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// detached
+	const message = "hello world\n"
+	var sig bytes.Buffer
+	signConfig := &packet.Config{DefaultHash: crypto.SHA256, SigningKeyId: subID}
+	require.NoError(t, openpgp.DetachSign(&sig, cert.PgpKey, strings.NewReader(message), signConfig))
+	sigPkt, err := packet.Read(bytes.NewReader(sig.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, subID, *sigPkt.(*packet.Signature).IssuerKeyId)
+	signer, err := openpgp.CheckDetachedSignature(keyring, strings.NewReader(message), bytes.NewReader(sig.Bytes()), nil)
+	require.NoError(t, err)
+	assert.Equal(t, master.PrimaryKey.KeyId, signer.PrimaryKey.KeyId)
+
+	// clearsign
+	var clear bytes.Buffer
+	require.NoError(t, pgptools.ClearSign(&clear, cert.PgpKey, strings.NewReader(message), &packet.Config{DefaultHash: crypto.SHA256}))
+	block, _ := clearsign.Decode(clear.Bytes())
+	require.NotNil(t, block)
+	signer, err = block.VerifySignature(keyring, nil)
+	require.NoError(t, err)
+	assert.Equal(t, master.PrimaryKey.KeyId, signer.PrimaryKey.KeyId)
+
+	ok, err = pgptools.MatchKey(&priv.PublicKey, fmt.Sprintf("%X", master.PrimaryKey.Fingerprint))
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// a key that isn't part of the certificate is rejected
+	_, err = LoadTokenCertificates(master.Subkeys[0].PrivateKey.PrivateKey, "", certPath, nil)
+	assert.Error(t, err)
+}
+
+func TestPgpTokenKeyTypes(t *testing.T) {
+	for name, config := range map[string]*packet.Config{
+		"ed25519": {Algorithm: packet.PubKeyAlgoEdDSA},
+		"p256":    {Algorithm: packet.PubKeyAlgoECDSA, Curve: packet.CurveNistP256},
+	} {
+		t.Run(name, func(t *testing.T) {
+			entity, err := openpgp.NewEntity("test", "", "test@example.com", config)
+			require.NoError(t, err)
+			var pub, secret bytes.Buffer
+			require.NoError(t, entity.Serialize(&pub))
+			require.NoError(t, entity.SerializePrivate(&secret, nil))
+			certPath := filepath.Join(t.TempDir(), "cert.pgp")
+			require.NoError(t, os.WriteFile(certPath, pub.Bytes(), 0644))
+			keyring, err := openpgp.ReadKeyRing(bytes.NewReader(pub.Bytes()))
+			require.NoError(t, err)
+
+			// the key file is loaded as a standard crypto.Signer, like a token key
+			key, err := ParseAnyPrivateKey(secret.Bytes(), nil)
+			require.NoError(t, err)
+			signer, ok := key.(crypto.Signer)
+			require.True(t, ok)
+			cert, err := LoadTokenCertificates(signer, "", certPath, nil)
+			require.NoError(t, err)
+			priv := pgptools.SigningKey(cert.PgpKey, 0)
+			require.NotNil(t, priv)
+			require.True(t, pgptools.NeedsSignKey(priv))
+
+			const message = "hello world\n"
+			for _, sigType := range []packet.SignatureType{packet.SigTypeBinary, packet.SigTypeText} {
+				var sig bytes.Buffer
+				signConfig := &packet.Config{DefaultHash: crypto.SHA256}
+				require.NoError(t, pgptools.DetachSignKey(&sig, &priv.PublicKey, signer, strings.NewReader(message), sigType, signConfig))
+				sigPkt, err := pgptools.ReadSignature(sig.Bytes())
+				require.NoError(t, err)
+				assert.Equal(t, 4, sigPkt.Version)
+				assert.Equal(t, sigType, sigPkt.SigType)
+				assert.Equal(t, entity.PrimaryKey.Fingerprint, sigPkt.IssuerFingerprint)
+				assert.Equal(t, entity.PrimaryKey.KeyId, *sigPkt.IssuerKeyId)
+				found, err := openpgp.CheckDetachedSignature(keyring, strings.NewReader(message), bytes.NewReader(sig.Bytes()), nil)
+				require.NoError(t, err)
+				assert.Equal(t, entity.PrimaryKey.KeyId, found.PrimaryKey.KeyId)
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package chainbuild completes X.509 certificate chains from a bundle of
+// intermediates and by downloading the issuers named in each certificate's
+// Authority Information Access extension, caching downloads in memory and
+// optionally on disk.
+package chainbuild
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mind-security/relic/v8/lib/atomicfile"
+	"github.com/mind-security/relic/v8/lib/certloader"
+)
+
+const (
+	maxResponseSize = 1024 * 1024
+	// longer chains are assumed to be a loop
+	maxDepth = 10
+)
+
+// Builder finds the issuers of certificates
+type Builder struct {
+	// Intermediates are searched for issuers before anything is downloaded
+	Intermediates []*x509.Certificate
+	// Fetch enables downloading issuers from AIA caIssuers URLs
+	Fetch bool
+	// CacheDir persists downloaded issuers so they survive restarts. If empty,
+	// they are only cached in memory.
+	CacheDir string
+	// Client is used to download issuers. If nil, http.DefaultClient is used.
+	Client *http.Client
+
+	mu  sync.Mutex
+	mem map[string][]byte
+}
+
+// Complete extends chain, which starts with the leaf, with issuers until a
+// self-signed root is reached or no issuer can be found. Certificates already
+// in the chain are used first, in any order. Problems finding an issuer are
+// returned as a warning alongside the chain built so far.
+func (b *Builder) Complete(ctx context.Context, chain []*x509.Certificate) ([]*x509.Certificate, error) {
+	if len(chain) == 0 {
+		return nil, errors.New("empty certificate chain")
+	}
+	given := chain[1:]
+	path := []*x509.Certificate{chain[0]}
+	for len(path) < maxDepth {
+		cert := path[len(path)-1]
+		if isSelfSigned(cert) {
+			return path, nil
+		}
+		issuer := findIssuer(cert, given)
+		if issuer == nil {
+			issuer = findIssuer(cert, b.Intermediates)
+		}
+		if issuer == nil {
+			var err error
+			issuer, err = b.fetchIssuer(ctx, cert)
+			if err != nil {
+				return path, fmt.Errorf("issuer of %q: %w", cert.Subject, err)
+			}
+		}
+		path = append(path, issuer)
+	}
+	return path, errors.New("certificate chain is too long")
+}
+
+func isSelfSigned(cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawIssuer, cert.RawSubject) && cert.CheckSignatureFrom(cert) == nil
+}
+
+func findIssuer(cert *x509.Certificate, candidates []*x509.Certificate) *x509.Certificate {
+	for _, c := range candidates {
+		if bytes.Equal(c.RawSubject, cert.RawIssuer) && cert.CheckSignatureFrom(c) == nil {
+			return c
+		}
+	}
+	return nil
+}
+
+func (b *Builder) fetchIssuer(ctx context.Context, cert *x509.Certificate) (*x509.Certificate, error) {
+	var lastErr error
+	for _, url := range cert.IssuingCertificateURL {
+		if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+			continue
+		}
+		key := cacheKey(url)
+		if blob := b.load(key); blob != nil {
+			issuer, err := issuerFrom(cert, url, blob)
+			if err == nil && time.Now().Before(issuer.NotAfter) {
+				return issuer, nil
+			}
+		}
+		if !b.Fetch {
+			continue
+		}
+		blob, err := b.fetch(ctx, url)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		issuer, err := issuerFrom(cert, url, blob)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		b.store(key, blob)
+		return issuer, nil
+	}
+	if lastErr == nil {
+		lastErr = errors.New("not found locally or in the Authority Information Access extension")
+	}
+	return nil, lastErr
+}
+
+// issuerFrom parses a caIssuers response, which may be a single DER
+// certificate or a PKCS#7 bundle, and finds the issuer of cert in it
+func issuerFrom(cert *x509.Certificate, url string, blob []byte) (*x509.Certificate, error) {
+	certs, err := certloader.ParseX509Certificates(blob)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", url, err)
+	}
+	issuer := findIssuer(cert, certs)
+	if issuer == nil {
+		return nil, fmt.Errorf("%s: certificate is not the issuer", url)
+	}
+	return issuer, nil
+}
+
+func (b *Builder) fetch(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	cli := b.Client
+	if cli == nil {
+		cli = http.DefaultClient
+	}
+	resp, err := cli.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+}
+
+func (b *Builder) load(key string) []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if blob := b.mem[key]; blob != nil {
+		return blob
+	}
+	if b.CacheDir == "" {
+		return nil
+	}
+	blob, err := os.ReadFile(filepath.Join(b.CacheDir, key))
+	if err != nil {
+		return nil
+	}
+	if b.mem == nil {
+		b.mem = make(map[string][]byte)
+	}
+	b.mem[key] = blob
+	return blob
+}
+
+func (b *Builder) store(key string, blob []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.mem == nil {
+		b.mem = make(map[string][]byte)
+	}
+	b.mem[key] = blob
+	if b.CacheDir == "" {
+		return
+	}
+	// the cache is only an optimization so failing to persist it isn't fatal
+	if err := os.MkdirAll(b.CacheDir, 0755); err == nil {
+		_ = atomicfile.WriteFile(filepath.Join(b.CacheDir, key), blob)
+	}
+}
+
+func cacheKey(url string) string {
+	d := sha256.Sum256([]byte(url))
+	return "aia-" + hex.EncodeToString(d[:])
+}
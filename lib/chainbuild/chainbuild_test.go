@@ -0,0 +1,124 @@
+package chainbuild
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testPKI struct {
+	root, inter, leaf *x509.Certificate
+	hits              atomic.Int32
+	srv               *httptest.Server
+}
+
+func issue(t *testing.T, name string, aia string, parent *x509.Certificate, parentKey crypto.Signer) (*x509.Certificate, crypto.Signer) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		BasicConstraintsValid: true,
+		IsCA:                  name != "leaf",
+	}
+	if aia != "" {
+		template.IssuingCertificateURL = []string{aia}
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert, key
+}
+
+func newTestPKI(t *testing.T) *testPKI {
+	p := new(testPKI)
+	p.srv = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		p.hits.Add(1)
+		switch req.URL.Path {
+		case "/inter.cer":
+			_, _ = rw.Write(p.inter.Raw)
+		case "/root.cer":
+			_, _ = rw.Write(p.root.Raw)
+		default:
+			http.NotFound(rw, req)
+		}
+	}))
+	t.Cleanup(p.srv.Close)
+	var rootKey, interKey crypto.Signer
+	p.root, rootKey = issue(t, "root", "", nil, nil)
+	p.inter, interKey = issue(t, "intermediate", p.srv.URL+"/root.cer", p.root, rootKey)
+	p.leaf, _ = issue(t, "leaf", p.srv.URL+"/inter.cer", p.inter, interKey)
+	return p
+}
+
+func TestCompleteLocal(t *testing.T) {
+	p := newTestPKI(t)
+	b := &Builder{Intermediates: []*x509.Certificate{p.root, p.inter}}
+	chain, err := b.Complete(context.Background(), []*x509.Certificate{p.leaf})
+	require.NoError(t, err)
+	assert.Equal(t, []*x509.Certificate{p.leaf, p.inter, p.root}, chain)
+	// given certificates can be in any order
+	b = &Builder{}
+	chain, err = b.Complete(context.Background(), []*x509.Certificate{p.leaf, p.root, p.inter})
+	require.NoError(t, err)
+	assert.Equal(t, []*x509.Certificate{p.leaf, p.inter, p.root}, chain)
+	assert.Zero(t, p.hits.Load())
+}
+
+func TestCompleteFetch(t *testing.T) {
+	p := newTestPKI(t)
+	dir := t.TempDir()
+	b := &Builder{Fetch: true, CacheDir: dir}
+	chain, err := b.Complete(context.Background(), []*x509.Certificate{p.leaf})
+	require.NoError(t, err)
+	assert.Equal(t, []*x509.Certificate{p.leaf, p.inter, p.root}, chain)
+	assert.Equal(t, int32(2), p.hits.Load())
+	// cached in memory
+	_, err = b.Complete(context.Background(), []*x509.Certificate{p.leaf})
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), p.hits.Load())
+	// and on disk
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+	p.srv.Close()
+	b = &Builder{CacheDir: dir}
+	chain, err = b.Complete(context.Background(), []*x509.Certificate{p.leaf})
+	require.NoError(t, err)
+	assert.Len(t, chain, 3)
+}
+
+func TestCompletePartial(t *testing.T) {
+	p := newTestPKI(t)
+	// not allowed to download
+	b := &Builder{}
+	chain, err := b.Complete(context.Background(), []*x509.Certificate{p.leaf})
+	assert.ErrorContains(t, err, `issuer of "CN=leaf"`)
+	assert.Equal(t, []*x509.Certificate{p.leaf}, chain)
+	// download fails
+	b = &Builder{Fetch: true, Intermediates: []*x509.Certificate{p.inter}}
+	p.srv.Close()
+	chain, err = b.Complete(context.Background(), []*x509.Certificate{p.leaf})
+	assert.ErrorContains(t, err, `issuer of "CN=intermediate"`)
+	assert.Equal(t, []*x509.Certificate{p.leaf, p.inter}, chain)
+}
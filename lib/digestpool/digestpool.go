@@ -0,0 +1,192 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package digestpool digests many small streams, such as the members of an
+// archive, using a bounded number of workers. Each stream is buffered in
+// memory so that the next one can be read while it is being hashed, so the
+// number of workers and the size of each buffer are both capped to keep
+// memory use in check.
+package digestpool
+
+import (
+	"bufio"
+	"bytes"
+	"crypto"
+	"io"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBufferSize is the largest stream buffered by a worker when no limit
+// is configured. Larger streams are digested without a worker.
+const DefaultBufferSize = 16 * 1024 * 1024
+
+// Limits control how much concurrency and memory are used
+type Limits struct {
+	// Workers is the most streams hashed at once. If 0, GOMAXPROCS is used.
+	// 1 or less disables parallel hashing.
+	Workers int
+	// BufferSize is the largest stream handed to a worker. If 0,
+	// DefaultBufferSize is used.
+	BufferSize int64
+}
+
+// memAvailable is a variable so tests can simulate a constrained host
+var memAvailable = readMemAvailable
+
+// Effective fills in defaults and reduces the number of workers so that all
+// of their buffers fit within a quarter of the available memory, if that can
+// be determined
+func (l Limits) Effective() Limits {
+	if l.Workers == 0 {
+		l.Workers = runtime.GOMAXPROCS(0)
+	}
+	if l.BufferSize <= 0 {
+		l.BufferSize = DefaultBufferSize
+	}
+	if avail := memAvailable(); avail > 0 {
+		if max := avail / 4 / l.BufferSize; max < int64(l.Workers) {
+			l.Workers = int(max)
+		}
+	}
+	if l.Workers < 1 {
+		l.Workers = 1
+	}
+	return l
+}
+
+// readMemAvailable returns the available memory in bytes, or 0 if unknown
+func readMemAvailable() int64 {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "MemAvailable:" {
+			kb, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return 0
+			}
+			return kb * 1024
+		}
+	}
+	return 0
+}
+
+// Pool digests streams, in parallel where the limits allow
+type Pool struct {
+	hash   crypto.Hash
+	limits Limits
+	sem    chan struct{}
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	digests map[string][]byte
+	// for tests
+	active, peakWorkers int
+	peakBuffer          int64
+}
+
+// New creates a pool that digests with the given hash
+func New(hash crypto.Hash, limits Limits) *Pool {
+	limits = limits.Effective()
+	return &Pool{
+		hash:    hash,
+		limits:  limits,
+		sem:     make(chan struct{}, limits.Workers),
+		digests: make(map[string][]byte),
+	}
+}
+
+// Limits returns the limits in effect after defaults and reductions
+func (p *Pool) Limits() Limits {
+	return p.limits
+}
+
+// Add reads r to completion and digests it under the given name. size is the
+// expected length of r, or -1 if unknown. Streams that fit in a buffer are
+// hashed by a worker, blocking while all workers are busy; others are hashed
+// before Add returns.
+func (p *Pool) Add(name string, r io.Reader, size int64) error {
+	if p.limits.Workers < 2 || size > p.limits.BufferSize {
+		return p.addInline(name, nil, r)
+	}
+	p.sem <- struct{}{}
+	var buf bytes.Buffer
+	if size > 0 {
+		buf.Grow(int(size))
+	}
+	if _, err := buf.ReadFrom(io.LimitReader(r, p.limits.BufferSize+1)); err != nil {
+		<-p.sem
+		return err
+	}
+	if int64(buf.Len()) > p.limits.BufferSize {
+		// longer than it claimed, so finish it here
+		<-p.sem
+		return p.addInline(name, buf.Bytes(), r)
+	}
+	p.wg.Add(1)
+	p.track(1, int64(buf.Len()))
+	go func() {
+		defer p.wg.Done()
+		defer func() { <-p.sem }()
+		defer p.track(-1, 0)
+		d := p.hash.New()
+		d.Write(buf.Bytes())
+		p.set(name, d.Sum(nil))
+	}()
+	return nil
+}
+
+func (p *Pool) addInline(name string, prefix []byte, r io.Reader) error {
+	d := p.hash.New()
+	d.Write(prefix)
+	if _, err := io.Copy(d, r); err != nil {
+		return err
+	}
+	p.set(name, d.Sum(nil))
+	return nil
+}
+
+// Wait for all workers to finish and return the digest of each stream by name
+func (p *Pool) Wait() map[string][]byte {
+	p.wg.Wait()
+	return p.digests
+}
+
+func (p *Pool) set(name string, digest []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.digests[name] = digest
+}
+
+func (p *Pool) track(delta int, bufSize int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.active += delta
+	if p.active > p.peakWorkers {
+		p.peakWorkers = p.active
+	}
+	if bufSize > p.peakBuffer {
+		p.peakBuffer = bufSize
+	}
+}
@@ -0,0 +1,69 @@
+package digestpool
+
+import (
+	"bytes"
+	"crypto"
+	_ "crypto/sha256"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// slowReader makes workers overlap so the cap is exercised
+type slowReader struct{ io.Reader }
+
+func (r slowReader) Read(d []byte) (int, error) {
+	time.Sleep(time.Millisecond)
+	return r.Reader.Read(d)
+}
+
+func TestLimits(t *testing.T) {
+	streams := make(map[string][]byte)
+	for i := 0; i < 50; i++ {
+		streams[fmt.Sprintf("f%d", i)] = bytes.Repeat([]byte{byte(i)}, i*100)
+	}
+	p := New(crypto.SHA256, Limits{Workers: 3, BufferSize: 2500})
+	for name, blob := range streams {
+		size := int64(len(blob))
+		if name == "f7" {
+			// claims to be smaller than it is
+			size = 10
+		} else if name == "f40" {
+			// claims to fit but doesn't
+			size = 100
+		}
+		require.NoError(t, p.Add(name, slowReader{bytes.NewReader(blob)}, size))
+	}
+	digests := p.Wait()
+	require.Len(t, digests, len(streams))
+	for name, blob := range streams {
+		d := crypto.SHA256.New()
+		d.Write(blob)
+		assert.Equal(t, d.Sum(nil), digests[name], name)
+	}
+	assert.LessOrEqual(t, p.peakWorkers, 3)
+	assert.Greater(t, p.peakWorkers, 0)
+	assert.LessOrEqual(t, p.peakBuffer, int64(2500))
+}
+
+func TestEffective(t *testing.T) {
+	defer func(f func() int64) { memAvailable = f }(memAvailable)
+	// plenty of memory
+	memAvailable = func() int64 { return 1 << 40 }
+	assert.Equal(t, Limits{Workers: 8, BufferSize: DefaultBufferSize}, Limits{Workers: 8}.Effective())
+	// only room for 2 buffers, so use fewer workers instead of failing
+	memAvailable = func() int64 { return 8 * DefaultBufferSize }
+	assert.Equal(t, 2, Limits{Workers: 8}.Effective().Workers)
+	// not even room for one, so hash without workers
+	memAvailable = func() int64 { return 1024 }
+	l := Limits{Workers: 8}.Effective()
+	assert.Equal(t, 1, l.Workers)
+	p := New(crypto.SHA256, Limits{Workers: 8})
+	require.NoError(t, p.Add("x", bytes.NewReader([]byte("hello")), 5))
+	assert.Len(t, p.Wait(), 1)
+	assert.Equal(t, 0, p.peakWorkers)
+}
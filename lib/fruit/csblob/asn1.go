@@ -3,6 +3,8 @@ package csblob
 import (
 	"crypto/x509"
 	"encoding/asn1"
+
+	"github.com/mind-security/relic/v8/lib/pkcs7"
 )
 
 // Extensions for specific types of key usage.
@@ -46,6 +48,10 @@ var (
 	AttrCodeDirHashes = asn1.ObjectIdentifier{1, 2, 840, 113635, 100, 9, 2}
 )
 
+func init() {
+	pkcs7.RegisterAttribute(AttrCodeDirHashPlist, AttrCodeDirHashes)
+}
+
 func hasPrefix(id, prefix asn1.ObjectIdentifier) bool {
 	if len(id) < len(prefix) {
 		return false
@@ -22,8 +22,10 @@ type SignatureParams struct {
 	Pages        io.Reader // read page contents
 	OldSignature io.Reader // read the existing signature, if any, after the pages
 	HashFunc     crypto.Hash
-	InfoPlist    []byte // manifest to bind to signature
-	Resources    []byte // CodeResources to bind to signature
+	InfoPlist    []byte    // manifest to bind to signature
+	Resources    []byte    // CodeResources to bind to signature
+	SigningTime  time.Time // signing time attribute (current time if zero)
+	OmitTime     bool      // leave out the signing time attribute
 
 	// the following are copied from the old signature if empty
 	Flags            SignatureFlags
@@ -212,8 +214,14 @@ func Sign(ctx context.Context, cert *certloader.Certificate, params *SignaturePa
 	if err := addPlistHashes(builder, plistHashes); err != nil {
 		return nil, nil, fmt.Errorf("adding cdhash plist: %w", err)
 	}
-	if err := builder.AddAuthenticatedAttribute(pkcs7.OidAttributeSigningTime, time.Now().UTC()); err != nil {
-		return nil, nil, err
+	if !params.OmitTime {
+		signingTime := params.SigningTime
+		if signingTime.IsZero() {
+			signingTime = time.Now()
+		}
+		if err := builder.AddAuthenticatedAttribute(pkcs7.OidAttributeSigningTime, signingTime.UTC()); err != nil {
+			return nil, nil, err
+		}
 	}
 	psd, err := builder.Sign()
 	if err != nil {
@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"time"
 
 	"github.com/mind-security/relic/v8/lib/binpatch"
 	"github.com/mind-security/relic/v8/lib/certloader"
@@ -20,6 +21,8 @@ type SignatureParams struct {
 	Requirements    []byte // requirements to embed in signature
 	SigningIdentity string
 	TeamIdentifier  string
+	SigningTime     time.Time // signing time attribute (current time if zero)
+	OmitTime        bool      // leave out the signing time attribute
 }
 
 func Sign(ctx context.Context, rsfBytes []byte, r io.Reader, cert *certloader.Certificate, params *SignatureParams) (*binpatch.PatchSet, *pkcs9.TimestampedSignature, error) {
@@ -39,6 +42,8 @@ func Sign(ctx context.Context, rsfBytes []byte, r io.Reader, cert *certloader.Ce
 		TeamIdentifier:  params.TeamIdentifier,
 		Pages:           io.LimitReader(nr, bundleSize),
 		RepSpecific:     rsf.ForHashing(),
+		SigningTime:     params.SigningTime,
+		OmitTime:        params.OmitTime,
 	}
 	if oldOffset != 0 {
 		// provide old signature to copy requirements and flags
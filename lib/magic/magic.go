@@ -17,6 +17,7 @@
 package magic
 
 import (
+	"archive/tar"
 	"archive/zip"
 	"bufio"
 	"bytes"
@@ -54,6 +55,10 @@ const (
 	FileTypeMachOFat
 	FileTypeIPA
 	FileTypeXAR
+	FileTypeZipJWS
+	FileTypeWASM
+	FileTypeHelm
+	FileTypeSSHSig
 )
 
 const (
@@ -94,6 +99,12 @@ func Detect(r io.Reader) FileType {
 		return FileTypeDEB
 	case hasPrefix(br, []byte("-----BEGIN PGP")):
 		return FileTypePGP
+	case hasPrefix(br, []byte("-----BEGIN SSH SIGNATURE-----")):
+		return FileTypeSSHSig
+	case hasPrefix(br, []byte("-----BEGIN PKCS7-----")), hasPrefix(br, []byte("-----BEGIN CMS-----")):
+		return FileTypePKCS7
+	case hasPrefix(br, []byte("\x00asm")):
+		return FileTypeWASM
 	case contains(br, []byte{0x06, 0x09, 0x2B, 0x06, 0x01, 0x04, 0x01, 0x82, 0x37, 0x0A, 0x01}, 256):
 		// OID certTrustList
 		return FileTypeCAT
@@ -176,6 +187,11 @@ func isTar(br *bufio.Reader) bool {
 }
 
 func detectTar(r io.Reader) FileType {
+	// helm puts Chart.yaml first when packaging a chart
+	hdr, err := tar.NewReader(r).Next()
+	if err == nil && path.Base(hdr.Name) == "Chart.yaml" && strings.Count(path.Clean(hdr.Name), "/") == 1 {
+		return FileTypeHelm
+	}
 	return FileTypeUnknown
 }
 
@@ -188,7 +204,7 @@ func detectZip(f *os.File) FileType {
 	if err != nil {
 		return FileTypeUnknown
 	}
-	var isJar bool
+	var isJar, hasJWS bool
 	for _, zf := range inz.File {
 		name := zf.Name
 		if strings.HasPrefix(name, "/") {
@@ -207,6 +223,9 @@ func detectZip(f *os.File) FileType {
 		case "META-INF/MANIFEST.MF":
 			// APKs are also JARs so save this for last
 			isJar = true
+		case "META-INF/signature.jws":
+			// generic ZIP with an embedded JWS, see lib/artifactjws
+			hasJWS = true
 		}
 		switch {
 		case strings.HasSuffix(name, ".app/Info.plist"):
@@ -217,6 +236,8 @@ func detectZip(f *os.File) FileType {
 	}
 	if isJar {
 		return FileTypeJAR
+	} else if hasJWS {
+		return FileTypeZipJWS
 	}
 	return FileTypeUnknown
 }
@@ -0,0 +1,134 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package pgptools
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"unicode"
+
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+)
+
+// ArmorHeaders holds the optional header lines written after the BEGIN line
+// of an ASCII armor block. The zero value writes no headers, which is what
+// tools that expect bare armor want.
+//
+// Headers are outside of the armored data, so adding or removing them does
+// not change the CRC24 checksum at the end of the block.
+type ArmorHeaders struct {
+	Version string
+	Comment string
+}
+
+// Validate checks that the header values fit on a single armor header line
+func (h ArmorHeaders) Validate() error {
+	for _, kv := range h.list() {
+		for _, r := range kv[1] {
+			if unicode.IsControl(r) || r == unicode.ReplacementChar {
+				return fmt.Errorf("armor header %s contains invalid characters", kv[0])
+			}
+		}
+	}
+	return nil
+}
+
+// list returns the non-empty headers in a fixed order
+func (h ArmorHeaders) list() [][2]string {
+	var headers [][2]string
+	if h.Version != "" {
+		headers = append(headers, [2]string{"Version", h.Version})
+	}
+	if h.Comment != "" {
+		headers = append(headers, [2]string{"Comment", h.Comment})
+	}
+	return headers
+}
+
+func (h ArmorHeaders) marshal(eol string) []byte {
+	var buf bytes.Buffer
+	for _, kv := range h.list() {
+		buf.WriteString(kv[0])
+		buf.WriteString(": ")
+		buf.WriteString(kv[1])
+		buf.WriteString(eol)
+	}
+	return buf.Bytes()
+}
+
+// Encode returns a WriteCloser which will encode the data written to it in
+// OpenPGP armor with these headers
+func (h ArmorHeaders) Encode(w io.Writer, blockType string) (io.WriteCloser, error) {
+	if err := h.Validate(); err != nil {
+		return nil, err
+	}
+	// armor.Encode writes headers from a map in random order, so write them
+	// here instead to keep the output reproducible
+	return armor.Encode(&headerWriter{w: w, headers: h.marshal("\n")}, blockType, nil)
+}
+
+// Insert adds these headers to an already armored block that has none,
+// keeping the line endings used by the BEGIN line
+func (h ArmorHeaders) Insert(block []byte) ([]byte, error) {
+	if err := h.Validate(); err != nil {
+		return nil, err
+	}
+	headers := h.marshal("\n")
+	if len(headers) == 0 {
+		return block, nil
+	}
+	i := bytes.IndexByte(block, '\n')
+	if i < 0 || !bytes.HasPrefix(block, []byte("-----BEGIN ")) {
+		return nil, errors.New("expected an ASCII armor block")
+	}
+	if i > 0 && block[i-1] == '\r' {
+		headers = h.marshal("\r\n")
+	}
+	out := make([]byte, 0, len(block)+len(headers))
+	out = append(out, block[:i+1]...)
+	out = append(out, headers...)
+	out = append(out, block[i+1:]...)
+	return out, nil
+}
+
+// headerWriter inserts header lines after the first line written through it
+type headerWriter struct {
+	w       io.Writer
+	headers []byte
+}
+
+func (hw *headerWriter) Write(d []byte) (int, error) {
+	if hw.headers == nil {
+		return hw.w.Write(d)
+	}
+	i := bytes.IndexByte(d, '\n')
+	if i < 0 {
+		return hw.w.Write(d)
+	}
+	if _, err := hw.w.Write(d[:i+1]); err != nil {
+		return 0, err
+	}
+	headers := hw.headers
+	hw.headers = nil
+	if _, err := hw.w.Write(headers); err != nil {
+		return i + 1, err
+	}
+	n, err := hw.w.Write(d[i+1:])
+	return i + 1 + n, err
+}
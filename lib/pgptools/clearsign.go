@@ -34,7 +34,11 @@ var crlf = []byte("\r\n")
 
 // Do a cleartext signature, signing the document in "message" and writing the result to "w"
 func ClearSign(w io.Writer, signer *openpgp.Entity, message io.Reader, config *packet.Config) error {
-	e, err := clearsign.Encode(w, signer.PrivateKey, config)
+	priv := SigningKey(signer, config.SigningKey())
+	if priv == nil {
+		return errors.New("PGP entity has no private key to sign with")
+	}
+	e, err := clearsign.Encode(w, priv, config)
 	if err != nil {
 		return err
 	}
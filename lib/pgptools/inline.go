@@ -21,17 +21,18 @@ import (
 	"errors"
 	"io"
 
-	"github.com/ProtonMail/go-crypto/openpgp/armor"
 	"github.com/ProtonMail/go-crypto/openpgp/packet"
 )
 
 const maxLiteralSize = (1 << 31) - 512 // int32_max minus some room for the literal data header
 
-// MergeSignature combines a detached signature with a cleartext message and writes it as an inline signed message with optional ASCII armor
-func MergeSignature(w io.Writer, sig []byte, message io.Reader, withArmor bool, filename string) (err error) {
+// MergeSignature combines a detached signature with a cleartext message and
+// writes it as an inline signed message. If headers is not nil then the
+// message is written with ASCII armor.
+func MergeSignature(w io.Writer, sig []byte, message io.Reader, headers *ArmorHeaders, filename string) (err error) {
 	var armorer io.WriteCloser = nopCloseWriter{w}
-	if withArmor {
-		armorer, err = armor.Encode(w, "PGP MESSAGE", nil)
+	if headers != nil {
+		armorer, err = headers.Encode(w, "PGP MESSAGE")
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,237 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package pgptools
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"math/bits"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/clearsign"
+	pgpecdsa "github.com/ProtonMail/go-crypto/openpgp/ecdsa"
+	"github.com/ProtonMail/go-crypto/openpgp/eddsa"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+// RFC 4880 9.4
+var hashIDs = map[crypto.Hash]byte{
+	crypto.SHA1:   2,
+	crypto.SHA256: 8,
+	crypto.SHA384: 9,
+	crypto.SHA512: 10,
+	crypto.SHA224: 11,
+}
+
+// RFC 4880 5.2.3.1
+const (
+	subpacketCreationTime      = 2
+	subpacketIssuer            = 16
+	subpacketIssuerFingerprint = 33
+)
+
+// NeedsSignKey returns true if the openpgp package can't make signatures with
+// priv because it's a token key of a type it only supports in memory. Use
+// DetachSignKey for these.
+func NeedsSignKey(priv *packet.PrivateKey) bool {
+	if _, ok := priv.PrivateKey.(crypto.Signer); !ok {
+		return false
+	}
+	return priv.PubKeyAlgo == packet.PubKeyAlgoECDSA || priv.PubKeyAlgo == packet.PubKeyAlgoEdDSA
+}
+
+// PublicKey converts a PGP public key to the type used by the standard crypto
+// packages, so it can be compared with a token key. It returns nil for curves
+// that have no standard equivalent.
+func PublicKey(pub *packet.PublicKey) crypto.PublicKey {
+	switch key := pub.PublicKey.(type) {
+	case *pgpecdsa.PublicKey:
+		var curve elliptic.Curve
+		switch key.GetCurve().GetCurveName() {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: key.X, Y: key.Y}
+	case *eddsa.PublicKey:
+		if key.GetCurve().GetCurveName() != "ed25519" {
+			return nil
+		}
+		return ed25519.PublicKey(key.X)
+	default:
+		return pub.PublicKey
+	}
+}
+
+// DetachSignKey makes a detached v4 signature of message using signer, the
+// token key corresponding to pub. It produces the same signature packet as
+// openpgp.DetachSign, including the issuer and issuer fingerprint subpackets,
+// for ECDSA and EdDSA keys that the openpgp package can't use from a token.
+func DetachSignKey(w io.Writer, pub *packet.PublicKey, signer crypto.Signer, message io.Reader, sigType packet.SignatureType, config *packet.Config) error {
+	if pub.Version != 4 {
+		return fmt.Errorf("can't sign with a version %d PGP key", pub.Version)
+	}
+	hash := config.Hash()
+	hashID, ok := hashIDs[hash]
+	if !ok || !hash.Available() {
+		return errors.New("unsupported digest for PGP signature")
+	}
+	var hashed []byte
+	created := make([]byte, 4)
+	binary.BigEndian.PutUint32(created, uint32(config.Now().Unix()))
+	hashed = appendSubpacket(hashed, subpacketCreationTime, created)
+	hashed = appendSubpacket(hashed, subpacketIssuer, binary.BigEndian.AppendUint64(nil, pub.KeyId))
+	hashed = appendSubpacket(hashed, subpacketIssuerFingerprint, append([]byte{byte(pub.Version)}, pub.Fingerprint...))
+	prefix := []byte{4, byte(sigType), byte(pub.PubKeyAlgo), hashID}
+	prefix = binary.BigEndian.AppendUint16(prefix, uint16(len(hashed)))
+	prefix = append(prefix, hashed...)
+	// digest the message, then the signature fields and trailer
+	h := hash.New()
+	mw := io.Writer(h)
+	if sigType == packet.SigTypeText {
+		mw = openpgp.NewCanonicalTextHash(h)
+	}
+	if _, err := io.Copy(mw, message); err != nil {
+		return err
+	}
+	h.Write(prefix)
+	h.Write([]byte{4, 0xff})
+	h.Write(binary.BigEndian.AppendUint32(nil, uint32(len(prefix))))
+	digest := h.Sum(nil)
+
+	var mpis [][]byte
+	switch pub.PubKeyAlgo {
+	case packet.PubKeyAlgoECDSA:
+		der, err := signer.Sign(config.Random(), digest, hash)
+		if err != nil {
+			return err
+		}
+		var sig struct{ R, S *big.Int }
+		if rest, err := asn1.Unmarshal(der, &sig); err != nil {
+			return err
+		} else if len(rest) != 0 {
+			return errors.New("trailing garbage after ECDSA signature")
+		}
+		mpis = [][]byte{sig.R.Bytes(), sig.S.Bytes()}
+	case packet.PubKeyAlgoEdDSA:
+		// legacy EdDSA signs the digest as the message
+		sig, err := signer.Sign(config.Random(), digest, crypto.Hash(0))
+		if err != nil {
+			return err
+		} else if len(sig) != ed25519.SignatureSize {
+			return errors.New("unexpected EdDSA signature size")
+		}
+		mpis = [][]byte{sig[:32], sig[32:]}
+	default:
+		return fmt.Errorf("unsupported PGP public key algorithm %d", pub.PubKeyAlgo)
+	}
+	body := bytes.NewBuffer(prefix)
+	body.Write([]byte{0, 0}) // no unhashed subpackets
+	body.Write(digest[:2])
+	for _, mpi := range mpis {
+		writeMPI(body, mpi)
+	}
+	// new-format signature packet
+	var header []byte
+	switch n := body.Len(); {
+	case n < 192:
+		header = []byte{0xc2, byte(n)}
+	case n < 8384:
+		n -= 192
+		header = []byte{0xc2, byte(n>>8) + 192, byte(n)}
+	default:
+		header = binary.BigEndian.AppendUint32([]byte{0xc2, 0xff}, uint32(n))
+	}
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(body.Bytes())
+	return err
+}
+
+func appendSubpacket(buf []byte, typ byte, contents []byte) []byte {
+	buf = append(buf, byte(1+len(contents)), typ)
+	return append(buf, contents...)
+}
+
+// writeMPI writes an unsigned big-endian integer with its length in bits
+func writeMPI(w *bytes.Buffer, value []byte) {
+	value = bytes.TrimLeft(value, "\x00")
+	var bitLen int
+	if len(value) != 0 {
+		bitLen = 8*(len(value)-1) + bits.Len8(value[0])
+	}
+	w.Write(binary.BigEndian.AppendUint16(nil, uint16(bitLen)))
+	w.Write(value)
+}
+
+// HashID returns the identifier PGP uses for a digest algorithm
+func HashID(hash crypto.Hash) (byte, bool) {
+	id, ok := hashIDs[hash]
+	return id, ok
+}
+
+// ReadSignature returns the first signature in a detached, inline or
+// cleartext signed PGP message, armored or not
+func ReadSignature(blob []byte) (*packet.Signature, error) {
+	var r io.Reader = bytes.NewReader(blob)
+	if bytes.HasPrefix(blob, []byte("-----BEGIN PGP SIGNED MESSAGE-----")) {
+		block, _ := clearsign.Decode(blob)
+		if block == nil {
+			return nil, errors.New("invalid cleartext signature")
+		}
+		r = block.ArmoredSignature.Body
+	} else if bytes.HasPrefix(blob, []byte("-----BEGIN")) {
+		block, err := armor.Decode(r)
+		if err != nil {
+			return nil, err
+		}
+		r = block.Body
+	}
+	packets := packet.NewReader(r)
+	for {
+		pkt, err := packets.Next()
+		if err == io.EOF {
+			return nil, errors.New("no PGP signature found")
+		} else if err != nil {
+			return nil, err
+		}
+		switch pkt := pkt.(type) {
+		case *packet.Signature:
+			return pkt, nil
+		case *packet.LiteralData:
+			if _, err := io.Copy(io.Discard, pkt.Body); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
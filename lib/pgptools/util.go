@@ -17,9 +17,12 @@
 package pgptools
 
 import (
+	"bytes"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
+	"strings"
 
 	"github.com/ProtonMail/go-crypto/openpgp"
 	"github.com/ProtonMail/go-crypto/openpgp/packet"
@@ -42,6 +45,51 @@ func EntityName(entity *openpgp.Entity) string {
 	return name
 }
 
+// SigningKey returns the private key to sign with. If keyID is nonzero, it
+// selects the primary key or subkey with that ID. Otherwise, the primary key
+// is used if its private part is present, or else the first subkey that has
+// one. This allows signing with a subkey when the primary key is kept offline.
+func SigningKey(entity *openpgp.Entity, keyID uint64) *packet.PrivateKey {
+	if keyID != 0 {
+		if entity.PrivateKey != nil && entity.PrivateKey.KeyId == keyID {
+			return entity.PrivateKey
+		}
+		for _, sub := range entity.Subkeys {
+			if sub.PrivateKey != nil && sub.PrivateKey.KeyId == keyID {
+				return sub.PrivateKey
+			}
+		}
+		return nil
+	}
+	if entity.PrivateKey != nil {
+		return entity.PrivateKey
+	}
+	for _, sub := range entity.Subkeys {
+		if sub.PrivateKey != nil {
+			return sub.PrivateKey
+		}
+	}
+	return nil
+}
+
+// MatchKey returns true if spec identifies the given key. spec is a hex key ID
+// or fingerprint, optionally prefixed with 0x.
+func MatchKey(pub *packet.PublicKey, spec string) (bool, error) {
+	spec = strings.TrimPrefix(strings.ReplaceAll(strings.ToLower(spec), " ", ""), "0x")
+	want, err := hex.DecodeString(spec)
+	if err != nil {
+		return false, fmt.Errorf("invalid PGP key ID %q: %w", spec, err)
+	}
+	switch len(want) {
+	case 8:
+		return fmt.Sprintf("%016x", pub.KeyId) == spec, nil
+	case len(pub.Fingerprint):
+		return bytes.Equal(want, pub.Fingerprint), nil
+	default:
+		return false, fmt.Errorf("invalid PGP key ID %q: expected a 16 digit key ID or a fingerprint", spec)
+	}
+}
+
 func readOneSignature(r io.Reader) (*packet.Signature, error) {
 	pkt, err := packet.Read(r)
 	if err != nil {
@@ -97,6 +97,8 @@ func appendAttr(attrList AttributeList, oid asn1.ObjectIdentifier, value []byte)
 	for i, attr := range attrList {
 		if attr.Type.Equal(oid) {
 			attr.Values.Bytes = append(attr.Values.Bytes, value...)
+			// FullBytes from a parsed attribute would be marshalled instead of Bytes
+			attr.Values.FullBytes = nil
 			attrList[i] = attr
 			return attrList
 		}
@@ -150,3 +152,32 @@ func (i SignerInfo) AuthenticatedAttributesBytes() ([]byte, error) {
 		Bytes:      raw.Bytes,
 	})
 }
+
+// RefreshUnauthenticated updates the original encoding of a parsed SignerInfo
+// after its unauthenticated attributes have been modified. Everything else
+// keeps its original encoding so that existing signatures remain valid.
+func (i *SignerInfo) RefreshUnauthenticated() error {
+	if i.RawContent == nil {
+		return nil
+	}
+	var seq []asn1.RawValue
+	if _, err := asn1.Unmarshal(i.RawContent, &seq); err != nil {
+		return err
+	}
+	if n := len(seq); n > 0 && seq[n-1].Class == asn1.ClassContextSpecific && seq[n-1].Tag == 1 {
+		seq = seq[:n-1]
+	}
+	if len(i.UnauthenticatedAttributes) != 0 {
+		attrs, err := asn1.MarshalWithParams(i.UnauthenticatedAttributes, "tag:1")
+		if err != nil {
+			return err
+		}
+		seq = append(seq, asn1.RawValue{FullBytes: attrs})
+	}
+	raw, err := asn1.Marshal(seq)
+	if err != nil {
+		return err
+	}
+	i.RawContent = raw
+	return nil
+}
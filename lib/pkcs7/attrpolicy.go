@@ -0,0 +1,181 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package pkcs7
+
+import (
+	"encoding/asn1"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+var (
+	OidAttributeSmimeCapabilities      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 15}
+	OidAttributeSigningCertificate     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 12}
+	OidAttributeSigningCertificateV2   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 47}
+	OidAttributeCMSAlgorithmProtection = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 52}
+)
+
+var (
+	knownMu    sync.RWMutex
+	knownAttrs = make(map[string]bool)
+)
+
+func init() {
+	RegisterAttribute(
+		OidAttributeContentType,
+		OidAttributeMessageDigest,
+		OidAttributeSigningTime,
+		OidAttributeSmimeCapabilities,
+		OidAttributeSigningCertificate,
+		OidAttributeSigningCertificateV2,
+		OidAttributeCMSAlgorithmProtection,
+	)
+}
+
+// RegisterAttribute marks attribute types as understood, so that they are not
+// reported as unknown when checking a signature against an
+// UnknownAttributePolicy. Packages that produce or consume format-specific
+// attributes register them at init time.
+func RegisterAttribute(oids ...asn1.ObjectIdentifier) {
+	knownMu.Lock()
+	defer knownMu.Unlock()
+	for _, oid := range oids {
+		knownAttrs[oid.String()] = true
+	}
+}
+
+func isKnownAttribute(oid asn1.ObjectIdentifier) bool {
+	knownMu.RLock()
+	defer knownMu.RUnlock()
+	return knownAttrs[oid.String()]
+}
+
+// AttributeAction determines what happens when a signature carries an
+// attribute that isn't understood
+type AttributeAction int
+
+const (
+	// AttributeDefault is strict for signed attributes and lenient for
+	// unsigned ones
+	AttributeDefault AttributeAction = iota
+	// AttributeStrict rejects the signature
+	AttributeStrict
+	// AttributeLenient accepts the signature and reports the attribute
+	AttributeLenient
+)
+
+func ParseAttributeAction(s string) (AttributeAction, error) {
+	switch strings.ToLower(s) {
+	case "":
+		return AttributeDefault, nil
+	case "strict":
+		return AttributeStrict, nil
+	case "lenient":
+		return AttributeLenient, nil
+	default:
+		return 0, fmt.Errorf("unknown attribute policy %q, expected strict or lenient", s)
+	}
+}
+
+func (a AttributeAction) String() string {
+	switch a {
+	case AttributeStrict:
+		return "strict"
+	case AttributeLenient:
+		return "lenient"
+	default:
+		return "default"
+	}
+}
+
+// UnknownAttributePolicy decides how to treat unrecognized attributes when
+// verifying a signature. CMS attributes have no criticality flag of their own,
+// so the policy is set separately for signed (authenticated) attributes, which
+// are covered by the signature and may change its meaning, and for unsigned
+// ones. The zero value treats signed attributes as critical and rejects them,
+// and accepts and reports unsigned ones.
+type UnknownAttributePolicy struct {
+	Signed   AttributeAction
+	Unsigned AttributeAction
+}
+
+// UnknownAttribute describes an unrecognized attribute found on a signature
+type UnknownAttribute struct {
+	Type   asn1.ObjectIdentifier
+	Signed bool
+}
+
+func (a UnknownAttribute) String() string {
+	if a.Signed {
+		return "signed attribute " + a.Type.String()
+	}
+	return "unsigned attribute " + a.Type.String()
+}
+
+// UnknownAttributeError is returned when a signature carries attributes that
+// the policy does not allow
+type UnknownAttributeError struct {
+	Attributes []UnknownAttribute
+}
+
+func (e UnknownAttributeError) Error() string {
+	names := make([]string, len(e.Attributes))
+	for i, attr := range e.Attributes {
+		names[i] = attr.String()
+	}
+	return "signature has unknown " + strings.Join(names, ", ")
+}
+
+// UnknownAttributes returns all attributes of si that have not been registered
+func (si *SignerInfo) UnknownAttributes() []UnknownAttribute {
+	var unknown []UnknownAttribute
+	for _, attr := range si.AuthenticatedAttributes {
+		if !isKnownAttribute(attr.Type) {
+			unknown = append(unknown, UnknownAttribute{Type: attr.Type, Signed: true})
+		}
+	}
+	for _, attr := range si.UnauthenticatedAttributes {
+		if !isKnownAttribute(attr.Type) {
+			unknown = append(unknown, UnknownAttribute{Type: attr.Type})
+		}
+	}
+	return unknown
+}
+
+// Check returns all unknown attributes of si, and an UnknownAttributeError
+// listing the ones that the policy rejects
+func (p UnknownAttributePolicy) Check(si *SignerInfo) ([]UnknownAttribute, error) {
+	unknown := si.UnknownAttributes()
+	var rejected []UnknownAttribute
+	for _, attr := range unknown {
+		action := p.Unsigned
+		if attr.Signed {
+			action = p.Signed
+			if action == AttributeDefault {
+				action = AttributeStrict
+			}
+		}
+		if action == AttributeStrict {
+			rejected = append(rejected, attr)
+		}
+	}
+	if len(rejected) != 0 {
+		return unknown, UnknownAttributeError{Attributes: rejected}
+	}
+	return unknown, nil
+}
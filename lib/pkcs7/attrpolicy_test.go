@@ -0,0 +1,84 @@
+package pkcs7
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var oidUnknownTest = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 99999, 1}
+
+func newTestSigner(t *testing.T) (crypto.Signer, *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return key, cert
+}
+
+func TestUnknownAttributePolicy(t *testing.T) {
+	key, cert := newTestSigner(t)
+	builder := NewBuilder(key, []*x509.Certificate{cert}, crypto.SHA256)
+	require.NoError(t, builder.SetContentData([]byte("hello")))
+	require.NoError(t, builder.AddAuthenticatedAttribute(oidUnknownTest, "from the future"))
+	psd, err := builder.Sign()
+	require.NoError(t, err)
+	sig, err := psd.Content.Verify(nil, false)
+	require.NoError(t, err)
+
+	unknown := []UnknownAttribute{{Type: oidUnknownTest, Signed: true}}
+	// signed attributes are critical, so the default rejects them
+	found, err := UnknownAttributePolicy{}.Check(sig.SignerInfo)
+	assert.Equal(t, unknown, found)
+	assert.Equal(t, UnknownAttributeError{Attributes: unknown}, err)
+	assert.EqualError(t, err, "signature has unknown signed attribute 1.3.6.1.4.1.99999.1")
+	found, err = UnknownAttributePolicy{Signed: AttributeStrict}.Check(sig.SignerInfo)
+	assert.Equal(t, unknown, found)
+	assert.Equal(t, UnknownAttributeError{Attributes: unknown}, err)
+	// lenient reports the attribute but accepts the signature
+	found, err = UnknownAttributePolicy{Signed: AttributeLenient}.Check(sig.SignerInfo)
+	assert.Equal(t, unknown, found)
+	assert.NoError(t, err)
+	// the unsigned policy doesn't apply to signed attributes
+	_, err = UnknownAttributePolicy{Unsigned: AttributeLenient}.Check(sig.SignerInfo)
+	assert.Error(t, err)
+
+	// unsigned attributes are lenient by default
+	sig.SignerInfo.AuthenticatedAttributes = nil
+	require.NoError(t, sig.SignerInfo.UnauthenticatedAttributes.Add(oidUnknownTest, "from the future"))
+	unknown = []UnknownAttribute{{Type: oidUnknownTest}}
+	found, err = UnknownAttributePolicy{}.Check(sig.SignerInfo)
+	assert.Equal(t, unknown, found)
+	assert.NoError(t, err)
+	found, err = UnknownAttributePolicy{Signed: AttributeLenient}.Check(sig.SignerInfo)
+	assert.Equal(t, unknown, found)
+	assert.NoError(t, err)
+	found, err = UnknownAttributePolicy{Unsigned: AttributeStrict}.Check(sig.SignerInfo)
+	assert.Equal(t, unknown, found)
+	assert.Error(t, err)
+
+	// registered attributes are not reported
+	RegisterAttribute(oidUnknownTest)
+	found, err = UnknownAttributePolicy{Unsigned: AttributeStrict}.Check(sig.SignerInfo)
+	assert.Empty(t, found)
+	assert.NoError(t, err)
+}
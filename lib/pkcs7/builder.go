@@ -74,7 +74,8 @@ func (sb *SignatureBuilder) SetContentInfo(cinfo ContentInfo) error {
 	return nil
 }
 
-// Set a "detached" content type, with digest
+// Set a "detached" content type, with digest. Authenticated attributes are
+// always included so the digest is recorded in the signature.
 func (sb *SignatureBuilder) SetDetachedContent(ctype asn1.ObjectIdentifier, digest []byte) error {
 	if len(digest) != sb.signerOpts.HashFunc().Size() {
 		return errors.New("digest size mismatch")
@@ -82,6 +83,9 @@ func (sb *SignatureBuilder) SetDetachedContent(ctype asn1.ObjectIdentifier, dige
 	cinfo, _ := NewContentInfo(ctype, nil)
 	sb.contentInfo = cinfo
 	sb.digest = digest
+	if sb.authAttrs == nil {
+		sb.authAttrs = AttributeList{}
+	}
 	return nil
 }
 
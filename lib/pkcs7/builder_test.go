@@ -0,0 +1,161 @@
+package pkcs7
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mind-security/relic/v8/lib/x509tools"
+)
+
+func selfSigned(t *testing.T, key crypto.Signer) *x509.Certificate {
+	t.Helper()
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert
+}
+
+func TestBuilderAlgorithms(t *testing.T) {
+	mustKey := func(key crypto.Signer, err error) crypto.Signer {
+		require.NoError(t, err)
+		return key
+	}
+	for _, tc := range []struct {
+		name   string
+		key    crypto.Signer
+		digest asn1.ObjectIdentifier
+		sigAlg asn1.ObjectIdentifier
+	}{
+		{"rsa2048", mustKey(rsa.GenerateKey(rand.Reader, 2048)), x509tools.OidDigestSHA256, x509tools.OidPublicKeyRSA},
+		{"rsa4096", mustKey(rsa.GenerateKey(rand.Reader, 4096)), x509tools.OidDigestSHA384, x509tools.OidPublicKeyRSA},
+		{"p256", mustKey(ecdsa.GenerateKey(elliptic.P256(), rand.Reader)), x509tools.OidDigestSHA256, x509tools.OidPublicKeyECDSA},
+		{"p384", mustKey(ecdsa.GenerateKey(elliptic.P384(), rand.Reader)), x509tools.OidDigestSHA384, x509tools.OidPublicKeyECDSA},
+		{"p521", mustKey(ecdsa.GenerateKey(elliptic.P521(), rand.Reader)), x509tools.OidDigestSHA512, x509tools.OidPublicKeyECDSA},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			hash := x509tools.HashForKey(tc.key.Public())
+			cert := selfSigned(t, tc.key)
+			content := []byte("hello")
+			b := NewBuilder(tc.key, []*x509.Certificate{cert}, hash)
+			require.NoError(t, b.SetContentData(content))
+			psd, err := b.Sign()
+			require.NoError(t, err)
+
+			sd := psd.Content
+			require.Len(t, sd.DigestAlgorithmIdentifiers, 1)
+			assert.Equal(t, tc.digest, sd.DigestAlgorithmIdentifiers[0].Algorithm)
+			si := sd.SignerInfos[0]
+			assert.Equal(t, tc.digest, si.DigestAlgorithm.Algorithm)
+			assert.Equal(t, tc.sigAlg, si.DigestEncryptionAlgorithm.Algorithm)
+
+			blob, err := psd.Marshal()
+			require.NoError(t, err)
+			parsed, err := Unmarshal(blob)
+			require.NoError(t, err)
+			sig, err := parsed.Content.Verify(nil, false)
+			require.NoError(t, err)
+			assert.Equal(t, cert.Raw, sig.Certificate.Raw)
+		})
+	}
+}
+
+func TestStapledRevocationInfo(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	cert := selfSigned(t, key)
+	b := NewBuilder(key, []*x509.Certificate{cert}, crypto.SHA256)
+	require.NoError(t, b.SetContentData([]byte("hello")))
+	psd, err := b.Sign()
+	require.NoError(t, err)
+
+	crl, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: time.Now(),
+		NextUpdate: time.Now().Add(time.Hour),
+	}, &x509.Certificate{
+		Subject:      cert.Subject,
+		SubjectKeyId: []byte{1},
+		KeyUsage:     x509.KeyUsageCRLSign,
+	}, key)
+	require.NoError(t, err)
+	fakeOCSP, err := asn1.Marshal([]int{1, 2, 3})
+	require.NoError(t, err)
+	other, err := asn1.MarshalWithParams(OtherRevocationInfoFormat{
+		Format: OidRevocationInfoOCSP,
+		Info:   asn1.RawValue{FullBytes: fakeOCSP},
+	}, "tag:1")
+	require.NoError(t, err)
+	psd.Content.CRLs = RevocationInfoChoices{{FullBytes: crl}, {FullBytes: other}}
+
+	blob, err := psd.Marshal()
+	require.NoError(t, err)
+	parsed, err := Unmarshal(blob)
+	require.NoError(t, err)
+	sig, err := parsed.Content.Verify(nil, false)
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{crl}, sig.CRLs)
+	assert.Equal(t, [][]byte{fakeOCSP}, sig.OCSPResponses)
+}
+
+func TestMerge(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	cert := selfSigned(t, key)
+	content := []byte("hello")
+	var psd *ContentInfoSignedData
+	for _, hash := range []crypto.Hash{crypto.SHA256, crypto.SHA512} {
+		b := NewBuilder(key, []*x509.Certificate{cert}, hash)
+		require.NoError(t, b.SetContentData(content))
+		other, err := b.Sign()
+		require.NoError(t, err)
+		if psd == nil {
+			psd = other
+		} else {
+			require.NoError(t, psd.Merge(other))
+		}
+	}
+	sd := psd.Content
+	require.Len(t, sd.SignerInfos, 2)
+	assert.Len(t, sd.DigestAlgorithmIdentifiers, 2)
+	assert.Len(t, sd.Certificates, 1)
+
+	blob, err := psd.Marshal()
+	require.NoError(t, err)
+	parsed, err := Unmarshal(blob)
+	require.NoError(t, err)
+	sigs, err := parsed.Content.VerifyAll(nil, false)
+	require.NoError(t, err)
+	require.Len(t, sigs, 2)
+	// SignerInfos is a SET OF, so DER encoding may reorder them
+	var algs []asn1.ObjectIdentifier
+	for _, sig := range sigs {
+		algs = append(algs, sig.SignerInfo.DigestAlgorithm.Algorithm)
+	}
+	assert.ElementsMatch(t, []asn1.ObjectIdentifier{x509tools.OidDigestSHA256, x509tools.OidDigestSHA512}, algs)
+
+	// different content can't be merged
+	b := NewBuilder(key, []*x509.Certificate{cert}, crypto.SHA256)
+	require.NoError(t, b.SetContentData([]byte("goodbye")))
+	other, err := b.Sign()
+	require.NoError(t, err)
+	assert.Error(t, psd.Merge(other))
+}
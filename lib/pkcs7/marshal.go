@@ -19,6 +19,7 @@ package pkcs7
 import (
 	"bytes"
 	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/asn1"
 	"errors"
 	"fmt"
@@ -51,6 +52,51 @@ func (psd *ContentInfoSignedData) Detach() ([]byte, error) {
 	return content, nil
 }
 
+// Merge the signers of another signature over the same content into this one,
+// so that a verifier can choose whichever digest or key it supports. Digest
+// algorithms and certificates that are already present are not repeated.
+func (psd *ContentInfoSignedData) Merge(other *ContentInfoSignedData) error {
+	sd, osd := &psd.Content, &other.Content
+	if !sd.ContentInfo.ContentType.Equal(osd.ContentInfo.ContentType) || !bytes.Equal(sd.ContentInfo.Raw, osd.ContentInfo.Raw) {
+		return errors.New("pkcs7: can't merge signatures over different content")
+	}
+	for _, alg := range osd.DigestAlgorithmIdentifiers {
+		if !hasAlgorithm(sd.DigestAlgorithmIdentifiers, alg) {
+			sd.DigestAlgorithmIdentifiers = append(sd.DigestAlgorithmIdentifiers, alg)
+		}
+	}
+	for _, cert := range osd.Certificates {
+		if !hasRaw(sd.Certificates, cert) {
+			sd.Certificates = append(sd.Certificates, cert)
+		}
+	}
+	for _, crl := range osd.CRLs {
+		if !hasRaw(sd.CRLs, crl) {
+			sd.CRLs = append(sd.CRLs, crl)
+		}
+	}
+	sd.SignerInfos = append(sd.SignerInfos, osd.SignerInfos...)
+	return nil
+}
+
+func hasAlgorithm(algs []pkix.AlgorithmIdentifier, alg pkix.AlgorithmIdentifier) bool {
+	for _, a := range algs {
+		if a.Algorithm.Equal(alg.Algorithm) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasRaw(values []asn1.RawValue, v asn1.RawValue) bool {
+	for _, x := range values {
+		if bytes.Equal(x.FullBytes, v.FullBytes) {
+			return true
+		}
+	}
+	return false
+}
+
 // dump raw certificates to structure
 func marshalCertificates(certs []*x509.Certificate) RawCertificates {
 	c := make(RawCertificates, len(certs))
@@ -79,6 +125,26 @@ func (raw RawCertificates) Parse() ([]*x509.Certificate, error) {
 	return certs, nil
 }
 
+// Parse stapled revocation data, returning the DER of each OCSP response and
+// CRL. Unrecognized formats are skipped.
+func (raw RevocationInfoChoices) Parse() (ocspResponses, crls [][]byte, err error) {
+	for _, choice := range raw {
+		switch {
+		case choice.Class == asn1.ClassUniversal && choice.Tag == asn1.TagSequence:
+			crls = append(crls, choice.FullBytes)
+		case choice.Class == asn1.ClassContextSpecific && choice.Tag == 1:
+			var other OtherRevocationInfoFormat
+			if _, err := asn1.UnmarshalWithParams(choice.FullBytes, &other, "tag:1"); err != nil {
+				return nil, nil, fmt.Errorf("pkcs7: parsing revocation info: %w", err)
+			}
+			if other.Format.Equal(OidRevocationInfoOCSP) {
+				ocspResponses = append(ocspResponses, other.Info.FullBytes)
+			}
+		}
+	}
+	return
+}
+
 type CertificateError struct {
 	Invalid [][]byte
 	Err     error
@@ -35,6 +35,7 @@ var (
 	OidAttributeContentType   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
 	OidAttributeMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
 	OidAttributeSigningTime   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
+	OidRevocationInfoOCSP     = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 16, 2}
 )
 
 const MimeType = "application/pkcs7-mime"
@@ -54,12 +55,21 @@ type SignedData struct {
 	DigestAlgorithmIdentifiers []pkix.AlgorithmIdentifier `asn1:"set"`
 	ContentInfo                ContentInfo                ``
 	Certificates               RawCertificates            `asn1:"optional,tag:0"`
-	CRLs                       []pkix.CertificateList     `asn1:"optional,tag:1"`
+	CRLs                       RevocationInfoChoices      `asn1:"optional,tag:1"`
 	SignerInfos                []SignerInfo               `asn1:"set"`
 }
 
 type RawCertificates []asn1.RawValue
 
+// RevocationInfoChoices holds CRLs and other revocation data, such as OCSP
+// responses, stapled to a SignedData (RFC 5652 section 10.2.1)
+type RevocationInfoChoices []asn1.RawValue
+
+type OtherRevocationInfoFormat struct {
+	Format asn1.ObjectIdentifier
+	Info   asn1.RawValue
+}
+
 type Attribute struct {
 	Type   asn1.ObjectIdentifier
 	Values asn1.RawValue
@@ -36,6 +36,9 @@ type Signature struct {
 	Certificate   *x509.Certificate
 	Intermediates []*x509.Certificate
 	CertError     error
+	// OCSPResponses and CRLs hold revocation data stapled to the signature
+	OCSPResponses [][]byte
+	CRLs          [][]byte
 }
 
 // Verify the content in a SignedData structure. External content may be
@@ -45,49 +48,67 @@ type Signature struct {
 //
 // If skipDigests is true, then the main content section is not checked, but
 // the SignerInfos are still checked for a valid signature.
+//
+// All SignerInfos must be valid. If there is more than one, the last is
+// returned; use VerifyAll to get each of them.
 func (sd *SignedData) Verify(externalContent []byte, skipDigests bool) (Signature, error) {
+	sigs, err := sd.VerifyAll(externalContent, skipDigests)
+	if err != nil {
+		return Signature{}, err
+	}
+	return sigs[len(sigs)-1], nil
+}
+
+// VerifyAll is like Verify but returns information about every SignerInfo, in
+// the order they appear
+func (sd *SignedData) VerifyAll(externalContent []byte, skipDigests bool) ([]Signature, error) {
 	var content []byte
 	if !skipDigests {
 		var err error
 		content, err = sd.ContentInfo.Bytes()
 		if err != nil {
-			return Signature{}, err
+			return nil, err
 		} else if content == nil {
 			if externalContent == nil {
-				return Signature{}, errors.New("pkcs7: missing content")
+				return nil, errors.New("pkcs7: missing content")
 			}
 			content = externalContent
 		} else if externalContent != nil {
 			if !bytes.Equal(externalContent, content) {
-				return Signature{}, errors.New("pkcs7: internal and external content were both provided but are not equal")
+				return nil, errors.New("pkcs7: internal and external content were both provided but are not equal")
 			}
 		}
 	}
 	if len(sd.SignerInfos) == 0 {
-		return Signature{}, sigerrors.NotSignedError{Type: "pkcs7"}
+		return nil, sigerrors.NotSignedError{Type: "pkcs7"}
 	}
 	certs, certErr := sd.Certificates.Parse()
+	ocspResponses, crls, err := sd.CRLs.Parse()
+	if err != nil {
+		return nil, err
+	}
 	// postpone handling of cert parse error until something is actually missing
-	var cert *x509.Certificate
-	var sig Signature
-	for _, si := range sd.SignerInfos {
-		var err error
-		cert, err = si.Verify(content, skipDigests, certs)
+	sigs := make([]Signature, len(sd.SignerInfos))
+	for i := range sd.SignerInfos {
+		si := &sd.SignerInfos[i]
+		cert, err := si.Verify(content, skipDigests, certs)
 		if err != nil {
 			if errors.As(err, &MissingCertificateError{}) && certErr != nil {
 				// now surface the parse error
 				err = certErr
 			}
-			return Signature{}, err
+			return nil, err
 		}
-		sig = Signature{
-			SignerInfo:    &si,
+		sigs[i] = Signature{
+			SignerInfo:    si,
 			Certificate:   cert,
 			Intermediates: certs,
 			CertError:     certErr,
+			OCSPResponses: ocspResponses,
+			CRLs:          crls,
 		}
 	}
-	return sig, nil
+	return sigs, nil
 }
 
 // Find the certificate that signed this SignerInfo from the bucket of certs
@@ -167,6 +188,13 @@ func (si *SignerInfo) Verify(content []byte, skipDigests bool, certs []*x509.Cer
 // PKCS#9 trusted timestamp was found, pass that timestamp in currentTime to
 // validate the chain as of the time of the signature.
 func (info Signature) VerifyChain(roots *x509.CertPool, extraCerts []*x509.Certificate, usage x509.ExtKeyUsage, currentTime time.Time) error {
+	_, err := info.BuildChain(roots, extraCerts, usage, currentTime)
+	return err
+}
+
+// BuildChain is like VerifyChain but also returns the constructed chain,
+// starting with the signing certificate and ending with a trusted root.
+func (info Signature) BuildChain(roots *x509.CertPool, extraCerts []*x509.Certificate, usage x509.ExtKeyUsage, currentTime time.Time) ([]*x509.Certificate, error) {
 	pool := x509.NewCertPool()
 	for _, cert := range extraCerts {
 		pool.AddCert(cert)
@@ -180,13 +208,13 @@ func (info Signature) VerifyChain(roots *x509.CertPool, extraCerts []*x509.Certi
 		CurrentTime:   currentTime,
 		KeyUsages:     []x509.ExtKeyUsage{usage},
 	}
-	_, err := info.Certificate.Verify(opts)
+	chains, err := info.Certificate.Verify(opts)
 	if err == nil {
-		return nil
+		return chains[0], nil
 	}
 	if e := new(x509.UnknownAuthorityError); errors.As(err, e) && info.CertError != nil {
 		// surface a saved cert parse error
-		return fmt.Errorf("%w: after failing to parse a bundled certificate: %s", err, info.CertError)
+		return nil, fmt.Errorf("%w: after failing to parse a bundled certificate: %s", err, info.CertError)
 	}
-	return err
+	return nil, err
 }
@@ -33,32 +33,49 @@ import (
 
 // Create a HTTP request to request a token from the given URL
 func NewRequest(url string, hash crypto.Hash, hashValue []byte) (msg *TimeStampReq, req *http.Request, err error) {
+	msg, err = NewTimeStampReq(hash, hashValue, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	req, err = msg.NewHTTPRequest(url)
+	return
+}
+
+// NewTimeStampReq builds a RFC 3161 request message for the given digest. If
+// certReq is false then the TSA is asked to omit its certificate from the
+// response.
+func NewTimeStampReq(hash crypto.Hash, hashValue []byte, certReq bool) (*TimeStampReq, error) {
 	alg, ok := x509tools.PkixDigestAlgorithm(hash)
 	if !ok {
-		return nil, nil, errors.New("unknown digest algorithm")
+		return nil, errors.New("unknown digest algorithm")
 	}
-	msg = &TimeStampReq{
+	return &TimeStampReq{
 		Version: 1,
 		MessageImprint: MessageImprint{
 			HashAlgorithm: alg,
 			HashedMessage: hashValue,
 		},
 		Nonce:   x509tools.MakeSerial(),
-		CertReq: true,
-	}
+		CertReq: certReq,
+	}, nil
+}
+
+// NewHTTPRequest marshals the request message into a HTTP POST to the given URL
+func (msg *TimeStampReq) NewHTTPRequest(url string) (*http.Request, error) {
 	reqbytes, err := asn1.Marshal(*msg)
 	if err != nil {
-		return
+		return nil, err
 	}
-	req, err = http.NewRequest("POST", url, bytes.NewReader(reqbytes))
+	req, err := http.NewRequest("POST", url, bytes.NewReader(reqbytes))
 	if err != nil {
-		return
+		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/timestamp-query")
-	return
+	return req, nil
 }
 
-// Parse a timestamp token from a HTTP response, sanity checking it against the original request nonce
+// Parse a timestamp token from a HTTP response, sanity checking it against
+// the nonce and message imprint of the original request
 func (req *TimeStampReq) ParseResponse(body []byte) (*pkcs7.ContentInfoSignedData, error) {
 	respmsg := new(TimeStampResp)
 	if rest, err := asn1.Unmarshal(body, respmsg); err != nil {
@@ -74,7 +91,8 @@ func (req *TimeStampReq) ParseResponse(body []byte) (*pkcs7.ContentInfoSignedDat
 	return &respmsg.TimeStampToken, nil
 }
 
-// Sanity check a timestamp token against the nonce in the original request
+// Sanity check a timestamp token against the nonce and message imprint in the
+// original request
 func (req *TimeStampReq) SanityCheckToken(psd *pkcs7.ContentInfoSignedData) error {
 	if _, err := psd.Content.Verify(nil, false); err != nil {
 		return err
@@ -83,9 +101,14 @@ func (req *TimeStampReq) SanityCheckToken(psd *pkcs7.ContentInfoSignedData) erro
 	if err != nil {
 		return err
 	}
-	if req.Nonce.Cmp(info.Nonce) != 0 {
+	if info.Nonce == nil {
+		return errors.New("response has no nonce")
+	} else if req.Nonce.Cmp(info.Nonce) != 0 {
 		return errors.New("request nonce mismatch")
 	}
+	if !info.MessageImprint.HashAlgorithm.Algorithm.Equal(req.MessageImprint.HashAlgorithm.Algorithm) {
+		return errors.New("message imprint algorithm mismatch")
+	}
 	if !hmac.Equal(info.MessageImprint.HashedMessage, req.MessageImprint.HashedMessage) {
 		return errors.New("message imprint mismatch")
 	}
@@ -0,0 +1,105 @@
+package pkcs9
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mind-security/relic/v8/lib/pkcs7"
+)
+
+// fakeToken signs a TSTInfo answering req, with the given nonce
+func fakeToken(t *testing.T, req *TimeStampReq, nonce *big.Int) *pkcs7.ContentInfoSignedData {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "TSA"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	info := TSTInfo{
+		Version:        1,
+		Policy:         asn1.ObjectIdentifier{1, 2, 3},
+		MessageImprint: req.MessageImprint,
+		SerialNumber:   big.NewInt(99),
+		GenTime:        asn1.RawValue{Tag: asn1.TagGeneralizedTime, Bytes: []byte(time.Now().UTC().Format("20060102150405Z"))},
+		Nonce:          nonce,
+	}
+	infoDER, err := asn1.Marshal(info)
+	require.NoError(t, err)
+	builder := pkcs7.NewBuilder(key, []*x509.Certificate{cert}, crypto.SHA256)
+	require.NoError(t, builder.SetContent(OidTSTInfo, infoDER))
+	psd, err := builder.Sign()
+	require.NoError(t, err)
+	return psd
+}
+
+func TestSanityCheckNonce(t *testing.T) {
+	digest := sha256.Sum256([]byte("signature"))
+	req, err := NewTimeStampReq(crypto.SHA256, digest[:], true)
+	require.NoError(t, err)
+	require.NotNil(t, req.Nonce)
+	other, err := NewTimeStampReq(crypto.SHA256, digest[:], true)
+	require.NoError(t, err)
+	assert.NotEqual(t, req.Nonce, other.Nonce, "each request gets its own nonce")
+
+	assert.NoError(t, req.SanityCheckToken(fakeToken(t, req, req.Nonce)))
+	assert.EqualError(t, req.SanityCheckToken(fakeToken(t, req, other.Nonce)), "request nonce mismatch")
+	assert.EqualError(t, req.SanityCheckToken(fakeToken(t, req, nil)), "response has no nonce")
+}
+
+func TestParseResponse(t *testing.T) {
+	digest := sha256.Sum256([]byte("signature"))
+	req, err := NewTimeStampReq(crypto.SHA256, digest[:], true)
+	require.NoError(t, err)
+	respond := func(token *pkcs7.ContentInfoSignedData) []byte {
+		blob, err := asn1.Marshal(TimeStampResp{
+			Status:         PKIStatusInfo{Status: StatusGranted},
+			TimeStampToken: *token,
+		})
+		require.NoError(t, err)
+		return blob
+	}
+
+	psd, err := req.ParseResponse(respond(fakeToken(t, req, req.Nonce)))
+	require.NoError(t, err)
+	assert.NotNil(t, psd)
+
+	// the TSA answered some other request
+	_, err = req.ParseResponse(respond(fakeToken(t, req, big.NewInt(12345))))
+	assert.ErrorContains(t, err, "request nonce mismatch")
+
+	otherDigest := sha256.Sum256([]byte("something else"))
+	otherImprint, err := NewTimeStampReq(crypto.SHA256, otherDigest[:], true)
+	require.NoError(t, err)
+	_, err = req.ParseResponse(respond(fakeToken(t, otherImprint, req.Nonce)))
+	assert.ErrorContains(t, err, "message imprint mismatch")
+
+	// same digest bytes, but labelled with another algorithm
+	otherAlg, err := NewTimeStampReq(crypto.SHA384, digest[:], true)
+	require.NoError(t, err)
+	_, err = req.ParseResponse(respond(fakeToken(t, otherAlg, req.Nonce)))
+	assert.ErrorContains(t, err, "message imprint algorithm mismatch")
+
+	denied, err := asn1.Marshal(TimeStampResp{Status: PKIStatusInfo{Status: 2}})
+	require.NoError(t, err)
+	_, err = req.ParseResponse(denied)
+	assert.ErrorContains(t, err, "request denied")
+}
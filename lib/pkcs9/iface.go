@@ -25,6 +25,13 @@ type Timestamper interface {
 	Timestamp(ctx context.Context, req *Request) (*pkcs7.ContentInfoSignedData, error)
 }
 
+// Wrapper is implemented by Timestamper middleware so that options attached
+// further down, such as the timestamp style and cap, can still be found
+type Wrapper interface {
+	Timestamper
+	Unwrap() Timestamper
+}
+
 // Request holds parameters for a timestamp operation
 type Request struct {
 	// EncryptedDigest is the raw encrypted signature value
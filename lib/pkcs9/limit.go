@@ -0,0 +1,101 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package pkcs9
+
+import (
+	"encoding/asn1"
+	"fmt"
+
+	"github.com/mind-security/relic/v8/lib/pkcs7"
+)
+
+// LimitedTimestamper caps how many timestamps a single signature may
+// accumulate, to keep repeated re-timestamping from bloating it
+type LimitedTimestamper struct {
+	Timestamper
+	// Max is the most timestamps a signature may carry. Zero means no limit.
+	Max int
+}
+
+func (t LimitedTimestamper) Unwrap() Timestamper {
+	return t.Timestamper
+}
+
+// MaxTimestampsOf returns the timestamp cap of a timestamper, or 0 if it has
+// none. Any middleware wrapped around the LimitedTimestamper is looked through.
+func MaxTimestampsOf(t Timestamper) int {
+	for {
+		switch tt := t.(type) {
+		case LimitedTimestamper:
+			return tt.Max
+		case Wrapper:
+			t = tt.Unwrap()
+		default:
+			return 0
+		}
+	}
+}
+
+// TooManyTimestampsError is returned when adding a timestamp would exceed the
+// configured cap
+type TooManyTimestampsError struct {
+	Count, Max int
+}
+
+func (e TooManyTimestampsError) Error() string {
+	return fmt.Sprintf("signature already has %d timestamp(s), the maximum allowed is %d", e.Count, e.Max)
+}
+
+// CountTimestamps returns the number of RFC 3161 timestamp tokens and
+// counter-signatures attached to signerInfo
+func CountTimestamps(signerInfo *pkcs7.SignerInfo) (int, error) {
+	var count int
+	for _, attr := range signerInfo.UnauthenticatedAttributes {
+		if !attr.Type.Equal(OidAttributeTimeStampToken) &&
+			!attr.Type.Equal(OidSpcTimeStampToken) &&
+			!attr.Type.Equal(OidAttributeCounterSign) {
+			continue
+		}
+		rest := attr.Values.Bytes
+		for len(rest) > 0 {
+			var value asn1.RawValue
+			var err error
+			rest, err = asn1.Unmarshal(rest, &value)
+			if err != nil {
+				return 0, fmt.Errorf("parsing attribute %s: %w", attr.Type, err)
+			}
+			count++
+		}
+	}
+	return count, nil
+}
+
+// CheckTimestampLimit returns an error if signerInfo cannot accept another
+// timestamp without exceeding max. A max of zero means no limit.
+func CheckTimestampLimit(signerInfo *pkcs7.SignerInfo, max int) error {
+	if max <= 0 {
+		return nil
+	}
+	count, err := CountTimestamps(signerInfo)
+	if err != nil {
+		return err
+	}
+	if count >= max {
+		return TooManyTimestampsError{Count: count, Max: max}
+	}
+	return nil
+}
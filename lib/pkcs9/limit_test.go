@@ -0,0 +1,64 @@
+package pkcs9
+
+import (
+	"context"
+	"crypto/x509/pkix"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mind-security/relic/v8/lib/pkcs7"
+	"github.com/mind-security/relic/v8/lib/x509tools"
+)
+
+type countingTimestamper struct {
+	calls int
+}
+
+func (t *countingTimestamper) Timestamp(ctx context.Context, req *Request) (*pkcs7.ContentInfoSignedData, error) {
+	t.calls++
+	return nil, errors.New("not implemented")
+}
+
+func TestTimestampLimit(t *testing.T) {
+	const max = 3
+	token := pkcs7.ContentInfoSignedData{
+		ContentType: pkcs7.OidSignedData,
+		Content:     pkcs7.SignedData{Version: 1, ContentInfo: pkcs7.ContentInfo{ContentType: OidTSTInfo}},
+	}
+	psd := &pkcs7.ContentInfoSignedData{
+		ContentType: pkcs7.OidSignedData,
+		Content: pkcs7.SignedData{SignerInfos: []pkcs7.SignerInfo{{
+			DigestAlgorithm: pkix.AlgorithmIdentifier{Algorithm: x509tools.OidDigestSHA256},
+		}}},
+	}
+	signerInfo := &psd.Content.SignerInfos[0]
+	// mix both OIDs to make sure they count against the same cap
+	for i := 0; i < max; i++ {
+		require.NoError(t, CheckTimestampLimit(signerInfo, max))
+		if i%2 == 0 {
+			require.NoError(t, AddStampToSignedData(signerInfo, token))
+		} else {
+			require.NoError(t, AddStampToSignedAuthenticode(signerInfo, token))
+		}
+	}
+	count, err := CountTimestamps(signerInfo)
+	require.NoError(t, err)
+	assert.Equal(t, max, count)
+	assert.NoError(t, CheckTimestampLimit(signerInfo, 0))
+
+	err = CheckTimestampLimit(signerInfo, max)
+	var tooMany TooManyTimestampsError
+	require.ErrorAs(t, err, &tooMany)
+	assert.Equal(t, TooManyTimestampsError{Count: max, Max: max}, tooMany)
+
+	// the timestamp server isn't contacted once the cap is reached
+	inner := new(countingTimestamper)
+	limited := StyledTimestamper{Timestamper: LimitedTimestamper{Timestamper: inner, Max: max}, Style: StyleFallback}
+	assert.Equal(t, max, MaxTimestampsOf(limited))
+	_, err = TimestampAndMarshal(context.Background(), psd, limited, true)
+	assert.ErrorAs(t, err, &tooMany)
+	assert.Equal(t, 0, inner.calls)
+}
@@ -21,6 +21,7 @@ import (
 	"context"
 	"crypto"
 	"crypto/x509"
+	"encoding/asn1"
 	"errors"
 	"fmt"
 	"time"
@@ -31,25 +32,67 @@ import (
 
 func TimestampAndMarshal(ctx context.Context, psd *pkcs7.ContentInfoSignedData, timestamper Timestamper, authenticode bool) (*TimestampedSignature, error) {
 	if timestamper != nil {
-		signerInfo := &psd.Content.SignerInfos[0]
+		if err := addTimestamp(ctx, psd, timestamper, authenticode); err != nil {
+			return nil, err
+		}
+	}
+	return selfCheckAndMarshal(psd, false)
+}
+
+// TimestampAndMarshalDetached is like TimestampAndMarshal for a signature over
+// detached content, which isn't available to check the digest against
+func TimestampAndMarshalDetached(ctx context.Context, psd *pkcs7.ContentInfoSignedData, timestamper Timestamper) (*TimestampedSignature, error) {
+	if timestamper != nil {
+		if err := addTimestamp(ctx, psd, timestamper, false); err != nil {
+			return nil, err
+		}
+	}
+	return selfCheckAndMarshal(psd, true)
+}
+
+func addTimestamp(ctx context.Context, psd *pkcs7.ContentInfoSignedData, timestamper Timestamper, authenticode bool) error {
+	// only authenticode signatures can carry legacy timestamps
+	style := StyleRFC3161
+	if authenticode {
+		style = StyleOf(timestamper)
+	}
+	if style != StyleRFC3161 && len(psd.Content.SignerInfos) > 1 {
+		return errors.New("pkcs9: legacy timestamps can only be added to a single signer")
+	}
+	// each signer gets its own timestamp over its own signature value
+	for i := range psd.Content.SignerInfos {
+		signerInfo := &psd.Content.SignerInfos[i]
 		hash, err := x509tools.PkixDigestToHashE(signerInfo.DigestAlgorithm)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		if err := CheckTimestampLimit(signerInfo, MaxTimestampsOf(timestamper)); err != nil {
+			return err
 		}
-		token, err := timestamper.Timestamp(ctx, &Request{EncryptedDigest: signerInfo.EncryptedDigest, Hash: hash})
+		token, legacy, err := timestampStyled(ctx, timestamper, style, &Request{EncryptedDigest: signerInfo.EncryptedDigest, Hash: hash})
 		if err != nil {
-			return nil, err
+			return err
 		}
-		if authenticode {
+		if legacy {
+			err = AddLegacyStamp(&psd.Content, token)
+		} else if authenticode {
 			err = AddStampToSignedAuthenticode(signerInfo, *token)
 		} else {
 			err = AddStampToSignedData(signerInfo, *token)
 		}
 		if err != nil {
-			return nil, err
+			return err
+		}
+		// a previously parsed signature would otherwise re-marshal its old bytes
+		if err := signerInfo.RefreshUnauthenticated(); err != nil {
+			return err
 		}
 	}
-	verified, err := psd.Content.Verify(nil, false)
+	return nil
+}
+
+func selfCheckAndMarshal(psd *pkcs7.ContentInfoSignedData, skipDigests bool) (*TimestampedSignature, error) {
+	verified, err := psd.Content.Verify(nil, skipDigests)
 	if err != nil {
 		return nil, fmt.Errorf("pkcs7: failed signature self-check: %w", err)
 	}
@@ -65,6 +108,28 @@ func TimestampAndMarshal(ctx context.Context, psd *pkcs7.ContentInfoSignedData,
 	return &ts, err
 }
 
+// AddTimestamp attaches a new timestamp to an existing signature, keeping any
+// that are already there. The timestamp covers the same signature value as the
+// original, so the signing time is preserved while the new timestamp extends
+// how long the signature can be verified.
+func AddTimestamp(ctx context.Context, blob []byte, timestamper Timestamper, authenticode bool) (*TimestampedSignature, error) {
+	if timestamper == nil {
+		return nil, errors.New("no timestamp server is configured")
+	}
+	psd, err := pkcs7.Unmarshal(blob)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := psd.Content.Verify(nil, true); err != nil {
+		return nil, fmt.Errorf("existing signature: %w", err)
+	}
+	if err := addTimestamp(ctx, psd, timestamper, authenticode); err != nil {
+		return nil, err
+	}
+	// the content may be detached, so only the signatures can be checked
+	return selfCheckAndMarshal(psd, true)
+}
+
 // Attach a RFC 3161 timestamp to a PKCS#7 SignerInfo
 func AddStampToSignedData(signerInfo *pkcs7.SignerInfo, token pkcs7.ContentInfoSignedData) error {
 	return signerInfo.UnauthenticatedAttributes.Add(OidAttributeTimeStampToken, token)
@@ -80,6 +145,9 @@ type CounterSignature struct {
 	pkcs7.Signature
 	Hash        crypto.Hash
 	SigningTime time.Time
+	// Legacy is true if this is a counter-signature, such as a Microsoft
+	// legacy timestamp, rather than a RFC 3161 timestamp token
+	Legacy bool
 }
 
 // Validated signature containing a optional timestamp token
@@ -87,39 +155,69 @@ type TimestampedSignature struct {
 	pkcs7.Signature
 	CounterSignature *CounterSignature
 	Raw              []byte
+	// Timestamps holds every timestamp on the signature, including
+	// CounterSignature, in the order they were added. Signatures that have
+	// been re-timestamped have more than one.
+	Timestamps []*CounterSignature
 }
 
 // Look for a timestamp (counter-signature or timestamp token) in the
 // UnauthenticatedAttributes of the given already-validated signature and check
 // its integrity. The certificate chain is not checked; call VerifyChain() on
-// the result to validate it fully. Returns nil if no timestamp is present.
+// the result to validate it fully. Returns nil if no timestamp is present. If
+// there are several then the first one is returned.
 func VerifyPkcs7(sig pkcs7.Signature) (*CounterSignature, error) {
-	var tst pkcs7.ContentInfoSignedData
-	// check several OIDs for timestamp tokens
-	err := sig.SignerInfo.UnauthenticatedAttributes.GetOne(OidAttributeTimeStampToken, &tst)
-	if _, ok := err.(pkcs7.ErrNoAttribute); ok {
-		err = sig.SignerInfo.UnauthenticatedAttributes.GetOne(OidSpcTimeStampToken, &tst)
-	}
-	var imprintHash crypto.Hash
-	if err == nil {
-		// timestamptoken is a fully nested signedData containing a TSTInfo
-		// that digests the parent signature blob
-		return Verify(&tst, sig.SignerInfo.EncryptedDigest, sig.Intermediates)
-	} else if _, ok := err.(pkcs7.ErrNoAttribute); ok {
-		tsi := new(pkcs7.SignerInfo)
-		if err := sig.SignerInfo.UnauthenticatedAttributes.GetOne(OidAttributeCounterSign, tsi); err != nil {
-			if _, ok := err.(pkcs7.ErrNoAttribute); ok {
-				return nil, nil
+	all, err := VerifyAllTimestamps(sig)
+	if err != nil || len(all) == 0 {
+		return nil, err
+	}
+	return all[0], nil
+}
+
+// VerifyAllTimestamps is like VerifyPkcs7 but returns every timestamp attached
+// to the signature, in the order they were added
+func VerifyAllTimestamps(sig pkcs7.Signature) ([]*CounterSignature, error) {
+	var all []*CounterSignature
+	for _, attr := range sig.SignerInfo.UnauthenticatedAttributes {
+		legacy := attr.Type.Equal(OidAttributeCounterSign)
+		if !legacy && !attr.Type.Equal(OidAttributeTimeStampToken) && !attr.Type.Equal(OidSpcTimeStampToken) {
+			continue
+		}
+		rest := attr.Values.Bytes
+		for len(rest) > 0 {
+			var cs *CounterSignature
+			var err error
+			if legacy {
+				// counterSignature is simply a signerinfo. The certificate chain is
+				// included in the parent structure, and the timestamp signs the
+				// signature blob from the parent signerinfo
+				tsi := new(pkcs7.SignerInfo)
+				rest, err = asn1.Unmarshal(rest, tsi)
+				if err != nil {
+					return nil, err
+				}
+				imprintHash, _ := x509tools.PkixDigestToHash(sig.SignerInfo.DigestAlgorithm)
+				cs, err = finishVerify(tsi, sig.SignerInfo.EncryptedDigest, sig.Intermediates, imprintHash, tsi, nil)
+				if cs != nil {
+					cs.Legacy = true
+				}
+			} else {
+				// timestamptoken is a fully nested signedData containing a TSTInfo
+				// that digests the parent signature blob
+				var tst pkcs7.ContentInfoSignedData
+				rest, err = asn1.Unmarshal(rest, &tst)
+				if err != nil {
+					return nil, err
+				}
+				cs, err = Verify(&tst, sig.SignerInfo.EncryptedDigest, sig.Intermediates)
 			}
-			return nil, err
+			if err != nil {
+				return nil, err
+			}
+			all = append(all, cs)
 		}
-		// counterSignature is simply a signerinfo. The certificate chain is
-		// included in the parent structure, and the timestamp signs the
-		// signature blob from the parent signerinfo
-		imprintHash, _ = x509tools.PkixDigestToHash(sig.SignerInfo.DigestAlgorithm)
-		return finishVerify(tsi, sig.SignerInfo.EncryptedDigest, sig.Intermediates, imprintHash, tsi, nil)
 	}
-	return nil, err
+	return all, nil
 }
 
 // Look for a timestamp token or counter-signature in the given signature and
@@ -128,11 +226,14 @@ func VerifyPkcs7(sig pkcs7.Signature) (*CounterSignature, error) {
 // validating the chain.
 func VerifyOptionalTimestamp(sig pkcs7.Signature) (TimestampedSignature, error) {
 	tsig := TimestampedSignature{Signature: sig}
-	ts, err := VerifyPkcs7(sig)
+	all, err := VerifyAllTimestamps(sig)
 	if err != nil {
 		return tsig, err
 	}
-	tsig.CounterSignature = ts
+	if len(all) != 0 {
+		tsig.CounterSignature = all[0]
+		tsig.Timestamps = all
+	}
 	return tsig, nil
 }
 
@@ -146,14 +247,41 @@ func (cs CounterSignature) VerifyChain(roots *x509.CertPool, extraCerts []*x509.
 // the primary signature's chain, making the signature valid after the
 // certificates have expired.
 func (sig TimestampedSignature) VerifyChain(roots *x509.CertPool, extraCerts []*x509.Certificate, usage x509.ExtKeyUsage) error {
+	_, err := sig.BuildChain(roots, extraCerts, usage)
+	return err
+}
+
+// BuildChain is like VerifyChain but also returns the constructed chain of
+// the primary signature, starting with the signing certificate and ending
+// with a trusted root.
+func (sig TimestampedSignature) BuildChain(roots *x509.CertPool, extraCerts []*x509.Certificate, usage x509.ExtKeyUsage) ([]*x509.Certificate, error) {
 	var signingTime time.Time
 	if sig.CounterSignature != nil {
 		if err := sig.CounterSignature.VerifyChain(roots, extraCerts); err != nil {
-			return fmt.Errorf("validating timestamp: %w", err)
+			// a later timestamp can stand in for one that no longer validates
+			cs := sig.laterTimestamp(roots, extraCerts)
+			if cs == nil {
+				return nil, fmt.Errorf("validating timestamp: %w", err)
+			}
+			signingTime = cs.SigningTime
+		} else {
+			signingTime = sig.CounterSignature.SigningTime
+		}
+	}
+	return sig.Signature.BuildChain(roots, extraCerts, usage, signingTime)
+}
+
+// return the first timestamp after CounterSignature with a valid chain
+func (sig TimestampedSignature) laterTimestamp(roots *x509.CertPool, extraCerts []*x509.Certificate) *CounterSignature {
+	for _, cs := range sig.Timestamps {
+		if cs == sig.CounterSignature {
+			continue
+		}
+		if err := cs.VerifyChain(roots, extraCerts); err == nil {
+			return cs
 		}
-		signingTime = sig.CounterSignature.SigningTime
 	}
-	return sig.Signature.VerifyChain(roots, extraCerts, usage, signingTime)
+	return nil
 }
 
 // Verify a non-RFC-3161 timestamp token against the given encrypted digest
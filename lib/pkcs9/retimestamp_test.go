@@ -0,0 +1,102 @@
+package pkcs9
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mind-security/relic/v8/lib/pkcs7"
+)
+
+type fakeTimestamper struct {
+	t *testing.T
+}
+
+func (f fakeTimestamper) Timestamp(ctx context.Context, req *Request) (*pkcs7.ContentInfoSignedData, error) {
+	d := req.Hash.New()
+	d.Write(req.EncryptedDigest)
+	tsreq, err := NewTimeStampReq(req.Hash, d.Sum(nil), false)
+	require.NoError(f.t, err)
+	return fakeToken(f.t, tsreq, nil), nil
+}
+
+func signedData(t *testing.T) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "signer"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	builder := pkcs7.NewBuilder(key, []*x509.Certificate{cert}, crypto.SHA256)
+	require.NoError(t, builder.SetContentData([]byte("hello")))
+	psd, err := builder.Sign()
+	require.NoError(t, err)
+	ts, err := TimestampAndMarshal(context.Background(), psd, fakeTimestamper{t}, false)
+	require.NoError(t, err)
+	// detach the content, like most CMS signatures
+	psd, err = pkcs7.Unmarshal(ts.Raw)
+	require.NoError(t, err)
+	_, err = psd.Detach()
+	require.NoError(t, err)
+	blob, err := psd.Marshal()
+	require.NoError(t, err)
+	return blob
+}
+
+func TestAddTimestamp(t *testing.T) {
+	ctx := context.Background()
+	blob := signedData(t)
+	ts, err := AddTimestamp(ctx, blob, fakeTimestamper{t}, false)
+	require.NoError(t, err)
+	require.Len(t, ts.Timestamps, 2)
+	assert.Same(t, ts.Timestamps[0], ts.CounterSignature)
+
+	// timestamps survive a round trip, in order
+	psd, err := pkcs7.Unmarshal(ts.Raw)
+	require.NoError(t, err)
+	sig, err := psd.Content.Verify(nil, true)
+	require.NoError(t, err)
+	all, err := VerifyAllTimestamps(sig)
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+	assert.Equal(t, ts.Timestamps[0].SigningTime, all[0].SigningTime)
+	assert.Equal(t, ts.Timestamps[1].Certificate.Raw, all[1].Certificate.Raw)
+
+	// the cap on timestamps applies
+	limited := LimitedTimestamper{Timestamper: fakeTimestamper{t}, Max: 2}
+	_, err = AddTimestamp(ctx, ts.Raw, limited, false)
+	assert.ErrorAs(t, err, &TooManyTimestampsError{})
+
+	_, err = AddTimestamp(ctx, blob, nil, false)
+	assert.Error(t, err)
+}
+
+func TestLaterTimestamp(t *testing.T) {
+	ts, err := AddTimestamp(context.Background(), signedData(t), fakeTimestamper{t}, false)
+	require.NoError(t, err)
+	// only trust the TSA of the second timestamp
+	roots := x509.NewCertPool()
+	roots.AddCert(ts.Timestamps[1].Certificate)
+	roots.AddCert(ts.Certificate)
+	require.NoError(t, ts.VerifyChain(roots, nil, x509.ExtKeyUsageAny))
+	// and neither
+	roots = x509.NewCertPool()
+	roots.AddCert(ts.Certificate)
+	assert.ErrorContains(t, ts.VerifyChain(roots, nil, x509.ExtKeyUsageAny), "validating timestamp")
+}
@@ -63,6 +63,10 @@ var (
 	OidSpcTimeStampToken = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 3, 3, 1}
 )
 
+func init() {
+	pkcs7.RegisterAttribute(OidAttributeTimeStampToken, OidAttributeCounterSign, OidSpcTimeStampToken)
+}
+
 type TimeStampReq struct {
 	Version        int
 	MessageImprint MessageImprint
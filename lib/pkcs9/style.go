@@ -0,0 +1,117 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package pkcs9
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/mind-security/relic/v8/lib/pkcs7"
+)
+
+// TimestampStyle selects which kind of timestamp to request for formats that
+// support more than one. Formats that only support RFC 3161 ignore it.
+type TimestampStyle string
+
+const (
+	// StyleRFC3161 requests a RFC 3161 timestamp token (default)
+	StyleRFC3161 TimestampStyle = "rfc3161"
+	// StyleMicrosoft requests a legacy Authenticode timestamp, attached as a
+	// counter-signature
+	StyleMicrosoft TimestampStyle = "microsoft"
+	// StyleFallback tries RFC 3161 first and falls back to a legacy
+	// Authenticode timestamp if that fails
+	StyleFallback TimestampStyle = "fallback"
+)
+
+func ParseTimestampStyle(s string) (TimestampStyle, error) {
+	switch style := TimestampStyle(s); style {
+	case "":
+		return StyleRFC3161, nil
+	case StyleRFC3161, StyleMicrosoft, StyleFallback:
+		return style, nil
+	default:
+		return "", fmt.Errorf("unknown timestamp style %q", s)
+	}
+}
+
+// StyledTimestamper attaches a timestamp style preference to a Timestamper
+type StyledTimestamper struct {
+	Timestamper
+	Style TimestampStyle
+}
+
+func (t StyledTimestamper) Unwrap() Timestamper {
+	return t.Timestamper
+}
+
+// StyleOf returns the style preference of a timestamper, if it has one. Any
+// middleware wrapped around the StyledTimestamper is looked through.
+func StyleOf(t Timestamper) TimestampStyle {
+	for {
+		if st, ok := t.(StyledTimestamper); ok {
+			if st.Style != "" {
+				return st.Style
+			}
+			return StyleRFC3161
+		}
+		w, ok := t.(Wrapper)
+		if !ok {
+			return StyleRFC3161
+		}
+		t = w.Unwrap()
+	}
+}
+
+// timestampStyled requests a timestamp in the given style, returning true if
+// the result is a legacy token
+func timestampStyled(ctx context.Context, t Timestamper, style TimestampStyle, req *Request) (*pkcs7.ContentInfoSignedData, bool, error) {
+	switch style {
+	case StyleMicrosoft:
+		req.Legacy = true
+		token, err := t.Timestamp(ctx, req)
+		return token, true, err
+	case StyleFallback:
+		token, err := t.Timestamp(ctx, req)
+		if err == nil || ctx.Err() != nil {
+			return token, false, err
+		}
+		log.Printf("warning: RFC 3161 timestamp failed, falling back to legacy timestamp: %s", err)
+		req.Legacy = true
+		token, err = t.Timestamp(ctx, req)
+		return token, true, err
+	default:
+		token, err := t.Timestamp(ctx, req)
+		return token, false, err
+	}
+}
+
+// AddLegacyStamp attaches a legacy Microsoft timestamp to a signature as a
+// counter-signature, merging the timestamp's certificates into the outer
+// signature.
+func AddLegacyStamp(psd *pkcs7.SignedData, token *pkcs7.ContentInfoSignedData) error {
+	if len(token.Content.SignerInfos) != 1 {
+		return errors.New("legacy timestamp should have exactly one signer")
+	}
+	if err := psd.SignerInfos[0].UnauthenticatedAttributes.Add(OidAttributeCounterSign, token.Content.SignerInfos[0]); err != nil {
+		return err
+	}
+	psd.Certificates = append(psd.Certificates, token.Content.Certificates...)
+	return nil
+}
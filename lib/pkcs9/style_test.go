@@ -0,0 +1,136 @@
+package pkcs9
+
+import (
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mind-security/relic/v8/lib/pkcs7"
+)
+
+// styleTimestamper records which kinds of timestamp were requested, and
+// optionally fails RFC 3161 requests
+type styleTimestamper struct {
+	failRFC bool
+	cancel  context.CancelFunc
+	token   *pkcs7.ContentInfoSignedData
+	legacy  []bool
+}
+
+func (s *styleTimestamper) Timestamp(ctx context.Context, req *Request) (*pkcs7.ContentInfoSignedData, error) {
+	s.legacy = append(s.legacy, req.Legacy)
+	if !req.Legacy && s.failRFC {
+		if s.cancel != nil {
+			s.cancel()
+		}
+		return nil, errors.New("RFC 3161 server is down")
+	}
+	return s.token, nil
+}
+
+// wrappingTimestamper is middleware that doesn't know about styles or caps
+type wrappingTimestamper struct {
+	Timestamper
+}
+
+func (w wrappingTimestamper) Unwrap() Timestamper {
+	return w.Timestamper
+}
+
+func TestParseTimestampStyle(t *testing.T) {
+	for s, expected := range map[string]TimestampStyle{
+		"":          StyleRFC3161,
+		"rfc3161":   StyleRFC3161,
+		"microsoft": StyleMicrosoft,
+		"fallback":  StyleFallback,
+	} {
+		style, err := ParseTimestampStyle(s)
+		require.NoError(t, err, s)
+		assert.Equal(t, expected, style, s)
+	}
+	_, err := ParseTimestampStyle("Microsoft")
+	assert.ErrorContains(t, err, "unknown timestamp style")
+}
+
+func TestStyleOf(t *testing.T) {
+	inner := new(styleTimestamper)
+	assert.Equal(t, StyleRFC3161, StyleOf(inner))
+	assert.Equal(t, StyleRFC3161, StyleOf(StyledTimestamper{Timestamper: inner}))
+	styled := StyledTimestamper{Timestamper: LimitedTimestamper{Timestamper: inner, Max: 3}, Style: StyleMicrosoft}
+	assert.Equal(t, StyleMicrosoft, StyleOf(styled))
+	// middleware on the outside is looked through
+	wrapped := wrappingTimestamper{LimitedTimestamper{Timestamper: wrappingTimestamper{styled}, Max: 2}}
+	assert.Equal(t, StyleMicrosoft, StyleOf(wrapped))
+	assert.Equal(t, 2, MaxTimestampsOf(wrapped))
+	assert.Equal(t, 3, MaxTimestampsOf(wrappingTimestamper{styled}))
+	assert.Equal(t, 0, MaxTimestampsOf(wrappingTimestamper{inner}))
+}
+
+func TestTimestampStyled(t *testing.T) {
+	ctx := context.Background()
+	token := new(pkcs7.ContentInfoSignedData)
+	request := func() *Request { return &Request{EncryptedDigest: []byte("sig"), Hash: crypto.SHA256} }
+
+	ts := &styleTimestamper{token: token}
+	got, legacy, err := timestampStyled(ctx, ts, StyleRFC3161, request())
+	require.NoError(t, err)
+	assert.Same(t, token, got)
+	assert.False(t, legacy)
+	assert.Equal(t, []bool{false}, ts.legacy)
+
+	ts = &styleTimestamper{token: token}
+	_, legacy, err = timestampStyled(ctx, ts, StyleMicrosoft, request())
+	require.NoError(t, err)
+	assert.True(t, legacy)
+	assert.Equal(t, []bool{true}, ts.legacy)
+
+	// fallback only asks for a legacy timestamp if RFC 3161 fails
+	ts = &styleTimestamper{token: token}
+	_, legacy, err = timestampStyled(ctx, ts, StyleFallback, request())
+	require.NoError(t, err)
+	assert.False(t, legacy)
+	assert.Equal(t, []bool{false}, ts.legacy)
+
+	ts = &styleTimestamper{token: token, failRFC: true}
+	got, legacy, err = timestampStyled(ctx, ts, StyleFallback, request())
+	require.NoError(t, err)
+	assert.Same(t, token, got)
+	assert.True(t, legacy)
+	assert.Equal(t, []bool{false, true}, ts.legacy)
+
+	// but not once the request has been cancelled
+	cctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	ts = &styleTimestamper{token: token, failRFC: true, cancel: cancel}
+	_, legacy, err = timestampStyled(cctx, ts, StyleFallback, request())
+	assert.ErrorContains(t, err, "server is down")
+	assert.False(t, legacy)
+	assert.Equal(t, []bool{false}, ts.legacy)
+}
+
+func TestAddLegacyStamp(t *testing.T) {
+	psd, err := pkcs7.Unmarshal(signedData(t))
+	require.NoError(t, err)
+	certs := len(psd.Content.Certificates)
+	digest := sha256.Sum256(psd.Content.SignerInfos[0].EncryptedDigest)
+	req, err := NewTimeStampReq(crypto.SHA256, digest[:], false)
+	require.NoError(t, err)
+	token := fakeToken(t, req, nil)
+
+	require.NoError(t, AddLegacyStamp(&psd.Content, token))
+	assert.Len(t, psd.Content.Certificates, certs+len(token.Content.Certificates))
+	var counterSig pkcs7.SignerInfo
+	require.NoError(t, psd.Content.SignerInfos[0].UnauthenticatedAttributes.GetOne(OidAttributeCounterSign, &counterSig))
+	assert.Equal(t, token.Content.SignerInfos[0].EncryptedDigest, counterSig.EncryptedDigest)
+	parsed, err := psd.Content.Certificates.Parse()
+	require.NoError(t, err)
+	assert.Equal(t, "TSA", parsed[certs].Subject.CommonName)
+
+	token.Content.SignerInfos = append(token.Content.SignerInfos, token.Content.SignerInfos[0])
+	assert.ErrorContains(t, AddLegacyStamp(&psd.Content, token), "exactly one signer")
+}
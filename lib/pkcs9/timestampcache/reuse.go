@@ -0,0 +1,123 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package timestampcache
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/mind-security/relic/v8/lib/pkcs7"
+	"github.com/mind-security/relic/v8/lib/pkcs9"
+)
+
+// maxReuseEntries bounds the memory used by a reuse cache
+const maxReuseEntries = 1024
+
+type reuseEntry struct {
+	done    chan struct{}
+	token   *pkcs7.ContentInfoSignedData
+	err     error
+	expires time.Time
+}
+
+type reuseCache struct {
+	Timestamper pkcs9.Timestamper
+	window      time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*reuseEntry
+	now     func() time.Time
+}
+
+// NewReuse returns a Timestamper that remembers each timestamp it obtains for
+// the given window. An identical request made within that window, such as a
+// retry after a later step failed, gets the same token back without
+// contacting the server again. Concurrent identical requests share one call.
+func NewReuse(t pkcs9.Timestamper, window time.Duration) pkcs9.Timestamper {
+	return &reuseCache{
+		Timestamper: t,
+		window:      window,
+		entries:     make(map[string]*reuseEntry),
+		now:         time.Now,
+	}
+}
+
+func (c *reuseCache) Timestamp(ctx context.Context, req *pkcs9.Request) (*pkcs7.ContentInfoSignedData, error) {
+	key := cacheKey(req)
+	c.mu.Lock()
+	now := c.now()
+	e := c.entries[key]
+	if e != nil && e.expires.IsZero() {
+		// another request for the same thing is in progress
+		c.mu.Unlock()
+		select {
+		case <-e.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if e.err == nil {
+			metricHits.WithLabelValues("hit").Inc()
+			return e.token, nil
+		}
+		// that one failed, so try again
+		return c.Timestamper.Timestamp(ctx, req)
+	} else if e != nil && now.Before(e.expires) {
+		c.mu.Unlock()
+		metricHits.WithLabelValues("hit").Inc()
+		return e.token, nil
+	}
+	c.prune(now)
+	e = &reuseEntry{done: make(chan struct{})}
+	c.entries[key] = e
+	c.mu.Unlock()
+
+	e.token, e.err = c.Timestamper.Timestamp(ctx, req)
+	c.mu.Lock()
+	if e.err != nil {
+		delete(c.entries, key)
+	} else {
+		e.expires = c.now().Add(c.window)
+	}
+	c.mu.Unlock()
+	close(e.done)
+	return e.token, e.err
+}
+
+// prune removes expired entries, and if the cache is still full, the ones
+// closest to expiring
+func (c *reuseCache) prune(now time.Time) {
+	for key, e := range c.entries {
+		if !e.expires.IsZero() && !now.Before(e.expires) {
+			delete(c.entries, key)
+		}
+	}
+	for len(c.entries) >= maxReuseEntries {
+		var oldest string
+		var oldestTime time.Time
+		for key, e := range c.entries {
+			if !e.expires.IsZero() && (oldest == "" || e.expires.Before(oldestTime)) {
+				oldest, oldestTime = key, e.expires
+			}
+		}
+		if oldest == "" {
+			// everything is in progress
+			return
+		}
+		delete(c.entries, oldest)
+	}
+}
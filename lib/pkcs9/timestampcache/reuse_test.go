@@ -0,0 +1,92 @@
+package timestampcache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mind-security/relic/v8/lib/pkcs7"
+	"github.com/mind-security/relic/v8/lib/pkcs9"
+)
+
+type countingTimestamper struct {
+	mu    sync.Mutex
+	calls int
+	fail  bool
+}
+
+func (t *countingTimestamper) Timestamp(ctx context.Context, req *pkcs9.Request) (*pkcs7.ContentInfoSignedData, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.calls++
+	if t.fail {
+		return nil, errors.New("TSA unavailable")
+	}
+	return &pkcs7.ContentInfoSignedData{Content: pkcs7.SignedData{Version: t.calls}}, nil
+}
+
+func TestReuse(t *testing.T) {
+	inner := new(countingTimestamper)
+	tc := NewReuse(inner, time.Minute).(*reuseCache)
+	now := time.Now()
+	tc.now = func() time.Time { return now }
+	ctx := context.Background()
+	req := &pkcs9.Request{EncryptedDigest: []byte("sig1"), Hash: 5}
+
+	// a failure isn't remembered
+	inner.fail = true
+	_, err := tc.Timestamp(ctx, req)
+	require.Error(t, err)
+	inner.fail = false
+	first, err := tc.Timestamp(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, 2, inner.calls)
+
+	// a retry within the window gets the same token
+	now = now.Add(30 * time.Second)
+	again, err := tc.Timestamp(ctx, req)
+	require.NoError(t, err)
+	assert.Same(t, first, again)
+	assert.Equal(t, 2, inner.calls)
+
+	// a different signature or digest is a different request
+	_, err = tc.Timestamp(ctx, &pkcs9.Request{EncryptedDigest: []byte("sig2"), Hash: 5})
+	require.NoError(t, err)
+	_, err = tc.Timestamp(ctx, &pkcs9.Request{EncryptedDigest: []byte("sig1"), Hash: 6})
+	require.NoError(t, err)
+	assert.Equal(t, 4, inner.calls)
+
+	// after the window the server is asked again
+	now = now.Add(time.Minute)
+	later, err := tc.Timestamp(ctx, req)
+	require.NoError(t, err)
+	assert.NotSame(t, first, later)
+	assert.Equal(t, 5, inner.calls)
+}
+
+func TestReuseConcurrent(t *testing.T) {
+	inner := new(countingTimestamper)
+	tc := NewReuse(inner, time.Minute)
+	req := &pkcs9.Request{EncryptedDigest: []byte("sig1"), Hash: 5}
+	var wg sync.WaitGroup
+	tokens := make([]*pkcs7.ContentInfoSignedData, 8)
+	for i := range tokens {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			token, err := tc.Timestamp(context.Background(), req)
+			assert.NoError(t, err)
+			tokens[i] = token
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, 1, inner.calls)
+	for _, token := range tokens {
+		assert.Same(t, tokens[0], token)
+	}
+}
@@ -15,6 +15,7 @@ package tsclient
 
 import (
 	"context"
+	"crypto"
 	"crypto/tls"
 	"errors"
 	"fmt"
@@ -34,9 +35,15 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+// defaultReuseWindow is how long a timestamp is reused for an identical
+// request if the configuration doesn't say
+const defaultReuseWindow = 60 * time.Second
+
 type tsClient struct {
-	conf   *config.TimestampConfig
-	client *http.Client
+	conf    *config.TimestampConfig
+	client  *http.Client
+	hash    crypto.Hash
+	certReq bool
 }
 
 var (
@@ -64,15 +71,29 @@ func New(conf *config.TimestampConfig) (t pkcs9.Timestamper, err error) {
 	if err := x509tools.LoadCertPool(conf.CaCert, tlsconf); err != nil {
 		return nil, err
 	}
+	proxy, err := config.ProxyFunc(conf.Proxy)
+	if err != nil {
+		return nil, err
+	}
 	client := &http.Client{
-		Timeout: time.Second * time.Duration(conf.Timeout),
 		Transport: &http.Transport{
+			Proxy:           proxy,
 			TLSClientConfig: tlsconf,
 		},
 	}
 	client.Transport = promhttp.InstrumentRoundTripperCounter(metricCount, client.Transport)
 	client.Transport = promhttp.InstrumentRoundTripperDuration(metricDuration, client.Transport)
-	t = tsClient{conf, client}
+	tc := tsClient{conf: conf, client: client, certReq: true}
+	if conf.HashAlgorithm != "" {
+		tc.hash = x509tools.HashByName(conf.HashAlgorithm)
+		if tc.hash == 0 {
+			return nil, fmt.Errorf("timestamp.hashalgorithm: unsupported digest %q", conf.HashAlgorithm)
+		}
+	}
+	if conf.RequestCertReq != nil {
+		tc.certReq = *conf.RequestCertReq
+	}
+	t = tc
 	if conf.RateLimit != 0 {
 		t = ratelimit.New(t, conf.RateLimit, conf.RateBurst)
 	}
@@ -82,6 +103,13 @@ func New(conf *config.TimestampConfig) (t pkcs9.Timestamper, err error) {
 			return nil, err
 		}
 	}
+	if conf.ReuseSeconds >= 0 {
+		window := defaultReuseWindow
+		if conf.ReuseSeconds > 0 {
+			window = time.Second * time.Duration(conf.ReuseSeconds)
+		}
+		t = timestampcache.NewReuse(t, window)
+	}
 	return
 }
 
@@ -100,6 +128,12 @@ func (c tsClient) Timestamp(ctx context.Context, req *pkcs9.Request) (*pkcs7.Con
 	}
 	imprint := req.EncryptedDigest
 	if !req.Legacy {
+		if c.hash != 0 {
+			// configured digest overrides the one suggested by the signer
+			req2 := *req
+			req2.Hash = c.hash
+			req = &req2
+		}
 		d := req.Hash.New()
 		d.Write(imprint)
 		imprint = d.Sum(nil)
@@ -126,7 +160,10 @@ func (c tsClient) do(ctx context.Context, url string, req *pkcs9.Request, imprin
 	var httpReq *http.Request
 	var err error
 	if !req.Legacy {
-		msg, httpReq, err = pkcs9.NewRequest(url, req.Hash, imprint)
+		msg, err = pkcs9.NewTimeStampReq(req.Hash, imprint, c.certReq)
+		if err == nil {
+			httpReq, err = msg.NewHTTPRequest(url)
+		}
 	} else {
 		httpReq, err = pkcs9.NewLegacyRequest(url, imprint)
 	}
@@ -134,6 +171,12 @@ func (c tsClient) do(ctx context.Context, url string, req *pkcs9.Request, imprin
 		return nil, err
 	}
 	httpReq.Header.Set("User-Agent", config.UserAgent)
+	if c.conf.Timeout != 0 {
+		// each server gets the full timeout
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Second*time.Duration(c.conf.Timeout))
+		defer cancel()
+	}
 	resp, err := c.client.Do(httpReq.WithContext(ctx))
 	if err != nil {
 		return nil, err
@@ -148,5 +191,24 @@ func (c tsClient) do(ctx context.Context, url string, req *pkcs9.Request, imprin
 	if req.Legacy {
 		return pkcs9.ParseLegacyResponse(body)
 	}
-	return msg.ParseResponse(body)
+	token, err := msg.ParseResponse(body)
+	if err != nil {
+		return nil, err
+	}
+	checkTokenDigest(url, token, req.Hash)
+	return token, nil
+}
+
+// checkTokenDigest warns if the TSA signed the token with a weaker digest than
+// the one that was requested
+func checkTokenDigest(url string, token *pkcs7.ContentInfoSignedData, want crypto.Hash) {
+	for _, si := range token.Content.SignerInfos {
+		got, ok := x509tools.PkixDigestToHash(si.DigestAlgorithm)
+		if !ok {
+			log.Printf("warning: timestamp from %s is signed with unrecognized digest %s", url, si.DigestAlgorithm.Algorithm)
+		} else if got.Size() < want.Size() {
+			log.Printf("warning: requested a %s timestamp from %s but the token is signed with %s",
+				x509tools.HashNames[want], url, x509tools.HashNames[got])
+		}
+	}
 }
@@ -0,0 +1,393 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package revocation checks X.509 certificates against OCSP responders and
+// CRLs, caching the results in memory and optionally on disk.
+package revocation
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/mind-security/relic/v8/lib/atomicfile"
+)
+
+const maxResponseSize = 16 * 1024 * 1024
+
+// ErrNoStatus is returned when no OCSP response or CRL could be obtained
+var ErrNoStatus = errors.New("revocation status unavailable")
+
+// RevokedError is returned for a certificate that has been revoked
+type RevokedError struct {
+	Cert      *x509.Certificate
+	RevokedAt time.Time
+}
+
+func (e RevokedError) Error() string {
+	return fmt.Sprintf("certificate %X was revoked at %s", e.Cert.SerialNumber, e.RevokedAt)
+}
+
+// Status is the revocation status of a single certificate
+type Status int
+
+const (
+	// StatusUnknown means no OCSP response or CRL could be obtained, for
+	// example due to a network failure
+	StatusUnknown Status = iota
+	StatusGood
+	StatusRevoked
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusGood:
+		return "good"
+	case StatusRevoked:
+		return "revoked"
+	default:
+		return "unknown"
+	}
+}
+
+// Result describes the revocation status of one certificate and where it came
+// from
+type Result struct {
+	Cert      *x509.Certificate
+	Status    Status
+	RevokedAt time.Time
+	// Source is the OCSP responder or CRL URL that gave the status, or
+	// "stapled OCSP" or "stapled CRL" if it came from the signature
+	Source string
+	// Cached is true if the response was reused from the cache
+	Cached bool
+	// Err explains why the status is unknown
+	Err error
+}
+
+// Error returns nil if the certificate is good, a RevokedError if it was
+// revoked, or an error wrapping ErrNoStatus if the status is unknown.
+func (r Result) Error() error {
+	switch r.Status {
+	case StatusGood:
+		return nil
+	case StatusRevoked:
+		return RevokedError{Cert: r.Cert, RevokedAt: r.RevokedAt}
+	}
+	if r.Err != nil {
+		return fmt.Errorf("%w for %q: %s", ErrNoStatus, r.Cert.Subject, r.Err)
+	}
+	return fmt.Errorf("%w for %q: no OCSP or CRL locations", ErrNoStatus, r.Cert.Subject)
+}
+
+// Stapled holds revocation data embedded in a signature. It is used in
+// preference to contacting responders as long as it is current.
+type Stapled struct {
+	OCSPResponses [][]byte
+	CRLs          [][]byte
+}
+
+// Checker determines the revocation status of certificates. OCSP is tried
+// first, then CRLs. Responses are reused until their nextUpdate time.
+type Checker struct {
+	// CacheDir persists OCSP responses and CRLs so they survive restarts. If
+	// empty, they are only cached in memory.
+	CacheDir string
+	// Offline prevents contacting responders, so only cached responses are
+	// used
+	Offline bool
+	// Client is used to fetch responses. If nil, http.DefaultClient is used.
+	Client *http.Client
+	// Now returns the current time. If nil, time.Now is used.
+	Now func() time.Time
+
+	mu  sync.Mutex
+	mem map[string][]byte
+}
+
+// CheckChain checks each certificate in chain against its issuer, which is
+// the next certificate in the chain. The root is not checked.
+func (c *Checker) CheckChain(ctx context.Context, chain []*x509.Certificate) error {
+	for _, result := range c.ChainStatus(ctx, chain, nil) {
+		if err := result.Error(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ChainStatus returns the status of each certificate in chain except the root,
+// stopping at the first one that is not good.
+func (c *Checker) ChainStatus(ctx context.Context, chain []*x509.Certificate, stapled *Stapled) []Result {
+	var results []Result
+	for i := 0; i+1 < len(chain); i++ {
+		result := c.Status(ctx, chain[i], chain[i+1], stapled)
+		results = append(results, result)
+		if result.Status != StatusGood {
+			break
+		}
+	}
+	return results
+}
+
+// Check returns nil if cert has not been revoked by issuer, a RevokedError if
+// it has, or an error wrapping ErrNoStatus if neither OCSP nor a CRL was
+// available.
+func (c *Checker) Check(ctx context.Context, cert, issuer *x509.Certificate) error {
+	return c.Status(ctx, cert, issuer, nil).Error()
+}
+
+// Status determines whether cert has been revoked by issuer, preferring
+// current stapled data, then OCSP, then CRLs.
+func (c *Checker) Status(ctx context.Context, cert, issuer *x509.Certificate, stapled *Stapled) Result {
+	if result, ok := c.stapledStatus(cert, issuer, stapled); ok {
+		return result
+	}
+	var lastErr error
+	if len(cert.OCSPServer) != 0 {
+		resp, cached, err := c.ocspStatus(ctx, cert, issuer)
+		if err == nil {
+			if result, ok := ocspResult(cert, resp); ok {
+				result.Source = cert.OCSPServer[0]
+				result.Cached = cached
+				return result
+			}
+			err = errors.New("OCSP responder returned unknown status")
+		}
+		lastErr = err
+	}
+	for _, url := range cert.CRLDistributionPoints {
+		if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+			continue
+		}
+		crl, cached, err := c.crl(ctx, url, issuer)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		result := crlResult(cert, crl)
+		result.Source = url
+		result.Cached = cached
+		return result
+	}
+	return Result{Cert: cert, Err: lastErr}
+}
+
+// stapledStatus looks for a stapled OCSP response or CRL covering cert. A
+// revocation is always honored, but a good status is only used if the
+// response is still current.
+func (c *Checker) stapledStatus(cert, issuer *x509.Certificate, stapled *Stapled) (Result, bool) {
+	if stapled == nil {
+		return Result{}, false
+	}
+	for _, blob := range stapled.OCSPResponses {
+		resp, err := ocsp.ParseResponseForCert(blob, cert, issuer)
+		if err != nil {
+			continue
+		}
+		result, ok := ocspResult(cert, resp)
+		if ok && (result.Status == StatusRevoked || c.fresh(resp.NextUpdate)) {
+			result.Source = "stapled OCSP"
+			return result, true
+		}
+	}
+	for _, blob := range stapled.CRLs {
+		crl, err := parseCRL(blob, issuer)
+		if err != nil {
+			continue
+		}
+		result := crlResult(cert, crl)
+		if result.Status == StatusRevoked || c.fresh(crl.NextUpdate) {
+			result.Source = "stapled CRL"
+			return result, true
+		}
+	}
+	return Result{}, false
+}
+
+func ocspResult(cert *x509.Certificate, resp *ocsp.Response) (Result, bool) {
+	switch resp.Status {
+	case ocsp.Good:
+		return Result{Cert: cert, Status: StatusGood}, true
+	case ocsp.Revoked:
+		return Result{Cert: cert, Status: StatusRevoked, RevokedAt: resp.RevokedAt}, true
+	}
+	return Result{}, false
+}
+
+func crlResult(cert *x509.Certificate, crl *x509.RevocationList) Result {
+	for _, entry := range crl.RevokedCertificateEntries {
+		if entry.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+			return Result{Cert: cert, Status: StatusRevoked, RevokedAt: entry.RevocationTime}
+		}
+	}
+	return Result{Cert: cert, Status: StatusGood}
+}
+
+func (c *Checker) ocspStatus(ctx context.Context, cert, issuer *x509.Certificate) (*ocsp.Response, bool, error) {
+	key := cacheKey("ocsp", issuer.RawSubjectPublicKeyInfo, cert.SerialNumber.Bytes())
+	if blob := c.load(key); blob != nil {
+		resp, err := ocsp.ParseResponseForCert(blob, cert, issuer)
+		if err == nil && c.fresh(resp.NextUpdate) {
+			return resp, true, nil
+		}
+	}
+	if c.Offline {
+		return nil, false, errors.New("offline and no current OCSP response is cached")
+	}
+	req, err := ocsp.CreateRequest(cert, issuer, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	blob, err := c.fetch(ctx, http.MethodPost, cert.OCSPServer[0], req)
+	if err != nil {
+		return nil, false, fmt.Errorf("OCSP: %w", err)
+	}
+	resp, err := ocsp.ParseResponseForCert(blob, cert, issuer)
+	if err != nil {
+		return nil, false, fmt.Errorf("OCSP: %w", err)
+	}
+	if c.fresh(resp.NextUpdate) {
+		c.store(key, blob)
+	}
+	return resp, false, nil
+}
+
+func (c *Checker) crl(ctx context.Context, url string, issuer *x509.Certificate) (*x509.RevocationList, bool, error) {
+	key := cacheKey("crl", []byte(url))
+	if blob := c.load(key); blob != nil {
+		crl, err := parseCRL(blob, issuer)
+		if err == nil && c.fresh(crl.NextUpdate) {
+			return crl, true, nil
+		}
+	}
+	if c.Offline {
+		return nil, false, errors.New("offline and no current CRL is cached")
+	}
+	blob, err := c.fetch(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("CRL: %w", err)
+	}
+	crl, err := parseCRL(blob, issuer)
+	if err != nil {
+		return nil, false, fmt.Errorf("CRL %s: %w", url, err)
+	}
+	if c.fresh(crl.NextUpdate) {
+		c.store(key, blob)
+	}
+	return crl, false, nil
+}
+
+func parseCRL(blob []byte, issuer *x509.Certificate) (*x509.RevocationList, error) {
+	crl, err := x509.ParseRevocationList(blob)
+	if err != nil {
+		return nil, err
+	}
+	if err := crl.CheckSignatureFrom(issuer); err != nil {
+		return nil, err
+	}
+	return crl, nil
+}
+
+func (c *Checker) fetch(ctx context.Context, method, url string, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/ocsp-request")
+	}
+	cli := c.Client
+	if cli == nil {
+		cli = http.DefaultClient
+	}
+	resp, err := cli.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+}
+
+// fresh returns true if a response with the given nextUpdate can still be
+// used. Responses without a nextUpdate are never reused.
+func (c *Checker) fresh(nextUpdate time.Time) bool {
+	now := time.Now()
+	if c.Now != nil {
+		now = c.Now()
+	}
+	return !nextUpdate.IsZero() && now.Before(nextUpdate)
+}
+
+func (c *Checker) load(key string) []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if blob := c.mem[key]; blob != nil {
+		return blob
+	}
+	if c.CacheDir == "" {
+		return nil
+	}
+	blob, err := os.ReadFile(filepath.Join(c.CacheDir, key))
+	if err != nil {
+		return nil
+	}
+	if c.mem == nil {
+		c.mem = make(map[string][]byte)
+	}
+	c.mem[key] = blob
+	return blob
+}
+
+func (c *Checker) store(key string, blob []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.mem == nil {
+		c.mem = make(map[string][]byte)
+	}
+	c.mem[key] = blob
+	if c.CacheDir == "" {
+		return
+	}
+	// the cache is only an optimization so failing to persist it isn't fatal
+	if err := os.MkdirAll(c.CacheDir, 0755); err == nil {
+		_ = atomicfile.WriteFile(filepath.Join(c.CacheDir, key), blob)
+	}
+}
+
+func cacheKey(kind string, parts ...[]byte) string {
+	d := sha256.New()
+	for _, p := range parts {
+		d.Write(p)
+	}
+	return kind + "-" + hex.EncodeToString(d.Sum(nil))
+}
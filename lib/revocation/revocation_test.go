@@ -0,0 +1,217 @@
+package revocation
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ocsp"
+)
+
+type testPKI struct {
+	ca, leaf *x509.Certificate
+	caKey    crypto.Signer
+	status   int
+	next     time.Time
+	hits     atomic.Int32
+	srv      *httptest.Server
+}
+
+func newTestPKI(t *testing.T) *testPKI {
+	p := &testPKI{status: ocsp.Good, next: time.Now().Add(time.Hour)}
+	p.srv = httptest.NewServer(http.HandlerFunc(p.serveOCSP))
+	t.Cleanup(p.srv.Close)
+	var err error
+	p.caKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	p.ca = createCert(t, caTemplate, caTemplate, p.caKey.Public(), p.caKey)
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	p.leaf = createCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(42),
+		Subject:      pkix.Name{CommonName: "signer"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		OCSPServer:   []string{p.srv.URL},
+	}, p.ca, leafKey.Public(), p.caKey)
+	return p
+}
+
+func createCert(t *testing.T, template, parent *x509.Certificate, pub crypto.PublicKey, key crypto.Signer) *x509.Certificate {
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, pub, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert
+}
+
+func (p *testPKI) serveOCSP(rw http.ResponseWriter, req *http.Request) {
+	p.hits.Add(1)
+	blob, _ := io.ReadAll(req.Body)
+	ocspReq, err := ocsp.ParseRequest(blob)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	tmpl := ocsp.Response{
+		Status:       p.status,
+		SerialNumber: ocspReq.SerialNumber,
+		ThisUpdate:   time.Now().Add(-time.Minute),
+		NextUpdate:   p.next,
+	}
+	if p.status == ocsp.Revoked {
+		tmpl.RevokedAt = time.Now().Add(-time.Minute).Truncate(time.Second)
+	}
+	resp, err := ocsp.CreateResponse(p.ca, p.ca, tmpl, p.caKey)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_, _ = rw.Write(resp)
+}
+
+func TestPersistedOCSP(t *testing.T) {
+	p := newTestPKI(t)
+	dir := t.TempDir()
+	ctx := context.Background()
+	c := &Checker{CacheDir: dir}
+	require.NoError(t, c.Check(ctx, p.leaf, p.ca))
+	require.NoError(t, c.Check(ctx, p.leaf, p.ca))
+	assert.Equal(t, int32(1), p.hits.Load())
+
+	// a new checker, as after a restart, reuses the persisted response even
+	// when the responder can't be reached
+	p.srv.Close()
+	c = &Checker{CacheDir: dir}
+	require.NoError(t, c.CheckChain(ctx, []*x509.Certificate{p.leaf, p.ca}))
+	c = &Checker{CacheDir: dir, Offline: true}
+	require.NoError(t, c.Check(ctx, p.leaf, p.ca))
+	assert.Equal(t, int32(1), p.hits.Load())
+
+	// once nextUpdate has passed the persisted response is no longer used
+	c = &Checker{CacheDir: dir, Offline: true, Now: func() time.Time { return p.next.Add(time.Second) }}
+	assert.ErrorIs(t, c.Check(ctx, p.leaf, p.ca), ErrNoStatus)
+}
+
+func TestRevokedOCSP(t *testing.T) {
+	p := newTestPKI(t)
+	p.status = ocsp.Revoked
+	err := (&Checker{}).Check(context.Background(), p.leaf, p.ca)
+	var revoked RevokedError
+	require.True(t, errors.As(err, &revoked), "%v", err)
+	assert.Equal(t, p.leaf, revoked.Cert)
+}
+
+func TestNoNextUpdate(t *testing.T) {
+	p := newTestPKI(t)
+	p.next = time.Time{}
+	dir := t.TempDir()
+	ctx := context.Background()
+	c := &Checker{CacheDir: dir}
+	require.NoError(t, c.Check(ctx, p.leaf, p.ca))
+	require.NoError(t, c.Check(ctx, p.leaf, p.ca))
+	// responses without a nextUpdate aren't cached
+	assert.Equal(t, int32(2), p.hits.Load())
+	c = &Checker{CacheDir: dir, Offline: true}
+	assert.ErrorIs(t, c.Check(ctx, p.leaf, p.ca), ErrNoStatus)
+}
+
+func TestCRL(t *testing.T) {
+	p := newTestPKI(t)
+	next := time.Now().Add(time.Hour)
+	crl, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: time.Now().Add(-time.Minute),
+		NextUpdate: next,
+		RevokedCertificateEntries: []x509.RevocationListEntry{
+			{SerialNumber: big.NewInt(42), RevocationTime: time.Now().Add(-time.Minute)},
+		},
+	}, p.ca, p.caKey)
+	require.NoError(t, err)
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		hits.Add(1)
+		_, _ = rw.Write(crl)
+	}))
+	defer srv.Close()
+	leaf := *p.leaf
+	leaf.OCSPServer = nil
+	leaf.CRLDistributionPoints = []string{srv.URL}
+
+	dir := t.TempDir()
+	var revoked RevokedError
+	assert.ErrorAs(t, (&Checker{CacheDir: dir}).Check(context.Background(), &leaf, p.ca), &revoked)
+	assert.ErrorAs(t, (&Checker{CacheDir: dir, Offline: true}).Check(context.Background(), &leaf, p.ca), &revoked)
+	assert.Equal(t, int32(1), hits.Load())
+}
+
+func TestStatusSource(t *testing.T) {
+	p := newTestPKI(t)
+	ctx := context.Background()
+	c := &Checker{}
+	result := c.Status(ctx, p.leaf, p.ca, nil)
+	assert.Equal(t, StatusGood, result.Status)
+	assert.Equal(t, p.srv.URL, result.Source)
+	assert.False(t, result.Cached)
+	result = c.Status(ctx, p.leaf, p.ca, nil)
+	assert.True(t, result.Cached)
+
+	// a network failure is unknown, not revoked
+	p.srv.Close()
+	result = (&Checker{}).Status(ctx, p.leaf, p.ca, nil)
+	assert.Equal(t, StatusUnknown, result.Status)
+	assert.Error(t, result.Err)
+	assert.ErrorIs(t, result.Error(), ErrNoStatus)
+}
+
+func TestStapledOCSP(t *testing.T) {
+	p := newTestPKI(t)
+	ctx := context.Background()
+	tmpl := ocsp.Response{
+		Status:       ocsp.Revoked,
+		SerialNumber: p.leaf.SerialNumber,
+		ThisUpdate:   time.Now().Add(-48 * time.Hour),
+		NextUpdate:   time.Now().Add(-24 * time.Hour),
+		RevokedAt:    time.Now().Add(-48 * time.Hour).Truncate(time.Second),
+	}
+	revokedResp, err := ocsp.CreateResponse(p.ca, p.ca, tmpl, p.caKey)
+	require.NoError(t, err)
+	tmpl.Status = ocsp.Good
+	staleResp, err := ocsp.CreateResponse(p.ca, p.ca, tmpl, p.caKey)
+	require.NoError(t, err)
+
+	// a stale good response falls through to the responder, which is offline
+	c := &Checker{Offline: true}
+	result := c.Status(ctx, p.leaf, p.ca, &Stapled{OCSPResponses: [][]byte{staleResp}})
+	assert.Equal(t, StatusUnknown, result.Status)
+	// but a stapled revocation is honored even after nextUpdate
+	result = c.Status(ctx, p.leaf, p.ca, &Stapled{OCSPResponses: [][]byte{staleResp, revokedResp}})
+	assert.Equal(t, StatusRevoked, result.Status)
+	assert.Equal(t, "stapled OCSP", result.Source)
+	var revoked RevokedError
+	assert.ErrorAs(t, result.Error(), &revoked)
+	assert.Equal(t, int32(0), p.hits.Load())
+}
@@ -69,11 +69,11 @@ func verifyBundle(r io.ReaderAt, files zipFiles, sig *AppxSignature, skipDigests
 		return fmt.Errorf("bundle manifest: publisher identity mismatch:\nexpected: %s\nactual: %s", publisher, bundle.Identity.Publisher)
 	}
 	for _, zf := range files {
-		if !strings.HasSuffix(zf.Name, ".appx") {
+		if !isNestedPackage(zf.Name) {
 			continue
 		}
 		if zf.Method != zip.Store {
-			return errors.New("bundle manifest: contains compressed appx")
+			return errors.New("bundle manifest: contains compressed package")
 		}
 		dosname := strings.ReplaceAll(zf.Name, "/", "\\")
 		pkgIndex, ok := packages[dosname]
@@ -122,6 +122,13 @@ func parseBundle(blob []byte) (*bundleManifest, error) {
 	return manifest, nil
 }
 
+// isNestedPackage returns true if a file in a bundle is an .appx or .msix
+// package
+func isNestedPackage(name string) bool {
+	name = strings.ToLower(name)
+	return strings.HasSuffix(name, ".appx") || strings.HasSuffix(name, ".msix")
+}
+
 func (m *bundleManifest) SetPublisher(cert *x509.Certificate) {
 	subj := x509tools.FormatPkixName(cert.RawSubject, x509tools.NameStyleMsOsco)
 	m.Identity.Publisher = subj
@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"crypto/x509"
 	"encoding/xml"
+	"errors"
 	"fmt"
 
 	"github.com/beevik/etree"
@@ -77,6 +78,26 @@ func checkManifest(files zipFiles, sig *AppxSignature) error {
 	return nil
 }
 
+// CheckPublisher returns an error if the Publisher in the package or bundle
+// manifest doesn't match the subject of the signing certificate. Otherwise,
+// signing replaces it with the certificate's subject.
+func (i *AppxDigest) CheckPublisher(leaf *x509.Certificate) error {
+	publisher := x509tools.FormatPkixName(leaf.RawSubject, x509tools.NameStyleMsOsco)
+	var actual string
+	switch {
+	case i.manifest != nil:
+		actual = i.manifest.Identity.Publisher
+	case i.bundle != nil:
+		actual = i.bundle.Identity.Publisher
+	default:
+		return errors.New("manifest not found")
+	}
+	if actual != publisher {
+		return fmt.Errorf("manifest publisher does not match the signing certificate:\nexpected: %s\nactual: %s", publisher, actual)
+	}
+	return nil
+}
+
 func (m *appxPackage) SetPublisher(cert *x509.Certificate) {
 	subj := x509tools.FormatPkixName(cert.RawSubject, x509tools.NameStyleMsOsco)
 	m.Identity.Publisher = subj
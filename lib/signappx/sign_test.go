@@ -0,0 +1,94 @@
+package signappx
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mind-security/relic/v8/lib/certloader"
+	"github.com/mind-security/relic/v8/lib/x509tools"
+	"github.com/mind-security/relic/v8/lib/zipslicer"
+)
+
+const appxPath = "../../functest/packages/App1_1.0.3.0_x64.appx"
+
+func testCert(t *testing.T, org string) *certloader.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "appx signer", Organization: []string{org}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &certloader.Certificate{Leaf: cert, PrivateKey: key}
+}
+
+func digestAppx(t *testing.T) *AppxDigest {
+	f, err := os.Open(appxPath)
+	require.NoError(t, err)
+	defer f.Close()
+	var stream bytes.Buffer
+	require.NoError(t, zipslicer.ZipToTar(f, &stream))
+	digest, err := DigestAppxTar(&stream, crypto.SHA256, false)
+	require.NoError(t, err)
+	return digest
+}
+
+func TestSignAppx(t *testing.T) {
+	cert := testCert(t, "Example")
+	digest := digestAppx(t)
+	// the fixture was built for a different publisher
+	assert.ErrorContains(t, digest.CheckPublisher(cert.Leaf), "publisher does not match")
+	patch, _, _, err := digest.Sign(context.Background(), cert, nil)
+	require.NoError(t, err)
+
+	infile, err := os.Open(appxPath)
+	require.NoError(t, err)
+	defer infile.Close()
+	signed := filepath.Join(t.TempDir(), "signed.appx")
+	require.NoError(t, patch.Apply(infile, signed))
+	f, err := os.Open(signed)
+	require.NoError(t, err)
+	defer f.Close()
+	st, err := f.Stat()
+	require.NoError(t, err)
+	sig, err := Verify(f, st.Size(), false)
+	require.NoError(t, err)
+	assert.Equal(t, cert.Leaf.Raw, sig.Signature.Certificate.Raw)
+
+	// re-signing with the same certificate passes the strict check
+	_, err = f.Seek(0, 0)
+	require.NoError(t, err)
+	var stream bytes.Buffer
+	require.NoError(t, zipslicer.ZipToTar(f, &stream))
+	digest, err = DigestAppxTar(&stream, crypto.SHA256, false)
+	require.NoError(t, err)
+	assert.NoError(t, digest.CheckPublisher(cert.Leaf))
+	assert.Equal(t, x509tools.FormatPkixName(cert.Leaf.RawSubject, x509tools.NameStyleMsOsco), digest.manifest.Identity.Publisher)
+}
+
+func TestIsNestedPackage(t *testing.T) {
+	assert.True(t, isNestedPackage("App1_x64.appx"))
+	assert.True(t, isNestedPackage("App1_x64.msix"))
+	assert.True(t, isNestedPackage("App1_x64.MSIX"))
+	assert.False(t, isNestedPackage("AppxManifest.xml"))
+}
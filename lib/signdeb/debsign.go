@@ -46,8 +46,28 @@ type DebSignature struct {
 // signature, e.g. "builder". Returns a structure holding a PatchSet that can
 // be applied to the original file to add or replace the signature.
 func Sign(r io.Reader, signer *openpgp.Entity, opts crypto.SignerOpts, role string) (*DebSignature, error) {
+	return SignAt(r, signer, opts, role, time.Now())
+}
+
+// SignAt is like Sign, but records the given signing time instead of the
+// current time
+func SignAt(r io.Reader, signer *openpgp.Entity, opts crypto.SignerOpts, role string, now time.Time) (*DebSignature, error) {
+	return signAt(r, signer, opts, role, now, false)
+}
+
+// SignAtReplacing is like SignAt, but the patch removes the existing
+// signatures for every role instead of only the one being signed
+func SignAtReplacing(r io.Reader, signer *openpgp.Entity, opts crypto.SignerOpts, role string, now time.Time) (*DebSignature, error) {
+	return signAt(r, signer, opts, role, now, true)
+}
+
+type removal struct {
+	offset, length int64
+}
+
+func signAt(r io.Reader, signer *openpgp.Entity, opts crypto.SignerOpts, role string, now time.Time, replaceAll bool) (*DebSignature, error) {
 	counter := readercounter.New(r)
-	now := time.Now().UTC()
+	now = now.UTC()
 	reader := ar.NewReader(counter)
 	msg := new(bytes.Buffer)
 	fmt.Fprintln(msg, "Version: 4")
@@ -55,7 +75,7 @@ func Sign(r io.Reader, signer *openpgp.Entity, opts crypto.SignerOpts, role stri
 	fmt.Fprintln(msg, "Date:", now.Format(time.ANSIC))
 	fmt.Fprintln(msg, "Role:", role)
 	fmt.Fprintln(msg, "Files: ")
-	var patchOffset, patchLength int64
+	var removals []removal
 	var info *PackageInfo
 	filename := "_gpg" + role
 	for {
@@ -66,12 +86,11 @@ func Sign(r io.Reader, signer *openpgp.Entity, opts crypto.SignerOpts, role stri
 			return nil, err
 		}
 		name := path.Clean(hdr.Name)
-		if name == filename {
-			// mark the old signature for removal
-			patchOffset = counter.N - 60
-			patchLength = int64(60 + ((hdr.Size+1)/2)*2)
-		}
 		if strings.HasPrefix(name, "_gpg") {
+			if name == filename || replaceAll {
+				// mark the old signature for removal
+				removals = append(removals, removal{counter.N - 60, 60 + ((hdr.Size+1)/2)*2})
+			}
 			continue
 		}
 		save := io.Writer(ioutil.Discard)
@@ -139,10 +158,17 @@ func Sign(r io.Reader, signer *openpgp.Entity, opts crypto.SignerOpts, role stri
 	if _, err := writer.Write(signed.Bytes()); err != nil {
 		return nil, err
 	}
-	if patchOffset == 0 {
-		patchOffset = counter.N // end of file
+	if len(removals) == 0 {
+		removals = append(removals, removal{counter.N, 0}) // end of file
 	}
+	// the new signature takes the place of the first one removed
 	patch := binpatch.New()
-	patch.Add(patchOffset, patchLength, pbuf.Bytes())
+	for i, rm := range removals {
+		var blob []byte
+		if i == 0 {
+			blob = pbuf.Bytes()
+		}
+		patch.Add(rm.offset, rm.length, blob)
+	}
 	return &DebSignature{*info, now, patch}, nil
 }
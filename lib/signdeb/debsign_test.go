@@ -0,0 +1,53 @@
+package signdeb
+
+import (
+	"bytes"
+	"crypto"
+	"io"
+	"os"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignRoles(t *testing.T) {
+	entity, err := openpgp.NewEntity("test", "", "test@example.com", &packet.Config{Algorithm: packet.PubKeyAlgoRSA, RSABits: 2048})
+	require.NoError(t, err)
+	keyring := openpgp.EntityList{entity}
+	pkg, err := os.ReadFile("../../functest/packages/zlib1g_1.2.8.dfsg-5_i386.deb")
+	require.NoError(t, err)
+
+	sign := func(signAt func(io.Reader, *openpgp.Entity, crypto.SignerOpts, string, time.Time) (*DebSignature, error), pkg []byte, role string) []byte {
+		sig, err := signAt(bytes.NewReader(pkg), entity, crypto.SHA256, role, time.Now())
+		require.NoError(t, err)
+		var out bytes.Buffer
+		require.NoError(t, sig.PatchSet.ApplyStream(bytes.NewReader(pkg), &out))
+		return out.Bytes()
+	}
+	roles := func(pkg []byte) []string {
+		sigs, err := Verify(bytes.NewReader(pkg), keyring, false)
+		require.NoError(t, err)
+		var names []string
+		for role := range sigs {
+			names = append(names, role)
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	// each role has its own signature, replaced if signed again
+	signed := sign(SignAt, pkg, "builder")
+	signed = sign(SignAt, signed, "origin")
+	assert.Equal(t, []string{"builder", "origin"}, roles(signed))
+	resigned := sign(SignAt, signed, "builder")
+	assert.Equal(t, []string{"builder", "origin"}, roles(resigned))
+	// replacing removes every role
+	replaced := sign(SignAtReplacing, signed, "maint")
+	assert.Equal(t, []string{"maint"}, roles(replaced))
+	assert.Equal(t, []string{"builder"}, roles(sign(SignAtReplacing, pkg, "builder")))
+}
@@ -18,13 +18,16 @@ package signjar
 
 import (
 	"crypto"
+	"crypto/rsa"
 	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
+	"time"
 
+	"github.com/mind-security/relic/v8/lib/digestpool"
 	"github.com/mind-security/relic/v8/lib/x509tools"
 	"github.com/mind-security/relic/v8/lib/zipslicer"
 )
@@ -37,24 +40,38 @@ type JarDigest struct {
 	Digests  map[string]string
 	Manifest []byte
 	Hash     crypto.Hash
-	inz      *zipslicer.Directory
+	// ModTime is applied to the signature files. If zero then the current
+	// time is used.
+	ModTime time.Time
+	// PSS makes RSA keys sign with RSASSA-PSS instead of PKCS#1 v1.5. Its
+	// hash is ignored in favor of Hash.
+	PSS *rsa.PSSOptions
+
+	inz *zipslicer.Directory
 }
 
 func DigestJarStream(r io.Reader, hash crypto.Hash) (*JarDigest, error) {
+	return DigestJarStreamLimits(r, hash, digestpool.Limits{})
+}
+
+// DigestJarStreamLimits is like DigestJarStream, but limits how many files are
+// digested in parallel and how much of each is buffered
+func DigestJarStreamLimits(r io.Reader, hash crypto.Hash, limits digestpool.Limits) (*JarDigest, error) {
 	inz, err := zipslicer.ReadZipTar(r)
 	if err != nil {
 		return nil, err
 	}
-	return updateManifest(inz, hash)
+	return updateManifest(inz, hash, limits)
 }
 
 // Digest all of the files in the JAR
-func digestFiles(jar *zipslicer.Directory, hash crypto.Hash) (*JarDigest, error) {
+func digestFiles(jar *zipslicer.Directory, hash crypto.Hash, limits digestpool.Limits) (*JarDigest, error) {
 	jd := &JarDigest{
 		Hash:    hash,
 		Digests: make(map[string]string),
 		inz:     jar,
 	}
+	pool := digestpool.New(hash, limits)
 	for _, f := range jar.File {
 		if f.Name == manifestName {
 			r, err := f.Open()
@@ -72,14 +89,12 @@ func digestFiles(jar *zipslicer.Directory, hash crypto.Hash) (*JarDigest, error)
 			if err != nil {
 				return nil, err
 			}
-			d := hash.New()
-			if _, err := io.Copy(d, r); err != nil {
+			if err := pool.Add(f.Name, r, int64(f.UncompressedSize)); err != nil {
 				return nil, fmt.Errorf("failed to digest JAR file %s: %w", f.Name, err)
 			}
 			if err := r.Close(); err != nil {
 				return nil, fmt.Errorf("failed to digest JAR file %s: %w", f.Name, err)
 			}
-			jd.Digests[f.Name] = base64.StdEncoding.EncodeToString(d.Sum(nil))
 		}
 		// Ensure we get a copy of the zip metadata even if the file isn't
 		// digested, because if we're reading from a stream we can't go back
@@ -88,12 +103,15 @@ func digestFiles(jar *zipslicer.Directory, hash crypto.Hash) (*JarDigest, error)
 			return nil, fmt.Errorf("failed to read JAR manifest: %w", err)
 		}
 	}
+	for name, digest := range pool.Wait() {
+		jd.Digests[name] = base64.StdEncoding.EncodeToString(digest)
+	}
 	return jd, nil
 }
 
 // Check JAR contents against its manifest and adds digests if necessary
-func updateManifest(jar *zipslicer.Directory, hash crypto.Hash) (*JarDigest, error) {
-	jd, err := digestFiles(jar, hash)
+func updateManifest(jar *zipslicer.Directory, hash crypto.Hash, limits digestpool.Limits) (*JarDigest, error) {
+	jd, err := digestFiles(jar, hash, limits)
 	if err != nil {
 		return nil, err
 	} else if jd.Manifest == nil {
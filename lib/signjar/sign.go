@@ -38,40 +38,93 @@ import (
 )
 
 func (jd *JarDigest) Sign(ctx context.Context, cert *certloader.Certificate, alias string, sectionsOnly, inlineSignature, apkV2 bool) (*binpatch.PatchSet, *pkcs9.TimestampedSignature, error) {
-	// Create sigfile from the manifest
-	sf, err := DigestManifest(jd.Manifest, jd.Hash, sectionsOnly, apkV2)
+	sf, psig, ts, err := jd.signManifest(ctx, cert, sectionsOnly, inlineSignature, apkV2)
+	if err != nil {
+		return nil, nil, err
+	}
+	patch, err := jd.insertSignature(cert.Leaf, alias, sf, psig)
 	if err != nil {
 		return nil, nil, err
 	}
+	return patch, ts, nil
+}
+
+// SignDetached signs the manifest the same way as Sign, but instead of
+// patching the JAR it returns a separate ZIP archive holding the manifest and
+// signature files. Use VerifyDetached to check it against the original JAR.
+func (jd *JarDigest) SignDetached(ctx context.Context, cert *certloader.Certificate, alias string, sectionsOnly, inlineSignature, apkV2 bool) ([]byte, *pkcs9.TimestampedSignature, error) {
+	sf, psig, ts, err := jd.signManifest(ctx, cert, sectionsOnly, inlineSignature, apkV2)
+	if err != nil {
+		return nil, nil, err
+	}
+	signame, pkcsname := sigNames(cert.Leaf.PublicKey, alias)
+	mtime := jd.ModTime
+	if mtime.IsZero() {
+		mtime = time.Now()
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range []struct {
+		name     string
+		contents []byte
+	}{
+		{manifestName, jd.Manifest},
+		{metaInf + signame, sf},
+		{metaInf + pkcsname, psig},
+	} {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: f.name, Method: zip.Deflate, Modified: mtime})
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, err := w.Write(f.contents); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, nil, err
+	}
+	return buf.Bytes(), ts, nil
+}
+
+// signManifest creates a sigfile from the manifest and signs it, returning
+// the sigfile and the signature block
+func (jd *JarDigest) signManifest(ctx context.Context, cert *certloader.Certificate, sectionsOnly, inlineSignature, apkV2 bool) (sf, psig []byte, ts *pkcs9.TimestampedSignature, err error) {
+	// Create sigfile from the manifest
+	sf, err = DigestManifest(jd.Manifest, jd.Hash, sectionsOnly, apkV2)
+	if err != nil {
+		return nil, nil, nil, err
+	}
 	// Sign sigfile
-	sig := pkcs7.NewBuilder(cert.Signer(), cert.Chain(), jd.Hash)
+	var opts crypto.SignerOpts = jd.Hash
+	if jd.PSS != nil {
+		if _, ok := cert.Leaf.PublicKey.(*rsa.PublicKey); !ok {
+			return nil, nil, nil, errors.New("RSASSA-PSS requires an RSA key")
+		}
+		opts = &rsa.PSSOptions{Hash: jd.Hash, SaltLength: jd.PSS.SaltLength}
+	}
+	sig := pkcs7.NewBuilder(cert.Signer(), cert.Chain(), opts)
 	if err := sig.SetContentData(sf); err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 	psd, err := sig.Sign()
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
-	ts, err := pkcs9.TimestampAndMarshal(ctx, psd, cert.Timestamper, false)
+	ts, err = pkcs9.TimestampAndMarshal(ctx, psd, cert.Timestamper, false)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
-	// Rebuild zip with updated manifest, sigfile, and signature
-	psig := ts.Raw
+	psig = ts.Raw
 	if !inlineSignature {
 		if _, err := psd.Detach(); err != nil {
-			return nil, nil, err
+			return nil, nil, nil, err
 		}
 		psig, err = asn1.Marshal(*psd)
 		if err != nil {
-			return nil, nil, err
+			return nil, nil, nil, err
 		}
 	}
-	patch, err := jd.insertSignature(cert.Leaf, alias, sf, psig)
-	if err != nil {
-		return nil, nil, err
-	}
-	return patch, ts, nil
+	return sf, psig, ts, nil
 }
 
 func (jd *JarDigest) insertSignature(cert *x509.Certificate, alias string, sf, sig []byte) (*binpatch.PatchSet, error) {
@@ -80,7 +133,10 @@ func (jd *JarDigest) insertSignature(cert *x509.Certificate, alias string, sf, s
 	// Add new files to beginning of zip
 	outz := new(zipslicer.Directory)
 	var zipcon bytes.Buffer
-	mtime := time.Now()
+	mtime := jd.ModTime
+	if mtime.IsZero() {
+		mtime = time.Now()
+	}
 	if _, err := outz.NewFile(metaInf, jarMagic, nil, &zipcon, mtime, false, false); err != nil {
 		return nil, err
 	}
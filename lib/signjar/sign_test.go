@@ -0,0 +1,113 @@
+package signjar
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mind-security/relic/v8/lib/certloader"
+	"github.com/mind-security/relic/v8/lib/zipslicer"
+)
+
+func testCert(t *testing.T) *certloader.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "jar signer"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &certloader.Certificate{Leaf: cert, PrivateKey: key}
+}
+
+func makeJar(t *testing.T, classFile string) string {
+	fp := filepath.Join(t.TempDir(), "test.jar")
+	f, err := os.Create(fp)
+	require.NoError(t, err)
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	for _, m := range []struct{ name, contents string }{
+		{"META-INF/MANIFEST.MF", "Manifest-Version: 1.0\r\nCreated-By: test\r\n\r\n"},
+		{"com/example/Main.class", classFile},
+	} {
+		w, err := zw.Create(m.name)
+		require.NoError(t, err)
+		_, err = io.WriteString(w, m.contents)
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	return fp
+}
+
+func openJar(t *testing.T, fp string) *zip.Reader {
+	blob, err := os.ReadFile(fp)
+	require.NoError(t, err)
+	inz, err := zip.NewReader(bytes.NewReader(blob), int64(len(blob)))
+	require.NoError(t, err)
+	return inz
+}
+
+func TestSignDetached(t *testing.T) {
+	jarPath := makeJar(t, "\xca\xfe\xba\xbe")
+	before, err := os.ReadFile(jarPath)
+	require.NoError(t, err)
+	f, err := os.Open(jarPath)
+	require.NoError(t, err)
+	defer f.Close()
+	var stream bytes.Buffer
+	require.NoError(t, zipslicer.ZipToTar(f, &stream))
+	jd, err := DigestJarStream(&stream, crypto.SHA256)
+	require.NoError(t, err)
+	jd.ModTime = zipslicer.ZipEpoch
+
+	cert := testCert(t)
+	blob, _, err := jd.SignDetached(context.Background(), cert, "relic", false, false, false)
+	require.NoError(t, err)
+	sigz, err := zip.NewReader(bytes.NewReader(blob), int64(len(blob)))
+	require.NoError(t, err)
+	var names []string
+	for _, f := range sigz.File {
+		names = append(names, f.Name)
+	}
+	assert.Equal(t, []string{"META-INF/MANIFEST.MF", "META-INF/RELIC.SF", "META-INF/RELIC.EC"}, names)
+
+	// the JAR itself is untouched and carries no signature
+	after, err := os.ReadFile(jarPath)
+	require.NoError(t, err)
+	assert.Equal(t, before, after)
+	_, err = Verify(openJar(t, jarPath), false)
+	assert.Error(t, err)
+
+	sigs, err := VerifyDetached(openJar(t, jarPath), sigz, false)
+	require.NoError(t, err)
+	require.Len(t, sigs, 1)
+	assert.Equal(t, cert.Leaf.Raw, sigs[0].Certificate.Raw)
+	assert.Equal(t, crypto.SHA256, sigs[0].Hash)
+
+	// a JAR with different contents doesn't match the detached manifest
+	other := makeJar(t, "\xca\xfe\xba\xbe tampered")
+	_, err = VerifyDetached(openJar(t, other), sigz, false)
+	assert.Error(t, err)
+	_, err = VerifyDetached(openJar(t, other), sigz, true)
+	assert.NoError(t, err)
+}
@@ -44,10 +44,17 @@ type JarSignature struct {
 }
 
 func Verify(inz *zip.Reader, skipDigests bool) ([]*JarSignature, error) {
+	return VerifyDetached(inz, inz, skipDigests)
+}
+
+// VerifyDetached verifies the manifest and signature files in sigz, as
+// produced by SignDetached, against the contents of the JAR inz. Any
+// signatures inside the JAR itself are ignored.
+func VerifyDetached(inz, sigz *zip.Reader, skipDigests bool) ([]*JarSignature, error) {
 	var manifest []byte
 	sigfiles := make(map[string][]byte)
 	sigblobs := make(map[string][]byte)
-	for _, f := range inz.File {
+	for _, f := range sigz.File {
 		dir, name := path.Split(strings.ToUpper(f.Name))
 		if dir != "META-INF/" || name == "" {
 			continue
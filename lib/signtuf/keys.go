@@ -0,0 +1,182 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package signtuf
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+// TUF signature schemes
+const (
+	SchemeEd25519   = "ed25519"
+	SchemeECDSAP256 = "ecdsa-sha2-nistp256"
+	SchemeECDSAP384 = "ecdsa-sha2-nistp384"
+	SchemeRSAPSS    = "rsassa-pss-sha256"
+	SchemeRSAPKCS1  = "rsa-pkcs1v15-sha256"
+)
+
+// Key is a public key as listed in TUF root metadata
+type Key struct {
+	KeyType string `json:"keytype"`
+	Scheme  string `json:"scheme"`
+	KeyVal  KeyVal `json:"keyval"`
+}
+
+type KeyVal struct {
+	Public string `json:"public"`
+}
+
+// NewKey describes a public key in TUF's format. Ed25519 keys are hex-encoded
+// and other types are PEM.
+func NewKey(pub crypto.PublicKey) (*Key, error) {
+	var keyType, scheme string
+	switch pub := pub.(type) {
+	case ed25519.PublicKey:
+		return &Key{KeyType: "ed25519", Scheme: SchemeEd25519, KeyVal: KeyVal{Public: hex.EncodeToString(pub)}}, nil
+	case *ecdsa.PublicKey:
+		keyType = "ecdsa"
+		switch pub.Curve {
+		case elliptic.P256():
+			scheme = SchemeECDSAP256
+		case elliptic.P384():
+			scheme = SchemeECDSAP384
+		default:
+			return nil, fmt.Errorf("unsupported curve %s for TUF", pub.Curve.Params().Name)
+		}
+	case *rsa.PublicKey:
+		keyType = "rsa"
+		scheme = SchemeRSAPSS
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T for TUF", pub)
+	}
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	block := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	return &Key{KeyType: keyType, Scheme: scheme, KeyVal: KeyVal{Public: string(block)}}, nil
+}
+
+// ID calculates the key ID, which is the SHA-256 of the canonical JSON of the
+// key
+func (k *Key) ID() (string, error) {
+	blob, err := json.Marshal(k)
+	if err != nil {
+		return "", err
+	}
+	blob, err = Canonical(blob)
+	if err != nil {
+		return "", err
+	}
+	d := sha256.Sum256(blob)
+	return hex.EncodeToString(d[:]), nil
+}
+
+// PublicKey parses the key's public value
+func (k *Key) PublicKey() (crypto.PublicKey, error) {
+	if k.Scheme == SchemeEd25519 {
+		pub, err := hex.DecodeString(k.KeyVal.Public)
+		if err != nil || len(pub) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid ed25519 public key")
+		}
+		return ed25519.PublicKey(pub), nil
+	}
+	block, _ := pem.Decode([]byte(k.KeyVal.Public))
+	if block == nil {
+		return nil, fmt.Errorf("invalid %s public key", k.Scheme)
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
+
+// schemeHash returns the digest a scheme signs with, or 0 if it signs the
+// message directly
+func schemeHash(scheme string) (crypto.Hash, error) {
+	switch scheme {
+	case SchemeEd25519:
+		return 0, nil
+	case SchemeECDSAP256, SchemeRSAPSS, SchemeRSAPKCS1:
+		return crypto.SHA256, nil
+	case SchemeECDSAP384:
+		return crypto.SHA384, nil
+	}
+	return 0, fmt.Errorf("unsupported TUF signature scheme %q", scheme)
+}
+
+func digestMessage(hash crypto.Hash, msg []byte) []byte {
+	if hash == 0 {
+		return msg
+	}
+	d := hash.New()
+	d.Write(msg)
+	return d.Sum(nil)
+}
+
+// sign a message using the key's scheme
+func sign(signer crypto.Signer, scheme string, msg []byte) ([]byte, error) {
+	hash, err := schemeHash(scheme)
+	if err != nil {
+		return nil, err
+	}
+	var opts crypto.SignerOpts = hash
+	if scheme == SchemeRSAPSS {
+		opts = &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: hash}
+	}
+	return signer.Sign(rand.Reader, digestMessage(hash, msg), opts)
+}
+
+// verify a signature over a message using the key's scheme
+func (k *Key) verify(msg, sig []byte) error {
+	hash, err := schemeHash(k.Scheme)
+	if err != nil {
+		return err
+	}
+	pub, err := k.PublicKey()
+	if err != nil {
+		return err
+	}
+	digest := digestMessage(hash, msg)
+	switch pub := pub.(type) {
+	case ed25519.PublicKey:
+		if k.Scheme == SchemeEd25519 && ed25519.Verify(pub, msg, sig) {
+			return nil
+		}
+	case *ecdsa.PublicKey:
+		if (k.Scheme == SchemeECDSAP256 || k.Scheme == SchemeECDSAP384) && ecdsa.VerifyASN1(pub, digest, sig) {
+			return nil
+		}
+	case *rsa.PublicKey:
+		switch k.Scheme {
+		case SchemeRSAPSS:
+			return rsa.VerifyPSS(pub, hash, digest, sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthAuto})
+		case SchemeRSAPKCS1:
+			return rsa.VerifyPKCS1v15(pub, hash, digest, sig)
+		}
+	}
+	return errors.New("signature verification failed")
+}
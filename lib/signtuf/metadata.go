@@ -0,0 +1,183 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package signtuf signs and verifies metadata for The Update Framework (TUF).
+// Each signature covers the canonical JSON of the "signed" object and is
+// added to the "signatures" list, so several keys can sign the same metadata
+// to meet a role's threshold.
+package signtuf
+
+import (
+	"crypto"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MediaType of TUF metadata
+const MediaType = "application/json"
+
+// Metadata is a TUF metadata document for any role
+type Metadata struct {
+	Signatures []Signature     `json:"signatures"`
+	Signed     json.RawMessage `json:"signed"`
+
+	// Fields common to all roles, and the keys and roles of root metadata
+	Type    string           `json:"-"`
+	Version int64            `json:"-"`
+	Keys    map[string]*Key  `json:"-"`
+	Roles   map[string]*Role `json:"-"`
+}
+
+// Signature over a metadata document
+type Signature struct {
+	KeyID string `json:"keyid"`
+	Sig   string `json:"sig"`
+}
+
+// Role lists the keys that may sign for a role in root metadata
+type Role struct {
+	KeyIDs    []string `json:"keyids"`
+	Threshold int      `json:"threshold"`
+}
+
+type signedHeader struct {
+	Type    string           `json:"_type"`
+	Version int64            `json:"version"`
+	Keys    map[string]*Key  `json:"keys"`
+	Roles   map[string]*Role `json:"roles"`
+}
+
+// Parse a TUF metadata document
+func Parse(blob []byte) (*Metadata, error) {
+	m := new(Metadata)
+	if err := json.Unmarshal(blob, m); err != nil {
+		return nil, fmt.Errorf("parsing TUF metadata: %w", err)
+	}
+	if len(m.Signed) == 0 {
+		return nil, errors.New("parsing TUF metadata: missing signed object")
+	}
+	var hdr signedHeader
+	if err := json.Unmarshal(m.Signed, &hdr); err != nil {
+		return nil, fmt.Errorf("parsing TUF metadata: %w", err)
+	}
+	if hdr.Type == "" {
+		return nil, errors.New("parsing TUF metadata: missing _type")
+	}
+	m.Type = strings.ToLower(hdr.Type)
+	m.Version = hdr.Version
+	m.Keys = hdr.Keys
+	m.Roles = hdr.Roles
+	return m, nil
+}
+
+// Marshal the document including any new signatures. The signed object is
+// reindented but otherwise unchanged.
+func (m *Metadata) Marshal() ([]byte, error) {
+	blob, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(blob, '\n'), nil
+}
+
+// KeyID returns the ID that root metadata uses for a public key, if it lists
+// it, otherwise the ID calculated from the key
+func (m *Metadata) KeyID(pub crypto.PublicKey) (string, error) {
+	key, err := NewKey(pub)
+	if err != nil {
+		return "", err
+	}
+	for keyID, listed := range m.Keys {
+		if listedPub, err := listed.PublicKey(); err == nil && equalKeys(pub, listedPub) {
+			return keyID, nil
+		}
+	}
+	return key.ID()
+}
+
+func equalKeys(a, b crypto.PublicKey) bool {
+	eq, ok := a.(interface{ Equal(crypto.PublicKey) bool })
+	return ok && eq.Equal(b)
+}
+
+// Sign the metadata and add the signature under keyID, replacing any existing
+// signature with the same ID
+func (m *Metadata) Sign(signer crypto.Signer, keyID string) error {
+	key, err := NewKey(signer.Public())
+	if err != nil {
+		return err
+	}
+	msg, err := Canonical(m.Signed)
+	if err != nil {
+		return err
+	}
+	sig, err := sign(signer, key.Scheme, msg)
+	if err != nil {
+		return err
+	}
+	sigs := []Signature{}
+	for _, existing := range m.Signatures {
+		if existing.KeyID != keyID {
+			sigs = append(sigs, existing)
+		}
+	}
+	m.Signatures = append(sigs, Signature{KeyID: keyID, Sig: hex.EncodeToString(sig)})
+	return nil
+}
+
+// Verify the metadata's signatures using the keys and threshold that root
+// assigns to its role, and return the IDs of the keys with valid signatures
+func (m *Metadata) Verify(root *Metadata) ([]string, error) {
+	role := root.Roles[m.Type]
+	if role == nil {
+		return nil, fmt.Errorf("role %q is not defined in root metadata", m.Type)
+	}
+	msg, err := Canonical(m.Signed)
+	if err != nil {
+		return nil, err
+	}
+	allowed := make(map[string]bool, len(role.KeyIDs))
+	for _, keyID := range role.KeyIDs {
+		allowed[keyID] = true
+	}
+	var valid []string
+	seen := make(map[string]bool)
+	for _, sig := range m.Signatures {
+		key := root.Keys[sig.KeyID]
+		if !allowed[sig.KeyID] || key == nil || seen[sig.KeyID] {
+			continue
+		}
+		// like other TUF clients, ignore bad signatures as long as enough good
+		// ones remain
+		raw, err := hex.DecodeString(sig.Sig)
+		if err != nil || key.verify(msg, raw) != nil {
+			continue
+		}
+		seen[sig.KeyID] = true
+		valid = append(valid, sig.KeyID)
+	}
+	threshold := role.Threshold
+	if threshold < 1 {
+		threshold = 1
+	}
+	if len(valid) < threshold {
+		return nil, fmt.Errorf("%s metadata has %d valid signatures from trusted keys but the threshold is %d", m.Type, len(valid), threshold)
+	}
+	return valid, nil
+}
@@ -0,0 +1,104 @@
+package signtuf
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonical(t *testing.T) {
+	blob, err := Canonical([]byte(`{"b": 1, "a": "x\"\\y\n", "c": [true, null, {}]}`))
+	require.NoError(t, err)
+	assert.Equal(t, "{\"a\":\"x\\\"\\\\y\n\",\"b\":1,\"c\":[true,null,{}]}", string(blob))
+	_, err = Canonical([]byte(`{"a": 1.5}`))
+	assert.Error(t, err)
+	_, err = Canonical([]byte(`{} {}`))
+	assert.Error(t, err)
+}
+
+func newTestRoot(t *testing.T, keys map[string][]crypto.Signer, thresholds map[string]int) *Metadata {
+	signed := map[string]any{
+		"_type":   "root",
+		"version": 1,
+		"expires": "2030-01-01T00:00:00Z",
+	}
+	allKeys := make(map[string]*Key)
+	roles := make(map[string]*Role)
+	for role, signers := range keys {
+		r := &Role{KeyIDs: []string{}, Threshold: thresholds[role]}
+		for _, signer := range signers {
+			key, err := NewKey(signer.Public())
+			require.NoError(t, err)
+			keyID, err := key.ID()
+			require.NoError(t, err)
+			allKeys[keyID] = key
+			r.KeyIDs = append(r.KeyIDs, keyID)
+		}
+		roles[role] = r
+	}
+	signed["keys"] = allKeys
+	signed["roles"] = roles
+	blob, err := json.Marshal(map[string]any{"signatures": []any{}, "signed": signed})
+	require.NoError(t, err)
+	md, err := Parse(blob)
+	require.NoError(t, err)
+	return md
+}
+
+func signAs(t *testing.T, md *Metadata, signer crypto.Signer) *Metadata {
+	keyID, err := md.KeyID(signer.Public())
+	require.NoError(t, err)
+	require.NoError(t, md.Sign(signer, keyID))
+	blob, err := md.Marshal()
+	require.NoError(t, err)
+	md, err = Parse(blob)
+	require.NoError(t, err)
+	return md
+}
+
+func TestSignVerify(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	root := newTestRoot(t,
+		map[string][]crypto.Signer{"root": {edKey, ecKey}, "targets": {rsaKey}},
+		map[string]int{"root": 2, "targets": 1})
+
+	// threshold signing of root
+	root = signAs(t, root, edKey)
+	_, err = root.Verify(root)
+	assert.ErrorContains(t, err, "threshold is 2")
+	root = signAs(t, root, ecKey)
+	root = signAs(t, root, edKey)
+	assert.Len(t, root.Signatures, 2)
+	keyIDs, err := root.Verify(root)
+	require.NoError(t, err)
+	assert.Len(t, keyIDs, 2)
+
+	// other roles are checked against the keys root gives them
+	targets, err := Parse([]byte(`{"signatures": [], "signed": {"_type": "targets", "version": 3, "targets": {}}}`))
+	require.NoError(t, err)
+	wrongKey := signAs(t, targets, edKey)
+	_, err = wrongKey.Verify(root)
+	assert.Error(t, err)
+	targets = signAs(t, targets, rsaKey)
+	keyIDs, err = targets.Verify(root)
+	require.NoError(t, err)
+	assert.Equal(t, root.Roles["targets"].KeyIDs, keyIDs)
+	assert.Equal(t, int64(3), targets.Version)
+
+	targets.Signed = json.RawMessage(`{"_type": "targets", "version": 4, "targets": {}}`)
+	_, err = targets.Verify(root)
+	assert.Error(t, err)
+}
@@ -0,0 +1,215 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package signwasm signs WebAssembly modules by appending a custom section
+// named "signature" that holds a CMS signature. The signed content is the
+// digest of the module with any signature sections removed, and is detached
+// from the stored signature.
+package signwasm
+
+import (
+	"bufio"
+	"bytes"
+	"crypto"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+)
+
+const (
+	// SectionName is the name of the custom section holding the signature
+	SectionName = "signature"
+
+	sectionCustom = 0
+	maxLEB128Size = 5
+)
+
+var (
+	wasmMagic   = []byte("\x00asm")
+	wasmVersion = []byte{1, 0, 0, 0}
+
+	ErrNotWasm = errors.New("not a WebAssembly module")
+)
+
+// section locates a signature section within a module
+type section struct {
+	Offset int64 // offset of the section ID
+	Size   int64 // size including the ID and length
+	Body   []byte
+}
+
+// scanResult describes a module after reading it through to the end
+type scanResult struct {
+	Digest     []byte
+	Size       int64
+	Signatures []section
+}
+
+// scan reads a module, feeding every section except signature sections into
+// the digest. If hashFunc is 0 then nothing is digested.
+func scan(r io.Reader, hashFunc crypto.Hash) (*scanResult, error) {
+	br := bufio.NewReader(r)
+	w := io.Discard
+	var d hash.Hash
+	if hashFunc != 0 {
+		d = hashFunc.New()
+		w = d
+	}
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, ErrNotWasm
+	} else if !bytes.Equal(header[:4], wasmMagic) {
+		return nil, ErrNotWasm
+	} else if !bytes.Equal(header[4:], wasmVersion) {
+		return nil, fmt.Errorf("unsupported WebAssembly version %d", binary.LittleEndian.Uint32(header[4:]))
+	}
+	_, _ = w.Write(header)
+	result := new(scanResult)
+	pos := int64(len(header))
+	for {
+		id, err := br.ReadByte()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		size, sizeBytes, err := readLEB128(br)
+		if err != nil {
+			return nil, fmt.Errorf("section at offset %d: %w", pos, err)
+		}
+		headerSize := int64(1 + len(sizeBytes))
+		body := io.LimitReader(br, int64(size))
+		if id == sectionCustom {
+			name, nameBytes, err := readName(body)
+			if err != nil {
+				return nil, fmt.Errorf("section at offset %d: %w", pos, err)
+			}
+			if name == SectionName {
+				blob, err := io.ReadAll(body)
+				if err != nil {
+					return nil, err
+				} else if len(blob)+len(nameBytes) != int(size) {
+					return nil, fmt.Errorf("section at offset %d: %w", pos, io.ErrUnexpectedEOF)
+				}
+				result.Signatures = append(result.Signatures, section{
+					Offset: pos,
+					Size:   headerSize + int64(size),
+					Body:   blob,
+				})
+				pos += headerSize + int64(size)
+				continue
+			}
+			// the name is part of the body but was consumed already
+			_, _ = w.Write([]byte{id})
+			_, _ = w.Write(sizeBytes)
+			_, _ = w.Write(nameBytes)
+			n, err := io.Copy(w, body)
+			if err != nil {
+				return nil, err
+			} else if n+int64(len(nameBytes)) != int64(size) {
+				return nil, fmt.Errorf("section at offset %d: %w", pos, io.ErrUnexpectedEOF)
+			}
+		} else {
+			_, _ = w.Write([]byte{id})
+			_, _ = w.Write(sizeBytes)
+			n, err := io.Copy(w, body)
+			if err != nil {
+				return nil, err
+			} else if n != int64(size) {
+				return nil, fmt.Errorf("section at offset %d: %w", pos, io.ErrUnexpectedEOF)
+			}
+		}
+		pos += headerSize + int64(size)
+	}
+	result.Size = pos
+	if d != nil {
+		result.Digest = d.Sum(nil)
+	}
+	return result, nil
+}
+
+// readName reads the name at the start of a custom section and returns it
+// along with its encoded form
+func readName(r io.Reader) (string, []byte, error) {
+	br := &byteReader{r: r}
+	size, sizeBytes, err := readLEB128(br)
+	if err != nil {
+		return "", nil, err
+	}
+	encoded := make([]byte, len(sizeBytes)+int(size))
+	copy(encoded, sizeBytes)
+	if _, err := io.ReadFull(r, encoded[len(sizeBytes):]); err != nil {
+		return "", nil, fmt.Errorf("reading custom section name: %w", err)
+	}
+	return string(encoded[len(sizeBytes):]), encoded, nil
+}
+
+// readLEB128 reads an unsigned 32-bit LEB128 integer and returns it along with
+// its encoded form
+func readLEB128(r io.ByteReader) (uint32, []byte, error) {
+	var value uint32
+	var encoded []byte
+	for i := 0; i < maxLEB128Size; i++ {
+		b, err := r.ReadByte()
+		if err == io.EOF {
+			return 0, nil, io.ErrUnexpectedEOF
+		} else if err != nil {
+			return 0, nil, err
+		}
+		encoded = append(encoded, b)
+		value |= uint32(b&0x7f) << (7 * i)
+		if b&0x80 == 0 {
+			return value, encoded, nil
+		}
+	}
+	return 0, nil, errors.New("invalid LEB128 integer")
+}
+
+func appendLEB128(buf []byte, value uint32) []byte {
+	for {
+		b := byte(value & 0x7f)
+		value >>= 7
+		if value != 0 {
+			b |= 0x80
+		}
+		buf = append(buf, b)
+		if value == 0 {
+			return buf
+		}
+	}
+}
+
+// customSection encodes a custom section with the given name and contents
+func customSection(name string, contents []byte) []byte {
+	body := appendLEB128(nil, uint32(len(name)))
+	body = append(body, name...)
+	body = append(body, contents...)
+	out := []byte{sectionCustom}
+	out = appendLEB128(out, uint32(len(body)))
+	return append(out, body...)
+}
+
+type byteReader struct {
+	r   io.Reader
+	buf [1]byte
+}
+
+func (b *byteReader) ReadByte() (byte, error) {
+	_, err := io.ReadFull(b.r, b.buf[:])
+	return b.buf[0], err
+}
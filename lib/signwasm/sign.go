@@ -0,0 +1,81 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package signwasm
+
+import (
+	"context"
+	"crypto"
+	"io"
+
+	"github.com/mind-security/relic/v8/lib/binpatch"
+	"github.com/mind-security/relic/v8/lib/certloader"
+	"github.com/mind-security/relic/v8/lib/pkcs7"
+	"github.com/mind-security/relic/v8/lib/pkcs9"
+)
+
+type WasmDigest struct {
+	Hash   crypto.Hash
+	Digest []byte
+
+	size       int64
+	signatures []section
+}
+
+// DigestModule reads a module and digests everything except existing
+// signature sections, which will be removed when the new signature is added
+func DigestModule(r io.Reader, hash crypto.Hash) (*WasmDigest, error) {
+	result, err := scan(r, hash)
+	if err != nil {
+		return nil, err
+	}
+	return &WasmDigest{
+		Hash:       hash,
+		Digest:     result.Digest,
+		size:       result.Size,
+		signatures: result.Signatures,
+	}, nil
+}
+
+// Sign the digest and return a patch that removes any old signature sections
+// and appends the new one
+func (d *WasmDigest) Sign(ctx context.Context, cert *certloader.Certificate) (*binpatch.PatchSet, *pkcs9.TimestampedSignature, error) {
+	builder := pkcs7.NewBuilder(cert.Signer(), cert.Chain(), d.Hash)
+	if err := builder.SetContentData(d.Digest); err != nil {
+		return nil, nil, err
+	}
+	psd, err := builder.Sign()
+	if err != nil {
+		return nil, nil, err
+	}
+	tsig, err := pkcs9.TimestampAndMarshal(ctx, psd, cert.Timestamper, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := psd.Detach(); err != nil {
+		return nil, nil, err
+	}
+	tsig.Raw, err = psd.Marshal()
+	if err != nil {
+		return nil, nil, err
+	}
+	patch := binpatch.New()
+	for _, sig := range d.signatures {
+		patch.Add(sig.Offset, sig.Size, nil)
+	}
+	patch.Add(d.size, 0, customSection(SectionName, tsig.Raw))
+	return patch, tsig, nil
+}
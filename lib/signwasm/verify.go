@@ -0,0 +1,72 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package signwasm
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/mind-security/relic/v8/lib/pkcs7"
+	"github.com/mind-security/relic/v8/lib/pkcs9"
+	"github.com/mind-security/relic/v8/lib/x509tools"
+	"github.com/mind-security/relic/v8/signers/sigerrors"
+)
+
+// Verify a signed module. The module is read twice, first to find the
+// signature and then to digest everything else.
+func Verify(r io.ReaderAt, size int64, skipDigests bool) (*pkcs9.TimestampedSignature, error) {
+	result, err := scan(io.NewSectionReader(r, 0, size), 0)
+	if err != nil {
+		return nil, err
+	}
+	switch len(result.Signatures) {
+	case 0:
+		return nil, sigerrors.NotSignedError{Type: "wasm"}
+	case 1:
+	default:
+		return nil, errors.New("module has more than one signature section")
+	}
+	psd, err := pkcs7.Unmarshal(result.Signatures[0].Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading signature section: %w", err)
+	}
+	if len(psd.Content.SignerInfos) == 0 {
+		return nil, sigerrors.NotSignedError{Type: "wasm"}
+	}
+	var digest []byte
+	if !skipDigests {
+		hash, err := x509tools.PkixDigestToHashE(psd.Content.SignerInfos[0].DigestAlgorithm)
+		if err != nil {
+			return nil, err
+		}
+		result, err = scan(io.NewSectionReader(r, 0, size), hash)
+		if err != nil {
+			return nil, err
+		}
+		digest = result.Digest
+	}
+	sig, err := psd.Content.Verify(digest, skipDigests)
+	if err != nil {
+		return nil, err
+	}
+	ts, err := pkcs9.VerifyOptionalTimestamp(sig)
+	if err != nil {
+		return nil, err
+	}
+	return &ts, nil
+}
@@ -0,0 +1,99 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package spool makes a seekable copy of a stream that can only be read once,
+// such as standard input, so that it can be replayed when a request is retried
+// or when a signer needs to read it more than once.
+package spool
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// DefaultThreshold is the largest input held in memory when no threshold is
+// configured. Larger inputs are written to a temporary file.
+const DefaultThreshold = 10 * 1000 * 1000
+
+// Spool is a seekable copy of a stream
+type Spool struct {
+	io.ReadSeeker
+	size int64
+	f    *os.File
+}
+
+// New reads r to completion. Up to threshold bytes are kept in memory; if r
+// is longer than that then it is written to a temporary file instead, which is
+// removed when the Spool is closed. A threshold of 0 means DefaultThreshold,
+// and a negative threshold always uses a temporary file.
+func New(r io.Reader, threshold int64) (*Spool, error) {
+	if threshold == 0 {
+		threshold = DefaultThreshold
+	}
+	var buf bytes.Buffer
+	if threshold > 0 {
+		n, err := io.Copy(&buf, io.LimitReader(r, threshold+1))
+		if err != nil {
+			return nil, err
+		} else if n <= threshold {
+			return &Spool{ReadSeeker: bytes.NewReader(buf.Bytes()), size: n}, nil
+		}
+	}
+	// too big for memory, move what was read so far to disk and continue
+	f, err := os.CreateTemp("", "relic-spool-")
+	if err != nil {
+		return nil, err
+	}
+	s := &Spool{ReadSeeker: f, f: f}
+	if _, err := io.Copy(f, io.MultiReader(&buf, r)); err != nil {
+		s.Close()
+		return nil, err
+	}
+	s.size, err = f.Seek(0, io.SeekCurrent)
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Size returns the length of the spooled stream
+func (s *Spool) Size() int64 {
+	return s.size
+}
+
+// OnDisk returns true if the stream was written to a temporary file
+func (s *Spool) OnDisk() bool {
+	return s.f != nil
+}
+
+// Close releases the temporary file, if any
+func (s *Spool) Close() error {
+	if s.f == nil {
+		return nil
+	}
+	f := s.f
+	s.f = nil
+	err := f.Close()
+	if err2 := os.Remove(f.Name()); err == nil {
+		err = err2
+	}
+	return err
+}
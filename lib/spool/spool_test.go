@@ -0,0 +1,49 @@
+package spool
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpool(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 100)
+	for _, tc := range []struct {
+		name      string
+		threshold int64
+		onDisk    bool
+	}{
+		{"memory", 2000, false},
+		{"exact", int64(len(data)), false},
+		{"disk", 100, true},
+		{"always-disk", -1, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := New(bytes.NewReader(data), tc.threshold)
+			require.NoError(t, err)
+			assert.Equal(t, tc.onDisk, s.OnDisk())
+			assert.Equal(t, int64(len(data)), s.Size())
+			// can be read more than once
+			for i := 0; i < 2; i++ {
+				_, err = s.Seek(0, io.SeekStart)
+				require.NoError(t, err)
+				got, err := io.ReadAll(s)
+				require.NoError(t, err)
+				assert.Equal(t, data, got)
+			}
+			var name string
+			if s.OnDisk() {
+				name = s.f.Name()
+			}
+			require.NoError(t, s.Close())
+			if name != "" {
+				_, err := os.Stat(name)
+				assert.True(t, os.IsNotExist(err))
+			}
+		})
+	}
+}
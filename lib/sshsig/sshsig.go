@@ -0,0 +1,219 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package sshsig implements the OpenSSH SSHSIG format for signing arbitrary
+// data with an SSH key, as made by "ssh-keygen -Y sign" and used by git when
+// gpg.format is ssh.
+//
+// https://github.com/openssh/openssh-portable/blob/master/PROTOCOL.sshsig
+package sshsig
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	magic   = "SSHSIG"
+	version = 1
+
+	armorBegin = "-----BEGIN SSH SIGNATURE-----"
+	armorEnd   = "-----END SSH SIGNATURE-----"
+)
+
+// Signature is a parsed SSHSIG signature
+type Signature struct {
+	PublicKey     ssh.PublicKey
+	Namespace     string
+	HashAlgorithm string
+	Signature     *ssh.Signature
+}
+
+// the signature blob, following the magic preamble
+type wireSignature struct {
+	Version       uint32
+	PublicKey     []byte
+	Namespace     string
+	Reserved      string
+	HashAlgorithm string
+	Signature     []byte
+}
+
+// the data that is actually signed, following the magic preamble
+type signedData struct {
+	Namespace     string
+	Reserved      string
+	HashAlgorithm string
+	Hash          []byte
+}
+
+// HashName returns the name SSHSIG uses for a digest algorithm. Only SHA-256
+// and SHA-512 are allowed.
+func HashName(hash crypto.Hash) (string, error) {
+	switch hash {
+	case crypto.SHA256:
+		return "sha256", nil
+	case crypto.SHA512:
+		return "sha512", nil
+	default:
+		return "", fmt.Errorf("SSH signatures can't use digest %s", hash)
+	}
+}
+
+func hashByName(name string) (crypto.Hash, error) {
+	switch name {
+	case "sha256":
+		return crypto.SHA256, nil
+	case "sha512":
+		return crypto.SHA512, nil
+	default:
+		return 0, fmt.Errorf("unsupported SSH signature digest %q", name)
+	}
+}
+
+func dataToSign(namespace, hashName string, digest []byte) []byte {
+	return append([]byte(magic), ssh.Marshal(signedData{
+		Namespace:     namespace,
+		HashAlgorithm: hashName,
+		Hash:          digest,
+	})...)
+}
+
+// Sign digests message and signs it with key under the given namespace, which
+// verifiers must also specify. The signature is returned in ASCII armor.
+func Sign(key crypto.Signer, message io.Reader, namespace string, hash crypto.Hash) ([]byte, error) {
+	if namespace == "" {
+		return nil, errors.New("SSH signatures require a namespace")
+	}
+	hashName, err := HashName(hash)
+	if err != nil {
+		return nil, err
+	}
+	d := hash.New()
+	if _, err := io.Copy(d, message); err != nil {
+		return nil, err
+	}
+	signer, err := ssh.NewSignerFromSigner(key)
+	if err != nil {
+		return nil, err
+	}
+	data := dataToSign(namespace, hashName, d.Sum(nil))
+	var sig *ssh.Signature
+	if _, ok := key.Public().(*rsa.PublicKey); ok {
+		// ssh-rsa means SHA-1, which OpenSSH won't accept here
+		algSigner, ok := signer.(ssh.AlgorithmSigner)
+		if !ok {
+			return nil, errors.New("RSA key can't sign with SHA-512")
+		}
+		sig, err = algSigner.SignWithAlgorithm(rand.Reader, data, ssh.KeyAlgoRSASHA512)
+	} else {
+		sig, err = signer.Sign(rand.Reader, data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	blob := append([]byte(magic), ssh.Marshal(wireSignature{
+		Version:       version,
+		PublicKey:     signer.PublicKey().Marshal(),
+		Namespace:     namespace,
+		HashAlgorithm: hashName,
+		Signature:     ssh.Marshal(sig),
+	})...)
+	return armor(blob), nil
+}
+
+func armor(blob []byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(armorBegin + "\n")
+	b64 := base64.StdEncoding.EncodeToString(blob)
+	for len(b64) > 70 {
+		buf.WriteString(b64[:70] + "\n")
+		b64 = b64[70:]
+	}
+	buf.WriteString(b64 + "\n")
+	buf.WriteString(armorEnd + "\n")
+	return buf.Bytes()
+}
+
+// Parse an armored SSHSIG signature
+func Parse(armored []byte) (*Signature, error) {
+	text := strings.TrimSpace(string(armored))
+	if !strings.HasPrefix(text, armorBegin) || !strings.HasSuffix(text, armorEnd) {
+		return nil, errors.New("not an armored SSH signature")
+	}
+	text = strings.TrimSuffix(strings.TrimPrefix(text, armorBegin), armorEnd)
+	blob, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(text), ""))
+	if err != nil {
+		return nil, fmt.Errorf("SSH signature: %w", err)
+	}
+	if !bytes.HasPrefix(blob, []byte(magic)) {
+		return nil, errors.New("SSH signature: bad magic")
+	}
+	var wire wireSignature
+	if err := ssh.Unmarshal(blob[len(magic):], &wire); err != nil {
+		return nil, fmt.Errorf("SSH signature: %w", err)
+	} else if wire.Version != version {
+		return nil, fmt.Errorf("SSH signature: unsupported version %d", wire.Version)
+	}
+	pub, err := ssh.ParsePublicKey(wire.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("SSH signature: %w", err)
+	}
+	sig := new(ssh.Signature)
+	if err := ssh.Unmarshal(wire.Signature, sig); err != nil {
+		return nil, fmt.Errorf("SSH signature: %w", err)
+	}
+	return &Signature{
+		PublicKey:     pub,
+		Namespace:     wire.Namespace,
+		HashAlgorithm: wire.HashAlgorithm,
+		Signature:     sig,
+	}, nil
+}
+
+// Hash returns the digest algorithm the signature was made with
+func (s *Signature) Hash() (crypto.Hash, error) {
+	return hashByName(s.HashAlgorithm)
+}
+
+// Verify checks that the signature covers message and was made for the given
+// namespace. It does not check whether the public key is trusted.
+func (s *Signature) Verify(message io.Reader, namespace string) error {
+	if s.Namespace != namespace {
+		return fmt.Errorf("signature is for namespace %q, not %q", s.Namespace, namespace)
+	}
+	if s.Signature.Format == ssh.KeyAlgoRSA {
+		return errors.New("SSH signatures made with SHA-1 are not accepted")
+	}
+	hash, err := s.Hash()
+	if err != nil {
+		return err
+	}
+	d := hash.New()
+	if _, err := io.Copy(d, message); err != nil {
+		return err
+	}
+	return s.PublicKey.Verify(dataToSign(s.Namespace, s.HashAlgorithm, d.Sum(nil)), s.Signature)
+}
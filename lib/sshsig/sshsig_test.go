@@ -0,0 +1,71 @@
+package sshsig
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+// made with "ssh-keygen -Y sign -n file"
+const (
+	testPublicKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIN812sx50BOe903lmBqeYVAZX8SKon7/p/97cW/ZXUE4 test"
+	testMessage   = "hello ssh\n"
+	testSignature = `-----BEGIN SSH SIGNATURE-----
+U1NIU0lHAAAAAQAAADMAAAALc3NoLWVkMjU1MTkAAAAg3zXazHnQE573TeWYGp5hUBlfxI
+qifv+n/3txb9ldQTgAAAAEZmlsZQAAAAAAAAAGc2hhNTEyAAAAUwAAAAtzc2gtZWQyNTUx
+OQAAAEDvDZlVhmuqZxUglmaSRlZo1omePrBgEUGhAmmdPNHAQhPy+QiKcML8tuc6pB+AdV
+y1ZLISQniqmUBjJfHcFX4M
+-----END SSH SIGNATURE-----
+`
+)
+
+func TestVerifyOpenSSH(t *testing.T) {
+	pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(testPublicKey))
+	require.NoError(t, err)
+	sig, err := Parse([]byte(testSignature))
+	require.NoError(t, err)
+	assert.Equal(t, pub.Marshal(), sig.PublicKey.Marshal())
+	assert.Equal(t, "file", sig.Namespace)
+	assert.NoError(t, sig.Verify(strings.NewReader(testMessage), "file"))
+	assert.Error(t, sig.Verify(strings.NewReader(testMessage), "git"))
+	assert.Error(t, sig.Verify(strings.NewReader("goodbye\n"), "file"))
+}
+
+func TestSign(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	for _, key := range []crypto.Signer{edKey, ecKey, rsaKey} {
+		for _, hash := range []crypto.Hash{crypto.SHA256, crypto.SHA512} {
+			armored, err := Sign(key, strings.NewReader(testMessage), "git", hash)
+			require.NoError(t, err)
+			assert.True(t, bytes.HasPrefix(armored, []byte(armorBegin+"\n")))
+			sig, err := Parse(armored)
+			require.NoError(t, err)
+			sigHash, err := sig.Hash()
+			require.NoError(t, err)
+			assert.Equal(t, hash, sigHash)
+			assert.NoError(t, sig.Verify(strings.NewReader(testMessage), "git"))
+			if _, ok := key.(*rsa.PrivateKey); ok {
+				assert.Equal(t, ssh.KeyAlgoRSASHA512, sig.Signature.Format)
+			}
+		}
+	}
+	_, err = Sign(edKey, strings.NewReader(testMessage), "git", crypto.SHA1)
+	assert.Error(t, err)
+	_, err = Sign(edKey, strings.NewReader(testMessage), "", crypto.SHA256)
+	assert.Error(t, err)
+}
@@ -0,0 +1,87 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package transparency
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"io"
+	"sync"
+)
+
+// Signature is one raw signature made by a signing key
+type Signature struct {
+	Hash   crypto.Hash
+	Digest []byte
+	Value  []byte
+}
+
+// Recorder wraps a signing key and remembers each signature it makes, so that
+// they can be submitted to a transparency log regardless of the package
+// format that embeds them
+type Recorder struct {
+	crypto.Signer
+
+	mu   sync.Mutex
+	sigs []Signature
+}
+
+type contextSigner interface {
+	SignContext(context.Context, []byte, crypto.SignerOpts) ([]byte, error)
+}
+
+func NewRecorder(signer crypto.Signer) *Recorder {
+	return &Recorder{Signer: signer}
+}
+
+func (r *Recorder) Sign(rnd io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	sig, err := r.Signer.Sign(rnd, digest, opts)
+	if err == nil {
+		r.record(digest, sig, opts)
+	}
+	return sig, err
+}
+
+func (r *Recorder) SignContext(ctx context.Context, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	cs, ok := r.Signer.(contextSigner)
+	if !ok {
+		return r.Sign(rand.Reader, digest, opts)
+	}
+	sig, err := cs.SignContext(ctx, digest, opts)
+	if err == nil {
+		r.record(digest, sig, opts)
+	}
+	return sig, err
+}
+
+func (r *Recorder) record(digest, sig []byte, opts crypto.SignerOpts) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sigs = append(r.sigs, Signature{
+		Hash:   opts.HashFunc(),
+		Digest: append([]byte(nil), digest...),
+		Value:  append([]byte(nil), sig...),
+	})
+}
+
+// Signatures returns the signatures made so far
+func (r *Recorder) Signatures() []Signature {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Signature(nil), r.sigs...)
+}
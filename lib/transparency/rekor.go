@@ -0,0 +1,217 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package transparency submits signatures to a Rekor-compatible transparency
+// log as "hashedrekord" entries, which record the digest that was signed, the
+// signature, and the signer's certificate or public key.
+package transparency
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mind-security/relic/v8/config"
+	"github.com/mind-security/relic/v8/lib/x509tools"
+)
+
+const defaultTimeout = 30 * time.Second
+
+const entriesPath = "/api/v1/log/entries"
+
+// Entry is the log's record of a submitted signature
+type Entry struct {
+	UUID                 string          `json:"uuid"`
+	LogIndex             int64           `json:"logIndex"`
+	IntegratedTime       int64           `json:"integratedTime"`
+	LogID                string          `json:"logID"`
+	InclusionProof       json.RawMessage `json:"inclusionProof,omitempty"`
+	SignedEntryTimestamp string          `json:"signedEntryTimestamp,omitempty"`
+}
+
+type Client struct {
+	conf   *config.TransparencyConfig
+	client *http.Client
+}
+
+func NewClient(conf *config.TransparencyConfig) (*Client, error) {
+	if conf.URL == "" {
+		return nil, errors.New("transparency.url is not set")
+	}
+	tlsconf := &tls.Config{}
+	if err := x509tools.LoadCertPool(conf.CaCert, tlsconf); err != nil {
+		return nil, err
+	}
+	proxy, err := config.ProxyFunc(conf.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	timeout := defaultTimeout
+	if conf.Timeout != 0 {
+		timeout = time.Second * time.Duration(conf.Timeout)
+	}
+	client := &http.Client{
+		Timeout:   timeout,
+		Transport: &http.Transport{Proxy: proxy, TLSClientConfig: tlsconf},
+	}
+	return &Client{conf: conf, client: client}, nil
+}
+
+// Submit adds a signature to the log. The signer is identified by cert if it
+// is not nil, otherwise by pub.
+func (c *Client) Submit(ctx context.Context, cert *x509.Certificate, pub crypto.PublicKey, sig Signature) (*Entry, error) {
+	body, err := hashedRekord(cert, pub, sig)
+	if err != nil {
+		return nil, err
+	}
+	url := strings.TrimSuffix(c.conf.URL, "/") + entriesPath
+	resp, err := c.do(ctx, http.MethodPost, url, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusConflict {
+		// already logged, so fetch the existing entry
+		loc := resp.Header.Get("Location")
+		if loc == "" {
+			return nil, errors.New("transparency log reported a duplicate entry without its location")
+		}
+		if strings.HasPrefix(loc, "/") {
+			loc = strings.TrimSuffix(c.conf.URL, "/") + loc
+		}
+		resp.Body.Close()
+		resp, err = c.do(ctx, http.MethodGet, loc, nil)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+	}
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("transparency log: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return parseEntry(resp.Body)
+}
+
+func (c *Client) do(ctx context.Context, method, url string, body []byte) (*http.Response, error) {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, r)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.conf.AuthToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.conf.AuthToken)
+	}
+	return c.client.Do(req)
+}
+
+var rekorHashNames = map[crypto.Hash]string{
+	crypto.SHA256: "sha256",
+	crypto.SHA384: "sha384",
+	crypto.SHA512: "sha512",
+}
+
+// Supported returns true if the log can accept a signature made with the
+// given digest algorithm
+func Supported(hash crypto.Hash) bool {
+	return rekorHashNames[hash] != ""
+}
+
+func hashedRekord(cert *x509.Certificate, pub crypto.PublicKey, sig Signature) ([]byte, error) {
+	hashName := rekorHashNames[sig.Hash]
+	if hashName == "" {
+		return nil, fmt.Errorf("transparency log does not support digest %s", sig.Hash)
+	}
+	var block *pem.Block
+	if cert != nil {
+		block = &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}
+	} else {
+		der, err := x509.MarshalPKIXPublicKey(pub)
+		if err != nil {
+			return nil, err
+		}
+		block = &pem.Block{Type: "PUBLIC KEY", Bytes: der}
+	}
+	entry := map[string]interface{}{
+		"apiVersion": "0.0.1",
+		"kind":       "hashedrekord",
+		"spec": map[string]interface{}{
+			"signature": map[string]interface{}{
+				"content": base64.StdEncoding.EncodeToString(sig.Value),
+				"publicKey": map[string]interface{}{
+					"content": base64.StdEncoding.EncodeToString(pem.EncodeToMemory(block)),
+				},
+			},
+			"data": map[string]interface{}{
+				"hash": map[string]interface{}{
+					"algorithm": hashName,
+					"value":     hex.EncodeToString(sig.Digest),
+				},
+			},
+		},
+	}
+	return json.Marshal(entry)
+}
+
+// parseEntry parses a log response, which is an object keyed by entry UUID
+func parseEntry(r io.Reader) (*Entry, error) {
+	var resp map[string]struct {
+		LogIndex       int64  `json:"logIndex"`
+		IntegratedTime int64  `json:"integratedTime"`
+		LogID          string `json:"logID"`
+		Verification   struct {
+			InclusionProof       json.RawMessage `json:"inclusionProof"`
+			SignedEntryTimestamp string          `json:"signedEntryTimestamp"`
+		} `json:"verification"`
+	}
+	if err := json.NewDecoder(r).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("transparency log: parsing response: %w", err)
+	}
+	if len(resp) != 1 {
+		return nil, fmt.Errorf("transparency log: expected 1 entry in response, got %d", len(resp))
+	}
+	var entry *Entry
+	for uuid, e := range resp {
+		entry = &Entry{
+			UUID:                 uuid,
+			LogIndex:             e.LogIndex,
+			IntegratedTime:       e.IntegratedTime,
+			LogID:                e.LogID,
+			InclusionProof:       e.Verification.InclusionProof,
+			SignedEntryTimestamp: e.Verification.SignedEntryTimestamp,
+		}
+	}
+	return entry, nil
+}
@@ -0,0 +1,79 @@
+package transparency
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mind-security/relic/v8/config"
+)
+
+func TestRecorder(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	rec := NewRecorder(key)
+	digest := sha256.Sum256([]byte("hello"))
+	sig, err := rec.Sign(rand.Reader, digest[:], crypto.SHA256)
+	require.NoError(t, err)
+	sigs := rec.Signatures()
+	require.Len(t, sigs, 1)
+	assert.Equal(t, crypto.SHA256, sigs[0].Hash)
+	assert.Equal(t, digest[:], sigs[0].Digest)
+	assert.Equal(t, sig, sigs[0].Value)
+	assert.True(t, ecdsa.VerifyASN1(&key.PublicKey, sigs[0].Digest, sigs[0].Value))
+}
+
+func TestSubmitDuplicate(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		switch req.Method {
+		case http.MethodPost:
+			rw.Header().Set("Location", "/api/v1/log/entries/abc")
+			rw.WriteHeader(http.StatusConflict)
+		case http.MethodGet:
+			assert.Equal(t, "/api/v1/log/entries/abc", req.URL.Path)
+			fmt.Fprint(rw, `{"abc": {"logIndex": 42, "integratedTime": 1700000000, "logID": "c0ffee", "verification": {"signedEntryTimestamp": "U0VU"}}}`)
+		}
+	}))
+	defer srv.Close()
+	client, err := NewClient(&config.TransparencyConfig{URL: srv.URL + "/"})
+	require.NoError(t, err)
+	digest := sha256.Sum256([]byte("hello"))
+	entry, err := client.Submit(context.Background(), nil, key.Public(), Signature{Hash: crypto.SHA256, Digest: digest[:], Value: []byte("sig")})
+	require.NoError(t, err)
+	assert.Equal(t, "abc", entry.UUID)
+	assert.EqualValues(t, 42, entry.LogIndex)
+	assert.Equal(t, "U0VU", entry.SignedEntryTimestamp)
+
+	_, err = client.Submit(context.Background(), nil, key.Public(), Signature{Hash: crypto.SHA1, Digest: digest[:20]})
+	assert.Error(t, err)
+}
+
+func TestSubmitProxy(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	// the log doesn't resolve, so the entry only arrives if it goes through the proxy
+	proxy := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "rekor.invalid", req.URL.Host)
+		rw.WriteHeader(http.StatusCreated)
+		fmt.Fprint(rw, `{"abc": {"logIndex": 7}}`)
+	}))
+	defer proxy.Close()
+	client, err := NewClient(&config.TransparencyConfig{URL: "http://rekor.invalid", Proxy: proxy.URL})
+	require.NoError(t, err)
+	digest := sha256.Sum256([]byte("hello"))
+	entry, err := client.Submit(context.Background(), nil, key.Public(), Signature{Hash: crypto.SHA256, Digest: digest[:], Value: []byte("sig")})
+	require.NoError(t, err)
+	assert.EqualValues(t, 7, entry.LogIndex)
+}
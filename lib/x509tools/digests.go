@@ -18,6 +18,8 @@ package x509tools
 
 import (
 	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
 	"crypto/x509/pkix"
 	"encoding/asn1"
 	"strings"
@@ -58,6 +60,37 @@ var (
 	once         sync.Once
 )
 
+// IsDeprecatedHash returns true for digest algorithms that are too weak to
+// make new signatures with, but may still be found in old ones
+func IsDeprecatedHash(hash crypto.Hash) bool {
+	switch hash {
+	case crypto.MD4, crypto.MD5, crypto.SHA1, crypto.MD5SHA1, crypto.RIPEMD160:
+		return true
+	default:
+		return false
+	}
+}
+
+// HashForKey returns a digest algorithm matching the strength of a public key:
+// SHA-384 or SHA-512 for the larger elliptic curves, SHA-384 for RSA keys of
+// 4096 bits or more, and SHA-256 for everything else.
+func HashForKey(pub crypto.PublicKey) crypto.Hash {
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		switch bits := k.Curve.Params().BitSize; {
+		case bits > 384:
+			return crypto.SHA512
+		case bits > 256:
+			return crypto.SHA384
+		}
+	case *rsa.PublicKey:
+		if k.N.BitLen() >= 4096 {
+			return crypto.SHA384
+		}
+	}
+	return crypto.SHA256
+}
+
 func HashShortName(hash crypto.Hash) string {
 	return normalName(HashNames[hash])
 }
@@ -0,0 +1,34 @@
+package x509tools
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashForKey(t *testing.T) {
+	rsaKey := func(bits uint) *rsa.PublicKey {
+		return &rsa.PublicKey{N: new(big.Int).Lsh(big.NewInt(1), bits-1), E: 65537}
+	}
+	for _, tc := range []struct {
+		name string
+		pub  crypto.PublicKey
+		hash crypto.Hash
+	}{
+		{"rsa2048", rsaKey(2048), crypto.SHA256},
+		{"rsa3072", rsaKey(3072), crypto.SHA256},
+		{"rsa4096", rsaKey(4096), crypto.SHA384},
+		{"p256", &ecdsa.PublicKey{Curve: elliptic.P256()}, crypto.SHA256},
+		{"p384", &ecdsa.PublicKey{Curve: elliptic.P384()}, crypto.SHA384},
+		{"p521", &ecdsa.PublicKey{Curve: elliptic.P521()}, crypto.SHA512},
+		{"ed25519", ed25519.PublicKey(make([]byte, ed25519.PublicKeySize)), crypto.SHA256},
+	} {
+		assert.Equal(t, tc.hash, HashForKey(tc.pub), tc.name)
+	}
+}
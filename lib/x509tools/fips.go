@@ -0,0 +1,68 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package x509tools
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/tls"
+	"errors"
+	"fmt"
+)
+
+// FIPSCipherSuites are the TLS 1.2 cipher suites negotiated in FIPS mode
+var FIPSCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+}
+
+// RestrictFIPS limits a TLS configuration to FIPS-approved protocol versions,
+// cipher suites and curves
+func RestrictFIPS(tconf *tls.Config) {
+	// the TLS 1.3 suites can't be chosen and include ChaCha20-Poly1305
+	tconf.MinVersion = tls.VersionTLS12
+	tconf.MaxVersion = tls.VersionTLS12
+	tconf.CipherSuites = FIPSCipherSuites
+	tconf.CurvePreferences = []tls.CurveID{tls.CurveP256, tls.CurveP384, tls.CurveP521}
+}
+
+// CheckFIPSKey returns an error if a TLS certificate key can't be used in FIPS
+// mode
+func CheckFIPSKey(pub crypto.PublicKey) error {
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		if k.N.BitLen() < 2048 {
+			return fmt.Errorf("%d-bit RSA keys are not FIPS-approved, at least 2048 bits are required", k.N.BitLen())
+		}
+		return nil
+	case *ecdsa.PublicKey:
+		switch k.Curve {
+		case elliptic.P256(), elliptic.P384(), elliptic.P521():
+			return nil
+		}
+		return fmt.Errorf("ECDSA curve %s is not FIPS-approved", k.Curve.Params().Name)
+	case ed25519.PublicKey:
+		return errors.New("ed25519 keys can't be used for TLS in FIPS mode, use ECDSA or RSA")
+	default:
+		return fmt.Errorf("%T keys are not FIPS-approved", pub)
+	}
+}
@@ -0,0 +1,66 @@
+package x509tools
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckFIPSKey(t *testing.T) {
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	assert.NoError(t, CheckFIPSKey(p256.Public()))
+	p224, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	require.NoError(t, err)
+	assert.ErrorContains(t, CheckFIPSKey(p224.Public()), "P-224")
+	rsa1k, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	assert.ErrorContains(t, CheckFIPSKey(rsa1k.Public()), "1024-bit")
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	assert.ErrorContains(t, CheckFIPSKey(edPub), "ed25519")
+}
+
+func TestRestrictFIPS(t *testing.T) {
+	tconf := &tls.Config{MinVersion: tls.VersionTLS10}
+	RestrictFIPS(tconf)
+	assert.Equal(t, uint16(tls.VersionTLS12), tconf.MinVersion)
+	assert.Equal(t, uint16(tls.VersionTLS12), tconf.MaxVersion)
+	for _, id := range tconf.CipherSuites {
+		name := tls.CipherSuiteName(id)
+		assert.Contains(t, name, "_GCM_", name)
+	}
+	assert.NotContains(t, tconf.CurvePreferences, tls.X25519)
+}
+
+func TestParseTLSOptions(t *testing.T) {
+	opts, err := ParseTLSOptions("", nil, false)
+	require.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS12), opts.MinVersion)
+	assert.Empty(t, opts.CipherSuites)
+	opts, err = ParseTLSOptions("1.2", []string{"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384", "TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256"}, false)
+	require.NoError(t, err)
+	assert.Equal(t, []uint16{tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384, tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256}, opts.CipherSuites)
+	tconf := &tls.Config{MinVersion: tls.VersionTLS10}
+	opts.Apply(tconf)
+	assert.Equal(t, uint16(tls.VersionTLS12), tconf.MinVersion)
+	assert.Equal(t, opts.CipherSuites, tconf.CipherSuites)
+
+	_, err = ParseTLSOptions("1.1", nil, false)
+	assert.ErrorContains(t, err, "unsupported version")
+	_, err = ParseTLSOptions("1.2", []string{"TLS_RSA_WITH_RC4_128_SHA"}, false)
+	assert.ErrorContains(t, err, "insecure cipher suite")
+	_, err = ParseTLSOptions("1.3", []string{"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384"}, false)
+	assert.ErrorContains(t, err, "not configurable")
+	_, err = ParseTLSOptions("1.3", nil, true)
+	assert.ErrorContains(t, err, "FIPS")
+	_, err = ParseTLSOptions("", []string{"TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256"}, true)
+	assert.ErrorContains(t, err, "not FIPS-approved")
+}
@@ -56,6 +56,14 @@ var extKeyUsageNames = map[x509.ExtKeyUsage]string{
 	x509.ExtKeyUsageMicrosoftKernelCodeSigning:     "msKernCode",
 }
 
+// ExtKeyUsageName returns a short name for an extended key usage
+func ExtKeyUsageName(u x509.ExtKeyUsage) string {
+	if name := extKeyUsageNames[u]; name != "" {
+		return name
+	}
+	return fmt.Sprintf("unknown(%d)", u)
+}
+
 var knownExtensions = []asn1.ObjectIdentifier{
 	asn1.ObjectIdentifier{2, 5, 29, 14},               // oidExtensionSubjectKeyId
 	asn1.ObjectIdentifier{2, 5, 29, 15},               // oidExtensionKeyUsage
@@ -0,0 +1,63 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package x509tools
+
+import (
+	"crypto/x509"
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// ParseSpiffeID checks that id is a well-formed SPIFFE ID and returns it in
+// canonical form, with the trust domain in lowercase
+func ParseSpiffeID(id string) (string, error) {
+	u, err := url.Parse(id)
+	if err != nil {
+		return "", err
+	}
+	return spiffeURI(u)
+}
+
+func spiffeURI(u *url.URL) (string, error) {
+	switch {
+	case !strings.EqualFold(u.Scheme, "spiffe"):
+		return "", errors.New("SPIFFE ID must use the spiffe scheme")
+	case u.Host == "":
+		return "", errors.New("SPIFFE ID is missing the trust domain")
+	case u.User != nil || u.Port() != "":
+		return "", errors.New("SPIFFE ID trust domain can't have a user or port")
+	case u.RawQuery != "" || u.Fragment != "":
+		return "", errors.New("SPIFFE ID can't have a query or fragment")
+	case u.Path != "" && (!strings.HasPrefix(u.Path, "/") || strings.HasSuffix(u.Path, "/")):
+		return "", errors.New("SPIFFE ID path must start and not end with a slash")
+	}
+	return "spiffe://" + strings.ToLower(u.Host) + u.Path, nil
+}
+
+// SpiffeID returns the SPIFFE ID of an X.509 SVID, or "" if the certificate
+// isn't one. An SVID holds exactly one URI SAN.
+func SpiffeID(cert *x509.Certificate) string {
+	if len(cert.URIs) != 1 {
+		return ""
+	}
+	id, err := spiffeURI(cert.URIs[0])
+	if err != nil {
+		return ""
+	}
+	return id
+}
@@ -0,0 +1,79 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package x509tools
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// TLSOptions restricts the protocol versions and cipher suites of a TLS
+// configuration
+type TLSOptions struct {
+	MinVersion   uint16
+	CipherSuites []uint16
+}
+
+// ParseTLSOptions validates a minimum TLS version, "1.2" (the default if
+// empty) or "1.3", and an optional list of TLS 1.2 cipher suite names as
+// spelled by the crypto/tls package. In FIPS mode only TLS 1.2 and the suites
+// in FIPSCipherSuites are allowed.
+func ParseTLSOptions(minVersion string, suites []string, fips bool) (TLSOptions, error) {
+	var opts TLSOptions
+	switch minVersion {
+	case "", "1.2":
+		opts.MinVersion = tls.VersionTLS12
+	case "1.3":
+		if fips {
+			return opts, errors.New("mintlsversion: FIPS mode only allows TLS 1.2")
+		}
+		opts.MinVersion = tls.VersionTLS13
+	default:
+		return opts, fmt.Errorf("mintlsversion: unsupported version %q, expected 1.2 or 1.3", minVersion)
+	}
+	if len(suites) == 0 {
+		return opts, nil
+	} else if opts.MinVersion == tls.VersionTLS13 {
+		return opts, errors.New("ciphersuites: TLS 1.3 cipher suites are not configurable")
+	}
+	byName := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		byName[suite.Name] = suite.ID
+	}
+	for _, name := range suites {
+		id, ok := byName[name]
+		if !ok {
+			return opts, fmt.Errorf("ciphersuites: unknown or insecure cipher suite %q", name)
+		} else if fips && !slices.Contains(FIPSCipherSuites, id) {
+			return opts, fmt.Errorf("ciphersuites: %s is not FIPS-approved", name)
+		}
+		opts.CipherSuites = append(opts.CipherSuites, id)
+	}
+	return opts, nil
+}
+
+// Apply the options to a TLS configuration. Zero options leave it unchanged.
+func (o TLSOptions) Apply(tconf *tls.Config) {
+	if o.MinVersion != 0 {
+		tconf.MinVersion = o.MinVersion
+	}
+	if len(o.CipherSuites) != 0 {
+		tconf.CipherSuites = o.CipherSuites
+	}
+}
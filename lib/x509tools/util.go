@@ -19,6 +19,7 @@ package x509tools
 import (
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
@@ -100,6 +101,9 @@ func SameKey(pub1, pub2 interface{}) bool {
 	case *ecdsa.PublicKey:
 		key2, ok := pub2.(*ecdsa.PublicKey)
 		return ok && key1.X.Cmp(key2.X) == 0 && key1.Y.Cmp(key2.Y) == 0
+	case ed25519.PublicKey:
+		key2, ok := pub2.(ed25519.PublicKey)
+		return ok && key1.Equal(key2)
 	default:
 		return false
 	}
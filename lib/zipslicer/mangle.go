@@ -24,6 +24,9 @@ import (
 )
 
 type Mangler struct {
+	// ModTime is applied to new files. If zero then the current time is used.
+	ModTime time.Time
+
 	outz          *Directory
 	patch         *binpatch.PatchSet
 	newcontents   bytes.Buffer
@@ -66,7 +69,11 @@ func (d *Directory) Mangle(callback MangleFunc) (*Mangler, error) {
 // Add a new file to a zip mangler
 func (m *Mangler) NewFile(name string, contents []byte) error {
 	deflate := len(contents) != 0
-	_, err := m.outz.NewFile(name, nil, contents, &m.newcontents, time.Now(), deflate, true)
+	mtime := m.ModTime
+	if mtime.IsZero() {
+		mtime = time.Now()
+	}
+	_, err := m.outz.NewFile(name, nil, contents, &m.newcontents, mtime, deflate, true)
 	return err
 }
 
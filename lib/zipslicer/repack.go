@@ -0,0 +1,192 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package zipslicer
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ZipEpoch is the earliest time representable in a zip file
+var ZipEpoch = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+const (
+	unixTypeMask    = 0170000
+	unixTypeSymlink = 0120000
+)
+
+// ReproducibleTime parses a SOURCE_DATE_EPOCH value. If it is empty then
+// ZipEpoch is returned.
+func ReproducibleTime(sourceDateEpoch string) (time.Time, error) {
+	if sourceDateEpoch == "" {
+		return ZipEpoch, nil
+	}
+	secs, err := strconv.ParseInt(sourceDateEpoch, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid SOURCE_DATE_EPOCH: %w", err)
+	}
+	t := time.Unix(secs, 0).UTC()
+	if t.Before(ZipEpoch) {
+		t = ZipEpoch
+	}
+	return t, nil
+}
+
+type RepackOptions struct {
+	// Reproducible sorts entries by name, applies ModTime to every entry, and
+	// normalizes permissions. Symbolic and hard links are rejected.
+	Reproducible bool
+	// ModTime is applied to all entries in reproducible mode. If zero then
+	// ZipEpoch is used.
+	ModTime time.Time
+}
+
+func (o RepackOptions) modTime() time.Time {
+	if o.ModTime.IsZero() {
+		return ZipEpoch
+	}
+	return o.ModTime.UTC().Truncate(2 * time.Second)
+}
+
+// Repack writes the files from d into a new zip archive. Compressed contents
+// are copied as-is. In reproducible mode the output depends only on the names
+// and contents of the entries.
+func (d *Directory) Repack(w io.Writer, opts RepackOptions) error {
+	files := append([]*File(nil), d.File...)
+	if opts.Reproducible {
+		sort.SliceStable(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	}
+	zw := zip.NewWriter(w)
+	for _, f := range files {
+		fh := &zip.FileHeader{
+			Name:               f.Name,
+			Method:             f.Method,
+			CRC32:              f.CRC32,
+			CompressedSize64:   f.CompressedSize,
+			UncompressedSize64: f.UncompressedSize,
+		}
+		if opts.Reproducible {
+			if (f.ExternalAttrs>>16)&unixTypeMask == unixTypeSymlink {
+				return fmt.Errorf("%s: symbolic links are not allowed in reproducible archives", f.Name)
+			}
+			// CreateRaw doesn't convert Modified, so set the DOS fields directly
+			fh.SetModTime(opts.modTime()) //nolint:staticcheck
+			if strings.HasSuffix(f.Name, "/") {
+				fh.SetMode(os.ModeDir | 0755)
+			} else {
+				fh.SetMode(0644)
+			}
+		} else {
+			fh.ModifiedTime = f.ModifiedTime
+			fh.ModifiedDate = f.ModifiedDate
+			fh.Comment = string(f.Comment)
+			fh.CreatorVersion = f.CreatorVersion
+			fh.ExternalAttrs = f.ExternalAttrs
+		}
+		raw, err := f.rawContents()
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.Name, err)
+		}
+		fw, err := zw.CreateRaw(fh)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(fw, raw); err != nil {
+			return fmt.Errorf("%s: %w", f.Name, err)
+		}
+	}
+	return zw.Close()
+}
+
+// rawContents returns the still-compressed contents of the file
+func (f *File) rawContents() (io.Reader, error) {
+	if f.compd != nil {
+		return bytes.NewReader(f.compd), nil
+	}
+	if err := f.readLocalHeader(); err != nil {
+		return nil, err
+	}
+	pos := int64(f.Offset) + fileHeaderLen + int64(f.lfh.FilenameLen) + int64(f.lfh.ExtraLen)
+	return io.NewSectionReader(f.r, pos, int64(f.CompressedSize)), nil
+}
+
+// RepackTar copies a tar archive from r to w. In reproducible mode, entries
+// are sorted by name and their timestamps, ownership and permissions are
+// normalized. Entries are buffered in memory in order to sort them.
+func RepackTar(r io.Reader, w io.Writer, opts RepackOptions) error {
+	type entry struct {
+		hdr  *tar.Header
+		data []byte
+	}
+	var entries []entry
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+		if opts.Reproducible {
+			switch hdr.Typeflag {
+			case tar.TypeReg, tar.TypeDir:
+			case tar.TypeSymlink, tar.TypeLink:
+				return fmt.Errorf("%s: links are not allowed in reproducible archives", hdr.Name)
+			default:
+				return fmt.Errorf("%s: unsupported entry type %q in reproducible archive", hdr.Name, hdr.Typeflag)
+			}
+			mode := int64(0644)
+			if hdr.Typeflag == tar.TypeDir || hdr.Mode&0111 != 0 {
+				mode = 0755
+			}
+			hdr = &tar.Header{
+				Typeflag: hdr.Typeflag,
+				Name:     hdr.Name,
+				Size:     hdr.Size,
+				Mode:     mode,
+				ModTime:  opts.modTime(),
+				Format:   tar.FormatPAX,
+			}
+		}
+		entries = append(entries, entry{hdr, data})
+	}
+	if opts.Reproducible {
+		sort.SliceStable(entries, func(i, j int) bool { return entries[i].hdr.Name < entries[j].hdr.Name })
+	}
+	tw := tar.NewWriter(w)
+	for _, e := range entries {
+		if err := tw.WriteHeader(e.hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(e.data); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
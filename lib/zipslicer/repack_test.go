@@ -0,0 +1,113 @@
+package zipslicer
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type member struct {
+	name, contents string
+}
+
+var signedMembers = []member{
+	{"META-INF/MANIFEST.MF", "Manifest-Version: 1.0\r\n\r\n"},
+	{"META-INF/RELIC.SF", "Signature-Version: 1.0\r\n\r\n"},
+	{"com/example/Main.class", "\xca\xfe\xba\xbe"},
+}
+
+func makeZip(t *testing.T, members []member, mtime time.Time, mode os.FileMode) *Directory {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, m := range members {
+		fh := &zip.FileHeader{Name: m.name, Method: zip.Deflate, Modified: mtime}
+		fh.SetMode(mode)
+		w, err := zw.CreateHeader(fh)
+		require.NoError(t, err)
+		_, err = io.WriteString(w, m.contents)
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	d, err := Read(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	return d
+}
+
+func TestRepackReproducible(t *testing.T) {
+	reversed := make([]member, len(signedMembers))
+	for i, m := range signedMembers {
+		reversed[len(signedMembers)-1-i] = m
+	}
+	d1 := makeZip(t, signedMembers, time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC), 0600)
+	d2 := makeZip(t, reversed, time.Date(2023, 6, 7, 8, 9, 10, 0, time.UTC), 0755)
+	epoch, err := ReproducibleTime("1700000000")
+	require.NoError(t, err)
+	opts := RepackOptions{Reproducible: true, ModTime: epoch}
+
+	var out1, out2 bytes.Buffer
+	require.NoError(t, d1.Repack(&out1, opts))
+	require.NoError(t, d2.Repack(&out2, opts))
+	assert.Equal(t, out1.Bytes(), out2.Bytes())
+
+	// result is a valid zip with the original contents in sorted order
+	zr, err := zip.NewReader(bytes.NewReader(out1.Bytes()), int64(out1.Len()))
+	require.NoError(t, err)
+	require.Len(t, zr.File, len(signedMembers))
+	for i, f := range zr.File {
+		assert.Equal(t, signedMembers[i].name, f.Name)
+		assert.True(t, f.Modified.Equal(epoch), "%s != %s", f.Modified, epoch)
+		r, err := f.Open()
+		require.NoError(t, err)
+		contents, err := io.ReadAll(r)
+		require.NoError(t, err)
+		assert.Equal(t, signedMembers[i].contents, string(contents))
+	}
+
+	// without reproducible mode the differences are preserved
+	out1.Reset()
+	out2.Reset()
+	require.NoError(t, d1.Repack(&out1, RepackOptions{}))
+	require.NoError(t, d2.Repack(&out2, RepackOptions{}))
+	assert.NotEqual(t, out1.Bytes(), out2.Bytes())
+}
+
+func TestRepackRejectsSymlinks(t *testing.T) {
+	d := makeZip(t, signedMembers[:1], ZipEpoch, os.ModeSymlink|0777)
+	assert.Error(t, d.Repack(io.Discard, RepackOptions{Reproducible: true}))
+}
+
+func makeTar(t *testing.T, members []member, mtime time.Time) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, m := range members {
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name:    m.name,
+			Mode:    0600,
+			Size:    int64(len(m.contents)),
+			ModTime: mtime,
+			Uid:     1000,
+			Uname:   "builder",
+		}))
+		_, err := io.WriteString(tw, m.contents)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	return buf.Bytes()
+}
+
+func TestRepackTarReproducible(t *testing.T) {
+	t1 := makeTar(t, signedMembers, time.Unix(1600000000, 0))
+	t2 := makeTar(t, []member{signedMembers[2], signedMembers[0], signedMembers[1]}, time.Unix(1650000000, 0))
+	var out1, out2 bytes.Buffer
+	opts := RepackOptions{Reproducible: true}
+	require.NoError(t, RepackTar(bytes.NewReader(t1), &out1, opts))
+	require.NoError(t, RepackTar(bytes.NewReader(t2), &out2, opts))
+	assert.Equal(t, out1.Bytes(), out2.Bytes())
+}
@@ -18,6 +18,8 @@ package zipslicer
 
 import (
 	"archive/tar"
+	"bytes"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
@@ -71,6 +73,31 @@ func tarAddStream(tw *tar.Writer, r io.Reader, name string, size int64) error {
 	return nil
 }
 
+// CheckZipTar checks that the start of a tar stream looks like it was produced
+// by ZipToTar, without reading the rest of it
+func CheckZipTar(head []byte) error {
+	tr := tar.NewReader(bytes.NewReader(head))
+	hdr, err := tr.Next()
+	if err != nil {
+		return fmt.Errorf("invalid tarzip: %w", err)
+	} else if hdr.Name != TarMemberCD {
+		return errors.New("invalid tarzip: central directory must come first")
+	} else if hdr.Size < directoryEndLen {
+		return errors.New("invalid tarzip: central directory is too short")
+	}
+	var sig [4]byte
+	if _, err := io.ReadFull(tr, sig[:]); err != nil {
+		// a truncated stream fails later when it is read in full
+		return nil
+	}
+	switch binary.LittleEndian.Uint32(sig[:]) {
+	case directoryHeaderSignature, directoryEndSignature, directory64EndSignature:
+		return nil
+	default:
+		return errors.New("invalid tarzip: bad central directory signature")
+	}
+}
+
 // Read a tar stream produced by ZipToTar and return the zip directory. Files
 // must be read from the zip in order or an error will be raised.
 func ReadZipTar(r io.Reader) (*Directory, error) {
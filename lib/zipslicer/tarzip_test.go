@@ -0,0 +1,37 @@
+package zipslicer
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckZipTar(t *testing.T) {
+	var zbuf bytes.Buffer
+	zw := zip.NewWriter(&zbuf)
+	_, err := zw.Create("hello.txt")
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	path := filepath.Join(t.TempDir(), "test.zip")
+	require.NoError(t, os.WriteFile(path, zbuf.Bytes(), 0600))
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	var tbuf bytes.Buffer
+	require.NoError(t, ZipToTar(f, &tbuf))
+	tarzip := tbuf.Bytes()
+
+	assert.NoError(t, CheckZipTar(tarzip))
+	assert.NoError(t, CheckZipTar(tarzip[:600]))
+	assert.Error(t, CheckZipTar(zbuf.Bytes()))
+	assert.Error(t, CheckZipTar([]byte("garbage")))
+	// central directory replaced with something else
+	broken := bytes.Clone(tarzip)
+	copy(broken[512:], "XXXX")
+	assert.ErrorContains(t, CheckZipTar(broken), "bad central directory signature")
+}
@@ -37,14 +37,19 @@ import (
 	_ "github.com/mind-security/relic/v8/signers/deb"
 	_ "github.com/mind-security/relic/v8/signers/dmg"
 	_ "github.com/mind-security/relic/v8/signers/jar"
+	_ "github.com/mind-security/relic/v8/signers/jws"
 	_ "github.com/mind-security/relic/v8/signers/macho"
 	_ "github.com/mind-security/relic/v8/signers/msi"
+	_ "github.com/mind-security/relic/v8/signers/notation"
 	_ "github.com/mind-security/relic/v8/signers/pecoff"
 	_ "github.com/mind-security/relic/v8/signers/pgp"
 	_ "github.com/mind-security/relic/v8/signers/pkcs"
 	_ "github.com/mind-security/relic/v8/signers/ps"
 	_ "github.com/mind-security/relic/v8/signers/rpm"
+	_ "github.com/mind-security/relic/v8/signers/sshsig"
+	_ "github.com/mind-security/relic/v8/signers/tuf"
 	_ "github.com/mind-security/relic/v8/signers/vsix"
+	_ "github.com/mind-security/relic/v8/signers/wasm"
 	_ "github.com/mind-security/relic/v8/signers/xap"
 	_ "github.com/mind-security/relic/v8/signers/xar"
 )
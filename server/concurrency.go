@@ -0,0 +1,182 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package server
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/mind-security/relic/v8/config"
+	"github.com/mind-security/relic/v8/internal/httperror"
+)
+
+// priorityHeader lets a client lower the priority of its own requests, for
+// example to run a batch job behind interactive signing
+const priorityHeader = "X-Relic-Priority"
+
+// defaultPriorityAging is how often a queued request gains a point of
+// priority, so that low-priority requests are not starved
+const defaultPriorityAging = 10 * time.Second
+
+var (
+	metricTokenBusy = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "token_busy_rejected_total",
+		Help: "Number of signing requests rejected because the token was at its concurrency limit",
+	}, []string{"token"})
+	metricTokenQueue = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "token_queue_depth",
+		Help: "Number of signing requests waiting for the token's concurrency limit",
+	}, []string{"token"})
+)
+
+// tokenLimiter bounds the number of in-flight signing requests against a
+// single token, to protect hardware with a small session pool. Requests that
+// have to wait are dispatched highest priority first, and in arrival order
+// among equals.
+type tokenLimiter struct {
+	name   string
+	max    int
+	reject bool
+	aging  time.Duration
+
+	mu      sync.Mutex
+	inUse   int
+	waiting []*limitWaiter
+}
+
+type limitWaiter struct {
+	priority int
+	queued   time.Time
+	ready    chan struct{}
+}
+
+func newTokenLimiter(name string, maxConcurrent int, reject bool, aging time.Duration) *tokenLimiter {
+	if aging <= 0 {
+		aging = defaultPriorityAging
+	}
+	return &tokenLimiter{
+		name:   name,
+		max:    maxConcurrent,
+		reject: reject,
+		aging:  aging,
+	}
+}
+
+// acquire waits for a free slot, or fails immediately if the token is
+// configured to reject excess requests. The returned function releases the
+// slot.
+func (l *tokenLimiter) acquire(ctx context.Context, priority int) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	l.mu.Lock()
+	if l.inUse < l.max && len(l.waiting) == 0 {
+		l.inUse++
+		l.mu.Unlock()
+		return l.release, nil
+	} else if l.reject {
+		l.mu.Unlock()
+		metricTokenBusy.WithLabelValues(l.name).Inc()
+		return nil, httperror.ErrTokenBusy
+	}
+	w := &limitWaiter{
+		priority: priority,
+		queued:   time.Now(),
+		ready:    make(chan struct{}),
+	}
+	l.waiting = append(l.waiting, w)
+	metricTokenQueue.WithLabelValues(l.name).Set(float64(len(l.waiting)))
+	l.mu.Unlock()
+	select {
+	case <-w.ready:
+		return l.release, nil
+	case <-ctx.Done():
+	}
+	l.mu.Lock()
+	for i, other := range l.waiting {
+		if other == w {
+			l.waiting = append(l.waiting[:i], l.waiting[i+1:]...)
+			metricTokenQueue.WithLabelValues(l.name).Set(float64(len(l.waiting)))
+			l.mu.Unlock()
+			return nil, ctx.Err()
+		}
+	}
+	l.mu.Unlock()
+	// the slot was handed over just as the request gave up, so pass it on
+	l.release()
+	return nil, ctx.Err()
+}
+
+// release hands the slot to the next waiting request, if any
+func (l *tokenLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.waiting) == 0 {
+		l.inUse--
+		return
+	}
+	// waiters are in arrival order, so ties go to the one that came first
+	now := time.Now()
+	next := 0
+	for i, w := range l.waiting {
+		if l.effective(w, now) > l.effective(l.waiting[next], now) {
+			next = i
+		}
+	}
+	w := l.waiting[next]
+	l.waiting = append(l.waiting[:next], l.waiting[next+1:]...)
+	metricTokenQueue.WithLabelValues(l.name).Set(float64(len(l.waiting)))
+	close(w.ready)
+}
+
+// effective returns a waiting request's priority, raised by one for each
+// aging interval it has spent in the queue
+func (l *tokenLimiter) effective(w *limitWaiter, now time.Time) int {
+	return w.priority + int(now.Sub(w.queued)/l.aging)
+}
+
+// requestPriority returns the highest priority of the caller's roles that
+// appear in the roles section, or 0 if none do. The client can ask for a
+// lower priority with a header, but not a higher one.
+func requestPriority(cfg *config.Config, roles []string, header string) (int, error) {
+	priority := 0
+	found := false
+	for _, role := range roles {
+		roleConf := cfg.Roles[role]
+		if roleConf == nil {
+			continue
+		}
+		if !found || roleConf.Priority > priority {
+			priority = roleConf.Priority
+			found = true
+		}
+	}
+	if header == "" {
+		return priority, nil
+	}
+	requested, err := strconv.Atoi(header)
+	if err != nil {
+		return 0, httperror.InvalidParameterError(priorityHeader, errors.New("must be an integer"))
+	}
+	return min(requested, priority), nil
+}
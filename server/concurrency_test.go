@@ -0,0 +1,156 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mind-security/relic/v8/config"
+	"github.com/mind-security/relic/v8/internal/httperror"
+)
+
+func TestTokenLimiter(t *testing.T) {
+	// unlimited
+	var none *tokenLimiter
+	release, err := none.acquire(context.Background(), 0)
+	require.NoError(t, err)
+	release()
+
+	// queued requests wait for a slot
+	l := newTokenLimiter("hsm", 1, false, 0)
+	release, err = l.acquire(context.Background(), 0)
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = l.acquire(ctx, 0)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	acquired := make(chan struct{})
+	go func() {
+		release2, err := l.acquire(context.Background(), 0)
+		if err == nil {
+			release2()
+		}
+		close(acquired)
+	}()
+	release()
+	<-acquired
+
+	// excess requests are rejected
+	l = newTokenLimiter("hsm", 1, true, 0)
+	release, err = l.acquire(context.Background(), 0)
+	require.NoError(t, err)
+	_, err = l.acquire(context.Background(), 0)
+	assert.Equal(t, httperror.ErrTokenBusy, err)
+	release()
+	release, err = l.acquire(context.Background(), 0)
+	require.NoError(t, err)
+	release()
+}
+
+// queued waits until n requests are waiting on the limiter
+func queued(t *testing.T, l *tokenLimiter, n int) {
+	require.Eventually(t, func() bool {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		return len(l.waiting) == n
+	}, time.Second, time.Millisecond)
+}
+
+func TestTokenLimiterPriority(t *testing.T) {
+	l := newTokenLimiter("hsm", 1, false, time.Hour)
+	release, err := l.acquire(context.Background(), 0)
+	require.NoError(t, err)
+	order := make(chan int, 3)
+	wait := func(priority int) {
+		go func() {
+			release, err := l.acquire(context.Background(), priority)
+			if err == nil {
+				order <- priority
+				release()
+			}
+		}()
+	}
+	// higher priority goes first, otherwise first come first served
+	wait(-5)
+	queued(t, l, 1)
+	wait(0)
+	queued(t, l, 2)
+	wait(10)
+	queued(t, l, 3)
+	release()
+	assert.Equal(t, 10, <-order)
+	assert.Equal(t, 0, <-order)
+	assert.Equal(t, -5, <-order)
+
+	// requests that wait long enough catch up
+	l = newTokenLimiter("hsm", 1, false, 10*time.Millisecond)
+	release, err = l.acquire(context.Background(), 0)
+	require.NoError(t, err)
+	wait(-1)
+	queued(t, l, 1)
+	time.Sleep(50 * time.Millisecond)
+	wait(2)
+	queued(t, l, 2)
+	release()
+	assert.Equal(t, -1, <-order)
+	assert.Equal(t, 2, <-order)
+
+	// a request that gives up leaves the queue
+	l = newTokenLimiter("hsm", 1, false, 0)
+	release, err = l.acquire(context.Background(), 0)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		_, err := l.acquire(ctx, 0)
+		done <- err
+	}()
+	queued(t, l, 1)
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+	queued(t, l, 0)
+	release()
+	release, err = l.acquire(context.Background(), 0)
+	require.NoError(t, err)
+	release()
+}
+
+func TestRequestPriority(t *testing.T) {
+	cfg := &config.Config{Roles: map[string]*config.RoleConfig{
+		"developer": {Priority: 10},
+		"batch":     {Priority: -5},
+		"windows":   {AllowedFormats: []string{"pe-coff"}},
+	}}
+	priority := func(header string, roles ...string) int {
+		p, err := requestPriority(cfg, roles, header)
+		require.NoError(t, err)
+		return p
+	}
+	assert.Equal(t, 0, priority(""))
+	assert.Equal(t, 0, priority("", "unlisted"))
+	assert.Equal(t, -5, priority("", "batch"))
+	assert.Equal(t, 10, priority("", "batch", "developer"))
+	assert.Equal(t, 0, priority("", "batch", "windows"))
+	// the header can only lower it
+	assert.Equal(t, 3, priority("3", "developer"))
+	assert.Equal(t, -5, priority("100", "batch"))
+	_, err := requestPriority(cfg, nil, "high")
+	assert.ErrorContains(t, err, "X-Relic-Priority")
+}
+
+func TestSignConcurrencyLimit(t *testing.T) {
+	env := newSignTestEnv(t)
+	l := newTokenLimiter("file", 1, true, 0)
+	env.s.limits = map[string]*tokenLimiter{"file": l}
+	release, err := l.acquire(context.Background(), 0)
+	require.NoError(t, err)
+	rec := env.signPE(t, "")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	release()
+	rec = env.signPE(t, "")
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+}
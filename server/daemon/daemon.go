@@ -32,12 +32,15 @@ import (
 
 	"github.com/mind-security/relic/v8/config"
 	"github.com/mind-security/relic/v8/internal/activation"
+	"github.com/mind-security/relic/v8/internal/otlpexport"
 	"github.com/mind-security/relic/v8/internal/zhttp"
 	"github.com/mind-security/relic/v8/lib/certloader"
 	"github.com/mind-security/relic/v8/lib/x509tools"
 	"github.com/mind-security/relic/v8/server"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rs/zerolog/log"
+
+	_ "net/http/pprof"
 )
 
 type Daemon struct {
@@ -45,14 +48,28 @@ type Daemon struct {
 	httpServer *http.Server
 	listeners  []net.Listener
 	metrics    net.Listener
+	debug      net.Listener
 	addrs      []string
 	eg         errgroup.Group
+	otlp       *otlpexport.Exporter
+
+	metricsServer   *http.Server
+	debugServer     *http.Server
+	shutdownTimeout time.Duration
 }
 
-func makeTLSConfig(config *config.Config) (*tls.Config, error) {
-	cert, err := certloader.LoadX509KeyPair(config.Server.CertFile, config.Server.KeyFile)
+func makeTLSConfig(cfg *config.Config) (*tls.Config, error) {
+	certBytes, err := config.ReadFileOrSecret(cfg.Server.CertFile)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("server.certfile: %w", err)
+	}
+	keyBytes, err := config.ReadFileOrSecret(cfg.Server.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("server.keyfile: %w", err)
+	}
+	cert, err := certloader.ParseX509KeyPair(certBytes, keyBytes)
+	if err != nil {
+		return nil, fmt.Errorf("server TLS certificate: %w", err)
 	}
 	var keyLog io.Writer
 	if klf := os.Getenv("SSLKEYLOGFILE"); klf != "" {
@@ -71,23 +88,65 @@ func makeTLSConfig(config *config.Config) (*tls.Config, error) {
 		MinVersion:               tls.VersionTLS12,
 		KeyLogWriter:             keyLog,
 	}
+	if cfg.FIPS {
+		if err := x509tools.CheckFIPSKey(cert.Leaf.PublicKey); err != nil {
+			return nil, fmt.Errorf("server TLS certificate: %w", err)
+		}
+		x509tools.RestrictFIPS(tconf)
+	}
+	cfg.Server.TLSOptions().Apply(tconf)
 	x509tools.SetKeyLogFile(tconf)
 	return tconf, nil
 }
 
-func getListener(index uint, laddr string, tconf *tls.Config) (net.Listener, error) {
+func getListener(index uint, laddr string, tconf *tls.Config, keepAlive time.Duration) (net.Listener, error) {
 	listener, err := activation.GetListener(index, "tcp", laddr)
 	if err == nil {
 		if listener.Addr().Network() != "tcp" {
 			return nil, errors.New("inherited a listener but it isn't tcp")
 		}
+		if keepAlive != 0 {
+			listener = keepAliveListener{Listener: listener, period: keepAlive}
+		}
 		listener = tls.NewListener(listener, tconf)
 	}
 	return listener, err
 }
 
+// keepAliveListener overrides the TCP keep-alive settings of accepted
+// connections so that dead clients are noticed on long-lived connections
+type keepAliveListener struct {
+	net.Listener
+	period time.Duration
+}
+
+func (l keepAliveListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if tc, ok := conn.(*net.TCPConn); ok {
+		if l.period < 0 {
+			_ = tc.SetKeepAlive(false)
+		} else {
+			_ = tc.SetKeepAlive(true)
+			_ = tc.SetKeepAlivePeriod(l.period)
+		}
+	}
+	return conn, nil
+}
+
 func New(config *config.Config, test bool) (*Daemon, error) {
-	if err := zhttp.SetupLogging(config.Server.LogLevel, config.Server.LogFile); err != nil {
+	// optionally push metrics and logs to an OTLP collector
+	otlp, err := otlpexport.New(otlpexport.Options{})
+	if err != nil {
+		return nil, fmt.Errorf("configuring OTLP export: %w", err)
+	}
+	var logWriters []io.Writer
+	if otlp != nil {
+		logWriters = append(logWriters, otlp)
+	}
+	if err := zhttp.SetupLogging(config.Server.LogLevel, config.Server.LogFile, logWriters...); err != nil {
 		return nil, fmt.Errorf("configuring logging: %w", err)
 	}
 	srv, err := server.New(config)
@@ -99,7 +158,7 @@ func New(config *config.Config, test bool) (*Daemon, error) {
 		ReadHeaderTimeout: time.Second * time.Duration(config.Server.ReadHeaderTimeout),
 		ReadTimeout:       time.Second * time.Duration(config.Server.ReadTimeout),
 		WriteTimeout:      time.Second * time.Duration(config.Server.WriteTimeout),
-		IdleTimeout:       10 * time.Second,
+		IdleTimeout:       time.Second * time.Duration(config.Server.IdleTimeout),
 	}
 	// configure TLS listener
 	if config.Server.Listen != "" {
@@ -108,7 +167,8 @@ func New(config *config.Config, test bool) (*Daemon, error) {
 			return nil, err
 		}
 		httpServer.TLSConfig = tconf
-		if err := http2.ConfigureServer(httpServer, nil); err != nil {
+		h2 := &http2.Server{MaxConcurrentStreams: config.Server.MaxConcurrentStreams}
+		if err := http2.ConfigureServer(httpServer, h2); err != nil {
 			return nil, err
 		}
 	}
@@ -122,7 +182,8 @@ func New(config *config.Config, test bool) (*Daemon, error) {
 	var index uint
 	// open TLS listener
 	if config.Server.Listen != "" {
-		listener, err := getListener(index, config.Server.Listen, httpServer.TLSConfig)
+		listener, err := getListener(index, config.Server.Listen, httpServer.TLSConfig,
+			time.Second*time.Duration(config.Server.KeepAliveInterval))
 		if err != nil {
 			return nil, err
 		}
@@ -152,12 +213,31 @@ func New(config *config.Config, test bool) (*Daemon, error) {
 		}
 		// index++
 	}
+	// open debug listener
+	var debugListener net.Listener
+	if config.Server.ListenDebug {
+		debugListener, err = net.Listen("tcp", ":0")
+		if err != nil {
+			return nil, err
+		}
+	}
 	return &Daemon{
 		server:     srv,
 		httpServer: httpServer,
 		listeners:  listeners,
 		metrics:    metricsListener,
+		debug:      debugListener,
 		addrs:      addrs,
+		otlp:       otlp,
+		metricsServer: &http.Server{
+			Handler:      promhttp.Handler(),
+			ReadTimeout:  10 * time.Second,
+			WriteTimeout: 10 * time.Second,
+			IdleTimeout:  65 * time.Second,
+		},
+		// pprof installs itself into the default handler on import
+		debugServer:     &http.Server{Handler: http.DefaultServeMux},
+		shutdownTimeout: time.Second * time.Duration(config.Server.ShutdownTimeout),
 	}, nil
 }
 
@@ -175,34 +255,61 @@ func (d *Daemon) Serve() error {
 	}
 	log.Info().Strs("urls", d.addrs).Msg("listening for requests")
 	if d.metrics != nil {
-		srv := &http.Server{
-			Handler:      promhttp.Handler(),
-			ReadTimeout:  10 * time.Second,
-			WriteTimeout: 10 * time.Second,
-			IdleTimeout:  65 * time.Second,
-		}
 		go func() {
-			err := srv.Serve(d.metrics)
-			log.Err(err).Msg("metrics listener stopped")
+			if err := d.metricsServer.Serve(d.metrics); err != http.ErrServerClosed {
+				log.Err(err).Msg("metrics listener stopped")
+			}
 		}()
 		log.Info().Str("url", fmt.Sprintf("http://%s/metrics", d.metrics.Addr())).
 			Msg("listening for metrics")
 	}
+	if d.debug != nil {
+		go func() {
+			if err := d.debugServer.Serve(d.debug); err != http.ErrServerClosed {
+				log.Err(err).Msg("debug listener stopped")
+			}
+		}()
+		log.Info().Msgf("serving debug info on http://%s/debug/pprof/", d.debug.Addr())
+	}
+	if d.otlp != nil {
+		d.otlp.Start()
+		log.Info().Msg("exporting metrics and logs via OTLP")
+	}
 	return d.eg.Wait()
 }
 
+// FlushSignatureCache discards signatures cached for keys with
+// cachesignatures set
+func (d *Daemon) FlushSignatureCache() int {
+	return d.server.FlushSignatureCache()
+}
+
 func (d *Daemon) Close() error {
 	// do Shutdown() inside errgroup because it will cause the ongoing Serve()
 	// calls to return immediately and we need something to keep blocking until
 	// all ongoing requests are done and Shutdown() returns
 	d.eg.Go(func() error {
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		ctx, cancel := context.WithTimeout(context.Background(), d.shutdownTimeout)
 		defer cancel()
+		// stop accepting connections everywhere and let ongoing requests drain
+		servers := []*http.Server{d.httpServer, d.metricsServer, d.debugServer}
 		err := d.httpServer.Shutdown(ctx)
+		for _, srv := range servers[1:] {
+			_ = srv.Shutdown(ctx)
+		}
+		if ctx.Err() != nil {
+			log.Warn().Stringer("timeout", d.shutdownTimeout).
+				Msg("shutdown timeout expired, closing remaining connections")
+			for _, srv := range servers {
+				_ = srv.Close()
+			}
+		}
+		// log out of tokens only after all requests are finished with them
 		err2 := d.server.Close()
 		if err == nil {
 			err = err2
 		}
+		_ = d.otlp.Close()
 		return err
 	})
 	return d.eg.Wait()
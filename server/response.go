@@ -23,7 +23,9 @@ import (
 	"net/http"
 
 	"github.com/mind-security/relic/v8/internal/httperror"
+	"github.com/mind-security/relic/v8/internal/signinit"
 	"github.com/mind-security/relic/v8/internal/zhttp"
+	"github.com/mind-security/relic/v8/lib/audit"
 	"github.com/mind-security/relic/v8/signers/sigerrors"
 	"github.com/mind-security/relic/v8/token"
 )
@@ -61,10 +63,55 @@ func errToProblem(err error) http.Handler {
 		}
 	} else if e := new(sigerrors.ErrNoCertificate); errors.As(err, e) {
 		return httperror.NoCertificateError(e.Type)
+	} else if e := new(signinit.ErrTimestampURLNotAllowed); errors.As(err, e) {
+		return httperror.Problem{
+			Status: http.StatusForbidden,
+			Type:   httperror.ProblemBase + "timestamp-url-not-allowed",
+			Detail: e.Error(),
+		}
+	} else if e := new(sigerrors.KeyNotFoundError); errors.As(err, e) {
+		return httperror.ErrKeyNotFound
+	} else if e := new(signinit.ErrTimestampFailed); errors.As(err, e) {
+		return httperror.Problem{
+			Status: http.StatusBadGateway,
+			Type:   httperror.ProblemTimestampFailed,
+			Detail: e.Error(),
+		}
+	} else if errors.Is(err, audit.ErrBufferFull) {
+		return httperror.Problem{
+			Status: http.StatusServiceUnavailable,
+			Type:   httperror.ProblemBase + "audit-buffer-full",
+			Detail: "audit records can't be delivered and the audit buffer is full",
+		}
+	} else if e := new(token.Error); errors.As(err, e) {
+		// the code and hint tell the client's operator what went wrong
+		// without having to dig through the server's logs
+		problem := httperror.Problem{
+			Status: http.StatusInternalServerError,
+			Type:   httperror.ProblemTokenError,
+			Detail: e.Error(),
+			Code:   e.Code,
+		}
+		if e.Transient || e.Fatal {
+			problem.Status = http.StatusServiceUnavailable
+			problem.Type = httperror.ProblemTokenUnavailable
+		}
+		return problem
+	} else if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		// reported by WriteUnhandledError
+		return nil
+	} else if tempErr := temporaryError(nil); errors.As(err, &tempErr) && tempErr.Temporary() {
+		// a retryable failure from the token, such as a worker that is
+		// restarting or a cloud KMS that can't be reached
+		return httperror.ErrTokenUnavailable
 	}
 	return nil
 }
 
+type temporaryError interface {
+	Temporary() bool
+}
+
 func writeJSON(rw http.ResponseWriter, data interface{}) error {
 	blob, err := json.Marshal(data)
 	if err != nil {
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mind-security/relic/v8/internal/httperror"
+	"github.com/mind-security/relic/v8/internal/signinit"
+	"github.com/mind-security/relic/v8/signers/sigerrors"
+	"github.com/mind-security/relic/v8/token"
+)
+
+type retryableError struct{}
+
+func (retryableError) Error() string   { return "worker restarting" }
+func (retryableError) Temporary() bool { return true }
+
+func TestErrToProblem(t *testing.T) {
+	assert.Equal(t, httperror.ErrKeyNotFound, errToProblem(fmt.Errorf("key: %w", sigerrors.KeyNotFoundError{})))
+	assert.Equal(t, httperror.ErrTokenUnavailable, errToProblem(fmt.Errorf("sign: %w", retryableError{})))
+	problem, ok := errToProblem(signinit.ErrTimestampFailed{Err: errors.New("tsa down")}).(httperror.Problem)
+	if assert.True(t, ok) {
+		assert.Equal(t, http.StatusBadGateway, problem.Status)
+		assert.Equal(t, httperror.ProblemTimestampFailed, problem.Type)
+	}
+	problem, ok = errToProblem(fmt.Errorf("sign: %w", token.Error{Code: "CKR_PIN_LOCKED", Hint: "unlock it", Fatal: true})).(httperror.Problem)
+	if assert.True(t, ok) {
+		assert.Equal(t, http.StatusServiceUnavailable, problem.Status)
+		assert.Equal(t, httperror.ProblemTokenUnavailable, problem.Type)
+		assert.Equal(t, "CKR_PIN_LOCKED", problem.Code)
+		assert.Equal(t, "CKR_PIN_LOCKED (unlock it)", problem.Detail)
+	}
+	problem, ok = errToProblem(token.Error{Code: "CKR_MECHANISM_INVALID"}).(httperror.Problem)
+	if assert.True(t, ok) {
+		assert.Equal(t, http.StatusInternalServerError, problem.Status)
+		assert.Equal(t, httperror.ProblemTokenError, problem.Type)
+	}
+	// left for WriteUnhandledError
+	assert.Nil(t, errToProblem(context.Canceled))
+	assert.Nil(t, errToProblem(errors.New("unexpected")))
+}
@@ -38,8 +38,14 @@ type Server struct {
 	Closed  <-chan bool
 	closeCh chan<- bool
 	tokens  map[string]token.Token
+	limits  map[string]*tokenLimiter
 	auth    authmodel.Authenticator
 	realIP  func(http.Handler) http.Handler
+	uploads *uploadStore
+	started time.Time
+	sigs    *tokencache.Signatures
+
+	unhealthy map[string]bool // tokens whose last health check failed
 }
 
 func (s *Server) Handler() http.Handler {
@@ -50,13 +56,26 @@ func (s *Server) Handler() http.Handler {
 	r.Use(compresshttp.Middleware)
 	// unauthenticated methods
 	r.Get("/health", s.serveHealth)
+	r.Get("/livez", s.serveLive)
+	r.Get("/readyz", s.serveReady)
 	r.Get("/directory", handleFunc(s.serveDirectory))
+	r.Get("/whoami", handleFunc(s.serveWhoami))
 	// authenticated methods
 	a := r.With(authmodel.Middleware(s.auth))
 	a.Get("/", handleFunc(s.serveHome))
 	a.Get("/list_keys", handleFunc(s.serveListKeys))
 	a.Get("/keys/{key}", handleFunc(s.serveGetKey))
+	a.Get("/keys/{key}/info", handleFunc(s.serveKeyInfo))
+	a.Get("/keys/{key}/x509", handleFunc(s.serveX509Cert))
+	a.Get("/keys/{key}/pgp", handleFunc(s.servePGPCert))
+	a.Get("/trust/x509", handleFunc(s.serveTrustX509))
+	a.Get("/trust/pgp", handleFunc(s.serveTrustPGP))
+	a.Get("/version", handleFunc(s.serveVersion))
 	a.Post("/sign", handleFunc(s.serveSign))
+	a.Post("/uploads", handleFunc(s.serveCreateUpload))
+	a.Get("/uploads/{upload}", handleFunc(s.serveUploadStatus))
+	a.Put("/uploads/{upload}", handleFunc(s.serveUploadChunk))
+	a.Delete("/uploads/{upload}", handleFunc(s.serveDeleteUpload))
 	return r
 }
 
@@ -73,6 +92,12 @@ func (s *Server) Close() error {
 
 func New(config *config.Config) (*Server, error) {
 	closed := make(chan bool)
+	if err := checkAllowedFormats(config); err != nil {
+		return nil, err
+	}
+	if err := checkDefaultKeys(config); err != nil {
+		return nil, err
+	}
 	auth, err := authmodel.New(config)
 	if err != nil {
 		return nil, fmt.Errorf("configuration authentication: %w", err)
@@ -87,7 +112,13 @@ func New(config *config.Config) (*Server, error) {
 		closeCh: closed,
 		auth:    auth,
 		realIP:  realIP,
+		uploads: newUploadStore(config.Server),
+		started: time.Now(),
 		tokens:  make(map[string]token.Token),
+		limits:  make(map[string]*tokenLimiter),
+		sigs: tokencache.NewSignatures(
+			time.Second*time.Duration(config.Server.SignatureCacheSeconds),
+			config.Server.SignatureCacheSize),
 	}
 	if err := s.openTokens(); err != nil {
 		for _, t := range s.tokens {
@@ -98,9 +129,16 @@ func New(config *config.Config) (*Server, error) {
 	if err := s.startHealthCheck(); err != nil {
 		return nil, err
 	}
+	go s.uploads.expireLoop(closed)
 	return s, nil
 }
 
+// FlushSignatureCache discards all cached signatures and returns how many
+// there were
+func (s *Server) FlushSignatureCache() int {
+	return s.sigs.Flush()
+}
+
 // Open each token used by any key. pkcs11 tokens get a worker, while other
 // types are used in-process via a cache.
 func (s *Server) openTokens() error {
@@ -129,7 +167,12 @@ func (s *Server) openTokens() error {
 		if err != nil {
 			return fmt.Errorf("configuring token %q: %w", name, err)
 		}
-		s.tokens[name] = tok
+		// keys with cachesignatures set reuse recent identical signatures
+		s.tokens[name] = s.sigs.Wrap(tok)
+		if tconf.MaxConcurrent > 0 {
+			aging := time.Second * time.Duration(tconf.PriorityAging)
+			s.limits[name] = newTokenLimiter(name, tconf.MaxConcurrent, tconf.RejectConcurrent, aging)
+		}
 	}
 	return nil
 }
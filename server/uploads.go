@@ -0,0 +1,218 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package server
+
+import (
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/mind-security/relic/v8/config"
+	"github.com/mind-security/relic/v8/internal/authmodel"
+	"github.com/mind-security/relic/v8/internal/httperror"
+)
+
+// uploadStore holds request bodies that are sent in chunks, so that a client
+// on a flaky network can resume a large upload instead of starting over
+type uploadStore struct {
+	dir      string
+	timeout  time.Duration
+	perOwner int
+
+	mu      sync.Mutex
+	uploads map[string]*upload
+}
+
+// upload is one partially or fully received body, assembled in a temp file.
+// Its mutex is held while a chunk is being written or the body is being
+// signed. Other requests for the upload fail instead of waiting for it, since
+// a chunk from a dropped connection can hold it until the read timeout.
+type upload struct {
+	mu    sync.Mutex
+	id    string
+	owner string
+	f     *os.File
+	size  int64
+	used  time.Time
+}
+
+func newUploadStore(conf *config.ServerConfig) *uploadStore {
+	return &uploadStore{
+		dir:      conf.UploadDir,
+		timeout:  time.Second * time.Duration(conf.UploadTimeout),
+		perOwner: conf.MaxUploads,
+		uploads:  make(map[string]*upload),
+	}
+}
+
+// uploadOwner identifies the caller, so that an upload can only be continued
+// or used by whoever started it
+func uploadOwner(userInfo authmodel.UserInfo) string {
+	ident := userInfo.Identity()
+	key := ident.Fingerprint
+	if ident.SpiffeID != "" {
+		// the key changes each time an SVID is rotated
+		key = ident.SpiffeID
+	}
+	return ident.Name + "\x00" + key + "\x00" + ident.Subject
+}
+
+// create starts a new, empty upload, unless the owner already has as many
+// unfinished uploads as allowed
+func (st *uploadStore) create(owner string) (*upload, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.perOwner > 0 {
+		var count int
+		for _, u := range st.uploads {
+			if u.owner == owner {
+				count++
+			}
+		}
+		if count >= st.perOwner {
+			return nil, httperror.ErrTooManyUploads
+		}
+	}
+	f, err := os.CreateTemp(st.dir, "relic-upload-")
+	if err != nil {
+		return nil, err
+	}
+	u := &upload{
+		id:    uuid.NewString(),
+		owner: owner,
+		f:     f,
+		used:  time.Now(),
+	}
+	st.uploads[u.id] = u
+	return u, nil
+}
+
+// acquire finds an upload and locks it. The caller must pass it to release or
+// remove when done. If another request is using the upload, ErrUploadBusy is
+// returned.
+func (st *uploadStore) acquire(id, owner string) (*upload, error) {
+	st.mu.Lock()
+	u := st.uploads[id]
+	st.mu.Unlock()
+	if u == nil || u.owner != owner {
+		return nil, httperror.ErrUploadNotFound
+	}
+	if !u.mu.TryLock() {
+		return nil, httperror.ErrUploadBusy
+	}
+	if u.f == nil {
+		// removed since it was looked up
+		u.mu.Unlock()
+		return nil, httperror.ErrUploadNotFound
+	}
+	return u, nil
+}
+
+// release unlocks an upload and restarts its idle timer
+func (st *uploadStore) release(u *upload) {
+	u.used = time.Now()
+	u.mu.Unlock()
+}
+
+// remove discards a locked upload and its temp file
+func (st *uploadStore) remove(u *upload) {
+	st.mu.Lock()
+	delete(st.uploads, u.id)
+	st.mu.Unlock()
+	u.discard()
+	u.mu.Unlock()
+}
+
+// expireLoop periodically discards uploads that have been idle for too long
+func (st *uploadStore) expireLoop(closed <-chan bool) {
+	interval := st.timeout / 4
+	if interval > time.Minute {
+		interval = time.Minute
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			st.expire(time.Now().Add(-st.timeout))
+		case <-closed:
+			st.expire(time.Now())
+			return
+		}
+	}
+}
+
+// expire discards uploads that were last used before the cutoff. Uploads that
+// are busy are left for the next pass.
+func (st *uploadStore) expire(cutoff time.Time) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	for id, u := range st.uploads {
+		if !u.mu.TryLock() {
+			continue
+		}
+		if u.used.Before(cutoff) {
+			delete(st.uploads, id)
+			u.discard()
+			log.Info().Str("upload", id).Int64("size", u.size).Msg("discarded abandoned upload")
+		}
+		u.mu.Unlock()
+	}
+}
+
+// write appends a chunk, which must start where the previous one ended. If
+// the chunk can't be read completely, or would make the upload larger than
+// maxSize, it is dropped so the client can send it again.
+func (u *upload) write(offset int64, r io.Reader, maxSize int64) error {
+	if offset != u.size {
+		return httperror.UploadOffsetError(u.size)
+	}
+	if maxSize != 0 {
+		// read one byte past the limit to tell if it was exceeded
+		r = io.LimitReader(r, maxSize-u.size+1)
+	}
+	n, err := io.Copy(io.NewOffsetWriter(u.f, u.size), r)
+	if err == nil && maxSize != 0 && u.size+n > maxSize {
+		err = httperror.ErrRequestTooLarge
+	}
+	if err != nil {
+		if terr := u.f.Truncate(u.size); terr != nil {
+			return terr
+		}
+		return err
+	}
+	u.size += n
+	return nil
+}
+
+// reader returns the assembled body from the start
+func (u *upload) reader() *io.SectionReader {
+	return io.NewSectionReader(u.f, 0, u.size)
+}
+
+func (u *upload) discard() {
+	if u.f == nil {
+		return
+	}
+	u.f.Close()
+	os.Remove(u.f.Name())
+	u.f = nil
+}
@@ -0,0 +1,79 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/mind-security/relic/v8/internal/httperror"
+	"github.com/mind-security/relic/v8/internal/signinit"
+	"github.com/mind-security/relic/v8/lib/certloader"
+)
+
+// serveX509Cert returns the key's certificate chain as PEM, for distributing
+// alongside signed artifacts. Like keyinfo it only needs the key to be visible
+// to the client.
+func (s *Server) serveX509Cert(rw http.ResponseWriter, req *http.Request) error {
+	cert, err := s.visibleCert(req)
+	if err != nil {
+		return err
+	} else if cert.Leaf == nil {
+		return httperror.NoCertificateError("x509")
+	}
+	blob, err := marshalX509Cert(cert.Certificates)
+	if err != nil {
+		return err
+	}
+	return writeCert(rw, "application/pem-certificate-chain", blob)
+}
+
+// servePGPCert returns the key's PGP public key in ASCII armor
+func (s *Server) servePGPCert(rw http.ResponseWriter, req *http.Request) error {
+	cert, err := s.visibleCert(req)
+	if err != nil {
+		return err
+	} else if cert.PgpKey == nil {
+		return httperror.NoCertificateError("pgp")
+	}
+	blob, err := marshalPGPCert(cert.PgpKey)
+	if err != nil {
+		return err
+	}
+	return writeCert(rw, "application/pgp-keys", blob)
+}
+
+func (s *Server) visibleCert(req *http.Request) (*certloader.Certificate, error) {
+	keyConf, err := s.visibleKey(req, chi.URLParam(req, "key"))
+	if err != nil {
+		return nil, err
+	}
+	tok := s.tokens[keyConf.Token]
+	if tok == nil {
+		return nil, fmt.Errorf("missing token \"%s\" for key \"%s\"", keyConf.Token, keyConf.Name())
+	}
+	cert, _, err := signinit.InitKey(req.Context(), tok, keyConf.Name())
+	return cert, err
+}
+
+func writeCert(rw http.ResponseWriter, contentType, blob string) error {
+	rw.Header().Set("Content-Type", contentType)
+	_, err := rw.Write([]byte(blob))
+	return err
+}
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetCert(t *testing.T) {
+	env := newSignTestEnv(t)
+	get := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		rec := httptest.NewRecorder()
+		env.s.Handler().ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("/keys/leaf/x509")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "application/pem-certificate-chain", rec.Header().Get("Content-Type"))
+	var certs [][]byte
+	rest := rec.Body.Bytes()
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		certs = append(certs, block.Bytes)
+	}
+	assert.Equal(t, [][]byte{env.leaf.Raw, env.inter.Raw, env.root.Raw}, certs)
+
+	// the key has no PGP certificate
+	rec = get("/keys/leaf/pgp")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "certificate-not-defined")
+
+	// hidden keys and aliases of them aren't served
+	alias := env.cfg.NewKey("alias")
+	alias.Alias = "leaf"
+	assert.Equal(t, http.StatusOK, get("/keys/alias/x509").Code)
+	alias.Hide = true
+	assert.Equal(t, http.StatusForbidden, get("/keys/alias/x509").Code)
+	env.cfg.Keys["leaf"].Hide = true
+	assert.Equal(t, http.StatusForbidden, get("/keys/leaf/x509").Code)
+	assert.Equal(t, http.StatusForbidden, get("/keys/missing/x509").Code)
+}
@@ -90,15 +90,17 @@ func marshalX509Cert(certs []*x509.Certificate) (string, error) {
 	return buf.String(), nil
 }
 
-// marshal PGP public certificate in ASCII armor
-func marshalPGPCert(entity *openpgp.Entity) (string, error) {
+// marshal PGP public certificates in ASCII armor
+func marshalPGPCert(entities ...*openpgp.Entity) (string, error) {
 	var buf bytes.Buffer
 	w, err := armor.Encode(&buf, "PGP PUBLIC KEY BLOCK", nil)
 	if err != nil {
 		return "", err
 	}
-	if err := entity.Serialize(w); err != nil {
-		return "", err
+	for _, entity := range entities {
+		if err := entity.Serialize(w); err != nil {
+			return "", err
+		}
 	}
 	if err := w.Close(); err != nil {
 		return "", err
@@ -18,6 +18,7 @@ package server
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"sync"
 	"time"
@@ -27,12 +28,14 @@ import (
 	"github.com/rs/zerolog/log"
 
 	"github.com/mind-security/relic/v8/internal/zhttp"
+	"github.com/mind-security/relic/v8/lib/audit"
 	"github.com/mind-security/relic/v8/token"
 )
 
 var (
 	healthStatus   int
 	healthLastPing time.Time
+	healthReady    bool // set once every token has passed a health check
 	healthMu       sync.Mutex
 
 	metricTokenCheckErrors = promauto.NewGaugeVec(
@@ -51,6 +54,7 @@ func (s *Server) healthCheckInterval() time.Duration {
 func (s *Server) startHealthCheck() error {
 	healthStatus = s.Config.Server.TokenCheckFailures
 	healthLastPing = time.Now()
+	healthReady = false
 	go s.healthCheckLoop()
 	return nil
 }
@@ -75,14 +79,20 @@ func (s *Server) healthCheck() bool {
 	last := healthStatus
 	healthMu.Unlock()
 	var notOK []string
-	for name, token := range s.tokens {
+	var fatal bool
+	for name, tok := range s.tokens {
 		metric := metricTokenCheckErrors.WithLabelValues(name)
-		if s.pingOne(token) {
+		err := s.pingOne(tok)
+		if err == nil {
 			metric.Set(0)
 		} else {
 			metric.Inc()
 			notOK = append(notOK, name)
+			if e := new(token.Error); errors.As(err, e) && e.Fatal {
+				fatal = true
+			}
 		}
+		s.auditHealth(tok, err)
 	}
 	next := last
 	if len(notOK) == 0 {
@@ -93,6 +103,12 @@ func (s *Server) healthCheck() bool {
 			ev.Msg("recovered to normal state")
 		}
 		next = s.Config.Server.TokenCheckFailures
+	} else if fatal && last > 0 {
+		// no point waiting out the remaining checks for an error that needs
+		// an operator to fix
+		next = 0
+		log.Error().Str("token_state", "ERROR").
+			Msg("token reported a fatal error, flagging as ERROR")
 	} else if last > 0 {
 		next--
 		if next == 0 {
@@ -104,22 +120,51 @@ func (s *Server) healthCheck() bool {
 	defer healthMu.Unlock()
 	healthStatus = next
 	healthLastPing = time.Now()
+	if len(notOK) == 0 {
+		healthReady = true
+	}
 	return len(notOK) == 0
 }
 
-func (s *Server) pingOne(tok token.Token) bool {
+func (s *Server) pingOne(tok token.Token) error {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*time.Duration(s.Config.Server.TokenCheckTimeout))
 	defer cancel()
-	if err := tok.Ping(ctx); err != nil {
+	err := tok.Ping(ctx)
+	if e := new(token.Error); errors.As(err, e) && e.Transient && ctx.Err() == nil {
+		log.Warn().Str("token", tok.Config().Name()).Str("code", e.Code).Err(err).
+			Msg("token health check failed; retrying")
+		err = tok.Ping(ctx)
+	}
+	if err != nil {
 		ev := log.Error().Str("token", tok.Config().Name())
 		if ctx.Err() != nil {
 			ev.Msg("token health check timed out")
-		} else {
-			ev.Err(err).Msg("token health check failed")
+			return errors.New("health check timed out")
 		}
-		return false
+		if e := new(token.Error); errors.As(err, e) {
+			ev.Str("code", e.Code).Bool("fatal", e.Fatal)
+		}
+		ev.Err(err).Msg("token health check failed")
+		return err
+	}
+	return nil
+}
+
+// auditHealth emits an audit event when a token's health check result changes
+func (s *Server) auditHealth(tok token.Token, pingErr error) {
+	name := tok.Config().Name()
+	wasUnhealthy := s.unhealthy[name]
+	switch {
+	case pingErr != nil && !wasUnhealthy:
+		if s.unhealthy == nil {
+			s.unhealthy = make(map[string]bool)
+		}
+		s.unhealthy[name] = true
+		token.AuditEvent(tok.Config(), audit.EventUnhealthy, pingErr.Error())
+	case pingErr == nil && wasUnhealthy:
+		delete(s.unhealthy, name)
+		token.AuditEvent(tok.Config(), audit.EventHealthy, "health check succeeded")
 	}
-	return true
 }
 
 func (s *Server) Healthy(request *http.Request) bool {
@@ -137,6 +182,33 @@ func (s *Server) Healthy(request *http.Request) bool {
 	return healthStatus > 0
 }
 
+// Ready is like Healthy, but also requires that the tokens have passed a
+// health check since the server started
+func (s *Server) Ready(request *http.Request) bool {
+	if !s.Healthy(request) {
+		return false
+	}
+	healthMu.Lock()
+	defer healthMu.Unlock()
+	return healthReady
+}
+
+// serveLive reports that the process is up, regardless of token health
+func (s *Server) serveLive(rw http.ResponseWriter, request *http.Request) {
+	zhttp.DontLog(request)
+	_, _ = rw.Write([]byte("OK\r\n"))
+}
+
+// serveReady reports whether the server should be sent signing requests
+func (s *Server) serveReady(rw http.ResponseWriter, request *http.Request) {
+	zhttp.DontLog(request)
+	if s.Ready(request) {
+		_, _ = rw.Write([]byte("OK\r\n"))
+	} else {
+		http.Error(rw, "not ready", http.StatusServiceUnavailable)
+	}
+}
+
 func (s *Server) serveHealth(rw http.ResponseWriter, request *http.Request) {
 	zhttp.DontLog(request)
 	if s.Healthy(request) {
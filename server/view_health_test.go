@@ -0,0 +1,124 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mind-security/relic/v8/config"
+	"github.com/mind-security/relic/v8/lib/audit"
+	"github.com/mind-security/relic/v8/token"
+)
+
+// flakyToken fails health checks while err is set
+type flakyToken struct {
+	token.Token
+	conf *config.TokenConfig
+	err  error
+}
+
+func (t *flakyToken) Config() *config.TokenConfig    { return t.conf }
+func (t *flakyToken) Ping(ctx context.Context) error { return t.err }
+
+func TestHealthTransitionAudit(t *testing.T) {
+	var events []*audit.Info
+	token.AuditHook = func(info *audit.Info) { events = append(events, info) }
+	defer func() { token.AuditHook = nil }()
+	cfg := &config.Config{Server: &config.ServerConfig{TokenCheckFailures: 3, TokenCheckTimeout: 5}}
+	tok := &flakyToken{conf: cfg.NewToken("hsm")}
+	s := &Server{Config: cfg, tokens: map[string]token.Token{"hsm": tok}}
+
+	assert.True(t, s.healthCheck())
+	assert.Empty(t, events)
+
+	tok.err = errors.New("CKR_DEVICE_ERROR")
+	assert.False(t, s.healthCheck())
+	assert.False(t, s.healthCheck())
+	require.Len(t, events, 1, "only the transition is audited")
+	assert.Equal(t, audit.EventUnhealthy, events[0].EventType())
+	assert.Equal(t, "hsm", events[0].Attributes["token.name"])
+	assert.Equal(t, "CKR_DEVICE_ERROR", events[0].Attributes["token.reason"])
+
+	tok.err = nil
+	assert.True(t, s.healthCheck())
+	require.Len(t, events, 2)
+	assert.Equal(t, audit.EventHealthy, events[1].EventType())
+}
+
+// onceToken fails the first health check with err
+type onceToken struct {
+	flakyToken
+	pings int
+}
+
+func (t *onceToken) Ping(ctx context.Context) error {
+	t.pings++
+	if t.pings == 1 {
+		return t.err
+	}
+	return nil
+}
+
+func TestHealthTokenErrors(t *testing.T) {
+	cfg := &config.Config{Server: &config.ServerConfig{TokenCheckFailures: 3, TokenCheckTimeout: 5, TokenCheckInterval: 60}}
+	healthMu.Lock()
+	healthStatus = cfg.Server.TokenCheckFailures
+	healthMu.Unlock()
+
+	// transient errors are retried right away
+	tok := &onceToken{flakyToken: flakyToken{conf: cfg.NewToken("hsm"), err: token.Error{Code: "CKR_DEVICE_ERROR", Transient: true}}}
+	s := &Server{Config: cfg, tokens: map[string]token.Token{"hsm": tok}}
+	assert.True(t, s.healthCheck())
+	assert.Equal(t, 2, tok.pings)
+
+	// other errors count against the failure limit
+	s.tokens["hsm"] = &flakyToken{conf: tok.conf, err: token.Error{Code: "CKR_FUNCTION_FAILED"}}
+	assert.False(t, s.healthCheck())
+	assert.True(t, s.Healthy(nil))
+
+	// fatal errors flag the server unhealthy immediately
+	s.tokens["hsm"] = &flakyToken{conf: tok.conf, err: token.Error{Code: "CKR_PIN_LOCKED", Fatal: true}}
+	assert.False(t, s.healthCheck())
+	assert.False(t, s.Healthy(nil))
+}
+
+func TestReadiness(t *testing.T) {
+	cfg := &config.Config{Server: &config.ServerConfig{TokenCheckFailures: 3, TokenCheckTimeout: 5, TokenCheckInterval: 60}}
+	tok := &flakyToken{conf: cfg.NewToken("hsm"), err: errors.New("CKR_DEVICE_ERROR")}
+	s := &Server{
+		Config: cfg,
+		auth:   testAuth{},
+		realIP: func(h http.Handler) http.Handler { return h },
+		tokens: map[string]token.Token{"hsm": tok},
+	}
+	probe := func(path string) int {
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		return rec.Code
+	}
+	healthMu.Lock()
+	healthStatus = cfg.Server.TokenCheckFailures
+	healthLastPing = time.Now()
+	healthReady = false
+	healthMu.Unlock()
+
+	// not ready until a token check has passed
+	assert.Equal(t, http.StatusOK, probe("/livez"))
+	assert.Equal(t, http.StatusServiceUnavailable, probe("/readyz"))
+	s.healthCheck()
+	assert.Equal(t, http.StatusServiceUnavailable, probe("/readyz"))
+	tok.err = nil
+	s.healthCheck()
+	assert.Equal(t, http.StatusOK, probe("/readyz"))
+
+	// disabling the server only affects readiness
+	cfg.Server.Disabled = true
+	assert.Equal(t, http.StatusServiceUnavailable, probe("/readyz"))
+	assert.Equal(t, http.StatusOK, probe("/livez"))
+}
@@ -0,0 +1,165 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package server
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/mind-security/relic/v8/config"
+	"github.com/mind-security/relic/v8/internal/authmodel"
+	"github.com/mind-security/relic/v8/internal/httperror"
+	"github.com/mind-security/relic/v8/internal/signinit"
+	"github.com/mind-security/relic/v8/lib/pgptools"
+	"github.com/mind-security/relic/v8/lib/x509tools"
+)
+
+type keyDetails struct {
+	Name      string
+	Algorithm string
+	KeySize   int
+	Digest    string       // used when the request doesn't choose one
+	X509      *certDetails `json:",omitempty"`
+	PGP       *pgpDetails  `json:",omitempty"`
+	Timestamp timestampDetails
+}
+
+type certDetails struct {
+	Subject           string
+	Issuer            string
+	SerialNumber      string
+	NotBefore         time.Time
+	NotAfter          time.Time
+	SHA256Fingerprint string
+}
+
+type pgpDetails struct {
+	UserID      string
+	KeyID       string
+	Fingerprint string
+}
+
+type timestampDetails struct {
+	Enabled bool
+	Style   string   `json:",omitempty"`
+	URLs    []string `json:",omitempty"`
+}
+
+// serveKeyInfo describes a key without returning its certificates. Like
+// list_keys it only needs the key to be visible to the client.
+func (s *Server) serveKeyInfo(rw http.ResponseWriter, req *http.Request) error {
+	keyName := chi.URLParam(req, "key")
+	keyConf, err := s.visibleKey(req, keyName)
+	if err != nil {
+		return err
+	}
+	details, err := s.getKeyDetails(req.Context(), keyName, keyConf)
+	if err != nil {
+		return err
+	}
+	return writeJSON(rw, details)
+}
+
+// visibleKey resolves a key that list_keys would show to the client, or
+// returns ErrForbidden
+func (s *Server) visibleKey(req *http.Request, keyName string) (*config.KeyConfig, error) {
+	if named := s.Config.Keys[keyName]; named != nil && named.Hide {
+		return nil, httperror.ErrForbidden
+	}
+	keyConf, err := s.Config.GetKey(keyName)
+	if err != nil || keyConf.Hide || !authmodel.RequestInfo(req).Allowed(keyConf) {
+		return nil, httperror.ErrForbidden
+	}
+	return keyConf, nil
+}
+
+func (s *Server) getKeyDetails(ctx context.Context, keyName string, keyConf *config.KeyConfig) (*keyDetails, error) {
+	tok := s.tokens[keyConf.Token]
+	if tok == nil {
+		return nil, fmt.Errorf("missing token \"%s\" for key \"%s\"", keyConf.Token, keyConf.Name())
+	}
+	cert, _, err := signinit.InitKey(ctx, tok, keyConf.Name())
+	if err != nil {
+		return nil, err
+	}
+	pub := cert.Signer().Public()
+	details := &keyDetails{
+		Name:      keyName,
+		Algorithm: x509tools.GetPublicKeyAlgorithm(pub).String(),
+		KeySize:   keySize(pub),
+	}
+	if _, ok := pub.(ed25519.PublicKey); ok {
+		details.Algorithm = "Ed25519"
+	}
+	if hash, err := signinit.KeyHash(keyConf, pub); err == nil {
+		details.Digest = x509tools.HashNames[hash]
+	}
+	if leaf := cert.Leaf; leaf != nil {
+		fp := sha256.Sum256(leaf.Raw)
+		details.X509 = &certDetails{
+			Subject:           x509tools.FormatSubject(leaf),
+			Issuer:            x509tools.FormatIssuer(leaf),
+			SerialNumber:      fmt.Sprintf("%x", leaf.SerialNumber),
+			NotBefore:         leaf.NotBefore,
+			NotAfter:          leaf.NotAfter,
+			SHA256Fingerprint: hex.EncodeToString(fp[:]),
+		}
+	}
+	if entity := cert.PgpKey; entity != nil {
+		details.PGP = &pgpDetails{
+			UserID:      pgptools.EntityName(entity),
+			KeyID:       fmt.Sprintf("%016x", entity.PrimaryKey.KeyId),
+			Fingerprint: hex.EncodeToString(entity.PrimaryKey.Fingerprint),
+		}
+	}
+	if keyConf.Timestamp {
+		details.Timestamp.Enabled = true
+		details.Timestamp.Style = keyConf.TimestampStyle
+		if details.Timestamp.Style == "" {
+			details.Timestamp.Style = "rfc3161"
+		}
+		if tconf := s.Config.Timestamp; tconf != nil {
+			details.Timestamp.URLs = tconf.URLs
+			if details.Timestamp.Style != "rfc3161" {
+				details.Timestamp.URLs = append(append([]string{}, tconf.URLs...), tconf.MsURLs...)
+			}
+		}
+	}
+	return details, nil
+}
+
+func keySize(pub crypto.PublicKey) int {
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		return k.N.BitLen()
+	case *ecdsa.PublicKey:
+		return k.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		return 256
+	}
+	return 0
+}
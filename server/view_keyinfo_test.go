@@ -0,0 +1,59 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mind-security/relic/v8/config"
+)
+
+func TestKeyInfo(t *testing.T) {
+	env := newSignTestEnv(t)
+	get := func(key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/keys/"+key+"/info", nil)
+		rec := httptest.NewRecorder()
+		env.s.Handler().ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("leaf")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var details keyDetails
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &details))
+	fp := sha256.Sum256(env.leaf.Raw)
+	assert.Equal(t, "leaf", details.Name)
+	assert.Equal(t, "ECDSA", details.Algorithm)
+	assert.Equal(t, 256, details.KeySize)
+	require.NotNil(t, details.X509)
+	assert.Equal(t, "CN=leaf", details.X509.Subject)
+	assert.Equal(t, "CN=intermediate", details.X509.Issuer)
+	assert.Equal(t, hex.EncodeToString(fp[:]), details.X509.SHA256Fingerprint)
+	assert.Nil(t, details.PGP)
+	assert.False(t, details.Timestamp.Enabled)
+
+	// timestamp settings
+	env.cfg.Keys["leaf"].Timestamp = true
+	env.cfg.Keys["leaf"].TimestampStyle = "fallback"
+	env.cfg.Timestamp = &config.TimestampConfig{URLs: []string{"http://tsa"}, MsURLs: []string{"http://mstsa"}}
+	rec = get("leaf")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &details))
+	assert.Equal(t, timestampDetails{Enabled: true, Style: "fallback", URLs: []string{"http://tsa", "http://mstsa"}}, details.Timestamp)
+
+	// hidden keys and aliases of them aren't described
+	alias := env.cfg.NewKey("alias")
+	alias.Alias = "leaf"
+	assert.Equal(t, http.StatusOK, get("alias").Code)
+	alias.Hide = true
+	assert.Equal(t, http.StatusForbidden, get("alias").Code)
+	env.cfg.Keys["leaf"].Hide = true
+	assert.Equal(t, http.StatusForbidden, get("leaf").Code)
+	assert.Equal(t, http.StatusForbidden, get("missing").Code)
+}
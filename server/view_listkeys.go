@@ -17,29 +17,120 @@
 package server
 
 import (
+	"errors"
 	"net/http"
+	"path"
 	"sort"
+	"strconv"
 
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/mind-security/relic/v8/config"
 	"github.com/mind-security/relic/v8/internal/authmodel"
+	"github.com/mind-security/relic/v8/internal/httperror"
+	"github.com/mind-security/relic/v8/signers"
 )
 
+// totalCountHeader holds the number of keys that matched the filters, before
+// limit and offset were applied
+const totalCountHeader = "X-Total-Count"
+
+// keyFilter narrows the keys listed to those matching all of the given
+// query parameters
+type keyFilter struct {
+	name   string          // glob matched against the key name
+	role   string          // role that may use the key
+	format *signers.Signer // signature type the key may produce
+}
+
+func (f keyFilter) match(name string, keyConf, target *config.KeyConfig) bool {
+	if f.name != "" {
+		if ok, _ := path.Match(f.name, name); !ok {
+			return false
+		}
+	}
+	if f.role != "" && !hasRole(target.Roles, f.role) {
+		return false
+	}
+	if f.format != nil && (!formatAllowed(keyConf.AllowedFormats, f.format) || !formatAllowed(target.AllowedFormats, f.format)) {
+		return false
+	}
+	return true
+}
+
+func hasRole(roles []string, role string) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// queryInt parses an optional non-negative integer parameter
+func queryInt(req *http.Request, param string) (int, error) {
+	v := req.URL.Query().Get(param)
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err == nil && n < 0 {
+		err = errors.New("must not be negative")
+	}
+	if err != nil {
+		return 0, httperror.InvalidParameterError(param, err)
+	}
+	return n, nil
+}
+
 func (s *Server) serveListKeys(rw http.ResponseWriter, req *http.Request) error {
 	userInfo := authmodel.RequestInfo(req)
+	query := req.URL.Query()
+	filter := keyFilter{name: query.Get("name"), role: query.Get("role")}
+	if _, err := path.Match(filter.name, ""); err != nil {
+		return httperror.InvalidParameterError("name", err)
+	}
+	if sigType := query.Get("format"); sigType != "" {
+		filter.format = signers.ByName(sigType)
+		if filter.format == nil {
+			hlog.FromRequest(req).Error().Str("sigtype", sigType).Msg("signature type not found")
+			return httperror.ErrUnknownSignatureType
+		}
+	}
+	limit, err := queryInt(req, "limit")
+	if err != nil {
+		return err
+	}
+	offset, err := queryInt(req, "offset")
+	if err != nil {
+		return err
+	}
 	keys := []string{}
 	for key, keyConf := range s.Config.Keys {
-		if keyConf.Hide {
+		if keyConf.Hide || keyConf.LabelPattern != "" {
 			continue
 		}
+		target := keyConf
 		if keyConf.Alias != "" {
-			keyConf = s.Config.Keys[keyConf.Alias]
-			if keyConf == nil {
+			target = s.Config.Keys[keyConf.Alias]
+			if target == nil {
 				continue
 			}
 		}
-		if !keyConf.Hide && userInfo.Allowed(keyConf) {
+		if !target.Hide && userInfo.Allowed(target) && filter.match(key, keyConf, target) {
 			keys = append(keys, key)
 		}
 	}
 	sort.Strings(keys)
+	rw.Header().Set(totalCountHeader, strconv.Itoa(len(keys)))
+	// pages are taken from the sorted list so that they stay stable while
+	// paging, unless the configuration is changed
+	if offset > len(keys) {
+		offset = len(keys)
+	}
+	keys = keys[offset:]
+	if limit != 0 && limit < len(keys) {
+		keys = keys[:limit]
+	}
 	return writeJSON(rw, keys)
 }
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListKeys(t *testing.T) {
+	env := newSignTestEnv(t)
+	for _, name := range []string{"alpha", "beta", "gamma"} {
+		k := env.cfg.NewKey(name)
+		k.Token = "file"
+		k.Roles = []string{"team-" + name}
+	}
+	env.cfg.Keys["alpha"].AllowedFormats = []string{"jar"}
+	env.cfg.Keys["gamma"].Hide = true
+	alias := env.cfg.NewKey("alias")
+	alias.Alias = "beta"
+	list := func(query string) ([]string, string) {
+		req := httptest.NewRequest("GET", "/list_keys"+query, nil)
+		rec := httptest.NewRecorder()
+		env.s.Handler().ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var keys []string
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &keys))
+		return keys, rec.Header().Get(totalCountHeader)
+	}
+
+	keys, total := list("")
+	assert.Equal(t, []string{"alias", "alpha", "beta", "leaf"}, keys)
+	assert.Equal(t, "4", total)
+	keys, _ = list("?name=a*")
+	assert.Equal(t, []string{"alias", "alpha"}, keys)
+	// aliases have the roles of their target
+	keys, _ = list("?role=team-beta")
+	assert.Equal(t, []string{"alias", "beta"}, keys)
+	keys, _ = list("?format=pe-coff")
+	assert.Equal(t, []string{"alias", "beta", "leaf"}, keys)
+	keys, _ = list("?role=team-gamma")
+	assert.Empty(t, keys)
+
+	// pages
+	keys, total = list("?limit=2")
+	assert.Equal(t, []string{"alias", "alpha"}, keys)
+	assert.Equal(t, "4", total)
+	keys, total = list("?limit=2&offset=3")
+	assert.Equal(t, []string{"leaf"}, keys)
+	assert.Equal(t, "4", total)
+	keys, _ = list("?offset=10")
+	assert.Empty(t, keys)
+
+	for _, query := range []string{"?name=[", "?format=nope", "?limit=-1", "?offset=x"} {
+		req := httptest.NewRequest("GET", "/list_keys"+query, nil)
+		rec := httptest.NewRecorder()
+		env.s.Handler().ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+	}
+}
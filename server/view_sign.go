@@ -17,26 +17,43 @@
 package server
 
 import (
+	"bufio"
 	"crypto"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/mind-security/relic/v8/config"
 	"github.com/mind-security/relic/v8/internal/authmodel"
 	"github.com/mind-security/relic/v8/internal/httperror"
 	"github.com/mind-security/relic/v8/internal/signinit"
 	"github.com/mind-security/relic/v8/internal/zhttp"
+	"github.com/mind-security/relic/v8/lib/audit"
 	"github.com/mind-security/relic/v8/lib/readercounter"
 	"github.com/mind-security/relic/v8/lib/x509tools"
 	"github.com/mind-security/relic/v8/signers"
 	"github.com/rs/zerolog/hlog"
 )
 
-const defaultHash = crypto.SHA256
+// chainHeader holds the signing certificate chain as base64 of concatenated
+// DER certificates
+const chainHeader = "X-Relic-Chain"
 
 func (s *Server) serveSign(rw http.ResponseWriter, request *http.Request) error {
 	// parse parameters
 	query := request.URL.Query()
+	userInfo := authmodel.RequestInfo(request)
 	keyName := query.Get("key")
+	if keyName == "" {
+		// clients that only ever use one key can leave it to the config
+		keyName = userInfo.Identity().DefaultKey
+	}
 	if keyName == "" {
 		return httperror.MissingParameterError("key")
 	}
@@ -46,7 +63,6 @@ func (s *Server) serveSign(rw http.ResponseWriter, request *http.Request) error
 	}
 	sigType := query.Get("sigtype")
 	// authorize key
-	userInfo := authmodel.RequestInfo(request)
 	keyConf, err := s.Config.GetKey(keyName)
 	if err != nil {
 		hlog.FromRequest(request).Err(err).Str("key", keyName).Msg("key not found")
@@ -61,14 +77,41 @@ func (s *Server) serveSign(rw http.ResponseWriter, request *http.Request) error
 		hlog.FromRequest(request).Error().Str("sigtype", sigType).Msg("signature type not found")
 		return httperror.ErrUnknownSignatureType
 	}
-	hash := defaultHash
+	// an alias must allow the type as well as its target. Keys resolved by
+	// labelpattern have no entry of their own.
+	named := s.Config.Keys[keyName]
+	if !formatAllowed(keyConf.AllowedFormats, mod) || (named != nil && !formatAllowed(named.AllowedFormats, mod)) {
+		hlog.FromRequest(request).Error().Str("key", keyName).Str("sigtype", mod.Name).Msg("signature type not allowed for key")
+		return httperror.ErrFormatNotAllowed
+	}
+	if reason := roleDenied(s.Config, userInfo.Identity().Roles, mod, filename); reason != "" {
+		hlog.FromRequest(request).Error().Str("key", keyName).Str("sigtype", mod.Name).
+			Str("filename", filename).Str("reason", reason).Msg("signature type not allowed for role")
+		s.auditDenied(request, userInfo, keyConf, mod, filename, reason)
+		return httperror.ErrRoleNotAllowed
+	}
+	priority, err := requestPriority(s.Config, userInfo.Identity().Roles, request.Header.Get(priorityHeader))
+	if err != nil {
+		return err
+	}
+	// zero lets signinit choose a digest for the key
+	var hash crypto.Hash
 	if digest := request.URL.Query().Get("digest"); digest != "" {
 		hash = x509tools.HashByName(digest)
 		if hash == 0 {
 			hlog.FromRequest(request).Error().Str("digest", digest).Msg("digest type not found")
 			return httperror.ErrUnknownDigest
+		} else if x509tools.IsDeprecatedHash(hash) {
+			hlog.FromRequest(request).Error().Str("digest", digest).Msg("deprecated digest refused")
+			return httperror.BadParameterError(signinit.ErrDeprecatedHash{Hash: hash})
 		}
 	}
+	// metadata identifying the artifact, recorded verbatim in the audit log
+	meta, err := audit.ParseMetadata(query["meta"])
+	if err != nil {
+		hlog.FromRequest(request).Err(err).Msg("invalid metadata")
+		return httperror.InvalidParameterError("meta", err)
+	}
 	// parse flags for signer
 	flags, err := mod.FlagsFromQuery(query)
 	if err != nil {
@@ -76,22 +119,77 @@ func (s *Server) serveSign(rw http.ResponseWriter, request *http.Request) error
 			Msg("failed to parse signer arguments")
 		return httperror.BadParameterError(err)
 	}
+	maxSize := s.maxRequestSize(mod, flags)
+	if maxSize != 0 && request.ContentLength > maxSize {
+		hlog.FromRequest(request).Error().Int64("size", request.ContentLength).Msg("request too large")
+		return httperror.ErrRequestTooLarge
+	}
+	// a body sent in chunks beforehand is signed in place of the request body
+	body := io.Reader(request.Body)
+	if maxSize != 0 {
+		body = http.MaxBytesReader(rw, request.Body, maxSize)
+	}
+	var up *upload
+	if uploadID := query.Get("upload"); uploadID != "" {
+		up, err = s.uploads.acquire(uploadID, uploadOwner(userInfo))
+		if err != nil {
+			return err
+		}
+		// kept until signing succeeds so that the request can be retried
+		defer func() {
+			if up != nil {
+				s.uploads.release(up)
+			}
+		}()
+		if maxSize != 0 && up.size > maxSize {
+			hlog.FromRequest(request).Error().Int64("size", up.size).Msg("request too large")
+			return httperror.ErrRequestTooLarge
+		}
+		body = up.reader()
+	}
+	if mod.CheckInput != nil {
+		br := bufio.NewReaderSize(body, signers.InputCheckSize)
+		head, err := br.Peek(signers.InputCheckSize)
+		if err != nil && err != io.EOF {
+			return requestBodyError(err)
+		}
+		if err := mod.CheckInput(head); err != nil {
+			hlog.FromRequest(request).Err(err).Str("sigtype", mod.Name).Msg("invalid input")
+			return httperror.InvalidInputError(err)
+		}
+		body = br
+	}
 	// get key from token and initialize signer context
 	tok := s.tokens[keyConf.Token]
 	if tok == nil {
 		return fmt.Errorf("missing token \"%s\" for key \"%s\"", keyConf.Token, keyName)
 	}
+	release, err := s.limits[keyConf.Token].acquire(request.Context(), priority)
+	if err != nil {
+		hlog.FromRequest(request).Err(err).Str("token", keyConf.Token).Msg("token concurrency limit reached")
+		return err
+	}
+	defer release()
 	cert, opts, err := signinit.Init(request.Context(), mod, tok, keyName, hash, flags)
 	if err != nil {
 		return err
 	}
+	opts.Path = filename
+	opts.SpoolThreshold = s.Config.Server.SpoolThreshold
 	opts.Audit.Attributes["client.ip"] = zhttp.StripPort(request.RemoteAddr)
 	opts.Audit.Attributes["client.filename"] = filename
+	opts.Audit.Attributes["client.request_id"] = zhttp.RequestID(request.Context())
+	opts.Audit.SetMetadata(meta)
 	userInfo.AuditContext(opts.Audit)
 	// sign the request stream and output a binpatch or signature blob
-	counter := readercounter.New(request.Body)
+	counter := readercounter.New(body)
+	signStart := time.Now()
 	blob, err := mod.Sign(counter, cert, *opts)
 	if err != nil {
+		return requestBodyError(err)
+	}
+	opts.Audit.SetPerf(time.Since(signStart))
+	if err := signinit.SubmitTransparency(request.Context(), cert, opts); err != nil {
 		return err
 	}
 	opts.Audit.Attributes["perf.size.in"] = counter.N
@@ -99,14 +197,163 @@ func (s *Server) serveSign(rw http.ResponseWriter, request *http.Request) error
 	if err := signinit.PublishAudit(opts.Audit); err != nil {
 		return err
 	}
+	if up != nil {
+		s.uploads.remove(up)
+		up = nil
+	}
 	ev := hlog.FromRequest(request).Info().
 		Str("key", keyConf.Name()).
 		Str("filename", filename)
 	if mod.FormatLog != nil {
 		ev.Dict("package", mod.FormatLog(opts.Audit))
 	}
+	if len(meta) != 0 {
+		ev.Dict("meta", opts.Audit.AttrsForLog(audit.MetadataPrefix))
+	}
 	ev.Msg("signed package")
 	rw.Header().Set("Content-Type", opts.Audit.GetMimeType())
+	if wantChain, _ := strconv.ParseBool(query.Get("chain")); wantChain && s.Config.Server.SendChain && len(cert.Certificates) != 0 {
+		var chain []byte
+		for _, c := range cert.Certificates {
+			chain = append(chain, c.Raw...)
+		}
+		rw.Header().Set(chainHeader, base64.StdEncoding.EncodeToString(chain))
+	}
 	_, err = rw.Write(blob)
 	return err
 }
+
+// maxRequestSize returns the largest body allowed when signing with these
+// options, or 0 for no limit. Detached signatures are made by streaming the
+// input, so they may be allowed a larger limit.
+func (s *Server) maxRequestSize(mod *signers.Signer, flags *signers.FlagValues) int64 {
+	conf := s.Config.Server
+	if conf.MaxDetachedRequestSize != 0 && mod.DetachedSuffix != nil && mod.DetachedSuffix(flags) != "" {
+		return conf.MaxDetachedRequestSize
+	}
+	return conf.MaxRequestSize
+}
+
+// maxUploadSize returns the largest resumable upload allowed, which must fit
+// whichever format it is eventually signed with
+func (s *Server) maxUploadSize() int64 {
+	conf := s.Config.Server
+	if conf.MaxRequestSize == 0 {
+		return 0
+	}
+	return max(conf.MaxRequestSize, conf.MaxDetachedRequestSize)
+}
+
+// requestBodyError reports a body cut off by the size limit as such
+func requestBodyError(err error) error {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		return httperror.ErrRequestTooLarge
+	}
+	return err
+}
+
+// formatAllowed checks a key or role's allowedformats list. An empty list
+// allows any type.
+func formatAllowed(allowed []string, mod *signers.Signer) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, name := range allowed {
+		if signers.ByName(name) == mod {
+			return true
+		}
+	}
+	return false
+}
+
+// roleDenied checks the request against the roles section. Only the caller's
+// roles that appear there are considered, and if there are any then at least
+// one of them must permit both the signature type and the filename's
+// extension. It returns why the request was refused, or "" if it is allowed.
+func roleDenied(cfg *config.Config, roles []string, mod *signers.Signer, filename string) string {
+	var limited []string
+	for _, role := range roles {
+		roleConf := cfg.Roles[role]
+		if roleConf == nil {
+			continue
+		}
+		limited = append(limited, role)
+		if formatAllowed(roleConf.AllowedFormats, mod) && roleExtensionAllowed(roleConf, filename) {
+			return ""
+		}
+	}
+	if len(limited) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("roles %s do not allow signature type %q for file %q",
+		strings.Join(limited, ", "), mod.Name, filename)
+}
+
+func roleExtensionAllowed(roleConf *config.RoleConfig, filename string) bool {
+	if len(roleConf.AllowedExtensions) == 0 {
+		return true
+	}
+	filename = strings.ToLower(filename)
+	for _, ext := range roleConf.AllowedExtensions {
+		if strings.HasSuffix(filename, strings.ToLower(ext)) {
+			return true
+		}
+	}
+	return false
+}
+
+// auditDenied records a request refused by the roles section
+func (s *Server) auditDenied(request *http.Request, userInfo authmodel.UserInfo, keyConf *config.KeyConfig, mod *signers.Signer, filename, reason string) {
+	info := audit.NewDeniedEvent(keyConf.Name(), mod.Name, reason)
+	info.Attributes["client.ip"] = zhttp.StripPort(request.RemoteAddr)
+	info.Attributes["client.filename"] = filename
+	info.Attributes["client.request_id"] = zhttp.RequestID(request.Context())
+	userInfo.AuditContext(info)
+	if err := signinit.PublishAudit(info); err != nil {
+		hlog.FromRequest(request).Err(err).Msg("failed to audit denied request")
+	}
+}
+
+// checkAllowedFormats rejects configured signature types that don't exist, so
+// that a typo doesn't leave a key unusable
+func checkAllowedFormats(cfg *config.Config) error {
+	for keyName, keyConf := range cfg.Keys {
+		for _, name := range keyConf.AllowedFormats {
+			if signers.ByName(name) == nil {
+				return fmt.Errorf("key \"%s\": unknown signature type \"%s\" in allowedformats", keyName, name)
+			}
+		}
+	}
+	for roleName, roleConf := range cfg.Roles {
+		for _, name := range roleConf.AllowedFormats {
+			if signers.ByName(name) == nil {
+				return fmt.Errorf("role \"%s\": unknown signature type \"%s\" in allowedformats", roleName, name)
+			}
+		}
+		for _, ext := range roleConf.AllowedExtensions {
+			if !strings.HasPrefix(ext, ".") {
+				return fmt.Errorf("role \"%s\": extension \"%s\" in allowedextensions must start with a dot", roleName, ext)
+			}
+		}
+	}
+	return nil
+}
+
+// checkDefaultKeys rejects clients whose default key doesn't exist or isn't
+// one their roles grant access to
+func checkDefaultKeys(cfg *config.Config) error {
+	for name, client := range cfg.Clients {
+		if client.DefaultKey == "" {
+			continue
+		}
+		keyConf, err := cfg.GetKey(client.DefaultKey)
+		if err != nil {
+			return fmt.Errorf("client \"%s\": defaultkey: %w", name, err)
+		}
+		if !slices.ContainsFunc(keyConf.Roles, func(role string) bool { return slices.Contains(client.Roles, role) }) {
+			return fmt.Errorf("client \"%s\": defaultkey \"%s\" is not accessible to the client's roles", name, client.DefaultKey)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,446 @@
+package server
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mind-security/relic/v8/cmdline/shared"
+	"github.com/mind-security/relic/v8/config"
+	"github.com/mind-security/relic/v8/internal/authmodel"
+	"github.com/mind-security/relic/v8/lib/audit"
+	"github.com/mind-security/relic/v8/lib/binpatch"
+	"github.com/mind-security/relic/v8/lib/x509tools"
+	"github.com/mind-security/relic/v8/signers"
+	_ "github.com/mind-security/relic/v8/signers/pecoff"
+	"github.com/mind-security/relic/v8/token"
+	"github.com/mind-security/relic/v8/token/open"
+)
+
+type testUser struct {
+	roles      []string
+	defaultKey string
+}
+
+func (testUser) Allowed(*config.KeyConfig) bool { return true }
+func (testUser) AuditContext(*audit.Info)       {}
+func (u testUser) Identity() *authmodel.Identity {
+	return &authmodel.Identity{Name: "test", Roles: u.roles, DefaultKey: u.defaultKey}
+}
+
+type testAuth struct {
+	roles      []string
+	defaultKey string
+}
+
+func (a testAuth) Authenticate(*http.Request) (authmodel.UserInfo, error) {
+	return testUser{roles: a.roles, defaultKey: a.defaultKey}, nil
+}
+
+func issueCert(t *testing.T, name string, parent *x509.Certificate, parentKey crypto.Signer) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		BasicConstraintsValid: true,
+		IsCA:                  parent == nil || name != "leaf",
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert, key
+}
+
+func writePEM(t *testing.T, path, blockType string, blobs ...[]byte) {
+	var buf bytes.Buffer
+	for _, blob := range blobs {
+		require.NoError(t, pem.Encode(&buf, &pem.Block{Type: blockType, Bytes: blob}))
+	}
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0600))
+}
+
+type signTestEnv struct {
+	s                 *Server
+	cfg               *config.Config
+	dir               string
+	root, inter, leaf *x509.Certificate
+}
+
+// newSignTestEnv sets up a server with a file token holding a leaf key
+// issued through an intermediate, with the whole chain bundled in
+// x509certificate
+func newSignTestEnv(t *testing.T) *signTestEnv {
+	root, rootKey := issueCert(t, "root", nil, nil)
+	inter, interKey := issueCert(t, "intermediate", root, rootKey)
+	leaf, leafKey := issueCert(t, "leaf", inter, interKey)
+	dir := t.TempDir()
+	keyDER, err := x509.MarshalPKCS8PrivateKey(leafKey)
+	require.NoError(t, err)
+	writePEM(t, filepath.Join(dir, "leaf.key"), "PRIVATE KEY", keyDER)
+	writePEM(t, filepath.Join(dir, "leaf.crt"), "CERTIFICATE", leaf.Raw, inter.Raw, root.Raw)
+	cfgPath := filepath.Join(dir, "relic.yml")
+	require.NoError(t, os.WriteFile(cfgPath, []byte(`
+tokens:
+  file:
+    type: file
+keys:
+  leaf:
+    token: file
+    keyfile: `+filepath.Join(dir, "leaf.key")+`
+    x509certificate: `+filepath.Join(dir, "leaf.crt")+`
+`), 0600))
+	cfg, err := config.ReadFile(cfgPath)
+	require.NoError(t, err)
+	cfg.Server = &config.ServerConfig{}
+	shared.CurrentConfig = cfg
+	t.Cleanup(func() { shared.CurrentConfig = nil })
+	tok, err := open.Token(cfg, "file", nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { tok.Close() })
+	s := &Server{
+		Config:  cfg,
+		auth:    testAuth{},
+		realIP:  func(h http.Handler) http.Handler { return h },
+		tokens:  map[string]token.Token{"file": tok},
+		uploads: newUploadStore(cfg.Server),
+	}
+	return &signTestEnv{s: s, cfg: cfg, dir: dir, root: root, inter: inter, leaf: leaf}
+}
+
+// signPE posts a PE file to the server and returns the response
+func (e *signTestEnv) signPE(t *testing.T, query string) *httptest.ResponseRecorder {
+	exe, err := os.ReadFile(pePath)
+	require.NoError(t, err)
+	req := httptest.NewRequest("POST", "/sign?key=leaf&filename=app.exe&sigtype=pe-coff"+query, bytes.NewReader(exe))
+	rec := httptest.NewRecorder()
+	e.s.Handler().ServeHTTP(rec, req)
+	return rec
+}
+
+const pePath = "../functest/packages/WindowsFormsApplication1.exe"
+
+func TestSignSendChain(t *testing.T) {
+	env := newSignTestEnv(t)
+	cfg, dir := env.cfg, env.dir
+	leaf, inter, root := env.leaf, env.inter, env.root
+	sign := func(query string) *httptest.ResponseRecorder {
+		rec := env.signPE(t, query)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		return rec
+	}
+
+	// not returned unless both the server and client want it
+	assert.Empty(t, sign("").Header().Get(chainHeader))
+	assert.Empty(t, sign("&chain=1").Header().Get(chainHeader))
+	cfg.Server.SendChain = true
+	assert.Empty(t, sign("").Header().Get(chainHeader))
+	rec := sign("&chain=1")
+	der, err := base64.StdEncoding.DecodeString(rec.Header().Get(chainHeader))
+	require.NoError(t, err)
+	chain, err := x509.ParseCertificates(der)
+	require.NoError(t, err)
+	assert.Equal(t, []*x509.Certificate{leaf, inter, root}, chain)
+
+	// client verification completes the chain using the returned certs
+	patch, err := binpatch.Load(rec.Body.Bytes())
+	require.NoError(t, err)
+	infile, err := os.Open(pePath)
+	require.NoError(t, err)
+	defer infile.Close()
+	signed := filepath.Join(dir, "signed.exe")
+	require.NoError(t, patch.Apply(infile, signed))
+	f, err := os.Open(signed)
+	require.NoError(t, err)
+	defer f.Close()
+	sigs, err := signers.ByName("pe-coff").Verify(f, signers.VerifyOpts{NoChain: true})
+	require.NoError(t, err)
+	require.Len(t, sigs, 1)
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	verified, err := sigs[0].X509Signature.BuildChain(roots, chain[1:], x509.ExtKeyUsageAny)
+	require.NoError(t, err)
+	assert.Equal(t, []*x509.Certificate{leaf, inter, root}, verified)
+}
+
+// fakeRekor accepts hashedrekord entries whose signature verifies
+func fakeRekor(t *testing.T, leaf *x509.Certificate) *httptest.Server {
+	var index int64
+	return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "/api/v1/log/entries", req.URL.Path)
+		assert.Equal(t, "Bearer sekrit", req.Header.Get("Authorization"))
+		var entry struct {
+			Kind string
+			Spec struct {
+				Signature struct {
+					Content   []byte
+					PublicKey struct{ Content []byte }
+				}
+				Data struct {
+					Hash struct{ Algorithm, Value string }
+				}
+			}
+		}
+		require.NoError(t, json.NewDecoder(req.Body).Decode(&entry))
+		assert.Equal(t, "hashedrekord", entry.Kind)
+		block, _ := pem.Decode(entry.Spec.Signature.PublicKey.Content)
+		require.NotNil(t, block)
+		assert.Equal(t, leaf.Raw, block.Bytes)
+		assert.Equal(t, "sha256", entry.Spec.Data.Hash.Algorithm)
+		digest, err := hex.DecodeString(entry.Spec.Data.Hash.Value)
+		require.NoError(t, err)
+		if err := x509tools.Verify(leaf.PublicKey, crypto.SHA256, digest, entry.Spec.Signature.Content); err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		index++
+		rw.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(rw, `{"abc%d": {"logIndex": %d, "integratedTime": 1700000000, "logID": "c0ffee", "verification": {"inclusionProof": {"treeSize": %d}}}}`, index, index, index)
+	}))
+}
+
+func TestSignTransparency(t *testing.T) {
+	env := newSignTestEnv(t)
+	rekor := fakeRekor(t, env.leaf)
+	defer rekor.Close()
+	env.cfg.AuditFile = filepath.Join(env.dir, "audit.log")
+	env.cfg.Transparency = &config.TransparencyConfig{URL: rekor.URL, AuthToken: "sekrit"}
+
+	rec := env.signPE(t, "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	blob, err := os.ReadFile(env.cfg.AuditFile)
+	require.NoError(t, err)
+	info, err := audit.Parse(blob)
+	require.NoError(t, err)
+	assert.Equal(t, rekor.URL, info.Attributes["tlog.url"])
+	entries, _ := info.Attributes["tlog.entries"].([]interface{})
+	require.Len(t, entries, 1)
+	entry := entries[0].(map[string]interface{})
+	assert.Equal(t, "abc1", entry["uuid"])
+	assert.EqualValues(t, 1, entry["logIndex"])
+	assert.NotNil(t, entry["inclusionProof"])
+
+	// best-effort submission failures are audited but don't fail the signature
+	rekor.Close()
+	require.NoError(t, os.Remove(env.cfg.AuditFile))
+	rec = env.signPE(t, "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	blob, err = os.ReadFile(env.cfg.AuditFile)
+	require.NoError(t, err)
+	info, err = audit.Parse(blob)
+	require.NoError(t, err)
+	assert.NotEmpty(t, info.Attributes["tlog.error"])
+
+	// but required ones do
+	env.cfg.Transparency.Required = true
+	rec = env.signPE(t, "")
+	assert.NotEqual(t, http.StatusOK, rec.Code)
+}
+
+func TestSignAllowedFormats(t *testing.T) {
+	env := newSignTestEnv(t)
+	exe, err := os.ReadFile(pePath)
+	require.NoError(t, err)
+	sign := func(key string) int {
+		req := httptest.NewRequest("POST", "/sign?key="+key+"&filename=app.exe&sigtype=pe-coff", bytes.NewReader(exe))
+		rec := httptest.NewRecorder()
+		env.s.Handler().ServeHTTP(rec, req)
+		return rec.Code
+	}
+	leaf := env.cfg.Keys["leaf"]
+	leaf.AllowedFormats = []string{"pe-coff"}
+	assert.Equal(t, http.StatusOK, sign("leaf"))
+	leaf.AllowedFormats = []string{"rpm"}
+	assert.Equal(t, http.StatusForbidden, sign("leaf"))
+
+	// an alias can only narrow the formats of its target
+	alias := env.cfg.NewKey("alias")
+	alias.Alias = "leaf"
+	assert.Equal(t, http.StatusForbidden, sign("alias"))
+	leaf.AllowedFormats = nil
+	assert.Equal(t, http.StatusOK, sign("alias"))
+	alias.AllowedFormats = []string{"rpm"}
+	assert.Equal(t, http.StatusForbidden, sign("alias"))
+
+	// unknown names are caught at startup
+	alias.AllowedFormats = []string{"pe-coff"}
+	assert.NoError(t, checkAllowedFormats(env.cfg))
+	alias.AllowedFormats = []string{"pe-cof"}
+	assert.ErrorContains(t, checkAllowedFormats(env.cfg), `unknown signature type "pe-cof"`)
+}
+
+func TestSignRoleFormats(t *testing.T) {
+	env := newSignTestEnv(t)
+	env.cfg.AuditFile = filepath.Join(env.dir, "audit.log")
+	exe, err := os.ReadFile(pePath)
+	require.NoError(t, err)
+	sign := func(filename string, roles ...string) *httptest.ResponseRecorder {
+		env.s.auth = testAuth{roles: roles}
+		req := httptest.NewRequest("POST", "/sign?key=leaf&filename="+filename+"&sigtype=pe-coff", bytes.NewReader(exe))
+		rec := httptest.NewRecorder()
+		env.s.Handler().ServeHTTP(rec, req)
+		return rec
+	}
+	env.cfg.Roles = map[string]*config.RoleConfig{
+		"packager": {AllowedFormats: []string{"efi"}},
+		"windows":  {AllowedFormats: []string{"pe-coff"}, AllowedExtensions: []string{".exe", ".DLL"}},
+	}
+	// roles without an entry aren't limited
+	assert.Equal(t, http.StatusOK, sign("app.exe").Code)
+	assert.Equal(t, http.StatusOK, sign("app.exe", "builder").Code)
+	assert.Equal(t, http.StatusOK, sign("APP.EXE", "windows").Code)
+	assert.Equal(t, http.StatusOK, sign("app.dll", "packager", "windows").Code)
+
+	rec := sign("app.exe", "packager", "builder")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Contains(t, rec.Body.String(), "role-not-allowed")
+	assert.Equal(t, http.StatusForbidden, sign("app.sys", "windows").Code)
+
+	// the last denial is in the audit log with its reason
+	blob, err := os.ReadFile(env.cfg.AuditFile)
+	require.NoError(t, err)
+	lines := bytes.Split(bytes.TrimSpace(blob), []byte("\n"))
+	info, err := audit.Parse(lines[len(lines)-1])
+	require.NoError(t, err)
+	assert.Equal(t, audit.EventSignDenied, info.EventType())
+	assert.Equal(t, "leaf", info.Attributes["sig.keyname"])
+	assert.Equal(t, "app.sys", info.Attributes["client.filename"])
+	assert.Equal(t, `roles windows do not allow signature type "pe-coff" for file "app.sys"`, info.Attributes["deny.reason"])
+
+	// unknown names are caught at startup
+	assert.NoError(t, checkAllowedFormats(env.cfg))
+	env.cfg.Roles["packager"].AllowedFormats = []string{"rmp"}
+	assert.ErrorContains(t, checkAllowedFormats(env.cfg), `role "packager": unknown signature type "rmp"`)
+	env.cfg.Roles["packager"].AllowedFormats = nil
+	env.cfg.Roles["packager"].AllowedExtensions = []string{"rpm"}
+	assert.ErrorContains(t, checkAllowedFormats(env.cfg), "must start with a dot")
+}
+
+func TestSignDefaultKey(t *testing.T) {
+	env := newSignTestEnv(t)
+	exe, err := os.ReadFile(pePath)
+	require.NoError(t, err)
+	sign := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/sign?filename=app.exe&sigtype=pe-coff"+query, bytes.NewReader(exe))
+		rec := httptest.NewRecorder()
+		env.s.Handler().ServeHTTP(rec, req)
+		return rec
+	}
+	// no key and no default
+	rec := sign("")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "key")
+
+	env.s.auth = testAuth{defaultKey: "leaf"}
+	rec = sign("")
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	// an explicit key still wins
+	assert.Equal(t, http.StatusForbidden, sign("&key=other").Code)
+
+	// the default has to exist and be granted by the client's roles
+	env.cfg.Keys["leaf"].Roles = []string{"builder"}
+	env.cfg.Clients = map[string]*config.ClientConfig{"ci": {Roles: []string{"builder"}, DefaultKey: "leaf"}}
+	assert.NoError(t, checkDefaultKeys(env.cfg))
+	env.cfg.Clients["ci"].Roles = []string{"tester"}
+	assert.ErrorContains(t, checkDefaultKeys(env.cfg), `client "ci": defaultkey "leaf" is not accessible`)
+	env.cfg.Clients["ci"].DefaultKey = "other"
+	assert.ErrorContains(t, checkDefaultKeys(env.cfg), `client "ci": defaultkey`)
+}
+
+func TestSignPerf(t *testing.T) {
+	env := newSignTestEnv(t)
+	env.cfg.AuditFile = filepath.Join(env.dir, "audit.log")
+
+	rec := env.signPE(t, "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	blob, err := os.ReadFile(env.cfg.AuditFile)
+	require.NoError(t, err)
+	info, err := audit.Parse(blob)
+	require.NoError(t, err)
+	for _, name := range []string{"perf.token.ms", "perf.timestamp.ms", "perf.digest.ms", "perf.elapsed.ms"} {
+		assert.IsType(t, float64(0), info.Attributes[name], name)
+	}
+}
+
+func TestSignMetadata(t *testing.T) {
+	env := newSignTestEnv(t)
+	env.cfg.AuditFile = filepath.Join(env.dir, "audit.log")
+
+	rec := env.signPE(t, "&meta=product%3Dfoo&meta=version%3D1.2.3")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	blob, err := os.ReadFile(env.cfg.AuditFile)
+	require.NoError(t, err)
+	info, err := audit.Parse(blob)
+	require.NoError(t, err)
+	assert.Equal(t, "foo", info.Attributes["client.meta.product"])
+	assert.Equal(t, "1.2.3", info.Attributes["client.meta.version"])
+
+	rec = env.signPE(t, "&meta=bad%20key%3Dfoo")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "invalid-parameter")
+}
+
+func TestSignRequestLimits(t *testing.T) {
+	env := newSignTestEnv(t)
+	exe, err := os.ReadFile(pePath)
+	require.NoError(t, err)
+	post := func(body []byte, contentLength int64) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/sign?key=leaf&filename=app.exe&sigtype=pe-coff", bytes.NewReader(body))
+		req.ContentLength = contentLength
+		rec := httptest.NewRecorder()
+		env.s.Handler().ServeHTTP(rec, req)
+		return rec
+	}
+
+	// garbage is refused before signing
+	rec := post([]byte("this is not an executable"), -1)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "invalid-input")
+	broken := bytes.Clone(exe)
+	copy(broken[0x80:], "XX")
+	rec = post(broken, int64(len(broken)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "missing PE header")
+
+	// size is checked up front when known, and while reading when not
+	env.cfg.Server.MaxRequestSize = int64(len(exe)) - 1
+	rec = post(exe, int64(len(exe)))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	rec = post(exe, -1)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code, rec.Body.String())
+	env.cfg.Server.MaxRequestSize = int64(len(exe))
+	rec = post(exe, -1)
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	// the detached limit doesn't apply to formats that patch the input
+	env.cfg.Server.MaxRequestSize = 100
+	env.cfg.Server.MaxDetachedRequestSize = int64(len(exe))
+	rec = post(exe, int64(len(exe)))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+}
@@ -0,0 +1,127 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package server
+
+import (
+	"bytes"
+	"crypto/x509"
+	"net/http"
+	"sort"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/mind-security/relic/v8/config"
+	"github.com/mind-security/relic/v8/internal/httperror"
+	"github.com/mind-security/relic/v8/internal/signinit"
+	"github.com/mind-security/relic/v8/lib/certloader"
+)
+
+// serveTrustX509 returns the roots that a verifier should trust for signatures
+// made by this server as PEM: the configured trustroots bundle, plus the
+// self-signed root at the end of each visible key's chain
+func (s *Server) serveTrustX509(rw http.ResponseWriter, req *http.Request) error {
+	var roots []*x509.Certificate
+	if path := s.Config.Server.TrustRoots; path != "" {
+		blob, err := config.ReadFileOrSecret(path)
+		if err != nil {
+			return err
+		}
+		roots, err = certloader.ParseX509Certificates(blob)
+		if err != nil {
+			return err
+		}
+	}
+	for _, cert := range s.visibleCerts(req) {
+		if len(cert.Certificates) == 0 {
+			continue
+		}
+		last := cert.Certificates[len(cert.Certificates)-1]
+		if bytes.Equal(last.RawIssuer, last.RawSubject) && last.CheckSignatureFrom(last) == nil {
+			roots = append(roots, last)
+		}
+	}
+	seen := make(map[string]bool)
+	var unique []*x509.Certificate
+	for _, root := range roots {
+		if !seen[string(root.Raw)] {
+			seen[string(root.Raw)] = true
+			unique = append(unique, root)
+		}
+	}
+	if len(unique) == 0 {
+		return httperror.NoTrustRootsError("x509")
+	}
+	blob, err := marshalX509Cert(unique)
+	if err != nil {
+		return err
+	}
+	return writeCert(rw, "application/pem-certificate-chain", blob)
+}
+
+// serveTrustPGP returns the PGP public keys of all visible keys as one armored
+// keyring
+func (s *Server) serveTrustPGP(rw http.ResponseWriter, req *http.Request) error {
+	var entities []*openpgp.Entity
+	seen := make(map[string]bool)
+	for _, cert := range s.visibleCerts(req) {
+		if cert.PgpKey == nil {
+			continue
+		}
+		fp := string(cert.PgpKey.PrimaryKey.Fingerprint)
+		if !seen[fp] {
+			seen[fp] = true
+			entities = append(entities, cert.PgpKey)
+		}
+	}
+	if len(entities) == 0 {
+		return httperror.NoTrustRootsError("pgp")
+	}
+	blob, err := marshalPGPCert(entities...)
+	if err != nil {
+		return err
+	}
+	return writeCert(rw, "application/pgp-keys", blob)
+}
+
+// visibleCerts loads the certificates of every key the client can see. Keys
+// that fail to load are logged and skipped.
+func (s *Server) visibleCerts(req *http.Request) []*certloader.Certificate {
+	names := make([]string, 0, len(s.Config.Keys))
+	for name := range s.Config.Keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var certs []*certloader.Certificate
+	for _, name := range names {
+		keyConf, err := s.visibleKey(req, name)
+		if err != nil {
+			continue
+		}
+		tok := s.tokens[keyConf.Token]
+		if tok == nil {
+			continue
+		}
+		cert, _, err := signinit.InitKey(req.Context(), tok, keyConf.Name())
+		if err != nil {
+			hlog.FromRequest(req).Err(err).Str("key", name).Msg("failed to load key for trust roots")
+			continue
+		}
+		certs = append(certs, cert)
+	}
+	return certs
+}
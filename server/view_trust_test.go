@@ -0,0 +1,58 @@
+package server
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrustRoots(t *testing.T) {
+	env := newSignTestEnv(t)
+	get := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		rec := httptest.NewRecorder()
+		env.s.Handler().ServeHTTP(rec, req)
+		return rec
+	}
+	parse := func(rec *httptest.ResponseRecorder) [][]byte {
+		var certs [][]byte
+		rest := rec.Body.Bytes()
+		for {
+			var block *pem.Block
+			block, rest = pem.Decode(rest)
+			if block == nil {
+				return certs
+			}
+			certs = append(certs, block.Bytes)
+		}
+	}
+
+	// the root of the key's chain
+	rec := get("/trust/x509")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, [][]byte{env.root.Raw}, parse(rec))
+
+	// plus the configured bundle, without repeats
+	other, _ := issueCert(t, "other root", nil, nil)
+	bundle := filepath.Join(env.dir, "roots.pem")
+	writePEM(t, bundle, "CERTIFICATE", other.Raw, env.root.Raw)
+	env.cfg.Server.TrustRoots = bundle
+	rec = get("/trust/x509")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, [][]byte{other.Raw, env.root.Raw}, parse(rec))
+
+	// hidden keys don't contribute
+	env.cfg.Server.TrustRoots = ""
+	env.cfg.Keys["leaf"].Hide = true
+	assert.Equal(t, http.StatusNotFound, get("/trust/x509").Code)
+
+	// no key has a PGP certificate
+	rec = get("/trust/pgp")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Contains(t, rec.Body.String(), "certificate-not-defined")
+}
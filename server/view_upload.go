@@ -0,0 +1,82 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/mind-security/relic/v8/internal/authmodel"
+	"github.com/mind-security/relic/v8/internal/httperror"
+)
+
+// uploadStatus tells the client how much of an upload has been received, and
+// thus where to resume
+type uploadStatus struct {
+	ID     string
+	Offset int64
+}
+
+func (s *Server) serveCreateUpload(rw http.ResponseWriter, req *http.Request) error {
+	u, err := s.uploads.create(uploadOwner(authmodel.RequestInfo(req)))
+	if err != nil {
+		return err
+	}
+	hlog.FromRequest(req).Info().Str("upload", u.id).Msg("started upload")
+	rw.WriteHeader(http.StatusCreated)
+	return writeJSON(rw, uploadStatus{ID: u.id})
+}
+
+func (s *Server) serveUploadStatus(rw http.ResponseWriter, req *http.Request) error {
+	u, err := s.uploads.acquire(chi.URLParam(req, "upload"), uploadOwner(authmodel.RequestInfo(req)))
+	if err != nil {
+		return err
+	}
+	status := uploadStatus{ID: u.id, Offset: u.size}
+	s.uploads.release(u)
+	return writeJSON(rw, status)
+}
+
+func (s *Server) serveUploadChunk(rw http.ResponseWriter, req *http.Request) error {
+	offset, err := strconv.ParseInt(req.URL.Query().Get("offset"), 10, 64)
+	if err != nil {
+		return httperror.MissingParameterError("offset")
+	}
+	u, err := s.uploads.acquire(chi.URLParam(req, "upload"), uploadOwner(authmodel.RequestInfo(req)))
+	if err != nil {
+		return err
+	}
+	defer s.uploads.release(u)
+	if err := u.write(offset, req.Body, s.maxUploadSize()); err != nil {
+		hlog.FromRequest(req).Err(err).Str("upload", u.id).Int64("offset", offset).Msg("upload chunk failed")
+		return err
+	}
+	return writeJSON(rw, uploadStatus{ID: u.id, Offset: u.size})
+}
+
+func (s *Server) serveDeleteUpload(rw http.ResponseWriter, req *http.Request) error {
+	u, err := s.uploads.acquire(chi.URLParam(req, "upload"), uploadOwner(authmodel.RequestInfo(req)))
+	if err != nil {
+		return err
+	}
+	s.uploads.remove(u)
+	rw.WriteHeader(http.StatusNoContent)
+	return nil
+}
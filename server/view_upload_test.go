@@ -0,0 +1,146 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mind-security/relic/v8/lib/binpatch"
+)
+
+func (e *signTestEnv) do(t *testing.T, method, target string, body io.Reader) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, body)
+	rec := httptest.NewRecorder()
+	e.s.Handler().ServeHTTP(rec, req)
+	return rec
+}
+
+func decodeUploadStatus(t *testing.T, rec *httptest.ResponseRecorder) uploadStatus {
+	var status uploadStatus
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+	return status
+}
+
+func TestSignUpload(t *testing.T) {
+	env := newSignTestEnv(t)
+	env.cfg.Server.UploadDir = t.TempDir()
+	env.s.uploads = newUploadStore(env.cfg.Server)
+	exe, err := os.ReadFile(pePath)
+	require.NoError(t, err)
+
+	rec := env.do(t, "POST", "/uploads", nil)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	id := decodeUploadStatus(t, rec).ID
+	require.NotEmpty(t, id)
+	// send the file in two chunks, with a retry of the first one
+	half := len(exe) / 2
+	rec = env.do(t, "PUT", "/uploads/"+id+"?offset=0", bytes.NewReader(exe[:half]))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	rec = env.do(t, "PUT", "/uploads/"+id+"?offset=0", bytes.NewReader(exe[:half]))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	rec = env.do(t, "GET", "/uploads/"+id, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, int64(half), decodeUploadStatus(t, rec).Offset)
+	rec = env.do(t, "PUT", "/uploads/"+id+"?offset="+strconv.Itoa(half), bytes.NewReader(exe[half:]))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, int64(len(exe)), decodeUploadStatus(t, rec).Offset)
+
+	// an unusable request leaves the upload in place
+	rec = env.do(t, "POST", "/sign?key=leaf&filename=app.exe&sigtype=pe-coff&digest=bogus&upload="+id, nil)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = env.do(t, "POST", "/sign?key=leaf&filename=app.exe&sigtype=pe-coff&upload="+id, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	patch, err := binpatch.Load(rec.Body.Bytes())
+	require.NoError(t, err)
+	assert.NotEmpty(t, patch.Patches)
+
+	// consumed by signing
+	rec = env.do(t, "GET", "/uploads/"+id, nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	entries, err := os.ReadDir(env.cfg.Server.UploadDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestUploadExpire(t *testing.T) {
+	env := newSignTestEnv(t)
+	env.cfg.Server.UploadDir = t.TempDir()
+	env.s.uploads = newUploadStore(env.cfg.Server)
+	rec := env.do(t, "POST", "/uploads", nil)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	id := decodeUploadStatus(t, rec).ID
+	rec = env.do(t, "PUT", "/uploads/"+id+"?offset=0", bytes.NewReader([]byte("partial")))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	env.s.uploads.expire(time.Now().Add(-time.Hour))
+	rec = env.do(t, "GET", "/uploads/"+id, nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	env.s.uploads.expire(time.Now().Add(time.Second))
+	rec = env.do(t, "GET", "/uploads/"+id, nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	entries, err := os.ReadDir(env.cfg.Server.UploadDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestUploadBusy(t *testing.T) {
+	env := newSignTestEnv(t)
+	env.cfg.Server.UploadDir = t.TempDir()
+	env.s.uploads = newUploadStore(env.cfg.Server)
+	rec := env.do(t, "POST", "/uploads", nil)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	id := decodeUploadStatus(t, rec).ID
+
+	// a chunk whose connection stalls keeps the upload busy
+	pr, pw := io.Pipe()
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- env.do(t, "PUT", "/uploads/"+id+"?offset=0", pr) }()
+	_, err := pw.Write([]byte("partial"))
+	require.NoError(t, err)
+	// other requests fail right away instead of waiting for it
+	rec = env.do(t, "PUT", "/uploads/"+id+"?offset=0", bytes.NewReader([]byte("chunk")))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), "upload-busy")
+	rec = env.do(t, "GET", "/uploads/"+id, nil)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	// once the stalled chunk is dropped the client can resume
+	pw.CloseWithError(io.ErrUnexpectedEOF)
+	assert.NotEqual(t, http.StatusOK, (<-done).Code)
+	rec = env.do(t, "PUT", "/uploads/"+id+"?offset=0", bytes.NewReader([]byte("chunk")))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, int64(5), decodeUploadStatus(t, rec).Offset)
+}
+
+func TestUploadLimit(t *testing.T) {
+	env := newSignTestEnv(t)
+	env.cfg.Server.UploadDir = t.TempDir()
+	env.cfg.Server.MaxUploads = 2
+	env.s.uploads = newUploadStore(env.cfg.Server)
+	var ids []string
+	for i := 0; i < 2; i++ {
+		rec := env.do(t, "POST", "/uploads", nil)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		ids = append(ids, decodeUploadStatus(t, rec).ID)
+	}
+	rec := env.do(t, "POST", "/uploads", nil)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	entries, err := os.ReadDir(env.cfg.Server.UploadDir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	// finishing one makes room for another
+	rec = env.do(t, "DELETE", "/uploads/"+ids[0], nil)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	rec = env.do(t, "POST", "/uploads", nil)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+}
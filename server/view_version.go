@@ -0,0 +1,47 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/mind-security/relic/v8/config"
+	"github.com/mind-security/relic/v8/signers"
+)
+
+// serverFeatures lists the optional parts of the API that this server
+// supports, so that clients can tell what an older server is missing
+var serverFeatures = []string{"keyinfo", "meta", "trust", "uploads", "whoami"}
+
+type versionInfo struct {
+	Version  string
+	Uptime   int64 // seconds since the server started
+	Formats  []string
+	Features []string
+}
+
+// serveVersion tells clients which relic release they are talking to and
+// which signature types it can make
+func (s *Server) serveVersion(rw http.ResponseWriter, req *http.Request) error {
+	return writeJSON(rw, versionInfo{
+		Version:  config.Version,
+		Uptime:   int64(time.Since(s.started) / time.Second),
+		Formats:  signers.Names(),
+		Features: serverFeatures,
+	})
+}
@@ -0,0 +1,27 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mind-security/relic/v8/config"
+)
+
+func TestVersion(t *testing.T) {
+	env := newSignTestEnv(t)
+	req := httptest.NewRequest("GET", "/version", nil)
+	rec := httptest.NewRecorder()
+	env.s.Handler().ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var info versionInfo
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &info))
+	assert.Equal(t, config.Version, info.Version)
+	assert.Contains(t, info.Formats, "pe-coff")
+	assert.NotContains(t, info.Formats, "")
+	assert.Contains(t, info.Features, "uploads")
+}
@@ -0,0 +1,34 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package server
+
+import (
+	"net/http"
+
+	"github.com/mind-security/relic/v8/internal/authmodel"
+)
+
+// serveWhoami reports how the server sees the caller. It is not behind the
+// authentication middleware so that callers who are not recognized get an
+// explanation instead of a bare 401.
+func (s *Server) serveWhoami(rw http.ResponseWriter, req *http.Request) error {
+	ident, err := authmodel.Whoami(s.auth, req)
+	if err != nil {
+		return err
+	}
+	return writeJSON(rw, ident)
+}
@@ -0,0 +1,223 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package sign signs files in-process using keys from a relic configuration,
+// for programs that embed relic instead of running the command-line tool.
+//
+// PGP, RPM and PE/COFF signing are available by default. Other formats can be
+// enabled by importing their signer module, e.g.:
+//
+//	import _ "github.com/mind-security/relic/v8/signers/jar"
+package sign
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/mind-security/relic/v8/config"
+	"github.com/mind-security/relic/v8/internal/signinit"
+	"github.com/mind-security/relic/v8/lib/audit"
+	"github.com/mind-security/relic/v8/lib/passprompt"
+	"github.com/mind-security/relic/v8/lib/pkcs9"
+	"github.com/mind-security/relic/v8/signers"
+	"github.com/mind-security/relic/v8/token"
+	"github.com/mind-security/relic/v8/token/open"
+
+	_ "github.com/mind-security/relic/v8/signers/pecoff"
+	_ "github.com/mind-security/relic/v8/signers/pgp"
+	_ "github.com/mind-security/relic/v8/signers/rpm"
+)
+
+// Signer signs files with a single key
+type Signer struct {
+	cfg     *config.Config
+	keyName string
+	tok     token.Token
+
+	tsOnce sync.Once
+	ts     pkcs9.Timestamper
+	tsErr  error
+}
+
+// Options control a single signing operation
+type Options struct {
+	// Hash is the digest algorithm to use. If zero, the key's hashalgorithm
+	// setting is used, or else a digest matching the strength of the key.
+	Hash crypto.Hash
+	// Flags holds signer-specific options by their command-line name, e.g.
+	// "armor" for PGP or "page-hashes" for PE/COFF
+	Flags map[string]string
+}
+
+// Result is the outcome of a successful signing operation
+type Result struct {
+	// Signed holds the signed file, or for detached formats such as PGP, the
+	// signature
+	Signed []byte
+	// Format is the name of the signer module that was used
+	Format string
+	// Audit describes the signature
+	Audit *audit.Info
+}
+
+// New opens the token holding keyName. prompt is used to get the PIN if the
+// token configuration doesn't supply one, and may be nil.
+func New(cfg *config.Config, keyName string, prompt passprompt.PasswordGetter) (*Signer, error) {
+	keyConf, err := cfg.GetKey(keyName)
+	if err != nil {
+		return nil, err
+	}
+	tok, err := open.Token(cfg, keyConf.Token, prompt)
+	if err != nil {
+		return nil, err
+	}
+	return &Signer{cfg: cfg, keyName: keyName, tok: tok}, nil
+}
+
+// Close the underlying token
+func (s *Signer) Close() error {
+	return s.tok.Close()
+}
+
+// Sign the file at path and return the result. The file itself is not
+// modified. format is the name of a signer module, or empty to detect it from
+// the file. Cancelling ctx aborts token operations where the token supports
+// it.
+func (s *Signer) Sign(ctx context.Context, format, path string, opts Options) (*Result, error) {
+	mod, err := signers.ByFile(path, format)
+	if err != nil {
+		return nil, err
+	}
+	if mod.Sign == nil {
+		return nil, fmt.Errorf("can't sign files of type: %s", mod.Name)
+	}
+	flags, err := flagValues(mod, opts.Flags)
+	if err != nil {
+		return nil, err
+	}
+	cert, sopts, err := signinit.InitWith(ctx, mod, s.tok, s.keyName, opts.Hash, flags, s.getTimestamper)
+	if err != nil {
+		return nil, err
+	}
+	sopts.Path = path
+	infile, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer infile.Close()
+	transform, err := mod.GetTransform(infile, *sopts)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := transform.GetReader()
+	if err != nil {
+		return nil, err
+	}
+	blob, err := mod.Sign(stream, cert, *sopts)
+	if err != nil {
+		return nil, err
+	}
+	// apply the result to a scratch copy so the input is left alone
+	tempdir, err := os.MkdirTemp("", "relic-sign-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tempdir)
+	outpath := filepath.Join(tempdir, filepath.Base(path))
+	if err := transform.Apply(outpath, sopts.Audit.GetMimeType(), bytes.NewReader(blob)); err != nil {
+		return nil, err
+	}
+	if mod.Fixup != nil {
+		if err := fixup(mod, outpath); err != nil {
+			return nil, err
+		}
+	}
+	signed, err := os.ReadFile(outpath)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{Signed: signed, Format: mod.Name, Audit: sopts.Audit}, nil
+}
+
+// SignReader signs the contents of r. It is spooled to a temporary file
+// first, so format must name a signer module unless name has an extension
+// that identifies it.
+func (s *Signer) SignReader(ctx context.Context, format, name string, r io.Reader, opts Options) (*Result, error) {
+	if name == "" {
+		name = "input"
+	}
+	tempdir, err := os.MkdirTemp("", "relic-sign-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tempdir)
+	path := filepath.Join(tempdir, filepath.Base(name))
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	_, err = io.Copy(f, r)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+	return s.Sign(ctx, format, path, opts)
+}
+
+func (s *Signer) getTimestamper() (pkcs9.Timestamper, error) {
+	s.tsOnce.Do(func() {
+		s.ts, s.tsErr = signinit.NewTimestamper(s.cfg)
+	})
+	return s.ts, s.tsErr
+}
+
+// flagValues converts a map of options to FlagValues, rejecting any that
+// the module doesn't define
+func flagValues(mod *signers.Signer, m map[string]string) (*signers.FlagValues, error) {
+	q := make(url.Values, len(m))
+	for k, v := range m {
+		q.Set(k, v)
+	}
+	flags, err := mod.FlagsFromQuery(q)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range m {
+		if _, ok := flags.Values[k]; !ok && v != "" {
+			return nil, fmt.Errorf("option %q is not defined for signature type %q", k, mod.Name)
+		}
+	}
+	return flags, nil
+}
+
+func fixup(mod *signers.Signer, path string) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	err = mod.Fixup(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
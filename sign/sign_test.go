@@ -0,0 +1,133 @@
+package sign
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mind-security/relic/v8/config"
+	"github.com/mind-security/relic/v8/internal/signinit"
+	"github.com/mind-security/relic/v8/lib/audit"
+	"github.com/mind-security/relic/v8/lib/binpatch"
+	"github.com/mind-security/relic/v8/lib/certloader"
+	"github.com/mind-security/relic/v8/signers"
+	"github.com/mind-security/relic/v8/signers/pecoff"
+)
+
+const testConfig = `
+tokens:
+  file:
+    type: file
+keys:
+  rsa2048:
+    token: file
+    keyfile: ../functest/testkeys/rsa2048.key
+    x509certificate: ../functest/testkeys/rsa2048.crt
+`
+
+func newTestSigner(t *testing.T) *Signer {
+	fp := filepath.Join(t.TempDir(), "relic.yml")
+	require.NoError(t, os.WriteFile(fp, []byte(testConfig), 0600))
+	cfg, err := config.ReadFile(fp)
+	require.NoError(t, err)
+	s, err := New(cfg, "rsa2048", nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestSignPE(t *testing.T) {
+	s := newTestSigner(t)
+	const path = "../functest/packages/WindowsFormsApplication1.exe"
+	before, err := os.ReadFile(path)
+	require.NoError(t, err)
+	res, err := s.Sign(context.Background(), "", path, Options{Hash: crypto.SHA256})
+	require.NoError(t, err)
+	assert.Equal(t, "pe-coff", res.Format)
+	assert.Equal(t, "rsa2048", res.Audit.Attributes["sig.keyname"])
+	// input is untouched
+	after, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, before, after)
+
+	// result verifies
+	out := filepath.Join(t.TempDir(), "signed.exe")
+	require.NoError(t, os.WriteFile(out, res.Signed, 0600))
+	f, err := os.Open(out)
+	require.NoError(t, err)
+	defer f.Close()
+	sigs, err := signers.ByName("pe-coff").Verify(f, signers.VerifyOpts{NoChain: true})
+	require.NoError(t, err)
+	require.Len(t, sigs, 1)
+	assert.Equal(t, "rsa2048", sigs[0].X509Signature.Certificate.Subject.CommonName)
+}
+
+func TestSignReader(t *testing.T) {
+	s := newTestSigner(t)
+	blob, err := os.ReadFile("../functest/packages/WindowsFormsApplication1.exe")
+	require.NoError(t, err)
+	res, err := s.SignReader(context.Background(), "pe-coff", "", bytes.NewReader(blob), Options{})
+	require.NoError(t, err)
+	assert.NotEqual(t, blob, res.Signed)
+}
+
+func TestBadOptions(t *testing.T) {
+	s := newTestSigner(t)
+	_, err := s.Sign(context.Background(), "", "../functest/packages/WindowsFormsApplication1.exe", Options{
+		Flags: map[string]string{"no-such-option": "1"},
+	})
+	assert.ErrorContains(t, err, "no-such-option")
+	_, err = s.Sign(context.Background(), "no-such-format", "../functest/packages/WindowsFormsApplication1.exe", Options{})
+	assert.Error(t, err)
+}
+
+func TestDeprecatedDigest(t *testing.T) {
+	const path = "../functest/packages/WindowsFormsApplication1.exe"
+	// signing refuses SHA-1
+	s := newTestSigner(t)
+	_, err := s.Sign(context.Background(), "", path, Options{Hash: crypto.SHA1})
+	var deprecated signinit.ErrDeprecatedHash
+	require.ErrorAs(t, err, &deprecated)
+	assert.Equal(t, crypto.SHA1, deprecated.Hash)
+
+	// make an old-style artifact by going around the signing policy
+	cert, err := certloader.LoadX509KeyPair("../functest/testkeys/rsa2048.crt", "../functest/testkeys/rsa2048.key")
+	require.NoError(t, err)
+	flags, err := pecoff.PeSigner.FlagsFromQuery(nil)
+	require.NoError(t, err)
+	infile, err := os.Open(path)
+	require.NoError(t, err)
+	defer infile.Close()
+	blob, err := pecoff.PeSigner.Sign(infile, cert, signers.SignOpts{
+		Hash:  crypto.SHA1,
+		Time:  time.Now(),
+		Flags: flags,
+		Audit: audit.New("rsa2048", "pe-coff", crypto.SHA1),
+	})
+	require.NoError(t, err)
+	patch, err := binpatch.Load(blob)
+	require.NoError(t, err)
+	signed := filepath.Join(t.TempDir(), "signed.exe")
+	require.NoError(t, patch.Apply(infile, signed))
+	f, err := os.Open(signed)
+	require.NoError(t, err)
+	defer f.Close()
+	sigs, err := pecoff.PeSigner.Verify(f, signers.VerifyOpts{NoChain: true})
+	require.NoError(t, err)
+	require.Len(t, sigs, 1)
+
+	// verifying accepts it with a warning by default, or rejects it when strict
+	warnings, err := signers.AlgorithmLenient.Check(sigs[0])
+	require.NoError(t, err)
+	require.NotEmpty(t, warnings)
+	assert.Contains(t, warnings[0], "SHA-1")
+	_, err = signers.AlgorithmStrict.Check(sigs[0])
+	assert.ErrorContains(t, err, "deprecated digest SHA-1")
+}
@@ -0,0 +1,57 @@
+package sign
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mind-security/relic/v8/signers"
+	"github.com/mind-security/relic/v8/signers/vsix"
+)
+
+func verifyVSIX(t *testing.T, blob []byte) ([]*signers.Signature, error) {
+	fp := filepath.Join(t.TempDir(), "signed.vsix")
+	require.NoError(t, os.WriteFile(fp, blob, 0600))
+	f, err := os.Open(fp)
+	require.NoError(t, err)
+	defer f.Close()
+	return vsix.Signer.Verify(f, signers.VerifyOpts{NoChain: true})
+}
+
+func TestSignVSIX(t *testing.T) {
+	s := newTestSigner(t)
+	res, err := s.Sign(context.Background(), "vsix", "../functest/packages/VSIXProject1.vsix", Options{})
+	require.NoError(t, err)
+	sigs, err := verifyVSIX(t, res.Signed)
+	require.NoError(t, err)
+	require.Len(t, sigs, 1)
+	assert.Equal(t, "rsa2048", sigs[0].X509Signature.Certificate.Subject.CommonName)
+
+	// a part added after signing is not covered by the signature
+	inz, err := zip.NewReader(bytes.NewReader(res.Signed), int64(len(res.Signed)))
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, f := range inz.File {
+		r, err := f.OpenRaw()
+		require.NoError(t, err)
+		fw, err := w.CreateRaw(&f.FileHeader)
+		require.NoError(t, err)
+		_, err = io.Copy(fw, r)
+		require.NoError(t, err)
+	}
+	fw, err := w.Create("extra.dll")
+	require.NoError(t, err)
+	_, err = fw.Write([]byte("MZ"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	_, err = verifyVSIX(t, buf.Bytes())
+	assert.ErrorContains(t, err, "not covered by signature: extra.dll")
+}
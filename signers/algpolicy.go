@@ -0,0 +1,82 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package signers
+
+import (
+	"crypto"
+	"fmt"
+	"strings"
+
+	"github.com/mind-security/relic/v8/lib/x509tools"
+)
+
+// AlgorithmPolicy decides how verification treats signatures made with
+// deprecated digest algorithms such as SHA-1. Signing always refuses them.
+type AlgorithmPolicy int
+
+const (
+	// AlgorithmLenient accepts deprecated algorithms with a warning, so that
+	// old artifacts can still be verified
+	AlgorithmLenient AlgorithmPolicy = iota
+	// AlgorithmStrict rejects signatures that use deprecated algorithms
+	AlgorithmStrict
+)
+
+func ParseAlgorithmPolicy(s string) (AlgorithmPolicy, error) {
+	switch strings.ToLower(s) {
+	case "", "lenient":
+		return AlgorithmLenient, nil
+	case "strict":
+		return AlgorithmStrict, nil
+	default:
+		return 0, fmt.Errorf("unknown algorithm policy %q, expected strict or lenient", s)
+	}
+}
+
+func (p AlgorithmPolicy) String() string {
+	if p == AlgorithmStrict {
+		return "strict"
+	}
+	return "lenient"
+}
+
+// Check the digest algorithms used by a verified signature and its timestamp.
+// Under the lenient policy, deprecated algorithms are returned as warnings;
+// under the strict policy they are an error.
+func (p AlgorithmPolicy) Check(sig *Signature) (warnings []string, err error) {
+	var found []string
+	add := func(what string, hash crypto.Hash) {
+		if x509tools.IsDeprecatedHash(hash) {
+			found = append(found, fmt.Sprintf("%s uses deprecated digest %s", what, hash))
+		}
+	}
+	add("signature", sig.Hash)
+	if ts := sig.X509Signature; ts != nil {
+		if ts.SignerInfo != nil {
+			if hash, ok := x509tools.PkixDigestToHash(ts.SignerInfo.DigestAlgorithm); ok && hash != sig.Hash {
+				add("signer info", hash)
+			}
+		}
+		if ts.CounterSignature != nil {
+			add("timestamp", ts.CounterSignature.Hash)
+		}
+	}
+	if len(found) != 0 && p == AlgorithmStrict {
+		return nil, fmt.Errorf("%s", found[0])
+	}
+	return found, nil
+}
@@ -20,9 +20,12 @@ import (
 	"bytes"
 	"crypto"
 	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
 	"encoding/binary"
 	"errors"
 	"io"
+	"slices"
 
 	"github.com/mind-security/relic/v8/lib/binpatch"
 	"github.com/mind-security/relic/v8/lib/certloader"
@@ -32,17 +35,37 @@ import (
 
 type Digest struct {
 	inz    *zipslicer.Directory
-	hash   crypto.Hash
-	value  []byte
+	hashes []crypto.Hash
+	values [][]byte
 	sigLoc int64
 }
 
-func digestApkStream(r io.Reader, hash crypto.Hash) (*Digest, error) {
+func digestApkStream(r io.Reader, hashes ...crypto.Hash) (*Digest, error) {
 	inz, err := zipslicer.ReadZipTar(r)
 	if err != nil {
 		return nil, err
 	}
-	hasher := newMerkleHasher([]crypto.Hash{hash})
+	return DigestDirectory(inz, hashes...)
+}
+
+// DigestDirectory computes the APK v2 digest of a zip with each of the given
+// hashes. The contents of inz are read in order, so it may be backed by a
+// stream.
+func DigestDirectory(inz *zipslicer.Directory, hashes ...crypto.Hash) (*Digest, error) {
+	var selected []crypto.Hash
+	for _, hash := range hashes {
+		if hash == crypto.SHA384 {
+			// the signature scheme only has SHA-256 and SHA-512 variants
+			hash = crypto.SHA512
+		}
+		if !slices.Contains(selected, hash) {
+			selected = append(selected, hash)
+		}
+	}
+	if len(selected) == 0 {
+		return nil, errors.New("no digest algorithm selected")
+	}
+	hasher := newMerkleHasher(selected)
 	for _, f := range inz.File {
 		_, err := f.Dump(hasher)
 		if err != nil {
@@ -62,29 +85,39 @@ func digestApkStream(r io.Reader, hash crypto.Hash) (*Digest, error) {
 	inz.DirLoc = origDirLoc
 	return &Digest{
 		inz:    inz,
-		hash:   hash,
-		value:  digests[0],
+		hashes: selected,
+		values: digests,
 		sigLoc: sigLoc,
 	}, nil
 }
 
-func (d *Digest) Sign(cert *certloader.Certificate) (*binpatch.PatchSet, error) {
-	// select a signature type
+// Sign the digest and return a patch that inserts the signing block. If pss is
+// true then RSA keys sign with RSASSA-PSS, which the scheme fixes to MGF1 and a
+// salt the length of the digest. When several digests were computed, the
+// signer carries a digest and signature for each so that verifiers can pick the
+// strongest they support.
+func (d *Digest) Sign(cert *certloader.Certificate, pss bool) (*binpatch.PatchSet, error) {
+	// select a signature type for each digest
 	alg := x509tools.GetPublicKeyAlgorithm(cert.Leaf.PublicKey)
-	var st sigType
-	for _, s := range sigTypes {
-		if s.hash == d.hash && s.alg == alg && !s.pss {
-			st = s
-			break
-		}
-		// TODO: PSS
+	if pss && alg != x509.RSA {
+		return nil, errors.New("RSASSA-PSS requires an RSA key")
 	}
-	if st.id == 0 {
-		return nil, errors.New("unsupported public key algorithm")
+	sts := make([]sigType, len(d.hashes))
+	for i, hash := range d.hashes {
+		for _, s := range sigTypes {
+			if s.hash == hash && s.alg == alg && s.pss == pss {
+				sts[i] = s
+				break
+			}
+		}
+		if sts[i].id == 0 {
+			return nil, errors.New("unsupported public key algorithm")
+		}
 	}
 	// build signed data
-	sd := apkSignedData{
-		Digests: []apkDigest{apkDigest{ID: st.id, Value: d.value}},
+	var sd apkSignedData
+	for i, st := range sts {
+		sd.Digests = append(sd.Digests, apkDigest{ID: st.id, Value: d.values[i]})
 	}
 	for _, cert := range cert.Chain() {
 		sd.Certificates = append(sd.Certificates, cert.Raw)
@@ -94,16 +127,24 @@ func (d *Digest) Sign(cert *certloader.Certificate) (*binpatch.PatchSet, error)
 		return nil, err
 	}
 	// sign
-	digest := st.hash.New()
-	digest.Write(signedData.Bytes())
-	sigv, err := cert.Signer().Sign(rand.Reader, digest.Sum(nil), st.hash)
-	if err != nil {
-		return nil, err
+	var sigs []apkSignature
+	for _, st := range sts {
+		var opts crypto.SignerOpts = st.hash
+		if st.pss {
+			opts = &rsa.PSSOptions{Hash: st.hash, SaltLength: rsa.PSSSaltLengthEqualsHash}
+		}
+		digest := st.hash.New()
+		digest.Write(signedData.Bytes())
+		sigv, err := cert.Signer().Sign(rand.Reader, digest.Sum(nil), opts)
+		if err != nil {
+			return nil, err
+		}
+		sigs = append(sigs, apkSignature{ID: st.id, Value: sigv})
 	}
 	// build signer block
 	signerList := []apkSigner{apkSigner{
 		SignedData: signedData,
-		Signatures: []apkSignature{apkSignature{ID: st.id, Value: sigv}},
+		Signatures: sigs,
 		PublicKey:  cert.Leaf.RawSubjectPublicKeyInfo,
 	}}
 	sblob, err := marshal(signerList)
@@ -35,6 +35,9 @@ var ApkSigner = &signers.Signer{
 	Transform: zipbased.Transform,
 	Sign:      sign,
 	Verify:    verify,
+	Resign:    signers.ResignReplace,
+
+	CheckInput: zipbased.CheckInput,
 }
 
 const (
@@ -48,15 +51,21 @@ var (
 )
 
 func init() {
+	ApkSigner.Flags().Bool("rsa-pss", false, "(JAR, APK) Sign with RSASSA-PSS instead of PKCS#1 v1.5 when using an RSA key")
+	ApkSigner.Flags().String("digests", "", "(PKCS#7, APK) Sign with each of these digests, comma separated (e.g. sha256,sha512), so verifiers can use whichever they support")
 	signers.Register(ApkSigner)
 }
 
 func sign(r io.Reader, cert *certloader.Certificate, opts signers.SignOpts) ([]byte, error) {
-	digest, err := digestApkStream(r, opts.Hash)
+	hashes, err := opts.Digests()
+	if err != nil {
+		return nil, err
+	}
+	digest, err := digestApkStream(r, hashes...)
 	if err != nil {
 		return nil, err
 	}
-	patchset, err := digest.Sign(cert)
+	patchset, err := digest.Sign(cert, opts.Flags.GetBool("rsa-pss"))
 	if err != nil {
 		return nil, err
 	}
@@ -46,35 +46,9 @@ func verify(f *os.File, opts signers.VerifyOpts) ([]*signers.Signature, error) {
 	if err != nil {
 		return nil, err
 	}
-	var allSigs []*signers.Signature
-	for len(block) > 0 {
-		if len(block) < 12 {
-			return nil, errTruncated
-		}
-		partSize := binary.LittleEndian.Uint64(block)
-		block = block[8:]
-		if partSize < 4 || partSize > uint64(len(block)) {
-			return nil, errTruncated
-		}
-		partType := binary.LittleEndian.Uint32(block)
-		partBlob := block[4:partSize]
-		block = block[partSize:]
-		if partType != sigApkV2 {
-			continue
-		}
-		var signerList []apkSigner
-		if err := unmarshal(partBlob, &signerList); err != nil {
-			return nil, fmt.Errorf("parsing signature block: %w", err)
-		} else if len(signerList) == 0 {
-			return nil, errors.New("empty APK signing block")
-		}
-		for i, signer := range signerList {
-			sig, err := signer.Verify(nil)
-			if err != nil {
-				return nil, fmt.Errorf("APK signature #%d: %w", i+1, err)
-			}
-			allSigs = append(allSigs, sig)
-		}
+	allSigs, err := verifyBlock(block, nil)
+	if err != nil {
+		return nil, err
 	}
 	v2present := len(allSigs) != 0
 	// verify v1
@@ -105,6 +79,55 @@ func verify(f *os.File, opts signers.VerifyOpts) ([]*signers.Signature, error) {
 	return allSigs, nil
 }
 
+// VerifyV2 checks the APK v2 signing block of a zip, if it has one. If there is
+// no signing block then no signatures are returned.
+func VerifyV2(f *os.File, noDigests bool) ([]*signers.Signature, error) {
+	inz, block, err := getSigBlock(f)
+	if err != nil {
+		return nil, err
+	}
+	if noDigests {
+		inz = nil
+	}
+	return verifyBlock(block, inz)
+}
+
+// verify the signers in each v2 block. If inz is not nil then the contents are
+// checked against the signed digests.
+func verifyBlock(block []byte, inz *zipslicer.Directory) ([]*signers.Signature, error) {
+	var sigs []*signers.Signature
+	for len(block) > 0 {
+		if len(block) < 12 {
+			return nil, errTruncated
+		}
+		partSize := binary.LittleEndian.Uint64(block)
+		block = block[8:]
+		if partSize < 4 || partSize > uint64(len(block)) {
+			return nil, errTruncated
+		}
+		partType := binary.LittleEndian.Uint32(block)
+		partBlob := block[4:partSize]
+		block = block[partSize:]
+		if partType != sigApkV2 {
+			continue
+		}
+		var signerList []apkSigner
+		if err := unmarshal(partBlob, &signerList); err != nil {
+			return nil, fmt.Errorf("parsing signature block: %w", err)
+		} else if len(signerList) == 0 {
+			return nil, errors.New("empty APK signing block")
+		}
+		for i, signer := range signerList {
+			sig, err := signer.Verify(inz)
+			if err != nil {
+				return nil, fmt.Errorf("APK signature #%d: %w", i+1, err)
+			}
+			sigs = append(sigs, sig)
+		}
+	}
+	return sigs, nil
+}
+
 func getSigBlock(f *os.File) (*zipslicer.Directory, []byte, error) {
 	size, err := f.Seek(0, io.SeekEnd)
 	if err != nil {
@@ -154,6 +177,7 @@ func (s *apkSigner) Verify(inz *zipslicer.Directory) (*signers.Signature, error)
 		return nil, err
 	}
 	var bestHash crypto.Hash
+	var names []string
 	for _, sig := range s.Signatures {
 		hash, err := sig.VerifySignature(publicKey, s.SignedData.Bytes())
 		if err != nil {
@@ -163,6 +187,12 @@ func (s *apkSigner) Verify(inz *zipslicer.Directory) (*signers.Signature, error)
 		if hash > bestHash {
 			bestHash = hash
 		}
+		names = append(names, x509tools.HashShortName(hash))
+	}
+	sigInfo := "v2"
+	if len(names) > 1 {
+		// verifiers use the strongest one they support
+		sigInfo += " (" + strings.Join(names, ", ") + ")"
 	}
 	// check digests
 	var signedData apkSignedData
@@ -215,7 +245,7 @@ func (s *apkSigner) Verify(inz *zipslicer.Directory) (*signers.Signature, error)
 		return nil, errors.New("public key does not match any certificate")
 	}
 	return &signers.Signature{
-		SigInfo: "v2",
+		SigInfo: sigInfo,
 		Hash:    bestHash,
 		X509Signature: &pkcs9.TimestampedSignature{
 			Signature: pkcs7.Signature{
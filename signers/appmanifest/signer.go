@@ -41,6 +41,9 @@ var AppSigner = &signers.Signer{
 	FormatLog:    formatLog,
 	Sign:         sign,
 	VerifyStream: verify,
+	Resign:       signers.ResignReplace,
+
+	KeyUsages: signers.CodeSigningKeyUsages,
 }
 
 func init() {
@@ -16,7 +16,7 @@
 
 package appx
 
-// Sign Windows Universal (UWP) .appx and .appxbundle
+// Sign Windows Universal (UWP) .appx, .msix and their bundles
 
 import (
 	"fmt"
@@ -38,10 +38,15 @@ var AppxSigner = &signers.Signer{
 	Transform: zipbased.Transform,
 	Sign:      sign,
 	Verify:    verify,
+	Resign:    signers.ResignReplace,
+
+	KeyUsages:  signers.CodeSigningKeyUsages,
+	CheckInput: zipbased.CheckInput,
 }
 
 func init() {
 	pecoff.AddOpusFlags(AppxSigner)
+	AppxSigner.Flags().Bool("strict-publisher", false, "(APPX) Fail if the manifest Publisher doesn't match the certificate, instead of replacing it")
 	signers.Register(AppxSigner)
 }
 
@@ -50,6 +55,11 @@ func sign(r io.Reader, cert *certloader.Certificate, opts signers.SignOpts) ([]b
 	if err != nil {
 		return nil, err
 	}
+	if opts.Flags.GetBool("strict-publisher") {
+		if err := digest.CheckPublisher(cert.Leaf); err != nil {
+			return nil, err
+		}
+	}
 	patch, priSig, _, err := digest.Sign(opts.Context(), cert, pecoff.OpusFlags(opts))
 	if err != nil {
 		return nil, err
@@ -36,6 +36,9 @@ var CabSigner = &signers.Signer{
 	CertTypes: signers.CertTypeX509,
 	Sign:      sign,
 	Verify:    verify,
+	Resign:    signers.ResignReplace,
+
+	KeyUsages: signers.CodeSigningKeyUsages,
 }
 
 func init() {
@@ -0,0 +1,18 @@
+package cab
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/mind-security/relic/v8/internal/signertest"
+)
+
+const testCab = "../../functest/packages/dummy.cab"
+
+func TestSignCab(t *testing.T) {
+	info, err := os.Stat(testCab)
+	require.NoError(t, err)
+	signertest.RoundTrip(t, CabSigner, testCab, signertest.LoadCert(t, false), int(info.Size()/2))
+}
@@ -38,6 +38,9 @@ var CatSigner = &signers.Signer{
 	CertTypes: signers.CertTypeX509,
 	Sign:      sign,
 	Verify:    pkcs.Verify,
+	Resign:    signers.ResignReplace,
+
+	KeyUsages: signers.CodeSigningKeyUsages,
 }
 
 func init() {
@@ -38,6 +38,9 @@ var DebSigner = &signers.Signer{
 	FormatLog: formatLog,
 	Sign:      sign,
 	Verify:    verify,
+	Resign:    signers.ResignAppend,
+
+	NeedsSigningTime: true,
 }
 
 func init() {
@@ -54,7 +57,11 @@ func sign(r io.Reader, cert *certloader.Certificate, opts signers.SignOpts) ([]b
 	if role == "" {
 		role = "builder"
 	}
-	sig, err := signdeb.Sign(r, cert.PgpKey, opts.Hash, role)
+	signAt := signdeb.SignAt
+	if opts.Flags.GetBool("replace") {
+		signAt = signdeb.SignAtReplacing
+	}
+	sig, err := signAt(r, cert.PgpKey, opts.Hash, role, opts.Time)
 	if err != nil {
 		return nil, err
 	}
@@ -1,6 +1,7 @@
 package dmg
 
 import (
+	"crypto"
 	"fmt"
 	"io"
 	"os"
@@ -14,10 +15,12 @@ import (
 var signer = &signers.Signer{
 	Name:      "dmg",
 	CertTypes: signers.CertTypeX509,
+	Hashes:    []crypto.Hash{crypto.SHA256, crypto.SHA384},
 	TestPath:  testPath,
 	Verify:    verify,
 	Sign:      sign,
 	Transform: transform,
+	Resign:    signers.ResignReplace,
 }
 
 func init() {
@@ -40,6 +43,8 @@ func sign(r io.Reader, cert *certloader.Certificate, opts signers.SignOpts) ([]b
 	params := &dmg.SignatureParams{
 		HashFunc:        opts.Hash,
 		SigningIdentity: opts.Flags.GetString("bundle-id"),
+		SigningTime:     opts.Time,
+		OmitTime:        opts.OmitSigningTime,
 	}
 	if v := args["requirements"]; v != nil {
 		params.Requirements = v
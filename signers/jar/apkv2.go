@@ -0,0 +1,96 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package jar
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto"
+	"errors"
+	"io"
+
+	"github.com/mind-security/relic/v8/lib/binpatch"
+	"github.com/mind-security/relic/v8/lib/certloader"
+	"github.com/mind-security/relic/v8/lib/zipslicer"
+	"github.com/mind-security/relic/v8/signers/apk"
+)
+
+// addApkV2 adds an APK v2 signing block to a JAR that has been signed with
+// patch. tarzip is the original input, which is read again to digest the
+// signed JAR.
+func addApkV2(tarzip io.ReadSeeker, patch *binpatch.PatchSet, cert *certloader.Certificate, hash crypto.Hash, pss bool) error {
+	if _, err := tarzip.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	tr := tar.NewReader(tarzip)
+	var size int64
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			return err
+		} else if hdr.Name == zipslicer.TarMemberZip {
+			size = hdr.Size
+			break
+		}
+	}
+	// the last patch replaces the central directory, so the signed JAR ends
+	// with its blob
+	last := len(patch.Patches) - 1
+	if last < 0 {
+		return errors.New("empty JAR patch")
+	}
+	dir := patch.Blobs[last]
+	for _, p := range patch.Patches {
+		size += int64(p.NewSize) - int64(p.OldSize)
+	}
+	dirLoc := size - int64(len(dir))
+	// digest the signed JAR as it is produced
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	go func() {
+		pw.CloseWithError(patch.ApplyStream(tr, pw))
+	}()
+	inz, err := zipslicer.ReadStream(pr, size, dir)
+	if err != nil {
+		return err
+	}
+	digest, err := apk.DigestDirectory(inz, hash)
+	if err != nil {
+		return err
+	}
+	v2patch, err := digest.Sign(cert, pss)
+	if err != nil {
+		return err
+	}
+	// the signing block goes right before the central directory and the end
+	// of directory record is updated to point past it, so fold both changes
+	// into the patch that writes the directory
+	tailPatch := binpatch.New()
+	for i, p := range v2patch.Patches {
+		if p.Offset < dirLoc {
+			return errors.New("APK signing block overlaps JAR contents")
+		}
+		tailPatch.Add(p.Offset-dirLoc, int64(p.OldSize), v2patch.Blobs[i])
+	}
+	var tail bytes.Buffer
+	if err := tailPatch.ApplyStream(bytes.NewReader(dir), &tail); err != nil {
+		return err
+	}
+	patch.Blobs[last] = tail.Bytes()
+	patch.Patches[last].NewSize = uint32(tail.Len())
+	return nil
+}
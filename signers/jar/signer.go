@@ -20,13 +20,19 @@ package jar
 
 import (
 	"archive/zip"
+	"crypto/rsa"
+	"errors"
+	"fmt"
 	"io"
 	"os"
+	"strconv"
 
 	"github.com/mind-security/relic/v8/lib/certloader"
 	"github.com/mind-security/relic/v8/lib/magic"
 	"github.com/mind-security/relic/v8/lib/signjar"
+	"github.com/mind-security/relic/v8/lib/spool"
 	"github.com/mind-security/relic/v8/signers"
+	"github.com/mind-security/relic/v8/signers/apk"
 	"github.com/mind-security/relic/v8/signers/zipbased"
 )
 
@@ -37,16 +43,35 @@ var JarSigner = &signers.Signer{
 	Transform: zipbased.Transform,
 	Sign:      sign,
 	Verify:    verify,
+	Resign:    signers.ResignReplace,
+
+	DetachedSuffix: detachedSuffix,
+	CheckInput:     zipbased.CheckInput,
 }
 
+// detachedMimeType is the result type for --detached, a ZIP archive holding
+// only the META-INF manifest and signature files
+const detachedMimeType = "application/zip"
+
 func init() {
 	JarSigner.Flags().Bool("sections-only", false, "(JAR) Don't compute hash of entire manifest")
 	JarSigner.Flags().Bool("inline-signature", false, "(JAR) Include .SF inside the signature block")
 	JarSigner.Flags().Bool("apk-v2-present", false, "(JAR) Add X-Android-APK-Signed header to signature")
+	JarSigner.Flags().Bool("apk-v2", false, "(JAR) Also add an APK v2 signing block so the JAR can be installed on Android")
 	JarSigner.Flags().String("key-alias", "RELIC", "(JAR, APK) Alias to use for the signed manifest")
+	JarSigner.Flags().Bool("detached", false, "(JAR) Write the manifest and signature files to a separate archive instead of modifying the JAR")
+	JarSigner.Flags().Bool("rsa-pss", false, "(JAR, APK) Sign with RSASSA-PSS instead of PKCS#1 v1.5 when using an RSA key")
+	JarSigner.Flags().String("pss-salt-length", "", "(JAR) RSASSA-PSS salt length in bytes (default: the digest length). The APK v2 block always uses the digest length")
 	signers.Register(JarSigner)
 }
 
+func detachedSuffix(flags *signers.FlagValues) string {
+	if flags.GetBool("detached") {
+		return ".sig.zip"
+	}
+	return ""
+}
+
 // sign a manifest and return the PKCS#7 blob
 func sign(r io.Reader, cert *certloader.Certificate, opts signers.SignOpts) ([]byte, error) {
 	argSectionsOnly := opts.Flags.GetBool("sections-only")
@@ -56,14 +81,59 @@ func sign(r io.Reader, cert *certloader.Certificate, opts signers.SignOpts) ([]b
 	if argAlias == "" {
 		argAlias = "RELIC"
 	}
-	digest, err := signjar.DigestJarStream(r, opts.Hash)
+	addV2 := opts.Flags.GetBool("apk-v2")
+	if addV2 {
+		if opts.Flags.GetBool("detached") {
+			return nil, errors.New("--apk-v2 can't be used with --detached")
+		}
+		// the input is needed again to digest the signed JAR
+		sp, err := spool.New(r, opts.SpoolThreshold)
+		if err != nil {
+			return nil, err
+		}
+		defer sp.Close()
+		r = sp
+		argApkV2 = true
+	}
+	digest, err := signjar.DigestJarStreamLimits(r, opts.Hash, opts.DigestLimits)
+	if err != nil {
+		return nil, err
+	}
+	digest.ModTime, err = opts.ModTime()
 	if err != nil {
 		return nil, err
 	}
+	pss := opts.Flags.GetBool("rsa-pss")
+	if pss {
+		digest.PSS = &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}
+		if v := opts.Flags.GetString("pss-salt-length"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				return nil, errors.New("--pss-salt-length must be a positive number of bytes")
+			}
+			digest.PSS.SaltLength = n
+		}
+	} else if opts.Flags.GetString("pss-salt-length") != "" {
+		return nil, errors.New("--pss-salt-length requires --rsa-pss")
+	}
+	if opts.Flags.GetBool("detached") {
+		blob, ts, err := digest.SignDetached(opts.Context(), cert, argAlias, argSectionsOnly, argInlineSignature, argApkV2)
+		if err != nil {
+			return nil, err
+		}
+		opts.Audit.SetCounterSignature(ts.CounterSignature)
+		opts.Audit.SetMimeType(detachedMimeType)
+		return blob, nil
+	}
 	patch, ts, err := digest.Sign(opts.Context(), cert, argAlias, argSectionsOnly, argInlineSignature, argApkV2)
 	if err != nil {
 		return nil, err
 	}
+	if addV2 {
+		if err := addApkV2(r.(io.ReadSeeker), patch, cert, opts.Hash, pss); err != nil {
+			return nil, fmt.Errorf("adding APK v2 signature: %w", err)
+		}
+	}
 	opts.Audit.SetCounterSignature(ts.CounterSignature)
 	return opts.SetBinPatch(patch)
 }
@@ -73,11 +143,32 @@ func verify(f *os.File, opts signers.VerifyOpts) ([]*signers.Signature, error) {
 	if err != nil {
 		return nil, err
 	}
-	sigs, err := signjar.Verify(inz, opts.NoDigests)
+	var sigs []*signjar.JarSignature
+	if opts.Content != "" {
+		// f holds detached signature files for the JAR named by --content
+		content, err := os.Open(opts.Content)
+		if err != nil {
+			return nil, err
+		}
+		defer content.Close()
+		jar, err := openZip(content)
+		if err != nil {
+			return nil, err
+		}
+		sigs, err = signjar.VerifyDetached(jar, inz, opts.NoDigests)
+	} else {
+		sigs, err = signjar.Verify(inz, opts.NoDigests)
+	}
 	if err != nil {
 		return nil, err
 	}
 	var ret []*signers.Signature
+	if opts.Content == "" {
+		ret, err = apk.VerifyV2(f, opts.NoDigests)
+		if err != nil {
+			return nil, fmt.Errorf("APK v2 signature: %w", err)
+		}
+	}
 	for _, ts := range sigs {
 		ret = append(ret, &signers.Signature{
 			Hash:          ts.Hash,
@@ -0,0 +1,154 @@
+package jar
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mind-security/relic/v8/lib/audit"
+	"github.com/mind-security/relic/v8/lib/binpatch"
+	"github.com/mind-security/relic/v8/lib/certloader"
+	"github.com/mind-security/relic/v8/lib/x509tools"
+	"github.com/mind-security/relic/v8/lib/zipslicer"
+	"github.com/mind-security/relic/v8/signers"
+	"github.com/mind-security/relic/v8/signers/apk"
+)
+
+const testJar = "../../functest/packages/hello.jar"
+
+func rsaCert(t *testing.T) *certloader.Certificate {
+	keyBlob, err := os.ReadFile("../../functest/testkeys/rsa2048.key")
+	require.NoError(t, err)
+	key, err := certloader.ParseAnyPrivateKey(keyBlob, nil)
+	require.NoError(t, err)
+	cert, err := certloader.LoadTokenCertificates(key, "../../functest/testkeys/rsa2048.crt", "", nil)
+	require.NoError(t, err)
+	return cert
+}
+
+func ecdsaCert(t *testing.T) *certloader.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "jar signer"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &certloader.Certificate{Leaf: cert, PrivateKey: key}
+}
+
+func signJar(t *testing.T, path string, cert *certloader.Certificate, flags map[string]string) string {
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	var tarzip bytes.Buffer
+	require.NoError(t, zipslicer.ZipToTar(f, &tarzip))
+	opts := signers.SignOpts{
+		Hash:  crypto.SHA256,
+		Time:  time.Now(),
+		Audit: audit.New("test", "jar", crypto.SHA256),
+		Flags: &signers.FlagValues{Defs: JarSigner.Flags(), Values: flags},
+	}
+	blob, err := JarSigner.Sign(&tarzip, cert, opts)
+	require.NoError(t, err)
+	patch, err := binpatch.Load(blob)
+	require.NoError(t, err)
+	out := filepath.Join(t.TempDir(), "signed.jar")
+	require.NoError(t, patch.Apply(f, out))
+	return out
+}
+
+func verifyFile(t *testing.T, s *signers.Signer, path string) ([]*signers.Signature, error) {
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	return s.Verify(f, signers.VerifyOpts{})
+}
+
+func TestSignApkV2(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		cert *certloader.Certificate
+	}{
+		{"RSA", rsaCert(t)},
+		{"ECDSA", ecdsaCert(t)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			signed := signJar(t, testJar, tc.cert, map[string]string{"apk-v2": "true"})
+			sigs, err := verifyFile(t, JarSigner, signed)
+			require.NoError(t, err)
+			require.Len(t, sigs, 2)
+			assert.Equal(t, "v2", sigs[0].SigInfo)
+			for _, sig := range sigs {
+				assert.Equal(t, crypto.SHA256, sig.Hash)
+				assert.Equal(t, tc.cert.Leaf.Raw, sig.X509Signature.Certificate.Raw)
+			}
+			// the result is also a valid APK
+			sigs, err = verifyFile(t, apk.ApkSigner, signed)
+			require.NoError(t, err)
+			assert.Len(t, sigs, 2)
+
+			// changes to the contents are detected by the v2 block
+			blob, err := os.ReadFile(signed)
+			require.NoError(t, err)
+			blob[100] ^= 0xff
+			require.NoError(t, os.WriteFile(signed, blob, 0644))
+			_, err = verifyFile(t, JarSigner, signed)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestSignWithoutApkV2(t *testing.T) {
+	signed := signJar(t, testJar, ecdsaCert(t), map[string]string{})
+	sigs, err := verifyFile(t, JarSigner, signed)
+	require.NoError(t, err)
+	require.Len(t, sigs, 1)
+	assert.Equal(t, "", sigs[0].SigInfo)
+}
+
+func TestSignRSAPSS(t *testing.T) {
+	cert := rsaCert(t)
+	signed := signJar(t, testJar, cert, map[string]string{"apk-v2": "true", "rsa-pss": "true", "pss-salt-length": "20"})
+	sigs, err := verifyFile(t, JarSigner, signed)
+	require.NoError(t, err)
+	require.Len(t, sigs, 2)
+	// the v2 block verifies with its PSS algorithm ID
+	assert.Equal(t, "v2", sigs[0].SigInfo)
+	si := sigs[1].X509Signature.SignerInfo
+	require.True(t, si.DigestEncryptionAlgorithm.Algorithm.Equal(x509tools.OidSignatureRSAPSS))
+	pss, err := x509tools.UnmarshalRSAPSSParameters(crypto.SHA256, si.DigestEncryptionAlgorithm.Parameters)
+	require.NoError(t, err)
+	assert.Equal(t, 20, pss.SaltLength)
+
+	// PSS needs an RSA key
+	f, err := os.Open(testJar)
+	require.NoError(t, err)
+	defer f.Close()
+	var tarzip bytes.Buffer
+	require.NoError(t, zipslicer.ZipToTar(f, &tarzip))
+	_, err = JarSigner.Sign(&tarzip, ecdsaCert(t), signers.SignOpts{
+		Hash:  crypto.SHA256,
+		Time:  time.Now(),
+		Audit: audit.New("test", "jar", crypto.SHA256),
+		Flags: &signers.FlagValues{Defs: JarSigner.Flags(), Values: map[string]string{"rsa-pss": "true"}},
+	})
+	assert.ErrorContains(t, err, "RSA key")
+}
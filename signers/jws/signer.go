@@ -0,0 +1,223 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package jws
+
+// Sign arbitrary files with a detached JWS, or embed one in a ZIP archive
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mind-security/relic/v8/config"
+	"github.com/mind-security/relic/v8/lib/artifactjws"
+	"github.com/mind-security/relic/v8/lib/certloader"
+	"github.com/mind-security/relic/v8/lib/magic"
+	"github.com/mind-security/relic/v8/lib/pkcs7"
+	"github.com/mind-security/relic/v8/lib/pkcs9"
+	"github.com/mind-security/relic/v8/lib/zipslicer"
+	"github.com/mind-security/relic/v8/signers"
+	"github.com/mind-security/relic/v8/signers/sigerrors"
+	"github.com/mind-security/relic/v8/signers/zipbased"
+)
+
+var JwsSigner = &signers.Signer{
+	Name:       "jws",
+	Magic:      magic.FileTypeZipJWS,
+	CertTypes:  signers.CertTypeX509,
+	AllowStdin: true,
+	TestPath:   testPath,
+	Transform:  transform,
+	Sign:       sign,
+	Verify:     verify,
+	Resign:     signers.ResignReplace,
+
+	DetachedSuffix:   detachedSuffix,
+	NeedsSigningTime: true,
+}
+
+const maxSignatureSize = 1024 * 1024
+
+func init() {
+	JwsSigner.Flags().Bool("embed", false, "(JWS) Embed the signature in a ZIP archive instead of producing a detached signature")
+	signers.Register(JwsSigner)
+}
+
+func testPath(fp string) bool {
+	return strings.HasSuffix(strings.ToLower(fp), ".jws")
+}
+
+func detachedSuffix(flags *signers.FlagValues) string {
+	if flags.GetBool("embed") {
+		return ""
+	}
+	return ".jws"
+}
+
+func transform(f *os.File, opts signers.SignOpts) (signers.Transformer, error) {
+	if opts.Flags.GetBool("embed") {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return nil, errors.New("--embed needs a seekable input file, not a pipe")
+		}
+		return zipbased.Transform(f, opts)
+	}
+	return signers.DefaultTransform(f), nil
+}
+
+func sign(r io.Reader, cert *certloader.Certificate, opts signers.SignOpts) ([]byte, error) {
+	payload := &artifactjws.Payload{
+		IssuedAt: opts.Time.Unix(),
+		Creator:  config.UserAgent,
+	}
+	if opts.Flags.GetBool("embed") {
+		return signEmbedded(r, cert, opts, payload)
+	}
+	var err error
+	if opts.Path != "" && opts.Path != "-" {
+		payload.Name = filepath.Base(opts.Path)
+	}
+	payload.SHA256, payload.Size, err = artifactjws.DigestFile(r)
+	if err != nil {
+		return nil, err
+	}
+	opts.Audit.Attributes["jws.sha256"] = payload.SHA256
+	blob, err := artifactjws.Sign(cert.Signer(), cert.Chain(), opts.Hash, payload)
+	if err != nil {
+		return nil, err
+	}
+	opts.Audit.SetMimeType(artifactjws.MediaType)
+	return blob, nil
+}
+
+func signEmbedded(r io.Reader, cert *certloader.Certificate, opts signers.SignOpts, payload *artifactjws.Payload) ([]byte, error) {
+	inz, err := zipslicer.ReadZipTar(r)
+	if err != nil {
+		return nil, err
+	}
+	payload.Entries = make(map[string]string)
+	m, err := inz.Mangle(func(f *zipslicer.MangleFile) error {
+		if f.Name == artifactjws.EntryName {
+			// replace existing signature
+			f.Delete()
+			return nil
+		} else if !artifactjws.IsSigned(f.Name) {
+			return nil
+		} else if _, ok := payload.Entries[f.Name]; ok {
+			return errors.New(f.Name + ": duplicate file in archive")
+		}
+		sum, err := artifactjws.DigestEntry(&f.File)
+		if err != nil {
+			return err
+		}
+		payload.Entries[f.Name] = sum
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	m.ModTime, err = opts.ModTime()
+	if err != nil {
+		return nil, err
+	}
+	blob, err := artifactjws.Sign(cert.Signer(), cert.Chain(), opts.Hash, payload)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.NewFile(artifactjws.EntryName, blob); err != nil {
+		return nil, err
+	}
+	patch, err := m.MakePatch(false)
+	if err != nil {
+		return nil, err
+	}
+	return opts.SetBinPatch(patch)
+}
+
+func verify(f *os.File, opts signers.VerifyOpts) ([]*signers.Signature, error) {
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	inz, zerr := zipslicer.Read(f, size)
+	var blob []byte
+	if zerr == nil {
+		blob, err = readEmbedded(inz)
+	} else {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		blob, err = io.ReadAll(io.LimitReader(f, maxSignatureSize))
+	}
+	if err != nil {
+		return nil, err
+	}
+	sig, err := artifactjws.Verify(blob)
+	if err != nil {
+		return nil, err
+	}
+	if !opts.NoDigests {
+		if zerr == nil {
+			err = sig.Payload.CheckEntries(inz)
+		} else {
+			err = checkDetached(sig, opts)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return []*signers.Signature{{
+		Package:      sig.Payload.Name,
+		CreationTime: time.Unix(sig.Payload.IssuedAt, 0),
+		Hash:         sig.Hash,
+		X509Signature: &pkcs9.TimestampedSignature{
+			Signature: pkcs7.Signature{
+				Certificate:   sig.Certificate,
+				Intermediates: sig.Intermediates,
+			},
+		},
+	}}, nil
+}
+
+func readEmbedded(inz *zipslicer.Directory) ([]byte, error) {
+	for _, f := range inz.File {
+		if f.Name != artifactjws.EntryName {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return io.ReadAll(io.LimitReader(r, maxSignatureSize))
+	}
+	return nil, sigerrors.NotSignedError{Type: "zip"}
+}
+
+func checkDetached(sig *artifactjws.Signature, opts signers.VerifyOpts) error {
+	if opts.Content == "" {
+		return errors.New("--content is required to verify a detached JWS")
+	}
+	content, err := os.Open(opts.Content)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+	return sig.Payload.CheckFile(content)
+}
@@ -15,6 +15,7 @@ var fatVerifier = &signers.Signer{
 	Magic:     magic.FileTypeMachOFat,
 	CertTypes: signers.CertTypeX509,
 	Verify:    verifyFatFile,
+	Resign:    signers.ResignReplace,
 }
 
 func init() {
@@ -20,6 +20,7 @@ var ipaVerifier = &signers.Signer{
 	Magic:     magic.FileTypeIPA,
 	CertTypes: signers.CertTypeX509,
 	Verify:    verifyIPA,
+	Resign:    signers.ResignReplace,
 }
 
 func init() {
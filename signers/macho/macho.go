@@ -1,6 +1,7 @@
 package macho
 
 import (
+	"crypto"
 	"fmt"
 	"io"
 	"os"
@@ -16,9 +17,11 @@ var signer = &signers.Signer{
 	Name:      "mach-o",
 	Magic:     magic.FileTypeMachO,
 	CertTypes: signers.CertTypeX509,
+	Hashes:    []crypto.Hash{crypto.SHA256, crypto.SHA384},
 	Transform: transform,
 	Sign:      sign,
 	Verify:    verifyMachoFile,
+	Resign:    signers.ResignReplace,
 }
 
 func init() {
@@ -41,6 +44,8 @@ func sign(r io.Reader, cert *certloader.Certificate, opts signers.SignOpts) ([]b
 	params := &csblob.SignatureParams{
 		HashFunc:        opts.Hash,
 		SigningIdentity: opts.Flags.GetString("bundle-id"),
+		SigningTime:     opts.Time,
+		OmitTime:        opts.OmitSigningTime,
 	}
 	if v := args["info-plist"]; v != nil {
 		params.InfoPlist = v
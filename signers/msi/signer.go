@@ -19,6 +19,8 @@ package msi
 // Sign Microsoft Installer files
 
 import (
+	"crypto"
+	"errors"
 	"io"
 	"io/ioutil"
 	"os"
@@ -28,6 +30,8 @@ import (
 	"github.com/mind-security/relic/v8/lib/certloader"
 	"github.com/mind-security/relic/v8/lib/comdoc"
 	"github.com/mind-security/relic/v8/lib/magic"
+	"github.com/mind-security/relic/v8/lib/pkcs7"
+	"github.com/mind-security/relic/v8/lib/x509tools"
 	"github.com/mind-security/relic/v8/signers"
 	"github.com/mind-security/relic/v8/signers/pecoff"
 )
@@ -40,6 +44,9 @@ var MsiSigner = &signers.Signer{
 	Transform: transform,
 	Sign:      sign,
 	Verify:    verify,
+	Resign:    signers.ResignReplace,
+
+	KeyUsages: signers.CodeSigningKeyUsages,
 }
 
 func init() {
@@ -49,9 +56,10 @@ func init() {
 }
 
 type msiTransformer struct {
-	f     *os.File
-	cdf   *comdoc.ComDoc
-	exsig []byte
+	f        *os.File
+	cdf      *comdoc.ComDoc
+	extended bool
+	exsig    []byte
 }
 
 func transform(f *os.File, opts signers.SignOpts) (signers.Transformer, error) {
@@ -59,15 +67,16 @@ func transform(f *os.File, opts signers.SignOpts) (signers.Transformer, error) {
 	if err != nil {
 		return nil, err
 	}
-	var exsig []byte
-	noExtended := opts.Flags.GetBool("no-extended-sig")
-	if !noExtended {
-		exsig, err = authenticode.PrehashMSI(cdf, opts.Hash)
+	t := &msiTransformer{f: f, cdf: cdf, extended: !opts.Flags.GetBool("no-extended-sig")}
+	// when the server picks the digest, prehash once the signature says which
+	// one it used
+	if t.extended && opts.Hash != 0 {
+		t.exsig, err = authenticode.PrehashMSI(cdf, opts.Hash)
 		if err != nil {
 			return nil, err
 		}
 	}
-	return &msiTransformer{f, cdf, exsig}, nil
+	return t, nil
 }
 
 // transform the MSI to a tar stream for upload
@@ -81,11 +90,22 @@ func (t *msiTransformer) GetReader() (io.Reader, error) {
 
 // apply a signed PKCS#7 blob to an already-open MSI document
 func (t *msiTransformer) Apply(dest, mimeType string, result io.Reader) error {
-	t.cdf.Close()
 	blob, err := ioutil.ReadAll(result)
 	if err != nil {
+		t.cdf.Close()
 		return err
 	}
+	if t.extended && t.exsig == nil {
+		hash, err := signatureHash(blob)
+		if err == nil {
+			t.exsig, err = authenticode.PrehashMSI(t.cdf, hash)
+		}
+		if err != nil {
+			t.cdf.Close()
+			return err
+		}
+	}
+	t.cdf.Close()
 	// copy src to dest if needed, otherwise open in-place
 	f, err := atomicfile.WriteInPlace(t.f, dest)
 	if err != nil {
@@ -105,6 +125,18 @@ func (t *msiTransformer) Apply(dest, mimeType string, result io.Reader) error {
 	return f.Commit()
 }
 
+// signatureHash returns the digest algorithm of a PKCS#7 signature
+func signatureHash(blob []byte) (crypto.Hash, error) {
+	psd, err := pkcs7.Unmarshal(blob)
+	if err != nil {
+		return 0, err
+	}
+	if len(psd.Content.SignerInfos) != 1 {
+		return 0, errors.New("expected exactly one signer")
+	}
+	return x509tools.PkixDigestToHashE(psd.Content.SignerInfos[0].DigestAlgorithm)
+}
+
 // sign a transformed tarball and return the PKCS#7 blob
 func sign(r io.Reader, cert *certloader.Certificate, opts signers.SignOpts) ([]byte, error) {
 	noExtended := opts.Flags.GetBool("no-extended-sig")
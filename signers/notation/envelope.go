@@ -0,0 +1,165 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package notation
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/mind-security/relic/v8/lib/x509tools"
+)
+
+// Notary Project signature specification:
+// https://github.com/notaryproject/specifications/blob/main/specs/signature-envelope-jws.md
+const (
+	envelopeMediaType = "application/jose+json"
+	payloadMediaType  = "application/vnd.cncf.notary.payload.v1+json"
+	artifactType      = "application/vnd.cncf.notary.signature"
+
+	signingScheme = "notary.x509"
+
+	headerSigningScheme = "io.cncf.notary.signingScheme"
+
+	thumbprintAnnotationKey = "io.cncf.notary.x509chain.thumbprint#S256"
+)
+
+// payload is the signed document naming the manifest being signed
+type payload struct {
+	TargetArtifact descriptor `json:"targetArtifact"`
+}
+
+type descriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+}
+
+type protectedHeader struct {
+	Algorithm     string   `json:"alg"`
+	Critical      []string `json:"crit"`
+	ContentType   string   `json:"cty"`
+	SigningScheme string   `json:"io.cncf.notary.signingScheme"`
+	SigningTime   string   `json:"io.cncf.notary.signingTime"`
+}
+
+type unprotectedHeader struct {
+	X5C          [][]byte `json:"x5c"`
+	SigningAgent string   `json:"io.cncf.notary.signingAgent,omitempty"`
+	Timestamp    []byte   `json:"io.cncf.notary.timestamp,omitempty"`
+}
+
+// envelope is a JWS in flattened JSON serialization
+type envelope struct {
+	Payload   string            `json:"payload"`
+	Protected string            `json:"protected"`
+	Header    unprotectedHeader `json:"header"`
+	Signature string            `json:"signature"`
+}
+
+// algorithm returns the JWS algorithm and digest for a key. The Notary Project
+// fixes both by the key size, and only allows RSASSA-PSS for RSA keys.
+func algorithm(pub crypto.PublicKey) (string, crypto.Hash, error) {
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		switch pub.N.BitLen() {
+		case 2048:
+			return "PS256", crypto.SHA256, nil
+		case 3072:
+			return "PS384", crypto.SHA384, nil
+		case 4096:
+			return "PS512", crypto.SHA512, nil
+		}
+		return "", 0, fmt.Errorf("unsupported RSA key size %d for Notation", pub.N.BitLen())
+	case *ecdsa.PublicKey:
+		switch pub.Curve {
+		case elliptic.P256():
+			return "ES256", crypto.SHA256, nil
+		case elliptic.P384():
+			return "ES384", crypto.SHA384, nil
+		case elliptic.P521():
+			return "ES512", crypto.SHA512, nil
+		}
+		return "", 0, fmt.Errorf("unsupported curve %s for Notation", pub.Curve.Params().Name)
+	default:
+		return "", 0, fmt.Errorf("unsupported public key type %T for Notation", pub)
+	}
+}
+
+// signEnvelope signs the payload and returns the envelope with the
+// certificate chain attached, along with the raw signature for timestamping
+func signEnvelope(signer crypto.Signer, certs []*x509.Certificate, target descriptor, signingTime time.Time) (*envelope, []byte, error) {
+	alg, hash, err := algorithm(signer.Public())
+	if err != nil {
+		return nil, nil, err
+	}
+	hdrBlob, err := json.Marshal(protectedHeader{
+		Algorithm:     alg,
+		Critical:      []string{headerSigningScheme},
+		ContentType:   payloadMediaType,
+		SigningScheme: signingScheme,
+		SigningTime:   signingTime.Format(time.RFC3339),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	payloadBlob, err := json.Marshal(payload{TargetArtifact: target})
+	if err != nil {
+		return nil, nil, err
+	}
+	env := &envelope{
+		Protected: b64(hdrBlob),
+		Payload:   b64(payloadBlob),
+	}
+	d := hash.New()
+	d.Write([]byte(env.Protected + "." + env.Payload))
+	var opts crypto.SignerOpts = hash
+	if _, ok := signer.Public().(*rsa.PublicKey); ok {
+		opts = &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: hash}
+	}
+	sig, err := signer.Sign(rand.Reader, d.Sum(nil), opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	if pub, ok := signer.Public().(*ecdsa.PublicKey); ok {
+		// JWS uses fixed-size R || S instead of ASN.1
+		esig, err := x509tools.UnmarshalEcdsaSignature(sig)
+		if err != nil {
+			return nil, nil, err
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		sig = make([]byte, 2*size)
+		esig.R.FillBytes(sig[:size])
+		esig.S.FillBytes(sig[size:])
+	}
+	env.Signature = b64(sig)
+	for _, cert := range certs {
+		env.Header.X5C = append(env.Header.X5C, cert.Raw)
+	}
+	return env, sig, nil
+}
+
+func b64(d []byte) string {
+	return base64.RawURLEncoding.EncodeToString(d)
+}
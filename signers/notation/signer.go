@@ -0,0 +1,186 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package notation
+
+// Sign container image manifests with a Notary Project (Notation) signature,
+// returned as an OCI artifact manifest that refers to the signed manifest.
+// Pushing it to the registry is left to the client; see doc/notation.md.
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	oci "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/mind-security/relic/v8/config"
+	"github.com/mind-security/relic/v8/lib/certloader"
+	"github.com/mind-security/relic/v8/lib/pkcs9"
+	"github.com/mind-security/relic/v8/signers"
+)
+
+var signer = &signers.Signer{
+	Name:      "notation",
+	Aliases:   []string{"notary"},
+	CertTypes: signers.CertTypeX509,
+	Sign:      sign,
+}
+
+func init() {
+	signers.Register(signer)
+}
+
+// legacy container media types
+const (
+	dockerImageType = "application/vnd.docker.distribution.manifest.v2+json"
+	dockerListType  = "application/vnd.docker.distribution.manifest.list.v2+json"
+)
+
+var allowedManifestTypes = map[string]bool{
+	oci.MediaTypeImageManifest: true,
+	oci.MediaTypeImageIndex:    true,
+	dockerImageType:            true,
+	dockerListType:             true,
+}
+
+func sign(r io.Reader, cert *certloader.Certificate, opts signers.SignOpts) ([]byte, error) {
+	const maxSize = 4 * 1024 * 1024 // spec recommends 4MiB maximum for a manifest
+	manifestBlob, err := io.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return nil, err
+	} else if len(manifestBlob) > maxSize {
+		return nil, fmt.Errorf("image manifest exceeds %d bytes", maxSize)
+	}
+	var mt struct {
+		MediaType string `json:"mediaType"`
+	}
+	if err := json.Unmarshal(manifestBlob, &mt); err != nil {
+		return nil, fmt.Errorf("unable to determine mediaType: %w", err)
+	} else if !allowedManifestTypes[mt.MediaType] {
+		return nil, fmt.Errorf("mediaType %q cannot be signed", mt.MediaType)
+	}
+	if opts.Time.IsZero() {
+		return nil, errors.New("notation signatures require a signing time")
+	}
+	certs, err := fullChain(cert)
+	if err != nil {
+		return nil, err
+	}
+	// registries address manifests by their SHA-256
+	target := descriptor{
+		MediaType: mt.MediaType,
+		Digest:    digest.SHA256.FromBytes(manifestBlob).String(),
+		Size:      int64(len(manifestBlob)),
+	}
+	env, rawSignature, err := signEnvelope(cert.Signer(), certs, target, opts.Time)
+	if err != nil {
+		return nil, err
+	}
+	env.Header.SigningAgent = config.UserAgent
+	if err := attachTimestamp(env, cert, opts, rawSignature); err != nil {
+		return nil, fmt.Errorf("timestamping failed: %w", err)
+	}
+	envBlob, err := json.Marshal(env)
+	if err != nil {
+		return nil, err
+	}
+	thumbprints := make([]string, len(certs))
+	for i, c := range certs {
+		d := sha256.Sum256(c.Raw)
+		thumbprints[i] = hex.EncodeToString(d[:])
+	}
+	thumbprintBlob, err := json.Marshal(thumbprints)
+	if err != nil {
+		return nil, err
+	}
+	// The envelope is the only layer of the signature manifest, and is
+	// embedded in its descriptor so that the client can push it as a blob
+	resp := oci.Manifest{
+		Versioned:    specs.Versioned{SchemaVersion: 2},
+		MediaType:    oci.MediaTypeImageManifest,
+		ArtifactType: artifactType,
+		Config:       oci.DescriptorEmptyJSON,
+		Subject: &oci.Descriptor{
+			MediaType: target.MediaType,
+			Digest:    digest.Digest(target.Digest),
+			Size:      target.Size,
+		},
+		Layers: []oci.Descriptor{{
+			MediaType: envelopeMediaType,
+			Digest:    digest.SHA256.FromBytes(envBlob),
+			Size:      int64(len(envBlob)),
+			Data:      envBlob,
+		}},
+		Annotations: map[string]string{
+			thumbprintAnnotationKey: string(thumbprintBlob),
+		},
+	}
+	opts.Audit.Attributes["notation.manifest-digest"] = target.Digest
+	opts.Audit.SetMimeType(resp.MediaType)
+	return json.Marshal(resp)
+}
+
+// fullChain returns the signing certificate followed by its issuers up to
+// and including the root, which Notation requires in the envelope
+func fullChain(cert *certloader.Certificate) ([]*x509.Certificate, error) {
+	chain := cert.Chain()
+	last := chain[len(chain)-1]
+	if bytes.Equal(last.RawIssuer, last.RawSubject) {
+		return chain, nil
+	}
+	for _, c := range cert.Certificates {
+		if bytes.Equal(c.RawSubject, last.RawIssuer) && bytes.Equal(c.RawIssuer, c.RawSubject) {
+			return append(chain, c), nil
+		}
+	}
+	return nil, errors.New("notation signatures must include the root certificate; bundle it in x509certificate or enable buildchain")
+}
+
+func attachTimestamp(env *envelope, cert *certloader.Certificate, opts signers.SignOpts, rawSignature []byte) error {
+	if cert.Timestamper == nil {
+		return nil
+	}
+	_, hash, err := algorithm(cert.Signer().Public())
+	if err != nil {
+		return err
+	}
+	timestamp, err := cert.Timestamper.Timestamp(opts.Context(), &pkcs9.Request{
+		EncryptedDigest: rawSignature,
+		Hash:            hash,
+	})
+	if err != nil {
+		return err
+	}
+	rawTimestamp, err := timestamp.Marshal()
+	if err != nil {
+		return err
+	}
+	counterSig, err := pkcs9.Verify(timestamp, rawSignature, nil)
+	if err != nil {
+		return fmt.Errorf("timestamp failed signature self-check: %w", err)
+	}
+	opts.Audit.SetCounterSignature(counterSig)
+	env.Header.Timestamp = rawTimestamp
+	return nil
+}
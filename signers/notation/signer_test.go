@@ -0,0 +1,159 @@
+package notation
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	oci "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mind-security/relic/v8/lib/audit"
+	"github.com/mind-security/relic/v8/lib/certloader"
+	"github.com/mind-security/relic/v8/signers"
+)
+
+const testManifest = `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","config":{"mediaType":"application/vnd.oci.image.config.v1+json","digest":"sha256:44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a","size":2}}`
+
+func signManifest(t *testing.T, cert *certloader.Certificate, manifest string) (*oci.Manifest, error) {
+	opts := signers.SignOpts{
+		Time:  time.Now(),
+		Audit: audit.New("test", "notation", crypto.SHA256),
+		Flags: &signers.FlagValues{Defs: signer.Flags()},
+	}
+	blob, err := signer.Sign(strings.NewReader(manifest), cert, opts)
+	if err != nil {
+		return nil, err
+	}
+	resp := new(oci.Manifest)
+	require.NoError(t, json.Unmarshal(blob, resp))
+	return resp, nil
+}
+
+// checkEnvelope verifies the signature manifest and returns the envelope's
+// protected header
+func checkEnvelope(t *testing.T, resp *oci.Manifest, certs []*x509.Certificate) map[string]any {
+	assert.Equal(t, artifactType, resp.ArtifactType)
+	assert.Equal(t, oci.DescriptorEmptyJSON, resp.Config)
+	mdigest := sha256.Sum256([]byte(testManifest))
+	assert.Equal(t, "sha256:"+hex.EncodeToString(mdigest[:]), resp.Subject.Digest.String())
+	require.Len(t, resp.Layers, 1)
+	layer := resp.Layers[0]
+	assert.Equal(t, envelopeMediaType, layer.MediaType)
+	assert.Equal(t, layer.Digest.String(), "sha256:"+hex.EncodeToString(sha256Sum(layer.Data)))
+
+	var thumbprints []string
+	require.NoError(t, json.Unmarshal([]byte(resp.Annotations[thumbprintAnnotationKey]), &thumbprints))
+	require.Len(t, thumbprints, len(certs))
+	for i, c := range certs {
+		assert.Equal(t, hex.EncodeToString(sha256Sum(c.Raw)), thumbprints[i])
+	}
+
+	var env envelope
+	require.NoError(t, json.Unmarshal(layer.Data, &env))
+	require.Len(t, env.Header.X5C, len(certs))
+	// go-jose refuses any crit header, so check the signature directly
+	_, hash, err := algorithm(certs[0].PublicKey)
+	require.NoError(t, err)
+	d := hash.New()
+	d.Write([]byte(env.Protected + "." + env.Payload))
+	sig, err := base64.RawURLEncoding.DecodeString(env.Signature)
+	require.NoError(t, err)
+	switch pub := certs[0].PublicKey.(type) {
+	case *rsa.PublicKey:
+		assert.NoError(t, rsa.VerifyPSS(pub, hash, d.Sum(nil), sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}))
+	case *ecdsa.PublicKey:
+		size := len(sig) / 2
+		r, s := new(big.Int).SetBytes(sig[:size]), new(big.Int).SetBytes(sig[size:])
+		assert.True(t, ecdsa.Verify(pub, d.Sum(nil), r, s))
+	}
+	payloadBlob, err := base64.RawURLEncoding.DecodeString(env.Payload)
+	require.NoError(t, err)
+	var p payload
+	require.NoError(t, json.Unmarshal(payloadBlob, &p))
+	assert.Equal(t, resp.Subject.Digest.String(), p.TargetArtifact.Digest)
+	assert.Equal(t, int64(len(testManifest)), p.TargetArtifact.Size)
+
+	hdrBlob, err := base64.RawURLEncoding.DecodeString(env.Protected)
+	require.NoError(t, err)
+	var hdr map[string]any
+	require.NoError(t, json.Unmarshal(hdrBlob, &hdr))
+	assert.Equal(t, []any{headerSigningScheme}, hdr["crit"])
+	assert.Equal(t, payloadMediaType, hdr["cty"])
+	assert.Equal(t, signingScheme, hdr[headerSigningScheme])
+	return hdr
+}
+
+func sha256Sum(d []byte) []byte {
+	s := sha256.Sum256(d)
+	return s[:]
+}
+
+func TestSignRSA(t *testing.T) {
+	keyBlob, err := os.ReadFile("../../functest/testkeys/rsa2048.key")
+	require.NoError(t, err)
+	key, err := certloader.ParseAnyPrivateKey(keyBlob, nil)
+	require.NoError(t, err)
+	cert, err := certloader.LoadTokenCertificates(key, "../../functest/testkeys/rsa2048.crt", "", nil)
+	require.NoError(t, err)
+	resp, err := signManifest(t, cert, testManifest)
+	require.NoError(t, err)
+	hdr := checkEnvelope(t, resp, []*x509.Certificate{cert.Leaf})
+	assert.Equal(t, "PS256", hdr["alg"])
+
+	_, err = signManifest(t, cert, `{"mediaType":"text/plain"}`)
+	assert.ErrorContains(t, err, "cannot be signed")
+}
+
+func TestSignECDSAChain(t *testing.T) {
+	rootKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	leafKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	issue := func(name string, pub crypto.PublicKey, parent *x509.Certificate) *x509.Certificate {
+		template := &x509.Certificate{
+			SerialNumber:          big.NewInt(time.Now().UnixNano()),
+			Subject:               pkix.Name{CommonName: name},
+			NotBefore:             time.Now().Add(-time.Hour),
+			NotAfter:              time.Now().Add(time.Hour),
+			BasicConstraintsValid: true,
+			IsCA:                  parent == nil,
+			ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		}
+		if parent == nil {
+			parent = template
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, parent, pub, rootKey)
+		require.NoError(t, err)
+		cert, err := x509.ParseCertificate(der)
+		require.NoError(t, err)
+		return cert
+	}
+	root := issue("root", rootKey.Public(), nil)
+	leaf := issue("leaf", leafKey.Public(), root)
+
+	// the root must be available to embed
+	cert := &certloader.Certificate{Leaf: leaf, Certificates: []*x509.Certificate{leaf}, PrivateKey: leafKey}
+	_, err = signManifest(t, cert, testManifest)
+	assert.ErrorContains(t, err, "root certificate")
+
+	cert.Certificates = append(cert.Certificates, root)
+	resp, err := signManifest(t, cert, testManifest)
+	require.NoError(t, err)
+	hdr := checkEnvelope(t, resp, []*x509.Certificate{leaf, root})
+	assert.Equal(t, "ES384", hdr["alg"])
+}
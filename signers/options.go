@@ -22,17 +22,23 @@ import (
 	"crypto/x509"
 	"fmt"
 	"net/url"
+	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ProtonMail/go-crypto/openpgp"
 	"github.com/spf13/pflag"
+	"golang.org/x/crypto/ssh"
 
 	"github.com/mind-security/relic/v8/lib/audit"
 	"github.com/mind-security/relic/v8/lib/binpatch"
+	"github.com/mind-security/relic/v8/lib/digestpool"
 	"github.com/mind-security/relic/v8/lib/magic"
 	"github.com/mind-security/relic/v8/lib/pkcs7"
 	"github.com/mind-security/relic/v8/lib/pkcs9"
+	"github.com/mind-security/relic/v8/lib/x509tools"
+	"github.com/mind-security/relic/v8/lib/zipslicer"
 )
 
 var common *pflag.FlagSet
@@ -40,6 +46,15 @@ var common *pflag.FlagSet
 func init() {
 	common = pflag.NewFlagSet("common", pflag.ExitOnError)
 	common.Bool("no-timestamp", false, "Do not attach a trusted timestamp even if the selected key configures one")
+	common.String("timestamp-url", "", "Use this timestamp server instead of the configured ones. It must be listed in timestamp.allowedurls.")
+	common.Bool("reproducible", false, "Use a fixed timestamp for archive entries created while signing, taken from SOURCE_DATE_EPOCH if set")
+	common.String("source-date-epoch", "", "Timestamp in UNIX seconds to use with --reproducible or --deterministic")
+	common.Bool("deterministic", false, "Produce identical signatures from identical input where the key and format allow it: the signing time is taken from SOURCE_DATE_EPOCH, ECDSA uses RFC 6979 nonces, and no timestamp is added")
+	common.String("signing-time", "", "Signing time to record: \"now\" (default), \"omit\" to rely on the timestamp instead, or a fixed time as RFC 3339 or UNIX seconds")
+	common.Bool("ignore-cert-validity", false, "Sign even if the current time is outside the certificate's validity period")
+	common.Bool("allow-eku-mismatch", false, "Sign even if the certificate's extended key usage doesn't allow the signature type")
+	common.Bool("replace", false, "Sign even if the input is already signed, discarding the existing signatures and their timestamps")
+	common.Bool("nest", false, "Sign even if the input is already signed, adding the new signature alongside the existing ones (only for formats that hold several signatures)")
 }
 
 type SignOpts struct {
@@ -48,7 +63,16 @@ type SignOpts struct {
 	Time  time.Time
 	Flags *FlagValues
 	Audit *audit.Info
-	ctx   context.Context
+	// OmitSigningTime leaves out the signing time, so that only a timestamp
+	// records when the signature was made. Time is still set.
+	OmitSigningTime bool
+	// SpoolThreshold is the largest input that transforms buffer in memory
+	// before switching to a temporary file. Zero means spool.DefaultThreshold.
+	SpoolThreshold int64
+	// DigestLimits bounds the workers and memory used to digest archive
+	// members in parallel
+	DigestLimits digestpool.Limits
+	ctx          context.Context
 }
 
 // Convenience method to return a binary patch
@@ -64,6 +88,45 @@ func (o SignOpts) SetPkcs7(ts *pkcs9.TimestampedSignature) ([]byte, error) {
 	return ts.Raw, nil
 }
 
+// ModTime returns the timestamp to apply to archive entries created while
+// signing. In reproducible or deterministic mode this is fixed, otherwise it is
+// the current time.
+func (o SignOpts) ModTime() (time.Time, error) {
+	if o.Flags == nil || !(o.Flags.GetBool("reproducible") || o.Flags.GetBool("deterministic")) {
+		return time.Now(), nil
+	}
+	return zipslicer.ReproducibleTime(o.Flags.GetString("source-date-epoch"))
+}
+
+// Digests returns the digest algorithms to sign with. Formats that can carry
+// several signatures register a "digests" flag listing them; otherwise, or if
+// it is not set, only Hash is used.
+func (o SignOpts) Digests() ([]crypto.Hash, error) {
+	if o.Flags == nil || o.Flags.Defs == nil || o.Flags.Defs.Lookup("digests") == nil {
+		return []crypto.Hash{o.Hash}, nil
+	}
+	value := o.Flags.GetString("digests")
+	if value == "" {
+		return []crypto.Hash{o.Hash}, nil
+	}
+	var hashes []crypto.Hash
+	seen := make(map[crypto.Hash]bool)
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		hash := x509tools.HashByName(name)
+		if hash == 0 || !hash.Available() {
+			return nil, fmt.Errorf("unknown digest %q", name)
+		} else if x509tools.IsDeprecatedHash(hash) {
+			return nil, fmt.Errorf("digest %s is deprecated", name)
+		}
+		if !seen[hash] {
+			seen[hash] = true
+			hashes = append(hashes, hash)
+		}
+	}
+	return hashes, nil
+}
+
 // WithContext attaches a context to the signature operation, and can be used to cancel long-running operations.
 func (o SignOpts) WithContext(ctx context.Context) SignOpts {
 	o.ctx = ctx
@@ -84,11 +147,20 @@ type VerifyOpts struct {
 	FileName    string
 	TrustedX509 []*x509.Certificate
 	TrustedPgp  openpgp.EntityList
+	TrustedSSH  []ssh.PublicKey
 	TrustedPool *x509.CertPool
-	NoDigests   bool
-	NoChain     bool
-	Content     string
-	Compression magic.CompressionType
+	// Intermediates are untrusted certificates used to complete the chain
+	Intermediates []*x509.Certificate
+	NoDigests     bool
+	NoChain       bool
+	Content       string
+	Compression   magic.CompressionType
+	// AttributePolicy decides whether unknown PKCS#7 attributes are fatal
+	AttributePolicy pkcs7.UnknownAttributePolicy
+	// AlgorithmPolicy decides whether deprecated digest algorithms are fatal
+	AlgorithmPolicy AlgorithmPolicy
+	// TufRoot is the path to trusted TUF root metadata
+	TufRoot string
 }
 
 type FlagValues struct {
@@ -139,6 +211,13 @@ func (s *Signer) FlagsFromCmdline(fs *pflag.FlagSet) (*FlagValues, error) {
 		}
 		return fs.Lookup(name).Value.String()
 	})
+	if (values.GetBool("reproducible") || values.GetBool("deterministic")) && values.Values["source-date-epoch"] == "" {
+		// pick up the epoch from the client's environment so it is passed
+		// along to the server
+		if epoch := os.Getenv("SOURCE_DATE_EPOCH"); epoch != "" {
+			values.Values["source-date-epoch"] = epoch
+		}
+	}
 	return values, nil
 }
 
@@ -0,0 +1,123 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package pecoff
+
+// Sign UEFI applications and drivers for Secure Boot, compatible with sbsign
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"debug/pe"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/mind-security/relic/v8/lib/authenticode"
+	"github.com/mind-security/relic/v8/lib/certloader"
+	"github.com/mind-security/relic/v8/signers"
+)
+
+var EfiSigner = &signers.Signer{
+	Name:      "efi",
+	Aliases:   []string{"sbsign"},
+	CertTypes: signers.CertTypeX509,
+	Sign:      efiSign,
+	Fixup:     authenticode.FixPEChecksum,
+	Verify:    efiVerify,
+	Resign:    signers.ResignReplace,
+
+	KeyUsages:  signers.CodeSigningKeyUsages,
+	Hashes:     []crypto.Hash{crypto.SHA256},
+	CheckInput: efiCheckInput,
+}
+
+func init() {
+	signers.Register(EfiSigner)
+}
+
+// efiCheckInput rejects PE images that aren't UEFI applications or drivers, if
+// the optional header is within the start of the input
+func efiCheckInput(head []byte) error {
+	if err := checkInput(head); err != nil {
+		return err
+	}
+	// the subsystem is at the same offset in PE32 and PE32+ optional headers
+	pos := int64(binary.LittleEndian.Uint32(head[0x3c:])) + 24 + 68
+	if pos+2 <= int64(len(head)) {
+		return checkSubsystem(binary.LittleEndian.Uint16(head[pos:]))
+	}
+	return nil
+}
+
+func checkSubsystem(subsystem uint16) error {
+	if !authenticode.IsEFISubsystem(subsystem) {
+		return fmt.Errorf("not a UEFI image (subsystem %d)", subsystem)
+	}
+	return nil
+}
+
+func efiSign(r io.Reader, cert *certloader.Certificate, opts signers.SignOpts) ([]byte, error) {
+	// firmware only implements what the UEFI spec requires
+	if _, ok := cert.Leaf.PublicKey.(*rsa.PublicKey); !ok {
+		return nil, errors.New("UEFI Secure Boot requires an RSA signing key")
+	} else if opts.Hash != crypto.SHA256 {
+		return nil, errors.New("UEFI Secure Boot requires a SHA-256 digest")
+	}
+	digest, err := authenticode.DigestPE(r, opts.Hash, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSubsystem(digest.Subsystem()); err != nil {
+		return nil, err
+	}
+	// any existing signatures are replaced, as firmware only checks the first
+	patch, ts, err := digest.Sign(opts.Context(), cert, nil)
+	if err != nil {
+		return nil, err
+	}
+	opts.Audit.SetCounterSignature(ts.CounterSignature)
+	return opts.SetBinPatch(patch)
+}
+
+func efiVerify(f *os.File, opts signers.VerifyOpts) ([]*signers.Signature, error) {
+	pf, err := pe.NewFile(f)
+	if err != nil {
+		return nil, err
+	}
+	var subsystem uint16
+	switch oh := pf.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		subsystem = oh.Subsystem
+	case *pe.OptionalHeader64:
+		subsystem = oh.Subsystem
+	}
+	if err := checkSubsystem(subsystem); err != nil {
+		return nil, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	sigs, err := verify(f, opts)
+	if err != nil {
+		return nil, err
+	} else if len(sigs) != 1 {
+		return nil, fmt.Errorf("UEFI images must have exactly one signature, found %d", len(sigs))
+	}
+	return sigs, nil
+}
@@ -0,0 +1,22 @@
+package pecoff
+
+import (
+	"encoding/binary"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEfiCheckInput(t *testing.T) {
+	exe, err := os.ReadFile("../../functest/packages/WindowsFormsApplication1.exe")
+	require.NoError(t, err)
+	assert.ErrorContains(t, efiCheckInput(exe[:4096]), "not a UEFI image")
+	pos := binary.LittleEndian.Uint32(exe[0x3c:]) + 24 + 68
+	binary.LittleEndian.PutUint16(exe[pos:], 10)
+	assert.NoError(t, efiCheckInput(exe[:4096]))
+	// the header isn't always within the start of the input
+	assert.NoError(t, efiCheckInput(exe[:0x80]))
+	assert.ErrorContains(t, efiCheckInput([]byte("garbage")), "missing DOS header")
+}
@@ -19,6 +19,8 @@ package pecoff
 // Sign Microsoft PE/COFF executables
 
 import (
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -27,6 +29,7 @@ import (
 	"github.com/mind-security/relic/v8/lib/authenticode"
 	"github.com/mind-security/relic/v8/lib/certloader"
 	"github.com/mind-security/relic/v8/lib/magic"
+	"github.com/mind-security/relic/v8/lib/x509tools"
 	"github.com/mind-security/relic/v8/signers"
 )
 
@@ -37,10 +40,15 @@ var PeSigner = &signers.Signer{
 	Sign:      sign,
 	Fixup:     authenticode.FixPEChecksum,
 	Verify:    verify,
+	Resign:    signers.ResignReplace,
+
+	KeyUsages: signers.CodeSigningKeyUsages,
+
+	CheckInput: checkInput,
 }
 
 func init() {
-	PeSigner.Flags().Bool("page-hashes", false, "(PE-COFF) Add page hashes to signature")
+	PeSigner.Flags().Bool("page-hashes", false, "(PE-COFF) Add page hashes to signature (SHA-256, or SHA-1 for SHA-1 signatures)")
 	AddOpusFlags(PeSigner)
 	signers.Register(PeSigner)
 }
@@ -68,10 +76,26 @@ func sign(r io.Reader, cert *certloader.Certificate, opts signers.SignOpts) ([]b
 		return nil, err
 	}
 	opts.Audit.Attributes["pe-coff.pagehashes"] = pageHashes
+	if pageHashes {
+		opts.Audit.Attributes["pe-coff.pagehash"] = x509tools.HashNames[digest.PageHash]
+	}
 	opts.Audit.SetCounterSignature(ts.CounterSignature)
 	return opts.SetBinPatch(patch)
 }
 
+// checkInput rejects input that doesn't start with a DOS header pointing to a
+// PE signature
+func checkInput(head []byte) error {
+	if len(head) < 0x40 || string(head[:2]) != "MZ" {
+		return errors.New("missing DOS header")
+	}
+	peStart := int64(binary.LittleEndian.Uint32(head[0x3c:]))
+	if peStart+4 <= int64(len(head)) && string(head[peStart:peStart+4]) != "PE\x00\x00" {
+		return errors.New("missing PE header")
+	}
+	return nil
+}
+
 func FormatOpus(info *authenticode.SpcSpOpusInfo) string {
 	if info == nil {
 		return ""
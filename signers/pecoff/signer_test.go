@@ -0,0 +1,49 @@
+package pecoff
+
+import (
+	"crypto"
+	"debug/pe"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mind-security/relic/v8/internal/signertest"
+	"github.com/mind-security/relic/v8/lib/authenticode"
+	"github.com/mind-security/relic/v8/signers"
+)
+
+func TestPageHashMismatch(t *testing.T) {
+	cert := signertest.LoadCert(t, false)
+	opts := signertest.Opts(PeSigner, time.Now(), map[string]string{"page-hashes": "true"})
+	opts.Hash = crypto.SHA384
+	signed := signertest.SignPatch(t, PeSigner, "../../functest/packages/WindowsFormsApplication1.exe", cert, opts)
+
+	f, err := os.Open(signed)
+	require.NoError(t, err)
+	defer f.Close()
+	sigs, err := authenticode.VerifyPE(f, false)
+	require.NoError(t, err)
+	require.Len(t, sigs, 1)
+	// page hashes use SHA-256 even for stronger image digests
+	assert.Equal(t, crypto.SHA384, sigs[0].ImageHashFunc)
+	assert.Equal(t, crypto.SHA256, sigs[0].PageHashFunc)
+	assert.NotEmpty(t, sigs[0].PageHashes)
+
+	// change a byte in the second page of the first section
+	image, err := pe.NewFile(f)
+	require.NoError(t, err)
+	section := image.Sections[0]
+	require.Greater(t, section.Size, uint32(0x1100))
+	blob, err := os.ReadFile(signed)
+	require.NoError(t, err)
+	page := section.Offset + 0x1000
+	blob[page+0x80] ^= 0xff
+	require.NoError(t, os.WriteFile(signed, blob, 0644))
+
+	_, err = signertest.Verify(t, PeSigner, signed, signers.VerifyOpts{})
+	assert.EqualError(t, err, fmt.Sprintf("page hash mismatch for page(s) at 0x%x", page))
+}
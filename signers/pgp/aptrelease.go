@@ -0,0 +1,233 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package pgp
+
+// Sign Debian repository Release files, producing both the detached Release.gpg
+// and the clearsigned InRelease in one pass
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/clearsign"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+
+	"github.com/mind-security/relic/v8/lib/atomicfile"
+	"github.com/mind-security/relic/v8/lib/certloader"
+	"github.com/mind-security/relic/v8/lib/pgptools"
+	"github.com/mind-security/relic/v8/lib/spool"
+	"github.com/mind-security/relic/v8/signers"
+	"github.com/mind-security/relic/v8/signers/sigerrors"
+)
+
+const (
+	detachedName  = "Release.gpg"
+	clearsignName = "InRelease"
+)
+
+var AptReleaseSigner = &signers.Signer{
+	Name:      "apt-release",
+	CertTypes: signers.CertTypePgp,
+	TestPath:  testAptPath,
+	Transform: aptTransform,
+	Sign:      aptSign,
+	Verify:    aptVerify,
+
+	NeedsSigningTime: true,
+}
+
+func init() {
+	signers.Register(AptReleaseSigner)
+}
+
+func testAptPath(fp string) bool {
+	return filepath.Base(fp) == "Release"
+}
+
+type aptTransformer struct {
+	stream io.ReadSeeker
+	closer io.Closer
+}
+
+func aptTransform(f *os.File, opts signers.SignOpts) (signers.Transformer, error) {
+	stream := io.ReadSeeker(f)
+	closer := io.Closer(f)
+	if _, err := f.Seek(0, 0); err != nil {
+		sp, err := spool.New(f, opts.SpoolThreshold)
+		if err != nil {
+			return nil, err
+		}
+		stream, closer = sp, sp
+	}
+	return &aptTransformer{stream: stream, closer: closer}, nil
+}
+
+func (t *aptTransformer) GetReader() (io.Reader, error) {
+	if _, err := t.stream.Seek(0, 0); err != nil {
+		return nil, err
+	}
+	return t.stream, nil
+}
+
+// Sign the release twice and return the armored detached signature followed
+// by the signature block for the clearsigned form
+func aptSign(r io.Reader, cert *certloader.Certificate, opts signers.SignOpts) ([]byte, error) {
+	sp, err := spool.New(r, opts.SpoolThreshold)
+	if err != nil {
+		return nil, err
+	}
+	defer sp.Close()
+	config := &packet.Config{
+		DefaultHash: opts.Hash,
+		Time:        func() time.Time { return opts.Time },
+	}
+	if priv := pgptools.SigningKey(cert.PgpKey, 0); priv != nil {
+		config.SigningKeyId = priv.KeyId
+	}
+	var buf bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&buf, cert.PgpKey, sp, config); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	if _, err := sp.Seek(0, 0); err != nil {
+		return nil, err
+	}
+	if err := pgptools.DetachClearSign(&buf, cert.PgpKey, sp, config); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Write the release to dest unchanged, and both signed forms alongside it
+func (t *aptTransformer) Apply(dest, mimeType string, result io.Reader) error {
+	defer t.closer.Close()
+	blob, err := io.ReadAll(result)
+	if err != nil {
+		return err
+	}
+	detached, clearsig, err := splitAptSignatures(blob)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(dest)
+	sigfile, err := atomicfile.WriteAny(filepath.Join(dir, detachedName))
+	if err != nil {
+		return err
+	}
+	defer sigfile.Close()
+	if _, err := sigfile.Write(detached); err != nil {
+		return err
+	}
+	inrelease, err := atomicfile.WriteAny(filepath.Join(dir, clearsignName))
+	if err != nil {
+		return err
+	}
+	defer inrelease.Close()
+	if _, err := t.stream.Seek(0, 0); err != nil {
+		return err
+	}
+	if err := pgptools.MergeClearSign(inrelease, clearsig, t.stream); err != nil {
+		return err
+	}
+	outfile, err := atomicfile.WriteAny(dest)
+	if err != nil {
+		return err
+	}
+	defer outfile.Close()
+	if _, err := t.stream.Seek(0, 0); err != nil {
+		return err
+	}
+	if _, err := io.Copy(outfile, t.stream); err != nil {
+		return err
+	}
+	if err := sigfile.Commit(); err != nil {
+		return err
+	}
+	if err := inrelease.Commit(); err != nil {
+		return err
+	}
+	return outfile.Commit()
+}
+
+func splitAptSignatures(blob []byte) (detached, clearsig []byte, err error) {
+	marker := []byte("-----BEGIN PGP SIGNATURE-----")
+	first := bytes.Index(blob, marker)
+	if first < 0 {
+		return nil, nil, errors.New("apt-release: malformed signature response")
+	}
+	second := bytes.Index(blob[first+len(marker):], marker)
+	if second < 0 {
+		return nil, nil, errors.New("apt-release: malformed signature response")
+	}
+	second += first + len(marker)
+	return blob[:second], blob[second:], nil
+}
+
+// Verify the InRelease and Release.gpg files found next to the release,
+// returning one signature for each form that is present
+func aptVerify(f *os.File, opts signers.VerifyOpts) ([]*signers.Signature, error) {
+	release, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Dir(f.Name())
+	var sigs []*signers.Signature
+	if blob, err := os.ReadFile(filepath.Join(dir, clearsignName)); err == nil {
+		psig, err := pgptools.VerifyClearSign(bytes.NewReader(blob), nil, opts.TrustedPgp)
+		found, err := verifyPgp(psig, clearsignName, err)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", clearsignName, err)
+		}
+		if csblock, _ := clearsign.Decode(blob); !bytes.Equal(csblock.Plaintext, release) {
+			return nil, fmt.Errorf("%s: signed content does not match %s", clearsignName, filepath.Base(f.Name()))
+		}
+		found[0].SigInfo = clearsignName
+		sigs = append(sigs, found...)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if blob, err := os.ReadFile(filepath.Join(dir, detachedName)); err == nil {
+		sigr := io.Reader(bytes.NewReader(blob))
+		if bytes.HasPrefix(blob, []byte("-----BEGIN")) {
+			block, err := armor.Decode(sigr)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", detachedName, err)
+			}
+			sigr = block.Body
+		}
+		psig, err := pgptools.VerifyDetached(sigr, bytes.NewReader(release), opts.TrustedPgp)
+		found, err := verifyPgp(psig, detachedName, err)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", detachedName, err)
+		}
+		found[0].SigInfo = detachedName
+		sigs = append(sigs, found...)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if len(sigs) == 0 {
+		return nil, sigerrors.NotSignedError{Type: "apt release"}
+	}
+	return sigs, nil
+}
@@ -0,0 +1,80 @@
+package pgp
+
+import (
+	"bytes"
+	"crypto"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mind-security/relic/v8/lib/certloader"
+	"github.com/mind-security/relic/v8/signers"
+	"github.com/mind-security/relic/v8/signers/sigerrors"
+)
+
+func TestAptRelease(t *testing.T) {
+	keyBlob, err := os.ReadFile("../../functest/testkeys/rsa2048.key")
+	require.NoError(t, err)
+	key, err := certloader.ParseAnyPrivateKey(keyBlob, nil)
+	require.NoError(t, err)
+	cert, err := certloader.LoadTokenCertificates(key, "", "../../functest/testkeys/rsa2048.pgp", nil)
+	require.NoError(t, err)
+	release, err := os.ReadFile("../../functest/packages/Release")
+	require.NoError(t, err)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "Release")
+	require.NoError(t, os.WriteFile(path, release, 0644))
+	assert.Equal(t, AptReleaseSigner, signers.ByFileName(path))
+
+	// sign and apply
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	opts := signers.SignOpts{Path: path, Hash: crypto.SHA256, Time: time.Now()}
+	xf, err := AptReleaseSigner.Transform(f, opts)
+	require.NoError(t, err)
+	r, err := xf.GetReader()
+	require.NoError(t, err)
+	blob, err := AptReleaseSigner.Sign(r, cert, opts)
+	require.NoError(t, err)
+	require.NoError(t, xf.Apply(path, "", bytes.NewReader(blob)))
+	after, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, release, after)
+
+	// both forms verify
+	vopts := signers.VerifyOpts{TrustedPgp: openpgp.EntityList{cert.PgpKey}}
+	verify := func() ([]*signers.Signature, error) {
+		f, err := os.Open(path)
+		require.NoError(t, err)
+		defer f.Close()
+		return AptReleaseSigner.Verify(f, vopts)
+	}
+	sigs, err := verify()
+	require.NoError(t, err)
+	require.Len(t, sigs, 2)
+	assert.Equal(t, "InRelease", sigs[0].SigInfo)
+	assert.Equal(t, "Release.gpg", sigs[1].SigInfo)
+	for _, sig := range sigs {
+		assert.Equal(t, crypto.SHA256, sig.Hash)
+		assert.Equal(t, cert.PgpKey.PrimaryKey.KeyId, sig.SignerPgp.PrimaryKey.KeyId)
+	}
+
+	// a modified release no longer matches either signature
+	require.NoError(t, os.WriteFile(path, append(release, "Extra: yes\n"...), 0644))
+	_, err = verify()
+	assert.ErrorContains(t, err, "InRelease")
+	require.NoError(t, os.Remove(filepath.Join(dir, "InRelease")))
+	_, err = verify()
+	assert.ErrorContains(t, err, "Release.gpg")
+
+	// no signatures at all
+	require.NoError(t, os.Remove(filepath.Join(dir, "Release.gpg")))
+	_, err = verify()
+	assert.ErrorAs(t, err, new(sigerrors.NotSignedError))
+}
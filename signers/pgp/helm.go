@@ -0,0 +1,270 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package pgp
+
+// Sign Helm charts, producing a provenance file beside the chart
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"gopkg.in/yaml.v3"
+
+	"github.com/mind-security/relic/v8/lib/atomicfile"
+	"github.com/mind-security/relic/v8/lib/certloader"
+	"github.com/mind-security/relic/v8/lib/magic"
+	"github.com/mind-security/relic/v8/lib/pgptools"
+	"github.com/mind-security/relic/v8/signers"
+	"github.com/mind-security/relic/v8/signers/sigerrors"
+)
+
+const provSuffix = ".prov"
+
+// Chart.yaml is small, so don't read too much of a mistaken input
+const maxChartYaml = 1 << 20
+
+var HelmSigner = &signers.Signer{
+	Name:           "helm",
+	Magic:          magic.FileTypeHelm,
+	CertTypes:      signers.CertTypePgp,
+	Transform:      helmTransform,
+	Sign:           helmSign,
+	Verify:         helmVerify,
+	DetachedSuffix: func(*signers.FlagValues) string { return provSuffix },
+
+	NeedsSigningTime: true,
+}
+
+func init() {
+	signers.Register(HelmSigner)
+}
+
+// helmSums lists the digests of the signed chart archives
+type helmSums struct {
+	Files map[string]string `yaml:"files"`
+}
+
+type helmTransformer struct {
+	f *os.File
+}
+
+func helmTransform(f *os.File, opts signers.SignOpts) (signers.Transformer, error) {
+	return helmTransformer{f: f}, nil
+}
+
+func (t helmTransformer) GetReader() (io.Reader, error) {
+	if _, err := t.f.Seek(0, 0); err != nil {
+		return nil, err
+	}
+	return t.f, nil
+}
+
+// Write the provenance file beside the chart, or to dest itself if it was
+// chosen with --detach-output. The chart is copied to dest if that is
+// somewhere else.
+func (t helmTransformer) Apply(dest, mimeType string, result io.Reader) error {
+	provPath := dest + provSuffix
+	if strings.HasSuffix(dest, provSuffix) {
+		provPath, dest = dest, ""
+	}
+	prov, err := atomicfile.WriteAny(provPath)
+	if err != nil {
+		return err
+	}
+	defer prov.Close()
+	if _, err := io.Copy(prov, result); err != nil {
+		return err
+	}
+	if dest != "" && dest != t.f.Name() {
+		outfile, err := atomicfile.WriteAny(dest)
+		if err != nil {
+			return err
+		}
+		defer outfile.Close()
+		if _, err := t.f.Seek(0, 0); err != nil {
+			return err
+		}
+		if _, err := io.Copy(outfile, t.f); err != nil {
+			return err
+		}
+		if err := outfile.Commit(); err != nil {
+			return err
+		}
+	}
+	return prov.Commit()
+}
+
+// readChart digests a packaged chart and returns its Chart.yaml
+func readChart(r io.Reader) (chartYaml []byte, digest string, err error) {
+	d := sha256.New()
+	zr, err := gzip.NewReader(io.TeeReader(r, d))
+	if err != nil {
+		return nil, "", err
+	}
+	tr := tar.NewReader(zr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, "", err
+		}
+		name := path.Clean(hdr.Name)
+		if chartYaml == nil && path.Base(name) == "Chart.yaml" && strings.Count(name, "/") == 1 {
+			chartYaml, err = io.ReadAll(io.LimitReader(tr, maxChartYaml))
+			if err != nil {
+				return nil, "", err
+			}
+		}
+	}
+	// the digest covers the whole file, not just the archive
+	if _, err := io.Copy(io.Discard, zr); err != nil {
+		return nil, "", err
+	}
+	if _, err := io.Copy(d, r); err != nil {
+		return nil, "", err
+	}
+	if chartYaml == nil {
+		return nil, "", errors.New("Chart.yaml not found in chart archive")
+	}
+	return chartYaml, "sha256:" + hex.EncodeToString(d.Sum(nil)), nil
+}
+
+// helmMessage builds the signed part of a provenance file: the chart metadata
+// and the digest of the chart archive, as separate YAML documents
+func helmMessage(chartYaml []byte, filename, digest string) ([]byte, string, error) {
+	var meta map[string]interface{}
+	if err := yaml.Unmarshal(chartYaml, &meta); err != nil {
+		return nil, "", fmt.Errorf("parsing Chart.yaml: %w", err)
+	}
+	name, _ := meta["name"].(string)
+	version := fmt.Sprint(meta["version"])
+	if name == "" || meta["version"] == nil {
+		return nil, "", errors.New("Chart.yaml must set name and version")
+	}
+	if filename == "" {
+		filename = name + "-" + version + ".tgz"
+	}
+	var buf bytes.Buffer
+	if err := encodeYaml(&buf, meta); err != nil {
+		return nil, "", err
+	}
+	// "---" can't start a line in a cleartext signature, so separate the
+	// documents with the end marker like helm does
+	buf.WriteString("\n...\n")
+	if err := encodeYaml(&buf, helmSums{Files: map[string]string{filename: digest}}); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), name + "-" + version, nil
+}
+
+func encodeYaml(w io.Writer, v interface{}) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+func helmSign(r io.Reader, cert *certloader.Certificate, opts signers.SignOpts) ([]byte, error) {
+	chartYaml, digest, err := readChart(r)
+	if err != nil {
+		return nil, err
+	}
+	var filename string
+	if opts.Path != "" && opts.Path != "-" {
+		filename = filepath.Base(opts.Path)
+	}
+	message, chart, err := helmMessage(chartYaml, filename, digest)
+	if err != nil {
+		return nil, err
+	}
+	config := &packet.Config{
+		DefaultHash: opts.Hash,
+		Time:        func() time.Time { return opts.Time },
+	}
+	if priv := pgptools.SigningKey(cert.PgpKey, 0); priv != nil {
+		config.SigningKeyId = priv.KeyId
+	}
+	var buf bytes.Buffer
+	if err := pgptools.ClearSign(&buf, cert.PgpKey, bytes.NewReader(message), config); err != nil {
+		return nil, err
+	}
+	opts.Audit.Attributes["helm.chart"] = chart
+	opts.Audit.Attributes["helm.digest"] = digest
+	opts.Audit.SetMimeType("application/pgp-signature")
+	return buf.Bytes(), nil
+}
+
+// Verify the provenance file beside the chart and check that it lists the
+// chart's digest
+func helmVerify(f *os.File, opts signers.VerifyOpts) ([]*signers.Signature, error) {
+	provPath := f.Name() + provSuffix
+	blob, err := os.ReadFile(provPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, sigerrors.NotSignedError{Type: "helm chart"}
+	} else if err != nil {
+		return nil, err
+	}
+	var message bytes.Buffer
+	psig, err := pgptools.VerifyClearSign(bytes.NewReader(blob), &message, opts.TrustedPgp)
+	found, err := verifyPgp(psig, filepath.Base(provPath), err)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(provPath), err)
+	}
+	// the verified text has canonical line endings
+	plaintext := strings.ReplaceAll(message.String(), "\r\n", "\n")
+	_, sumsYaml, ok := strings.Cut(plaintext, "\n...\n")
+	if !ok {
+		return nil, fmt.Errorf("%s: malformed provenance file", filepath.Base(provPath))
+	}
+	var sums helmSums
+	if err := yaml.Unmarshal([]byte(sumsYaml), &sums); err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(provPath), err)
+	}
+	if !opts.NoDigests {
+		if _, err := f.Seek(0, 0); err != nil {
+			return nil, err
+		}
+		d := sha256.New()
+		if _, err := io.Copy(d, f); err != nil {
+			return nil, err
+		}
+		filename := filepath.Base(f.Name())
+		expected := sums.Files[filename]
+		if expected == "" {
+			return nil, fmt.Errorf("%s: no digest for %s", filepath.Base(provPath), filename)
+		} else if expected != "sha256:"+hex.EncodeToString(d.Sum(nil)) {
+			return nil, fmt.Errorf("%s: digest mismatch for %s", filepath.Base(provPath), filename)
+		}
+	}
+	found[0].SigInfo = filepath.Base(provPath)
+	return found, nil
+}
@@ -0,0 +1,115 @@
+package pgp
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/clearsign"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/mind-security/relic/v8/lib/audit"
+	"github.com/mind-security/relic/v8/lib/certloader"
+	"github.com/mind-security/relic/v8/signers"
+	"github.com/mind-security/relic/v8/signers/sigerrors"
+)
+
+func writeTestChart(t *testing.T, path string) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(zw)
+	for _, f := range []struct{ name, body string }{
+		{"mychart/Chart.yaml", "# a comment\napiVersion: v2\nname: mychart\nversion: 0.1.0\nmaintainers:\n- name: someone\n"},
+		{"mychart/values.yaml", "replicas: 1\n"},
+	} {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: f.name, Mode: 0644, Size: int64(len(f.body))}))
+		_, err := tw.Write([]byte(f.body))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, zw.Close())
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0644))
+}
+
+func TestHelm(t *testing.T) {
+	keyBlob, err := os.ReadFile("../../functest/testkeys/rsa2048.key")
+	require.NoError(t, err)
+	key, err := certloader.ParseAnyPrivateKey(keyBlob, nil)
+	require.NoError(t, err)
+	cert, err := certloader.LoadTokenCertificates(key, "", "../../functest/testkeys/rsa2048.pgp", nil)
+	require.NoError(t, err)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "mychart-0.1.0.tgz")
+	writeTestChart(t, path)
+	mod, err := signers.ByFile(path, "")
+	require.NoError(t, err)
+	assert.Equal(t, HelmSigner, mod)
+
+	// sign and write the provenance file beside the chart
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	opts := signers.SignOpts{
+		Path:  path,
+		Hash:  crypto.SHA256,
+		Time:  time.Now(),
+		Audit: audit.New("rsa2048", "helm", crypto.SHA256),
+	}
+	xf, err := HelmSigner.Transform(f, opts)
+	require.NoError(t, err)
+	r, err := xf.GetReader()
+	require.NoError(t, err)
+	blob, err := HelmSigner.Sign(r, cert, opts)
+	require.NoError(t, err)
+	require.NoError(t, xf.Apply(path, opts.Audit.GetMimeType(), bytes.NewReader(blob)))
+	assert.Equal(t, "mychart-0.1.0", opts.Audit.Attributes["helm.chart"])
+
+	// the message is parsed the same way as by helm
+	prov, err := os.ReadFile(path + ".prov")
+	require.NoError(t, err)
+	block, _ := clearsign.Decode(prov)
+	require.NotNil(t, block)
+	parts := strings.Split(string(block.Plaintext), "\n...\n")
+	require.Len(t, parts, 2)
+	var meta struct {
+		Name        string
+		Version     string
+		Maintainers []struct{ Name string }
+	}
+	require.NoError(t, yaml.Unmarshal([]byte(parts[0]), &meta))
+	assert.Equal(t, "mychart", meta.Name)
+	assert.Equal(t, "0.1.0", meta.Version)
+	require.Len(t, meta.Maintainers, 1)
+	var sums helmSums
+	require.NoError(t, yaml.Unmarshal([]byte(parts[1]), &sums))
+	assert.Equal(t, opts.Audit.Attributes["helm.digest"], sums.Files["mychart-0.1.0.tgz"])
+
+	vopts := signers.VerifyOpts{TrustedPgp: openpgp.EntityList{cert.PgpKey}}
+	verify := func() ([]*signers.Signature, error) {
+		f, err := os.Open(path)
+		require.NoError(t, err)
+		defer f.Close()
+		return HelmSigner.Verify(f, vopts)
+	}
+	sigs, err := verify()
+	require.NoError(t, err)
+	require.Len(t, sigs, 1)
+	assert.Equal(t, cert.PgpKey.PrimaryKey.KeyId, sigs[0].SignerPgp.PrimaryKey.KeyId)
+
+	// a different chart doesn't match
+	require.NoError(t, os.WriteFile(path, append(blob, 0), 0644))
+	_, err = verify()
+	assert.ErrorContains(t, err, "digest mismatch")
+	require.NoError(t, os.Remove(path+".prov"))
+	_, err = verify()
+	assert.ErrorAs(t, err, new(sigerrors.NotSignedError))
+}
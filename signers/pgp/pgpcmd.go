@@ -22,10 +22,14 @@ package pgp
 // command and calls it.
 
 import (
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"os"
 	"strings"
 
 	"github.com/mind-security/relic/v8/cmdline/shared"
+	"github.com/mind-security/relic/v8/lib/pgptools"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
@@ -33,12 +37,14 @@ import (
 
 var (
 	argDigest       string
+	argComment      string
 	argOutput       string
 	argPgpUser      string
 	argPgpArmor     bool
 	argPgpDetached  bool
 	argPgpClearsign bool
 	argPgpTextMode  bool
+	argStatusFd     int
 )
 
 func AddCompatFlags(cmd *cobra.Command) {
@@ -50,6 +56,7 @@ func AddCompatFlags(cmd *cobra.Command) {
 	flags.BoolVarP(&argPgpDetached, "detach-sign", "b", false, "Create a detached signature")
 	flags.BoolVar(&argPgpClearsign, "clearsign", false, "Create a cleartext signature")
 	flags.StringVar(&argDigest, "digest-algo", "", "Digest algorithm")
+	flags.StringVar(&argComment, "comment", "", "Add a Comment header to ASCII armored output")
 
 	flags.BoolP("sign", "s", false, "(ignored)")
 	flags.BoolP("verbose", "v", false, "(ignored)")
@@ -57,7 +64,9 @@ func AddCompatFlags(cmd *cobra.Command) {
 	flags.Bool("no-verbose", false, "(ignored)")
 	flags.BoolP("quiet", "q", false, "(ignored)")
 	flags.Bool("no-secmem-warning", false, "(ignored)")
-	flags.String("status-fd", "", "(ignored)")
+	flags.Bool("no-comments", false, "(ignored)")
+	flags.Bool("no-emit-version", false, "(ignored)")
+	flags.IntVar(&argStatusFd, "status-fd", -1, "Write gpg-style status lines to this file descriptor, as git expects when used as gpg.program")
 	flags.String("logger-fd", "", "(ignored)")
 	flags.String("attribute-fd", "", "(ignored)")
 }
@@ -84,7 +93,18 @@ func CallCmd(src, dest *cobra.Command, args []string) error {
 	if argOutput == "" {
 		argOutput = "-"
 	}
-	setFlag(dest.Flags(), "output", argOutput)
+	outpath := argOutput
+	if argStatusFd >= 0 && outpath == "-" {
+		// write to a temp file so the signature can be inspected afterwards
+		tmp, err := os.CreateTemp("", "relic-sign-pgp-")
+		if err != nil {
+			return err
+		}
+		tmp.Close()
+		defer os.Remove(tmp.Name())
+		outpath = tmp.Name()
+	}
+	setFlag(dest.Flags(), "output", outpath)
 	if argPgpArmor {
 		setFlag(dest.Flags(), "armor", "true")
 	}
@@ -99,7 +119,47 @@ func CallCmd(src, dest *cobra.Command, args []string) error {
 	if argDigest != "" {
 		setFlag(dest.Flags(), "digest", argDigest)
 	}
-	return dest.RunE(dest, []string{})
+	if argComment != "" {
+		setFlag(dest.Flags(), "armor-comment", argComment)
+	}
+	if err := dest.RunE(dest, []string{}); err != nil {
+		return err
+	}
+	if argStatusFd < 0 {
+		return nil
+	}
+	blob, err := os.ReadFile(outpath)
+	if err != nil {
+		return err
+	}
+	if err := writeStatus(blob); err != nil {
+		return fmt.Errorf("writing status: %w", err)
+	}
+	if outpath != argOutput {
+		_, err = os.Stdout.Write(blob)
+	}
+	return err
+}
+
+// writeStatus writes the status lines that gpg would for the signature in
+// blob. git requires SIG_CREATED to tell that signing succeeded.
+func writeStatus(blob []byte) error {
+	sig, err := pgptools.ReadSignature(blob)
+	if err != nil {
+		return err
+	}
+	kind := "D"
+	if argPgpClearsign {
+		kind = "C"
+	} else if !argPgpDetached {
+		kind = "S"
+	}
+	hashID, _ := pgptools.HashID(sig.Hash)
+	fpr := strings.ToUpper(hex.EncodeToString(sig.IssuerFingerprint))
+	w := os.NewFile(uintptr(argStatusFd), "status")
+	_, err = fmt.Fprintf(w, "[GNUPG:] KEY_CONSIDERED %s 0\n[GNUPG:] BEGIN_SIGNING H%d\n[GNUPG:] SIG_CREATED %s %d %d %02X %d %s\n",
+		fpr, hashID, kind, sig.PubKeyAlgo, hashID, byte(sig.SigType), sig.CreationTime.Unix(), fpr)
+	return err
 }
 
 func setFlag(flags *pflag.FlagSet, name, value string) {
@@ -21,6 +21,7 @@ package pgp
 import (
 	"bufio"
 	"bytes"
+	"crypto"
 	"errors"
 	"fmt"
 	"io"
@@ -37,6 +38,7 @@ import (
 	"github.com/mind-security/relic/v8/lib/certloader"
 	"github.com/mind-security/relic/v8/lib/magic"
 	"github.com/mind-security/relic/v8/lib/pgptools"
+	"github.com/mind-security/relic/v8/lib/spool"
 	"github.com/mind-security/relic/v8/signers"
 )
 
@@ -48,23 +50,48 @@ var PgpSigner = &signers.Signer{
 	Transform:    transform,
 	Sign:         sign,
 	VerifyStream: verify,
-}
 
-const maxStreamClearSignSize = 10 * 1000 * 1000
+	DetachedSuffix:   detachedSuffix,
+	NeedsSigningTime: true,
+}
 
 func init() {
 	PgpSigner.Flags().BoolP("armor", "a", false, "(PGP) Create ASCII armored output")
 	PgpSigner.Flags().Bool("inline", false, "(PGP) Create a signed message instead of a detached signature")
 	PgpSigner.Flags().Bool("clearsign", false, "(PGP) Create a cleartext signature")
 	PgpSigner.Flags().BoolP("textmode", "t", false, "(PGP) Sign in CRLF canonical text form")
+	PgpSigner.Flags().String("armor-comment", "", "(PGP) Add a Comment header to ASCII armored output")
+	PgpSigner.Flags().String("armor-version", "", "(PGP) Add a Version header to ASCII armored output")
 	// for compat with 2.0 clients
 	PgpSigner.Flags().String("pgp", "", "")
 	_ = PgpSigner.Flags().MarkHidden("pgp")
 	signers.Register(PgpSigner)
 }
 
+func detachedSuffix(flags *signers.FlagValues) string {
+	switch {
+	case flags.GetBool("inline"), flags.GetBool("clearsign"), flags.GetString("pgp") == "mini-clear":
+		return ""
+	case flags.GetBool("armor"):
+		return ".asc"
+	default:
+		return ".sig"
+	}
+}
+
+// armorHeaders returns the headers to write in ASCII armored output. None are
+// written unless asked for.
+func armorHeaders(flags *signers.FlagValues) (pgptools.ArmorHeaders, error) {
+	headers := pgptools.ArmorHeaders{
+		Comment: flags.GetString("armor-comment"),
+		Version: flags.GetString("armor-version"),
+	}
+	return headers, headers.Validate()
+}
+
 type pgpTransformer struct {
 	inline, clearsign, armor bool
+	headers                  pgptools.ArmorHeaders
 
 	filename string
 	stream   io.ReadSeeker
@@ -79,24 +106,28 @@ func transform(f *os.File, opts signers.SignOpts) (signers.Transformer, error) {
 		opts.Flags.Values["armor"] = "false"
 	}
 	clearsign := opts.Flags.GetBool("clearsign")
+	headers, err := armorHeaders(opts.Flags)
+	if err != nil {
+		return nil, err
+	}
 	stream := io.ReadSeeker(f)
+	closer := io.Closer(f)
 	if _, err := f.Seek(0, 0); err != nil {
-		// not seekable so consume it all now
-		contents, err := ioutil.ReadAll(io.LimitReader(stream, maxStreamClearSignSize))
+		// not seekable so consume it all now, spilling to disk if it's big
+		sp, err := spool.New(f, opts.SpoolThreshold)
 		if err != nil {
 			return nil, err
-		} else if len(contents) == maxStreamClearSignSize {
-			return nil, errors.New("input stream is too big, try writing it to file first")
 		}
-		stream = bytes.NewReader(contents)
+		stream, closer = sp, sp
 	}
 	return &pgpTransformer{
 		inline:    inline,
 		clearsign: clearsign,
 		armor:     armor,
+		headers:   headers,
 		filename:  filepath.Base(f.Name()),
 		stream:    stream,
-		closer:    f,
+		closer:    closer,
 	}, nil
 }
 
@@ -114,6 +145,10 @@ func sign(r io.Reader, cert *certloader.Certificate, opts signers.SignOpts) ([]b
 	if pgpcompat := opts.Flags.GetString("pgp"); pgpcompat == "mini-clear" {
 		clearsign = true
 	}
+	headers, err := armorHeaders(opts.Flags)
+	if err != nil {
+		return nil, err
+	}
 	var sf func(io.Writer, *openpgp.Entity, io.Reader, *packet.Config) error
 	if clearsign {
 		sf = pgptools.DetachClearSign
@@ -135,12 +170,55 @@ func sign(r io.Reader, cert *certloader.Certificate, opts signers.SignOpts) ([]b
 		DefaultHash: opts.Hash,
 		Time:        func() time.Time { return opts.Time },
 	}
+	// make sure the library picks the key that the token holds, which may be
+	// a subkey
+	priv := pgptools.SigningKey(cert.PgpKey, 0)
+	if priv != nil {
+		config.SigningKeyId = priv.KeyId
+	}
+	if priv != nil && pgptools.NeedsSignKey(priv) {
+		if clearsign {
+			return nil, errors.New("cleartext signatures are only supported for RSA keys")
+		}
+		sigType := packet.SigTypeBinary
+		if textmode {
+			sigType = packet.SigTypeText
+		}
+		sf = signKey(priv, sigType, armor)
+	}
 	if err := sf(&buf, cert.PgpKey, r, config); err != nil {
 		return nil, err
-	} else if armor {
-		buf.WriteByte('\n')
 	}
-	return buf.Bytes(), nil
+	if !armor && !clearsign {
+		return buf.Bytes(), nil
+	}
+	sig, err := headers.Insert(buf.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	if armor {
+		sig = append(sig, '\n')
+	}
+	return sig, nil
+}
+
+// signKey returns a signing function that uses pgptools.DetachSignKey, for
+// token keys that the openpgp package can't sign with
+func signKey(priv *packet.PrivateKey, sigType packet.SignatureType, armored bool) func(io.Writer, *openpgp.Entity, io.Reader, *packet.Config) error {
+	return func(w io.Writer, _ *openpgp.Entity, message io.Reader, config *packet.Config) error {
+		signer := priv.PrivateKey.(crypto.Signer)
+		if !armored {
+			return pgptools.DetachSignKey(w, &priv.PublicKey, signer, message, sigType, config)
+		}
+		aw, err := armor.Encode(w, openpgp.SignatureType, nil)
+		if err != nil {
+			return err
+		}
+		if err := pgptools.DetachSignKey(aw, &priv.PublicKey, signer, message, sigType, config); err != nil {
+			return err
+		}
+		return aw.Close()
+	}
 }
 
 func (t *pgpTransformer) Apply(dest, mimeType string, result io.Reader) error {
@@ -161,7 +239,11 @@ func (t *pgpTransformer) Apply(dest, mimeType string, result io.Reader) error {
 		if t.clearsign {
 			err = pgptools.MergeClearSign(outfile, sig, t.stream)
 		} else {
-			err = pgptools.MergeSignature(outfile, sig, t.stream, t.armor, t.filename)
+			var headers *pgptools.ArmorHeaders
+			if t.armor {
+				headers = &t.headers
+			}
+			err = pgptools.MergeSignature(outfile, sig, t.stream, headers, t.filename)
 		}
 		if err != nil {
 			return err
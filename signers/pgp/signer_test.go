@@ -0,0 +1,102 @@
+package pgp
+
+import (
+	"bytes"
+	"crypto"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mind-security/relic/v8/lib/audit"
+	"github.com/mind-security/relic/v8/lib/certloader"
+	"github.com/mind-security/relic/v8/signers"
+)
+
+func TestArmorHeaders(t *testing.T) {
+	keyBlob, err := os.ReadFile("../../functest/testkeys/rsa2048.key")
+	require.NoError(t, err)
+	key, err := certloader.ParseAnyPrivateKey(keyBlob, nil)
+	require.NoError(t, err)
+	cert, err := certloader.LoadTokenCertificates(key, "", "../../functest/testkeys/rsa2048.pgp", nil)
+	require.NoError(t, err)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "message.txt")
+	message := []byte("hello\n-- world\n")
+	require.NoError(t, os.WriteFile(path, message, 0644))
+
+	signFile := func(flags map[string]string) []byte {
+		f, err := os.Open(path)
+		require.NoError(t, err)
+		defer f.Close()
+		opts := signers.SignOpts{
+			Path:  path,
+			Hash:  crypto.SHA256,
+			Time:  time.Now(),
+			Audit: audit.New("rsa2048", "pgp", crypto.SHA256),
+			Flags: &signers.FlagValues{Defs: PgpSigner.Flags(), Values: flags},
+		}
+		xf, err := PgpSigner.Transform(f, opts)
+		require.NoError(t, err)
+		r, err := xf.GetReader()
+		require.NoError(t, err)
+		blob, err := PgpSigner.Sign(r, cert, opts)
+		require.NoError(t, err)
+		out := filepath.Join(dir, "out")
+		require.NoError(t, xf.Apply(out, opts.Audit.GetMimeType(), bytes.NewReader(blob)))
+		result, err := os.ReadFile(out)
+		require.NoError(t, err)
+		return result
+	}
+	// decoding the armor also checks the CRC24
+	decode := func(blob []byte) *armor.Block {
+		block, err := armor.Decode(bytes.NewReader(blob))
+		require.NoError(t, err)
+		_, err = io.ReadAll(block.Body)
+		require.NoError(t, err)
+		return block
+	}
+	keyring := openpgp.EntityList{cert.PgpKey}
+
+	// bare armor by default
+	sig := signFile(map[string]string{"armor": "true"})
+	assert.True(t, bytes.HasPrefix(sig, []byte("-----BEGIN PGP SIGNATURE-----\n\n")))
+	assert.Empty(t, decode(sig).Header)
+
+	sig = signFile(map[string]string{"armor": "true", "armor-comment": "built by ci", "armor-version": "relic"})
+	assert.True(t, bytes.HasPrefix(sig, []byte("-----BEGIN PGP SIGNATURE-----\nVersion: relic\nComment: built by ci\n\n")))
+	assert.Equal(t, map[string]string{"Version": "relic", "Comment": "built by ci"}, decode(sig).Header)
+	_, err = openpgp.CheckArmoredDetachedSignature(keyring, bytes.NewReader(message), bytes.NewReader(sig), nil)
+	assert.NoError(t, err)
+
+	// inline messages are armored by the client
+	msg := signFile(map[string]string{"armor": "true", "inline": "true", "armor-comment": "inline"})
+	block := decode(msg)
+	assert.Equal(t, "PGP MESSAGE", block.Type)
+	assert.Equal(t, map[string]string{"Comment": "inline"}, block.Header)
+
+	// cleartext signature block keeps CRLF line endings
+	clear := signFile(map[string]string{"clearsign": "true", "armor-comment": "clear"})
+	assert.Contains(t, string(clear), "-----BEGIN PGP SIGNATURE-----\r\nComment: clear\r\n\r\n")
+	fc, err := os.Open(filepath.Join(dir, "out"))
+	require.NoError(t, err)
+	defer fc.Close()
+	sigs, err := PgpSigner.VerifyStream(fc, signers.VerifyOpts{TrustedPgp: keyring})
+	require.NoError(t, err)
+	require.Len(t, sigs, 1)
+
+	// headers can't break out of their line
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	_, err = PgpSigner.Transform(f, signers.SignOpts{
+		Flags: &signers.FlagValues{Defs: PgpSigner.Flags(), Values: map[string]string{"armor-comment": "a\nb"}},
+	})
+	assert.ErrorContains(t, err, "invalid characters")
+}
@@ -0,0 +1,100 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package pkcs
+
+// Create detached PKCS#7 (CMS) signatures over arbitrary files
+
+import (
+	"encoding/pem"
+	"hash"
+	"io"
+	"strings"
+
+	"github.com/mind-security/relic/v8/lib/certloader"
+	"github.com/mind-security/relic/v8/lib/pkcs7"
+	"github.com/mind-security/relic/v8/lib/pkcs9"
+	"github.com/mind-security/relic/v8/lib/x509tools"
+	"github.com/mind-security/relic/v8/signers"
+)
+
+// MimeType is the media type of a detached CMS signature
+const MimeType = "application/pkcs7-signature"
+
+func testPath(fp string) bool {
+	fp = strings.ToLower(fp)
+	return strings.HasSuffix(fp, ".p7s") || strings.HasSuffix(fp, ".p7b")
+}
+
+func detachedSuffix(*signers.FlagValues) string {
+	return ".p7s"
+}
+
+func sign(r io.Reader, cert *certloader.Certificate, opts signers.SignOpts) ([]byte, error) {
+	hashes, err := opts.Digests()
+	if err != nil {
+		return nil, err
+	}
+	// read the input once, digesting it with every algorithm at the same time
+	digesters := make([]hash.Hash, len(hashes))
+	writers := make([]io.Writer, len(hashes))
+	for i, h := range hashes {
+		digesters[i] = h.New()
+		writers[i] = digesters[i]
+	}
+	if _, err := io.Copy(io.MultiWriter(writers...), r); err != nil {
+		return nil, err
+	}
+	// one signer per digest, all in the same SignedData
+	var psd *pkcs7.ContentInfoSignedData
+	for i, h := range hashes {
+		builder := pkcs7.NewBuilder(cert.Signer(), cert.Chain(), h)
+		if err := builder.SetDetachedContent(pkcs7.OidData, digesters[i].Sum(nil)); err != nil {
+			return nil, err
+		}
+		if !opts.Flags.GetBool("no-signing-time") && !opts.OmitSigningTime {
+			if err := builder.AddAuthenticatedAttribute(pkcs7.OidAttributeSigningTime, opts.Time.UTC()); err != nil {
+				return nil, err
+			}
+		}
+		signed, err := builder.Sign()
+		if err != nil {
+			return nil, err
+		}
+		if psd == nil {
+			psd = signed
+		} else if err := psd.Merge(signed); err != nil {
+			return nil, err
+		}
+	}
+	ts, err := pkcs9.TimestampAndMarshalDetached(opts.Context(), psd, cert.Timestamper)
+	if err != nil {
+		return nil, err
+	}
+	if len(hashes) > 1 {
+		names := make([]string, len(hashes))
+		for i, h := range hashes {
+			names[i] = x509tools.HashShortName(h)
+		}
+		opts.Audit.Attributes["pkcs7.digests"] = strings.Join(names, ",")
+	}
+	opts.Audit.SetCounterSignature(ts.CounterSignature)
+	opts.Audit.SetMimeType(MimeType)
+	if opts.Flags.GetBool("pem") {
+		return pem.EncodeToMemory(&pem.Block{Type: "PKCS7", Bytes: ts.Raw}), nil
+	}
+	return ts.Raw, nil
+}
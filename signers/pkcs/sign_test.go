@@ -0,0 +1,133 @@
+package pkcs
+
+import (
+	"bytes"
+	"crypto"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mind-security/relic/v8/internal/signertest"
+	"github.com/mind-security/relic/v8/lib/certloader"
+	"github.com/mind-security/relic/v8/lib/magic"
+	"github.com/mind-security/relic/v8/lib/pkcs7"
+	"github.com/mind-security/relic/v8/signers"
+)
+
+func signFile(t *testing.T, path string, cert *certloader.Certificate, flags map[string]string) string {
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	opts := signertest.Opts(PkcsSigner, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), flags)
+	blob, err := PkcsSigner.Sign(f, cert, opts)
+	require.NoError(t, err)
+	sigPath := path + detachedSuffix(opts.Flags)
+	require.NoError(t, os.WriteFile(sigPath, blob, 0644))
+	return sigPath
+}
+
+func verifyFile(t *testing.T, sigPath string) ([]*signers.Signature, error) {
+	f, err := os.Open(sigPath)
+	require.NoError(t, err)
+	defer f.Close()
+	assert.Equal(t, magic.FileTypePKCS7, magic.Detect(f))
+	_, err = f.Seek(0, 0)
+	require.NoError(t, err)
+	return PkcsSigner.Verify(f, signers.VerifyOpts{FileName: sigPath})
+}
+
+func TestSignDetached(t *testing.T) {
+	cert := signertest.LoadCert(t, false)
+	for _, tc := range []struct {
+		name  string
+		flags map[string]string
+	}{
+		{"der", map[string]string{}},
+		{"pem", map[string]string{"pem": "true"}},
+		{"no-signing-time", map[string]string{"no-signing-time": "true"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			content := filepath.Join(t.TempDir(), "data.bin")
+			require.NoError(t, os.WriteFile(content, bytes.Repeat([]byte{0x5a}, 10000), 0644))
+			sigPath := signFile(t, content, cert, tc.flags)
+			assert.True(t, testPath(sigPath))
+
+			sigs, err := verifyFile(t, sigPath)
+			require.NoError(t, err)
+			require.Len(t, sigs, 1)
+			assert.Equal(t, crypto.SHA256, sigs[0].Hash)
+			assert.Equal(t, cert.Leaf.Raw, sigs[0].X509Signature.Certificate.Raw)
+			var signingTime time.Time
+			err = sigs[0].X509Signature.SignerInfo.AuthenticatedAttributes.GetOne(pkcs7.OidAttributeSigningTime, &signingTime)
+			if tc.flags["no-signing-time"] != "" {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), signingTime.UTC())
+			}
+
+			// changes to the content are detected
+			require.NoError(t, os.WriteFile(content, []byte("tampered"), 0644))
+			_, err = verifyFile(t, sigPath)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestSignPipe(t *testing.T) {
+	cert := signertest.LoadCert(t, false)
+	content := bytes.Repeat([]byte{0x5a}, 100000)
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	defer r.Close()
+	go func() {
+		_, _ = w.Write(content)
+		w.Close()
+	}()
+	opts := signertest.Opts(PkcsSigner, time.Now(), nil)
+	// a pipe is streamed once without buffering
+	transform, err := PkcsSigner.GetTransform(r, opts)
+	require.NoError(t, err)
+	stream, err := transform.GetReader()
+	require.NoError(t, err)
+	blob, err := PkcsSigner.Sign(stream, cert, opts)
+	require.NoError(t, err)
+	_, err = transform.GetReader()
+	assert.ErrorContains(t, err, "seeking input file")
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "data.bin"), content, 0644))
+	sigPath := filepath.Join(dir, "data.bin.p7s")
+	require.NoError(t, os.WriteFile(sigPath, blob, 0644))
+	sigs, err := verifyFile(t, sigPath)
+	require.NoError(t, err)
+	assert.Len(t, sigs, 1)
+}
+
+func TestSignDigests(t *testing.T) {
+	cert := signertest.LoadCert(t, false)
+	content := filepath.Join(t.TempDir(), "data.bin")
+	require.NoError(t, os.WriteFile(content, bytes.Repeat([]byte{0x5a}, 10000), 0644))
+	sigPath := signFile(t, content, cert, map[string]string{"digests": "sha256, sha512,sha256"})
+
+	sigs, err := verifyFile(t, sigPath)
+	require.NoError(t, err)
+	require.Len(t, sigs, 2)
+	assert.Equal(t, crypto.SHA256, sigs[0].Hash)
+	assert.Equal(t, "sha256", sigs[0].SigInfo)
+	assert.Equal(t, crypto.SHA512, sigs[1].Hash)
+	assert.Equal(t, "sha512", sigs[1].SigInfo)
+
+	// deprecated and unknown digests are refused
+	for _, digests := range []string{"sha1", "sha256,bogus"} {
+		f, err := os.Open(content)
+		require.NoError(t, err)
+		_, err = PkcsSigner.Sign(f, cert, signertest.Opts(PkcsSigner, time.Now(), map[string]string{"digests": digests}))
+		f.Close()
+		assert.Error(t, err, digests)
+	}
+}
@@ -19,8 +19,10 @@ package pkcs
 // Verify PKCS#7 SignedData structures.
 
 import (
+	"encoding/pem"
 	"io/ioutil"
 	"os"
+	"strings"
 
 	"github.com/mind-security/relic/v8/lib/magic"
 	"github.com/mind-security/relic/v8/lib/pkcs7"
@@ -30,15 +32,23 @@ import (
 )
 
 var PkcsSigner = &signers.Signer{
-	Name:      "pkcs7",
-	Magic:     magic.FileTypePKCS7,
-	CertTypes: signers.CertTypeX509,
-	Sign:      nil,
-	Verify:    Verify,
+	Name:       "pkcs7",
+	Aliases:    []string{"cms"},
+	Magic:      magic.FileTypePKCS7,
+	CertTypes:  signers.CertTypeX509,
+	AllowStdin: true,
+	TestPath:   testPath,
+	Sign:       sign,
+	Verify:     Verify,
+
+	DetachedSuffix: detachedSuffix,
 }
 
 func init() {
 	PkcsSigner.Flags().String("content", "", "Specify file containing contents for detached signatures")
+	PkcsSigner.Flags().Bool("pem", false, "(PKCS#7) Write the signature in PEM format instead of DER")
+	PkcsSigner.Flags().Bool("no-signing-time", false, "(PKCS#7) Omit the signing time attribute")
+	PkcsSigner.Flags().String("digests", "", "(PKCS#7, APK) Sign with each of these digests, comma separated (e.g. sha256,sha512), so verifiers can use whichever they support")
 	signers.Register(PkcsSigner)
 }
 
@@ -47,28 +57,46 @@ func Verify(f *os.File, opts signers.VerifyOpts) ([]*signers.Signature, error) {
 	if err != nil {
 		return nil, err
 	}
+	if block, _ := pem.Decode(blob); block != nil {
+		blob = block.Bytes
+	}
 	psd, err := pkcs7.Unmarshal(blob)
 	if err != nil {
 		return nil, err
 	}
+	content := opts.Content
+	if content == "" && strings.HasSuffix(strings.ToLower(opts.FileName), ".p7s") {
+		if embedded, _ := psd.Content.ContentInfo.Bytes(); embedded == nil {
+			// detached signature beside the file it signs
+			content = opts.FileName[:len(opts.FileName)-4]
+		}
+	}
 	var cblob []byte
-	if !opts.NoDigests && opts.Content != "" {
-		cblob, err = ioutil.ReadFile(opts.Content)
+	if !opts.NoDigests && content != "" {
+		cblob, err = ioutil.ReadFile(content)
 		if err != nil {
 			return nil, err
 		}
 	}
-	sig, err := psd.Content.Verify(cblob, opts.NoDigests)
+	sigs, err := psd.Content.VerifyAll(cblob, opts.NoDigests)
 	if err != nil {
 		return nil, err
 	}
-	ts, err := pkcs9.VerifyOptionalTimestamp(sig)
-	if err != nil {
-		return nil, err
+	ret := make([]*signers.Signature, len(sigs))
+	for i, sig := range sigs {
+		ts, err := pkcs9.VerifyOptionalTimestamp(sig)
+		if err != nil {
+			return nil, err
+		}
+		hash, _ := x509tools.PkixDigestToHash(ts.SignerInfo.DigestAlgorithm)
+		ret[i] = &signers.Signature{
+			Hash:          hash,
+			X509Signature: &ts,
+		}
+		if len(sigs) > 1 {
+			// tell the signers apart by their digest
+			ret[i].SigInfo = x509tools.HashShortName(hash)
+		}
 	}
-	hash, _ := x509tools.PkixDigestToHash(ts.SignerInfo.DigestAlgorithm)
-	return []*signers.Signature{&signers.Signature{
-		Hash:          hash,
-		X509Signature: &ts,
-	}}, nil
+	return ret, nil
 }
@@ -16,7 +16,8 @@
 
 package ps
 
-// Sign Microsoft PowerShell scripts, modules, and other bits that can be signed
+// Sign Microsoft PowerShell scripts, modules, and other bits that can be
+// signed, as well as JScript and VBScript files run by Windows Script Host
 
 import (
 	"errors"
@@ -39,10 +40,13 @@ var PsSigner = &signers.Signer{
 	Transform: transform,
 	Sign:      sign,
 	Verify:    verify,
+	Resign:    signers.ResignReplace,
+
+	KeyUsages: signers.CodeSigningKeyUsages,
 }
 
 func init() {
-	PsSigner.Flags().String("ps-style", "", "(Powershell) signature type")
+	PsSigner.Flags().String("ps-style", "", "(Powershell) signature type, given as the file extension e.g. .ps1 or .js")
 	pecoff.AddOpusFlags(PsSigner)
 	signers.Register(PsSigner)
 }
@@ -102,8 +106,11 @@ func verify(f *os.File, opts signers.VerifyOpts) ([]*signers.Signature, error) {
 
 func getStyle(name string) (authenticode.PsSigStyle, error) {
 	style, ok := authenticode.GetSigStyle(name)
-	if !ok {
-		return 0, errors.New("unknown powershell style, expected: " + strings.Join(authenticode.AllSigStyles(), " "))
+	if !ok && strings.EqualFold(filepath.Ext(name), ".wsf") {
+		// signed in a <signature> element with a SIP of its own
+		return 0, errors.New("windows script files (.wsf) are not supported")
+	} else if !ok {
+		return 0, errors.New("unknown script style, expected: " + strings.Join(authenticode.AllSigStyles(), " "))
 	}
 	return style, nil
 }
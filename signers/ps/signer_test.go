@@ -0,0 +1,63 @@
+package ps
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mind-security/relic/v8/internal/signertest"
+	"github.com/mind-security/relic/v8/signers"
+)
+
+func toUtf16(s string) []byte {
+	blob := []byte{0xff, 0xfe}
+	for _, c := range utf16.Encode([]rune(s)) {
+		blob = append(blob, byte(c), byte(c>>8))
+	}
+	return blob
+}
+
+func TestSignPowershell(t *testing.T) {
+	cert := signertest.LoadCert(t, false)
+	dir := t.TempDir()
+	scripts := map[string][]byte{
+		"hello.ps1":     []byte("echo hello world\n"),
+		"module.psm1":   toUtf16("function Get-Hello {\r\n  'hello'\r\n}\r\n"),
+		"format.ps1xml": []byte("<?xml version=\"1.0\"?>\r\n<Configuration />\r\n"),
+		"hello.js":      []byte("WScript.Echo(\"hello world\");\r\n"),
+		"hello.vbs":     []byte("WScript.Echo \"hello world\"\r\n"),
+	}
+	for name, contents := range scripts {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			require.NoError(t, os.WriteFile(path, contents, 0644))
+			_, err := signertest.Verify(t, PsSigner, path, signers.VerifyOpts{})
+			assert.Error(t, err)
+			signed, err := os.ReadFile(signertest.RoundTrip(t, PsSigner, path, cert, len(contents)/2))
+			require.NoError(t, err)
+			if !strings.HasSuffix(name, ".psm1") {
+				assert.Contains(t, string(signed), "Begin signature block")
+			}
+			switch filepath.Ext(name) {
+			case ".js":
+				assert.Contains(t, string(signed), "\r\n// SIG // Begin signature block\r\n// SIG // MII")
+				assert.Regexp(t, "\r\n// SIG // [A-Za-z0-9+/]{44}\r\n", string(signed))
+			case ".vbs":
+				assert.Contains(t, string(signed), "\r\n'' SIG '' Begin signature block\r\n'' SIG '' MII")
+				assert.Regexp(t, "\r\n'' SIG '' [A-Za-z0-9+/]{44}\r\n", string(signed))
+			}
+		})
+	}
+}
+
+func TestGetStyleWSF(t *testing.T) {
+	_, err := getStyle("job.wsf")
+	assert.ErrorContains(t, err, ".wsf")
+	_, err = getStyle("hello.txt")
+	assert.ErrorContains(t, err, "unknown script style")
+}
@@ -0,0 +1,307 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package rpm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	rpmutils "github.com/sassoftware/go-rpmutils"
+)
+
+const (
+	headerMagic = 0x8eade801
+	leadSize    = 96
+
+	tagHeaderSignatures = 62
+	tagHeaderImmutable  = 63
+	tagHeaderRegions    = 100
+
+	// signature header tags, relative to the start of the signature header
+	sigDSA       = 267
+	sigRSA       = 268
+	sigSHA1      = 269
+	sigLongSize  = 270
+	sigSHA256    = 273
+	sigSHA3_256  = 274
+	sigOpenPGP   = 278
+	sigSize      = 1000
+	sigPGP       = 1002
+	sigMD5       = 1004
+	sigGPG       = 1005
+	sigReserved  = 1008
+	tagFileModes = 1030
+
+	tagFileSignatures      = 5090
+	tagFileSignatureLength = 5091
+)
+
+type headerIntro struct {
+	Magic, Reserved, Entries, Size uint32
+}
+
+type headerTag struct {
+	Tag, DataType, Offset, Count int32
+}
+
+type headerEntry struct {
+	dataType, count int32
+	contents        []byte
+}
+
+// rpmHeader is a parsed signature or general header that can be modified and
+// written back out. Existing regions are discarded and a single region
+// covering every tag is written in their place.
+type rpmHeader struct {
+	entries map[int]headerEntry
+	orig    []byte
+}
+
+var typeAlign = map[int32]int{
+	rpmutils.RPM_INT16_TYPE: 2,
+	rpmutils.RPM_INT32_TYPE: 4,
+	rpmutils.RPM_INT64_TYPE: 8,
+}
+
+var typeSizes = map[int32]int{
+	rpmutils.RPM_NULL_TYPE:  0,
+	rpmutils.RPM_CHAR_TYPE:  1,
+	rpmutils.RPM_INT8_TYPE:  1,
+	rpmutils.RPM_INT16_TYPE: 2,
+	rpmutils.RPM_INT32_TYPE: 4,
+	rpmutils.RPM_INT64_TYPE: 8,
+	rpmutils.RPM_BIN_TYPE:   1,
+}
+
+// readHeader reads one header from the stream. The signature header is padded
+// to a multiple of 8 bytes.
+func readHeader(r io.Reader, sigBlock bool) (*rpmHeader, error) {
+	var orig bytes.Buffer
+	r = io.TeeReader(r, &orig)
+	var intro headerIntro
+	if err := binary.Read(r, binary.BigEndian, &intro); err != nil {
+		return nil, fmt.Errorf("reading RPM header: %w", err)
+	}
+	if intro.Magic != headerMagic {
+		return nil, errors.New("bad magic for RPM header")
+	}
+	if intro.Entries > 1<<16 || intro.Size > 256<<20 {
+		return nil, errors.New("RPM header is too large")
+	}
+	table := make([]byte, 16*intro.Entries)
+	if _, err := io.ReadFull(r, table); err != nil {
+		return nil, fmt.Errorf("reading RPM header: %w", err)
+	}
+	size := intro.Size
+	if sigBlock {
+		size = (size + 7) / 8 * 8
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, fmt.Errorf("reading RPM header: %w", err)
+	}
+	hdr := &rpmHeader{entries: make(map[int]headerEntry), orig: orig.Bytes()}
+	tr := bytes.NewReader(table)
+	for i := 0; i < int(intro.Entries); i++ {
+		var tag headerTag
+		if err := binary.Read(tr, binary.BigEndian, &tag); err != nil {
+			return nil, err
+		}
+		if tag.Offset < 0 || int(tag.Offset) > len(data) || tag.Count < 0 {
+			return nil, fmt.Errorf("RPM header tag %d is out of bounds", tag.Tag)
+		}
+		end := int(tag.Offset)
+		if typeSize, ok := typeSizes[tag.DataType]; ok {
+			end += typeSize * int(tag.Count)
+		} else {
+			// string types are null-terminated
+			for j := 0; j < int(tag.Count); j++ {
+				next := bytes.IndexByte(data[end:], 0)
+				if next < 0 {
+					return nil, fmt.Errorf("RPM header tag %d is truncated", tag.Tag)
+				}
+				end += next + 1
+			}
+		}
+		if end > len(data) {
+			return nil, fmt.Errorf("RPM header tag %d is truncated", tag.Tag)
+		}
+		hdr.entries[int(tag.Tag)] = headerEntry{
+			dataType: tag.DataType,
+			count:    tag.Count,
+			contents: data[tag.Offset:end],
+		}
+	}
+	return hdr, nil
+}
+
+func (hdr *rpmHeader) has(tag int) bool {
+	_, ok := hdr.entries[tag]
+	return ok
+}
+
+func (hdr *rpmHeader) getStrings(tag int) []string {
+	e, ok := hdr.entries[tag]
+	if !ok || (e.dataType != rpmutils.RPM_STRING_TYPE && e.dataType != rpmutils.RPM_STRING_ARRAY_TYPE) {
+		return nil
+	}
+	values := bytes.Split(e.contents, []byte{0})
+	ret := make([]string, 0, len(values))
+	for _, v := range values[:len(values)-1] {
+		ret = append(ret, string(v))
+	}
+	return ret
+}
+
+func (hdr *rpmHeader) getInts(tag int) []int {
+	e, ok := hdr.entries[tag]
+	if !ok {
+		return nil
+	}
+	ret := make([]int, e.count)
+	for i := range ret {
+		switch e.dataType {
+		case rpmutils.RPM_INT16_TYPE:
+			ret[i] = int(binary.BigEndian.Uint16(e.contents[2*i:]))
+		case rpmutils.RPM_INT32_TYPE:
+			ret[i] = int(binary.BigEndian.Uint32(e.contents[4*i:]))
+		default:
+			return nil
+		}
+	}
+	return ret
+}
+
+func (hdr *rpmHeader) setBytes(tag int, value []byte) {
+	hdr.entries[tag] = headerEntry{
+		dataType: rpmutils.RPM_BIN_TYPE,
+		count:    int32(len(value)),
+		contents: value,
+	}
+}
+
+func (hdr *rpmHeader) setString(tag int, value string) {
+	hdr.entries[tag] = headerEntry{
+		dataType: rpmutils.RPM_STRING_TYPE,
+		count:    1,
+		contents: append([]byte(value), 0),
+	}
+}
+
+func (hdr *rpmHeader) setStrings(tag int, values []string) {
+	var buf bytes.Buffer
+	for _, v := range values {
+		buf.WriteString(v)
+		buf.WriteByte(0)
+	}
+	hdr.entries[tag] = headerEntry{
+		dataType: rpmutils.RPM_STRING_ARRAY_TYPE,
+		count:    int32(len(values)),
+		contents: buf.Bytes(),
+	}
+}
+
+func (hdr *rpmHeader) setInt32(tag int, value uint32) {
+	hdr.entries[tag] = headerEntry{
+		dataType: rpmutils.RPM_INT32_TYPE,
+		count:    1,
+		contents: binary.BigEndian.AppendUint32(nil, value),
+	}
+}
+
+func (hdr *rpmHeader) setInt64(tag int, value uint64) {
+	hdr.entries[tag] = headerEntry{
+		dataType: rpmutils.RPM_INT64_TYPE,
+		count:    1,
+		contents: binary.BigEndian.AppendUint64(nil, value),
+	}
+}
+
+// dump serializes the header with tags in ascending order, preceded by a
+// region tag that spans all of them
+func (hdr *rpmHeader) dump(regionTag int) []byte {
+	var keys []int
+	for k := range hdr.entries {
+		if k >= tagHeaderRegions {
+			keys = append(keys, k)
+		}
+	}
+	sort.Ints(keys)
+	var entries, blobs bytes.Buffer
+	writeTag := func(tag int, e headerEntry) {
+		if align := typeAlign[e.dataType]; align != 0 {
+			if n := blobs.Len() % align; n != 0 {
+				blobs.Write(make([]byte, align-n))
+			}
+		}
+		_ = binary.Write(&entries, binary.BigEndian, headerTag{
+			Tag:      int32(tag),
+			DataType: e.dataType,
+			Offset:   int32(blobs.Len()),
+			Count:    e.count,
+		})
+		blobs.Write(e.contents)
+	}
+	for _, k := range keys {
+		writeTag(k, hdr.entries[k])
+	}
+	// the region's value is itself a tag, with an offset pointing back to the
+	// start of the index
+	var trailer bytes.Buffer
+	_ = binary.Write(&trailer, binary.BigEndian, headerTag{
+		Tag:      int32(regionTag),
+		DataType: rpmutils.RPM_BIN_TYPE,
+		Offset:   int32(-16 * (1 + len(keys))),
+		Count:    16,
+	})
+	tableSize := entries.Len()
+	writeTag(regionTag, headerEntry{dataType: rpmutils.RPM_BIN_TYPE, count: 16, contents: trailer.Bytes()})
+	// move the region tag to the front of the index
+	table := entries.Bytes()
+	table = append(table[tableSize:], table[:tableSize]...)
+
+	var out bytes.Buffer
+	_ = binary.Write(&out, binary.BigEndian, headerIntro{
+		Magic:   headerMagic,
+		Entries: uint32(len(keys) + 1),
+		Size:    uint32(blobs.Len()),
+	})
+	out.Write(table)
+	out.Write(blobs.Bytes())
+	if regionTag == tagHeaderSignatures {
+		if n := out.Len() % 8; n != 0 {
+			out.Write(make([]byte, 8-n))
+		}
+	}
+	return out.Bytes()
+}
+
+// dumpSameSize serializes the signature header, padding it with a reserved
+// space tag so it stays the same size as the original where possible
+func (hdr *rpmHeader) dumpSameSize() []byte {
+	delete(hdr.entries, sigReserved)
+	blob := hdr.dump(tagHeaderSignatures)
+	if available := len(hdr.orig); len(blob)+16 <= available {
+		hdr.setBytes(sigReserved, make([]byte, available-len(blob)-16))
+		blob = hdr.dump(tagHeaderSignatures)
+	}
+	return blob
+}
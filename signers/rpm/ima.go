@@ -0,0 +1,192 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package rpm
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	rpmutils "github.com/sassoftware/go-rpmutils"
+
+	"github.com/mind-security/relic/v8/lib/certloader"
+	"github.com/mind-security/relic/v8/lib/x509tools"
+)
+
+// IMA extended attribute type for a digital signature (EVM_IMA_XATTR_DIGSIG)
+// and the version of the signature header that follows it
+const (
+	imaXattrDigsig = 3
+	imaDigsigV2    = 2
+)
+
+// kernel hash_algo identifiers (include/uapi/linux/hash_info.h)
+var imaHashAlgos = map[crypto.Hash]byte{
+	crypto.SHA1:   2,
+	crypto.SHA256: 4,
+	crypto.SHA384: 5,
+	crypto.SHA512: 6,
+	crypto.SHA224: 7,
+}
+
+// RPM file digest algorithms (PGPHASHALGO_*)
+var fileDigestAlgos = map[int]crypto.Hash{
+	rpmutils.PGPHASHALGO_MD5:    crypto.MD5,
+	rpmutils.PGPHASHALGO_SHA1:   crypto.SHA1,
+	rpmutils.PGPHASHALGO_SHA256: crypto.SHA256,
+	rpmutils.PGPHASHALGO_SHA384: crypto.SHA384,
+	rpmutils.PGPHASHALGO_SHA512: crypto.SHA512,
+	rpmutils.PGPHASHALGO_SHA224: crypto.SHA224,
+}
+
+// fileDigestHash returns the algorithm of the per-file digests in the header
+func fileDigestHash(genHeader *rpmHeader) (crypto.Hash, error) {
+	algo := rpmutils.PGPHASHALGO_MD5
+	if algos := genHeader.getInts(rpmutils.FILEDIGESTALGO); len(algos) != 0 {
+		algo = algos[0]
+	}
+	hash := fileDigestAlgos[algo]
+	if hash == 0 {
+		return 0, fmt.Errorf("unsupported file digest algorithm %d", algo)
+	}
+	return hash, nil
+}
+
+// imaKeyID returns the identifier the kernel uses to find the key that
+// verifies a signature: the low 32 bits of its subject key identifier
+func imaKeyID(cert *certloader.Certificate) ([]byte, error) {
+	if cert.Leaf != nil && len(cert.Leaf.SubjectKeyId) >= 4 {
+		return cert.Leaf.SubjectKeyId[len(cert.Leaf.SubjectKeyId)-4:], nil
+	}
+	ski, err := x509tools.SubjectKeyID(cert.Signer().Public())
+	if err != nil {
+		return nil, err
+	}
+	return ski[len(ski)-4:], nil
+}
+
+// signFiles adds an IMA signature for each regular file to the general header,
+// in the form that is written to the security.ima extended attribute
+func signFiles(genHeader *rpmHeader, cert *certloader.Certificate) (int, error) {
+	hash, err := fileDigestHash(genHeader)
+	if err != nil {
+		return 0, err
+	}
+	if x509tools.IsDeprecatedHash(hash) || imaHashAlgos[hash] == 0 {
+		return 0, fmt.Errorf("file digests use %s, which can't be used for IMA signatures", hash)
+	}
+	keyID, err := imaKeyID(cert)
+	if err != nil {
+		return 0, err
+	}
+	digests := genHeader.getStrings(rpmutils.FILEDIGESTS)
+	modes := genHeader.getInts(tagFileModes)
+	if len(modes) != len(digests) {
+		return 0, errors.New("RPM file digests and modes don't match")
+	}
+	signer := cert.Signer()
+	sigs := make([]string, len(digests))
+	var count, maxLen int
+	for i, digest := range digests {
+		if modes[i]&0170000 != 0100000 || digest == "" {
+			// only regular files are signed
+			continue
+		}
+		d, err := hex.DecodeString(digest)
+		if err != nil || len(d) != hash.Size() {
+			return 0, fmt.Errorf("invalid file digest %q", digest)
+		}
+		sig, err := signer.Sign(rand.Reader, d, hash)
+		if err != nil {
+			return 0, err
+		}
+		blob := make([]byte, 0, 9+len(sig))
+		blob = append(blob, imaXattrDigsig, imaDigsigV2, imaHashAlgos[hash])
+		blob = append(blob, keyID...)
+		blob = binary.BigEndian.AppendUint16(blob, uint16(len(sig)))
+		blob = append(blob, sig...)
+		sigs[i] = hex.EncodeToString(blob)
+		maxLen = max(maxLen, len(blob))
+		count++
+	}
+	if count == 0 {
+		return 0, nil
+	}
+	genHeader.setStrings(tagFileSignatures, sigs)
+	genHeader.setInt32(tagFileSignatureLength, uint32(maxLen))
+	return count, nil
+}
+
+// verifyFiles checks the IMA signatures in the general header against the
+// given certificates, returning the number of files that are signed
+func verifyFiles(genHeader *rpmHeader, certs []*x509.Certificate) (int, error) {
+	sigs := genHeader.getStrings(tagFileSignatures)
+	digests := genHeader.getStrings(rpmutils.FILEDIGESTS)
+	if len(sigs) != len(digests) {
+		return 0, errors.New("RPM file signatures and digests don't match")
+	}
+	hash, err := fileDigestHash(genHeader)
+	if err != nil {
+		return 0, err
+	}
+	var count int
+	for i, sig := range sigs {
+		if sig == "" {
+			continue
+		}
+		count++
+		if len(certs) == 0 {
+			continue
+		}
+		blob, err := hex.DecodeString(sig)
+		if err != nil || len(blob) < 9 || blob[0] != imaXattrDigsig || blob[1] != imaDigsigV2 {
+			return 0, fmt.Errorf("file %d: malformed IMA signature", i)
+		}
+		if blob[2] != imaHashAlgos[hash] {
+			return 0, fmt.Errorf("file %d: IMA signature uses the wrong digest algorithm", i)
+		}
+		if int(binary.BigEndian.Uint16(blob[7:9])) != len(blob)-9 {
+			return 0, fmt.Errorf("file %d: malformed IMA signature", i)
+		}
+		digest, err := hex.DecodeString(digests[i])
+		if err != nil {
+			return 0, fmt.Errorf("file %d: invalid file digest", i)
+		}
+		if err := verifyFile(certs, blob[3:7], hash, digest, blob[9:]); err != nil {
+			return 0, fmt.Errorf("file %d: %w", i, err)
+		}
+	}
+	return count, nil
+}
+
+func verifyFile(certs []*x509.Certificate, keyID []byte, hash crypto.Hash, digest, sig []byte) error {
+	for _, cert := range certs {
+		ski := cert.SubjectKeyId
+		if len(ski) < 4 {
+			ski, _ = x509tools.SubjectKeyID(cert.PublicKey)
+		}
+		if len(ski) < 4 || string(ski[len(ski)-4:]) != string(keyID) {
+			continue
+		}
+		return x509tools.Verify(cert.PublicKey, hash, digest, sig)
+	}
+	return fmt.Errorf("no certificate found for IMA key %x", keyID)
+}
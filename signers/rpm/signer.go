@@ -19,15 +19,26 @@ package rpm
 // Sign RedHat packages
 
 import (
+	"bytes"
+	"crypto"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"os"
 	"strings"
 	"time"
 
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
 	"github.com/rs/zerolog"
 	rpmutils "github.com/sassoftware/go-rpmutils"
+	"golang.org/x/crypto/sha3"
 
 	"github.com/mind-security/relic/v8/lib/audit"
 	"github.com/mind-security/relic/v8/lib/binpatch"
@@ -45,9 +56,14 @@ var RpmSigner = &signers.Signer{
 	FormatLog: formatLog,
 	Sign:      sign,
 	Verify:    verify,
+	Resign:    signers.ResignReplace,
+
+	NeedsSigningTime: true,
 }
 
 func init() {
+	RpmSigner.Flags().String("rpm-style", "", "(RPM) Signature style: classic (header and payload), v4 or v6 (header only)")
+	RpmSigner.Flags().Bool("rpm-ima", false, "(RPM) Add an IMA signature for each file")
 	signers.Register(RpmSigner)
 }
 
@@ -55,29 +71,207 @@ func formatLog(attrs *audit.Info) *zerolog.Event {
 	return attrs.AttrsForLog("rpm.")
 }
 
+// Signature styles
+const (
+	// StyleClassic signs both the header and the header plus payload
+	StyleClassic = "classic"
+	// StyleV4 signs only the header, as rpm 4.16 and later do by default
+	StyleV4 = "v4"
+	// StyleV6 signs only the header and also stores the signature in the
+	// OpenPGP tag introduced in rpm 6
+	StyleV6 = "v6"
+)
+
 func sign(r io.Reader, cert *certloader.Certificate, opts signers.SignOpts) ([]byte, error) {
-	config := &rpmutils.SignatureOptions{
-		Hash:         opts.Hash,
-		CreationTime: opts.Time.UTC().Round(time.Second),
+	style := opts.Flags.GetString("rpm-style")
+	switch style {
+	case "":
+		style = StyleClassic
+	case StyleClassic, StyleV4, StyleV6:
+	default:
+		return nil, fmt.Errorf("unknown RPM signature style %q", style)
+	}
+	lead := make([]byte, leadSize)
+	if _, err := io.ReadFull(r, lead); err != nil {
+		return nil, err
+	} else if binary.BigEndian.Uint32(lead) != 0xedabeedb {
+		return nil, errors.New("file is not an RPM")
 	}
-	header, err := rpmutils.SignRpmStream(r, cert.PgpKey.PrivateKey, config)
+	sigHeader, err := readHeader(r, true)
 	if err != nil {
 		return nil, err
 	}
-	blob, err := header.DumpSignatureHeader(true)
+	genHeader, err := readHeader(r, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkHeaderDigest(sigHeader, genHeader.orig); err != nil {
+		return nil, err
+	}
+	// file signatures go into the general header, so it has to be rewritten
+	// and all of the digests over it updated
+	genBlob := genHeader.orig
+	var fileSigs int
+	if opts.Flags.GetBool("rpm-ima") {
+		fileSigs, err = signFiles(genHeader, cert)
+		if err != nil {
+			return nil, fmt.Errorf("IMA: %w", err)
+		}
+		if fileSigs != 0 {
+			genBlob = genHeader.dump(tagHeaderImmutable)
+		}
+	}
+	payloadSize, md5sum, combined, err := digestPayload(r, genHeader, sigHeader, genBlob, opts.Hash)
+	if err != nil {
+		return nil, err
+	}
+	sha1sum := sha1.Sum(genBlob)
+	if sigHeader.has(sigSHA1) {
+		sigHeader.setString(sigSHA1, hex.EncodeToString(sha1sum[:]))
+	}
+	if sigHeader.has(sigSHA256) {
+		d := sha256.Sum256(genBlob)
+		sigHeader.setString(sigSHA256, hex.EncodeToString(d[:]))
+	}
+	if sigHeader.has(sigSHA3_256) {
+		d := sha3.Sum256(genBlob)
+		sigHeader.setString(sigSHA3_256, hex.EncodeToString(d[:]))
+	}
+	if sigHeader.has(sigMD5) {
+		sigHeader.setBytes(sigMD5, md5sum)
+	}
+	totalSize := uint64(len(genBlob)) + uint64(payloadSize)
+	if sigHeader.has(sigSize) {
+		sigHeader.setInt32(sigSize, uint32(totalSize))
+	}
+	if sigHeader.has(sigLongSize) {
+		sigHeader.setInt64(sigLongSize, totalSize)
+	}
+	// sign
+	key := pgptools.SigningKey(cert.PgpKey, 0)
+	if key == nil {
+		return nil, errors.New("no PGP signing key available")
+	}
+	creationTime := opts.Time.UTC().Round(time.Second)
+	d := opts.Hash.New()
+	d.Write(genBlob)
+	headerSig, err := makeSignature(d, key, opts.Hash, creationTime)
 	if err != nil {
 		return nil, err
 	}
+	for _, tag := range []int{sigDSA, sigPGP, sigGPG, sigOpenPGP} {
+		delete(sigHeader.entries, tag)
+	}
+	sigHeader.setBytes(sigRSA, headerSig)
+	switch style {
+	case StyleClassic:
+		payloadSig, err := makeSignature(combined, key, opts.Hash, creationTime)
+		if err != nil {
+			return nil, err
+		}
+		sigHeader.setBytes(sigPGP, payloadSig)
+	case StyleV6:
+		sigHeader.setStrings(sigOpenPGP, []string{base64.StdEncoding.EncodeToString(headerSig)})
+	}
+	sigBlob := sigHeader.dumpSameSize()
+	// parse the result to make sure it's sane
+	header, err := rpmutils.ReadHeader(io.MultiReader(bytes.NewReader(lead), bytes.NewReader(sigBlob), bytes.NewReader(genBlob)))
+	if err != nil {
+		return nil, fmt.Errorf("checking signed RPM header: %w", err)
+	}
+	blob := append(lead, sigBlob...)
+	replaced := len(lead) + len(sigHeader.orig)
+	if fileSigs != 0 {
+		blob = append(blob, genBlob...)
+		replaced += len(genHeader.orig)
+	}
 	patch := binpatch.New()
-	patch.Add(0, int64(header.OriginalSignatureHeaderSize()), blob)
-	md5, _ := header.GetBytes(rpmutils.SIG_MD5)
-	sha1, _ := header.GetString(rpmutils.SIG_SHA1)
+	patch.Add(0, int64(replaced), blob)
 	opts.Audit.Attributes["rpm.nevra"] = nevra(header)
-	opts.Audit.Attributes["rpm.md5"] = hex.EncodeToString(md5)
-	opts.Audit.Attributes["rpm.sha1"] = sha1
+	opts.Audit.Attributes["rpm.md5"] = hex.EncodeToString(md5sum)
+	opts.Audit.Attributes["rpm.sha1"] = hex.EncodeToString(sha1sum[:])
+	opts.Audit.Attributes["rpm.style"] = style
+	if fileSigs != 0 {
+		opts.Audit.Attributes["rpm.filesigs"] = fileSigs
+	}
 	return opts.SetBinPatch(patch)
 }
 
+// checkHeaderDigest validates the general header against the digests in the
+// signature header
+func checkHeaderDigest(sigHeader *rpmHeader, genBlob []byte) error {
+	var expected, calculated string
+	if v := sigHeader.getStrings(sigSHA256); len(v) != 0 {
+		d := sha256.Sum256(genBlob)
+		expected, calculated = v[0], hex.EncodeToString(d[:])
+	} else if v := sigHeader.getStrings(sigSHA1); len(v) != 0 {
+		d := sha1.Sum(genBlob)
+		expected, calculated = v[0], hex.EncodeToString(d[:])
+	} else {
+		return nil
+	}
+	if expected != calculated {
+		return errors.New("RPM header digest mismatch")
+	}
+	return nil
+}
+
+// digestPayload reads the payload, checking its integrity and computing the
+// MD5 and signature digests over the new header and the payload
+func digestPayload(r io.Reader, genHeader, sigHeader *rpmHeader, genBlob []byte, sigHash crypto.Hash) (int64, []byte, hash.Hash, error) {
+	md5sum := md5.New()
+	combined := sigHash.New()
+	md5sum.Write(genBlob)
+	combined.Write(genBlob)
+	writers := []io.Writer{md5sum, combined}
+	var check hash.Hash
+	var expected []byte
+	payloadDigest := genHeader.getStrings(rpmutils.PAYLOADDIGEST)
+	payloadAlgo := genHeader.getInts(rpmutils.PAYLOADDIGESTALGO)
+	if len(payloadDigest) != 0 && len(payloadAlgo) != 0 {
+		h := fileDigestAlgos[payloadAlgo[0]]
+		if h == 0 || !h.Available() {
+			return 0, nil, nil, fmt.Errorf("unknown payload digest algorithm %d", payloadAlgo[0])
+		}
+		check = h.New()
+		expected, _ = hex.DecodeString(payloadDigest[0])
+	} else if orig, ok := sigHeader.entries[sigMD5]; ok {
+		// legacy MD5 over the original header and payload is the only digest
+		check = md5.New()
+		check.Write(genHeader.orig)
+		expected = orig.contents
+	} else {
+		return 0, nil, nil, errors.New("no usable payload digest found")
+	}
+	writers = append(writers, check)
+	n, err := io.Copy(io.MultiWriter(writers...), r)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	if !bytes.Equal(check.Sum(nil), expected) {
+		return 0, nil, nil, errors.New("RPM payload digest mismatch")
+	}
+	return n, md5sum.Sum(nil), combined, nil
+}
+
+func makeSignature(h hash.Hash, key *packet.PrivateKey, sigHash crypto.Hash, creationTime time.Time) ([]byte, error) {
+	sig := &packet.Signature{
+		SigType:      packet.SigTypeBinary,
+		CreationTime: creationTime,
+		PubKeyAlgo:   key.PublicKey.PubKeyAlgo,
+		Hash:         sigHash,
+		IssuerKeyId:  &key.KeyId,
+	}
+	if err := sig.Sign(h, key, nil); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := sig.Serialize(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
 func verify(f *os.File, opts signers.VerifyOpts) ([]*signers.Signature, error) {
 	// TODO: add a flag to skip payload digest to rpmutils.Verify
 	header, sigs, err := rpmutils.Verify(f, opts.TrustedPgp)
@@ -87,6 +281,10 @@ func verify(f *os.File, opts signers.VerifyOpts) ([]*signers.Signature, error) {
 	if len(sigs) == 0 {
 		return nil, sigerrors.NotSignedError{Type: "RPM"}
 	}
+	types, err := verifyExtra(f, header, opts)
+	if err != nil {
+		return nil, err
+	}
 	var ret []*signers.Signature
 	seen := make(map[uint64]bool)
 	for _, sig := range sigs {
@@ -96,6 +294,7 @@ func verify(f *os.File, opts signers.VerifyOpts) ([]*signers.Signature, error) {
 		seen[sig.KeyId] = true
 		rsig := &signers.Signature{
 			Package:      nevra(header),
+			SigInfo:      types,
 			CreationTime: sig.CreationTime,
 			Hash:         sig.Hash,
 		}
@@ -112,6 +311,61 @@ func verify(f *os.File, opts signers.VerifyOpts) ([]*signers.Signature, error) {
 	return ret, nil
 }
 
+// verifyExtra checks the signatures that rpmutils doesn't know about, and
+// returns a description of which kinds of signature are present
+func verifyExtra(f *os.File, header *rpmutils.RpmHeader, opts signers.VerifyOpts) (string, error) {
+	var types []string
+	if header.HasTag(rpmutils.SIG_PGP) || header.HasTag(rpmutils.SIG_GPG) {
+		types = append(types, "header+payload")
+	}
+	if header.HasTag(rpmutils.SIG_RSA) || header.HasTag(rpmutils.SIG_DSA) {
+		types = append(types, "header")
+	}
+	hasOpenPGP := header.HasTag(sigOpenPGP)
+	hasFileSigs := header.HasTag(tagFileSignatures)
+	if !hasOpenPGP && !hasFileSigs {
+		return strings.Join(types, ","), nil
+	}
+	if _, err := f.Seek(leadSize, 0); err != nil {
+		return "", err
+	}
+	sigHeader, err := readHeader(f, true)
+	if err != nil {
+		return "", err
+	}
+	genHeader, err := readHeader(f, false)
+	if err != nil {
+		return "", err
+	}
+	if hasOpenPGP {
+		for _, b64 := range sigHeader.getStrings(sigOpenPGP) {
+			blob, err := base64.StdEncoding.DecodeString(b64)
+			if err != nil {
+				return "", fmt.Errorf("malformed OpenPGP signature: %w", err)
+			}
+			psig, err := pgptools.VerifyDetached(bytes.NewReader(blob), bytes.NewReader(genHeader.orig), opts.TrustedPgp)
+			if _, ok := err.(pgptools.ErrNoKey); ok && opts.NoChain {
+				err = nil
+			}
+			if err != nil {
+				if psig != nil {
+					return "", fmt.Errorf("bad OpenPGP signature from %s(%x): %w", pgptools.EntityName(psig.Key.Entity), psig.Key.PublicKey.KeyId, err)
+				}
+				return "", err
+			}
+		}
+		types = append(types, "openpgp")
+	}
+	if hasFileSigs {
+		count, err := verifyFiles(genHeader, opts.TrustedX509)
+		if err != nil {
+			return "", fmt.Errorf("IMA: %w", err)
+		}
+		types = append(types, fmt.Sprintf("ima(%d files)", count))
+	}
+	return strings.Join(types, ","), nil
+}
+
 func nevra(header *rpmutils.RpmHeader) string {
 	nevra, _ := header.GetNEVRA()
 	snevra := nevra.String()
@@ -0,0 +1,177 @@
+package rpm
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	rpmutils "github.com/sassoftware/go-rpmutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mind-security/relic/v8/internal/signertest"
+	"github.com/mind-security/relic/v8/lib/certloader"
+	"github.com/mind-security/relic/v8/lib/pgptools"
+	"github.com/mind-security/relic/v8/signers"
+)
+
+const testRPM = "../../functest/packages/rocky-basesystem-11-13.el9.noarch.rpm"
+
+var testTime = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+// withFiles derives a package from the test RPM that lists some files in its
+// header, since the test RPM has none
+func withFiles(t *testing.T) string {
+	f, err := os.Open(testRPM)
+	require.NoError(t, err)
+	defer f.Close()
+	lead := make([]byte, leadSize)
+	_, err = io.ReadFull(f, lead)
+	require.NoError(t, err)
+	sigHeader, err := readHeader(f, true)
+	require.NoError(t, err)
+	genHeader, err := readHeader(f, false)
+	require.NoError(t, err)
+	payload, err := io.ReadAll(f)
+	require.NoError(t, err)
+
+	d1 := sha256.Sum256([]byte("one"))
+	d2 := sha256.Sum256([]byte("two"))
+	genHeader.setStrings(rpmutils.FILEDIGESTS, []string{hex.EncodeToString(d1[:]), "", hex.EncodeToString(d2[:])})
+	genHeader.setInt32(rpmutils.FILEDIGESTALGO, rpmutils.PGPHASHALGO_SHA256)
+	modes := []byte{0x81, 0xa4, 0x41, 0xed, 0x81, 0xed}
+	genHeader.entries[tagFileModes] = headerEntry{dataType: rpmutils.RPM_INT16_TYPE, count: 3, contents: modes}
+	genBlob := genHeader.dump(tagHeaderImmutable)
+	d := sha256.Sum256(genBlob)
+	sigHeader.setString(sigSHA256, hex.EncodeToString(d[:]))
+	d1s := sha1.Sum(genBlob)
+	sigHeader.setString(sigSHA1, hex.EncodeToString(d1s[:]))
+	m := md5.New()
+	m.Write(genBlob)
+	m.Write(payload)
+	sigHeader.setBytes(sigMD5, m.Sum(nil))
+	sigHeader.setInt32(sigSize, uint32(len(genBlob)+len(payload)))
+
+	out := filepath.Join(t.TempDir(), "files.rpm")
+	blob := append(lead, sigHeader.dump(tagHeaderSignatures)...)
+	blob = append(blob, genBlob...)
+	blob = append(blob, payload...)
+	require.NoError(t, os.WriteFile(out, blob, 0644))
+	return out
+}
+
+func signTestRPM(t *testing.T, cert *certloader.Certificate, flags map[string]string) string {
+	return signRPM(t, testRPM, cert, flags)
+}
+
+func signRPM(t *testing.T, path string, cert *certloader.Certificate, flags map[string]string) string {
+	return signertest.SignPatch(t, RpmSigner, path, cert, signertest.Opts(RpmSigner, testTime, flags))
+}
+
+func verifyTestRPM(t *testing.T, path string, opts signers.VerifyOpts) ([]*signers.Signature, error) {
+	return signertest.Verify(t, RpmSigner, path, opts)
+}
+
+func TestSignClassic(t *testing.T) {
+	cert := signertest.LoadCert(t, true)
+	signed := signTestRPM(t, cert, map[string]string{})
+	// same result as rpmutils
+	f, err := os.Open(testRPM)
+	require.NoError(t, err)
+	defer f.Close()
+	header, err := rpmutils.SignRpmStream(f, pgptools.SigningKey(cert.PgpKey, 0), &rpmutils.SignatureOptions{Hash: crypto.SHA256, CreationTime: testTime})
+	require.NoError(t, err)
+	expected, err := header.DumpSignatureHeader(true)
+	require.NoError(t, err)
+	actual, err := os.ReadFile(signed)
+	require.NoError(t, err)
+	assert.Equal(t, expected, actual[:len(expected)])
+
+	sigs, err := verifyTestRPM(t, signed, signers.VerifyOpts{TrustedPgp: openpgp.EntityList{cert.PgpKey}})
+	require.NoError(t, err)
+	require.Len(t, sigs, 1)
+	assert.Equal(t, "header+payload,header", sigs[0].SigInfo)
+	assert.Equal(t, "basesystem-11-13.el9.noarch", sigs[0].Package)
+}
+
+func TestSignStyles(t *testing.T) {
+	cert := signertest.LoadCert(t, true)
+	vopts := signers.VerifyOpts{TrustedPgp: openpgp.EntityList{cert.PgpKey}}
+	signed := signTestRPM(t, cert, map[string]string{"rpm-style": "v4"})
+	sigs, err := verifyTestRPM(t, signed, vopts)
+	require.NoError(t, err)
+	require.Len(t, sigs, 1)
+	assert.Equal(t, "header", sigs[0].SigInfo)
+
+	signed = signTestRPM(t, cert, map[string]string{"rpm-style": "v6"})
+	sigs, err = verifyTestRPM(t, signed, vopts)
+	require.NoError(t, err)
+	require.Len(t, sigs, 1)
+	assert.Equal(t, "header,openpgp", sigs[0].SigInfo)
+	// the OpenPGP tag is checked even without a matching RSA tag
+	_, err = verifyTestRPM(t, signed, signers.VerifyOpts{NoChain: true})
+	require.NoError(t, err)
+
+	f, err := os.Open(testRPM)
+	require.NoError(t, err)
+	defer f.Close()
+	_, err = RpmSigner.Sign(f, cert, signers.SignOpts{
+		Hash:  crypto.SHA256,
+		Flags: &signers.FlagValues{Defs: RpmSigner.Flags(), Values: map[string]string{"rpm-style": "v5"}},
+	})
+	assert.ErrorContains(t, err, "unknown RPM signature style")
+}
+
+func TestSignIMA(t *testing.T) {
+	cert := signertest.LoadCert(t, true)
+	signed := signRPM(t, withFiles(t), cert, map[string]string{"rpm-style": "v4", "rpm-ima": "true"})
+	vopts := signers.VerifyOpts{
+		TrustedPgp:  openpgp.EntityList{cert.PgpKey},
+		TrustedX509: []*x509.Certificate{cert.Leaf},
+	}
+	sigs, err := verifyTestRPM(t, signed, vopts)
+	require.NoError(t, err)
+	require.Len(t, sigs, 1)
+	assert.Equal(t, "header,ima(2 files)", sigs[0].SigInfo)
+
+	// check the xattr layout of the first signature
+	f, err := os.Open(signed)
+	require.NoError(t, err)
+	defer f.Close()
+	header, err := rpmutils.ReadHeader(f)
+	require.NoError(t, err)
+	fileSigs, err := header.GetStrings(tagFileSignatures)
+	require.NoError(t, err)
+	var blob []byte
+	for _, sig := range fileSigs {
+		if sig != "" {
+			blob, err = hex.DecodeString(sig)
+			require.NoError(t, err)
+			break
+		}
+	}
+	require.NotEmpty(t, blob)
+	assert.Equal(t, "", fileSigs[1], "directories are not signed")
+	ski := cert.Leaf.SubjectKeyId
+	assert.Equal(t, []byte{imaXattrDigsig, imaDigsigV2, 4}, blob[:3])
+	assert.Equal(t, ski[len(ski)-4:], blob[3:7])
+	assert.Equal(t, []byte{1, 0}, blob[7:9])
+	assert.Len(t, blob, 9+256)
+
+	// a different certificate can't verify the file signatures
+	other := *cert.Leaf
+	other.SubjectKeyId = bytes.Repeat([]byte{1}, 20)
+	vopts.TrustedX509 = []*x509.Certificate{&other}
+	_, err = verifyTestRPM(t, signed, vopts)
+	assert.ErrorContains(t, err, "no certificate found for IMA key")
+}
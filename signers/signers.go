@@ -18,10 +18,12 @@ package signers
 
 import (
 	"crypto"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"io"
 	"os"
+	"sort"
 	"time"
 
 	"github.com/rs/zerolog"
@@ -45,6 +47,14 @@ type Signer struct {
 	Magic      magic.FileType
 	CertTypes  CertType
 	AllowStdin bool
+	// The format always records a signing time, so it can't be omitted
+	NeedsSigningTime bool
+	// Extended key usages that make a X509 certificate suitable for the
+	// format. If empty, DefaultKeyUsages is used.
+	KeyUsages []x509.ExtKeyUsage
+	// Digests the format can sign with, weakest first. If set, the default
+	// digest chosen for a key is limited to these.
+	Hashes []crypto.Hash
 	// Return true if the given filename is associated with this signer
 	TestPath func(string) bool
 	// Format audit attributes for logfile
@@ -60,10 +70,61 @@ type Signer struct {
 	Sign func(io.Reader, *certloader.Certificate, SignOpts) ([]byte, error)
 	// Final step to run on the client after the file is patched
 	Fixup func(*os.File) error
+	// Return the usual filename suffix for the output if these options make a
+	// detached signature instead of signing the input in place
+	DetachedSuffix func(*FlagValues) string
+	// Check the start of a (possibly transformed) input stream so that the
+	// server can reject garbage before signing. At most InputCheckSize bytes
+	// are passed, fewer if the stream is shorter.
+	CheckInput func(head []byte) error
+	// What happens to signatures already present in the input when it is
+	// signed in place
+	Resign ResignMode
 
 	flags *pflag.FlagSet
 }
 
+// SupportedHash returns hash if the format supports it, otherwise the weakest
+// supported digest that is stronger, or failing that the strongest one
+func (s *Signer) SupportedHash(hash crypto.Hash) crypto.Hash {
+	if len(s.Hashes) == 0 {
+		return hash
+	}
+	for _, h := range s.Hashes {
+		if h.Size() >= hash.Size() {
+			return h
+		}
+	}
+	return s.Hashes[len(s.Hashes)-1]
+}
+
+var (
+	// DefaultKeyUsages are accepted for formats that don't set their own
+	DefaultKeyUsages = []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning, x509.ExtKeyUsageEmailProtection}
+	// CodeSigningKeyUsages are accepted for Authenticode formats
+	CodeSigningKeyUsages = []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning}
+)
+
+// ResignMode describes how a format treats existing signatures when a file
+// is signed again
+type ResignMode int
+
+const (
+	// ResignNone is for formats that always make a new file or detached
+	// signature, so the input is never checked for existing signatures
+	ResignNone ResignMode = iota
+	// ResignReplace formats discard existing signatures, along with any
+	// timestamps countersigning them, and write only the new one
+	ResignReplace
+	// ResignAppend formats can hold several signatures. The new one is added
+	// alongside the others, or replaces one made in the same slot (e.g. the
+	// same DEB role or TUF key ID). Use --replace to discard all of them.
+	ResignAppend
+)
+
+// InputCheckSize is the most bytes of input passed to CheckInput
+const InputCheckSize = 4096
+
 type CertType uint
 
 const (
@@ -101,6 +162,19 @@ func Register(s *Signer) {
 	registered = append(registered, s)
 }
 
+// Names returns the sorted names of the registered signer modules that can
+// sign
+func Names() []string {
+	var names []string
+	for _, s := range registered {
+		if s.Sign != nil {
+			names = append(names, s.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
 // Return the signer module with the given name or alias
 func ByName(name string) *Signer {
 	for _, s := range registered {
@@ -150,7 +224,7 @@ func ByFile(name, sigtype string) (*Signer, error) {
 		return mod, nil
 	}
 	if name == "-" {
-		return nil, errors.New("reading from standard input is not supported")
+		return nil, errors.New("--sig-type is required when reading from standard input")
 	}
 	f, err := os.Open(name)
 	if err != nil {
@@ -158,11 +232,11 @@ func ByFile(name, sigtype string) (*Signer, error) {
 	}
 	defer f.Close()
 	fileType, compressionType := magic.DetectCompressed(f)
-	if compressionType != magic.CompressedNone {
-		return nil, errors.New("cannot sign compressed file")
-	}
 	if mod := ByMagic(fileType); mod != nil {
+		// including compressed formats like Helm charts
 		return mod, nil
+	} else if compressionType != magic.CompressedNone {
+		return nil, errors.New("cannot sign compressed file")
 	} else if mod := ByFileName(name); mod != nil {
 		return mod, nil
 	}
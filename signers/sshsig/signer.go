@@ -0,0 +1,115 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package sshsig
+
+// Sign arbitrary files with a detached OpenSSH signature, as made by
+// "ssh-keygen -Y sign"
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/mind-security/relic/v8/lib/certloader"
+	"github.com/mind-security/relic/v8/lib/magic"
+	"github.com/mind-security/relic/v8/lib/sshsig"
+	"github.com/mind-security/relic/v8/signers"
+)
+
+var SSHSigner = &signers.Signer{
+	Name:         "ssh",
+	Aliases:      []string{"sshsig"},
+	Magic:        magic.FileTypeSSHSig,
+	AllowStdin:   true,
+	Hashes:       []crypto.Hash{crypto.SHA256, crypto.SHA512},
+	Sign:         sign,
+	VerifyStream: verify,
+
+	DetachedSuffix: func(*signers.FlagValues) string { return ".sig" },
+}
+
+const maxSignatureSize = 64 * 1024
+
+func init() {
+	SSHSigner.Flags().String("namespace", "file", "(SSH) Namespace the signature is made for, which verifiers must also specify, e.g. \"git\" for git commits")
+	signers.Register(SSHSigner)
+}
+
+func sign(r io.Reader, cert *certloader.Certificate, opts signers.SignOpts) ([]byte, error) {
+	namespace := opts.Flags.GetString("namespace")
+	sig, err := sshsig.Sign(cert.Signer(), r, namespace, opts.Hash)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := ssh.NewPublicKey(cert.Signer().Public())
+	if err != nil {
+		return nil, err
+	}
+	opts.Audit.Attributes["ssh.namespace"] = namespace
+	opts.Audit.Attributes["ssh.fingerprint"] = ssh.FingerprintSHA256(pub)
+	return sig, nil
+}
+
+func verify(r io.Reader, opts signers.VerifyOpts) ([]*signers.Signature, error) {
+	blob, err := io.ReadAll(io.LimitReader(r, maxSignatureSize))
+	if err != nil {
+		return nil, err
+	}
+	sig, err := sshsig.Parse(blob)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := sig.Hash()
+	if err != nil {
+		return nil, err
+	}
+	if opts.Content == "" {
+		return nil, errors.New("--content is required to verify a detached SSH signature")
+	}
+	content, err := os.Open(opts.Content)
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+	if err := sig.Verify(content, sig.Namespace); err != nil {
+		return nil, err
+	}
+	fingerprint := ssh.FingerprintSHA256(sig.PublicKey)
+	if !trusted(sig.PublicKey, opts.TrustedSSH) {
+		return nil, fmt.Errorf("SSH key %s is not trusted, use --cert to name a file with trusted public keys", fingerprint)
+	}
+	return []*signers.Signature{{
+		Hash:    hash,
+		SigInfo: "namespace " + sig.Namespace,
+		Signer:  sig.PublicKey.Type() + " " + fingerprint,
+	}}, nil
+}
+
+func trusted(pub ssh.PublicKey, keys []ssh.PublicKey) bool {
+	blob := pub.Marshal()
+	for _, key := range keys {
+		if bytes.Equal(key.Marshal(), blob) {
+			return true
+		}
+	}
+	return false
+}
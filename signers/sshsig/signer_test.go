@@ -0,0 +1,65 @@
+package sshsig
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/mind-security/relic/v8/lib/audit"
+	"github.com/mind-security/relic/v8/lib/certloader"
+	"github.com/mind-security/relic/v8/signers"
+)
+
+func TestSignVerify(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	cert := &certloader.Certificate{PrivateKey: priv}
+	pub, err := ssh.NewPublicKey(priv.Public())
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "bundle")
+	require.NoError(t, os.WriteFile(path, []byte("hello world\n"), 0644))
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	opts := signers.SignOpts{
+		Path:  path,
+		Hash:  crypto.SHA512,
+		Time:  time.Now(),
+		Audit: audit.New("ed", "ssh", crypto.SHA512),
+		Flags: &signers.FlagValues{Defs: SSHSigner.Flags(), Values: map[string]string{"namespace": "git"}},
+	}
+	sig, err := SSHSigner.Sign(f, cert, opts)
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(sig, []byte("-----BEGIN SSH SIGNATURE-----\n")))
+	assert.Equal(t, "git", opts.Audit.Attributes["ssh.namespace"])
+
+	vopts := signers.VerifyOpts{Content: path, TrustedSSH: []ssh.PublicKey{pub}}
+	sigs, err := SSHSigner.VerifyStream(bytes.NewReader(sig), vopts)
+	require.NoError(t, err)
+	require.Len(t, sigs, 1)
+	assert.Equal(t, crypto.SHA512, sigs[0].Hash)
+	assert.Equal(t, "namespace git", sigs[0].SigInfo)
+	assert.Equal(t, "ssh-ed25519 "+ssh.FingerprintSHA256(pub), sigs[0].Signer)
+
+	// untrusted key
+	_, err = SSHSigner.VerifyStream(bytes.NewReader(sig), signers.VerifyOpts{Content: path})
+	assert.ErrorContains(t, err, "not trusted")
+	// modified content
+	require.NoError(t, os.WriteFile(path, []byte("goodbye world\n"), 0644))
+	_, err = SSHSigner.VerifyStream(bytes.NewReader(sig), vopts)
+	assert.Error(t, err)
+	// detached signature needs the content
+	_, err = SSHSigner.VerifyStream(bytes.NewReader(sig), signers.VerifyOpts{})
+	assert.ErrorContains(t, err, "--content")
+}
@@ -48,11 +48,11 @@ func (s *Signer) GetTransform(f *os.File, opts SignOpts) (Transformer, error) {
 	if s != nil && s.Transform != nil {
 		return s.Transform(f, opts)
 	}
-	return fileProducer{f}, nil
+	return &fileProducer{f: f}, nil
 }
 
 func DefaultTransform(f *os.File) Transformer {
-	return fileProducer{f}
+	return &fileProducer{f: f}
 }
 
 // Dummy implementation that sends the original file as a request, and
@@ -60,19 +60,25 @@ func DefaultTransform(f *os.File) Transformer {
 // MIME type.
 
 type fileProducer struct {
-	f *os.File
+	f        *os.File
+	streamed bool
 }
 
-func (p fileProducer) GetReader() (io.Reader, error) {
+// GetReader rewinds the input file. A pipe can't be rewound, but can still be
+// read once by formats that sign it as a stream.
+func (p *fileProducer) GetReader() (io.Reader, error) {
 	if _, err := p.f.Seek(0, io.SeekStart); err != nil {
-		return nil, fmt.Errorf("seeking input file: %w", err)
+		if p.streamed {
+			return nil, fmt.Errorf("seeking input file: %w", err)
+		}
+		p.streamed = true
 	}
 	return p.f, nil
 }
 
 // If the response is a binpatch, apply it. Otherwise overwrite the destination
 // file with the response
-func (p fileProducer) Apply(dest, mimetype string, result io.Reader) error {
+func (p *fileProducer) Apply(dest, mimetype string, result io.Reader) error {
 	if mimetype == binpatch.MimeType {
 		return ApplyBinPatch(p.f, dest, result)
 	}
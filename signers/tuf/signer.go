@@ -0,0 +1,140 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package tuf
+
+// Sign metadata for The Update Framework
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/mind-security/relic/v8/lib/certloader"
+	"github.com/mind-security/relic/v8/lib/signtuf"
+	"github.com/mind-security/relic/v8/signers"
+	"github.com/mind-security/relic/v8/signers/sigerrors"
+)
+
+// metadata files are small, so don't read too much of a mistaken input
+const maxMetadataSize = 64 << 20
+
+var TufSigner = &signers.Signer{
+	Name:       "tuf",
+	AllowStdin: true,
+	TestPath:   testPath,
+	Sign:       sign,
+	Verify:     verify,
+	Resign:     signers.ResignAppend,
+}
+
+func init() {
+	TufSigner.Flags().String("keyid", "", "(TUF) Key ID to sign as, if root metadata doesn't list the key")
+	signers.Register(TufSigner)
+}
+
+// top-level role names, optionally prefixed by a version number when using
+// consistent snapshots
+var topLevelRole = regexp.MustCompile(`^([0-9]+\.)?(root|targets|snapshot|timestamp)\.json$`)
+
+func testPath(fp string) bool {
+	return topLevelRole.MatchString(filepath.Base(fp))
+}
+
+func readMetadata(r io.Reader) (*signtuf.Metadata, error) {
+	blob, err := io.ReadAll(io.LimitReader(r, maxMetadataSize+1))
+	if err != nil {
+		return nil, err
+	} else if len(blob) > maxMetadataSize {
+		return nil, errors.New("TUF metadata is too large")
+	}
+	return signtuf.Parse(blob)
+}
+
+func sign(r io.Reader, cert *certloader.Certificate, opts signers.SignOpts) ([]byte, error) {
+	md, err := readMetadata(r)
+	if err != nil {
+		return nil, err
+	}
+	keyID := opts.Flags.GetString("keyid")
+	if keyID == "" {
+		keyID, err = md.KeyID(cert.Signer().Public())
+		if err != nil {
+			return nil, err
+		}
+	}
+	if opts.Flags.GetBool("replace") {
+		md.Signatures = nil
+	}
+	if err := md.Sign(cert.Signer(), keyID); err != nil {
+		return nil, err
+	}
+	opts.Audit.Attributes["tuf.role"] = md.Type
+	opts.Audit.Attributes["tuf.version"] = md.Version
+	opts.Audit.Attributes["tuf.keyid"] = keyID
+	opts.Audit.SetMimeType(signtuf.MediaType)
+	return md.Marshal()
+}
+
+func verify(f *os.File, opts signers.VerifyOpts) ([]*signers.Signature, error) {
+	if opts.TufRoot == "" && !(opts.NoDigests && opts.NoChain) {
+		return nil, errors.New("TUF metadata is verified against trusted root metadata; use --tuf-root to specify it")
+	}
+	md, err := readMetadata(f)
+	if err != nil {
+		return nil, err
+	}
+	if opts.TufRoot == "" {
+		// nothing to check against, so just list the signatures
+		if len(md.Signatures) == 0 {
+			return nil, sigerrors.NotSignedError{Type: "TUF"}
+		}
+		var sigs []*signers.Signature
+		for _, sig := range md.Signatures {
+			sigs = append(sigs, &signers.Signature{
+				Package: fmt.Sprintf("%s v%d", md.Type, md.Version),
+				Signer:  "keyid " + sig.KeyID,
+			})
+		}
+		return sigs, nil
+	}
+	rf, err := os.Open(opts.TufRoot)
+	if err != nil {
+		return nil, err
+	}
+	defer rf.Close()
+	root, err := readMetadata(rf)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", opts.TufRoot, err)
+	} else if root.Type != "root" {
+		return nil, fmt.Errorf("%s: expected root metadata but found %s", opts.TufRoot, root.Type)
+	}
+	keyIDs, err := md.Verify(root)
+	if err != nil {
+		return nil, err
+	}
+	var sigs []*signers.Signature
+	for _, keyID := range keyIDs {
+		sigs = append(sigs, &signers.Signature{
+			Package: fmt.Sprintf("%s v%d", md.Type, md.Version),
+			Signer:  "keyid " + keyID,
+		})
+	}
+	return sigs, nil
+}
@@ -62,6 +62,8 @@ type property struct {
 	SignatureTimeValue  string `xml:"SignatureTime>Value"`
 }
 
+// checkManifest verifies the digest of each part listed in the signature, and
+// that all content parts are listed
 func checkManifest(files zipFiles, manifest *etree.Element) error {
 	doc := etree.NewDocument()
 	doc.SetRoot(manifest.Copy())
@@ -73,12 +75,14 @@ func checkManifest(files zipFiles, manifest *etree.Element) error {
 	if err := xml.Unmarshal(blob, &m); err != nil {
 		return fmt.Errorf("validation failed: %w", err)
 	}
+	covered := make(map[string]bool, len(m.References))
 	for _, ref := range m.References {
 		p := path.Join("./" + ref.URI)
 		i := strings.IndexByte(p, '?')
 		if i >= 0 {
 			p = p[:i]
 		}
+		covered[p] = true
 		zf := files[p]
 		if zf == nil {
 			return fmt.Errorf("validation failed: file not found: %s", p)
@@ -104,6 +108,12 @@ func checkManifest(files zipFiles, manifest *etree.Element) error {
 			return fmt.Errorf("validation failed: digest mismatch for %s: calculated %x, found %x", p, refCalc, refv)
 		}
 	}
+	// every content part must be signed, not just the ones that are listed
+	for name := range files {
+		if keepFile(name) && !strings.HasSuffix(name, "/") && !covered[name] {
+			return fmt.Errorf("validation failed: file not covered by signature: %s", name)
+		}
+	}
 	return nil
 }
 
@@ -41,6 +41,10 @@ var Signer = &signers.Signer{
 	Transform: zipbased.Transform,
 	Sign:      sign,
 	Verify:    verify,
+	Resign:    signers.ResignReplace,
+
+	NeedsSigningTime: true,
+	CheckInput:       zipbased.CheckInput,
 }
 
 type zipFiles map[string]*zip.File
@@ -56,6 +60,10 @@ func sign(r io.Reader, cert *certloader.Certificate, opts signers.SignOpts) ([]b
 	if err != nil {
 		return nil, err
 	}
+	m.m.ModTime, err = opts.ModTime()
+	if err != nil {
+		return nil, err
+	}
 	// add rels and origin to zip
 	sigName := path.Join(xmlSigPath, calcFileName(cert.Leaf)+".psdsxs")
 	if err := m.newRels("", originPath, sigOriginType); err != nil {
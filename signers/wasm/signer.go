@@ -0,0 +1,72 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package wasm
+
+// Sign WebAssembly modules
+
+import (
+	"io"
+	"os"
+
+	"github.com/mind-security/relic/v8/lib/certloader"
+	"github.com/mind-security/relic/v8/lib/magic"
+	"github.com/mind-security/relic/v8/lib/signwasm"
+	"github.com/mind-security/relic/v8/lib/x509tools"
+	"github.com/mind-security/relic/v8/signers"
+)
+
+var WasmSigner = &signers.Signer{
+	Name:      "wasm",
+	Magic:     magic.FileTypeWASM,
+	CertTypes: signers.CertTypeX509,
+	Sign:      sign,
+	Verify:    verify,
+	Resign:    signers.ResignReplace,
+}
+
+func init() {
+	signers.Register(WasmSigner)
+}
+
+func sign(r io.Reader, cert *certloader.Certificate, opts signers.SignOpts) ([]byte, error) {
+	digest, err := signwasm.DigestModule(r, opts.Hash)
+	if err != nil {
+		return nil, err
+	}
+	patch, ts, err := digest.Sign(opts.Context(), cert)
+	if err != nil {
+		return nil, err
+	}
+	opts.Audit.SetCounterSignature(ts.CounterSignature)
+	return opts.SetBinPatch(patch)
+}
+
+func verify(f *os.File, opts signers.VerifyOpts) ([]*signers.Signature, error) {
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	ts, err := signwasm.Verify(f, size, opts.NoDigests)
+	if err != nil {
+		return nil, err
+	}
+	hash, _ := x509tools.PkixDigestToHash(ts.SignerInfo.DigestAlgorithm)
+	return []*signers.Signature{{
+		Hash:          hash,
+		X509Signature: ts,
+	}}, nil
+}
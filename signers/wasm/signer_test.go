@@ -0,0 +1,58 @@
+package wasm
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mind-security/relic/v8/internal/signertest"
+	"github.com/mind-security/relic/v8/lib/magic"
+	"github.com/mind-security/relic/v8/signers"
+)
+
+// a module with an empty function type and a custom "name" section
+var testModule = []byte{
+	0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00,
+	0x01, 0x04, 0x01, 0x60, 0x00, 0x00,
+	0x00, 0x07, 0x04, 'n', 'a', 'm', 'e', 0x00, 0x00,
+}
+
+func writeModule(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "test.wasm")
+	require.NoError(t, os.WriteFile(path, testModule, 0644))
+	return path
+}
+
+func TestSignWasm(t *testing.T) {
+	unsigned := writeModule(t)
+	assert.Equal(t, magic.FileTypeWASM, magic.Detect(bytes.NewReader(testModule)))
+	_, err := signertest.Verify(t, WasmSigner, unsigned, signers.VerifyOpts{})
+	assert.Error(t, err)
+
+	signed := signertest.RoundTrip(t, WasmSigner, unsigned, signertest.LoadCert(t, false), len(testModule)-1)
+	blob, err := os.ReadFile(signed)
+	require.NoError(t, err)
+	assert.Equal(t, testModule, blob[:len(testModule)], "original sections are untouched")
+}
+
+func TestSignWasmDeterministic(t *testing.T) {
+	// RSA PKCS#1 v1.5 and no signing time attribute: the same input always
+	// gives the same signature
+	cert := signertest.LoadCert(t, false)
+	path := writeModule(t)
+	opts := signertest.Opts(WasmSigner, time.Unix(1700000000, 0), nil)
+	sign := func() []byte {
+		f, err := os.Open(path)
+		require.NoError(t, err)
+		defer f.Close()
+		blob, err := WasmSigner.Sign(f, cert, opts)
+		require.NoError(t, err)
+		return blob
+	}
+	assert.Equal(t, sign(), sign())
+}
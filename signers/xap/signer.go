@@ -37,6 +37,9 @@ var XapSigner = &signers.Signer{
 	Transform: zipbased.Transform,
 	Sign:      sign,
 	Verify:    verify,
+	Resign:    signers.ResignReplace,
+
+	CheckInput: zipbased.CheckInput,
 }
 
 func init() {
@@ -0,0 +1,14 @@
+package xar
+
+import (
+	"testing"
+
+	"github.com/mind-security/relic/v8/internal/signertest"
+)
+
+const testPkg = "../../functest/packages/dummy.pkg"
+
+func TestSignXar(t *testing.T) {
+	// the last byte belongs to the archived files
+	signertest.RoundTrip(t, signer, testPkg, signertest.LoadCert(t, false), -1)
+}
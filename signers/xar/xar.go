@@ -1,6 +1,7 @@
 package xar
 
 import (
+	"crypto"
 	"io"
 	"os"
 
@@ -15,8 +16,10 @@ var signer = &signers.Signer{
 	Name:      "xar",
 	Magic:     magic.FileTypeXAR,
 	CertTypes: signers.CertTypeX509,
+	Hashes:    []crypto.Hash{crypto.SHA256, crypto.SHA512},
 	Sign:      sign,
 	Verify:    verify,
+	Resign:    signers.ResignReplace,
 }
 
 func init() {
@@ -17,9 +17,11 @@
 package zipbased
 
 import (
+	"errors"
 	"io"
 	"os"
 
+	"github.com/mind-security/relic/v8/lib/binpatch"
 	"github.com/mind-security/relic/v8/lib/zipslicer"
 	"github.com/mind-security/relic/v8/signers"
 )
@@ -32,6 +34,11 @@ func Transform(f *os.File, opts signers.SignOpts) (signers.Transformer, error) {
 	return &zipTransformer{f}, nil
 }
 
+// CheckInput rejects a stream that was not produced by Transform
+func CheckInput(head []byte) error {
+	return zipslicer.CheckZipTar(head)
+}
+
 // Wrap the zip in a tarball with the central directory first so that it can be
 // processed as a stream
 func (t *zipTransformer) GetReader() (io.Reader, error) {
@@ -42,6 +49,14 @@ func (t *zipTransformer) GetReader() (io.Reader, error) {
 	return r, nil
 }
 
+// Apply a binary patch to the archive. Any other result is a detached
+// signature and is written to dest as-is, which must not be the input file.
 func (t *zipTransformer) Apply(dest, mimeType string, result io.Reader) error {
-	return signers.ApplyBinPatch(t.f, dest, result)
+	if mimeType == binpatch.MimeType {
+		return signers.ApplyBinPatch(t.f, dest, result)
+	}
+	if dest == t.f.Name() {
+		return errors.New("refusing to overwrite the input file with a detached signature; use --output")
+	}
+	return signers.DefaultTransform(t.f).Apply(dest, mimeType, result)
 }
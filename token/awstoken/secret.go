@@ -0,0 +1,45 @@
+package awstoken
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+
+	"github.com/mind-security/relic/v8/config"
+)
+
+func init() {
+	config.RegisterSecretLoader("aws", getSecret)
+}
+
+// getSecret fetches a secret from AWS Secrets Manager by name or ARN, using
+// the default credentials and region. A full ARN selects its own region.
+func getSecret(name string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	var opts []func(*awsconfig.LoadOptions) error
+	if parsed, err := arn.Parse(name); err == nil && parsed.Region != "" {
+		opts = append(opts, awsconfig.WithRegion(parsed.Region))
+	}
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	out, err := secretsmanager.NewFromConfig(cfg).GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(name),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if out.SecretString != nil {
+		return []byte(*out.SecretString), nil
+	} else if out.SecretBinary != nil {
+		return out.SecretBinary, nil
+	}
+	return nil, errors.New("secret has no value")
+}
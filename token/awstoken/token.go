@@ -8,7 +8,12 @@ import (
 	"crypto/x509"
 	"fmt"
 	"io"
+	"net/http"
+	"strings"
+	"sync"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/kms"
 	"github.com/aws/aws-sdk-go-v2/service/kms/types"
@@ -23,12 +28,17 @@ const tokenType = "aws"
 type awsToken struct {
 	config *config.Config
 	tconf  *config.TokenConfig
+	cfg    aws.Config
 	cli    *kms.Client
+
+	mu         sync.Mutex
+	regionClis map[string]*kms.Client
 }
 
 type awsKey struct {
 	kconf *config.KeyConfig
 	cli   *kms.Client
+	id    string
 	pub   crypto.PublicKey
 }
 
@@ -41,7 +51,16 @@ func open(conf *config.Config, tokenName string, pinProvider passprompt.Password
 	if err != nil {
 		return nil, err
 	}
-	cfg, err := awsconfig.LoadDefaultConfig(context.Background())
+	var opts []func(*awsconfig.LoadOptions) error
+	if tconf.Proxy != "" {
+		proxy, err := config.ProxyFunc(tconf.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		hc := awshttp.NewBuildableClient().WithTransportOptions(func(tr *http.Transport) { tr.Proxy = proxy })
+		opts = append(opts, awsconfig.WithHTTPClient(hc))
+	}
+	cfg, err := awsconfig.LoadDefaultConfig(context.Background(), opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -49,6 +68,7 @@ func open(conf *config.Config, tokenName string, pinProvider passprompt.Password
 	return &awsToken{
 		config: conf,
 		tconf:  tconf,
+		cfg:    cfg,
 		cli:    cli,
 	}, nil
 }
@@ -74,8 +94,14 @@ func (t *awsToken) GetKey(ctx context.Context, keyName string) (token.Key, error
 	if keyConf.ID == "" {
 		return nil, fmt.Errorf("key %q must have \"id\" set to the ID or ARN of the key", keyName)
 	}
-	id := keyConf.ID
-	resp, err := t.cli.GetPublicKey(ctx, &kms.GetPublicKeyInput{KeyId: &id})
+	return token.GetStandbyKey(ctx, keyConf, func(ctx context.Context, id string) (token.Key, error) {
+		return t.getReplica(ctx, keyConf, id)
+	}, nil)
+}
+
+func (t *awsToken) getReplica(ctx context.Context, keyConf *config.KeyConfig, id string) (token.Key, error) {
+	cli := t.clientFor(id)
+	resp, err := cli.GetPublicKey(ctx, &kms.GetPublicKeyInput{KeyId: &id})
 	if err != nil {
 		return nil, err
 	}
@@ -85,11 +111,34 @@ func (t *awsToken) GetKey(ctx context.Context, keyName string) (token.Key, error
 	}
 	return &awsKey{
 		kconf: keyConf,
-		cli:   t.cli,
+		cli:   cli,
+		id:    id,
 		pub:   pub,
 	}, nil
 }
 
+// clientFor returns a client for the region named in a key ARN, or the default
+// client if the ID is not an ARN
+func (t *awsToken) clientFor(id string) *kms.Client {
+	// arn:aws:kms:us-east-1:111122223333:key/mrk-1234abcd
+	fields := strings.SplitN(id, ":", 5)
+	if len(fields) < 5 || fields[0] != "arn" || fields[3] == "" || fields[3] == t.cfg.Region {
+		return t.cli
+	}
+	region := fields[3]
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if cli := t.regionClis[region]; cli != nil {
+		return cli
+	}
+	cli := kms.NewFromConfig(t.cfg, func(o *kms.Options) { o.Region = region })
+	if t.regionClis == nil {
+		t.regionClis = make(map[string]*kms.Client)
+	}
+	t.regionClis[region] = cli
+	return cli
+}
+
 func (t *awsToken) Import(keyName string, privKey crypto.PrivateKey) (token.Key, error) {
 	return nil, token.NotImplementedError{Op: "import-key", Type: tokenType}
 }
@@ -119,7 +168,7 @@ func (k *awsKey) SignContext(ctx context.Context, digest []byte, opts crypto.Sig
 	if err != nil {
 		return nil, err
 	}
-	id := k.kconf.ID
+	id := k.id
 	resp, err := k.cli.Sign(ctx, &kms.SignInput{
 		KeyId:            &id,
 		Message:          digest,
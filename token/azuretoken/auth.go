@@ -18,11 +18,13 @@ import (
 // Configure azure authentication based on the token config and/or process
 // environment.
 func newAuthorizer(tconf *config.TokenConfig) (autorest.Authorizer, error) {
-	if tconf.Pin == nil {
+	credFile, ok, err := tconf.GetPin()
+	if err != nil {
+		return nil, err
+	} else if !ok {
 		// PIN not present means use environment
 		return newAuthorizerFromEnvironment()
 	}
-	credFile := *tconf.Pin
 	if credFile == "" {
 		// PIN present but empty means use azure CLI auth
 		return kvauth.NewAuthorizerFromCLI()
@@ -11,9 +11,11 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"strings"
 
 	"github.com/Azure/azure-sdk-for-go/services/keyvault/2016-10-01/keyvault"
+	"github.com/Azure/go-autorest/autorest"
 	"github.com/go-jose/go-jose/v3"
 
 	"github.com/mind-security/relic/v8/config"
@@ -56,6 +58,17 @@ func open(conf *config.Config, tokenName string, pinProvider passprompt.Password
 	}
 	cli := keyvault.New()
 	cli.Authorizer = auth
+	if tconf.Proxy != "" {
+		// only key vault calls go through it, fetching the auth token follows
+		// the environment
+		proxy, err := config.ProxyFunc(tconf.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		tr := http.DefaultTransport.(*http.Transport).Clone()
+		tr.Proxy = proxy
+		cli.Sender = &http.Client{Transport: tr}
+	}
 	return &kvToken{
 		config: conf,
 		tconf:  tconf,
@@ -70,7 +83,7 @@ func (t *kvToken) Close() error {
 func (t *kvToken) Ping(ctx context.Context) error {
 	// query info for one of the keys in this token
 	for _, keyConf := range t.config.Keys {
-		if keyConf.Token != t.tconf.Name() || keyConf.Hide {
+		if keyConf.Token != t.tconf.Name() || keyConf.Hide || keyConf.LabelPattern != "" {
 			continue
 		}
 		ctx, cancel := context.WithTimeout(ctx, keyConf.GetTimeout())
@@ -93,7 +106,25 @@ func (t *kvToken) GetKey(ctx context.Context, keyName string) (token.Key, error)
 	if err != nil {
 		return nil, err
 	}
-	return t.getKey(ctx, keyConf, false)
+	return token.GetStandbyKey(ctx, keyConf, func(ctx context.Context, id string) (token.Key, error) {
+		if id == keyConf.ID {
+			return t.getKey(ctx, keyConf, false)
+		}
+		replicaConf := *keyConf
+		replicaConf.ID = id
+		return t.getKey(ctx, &replicaConf, false)
+	}, isUnavailable)
+}
+
+// isUnavailable reports whether a key vault error indicates a regional outage
+func isUnavailable(err error) bool {
+	var detailed autorest.DetailedError
+	if errors.As(err, &detailed) {
+		if code, ok := detailed.StatusCode.(int); ok && code >= 500 {
+			return true
+		}
+	}
+	return token.IsUnavailable(err)
 }
 
 func (t *kvToken) getKey(ctx context.Context, keyConf *config.KeyConfig, pingOnly bool) (token.Key, error) {
@@ -0,0 +1,37 @@
+package gcloudtoken
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	secretmanager "cloud.google.com/go/secretmanager/apiv1"
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+
+	"github.com/mind-security/relic/v8/config"
+)
+
+func init() {
+	config.RegisterSecretLoader("gcp", getSecret)
+}
+
+// getSecret fetches a secret from Google Secret Manager using the default
+// credentials. The name is a resource name, projects/<p>/secrets/<s>, and the
+// latest version is used unless one is given.
+func getSecret(name string) ([]byte, error) {
+	if !strings.Contains(name, "/versions/") {
+		name += "/versions/latest"
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	cli, err := secretmanager.NewClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer cli.Close()
+	resp, err := cli.AccessSecretVersion(ctx, &secretmanagerpb.AccessSecretVersionRequest{Name: name})
+	if err != nil {
+		return nil, err
+	}
+	return resp.GetPayload().GetData(), nil
+}
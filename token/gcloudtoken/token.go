@@ -8,14 +8,19 @@ import (
 	"encoding/pem"
 	"errors"
 	"fmt"
+	"hash/crc32"
 	"io"
 
 	kms "cloud.google.com/go/kms/apiv1"
 	"google.golang.org/api/option"
 	kmspb "google.golang.org/genproto/googleapis/cloud/kms/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
 
 	"github.com/mind-security/relic/v8/config"
 	"github.com/mind-security/relic/v8/lib/passprompt"
+	"github.com/mind-security/relic/v8/lib/x509tools"
 	"github.com/mind-security/relic/v8/token"
 )
 
@@ -30,9 +35,13 @@ type gcloudToken struct {
 type gcloudKey struct {
 	kconf *config.KeyConfig
 	cli   *kms.KeyManagementClient
+	name  string
 	pub   crypto.PublicKey
 	hash  crypto.Hash
 	pss   bool
+	// raw keys sign a DigestInfo supplied as data instead of a bare digest,
+	// so any hash is allowed
+	raw bool
 }
 
 func init() {
@@ -44,9 +53,14 @@ func open(conf *config.Config, tokenName string, pinProvider passprompt.Password
 	if err != nil {
 		return nil, err
 	}
+	if tconf.Proxy != "" {
+		return nil, errors.New("gcloud tokens don't support the proxy option, set HTTPS_PROXY in the environment instead")
+	}
 	var opts []option.ClientOption
-	if tconf.Pin != nil {
-		opts = append(opts, option.WithCredentialsFile(*tconf.Pin))
+	if credFile, ok, err := tconf.GetPin(); err != nil {
+		return nil, err
+	} else if ok {
+		opts = append(opts, option.WithCredentialsFile(credFile))
 	}
 	cli, err := kms.NewKeyManagementClient(context.Background(), opts...)
 	if err != nil {
@@ -80,13 +94,23 @@ func (t *gcloudToken) GetKey(ctx context.Context, keyName string) (token.Key, er
 	if keyConf.ID == "" {
 		return nil, fmt.Errorf("key %q must have \"id\" set to the fully-quaified resource name of a Cloud KMS key version", keyName)
 	}
-	resp, err := t.cli.GetPublicKey(ctx, &kmspb.GetPublicKeyRequest{Name: keyConf.ID})
+	return token.GetStandbyKey(ctx, keyConf, func(ctx context.Context, name string) (token.Key, error) {
+		return t.getReplica(ctx, keyConf, name)
+	}, isUnavailable)
+}
+
+func (t *gcloudToken) getReplica(ctx context.Context, keyConf *config.KeyConfig, name string) (token.Key, error) {
+	resp, err := t.cli.GetPublicKey(ctx, &kmspb.GetPublicKeyRequest{Name: name})
 	if err != nil {
 		return nil, err
 	}
+	if resp.PemCrc32C != nil && resp.PemCrc32C.Value != checksum([]byte(resp.Pem)) {
+		return nil, fmt.Errorf("key %q: public key was corrupted in transit", keyConf.Name())
+	}
 	hashFunc, pss := pubKeyAlgorithm(resp)
-	if hashFunc == 0 {
-		return nil, fmt.Errorf("key %q: unsupported type %q", keyName, resp.Algorithm.String())
+	raw := isRawPKCS1(resp)
+	if hashFunc == 0 && !raw {
+		return nil, fmt.Errorf("key %q: unsupported type %q", keyConf.Name(), resp.Algorithm.String())
 	}
 	block, _ := pem.Decode([]byte(resp.Pem))
 	if block == nil {
@@ -101,9 +125,11 @@ func (t *gcloudToken) GetKey(ctx context.Context, keyName string) (token.Key, er
 	return &gcloudKey{
 		kconf: keyConf,
 		cli:   t.cli,
+		name:  name,
 		pub:   pub,
 		hash:  hashFunc,
 		pss:   pss,
+		raw:   raw,
 	}, nil
 }
 
@@ -132,6 +158,9 @@ func (k *gcloudKey) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts)
 }
 
 func (k *gcloudKey) SignContext(ctx context.Context, digest []byte, opts crypto.SignerOpts) (signature []byte, err error) {
+	if k.raw {
+		return k.signRaw(ctx, digest, opts)
+	}
 	if opts.HashFunc() != k.hash {
 		return nil, token.KeyUsageError{
 			Key: k.kconf.Name(),
@@ -150,8 +179,9 @@ func (k *gcloudKey) SignContext(ctx context.Context, digest []byte, opts crypto.
 		}
 	}
 	req := &kmspb.AsymmetricSignRequest{
-		Name:   k.kconf.ID,
-		Digest: &kmspb.Digest{},
+		Name:         k.name,
+		Digest:       &kmspb.Digest{},
+		DigestCrc32C: wrapperspb.Int64(checksum(digest)),
 	}
 	switch k.hash {
 	case crypto.SHA256:
@@ -170,6 +200,50 @@ func (k *gcloudKey) SignContext(ctx context.Context, digest []byte, opts crypto.
 	if err != nil {
 		return nil, err
 	}
+	if !resp.VerifiedDigestCrc32C {
+		return nil, fmt.Errorf("key %q: digest was corrupted in transit", k.kconf.Name())
+	}
+	return k.checkResponse(resp)
+}
+
+// signRaw signs with a RSA_SIGN_RAW_PKCS1 key. These take the DigestInfo as
+// data rather than a digest, which lets the caller choose the hash.
+func (k *gcloudKey) signRaw(ctx context.Context, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if _, ok := opts.(*rsa.PSSOptions); ok {
+		return nil, token.KeyUsageError{
+			Key: k.kconf.Name(),
+			Err: errors.New("tried to use RSA-PSS signature but key uses PKCS#1"),
+		}
+	}
+	data, ok := x509tools.MarshalDigest(opts.HashFunc(), digest)
+	if !ok {
+		return nil, token.KeyUsageError{
+			Key: k.kconf.Name(),
+			Err: fmt.Errorf("unsupported digest algorithm %s", opts.HashFunc()),
+		}
+	}
+	resp, err := k.cli.AsymmetricSign(ctx, &kmspb.AsymmetricSignRequest{
+		Name:       k.name,
+		Data:       data,
+		DataCrc32C: wrapperspb.Int64(checksum(data)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.VerifiedDataCrc32C {
+		return nil, fmt.Errorf("key %q: data was corrupted in transit", k.kconf.Name())
+	}
+	return k.checkResponse(resp)
+}
+
+// checkResponse verifies the integrity fields of a signing response
+func (k *gcloudKey) checkResponse(resp *kmspb.AsymmetricSignResponse) ([]byte, error) {
+	if resp.Name != k.name {
+		return nil, fmt.Errorf("key %q: response was for key version %q", k.kconf.Name(), resp.Name)
+	}
+	if resp.SignatureCrc32C == nil || resp.SignatureCrc32C.Value != checksum(resp.Signature) {
+		return nil, fmt.Errorf("key %q: signature was corrupted in transit", k.kconf.Name())
+	}
 	return resp.Signature, nil
 }
 
@@ -214,3 +288,29 @@ func pubKeyAlgorithm(pub *kmspb.PublicKey) (h crypto.Hash, pss bool) {
 	}
 	return 0, false
 }
+
+func isRawPKCS1(pub *kmspb.PublicKey) bool {
+	switch pub.Algorithm {
+	case kmspb.CryptoKeyVersion_RSA_SIGN_RAW_PKCS1_2048,
+		kmspb.CryptoKeyVersion_RSA_SIGN_RAW_PKCS1_3072,
+		kmspb.CryptoKeyVersion_RSA_SIGN_RAW_PKCS1_4096:
+		return true
+	}
+	return false
+}
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// checksum computes the CRC32C that Cloud KMS uses to detect corruption
+func checksum(b []byte) int64 {
+	return int64(crc32.Checksum(b, crcTable))
+}
+
+// isUnavailable reports whether a KMS error indicates a regional outage
+func isUnavailable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Internal:
+		return true
+	}
+	return token.IsUnavailable(err)
+}
@@ -22,16 +22,32 @@ import (
 	"io"
 
 	"github.com/mind-security/relic/v8/config"
+	"github.com/mind-security/relic/v8/lib/audit"
 	"github.com/mind-security/relic/v8/lib/passprompt"
 	"github.com/mind-security/relic/v8/signers/sigerrors"
 )
 
 func Login(tokenConf *config.TokenConfig, pinProvider passprompt.PasswordGetter, loginFunc passprompt.LoginFunc, keyringUser, initialPrompt string) error {
-	if tokenConf.Pin != nil {
-		ok, err := loginFunc(*tokenConf.Pin)
+	err := login(tokenConf, pinProvider, loginFunc, keyringUser, initialPrompt)
+	if err != nil {
+		AuditEvent(tokenConf, audit.EventLoginFailed, err.Error())
+	} else {
+		AuditEvent(tokenConf, audit.EventLogin, "")
+	}
+	return err
+}
+
+func login(tokenConf *config.TokenConfig, pinProvider passprompt.PasswordGetter, loginFunc passprompt.LoginFunc, keyringUser, initialPrompt string) error {
+	pin, havePin, err := tokenConf.GetPin()
+	if err != nil {
+		return err
+	} else if havePin {
+		ok, err := loginFunc(pin)
 		if err != nil {
 			return err
 		} else if !ok {
+			// the secret may have been rotated, so fetch it again next time
+			tokenConf.ClearPin()
 			return sigerrors.PinIncorrectError{}
 		} else {
 			return nil
@@ -45,7 +61,7 @@ func Login(tokenConf *config.TokenConfig, pinProvider passprompt.PasswordGetter,
 	if tokenConf.UseKeyring {
 		keyringService = "relic"
 	}
-	err := passprompt.Login(loginFunc, pinProvider, keyringService, keyringUser, initialPrompt, failPrefix)
+	err = passprompt.Login(loginFunc, pinProvider, keyringService, keyringUser, initialPrompt, failPrefix)
 	if err == io.EOF {
 		if pinProvider == nil {
 			msg := "PIN required but none was provided"
@@ -0,0 +1,35 @@
+package token
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mind-security/relic/v8/config"
+	"github.com/mind-security/relic/v8/lib/audit"
+	"github.com/mind-security/relic/v8/signers/sigerrors"
+)
+
+func TestLoginAudit(t *testing.T) {
+	var events []*audit.Info
+	AuditHook = func(info *audit.Info) { events = append(events, info) }
+	defer func() { AuditHook = nil }()
+	cfg := new(config.Config)
+	tconf := cfg.NewToken("hsm")
+	pin := "1234"
+	tconf.Pin = &pin
+	loginFunc := func(attempt string) (bool, error) { return attempt == "0000", nil }
+
+	err := Login(tconf, nil, loginFunc, "", "")
+	assert.ErrorAs(t, err, new(sigerrors.PinIncorrectError))
+	require.Len(t, events, 1)
+	assert.Equal(t, audit.EventLoginFailed, events[0].EventType())
+	assert.Equal(t, "hsm", events[0].Attributes["token.name"])
+	assert.Equal(t, err.Error(), events[0].Attributes["token.reason"])
+
+	pin = "0000"
+	require.NoError(t, Login(tconf, nil, loginFunc, "", ""))
+	require.Len(t, events, 2)
+	assert.Equal(t, audit.EventLogin, events[1].EventType())
+}
@@ -0,0 +1,217 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package offlinetoken stands in for a real token when the private key is
+// not available, such as when signatures are made on an offline HSM. Keys
+// return signatures that were made elsewhere, checking each one against its
+// digest. When they run out, the next digest is recorded and signing stops
+// with ErrNeedSignature.
+package offlinetoken
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/mind-security/relic/v8/config"
+	"github.com/mind-security/relic/v8/lib/certloader"
+	"github.com/mind-security/relic/v8/lib/pgptools"
+	"github.com/mind-security/relic/v8/lib/x509tools"
+	"github.com/mind-security/relic/v8/token"
+)
+
+const tokenType = "offline"
+
+// ErrNeedSignature is returned by a key when a digest was recorded because no
+// signature was provided for it
+var ErrNeedSignature = errors.New("a signature made elsewhere is needed to continue")
+
+// Digest is a digest that would have been signed
+type Digest struct {
+	Hash  crypto.Hash
+	Value []byte
+	// PSS is true if the signature must use RSA-PSS padding
+	PSS bool
+}
+
+func (d Digest) String() string {
+	name := x509tools.HashShortName(d.Hash)
+	if d.PSS {
+		name += "+pss"
+	}
+	return fmt.Sprintf("%s:%x", name, d.Value)
+}
+
+// Token provides keys whose public half comes from the certificates in the
+// key configuration
+type Token struct {
+	config *config.Config
+	// Digests holds each digest that was signed, in order
+	Digests []Digest
+	// Signatures to return, in order
+	Signatures [][]byte
+}
+
+type offlineKey struct {
+	tok     *Token
+	keyConf *config.KeyConfig
+	pub     crypto.PublicKey
+}
+
+// New creates an offline token for keys in the given configuration
+func New(conf *config.Config, signatures [][]byte) *Token {
+	return &Token{config: conf, Signatures: signatures}
+}
+
+func (tok *Token) Ping(context.Context) error {
+	return nil
+}
+
+func (tok *Token) Close() error {
+	return nil
+}
+
+func (tok *Token) Config() *config.TokenConfig {
+	return &config.TokenConfig{Type: tokenType}
+}
+
+func (tok *Token) ListKeys(opts token.ListOptions) error {
+	return token.NotImplementedError{Op: "list-keys", Type: tokenType}
+}
+
+// GetKey returns a key whose public half is the leaf of x509certificate, or
+// failing that the pgpsubkey or primary key of pgpcertificate
+func (tok *Token) GetKey(ctx context.Context, keyName string) (token.Key, error) {
+	keyConf, err := tok.config.GetKey(keyName)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := publicKey(keyConf)
+	if err != nil {
+		return nil, fmt.Errorf("key %q: %w", keyName, err)
+	}
+	return &offlineKey{tok: tok, keyConf: keyConf, pub: pub}, nil
+}
+
+func publicKey(keyConf *config.KeyConfig) (crypto.PublicKey, error) {
+	if keyConf.X509Certificate != "" {
+		certs, err := certloader.LoadAnyCerts([]string{keyConf.X509Certificate})
+		if err != nil {
+			return nil, err
+		} else if len(certs.X509Certs) == 0 {
+			return nil, errors.New("no certificates found in x509certificate")
+		}
+		// the leaf is the first certificate that isn't a CA
+		for _, cert := range certs.X509Certs {
+			if !cert.IsCA {
+				return cert.PublicKey, nil
+			}
+		}
+		return certs.X509Certs[0].PublicKey, nil
+	}
+	if keyConf.PgpCertificate != "" {
+		certs, err := certloader.LoadAnyCerts([]string{keyConf.PgpCertificate})
+		if err != nil {
+			return nil, err
+		} else if len(certs.PGPCerts) != 1 {
+			return nil, errors.New("expected exactly 1 entity in pgpcertificate")
+		}
+		entity := certs.PGPCerts[0]
+		if keyConf.PgpSubkey == "" {
+			return entity.PrimaryKey.PublicKey, nil
+		}
+		for _, sub := range entity.Subkeys {
+			if ok, err := pgptools.MatchKey(sub.PublicKey, keyConf.PgpSubkey); err != nil {
+				return nil, err
+			} else if ok {
+				return sub.PublicKey.PublicKey, nil
+			}
+		}
+		return nil, fmt.Errorf("PGP subkey %s not found in pgpcertificate", keyConf.PgpSubkey)
+	}
+	return nil, errors.New("offline signing requires x509certificate or pgpcertificate")
+}
+
+func (key *offlineKey) Public() crypto.PublicKey {
+	return key.pub
+}
+
+func (key *offlineKey) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return key.SignContext(context.Background(), digest, opts)
+}
+
+func (key *offlineKey) SignContext(ctx context.Context, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	_, pss := opts.(*rsa.PSSOptions)
+	d := Digest{Hash: opts.HashFunc(), Value: append([]byte(nil), digest...), PSS: pss}
+	tok := key.tok
+	i := len(tok.Digests)
+	tok.Digests = append(tok.Digests, d)
+	if i >= len(tok.Signatures) {
+		return nil, ErrNeedSignature
+	}
+	sig := tok.Signatures[i]
+	if err := verify(key.pub, d, sig); err != nil {
+		return nil, fmt.Errorf("signature %d does not match %s: %w", i+1, d, err)
+	}
+	return sig, nil
+}
+
+// Pending returns the digest that still needs to be signed, if any
+func (tok *Token) Pending() *Digest {
+	if len(tok.Digests) > len(tok.Signatures) {
+		return &tok.Digests[len(tok.Signatures)]
+	}
+	return nil
+}
+
+func verify(pub crypto.PublicKey, d Digest, sig []byte) error {
+	if k, ok := pub.(*rsa.PublicKey); ok && d.PSS {
+		return rsa.VerifyPSS(k, d.Hash, d.Value, sig, nil)
+	}
+	return x509tools.Verify(pub, d.Hash, d.Value, sig)
+}
+
+func (key *offlineKey) Config() *config.KeyConfig {
+	return key.keyConf
+}
+
+func (key *offlineKey) Certificate() []byte {
+	return nil
+}
+
+func (key *offlineKey) GetID() []byte {
+	return nil
+}
+
+func (tok *Token) Import(keyName string, privKey crypto.PrivateKey) (token.Key, error) {
+	return nil, token.NotImplementedError{Op: "import-key", Type: tokenType}
+}
+
+func (tok *Token) ImportCertificate(cert *x509.Certificate, labelBase string) error {
+	return token.NotImplementedError{Op: "import-certificate", Type: tokenType}
+}
+
+func (tok *Token) Generate(keyName string, keyType token.KeyType, bits uint) (token.Key, error) {
+	return nil, token.NotImplementedError{Op: "generate-key", Type: tokenType}
+}
+
+func (key *offlineKey) ImportCertificate(cert *x509.Certificate) error {
+	return token.NotImplementedError{Op: "import-certificate", Type: tokenType}
+}
@@ -0,0 +1,100 @@
+package offlinetoken
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mind-security/relic/v8/config"
+	"github.com/mind-security/relic/v8/internal/signinit"
+	"github.com/mind-security/relic/v8/lib/binpatch"
+	"github.com/mind-security/relic/v8/lib/certloader"
+	"github.com/mind-security/relic/v8/signers"
+	"github.com/mind-security/relic/v8/signers/pecoff"
+)
+
+const pePath = "../../functest/packages/WindowsFormsApplication1.exe"
+
+func signPE(t *testing.T, tok *Token, now time.Time) []byte {
+	flags, err := pecoff.PeSigner.FlagsFromQuery(nil)
+	require.NoError(t, err)
+	cert, opts, err := signinit.InitAt(context.Background(), pecoff.PeSigner, tok, "rsa2048", crypto.SHA256, flags, now)
+	require.NoError(t, err)
+	f, err := os.Open(pePath)
+	require.NoError(t, err)
+	defer f.Close()
+	blob, err := pecoff.PeSigner.Sign(f, cert, *opts)
+	if tok.Pending() != nil {
+		require.ErrorIs(t, err, ErrNeedSignature)
+		return nil
+	}
+	require.NoError(t, err)
+	return blob
+}
+
+func TestDigestAndAssemble(t *testing.T) {
+	// the token is never opened
+	cfg := &config.Config{
+		Tokens: map[string]*config.TokenConfig{"hsm": {Type: "pkcs11"}},
+		Keys: map[string]*config.KeyConfig{
+			"rsa2048": {Token: "hsm", X509Certificate: "../../functest/testkeys/rsa2048.crt"},
+		},
+	}
+	require.NoError(t, cfg.Normalize("."))
+	now := time.Now().Truncate(time.Second)
+
+	// record the digest without the key
+	tok := New(cfg, nil)
+	signPE(t, tok, now)
+	require.Len(t, tok.Digests, 1)
+	d := *tok.Pending()
+	assert.Equal(t, crypto.SHA256, d.Hash)
+
+	// sign it elsewhere
+	keyBlob, err := os.ReadFile("../../functest/testkeys/rsa2048.key")
+	require.NoError(t, err)
+	key, err := certloader.ParseAnyPrivateKey(keyBlob, nil)
+	require.NoError(t, err)
+	sig, err := key.(crypto.Signer).Sign(rand.Reader, d.Value, d.Hash)
+	require.NoError(t, err)
+
+	// a signature over something else is rejected
+	bad := New(cfg, [][]byte{sig})
+	flags, err := pecoff.PeSigner.FlagsFromQuery(nil)
+	require.NoError(t, err)
+	cert, opts, err := signinit.InitAt(context.Background(), pecoff.PeSigner, bad, "rsa2048", crypto.SHA384, flags, now)
+	require.NoError(t, err)
+	f, err := os.Open(pePath)
+	require.NoError(t, err)
+	defer f.Close()
+	_, err = pecoff.PeSigner.Sign(f, cert, *opts)
+	assert.ErrorContains(t, err, "does not match")
+
+	// assemble it and check that the result verifies
+	tok = New(cfg, [][]byte{sig})
+	defer func() { assert.Nil(t, tok.Pending()) }()
+	patch, err := binpatch.Load(signPE(t, tok, now))
+	require.NoError(t, err)
+	infile, err := os.Open(pePath)
+	require.NoError(t, err)
+	defer infile.Close()
+	signed := filepath.Join(t.TempDir(), "signed.exe")
+	require.NoError(t, patch.Apply(infile, signed))
+	blob, err := os.ReadFile(signed)
+	require.NoError(t, err)
+	assert.True(t, bytes.Contains(blob, sig))
+	outfile, err := os.Open(signed)
+	require.NoError(t, err)
+	defer outfile.Close()
+	sigs, err := pecoff.PeSigner.Verify(outfile, signers.VerifyOpts{NoChain: true})
+	require.NoError(t, err)
+	require.Len(t, sigs, 1)
+}
@@ -30,6 +30,7 @@ import (
 	_ "github.com/mind-security/relic/v8/token/azuretoken"
 	_ "github.com/mind-security/relic/v8/token/filetoken"
 	_ "github.com/mind-security/relic/v8/token/gcloudtoken"
+	_ "github.com/mind-security/relic/v8/token/pkcs12token"
 	_ "github.com/mind-security/relic/v8/token/scdtoken"
 )
 
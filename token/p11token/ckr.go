@@ -0,0 +1,100 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package p11token
+
+import (
+	"errors"
+
+	"github.com/miekg/pkcs11"
+
+	"github.com/mind-security/relic/v8/token"
+)
+
+type ckrInfo struct {
+	name string
+	hint string
+	// transient errors might succeed on retry, typically with a new session
+	transient bool
+	// fatal errors need an operator to fix the token or its configuration
+	fatal bool
+}
+
+// common return values from PKCS#11 providers, and what to do about them
+var ckrErrors = map[pkcs11.Error]ckrInfo{
+	pkcs11.CKR_PIN_INCORRECT: {name: "CKR_PIN_INCORRECT",
+		fatal: true, hint: "the configured PIN is wrong; correct it before the token locks"},
+	pkcs11.CKR_PIN_LOCKED: {name: "CKR_PIN_LOCKED",
+		fatal: true, hint: "the PIN is locked after too many failed logins; unlock it with the security officer PIN"},
+	pkcs11.CKR_PIN_EXPIRED: {name: "CKR_PIN_EXPIRED",
+		fatal: true, hint: "the PIN has expired; set a new one with the token's management tools"},
+	pkcs11.CKR_TOKEN_NOT_PRESENT: {name: "CKR_TOKEN_NOT_PRESENT",
+		fatal: true, hint: "the token is not in its slot; check that it is connected"},
+	pkcs11.CKR_TOKEN_NOT_RECOGNIZED: {name: "CKR_TOKEN_NOT_RECOGNIZED",
+		fatal: true, hint: "the provider does not recognize the token; check that the provider library matches the device"},
+	pkcs11.CKR_DEVICE_REMOVED: {name: "CKR_DEVICE_REMOVED",
+		fatal: true, hint: "the token was removed while in use; reconnect it"},
+	pkcs11.CKR_CRYPTOKI_NOT_INITIALIZED: {name: "CKR_CRYPTOKI_NOT_INITIALIZED",
+		fatal: true, hint: "the provider library lost its state; restart relic"},
+	pkcs11.CKR_LIBRARY_LOAD_FAILED: {name: "CKR_LIBRARY_LOAD_FAILED",
+		fatal: true, hint: "the provider could not load a dependency; check the provider installation"},
+	pkcs11.CKR_DEVICE_ERROR: {name: "CKR_DEVICE_ERROR",
+		transient: true, hint: "the token reported a hardware fault; check its logs if this persists"},
+	pkcs11.CKR_DEVICE_MEMORY: {name: "CKR_DEVICE_MEMORY",
+		transient: true, hint: "the token is out of memory; reduce max_sessions or the load on the token"},
+	pkcs11.CKR_HOST_MEMORY: {name: "CKR_HOST_MEMORY",
+		transient: true, hint: "the provider library is out of memory"},
+	pkcs11.CKR_GENERAL_ERROR: {name: "CKR_GENERAL_ERROR",
+		transient: true, hint: "the provider reported an unspecified failure; check its logs if this persists"},
+	pkcs11.CKR_FUNCTION_FAILED: {name: "CKR_FUNCTION_FAILED",
+		transient: true, hint: "the token could not complete the operation; check its logs if this persists"},
+	pkcs11.CKR_SESSION_COUNT: {name: "CKR_SESSION_COUNT",
+		transient: true, hint: "the token has no free sessions; lower max_sessions or stop other clients of the token"},
+	pkcs11.CKR_SESSION_CLOSED: {name: "CKR_SESSION_CLOSED",
+		transient: true, hint: "the session was closed by the token and will be reopened"},
+	pkcs11.CKR_SESSION_HANDLE_INVALID: {name: "CKR_SESSION_HANDLE_INVALID",
+		transient: true, hint: "the session is no longer valid and will be reopened"},
+	pkcs11.CKR_USER_NOT_LOGGED_IN: {name: "CKR_USER_NOT_LOGGED_IN",
+		transient: true, hint: "the token forgot the login; consider setting relogin"},
+	pkcs11.CKR_KEY_HANDLE_INVALID: {name: "CKR_KEY_HANDLE_INVALID",
+		hint: "the key was deleted or replaced on the token; check the key's label and id"},
+	pkcs11.CKR_KEY_FUNCTION_NOT_PERMITTED: {name: "CKR_KEY_FUNCTION_NOT_PERMITTED",
+		hint: "the key's attributes do not allow signing; check CKA_SIGN on the private key"},
+	pkcs11.CKR_MECHANISM_INVALID: {name: "CKR_MECHANISM_INVALID",
+		hint: "the token does not support this signature algorithm for the key"},
+	pkcs11.CKR_MECHANISM_PARAM_INVALID: {name: "CKR_MECHANISM_PARAM_INVALID",
+		hint: "the token rejected the signature parameters, such as the PSS salt length"},
+}
+
+// wrapError attaches a stable code and remediation hint to well-known PKCS#11
+// errors. Other errors are returned unchanged.
+func wrapError(err error) error {
+	var rv pkcs11.Error
+	if !errors.As(err, &rv) || errors.As(err, new(token.Error)) {
+		return err
+	}
+	info, ok := ckrErrors[rv]
+	if !ok {
+		return err
+	}
+	return token.Error{
+		Code:      info.name,
+		Hint:      info.hint,
+		Transient: info.transient,
+		Fatal:     info.fatal,
+		Err:       err,
+	}
+}
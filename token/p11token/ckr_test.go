@@ -0,0 +1,40 @@
+package p11token
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/miekg/pkcs11"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mind-security/relic/v8/token"
+)
+
+func TestWrapError(t *testing.T) {
+	err := wrapError(fmt.Errorf("logging in to token again: %w", pkcs11.Error(pkcs11.CKR_PIN_LOCKED)))
+	var tokErr token.Error
+	require.ErrorAs(t, err, &tokErr)
+	assert.Equal(t, "CKR_PIN_LOCKED", tokErr.Code)
+	assert.True(t, tokErr.Fatal)
+	assert.False(t, tokErr.Temporary())
+	assert.Contains(t, err.Error(), "security officer PIN")
+	// callers checking for specific return values still see them
+	var rv pkcs11.Error
+	require.ErrorAs(t, err, &rv)
+	assert.Equal(t, pkcs11.Error(pkcs11.CKR_PIN_LOCKED), rv)
+	assert.Equal(t, err, wrapError(err))
+
+	err = wrapError(pkcs11.Error(pkcs11.CKR_SESSION_HANDLE_INVALID))
+	require.ErrorAs(t, err, &tokErr)
+	assert.True(t, tokErr.Temporary())
+	assert.True(t, isSessionError(err))
+
+	// anything else passes through
+	other := pkcs11.Error(pkcs11.CKR_ARGUMENTS_BAD)
+	assert.Equal(t, error(other), wrapError(other))
+	plain := errors.New("no token found")
+	assert.Equal(t, plain, wrapError(plain))
+	assert.Nil(t, wrapError(nil))
+}
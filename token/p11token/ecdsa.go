@@ -46,13 +46,13 @@ func (key *Key) toEcdsaKey() (crypto.PublicKey, error) {
 }
 
 // Sign a digest using token ECDSA private key
-func (key *Key) signECDSA(digest []byte) (der []byte, err error) {
+func (key *Key) signECDSA(sh pkcs11.SessionHandle, digest []byte) (der []byte, err error) {
 	mech := pkcs11.NewMechanism(pkcs11.CKM_ECDSA, nil)
-	err = key.token.ctx.SignInit(key.token.sh, []*pkcs11.Mechanism{mech}, key.priv)
+	err = key.token.signInit(sh, mech, key.priv)
 	if err != nil {
 		return nil, err
 	}
-	sig, err := key.token.ctx.Sign(key.token.sh, digest)
+	sig, err := key.token.ctx.Sign(sh, digest)
 	if err != nil {
 		return nil, err
 	}
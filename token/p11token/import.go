@@ -159,9 +159,17 @@ func (tok *Token) Generate(keyName string, keyType token.KeyType, bits uint) (to
 	if keyConf.Label == "" {
 		return nil, errors.New("Key attribute 'label' must be defined in order to create an object")
 	}
-	keyID := makeKeyID()
-	if keyID == nil {
-		return nil, errors.New("failed to make key ID")
+	var keyID []byte
+	if keyConf.ID != "" {
+		keyID, err = parseKeyID(keyConf.ID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key ID: %w", err)
+		}
+	} else {
+		keyID = makeKeyID()
+		if keyID == nil {
+			return nil, errors.New("failed to make key ID")
+		}
 	}
 	var pubTypeAttrs []*pkcs11.Attribute
 	var mech *pkcs11.Mechanism
@@ -19,7 +19,6 @@ package p11token
 import (
 	"context"
 	"crypto"
-	"crypto/rand"
 	"errors"
 	"fmt"
 	"io"
@@ -49,7 +48,11 @@ func (token *Token) GetKey(ctx context.Context, keyName string) (token.Key, erro
 	if err != nil {
 		return nil, err
 	}
-	return token.getKey(keyConf, keyName)
+	key, err := token.getKey(keyConf, keyName)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	return key, nil
 }
 
 func (token *Token) getKey(keyConf *config.KeyConfig, keyName string) (*Key, error) {
@@ -104,6 +107,15 @@ func (token *Token) findKey(keyConf *config.KeyConfig, class uint) (pkcs11.Objec
 		}
 		attrs = append(attrs, pkcs11.NewAttribute(pkcs11.CKA_ID, keyID))
 	}
+	// keys that don't select an object themselves can use the token's URI
+	if u := token.tokenConf.PKCS11URI(); u != nil && keyConf.Label == "" && keyConf.ID == "" {
+		if u.Object != "" {
+			attrs = append(attrs, pkcs11.NewAttribute(pkcs11.CKA_LABEL, u.Object))
+		}
+		if u.ID != nil {
+			attrs = append(attrs, pkcs11.NewAttribute(pkcs11.CKA_ID, u.ID))
+		}
+	}
 	objects, err := token.findObject(attrs)
 	if err != nil {
 		return 0, err
@@ -140,18 +152,47 @@ func (key *Key) GetID() []byte {
 }
 
 func (key *Key) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
-	key.token.mutex.Lock()
-	defer key.token.mutex.Unlock()
+	return key.SignContext(context.Background(), digest, opts)
+}
+
+func (key *Key) SignContext(ctx context.Context, digest []byte, opts crypto.SignerOpts) (sig []byte, err error) {
+	if key.token.pool == nil {
+		key.token.mutex.Lock()
+		defer key.token.mutex.Unlock()
+		sig, err = key.signRelogin(key.token.sh, digest, opts)
+		return sig, wrapError(err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, key.keyConf.GetTimeout())
+	defer cancel()
+	sh, err := key.token.pool.get(ctx)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	defer func() { key.token.pool.put(sh, err) }()
+	sig, err = key.signRelogin(sh, digest, opts)
+	return sig, wrapError(err)
+}
+
+// signRelogin signs, and if the token has forgotten the login state then logs
+// in again and retries once unless the token is configured not to
+func (key *Key) signRelogin(sh pkcs11.SessionHandle, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	sig, err := key.sign(sh, digest, opts)
+	if !isNotLoggedIn(err) || key.token.tokenConf.Relogin == config.ReloginNever || key.token.tokenConf.LoginMode == config.LoginPublic {
+		return sig, err
+	}
+	if lerr := key.token.loginAgain(sh); lerr != nil {
+		return nil, lerr
+	}
+	return key.sign(sh, digest, opts)
+}
+
+func (key *Key) sign(sh pkcs11.SessionHandle, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
 	switch key.keyType {
 	case CKK_RSA:
-		return key.signRSA(digest, opts)
+		return key.signRSA(sh, digest, opts)
 	case CKK_ECDSA:
-		return key.signECDSA(digest)
+		return key.signECDSA(sh, digest)
 	default:
 		return nil, errors.New("Unsupported key type")
 	}
 }
-
-func (key *Key) SignContext(ctx context.Context, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
-	return key.Sign(rand.Reader, digest, opts)
-}
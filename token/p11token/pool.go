@@ -0,0 +1,142 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package p11token
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/miekg/pkcs11"
+)
+
+const defaultMaxSessions = 8
+
+var errPoolClosed = errors.New("token is closed")
+
+// sessionPool hands out PKCS#11 sessions for signing. Sessions are opened on
+// demand up to a limit, checked before each use, and discarded if the token
+// reports that they are no longer usable.
+type sessionPool struct {
+	open  func() (pkcs11.SessionHandle, error)
+	close func(pkcs11.SessionHandle)
+	// check returns an error if the session can't be used. It may log the
+	// session in again.
+	check func(pkcs11.SessionHandle) error
+
+	slots  chan struct{}
+	mu     sync.Mutex
+	idle   []pkcs11.SessionHandle
+	closed bool
+}
+
+func newSessionPool(max int) *sessionPool {
+	if max <= 0 {
+		max = defaultMaxSessions
+	}
+	return &sessionPool{slots: make(chan struct{}, max)}
+}
+
+// get returns a session that is ready to use, waiting until one is available
+// or ctx is done. The session must be returned with put.
+func (p *sessionPool) get(ctx context.Context) (pkcs11.SessionHandle, error) {
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return 0, fmt.Errorf("waiting for a token session: %w", ctx.Err())
+	}
+	for {
+		sh, fresh, err := p.take()
+		if err != nil {
+			<-p.slots
+			return 0, err
+		}
+		if err := p.check(sh); err != nil {
+			p.close(sh)
+			if fresh {
+				<-p.slots
+				return 0, err
+			}
+			// stale session, try the next one
+			continue
+		}
+		return sh, nil
+	}
+}
+
+// take pops an idle session or opens a new one
+func (p *sessionPool) take() (sh pkcs11.SessionHandle, fresh bool, err error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return 0, false, errPoolClosed
+	}
+	if n := len(p.idle); n > 0 {
+		sh = p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.mu.Unlock()
+		return sh, false, nil
+	}
+	p.mu.Unlock()
+	sh, err = p.open()
+	return sh, true, err
+}
+
+// put returns a session to the pool. If the operation that used it failed in
+// a way that suggests the session is broken then it is closed instead.
+func (p *sessionPool) put(sh pkcs11.SessionHandle, opErr error) {
+	p.mu.Lock()
+	if p.closed || isSessionError(opErr) {
+		p.mu.Unlock()
+		p.close(sh)
+	} else {
+		p.idle = append(p.idle, sh)
+		p.mu.Unlock()
+	}
+	<-p.slots
+}
+
+// closeAll closes idle sessions. Sessions that are in use are closed when they
+// are returned.
+func (p *sessionPool) closeAll() {
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.closed = true
+	p.mu.Unlock()
+	for _, sh := range idle {
+		p.close(sh)
+	}
+}
+
+func isSessionError(err error) bool {
+	var rv pkcs11.Error
+	if !errors.As(err, &rv) {
+		return false
+	}
+	switch rv {
+	case pkcs11.CKR_SESSION_HANDLE_INVALID,
+		pkcs11.CKR_SESSION_CLOSED,
+		pkcs11.CKR_USER_NOT_LOGGED_IN,
+		pkcs11.CKR_DEVICE_ERROR,
+		pkcs11.CKR_DEVICE_REMOVED,
+		pkcs11.CKR_TOKEN_NOT_PRESENT:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+package p11token
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/miekg/pkcs11"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSessions struct {
+	next   pkcs11.SessionHandle
+	open   map[pkcs11.SessionHandle]bool
+	broken map[pkcs11.SessionHandle]bool
+}
+
+func newTestPool(max int) (*sessionPool, *fakeSessions) {
+	f := &fakeSessions{
+		open:   make(map[pkcs11.SessionHandle]bool),
+		broken: make(map[pkcs11.SessionHandle]bool),
+	}
+	p := newSessionPool(max)
+	p.open = func() (pkcs11.SessionHandle, error) {
+		f.next++
+		f.open[f.next] = true
+		return f.next, nil
+	}
+	p.close = func(sh pkcs11.SessionHandle) { delete(f.open, sh) }
+	p.check = func(sh pkcs11.SessionHandle) error {
+		if f.broken[sh] {
+			return pkcs11.Error(pkcs11.CKR_SESSION_HANDLE_INVALID)
+		}
+		return nil
+	}
+	return p, f
+}
+
+func TestSessionPoolLimit(t *testing.T) {
+	p, f := newTestPool(2)
+	ctx := context.Background()
+	s1, err := p.get(ctx)
+	require.NoError(t, err)
+	s2, err := p.get(ctx)
+	require.NoError(t, err)
+	assert.NotEqual(t, s1, s2)
+	assert.Len(t, f.open, 2)
+	// a third caller waits until the deadline
+	tctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err = p.get(tctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	// returned sessions are reused
+	p.put(s1, nil)
+	s3, err := p.get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, s1, s3)
+	assert.Len(t, f.open, 2)
+}
+
+func TestSessionPoolEviction(t *testing.T) {
+	p, f := newTestPool(2)
+	ctx := context.Background()
+	s1, err := p.get(ctx)
+	require.NoError(t, err)
+	p.put(s1, nil)
+	// a session that goes stale while idle is replaced
+	f.broken[s1] = true
+	s2, err := p.get(ctx)
+	require.NoError(t, err)
+	assert.NotEqual(t, s1, s2)
+	assert.False(t, f.open[s1])
+	// a session that fails an operation is discarded
+	p.put(s2, pkcs11.Error(pkcs11.CKR_DEVICE_ERROR))
+	assert.False(t, f.open[s2])
+	// but other errors don't affect the session
+	s3, err := p.get(ctx)
+	require.NoError(t, err)
+	p.put(s3, errors.New("bad digest"))
+	assert.True(t, f.open[s3])
+	// a new session that can't be used is an error
+	p.check = func(pkcs11.SessionHandle) error { return errors.New("token not logged in") }
+	_, err = p.get(ctx)
+	assert.EqualError(t, err, "token not logged in")
+	assert.Empty(t, f.open)
+}
+
+func TestSessionPoolClose(t *testing.T) {
+	p, f := newTestPool(0)
+	ctx := context.Background()
+	s1, err := p.get(ctx)
+	require.NoError(t, err)
+	s2, err := p.get(ctx)
+	require.NoError(t, err)
+	p.put(s1, nil)
+	p.closeAll()
+	assert.Equal(t, map[pkcs11.SessionHandle]bool{s2: true}, f.open)
+	p.put(s2, nil)
+	assert.Empty(t, f.open)
+	_, err = p.get(ctx)
+	assert.ErrorIs(t, err, errPoolClosed)
+}
@@ -23,10 +23,12 @@ import (
 	"io"
 	"runtime"
 	"sync"
+	"sync/atomic"
 
 	"github.com/miekg/pkcs11"
 
 	"github.com/mind-security/relic/v8/config"
+	"github.com/mind-security/relic/v8/lib/audit"
 	"github.com/mind-security/relic/v8/lib/passprompt"
 	"github.com/mind-security/relic/v8/signers/sigerrors"
 	"github.com/mind-security/relic/v8/token"
@@ -45,6 +47,8 @@ const (
 
 	CKK_RSA   = pkcs11.CKK_RSA
 	CKK_ECDSA = pkcs11.CKK_ECDSA
+
+	CKU_CONTEXT_SPECIFIC = 2
 )
 
 func init() {
@@ -61,6 +65,15 @@ type Token struct {
 	ctx       *pkcs11.Ctx
 	sh        pkcs11.SessionHandle
 	mutex     sync.Mutex
+	pool      *sessionPool
+	// credentials from the last successful login, to log in again if the
+	// token forgets the login state or wants each operation authenticated
+	creds atomic.Pointer[credentials]
+}
+
+type credentials struct {
+	user uint
+	pin  string
 }
 
 func List(provider string, output io.Writer) error {
@@ -111,14 +124,20 @@ func Open(config *config.Config, tokenName string, pinProvider passprompt.Passwo
 	sh, err := tok.ctx.OpenSession(slot, mode)
 	if err != nil {
 		tok.Close()
-		return nil, err
+		return nil, wrapError(err)
 	}
 	tok.sh = sh
 	err = tok.autoLogIn(pinProvider)
 	if err != nil {
 		tok.Close()
-		return nil, err
+		return nil, wrapError(err)
 	}
+	// signing uses its own sessions so that one going bad doesn't take the
+	// token down with it
+	tok.pool = newSessionPool(tokenConf.MaxSessions)
+	tok.pool.open = func() (pkcs11.SessionHandle, error) { return ctx.OpenSession(slot, mode) }
+	tok.pool.close = func(sh pkcs11.SessionHandle) { _ = ctx.CloseSession(sh) }
+	tok.pool.check = tok.checkSession
 	return tok, nil
 }
 
@@ -158,11 +177,16 @@ func (tok *Token) Close() error {
 	tok.mutex.Lock()
 	defer tok.mutex.Unlock()
 	var err error
+	if tok.pool != nil {
+		tok.pool.closeAll()
+	}
 	if tok.ctx != nil {
 		err = tok.ctx.CloseSession(tok.sh)
 		tok.ctx = nil
 		runtime.SetFinalizer(tok, nil)
+		token.AuditEvent(tok.tokenConf, audit.EventLogout, "session closed")
 	}
+	tok.tokenConf.ClearPin()
 	return err
 }
 
@@ -176,8 +200,17 @@ func (tok *Token) findSlot() (uint, error) {
 	if err != nil {
 		return 0, nil
 	}
+	label, serial, slotID := tokenConf.Label, tokenConf.Serial, tokenConf.Slot
+	var manufacturer, model string
+	if u := tokenConf.PKCS11URI(); u != nil {
+		label, serial, slotID = u.Token, u.Serial, u.SlotID
+		manufacturer, model = u.Manufacturer, u.Model
+	}
 	candidates := make([]uint, 0, len(slots))
 	for _, slot := range slots {
+		if slotID != nil && *slotID != slot {
+			continue
+		}
 		info, err := tok.ctx.GetTokenInfo(slot)
 		if err != nil {
 			if rv, ok := err.(pkcs11.Error); ok && rv == pkcs11.CKR_TOKEN_NOT_PRESENT {
@@ -185,9 +218,13 @@ func (tok *Token) findSlot() (uint, error) {
 			}
 			return 0, err
 		}
-		if tokenConf.Label != "" && tokenConf.Label != info.Label {
+		if label != "" && label != info.Label {
+			continue
+		} else if serial != "" && serial != info.SerialNumber {
+			continue
+		} else if manufacturer != "" && manufacturer != info.ManufacturerID {
 			continue
-		} else if tokenConf.Serial != "" && tokenConf.Serial != info.SerialNumber {
+		} else if model != "" && model != info.Model {
 			continue
 		}
 		candidates = append(candidates, slot)
@@ -209,16 +246,35 @@ func (tok *Token) isLoggedIn() (bool, error) {
 	if err != nil {
 		return false, err
 	}
-	return (info.State == CKS_RO_USER_FUNCTIONS || info.State == CKS_RW_USER_FUNCTIONS || info.State == CKS_RW_SO_FUNCTIONS), nil
+	return tok.loggedInState(info.State), nil
+}
+
+// loggedInState returns true if a session in the given state can use the
+// token's keys
+func (tok *Token) loggedInState(state uint) bool {
+	switch state {
+	case CKS_RO_USER_FUNCTIONS, CKS_RW_USER_FUNCTIONS, CKS_RW_SO_FUNCTIONS:
+		return true
+	}
+	return tok.tokenConf.LoginMode == config.LoginPublic
 }
 
 func (tok *Token) Ping(ctx context.Context) error {
-	loggedIn, err := tok.isLoggedIn()
+	if tok.pool == nil {
+		loggedIn, err := tok.isLoggedIn()
+		if err != nil {
+			return wrapError(err)
+		} else if !loggedIn {
+			return errors.New("token not logged in")
+		}
+		return nil
+	}
+	// checking out a session validates it, and replaces it if necessary
+	sh, err := tok.pool.get(ctx)
 	if err != nil {
-		return err
-	} else if !loggedIn {
-		return errors.New("token not logged in")
+		return wrapError(err)
 	}
+	tok.pool.put(sh, nil)
 	return nil
 }
 
@@ -226,21 +282,85 @@ func (tok *Token) login(user uint, pin string) error {
 	tok.mutex.Lock()
 	defer tok.mutex.Unlock()
 	err := tok.ctx.Login(tok.sh, user, pin)
+	if rv, ok := err.(pkcs11.Error); ok && rv == pkcs11.CKR_USER_ALREADY_LOGGED_IN {
+		err = nil
+	}
 	if err != nil {
 		if rv, ok := err.(pkcs11.Error); ok && rv == pkcs11.CKR_PIN_INCORRECT {
 			return sigerrors.PinIncorrectError{}
 		}
+		return err
 	}
-	return err
+	tok.creds.Store(&credentials{user: user, pin: pin})
+	return nil
+}
+
+// Check that a pooled session is alive, and log in again if the token has
+// forgotten the login state
+func (tok *Token) checkSession(sh pkcs11.SessionHandle) error {
+	info, err := tok.ctx.GetSessionInfo(sh)
+	if err != nil {
+		return err
+	}
+	if tok.loggedInState(info.State) {
+		return nil
+	}
+	return tok.loginAgain(sh)
+}
+
+// loginAgain logs in using the credentials from the last successful login
+func (tok *Token) loginAgain(sh pkcs11.SessionHandle) error {
+	creds := tok.creds.Load()
+	if creds == nil {
+		return errors.New("token not logged in")
+	}
+	err := tok.ctx.Login(sh, creds.user, creds.pin)
+	if rv, ok := err.(pkcs11.Error); ok && rv == pkcs11.CKR_USER_ALREADY_LOGGED_IN {
+		err = nil
+	}
+	if err != nil {
+		return fmt.Errorf("logging in to token again: %w", err)
+	}
+	token.AuditEvent(tok.tokenConf, audit.EventLogin, "session restored")
+	return nil
+}
+
+// signInit starts a signing operation, authenticating it separately if the
+// token requires context-specific login
+func (tok *Token) signInit(sh pkcs11.SessionHandle, mech *pkcs11.Mechanism, priv pkcs11.ObjectHandle) error {
+	if err := tok.ctx.SignInit(sh, []*pkcs11.Mechanism{mech}, priv); err != nil {
+		return err
+	}
+	if tok.tokenConf.LoginMode != config.LoginContext {
+		return nil
+	}
+	creds := tok.creds.Load()
+	if creds == nil {
+		return errors.New("token not logged in")
+	}
+	if err := tok.ctx.Login(sh, CKU_CONTEXT_SPECIFIC, creds.pin); err != nil {
+		return fmt.Errorf("context-specific login: %w", err)
+	}
+	return nil
+}
+
+// isNotLoggedIn returns true if err means the token forgot the login state
+func isNotLoggedIn(err error) bool {
+	var rv pkcs11.Error
+	return errors.As(err, &rv) && rv == pkcs11.CKR_USER_NOT_LOGGED_IN
 }
 
 func (tok *Token) autoLogIn(pinProvider passprompt.PasswordGetter) error {
 	tokenConf := tok.tokenConf
+	if tokenConf.LoginMode == config.LoginPublic {
+		return nil
+	}
 	loggedIn, err := tok.isLoggedIn()
 	if err != nil {
 		return err
 	}
-	if loggedIn {
+	if loggedIn && tokenConf.LoginMode != config.LoginContext {
+		// context-specific login still needs the PIN, so keep going
 		return nil
 	}
 	var user uint = pkcs11.CKU_USER
@@ -0,0 +1,28 @@
+package p11token
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/miekg/pkcs11"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mind-security/relic/v8/config"
+)
+
+func TestLoggedInState(t *testing.T) {
+	tok := &Token{tokenConf: &config.TokenConfig{LoginMode: config.LoginSession}}
+	assert.True(t, tok.loggedInState(CKS_RW_USER_FUNCTIONS))
+	assert.False(t, tok.loggedInState(CKS_RW_PUBLIC_SESSION))
+	tok.tokenConf.LoginMode = config.LoginPublic
+	assert.True(t, tok.loggedInState(CKS_RW_PUBLIC_SESSION))
+}
+
+func TestIsNotLoggedIn(t *testing.T) {
+	assert.True(t, isNotLoggedIn(pkcs11.Error(pkcs11.CKR_USER_NOT_LOGGED_IN)))
+	assert.True(t, isNotLoggedIn(fmt.Errorf("signing: %w", pkcs11.Error(pkcs11.CKR_USER_NOT_LOGGED_IN))))
+	assert.False(t, isNotLoggedIn(pkcs11.Error(pkcs11.CKR_PIN_INCORRECT)))
+	assert.False(t, isNotLoggedIn(errors.New("other")))
+	assert.False(t, isNotLoggedIn(nil))
+}
@@ -0,0 +1,169 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package pkcs12token
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"software.sslmate.com/src/go-pkcs12"
+
+	"github.com/mind-security/relic/v8/config"
+	"github.com/mind-security/relic/v8/lib/passprompt"
+	"github.com/mind-security/relic/v8/token"
+)
+
+const tokenType = "pkcs12"
+
+func init() {
+	token.Openers[tokenType] = Open
+}
+
+// pkcs12Token holds the single key and certificate chain from a PKCS#12
+// (.p12 or .pfx) file, decrypted using the token's PIN
+type pkcs12Token struct {
+	config    *config.Config
+	tokenConf *config.TokenConfig
+	signer    crypto.Signer
+	// DER certificates, leaf first
+	certs []byte
+}
+
+type pkcs12Key struct {
+	keyConf *config.KeyConfig
+	signer  crypto.Signer
+	cert    []byte
+}
+
+func Open(conf *config.Config, tokenName string, prompt passprompt.PasswordGetter) (token.Token, error) {
+	tconf, err := conf.GetToken(tokenName)
+	if err != nil {
+		return nil, err
+	}
+	if tconf.KeyFile == "" {
+		return nil, fmt.Errorf("token \"%s\" needs a KeyFile setting", tokenName)
+	}
+	blob, err := os.ReadFile(tconf.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	tok := &pkcs12Token{
+		config:    conf,
+		tokenConf: tconf,
+	}
+	loginFunc := func(pin string) (bool, error) {
+		// files exported from Windows are typically encrypted with RC2 or
+		// 3DES, which the decoder handles alongside PBES2
+		priv, leaf, chain, err := pkcs12.DecodeChain(blob, pin)
+		if errors.Is(err, pkcs12.ErrIncorrectPassword) {
+			return false, nil
+		} else if err != nil {
+			return false, fmt.Errorf("%s: %w", tconf.KeyFile, err)
+		}
+		signer, ok := priv.(crypto.Signer)
+		if !ok {
+			return false, fmt.Errorf("%s: unsupported private key type %T", tconf.KeyFile, priv)
+		}
+		tok.signer = signer
+		for _, cert := range append([]*x509.Certificate{leaf}, chain...) {
+			tok.certs = append(tok.certs, cert.Raw...)
+		}
+		return true, nil
+	}
+	initialPrompt := fmt.Sprintf("Password for %s: ", tconf.KeyFile)
+	if err := token.Login(tconf, prompt, loginFunc, tokenName, initialPrompt); err != nil {
+		return nil, err
+	}
+	return tok, nil
+}
+
+func (tok *pkcs12Token) Ping(context.Context) error {
+	return nil
+}
+
+func (tok *pkcs12Token) Close() error {
+	return nil
+}
+
+func (tok *pkcs12Token) Config() *config.TokenConfig {
+	return tok.tokenConf
+}
+
+func (tok *pkcs12Token) ListKeys(opts token.ListOptions) error {
+	return token.NotImplementedError{Op: "list-keys", Type: tokenType}
+}
+
+// GetKey returns the file's key for any key section that uses this token
+func (tok *pkcs12Token) GetKey(ctx context.Context, keyName string) (token.Key, error) {
+	keyConf, err := tok.config.GetKey(keyName)
+	if err != nil {
+		return nil, err
+	}
+	return &pkcs12Key{
+		keyConf: keyConf,
+		signer:  tok.signer,
+		cert:    tok.certs,
+	}, nil
+}
+
+func (tok *pkcs12Token) Import(keyName string, privKey crypto.PrivateKey) (token.Key, error) {
+	return nil, token.NotImplementedError{Op: "import-key", Type: tokenType}
+}
+
+func (tok *pkcs12Token) ImportCertificate(cert *x509.Certificate, labelBase string) error {
+	return token.NotImplementedError{Op: "import-certificate", Type: tokenType}
+}
+
+func (tok *pkcs12Token) Generate(keyName string, keyType token.KeyType, bits uint) (token.Key, error) {
+	return nil, token.NotImplementedError{Op: "generate-key", Type: tokenType}
+}
+
+func (key *pkcs12Key) Public() crypto.PublicKey {
+	return key.signer.Public()
+}
+
+func (key *pkcs12Key) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return key.signer.Sign(rand, digest, opts)
+}
+
+func (key *pkcs12Key) SignContext(ctx context.Context, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return key.signer.Sign(rand.Reader, digest, opts)
+}
+
+func (key *pkcs12Key) Config() *config.KeyConfig {
+	return key.keyConf
+}
+
+// Certificate returns the certificate chain from the file, so CMS-based
+// signatures include it without an x509certificate setting
+func (key *pkcs12Key) Certificate() []byte {
+	return key.cert
+}
+
+func (key *pkcs12Key) GetID() []byte {
+	return nil
+}
+
+func (key *pkcs12Key) ImportCertificate(cert *x509.Certificate) error {
+	return token.NotImplementedError{Op: "import-certificate", Type: tokenType}
+}
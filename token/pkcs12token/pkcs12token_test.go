@@ -0,0 +1,99 @@
+package pkcs12token
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"software.sslmate.com/src/go-pkcs12"
+
+	"github.com/mind-security/relic/v8/config"
+	"github.com/mind-security/relic/v8/lib/certloader"
+	"github.com/mind-security/relic/v8/signers/sigerrors"
+)
+
+func issue(t *testing.T, name string, parent *x509.Certificate, parentKey crypto.Signer) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		BasicConstraintsValid: true,
+		IsCA:                  parent == nil,
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert, key
+}
+
+func TestOpen(t *testing.T) {
+	root, rootKey := issue(t, "root", nil, nil)
+	leaf, leafKey := issue(t, "leaf", root, rootKey)
+	encoders := map[string]*pkcs12.Encoder{
+		"rc2":    pkcs12.LegacyRC2,
+		"3des":   pkcs12.LegacyDES,
+		"modern": pkcs12.Modern2023,
+	}
+	for name, enc := range encoders {
+		t.Run(name, func(t *testing.T) {
+			blob, err := enc.Encode(leafKey, leaf, []*x509.Certificate{root}, "hunter2")
+			require.NoError(t, err)
+			path := filepath.Join(t.TempDir(), "signer.pfx")
+			require.NoError(t, os.WriteFile(path, blob, 0600))
+			cfg := new(config.Config)
+			tconf := cfg.NewToken("pfx")
+			tconf.Type = tokenType
+			tconf.KeyFile = path
+			pin := "hunter2"
+			tconf.Pin = &pin
+			cfg.NewKey("signer").Token = "pfx"
+
+			tok, err := Open(cfg, "pfx", nil)
+			require.NoError(t, err)
+			key, err := tok.GetKey(context.Background(), "signer")
+			require.NoError(t, err)
+			digest := sha256.Sum256([]byte("hello"))
+			sig, err := key.SignContext(context.Background(), digest[:], crypto.SHA256)
+			require.NoError(t, err)
+			assert.True(t, ecdsa.VerifyASN1(&leafKey.PublicKey, digest[:], sig))
+
+			// the chain comes along without an x509certificate setting
+			cert, err := certloader.LoadTokenCertificates(key, "", "", key.Certificate())
+			require.NoError(t, err)
+			assert.Equal(t, leaf.Raw, cert.Leaf.Raw)
+			require.Len(t, cert.Certificates, 2)
+			assert.Equal(t, root.Raw, cert.Certificates[1].Raw)
+
+			pin = "wrong"
+			_, err = Open(cfg, "pfx", nil)
+			assert.True(t, errors.As(err, new(sigerrors.PinIncorrectError)), "got %v", err)
+		})
+	}
+}
+
+func TestOpenNoKeyFile(t *testing.T) {
+	cfg := new(config.Config)
+	cfg.NewToken("pfx").Type = tokenType
+	_, err := Open(cfg, "pfx", nil)
+	assert.ErrorContains(t, err, "needs a KeyFile setting")
+}
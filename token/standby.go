@@ -0,0 +1,216 @@
+//
+// Copyright (c) SAS Institute Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package token
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+
+	"golang.org/x/sync/singleflight"
+
+	"github.com/mind-security/relic/v8/config"
+)
+
+// LoadReplicaFunc loads one regional replica of a cloud KMS key by its ID
+type LoadReplicaFunc func(ctx context.Context, id string) (Key, error)
+
+// UnavailableFunc reports whether an error means that a replica's region could
+// not be reached, as opposed to a problem with the request itself
+type UnavailableFunc func(error) bool
+
+// StandbyMismatchError is returned when a standby replica doesn't hold the same
+// public key as the replica that was loaded first
+type StandbyMismatchError struct {
+	Key, ID string
+}
+
+func (e StandbyMismatchError) Error() string {
+	return fmt.Sprintf("key %q: standby %s has a different public key than the primary", e.Key, e.ID)
+}
+
+// IsUnavailable is the default UnavailableFunc. It treats network errors and
+// HTTP 5xx responses as a region outage.
+func IsUnavailable(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var statusErr interface{ HTTPStatusCode() int }
+	if errors.As(err, &statusErr) {
+		return statusErr.HTTPStatusCode() >= 500
+	}
+	return false
+}
+
+// GetStandbyKey loads the key named by keyConf.ID. If keyConf.StandbyIDs is
+// set then the result fails over to each standby in turn whenever the active
+// replica's region is unavailable. Each standby must hold the same public key
+// as the first replica loaded, which is checked before it is used.
+func GetStandbyKey(ctx context.Context, keyConf *config.KeyConfig, load LoadReplicaFunc, unavailable UnavailableFunc) (Key, error) {
+	if len(keyConf.StandbyIDs) == 0 {
+		return load(ctx, keyConf.ID)
+	}
+	if unavailable == nil {
+		unavailable = IsUnavailable
+	}
+	k := &standbyKey{
+		kconf:       keyConf,
+		ids:         append([]string{keyConf.ID}, keyConf.StandbyIDs...),
+		load:        load,
+		unavailable: unavailable,
+	}
+	k.replicas = make([]Key, len(k.ids))
+	if _, err := k.do(ctx, func(Key) error { return nil }); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+type standbyKey struct {
+	kconf       *config.KeyConfig
+	ids         []string
+	load        LoadReplicaFunc
+	unavailable UnavailableFunc
+	loading     singleflight.Group
+
+	mu       sync.Mutex
+	replicas []Key
+	active   int
+	pub      crypto.PublicKey
+}
+
+// replica returns the loaded replica at index i, loading it if needed. The
+// load happens outside of the lock so that a region outage doesn't hold up
+// requests to the other replicas.
+func (k *standbyKey) replica(ctx context.Context, i int) (Key, error) {
+	if r := k.loaded(i); r != nil {
+		return r, nil
+	}
+	r, err, _ := k.loading.Do(k.ids[i], func() (interface{}, error) {
+		if r := k.loaded(i); r != nil {
+			// loaded while waiting
+			return r, nil
+		}
+		r, err := k.load(ctx, k.ids[i])
+		if err != nil {
+			return nil, err
+		}
+		k.mu.Lock()
+		defer k.mu.Unlock()
+		if k.pub == nil {
+			k.pub = r.Public()
+		} else if !publicKeysEqual(k.pub, r.Public()) {
+			return nil, StandbyMismatchError{Key: k.kconf.Name(), ID: k.ids[i]}
+		}
+		k.replicas[i] = r
+		return r, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r.(Key), nil
+}
+
+func (k *standbyKey) loaded(i int) Key {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.replicas[i]
+}
+
+// do calls fn with the active replica, failing over to the next one each time
+// a region is unavailable
+func (k *standbyKey) do(ctx context.Context, fn func(Key) error) (Key, error) {
+	k.mu.Lock()
+	start := k.active
+	k.mu.Unlock()
+	var lastErr error
+	for n := 0; n < len(k.ids); n++ {
+		i := (start + n) % len(k.ids)
+		r, err := k.replica(ctx, i)
+		if err == nil {
+			err = fn(r)
+		}
+		if err == nil {
+			k.mu.Lock()
+			if k.active != i {
+				log.Printf("key %q: failed over to %s", k.kconf.Name(), k.ids[i])
+				k.active = i
+			}
+			k.mu.Unlock()
+			return r, nil
+		}
+		if !k.unavailable(err) || ctx.Err() != nil {
+			return nil, err
+		}
+		lastErr = err
+	}
+	return nil, fmt.Errorf("key %q: all replicas unavailable: %w", k.kconf.Name(), lastErr)
+}
+
+func (k *standbyKey) current() Key {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.replicas[k.active]
+}
+
+func (k *standbyKey) Public() crypto.PublicKey {
+	return k.current().Public()
+}
+
+func (k *standbyKey) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return k.SignContext(context.Background(), digest, opts)
+}
+
+func (k *standbyKey) SignContext(ctx context.Context, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	var sig []byte
+	_, err := k.do(ctx, func(r Key) (err error) {
+		sig, err = r.SignContext(ctx, digest, opts)
+		return
+	})
+	return sig, err
+}
+
+func (k *standbyKey) Config() *config.KeyConfig {
+	return k.kconf
+}
+
+func (k *standbyKey) Certificate() []byte {
+	return k.current().Certificate()
+}
+
+func (k *standbyKey) GetID() []byte {
+	return k.current().GetID()
+}
+
+func (k *standbyKey) ImportCertificate(cert *x509.Certificate) error {
+	return k.current().ImportCertificate(cert)
+}
+
+func publicKeysEqual(a, b crypto.PublicKey) bool {
+	eq, ok := a.(interface{ Equal(crypto.PublicKey) bool })
+	return ok && eq.Equal(b)
+}
@@ -0,0 +1,167 @@
+package token
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mind-security/relic/v8/config"
+)
+
+var errRegionDown = &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+
+// mockKMS holds replicas of keys in several regions, some of which may be down
+type mockKMS struct {
+	keys  map[string]*ecdsa.PrivateKey
+	down  map[string]bool
+	signs map[string]int
+}
+
+func (m *mockKMS) load(ctx context.Context, id string) (Key, error) {
+	if m.down[id] {
+		return nil, errRegionDown
+	}
+	priv := m.keys[id]
+	if priv == nil {
+		return nil, errors.New("key not found")
+	}
+	return &mockKey{kms: m, id: id, priv: priv}, nil
+}
+
+type mockKey struct {
+	kms  *mockKMS
+	id   string
+	priv *ecdsa.PrivateKey
+}
+
+func (k *mockKey) Public() crypto.PublicKey { return k.priv.Public() }
+func (k *mockKey) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return k.SignContext(context.Background(), digest, opts)
+}
+func (k *mockKey) SignContext(ctx context.Context, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if k.kms.down[k.id] {
+		return nil, errRegionDown
+	}
+	k.kms.signs[k.id]++
+	return k.priv.Sign(rand.Reader, digest, opts)
+}
+func (k *mockKey) Config() *config.KeyConfig                      { return nil }
+func (k *mockKey) Certificate() []byte                            { return nil }
+func (k *mockKey) GetID() []byte                                  { return []byte(k.id) }
+func (k *mockKey) ImportCertificate(cert *x509.Certificate) error { return nil }
+
+func newMockKMS(t *testing.T) *mockKMS {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	return &mockKMS{
+		keys: map[string]*ecdsa.PrivateKey{
+			"us-east-1/key": priv,
+			"us-west-2/key": priv,
+			"eu-west-1/key": other,
+		},
+		down:  make(map[string]bool),
+		signs: make(map[string]int),
+	}
+}
+
+func TestStandbyFailover(t *testing.T) {
+	ctx := context.Background()
+	m := newMockKMS(t)
+	kconf := &config.KeyConfig{ID: "us-east-1/key", StandbyIDs: []string{"us-west-2/key"}}
+	key, err := GetStandbyKey(ctx, kconf, m.load, nil)
+	require.NoError(t, err)
+	digest := make([]byte, 32)
+
+	_, err = key.SignContext(ctx, digest, crypto.SHA256)
+	require.NoError(t, err)
+	assert.Equal(t, 1, m.signs["us-east-1/key"])
+
+	// primary region goes down, standby takes over
+	m.down["us-east-1/key"] = true
+	sig, err := key.SignContext(ctx, digest, crypto.SHA256)
+	require.NoError(t, err)
+	assert.Equal(t, 1, m.signs["us-west-2/key"])
+	assert.True(t, ecdsa.VerifyASN1(key.Public().(*ecdsa.PublicKey), digest, sig))
+	assert.Equal(t, []byte("us-west-2/key"), key.GetID())
+
+	// everything down
+	m.down["us-west-2/key"] = true
+	_, err = key.SignContext(ctx, digest, crypto.SHA256)
+	assert.ErrorIs(t, err, errRegionDown)
+}
+
+func TestStandbyPrimaryDownAtStartup(t *testing.T) {
+	m := newMockKMS(t)
+	m.down["us-east-1/key"] = true
+	kconf := &config.KeyConfig{ID: "us-east-1/key", StandbyIDs: []string{"us-west-2/key"}}
+	key, err := GetStandbyKey(context.Background(), kconf, m.load, nil)
+	require.NoError(t, err)
+	_, err = key.SignContext(context.Background(), make([]byte, 32), crypto.SHA256)
+	require.NoError(t, err)
+	assert.Equal(t, 1, m.signs["us-west-2/key"])
+}
+
+func TestStandbyMismatch(t *testing.T) {
+	ctx := context.Background()
+	m := newMockKMS(t)
+	kconf := &config.KeyConfig{ID: "us-east-1/key", StandbyIDs: []string{"eu-west-1/key"}}
+	key, err := GetStandbyKey(ctx, kconf, m.load, nil)
+	require.NoError(t, err)
+	m.down["us-east-1/key"] = true
+	_, err = key.SignContext(ctx, make([]byte, 32), crypto.SHA256)
+	assert.ErrorAs(t, err, new(StandbyMismatchError))
+	assert.Zero(t, m.signs["eu-west-1/key"])
+}
+
+func TestStandbyLoadOutsideLock(t *testing.T) {
+	ctx := context.Background()
+	m := newMockKMS(t)
+	release := make(chan struct{})
+	var loads atomic.Int32
+	load := func(ctx context.Context, id string) (Key, error) {
+		if id == "us-east-1/key" {
+			loads.Add(1)
+			<-release
+		}
+		return m.load(ctx, id)
+	}
+	k := &standbyKey{
+		kconf:       &config.KeyConfig{ID: "us-east-1/key", StandbyIDs: []string{"us-west-2/key"}},
+		ids:         []string{"us-east-1/key", "us-west-2/key"},
+		load:        load,
+		unavailable: IsUnavailable,
+		replicas:    make([]Key, 2),
+	}
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := k.replica(ctx, 0)
+			errs <- err
+		}()
+	}
+	require.Eventually(t, func() bool { return loads.Load() == 1 }, time.Second, time.Millisecond)
+	// the other replica is still usable while the first is loading
+	r, err := k.replica(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("us-west-2/key"), r.GetID())
+	close(release)
+	for i := 0; i < 2; i++ {
+		require.NoError(t, <-errs)
+	}
+	assert.Equal(t, int32(1), loads.Load())
+	assert.NotNil(t, k.loaded(0))
+}
@@ -96,3 +96,36 @@ func (e KeyUsageError) Error() string {
 func (e KeyUsageError) Unwrap() error {
 	return e.Err
 }
+
+// Error is a failure reported by a token's driver, carrying a stable code and
+// a hint for the operator on how to resolve it
+type Error struct {
+	// Code is a stable identifier for the failure, such as CKR_PIN_LOCKED
+	Code string
+	Hint string
+	// Transient failures may succeed if the operation is tried again
+	Transient bool
+	// Fatal failures won't go away without intervention, so the token should
+	// be considered unhealthy right away
+	Fatal bool
+	Err   error
+}
+
+func (e Error) Error() string {
+	msg := e.Code
+	if e.Err != nil {
+		msg = e.Err.Error()
+	}
+	if e.Hint != "" {
+		msg += " (" + e.Hint + ")"
+	}
+	return msg
+}
+
+func (e Error) Unwrap() error {
+	return e.Err
+}
+
+func (e Error) Temporary() bool {
+	return e.Transient
+}
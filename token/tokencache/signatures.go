@@ -0,0 +1,165 @@
+package tokencache
+
+import (
+	"container/list"
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/mind-security/relic/v8/token"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var metricSignatureCache = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "token_signature_cache",
+		Help: "Signatures served from the signature cache (hit) or made by the token (miss)",
+	},
+	[]string{"key", "result"},
+)
+
+// Signatures remembers recent signatures made by keys that have
+// CacheSignatures set, so that signing the same digest again returns the
+// earlier signature without another token operation. Entries are bounded in
+// both age and number.
+type Signatures struct {
+	mu      sync.Mutex
+	entries map[[32]byte]*list.Element
+	order   *list.List
+	expiry  time.Duration
+	size    int
+}
+
+type cachedSig struct {
+	id      [32]byte
+	expires time.Time
+	sig     []byte
+}
+
+func NewSignatures(expiry time.Duration, size int) *Signatures {
+	return &Signatures{
+		entries: make(map[[32]byte]*list.Element),
+		order:   list.New(),
+		expiry:  expiry,
+		size:    size,
+	}
+}
+
+// Wrap a token so that signatures made by its keys go through the cache
+func (c *Signatures) Wrap(base token.Token) token.Token {
+	return sigCacheToken{Token: base, cache: c}
+}
+
+// Flush discards all cached signatures and returns how many there were
+func (c *Signatures) Flush() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := len(c.entries)
+	c.entries = make(map[[32]byte]*list.Element)
+	c.order.Init()
+	return n
+}
+
+func (c *Signatures) get(id [32]byte) []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem := c.entries[id]
+	if elem == nil {
+		return nil
+	}
+	cached := elem.Value.(*cachedSig)
+	if time.Now().After(cached.expires) {
+		c.order.Remove(elem)
+		delete(c.entries, id)
+		return nil
+	}
+	return cached.sig
+}
+
+func (c *Signatures) put(id [32]byte, sig []byte) {
+	if c.size <= 0 || c.expiry <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem := c.entries[id]; elem != nil {
+		c.order.Remove(elem)
+	}
+	// oldest entries are at the front and expire first
+	for c.order.Len() >= c.size {
+		oldest := c.order.Front()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cachedSig).id)
+	}
+	c.entries[id] = c.order.PushBack(&cachedSig{
+		id:      id,
+		expires: time.Now().Add(c.expiry),
+		sig:     sig,
+	})
+}
+
+type sigCacheToken struct {
+	token.Token
+	cache *Signatures
+}
+
+func (t sigCacheToken) GetKey(ctx context.Context, keyName string) (token.Key, error) {
+	key, err := t.Token.GetKey(ctx, keyName)
+	if err != nil || !key.Config().CacheSignatures {
+		return key, err
+	}
+	return sigCacheKey{Key: key, cache: t.cache}, nil
+}
+
+type sigCacheKey struct {
+	token.Key
+	cache *Signatures
+}
+
+// cacheID identifies a signing request. The token, key name and key ID are
+// all included so that entries can never be shared between keys.
+func (k sigCacheKey) cacheID(digest []byte, opts crypto.SignerOpts) [32]byte {
+	d := sha256.New()
+	fmt.Fprintf(d, "%s\x00%s\x00%x\x00%T\x00%d\x00", k.Config().Token, k.Config().Name(), k.GetID(), opts, opts.HashFunc())
+	if pss, ok := opts.(*rsa.PSSOptions); ok {
+		fmt.Fprintf(d, "%d\x00", pss.SaltLength)
+	}
+	d.Write(digest)
+	var id [32]byte
+	d.Sum(id[:0])
+	return id
+}
+
+func (k sigCacheKey) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return k.sign(digest, opts, func() ([]byte, error) {
+		return k.Key.Sign(rand, digest, opts)
+	})
+}
+
+func (k sigCacheKey) SignContext(ctx context.Context, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return k.sign(digest, opts, func() ([]byte, error) {
+		return k.Key.SignContext(ctx, digest, opts)
+	})
+}
+
+func (k sigCacheKey) sign(digest []byte, opts crypto.SignerOpts, signFunc func() ([]byte, error)) ([]byte, error) {
+	name := k.Config().Name()
+	id := k.cacheID(digest, opts)
+	if sig := k.cache.get(id); sig != nil {
+		metricSignatureCache.WithLabelValues(name, "hit").Inc()
+		return append([]byte(nil), sig...), nil
+	}
+	metricSignatureCache.WithLabelValues(name, "miss").Inc()
+	sig, err := signFunc()
+	if err != nil {
+		return nil, err
+	}
+	k.cache.put(id, append([]byte(nil), sig...))
+	return sig, nil
+}
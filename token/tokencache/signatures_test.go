@@ -0,0 +1,80 @@
+package tokencache
+
+import (
+	"crypto"
+	"crypto/rand"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mind-security/relic/v8/config"
+	"github.com/mind-security/relic/v8/token"
+)
+
+// countingKey returns a different signature every time the token is used
+type countingKey struct {
+	token.Key
+	conf  *config.KeyConfig
+	calls *int
+}
+
+func (k countingKey) Config() *config.KeyConfig { return k.conf }
+func (k countingKey) GetID() []byte             { return []byte{1} }
+
+func (k countingKey) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	*k.calls++
+	return []byte{byte(*k.calls)}, nil
+}
+
+func newCountingKey(name string) (countingKey, *int) {
+	keyConf := new(config.Config).NewKey(name)
+	keyConf.CacheSignatures = true
+	calls := new(int)
+	return countingKey{conf: keyConf, calls: calls}, calls
+}
+
+func TestSignatureCache(t *testing.T) {
+	cache := NewSignatures(time.Minute, 2)
+	base, calls := newCountingKey("one")
+	key := sigCacheKey{Key: base, cache: cache}
+	sign := func(k sigCacheKey, digest string, opts crypto.SignerOpts) byte {
+		sig, err := k.Sign(rand.Reader, []byte(digest), opts)
+		require.NoError(t, err)
+		return sig[0]
+	}
+
+	first := sign(key, "aaaa", crypto.SHA256)
+	assert.Equal(t, first, sign(key, "aaaa", crypto.SHA256))
+	assert.Equal(t, 1, *calls)
+	// different digest or options
+	assert.NotEqual(t, first, sign(key, "bbbb", crypto.SHA256))
+	assert.NotEqual(t, first, sign(key, "aaaa", crypto.SHA384))
+	assert.Equal(t, 3, *calls)
+	// "aaaa"/SHA256 was evicted to stay within the size limit
+	sign(key, "aaaa", crypto.SHA256)
+	assert.Equal(t, 4, *calls)
+
+	// never shared with another key
+	other, otherCalls := newCountingKey("two")
+	sign(sigCacheKey{Key: other, cache: cache}, "aaaa", crypto.SHA256)
+	assert.Equal(t, 1, *otherCalls)
+
+	assert.Equal(t, 2, cache.Flush())
+	sign(key, "aaaa", crypto.SHA256)
+	assert.Equal(t, 5, *calls)
+}
+
+func TestSignatureCacheExpiry(t *testing.T) {
+	cache := NewSignatures(time.Millisecond, 10)
+	base, calls := newCountingKey("one")
+	key := sigCacheKey{Key: base, cache: cache}
+	_, err := key.Sign(rand.Reader, []byte("aaaa"), crypto.SHA256)
+	require.NoError(t, err)
+	time.Sleep(5 * time.Millisecond)
+	_, err = key.Sign(rand.Reader, []byte("aaaa"), crypto.SHA256)
+	require.NoError(t, err)
+	assert.Equal(t, 2, *calls)
+}
@@ -28,6 +28,7 @@ import (
 
 	"github.com/mind-security/relic/v8/internal/httperror"
 	"github.com/mind-security/relic/v8/internal/workerrpc"
+	"github.com/mind-security/relic/v8/signers/sigerrors"
 	"github.com/mind-security/relic/v8/token"
 	"github.com/mind-security/relic/v8/token/tokencache"
 	"github.com/rs/zerolog/log"
@@ -140,6 +141,16 @@ func (t *WorkerToken) doOnce(req *http.Request, timeout time.Duration) (*workerr
 			Key: rresp.Key,
 			Err: errors.New(rresp.Err),
 		}
+	} else if rresp.NotFound {
+		return nil, sigerrors.KeyNotFoundError{}
+	} else if rresp.Code != "" {
+		// the worker's message already includes the hint
+		return nil, token.Error{
+			Code:      rresp.Code,
+			Transient: rresp.Retryable,
+			Fatal:     rresp.Fatal,
+			Err:       errors.New(rresp.Err),
+		}
 	}
 	return nil, tokenError{Err: rresp.Err, Retryable: rresp.Retryable}
 }
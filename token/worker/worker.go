@@ -33,6 +33,8 @@ import (
 	"github.com/mind-security/relic/v8/config"
 	"github.com/mind-security/relic/v8/internal/activation/activatecmd"
 	"github.com/mind-security/relic/v8/internal/closeonce"
+	"github.com/mind-security/relic/v8/lib/audit"
+	"github.com/mind-security/relic/v8/token"
 )
 
 const (
@@ -169,6 +171,7 @@ func (t *WorkerToken) monitor() {
 	if t.config.Server != nil && t.config.Server.NumWorkers > 0 {
 		target = t.config.Server.NumWorkers
 	}
+	var restartReason string
 	for t.ctx.Err() == nil {
 		for t.countWorkers() < target {
 			if err := t.spawn(); err != nil {
@@ -178,6 +181,8 @@ func (t *WorkerToken) monitor() {
 				case <-t.ctx.Done():
 					return
 				}
+			} else if restartReason != "" {
+				token.AuditEvent(t.tconf, audit.EventSessionRestart, restartReason)
 			}
 		}
 		select {
@@ -186,9 +191,11 @@ func (t *WorkerToken) monitor() {
 		case pid := <-t.procsExited:
 			// process exited
 			t.removePid(pid)
+			restartReason = "worker exited"
 		case pid := <-t.notify.Stopping():
 			// process hit an error and will exit soon
 			t.removePid(pid)
+			restartReason = "worker stopping after error"
 		}
 	}
 }